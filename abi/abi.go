@@ -60,6 +60,8 @@ const (
 	Predicate
 	// Parameters is a slice of individually encodable parameters
 	Parameters
+	// Addresses is a slice of address.Address
+	Addresses
 )
 
 func (t Type) String() string {
@@ -104,6 +106,8 @@ func (t Type) String() string {
 		return "*types.Predicate"
 	case Parameters:
 		return "[]interface{}"
+	case Addresses:
+		return "[]address.Address"
 	default:
 		return "<unknown type>"
 	}
@@ -157,6 +161,8 @@ func (av *Value) String() string {
 		return fmt.Sprint(av.Val.(*types.Predicate))
 	case Parameters:
 		return fmt.Sprint(av.Val.([]interface{}))
+	case Addresses:
+		return fmt.Sprint(av.Val.([]address.Address))
 	default:
 		return "<unknown type>"
 	}
@@ -308,6 +314,13 @@ func (av *Value) Serialize() ([]byte, error) {
 		}
 
 		return cbor.DumpObject(p)
+	case Addresses:
+		addrs, ok := av.Val.([]address.Address)
+		if !ok {
+			return nil, &typeError{[]address.Address{}, av.Val}
+		}
+
+		return cbor.DumpObject(addrs)
 	default:
 		return nil, fmt.Errorf("unrecognized Type: %d", av.Type)
 	}
@@ -361,6 +374,8 @@ func ToValues(i []interface{}) ([]*Value, error) {
 			out = append(out, &Value{Type: Predicate, Val: v})
 		case []interface{}:
 			out = append(out, &Value{Type: Parameters, Val: v})
+		case []address.Address:
+			out = append(out, &Value{Type: Addresses, Val: v})
 		default:
 			return nil, fmt.Errorf("unsupported type: %T", v)
 		}
@@ -515,6 +530,15 @@ func Deserialize(data []byte, t Type) (*Value, error) {
 			Type: t,
 			Val:  parameters,
 		}, nil
+	case Addresses:
+		var addrs []address.Address
+		if err := cbor.DecodeInto(data, &addrs); err != nil {
+			return nil, err
+		}
+		return &Value{
+			Type: t,
+			Val:  addrs,
+		}, nil
 	case Invalid:
 		return nil, ErrInvalidType
 	default:
@@ -542,6 +566,7 @@ var typeTable = map[Type]reflect.Type{
 	PoStProof:      reflect.TypeOf(types.PoStProof{}),
 	Predicate:      reflect.TypeOf(&types.Predicate{}),
 	Parameters:     reflect.TypeOf([]interface{}{}),
+	Addresses:      reflect.TypeOf([]address.Address{}),
 }
 
 // TypeMatches returns whether or not 'val' is the go type expected for the given ABI type
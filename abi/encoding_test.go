@@ -29,6 +29,7 @@ func TestBasicEncodingRoundTrip(t *testing.T) {
 		"a string":   {"flugzeug"},
 		"mixed":      {big.NewInt(17), []byte("beep"), "mr rogers", addrGetter()},
 		"sector ids": {uint64(1234), uint64(0)},
+		"addresses":  {[]address.Address{addrGetter(), addrGetter()}},
 		"predicate": {&types.Predicate{
 			To:     addrGetter(),
 			Method: "someMethod",
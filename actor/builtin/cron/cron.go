@@ -0,0 +1,119 @@
+// Package cron implements the actor invoked by the state transition once per
+// tipset to run the callbacks of other actors.
+package cron
+
+import (
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	xerrors "github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+func init() {
+	cbor.RegisterCborType(State{})
+	cbor.RegisterCborType(Entry{})
+}
+
+// Actor is the builtin actor holding the methods of other actors that the
+// state transition calls once per tipset, after the messages of the tipset are
+// applied, so that they don't depend on external parties sending messages to
+// check deadlines or clean up expired state.  It is installed at
+// address.CronAddress.  Each entry is called in its own state tree, so a
+// failing entry neither leaves partial writes nor prevents the others from
+// running.
+//
+// Since callbacks run without anyone paying for their gas, entries are only
+// registered at genesis and by the state migrations of protocol upgrades.
+type Actor struct{}
+
+// Entry is a method of an actor called on every tick.  The method is sent
+// from address.CronAddress, which the actor may check, and takes the height of
+// the previous tick, so that it handles every height since then including null
+// rounds.
+type Entry struct {
+	Actor  address.Address
+	Method string
+}
+
+// State is the cron actor's storage.
+type State struct {
+	// Entries are called in order on every tick.
+	Entries []Entry
+}
+
+// NewActor returns a new cron actor.
+func NewActor() *actor.Actor {
+	return actor.NewActor(types.CronActorCodeCid, types.NewZeroAttoFIL())
+}
+
+// EmptyState returns an empty cron actor state for decoding its storage.
+func (ca *Actor) EmptyState() interface{} {
+	return &State{}
+}
+
+// InitializeState stores the actor's initial data structure.  The
+// initializerData is the list of entries, if any.
+func (ca *Actor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	var cronState State
+	if initializerData != nil {
+		entries, ok := initializerData.([]Entry)
+		if !ok {
+			return errors.NewFaultError("Initial state to cron actor is not a list of entries")
+		}
+		cronState.Entries = entries
+	}
+
+	stateBytes, err := cbor.DumpObject(cronState)
+	if err != nil {
+		return xerrors.Wrap(err, "failed to cbor marshal object")
+	}
+
+	id, err := storage.Put(stateBytes)
+	if err != nil {
+		return err
+	}
+
+	return storage.Commit(id, cid.Undef)
+}
+
+var _ exec.ExecutableActor = (*Actor)(nil)
+
+var cronExports = exec.Exports{
+	"getEntries": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Bytes},
+	},
+}
+
+// Exports returns the cron actor's exported functions.
+func (ca *Actor) Exports() exec.Exports {
+	return cronExports
+}
+
+// GetEntries returns the cbor encoded list of entries.
+func (ca *Actor) GetEntries(ctx exec.VMContext) ([]byte, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		return cbor.DumpObject(state.Entries)
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	entries, ok := out.([]byte)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected []byte to be returned, but got %T instead", out)
+	}
+
+	return entries, 0, nil
+}
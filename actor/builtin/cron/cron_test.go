@@ -0,0 +1,90 @@
+package cron_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	. "github.com/filecoin-project/go-filecoin/actor/builtin/cron"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+func TestCronActorGenesis(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	st, vms := requireGenesis(ctx, t)
+
+	cronActor := state.MustGetActor(st, address.CronAddress)
+	assert.Equal(t, types.CronActorCodeCid, cronActor.Code)
+
+	ret, code, err := consensus.CallQueryMethod(ctx, st, vms, address.CronAddress, "getEntries", nil, address.Undef, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), code)
+
+	var entries []Entry
+	require.NoError(t, cbor.DecodeInto(ret[0], &entries))
+	assert.Equal(t, []Entry{
+		{Actor: address.StorageMarketAddress, Method: "checkProvingPeriods"},
+		{Actor: address.PaymentBrokerAddress, Method: "expireChannels"},
+	}, entries)
+}
+
+func TestCronEntriesFire(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	st, vms := requireGenesis(ctx, t)
+	newAddress := address.NewForTestGetter()
+	target, minerOwner := newAddress(), newAddress()
+
+	payerBalance := state.MustGetActor(st, address.TestAddress).Balance
+	nonce, err := actor.NextNonce(state.MustGetActor(st, address.TestAddress))
+	require.NoError(t, err)
+	params, err := abi.ToEncodedValues(target, types.NewBlockHeight(10))
+	require.NoError(t, err)
+	msg := types.NewMessage(address.TestAddress, address.PaymentBrokerAddress, nonce, types.NewAttoFILFromFIL(100), "createChannel", params)
+	result, err := th.ApplyTestMessage(st, vms, msg, types.NewBlockHeight(1))
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), result.Receipt.ExitCode)
+	assert.Equal(t, payerBalance.Sub(types.NewAttoFILFromFIL(100)), state.MustGetActor(st, address.TestAddress).Balance)
+
+	processor := consensus.NewDefaultProcessor()
+	_, err = processor.ApplyMessagesAndPayRewards(ctx, st, vms, nil, minerOwner, types.NewBlockHeight(9), nil)
+	require.NoError(t, err)
+	assert.Equal(t, payerBalance.Sub(types.NewAttoFILFromFIL(100)), state.MustGetActor(st, address.TestAddress).Balance)
+
+	// the channel expires without anyone sending a reclaim message
+	_, err = processor.ApplyMessagesAndPayRewards(ctx, st, vms, nil, minerOwner, types.NewBlockHeight(10), nil)
+	require.NoError(t, err)
+	assert.Equal(t, payerBalance, state.MustGetActor(st, address.TestAddress).Balance)
+}
+
+func requireGenesis(ctx context.Context, t *testing.T) (state.Tree, vm.StorageMap) {
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := vm.NewStorageMap(bs)
+
+	cst := hamt.NewCborStore()
+	blk, err := consensus.DefaultGenesis(cst, bs)
+	require.NoError(t, err)
+
+	st, err := state.LoadStateTree(ctx, cst, blk.StateRoot, builtin.Actors)
+	require.NoError(t, err)
+
+	return st, vms
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm/errors"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 )
 
 func init() {
@@ -50,19 +51,19 @@ const (
 	// ErrPublicKeyTooBig indicates an invalid public key.
 	ErrPublicKeyTooBig = 33
 	// ErrInvalidSector indicates and invalid sector id.
-	ErrInvalidSector = 34
+	ErrInvalidSector = exitcode.IllegalArgument
 	// ErrSectorCommitted indicates the sector has already been committed.
 	ErrSectorCommitted = 35
 	// ErrStoragemarketCallFailed indicates the call to commit the deal failed.
 	ErrStoragemarketCallFailed = 36
 	// ErrCallerUnauthorized signals an unauthorized caller.
-	ErrCallerUnauthorized = 37
+	ErrCallerUnauthorized = exitcode.Forbidden
 	// ErrInsufficientPledge signals insufficient pledge for what you are trying to do.
 	ErrInsufficientPledge = 38
 	// ErrInvalidPoSt signals that the passed in PoSt was invalid.
 	ErrInvalidPoSt = 39
 	// ErrAskNotFound indicates that no ask was found with the given ID.
-	ErrAskNotFound = 40
+	ErrAskNotFound = exitcode.NotFound
 	// ErrInvalidSealProof signals that the passed in seal proof was invalid.
 	ErrInvalidSealProof = 41
 	// ErrGetProofsModeFailed indicates the call to get the proofs mode failed.
@@ -164,6 +165,11 @@ func NewState(owner address.Address, key []byte, pledge *big.Int, pid peer.ID, c
 	}
 }
 
+// EmptyState returns an empty miner state for decoding its storage.
+func (ma *Actor) EmptyState() interface{} {
+	return &State{}
+}
+
 // InitializeState stores this miner's initial data structure.
 func (ma *Actor) InitializeState(storage exec.Storage, initializerData interface{}) error {
 	minerState, ok := initializerData.(*State)
@@ -245,6 +251,10 @@ var minerExports = exec.Exports{
 		Params: []abi.Type{abi.Bytes, abi.SectorID, abi.Bytes},
 		Return: []abi.Type{},
 	},
+	"checkProvingPeriod": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Integer},
+	},
 	"getProvingPeriodStart": &exec.FunctionSignature{
 		Params: []abi.Type{},
 		Return: []abi.Type{abi.BlockHeight},
@@ -294,7 +304,7 @@ func (ma *Actor) AddAsk(ctx exec.VMContext, price *types.AttoFIL, expiry *big.In
 		}
 
 		if !expiry.IsUint64() {
-			return nil, errors.NewRevertError("expiry was invalid")
+			return nil, errors.NewCodedRevertError(exitcode.IllegalArgument, "expiry was invalid")
 		}
 		expiryBH := types.NewBlockHeight(expiry.Uint64())
 
@@ -488,13 +498,13 @@ func (ma *Actor) CommitSector(ctx exec.VMContext, sectorID uint64, commD, commR,
 		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
 	}
 	if len(commD) != int(types.CommitmentBytesLen) {
-		return 1, errors.NewRevertError("invalid sized commD")
+		return exitcode.IllegalArgument, errors.NewCodedRevertError(exitcode.IllegalArgument, "invalid sized commD")
 	}
 	if len(commR) != int(types.CommitmentBytesLen) {
-		return 1, errors.NewRevertError("invalid sized commR")
+		return exitcode.IllegalArgument, errors.NewCodedRevertError(exitcode.IllegalArgument, "invalid sized commR")
 	}
 	if len(commRStar) != int(types.CommitmentBytesLen) {
-		return 1, errors.NewRevertError("invalid sized commRStar")
+		return exitcode.IllegalArgument, errors.NewCodedRevertError(exitcode.IllegalArgument, "invalid sized commRStar")
 	}
 
 	var state State
@@ -538,6 +548,9 @@ func (ma *Actor) CommitSector(ctx exec.VMContext, sectorID uint64, commD, commR,
 
 		if state.Power.Cmp(big.NewInt(0)) == 0 {
 			state.ProvingPeriodStart = ctx.BlockHeight()
+			if err := ma.scheduleProvingCheck(ctx, state.ProvingPeriodStart); err != nil {
+				return nil, err
+			}
 		}
 		inc := big.NewInt(1)
 		state.Power = state.Power.Add(state.Power, inc)
@@ -581,17 +594,17 @@ func (ma *Actor) VerifyPieceInclusion(ctx exec.VMContext, commP []byte, sectorID
 		sectorIDstr := strconv.FormatUint(sectorID, 10)
 		commitment, ok := state.SectorCommitments[sectorIDstr]
 		if !ok {
-			return nil, errors.NewRevertError("sector not committed")
+			return nil, errors.NewCodedRevertError(exitcode.IllegalState, "sector not committed")
 		}
 
 		// If miner is not up-to-date on their PoSts, proof is invalid
 		if state.LastPoSt == nil {
-			return nil, errors.NewRevertError("proofs out of date")
+			return nil, errors.NewCodedRevertError(exitcode.IllegalState, "proofs out of date")
 		}
 
 		clientProofsTimeout := state.LastPoSt.Add(types.NewBlockHeight(PieceInclusionGracePeriodBlocks))
 		if ctx.BlockHeight().GreaterThan(clientProofsTimeout) {
-			return nil, errors.NewRevertError("proofs out of date")
+			return nil, errors.NewCodedRevertError(exitcode.IllegalState, "proofs out of date")
 		}
 
 		// Verify proof proves CommP is in sector's CommD
@@ -603,7 +616,7 @@ func (ma *Actor) VerifyPieceInclusion(ctx exec.VMContext, commP []byte, sectorID
 		}
 
 		if !valid {
-			return nil, errors.NewRevertError("invalid inclusion proof")
+			return nil, errors.NewCodedRevertError(exitcode.IllegalArgument, "invalid inclusion proof")
 		}
 
 		return nil, nil
@@ -781,6 +794,9 @@ func (ma *Actor) SubmitPoSt(ctx exec.VMContext, poStProofs []types.PoStProof) (u
 		// transition to the next proving period
 		state.ProvingPeriodStart = provingPeriodEnd
 		state.LastPoSt = ctx.BlockHeight()
+		if err := ma.scheduleProvingCheck(ctx, state.ProvingPeriodStart); err != nil {
+			return nil, err
+		}
 
 		return nil, nil
 	})
@@ -791,6 +807,77 @@ func (ma *Actor) SubmitPoSt(ctx exec.VMContext, poStProofs []types.PoStProof) (u
 	return 0, nil
 }
 
+// CheckProvingPeriod removes the power of a miner that didn't submit a PoSt
+// for its proving period by the end of its grace period and burns its
+// collateral, and returns the power removed.  It is called by the storage
+// market, which updates the total power, once the grace period is over.
+// Bootstrap miners don't submit PoSts and keep their power.
+func (ma *Actor) CheckProvingPeriod(ctx exec.VMContext) (*big.Int, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	ret, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		if ctx.Message().From != address.StorageMarketAddress {
+			return nil, Errors[ErrCallerUnauthorized]
+		}
+
+		if ma.Bootstrap || state.ProvingPeriodStart == nil || state.Power.Sign() == 0 {
+			return big.NewInt(0), nil
+		}
+
+		deadline := state.ProvingPeriodStart.Add(types.NewBlockHeight(ProvingPeriodBlocks + GracePeriodBlocks))
+		if !ctx.BlockHeight().GreaterThan(deadline) {
+			return big.NewInt(0), nil
+		}
+
+		slashed := state.Collateral
+		if ctx.Balance().LessThan(slashed) {
+			slashed = ctx.Balance()
+		}
+		if slashed.IsPositive() {
+			if _, _, err := ctx.Send(address.BurntFundsAddress, "", slashed, nil); err != nil {
+				return nil, err
+			}
+		}
+		state.Collateral = state.Collateral.Sub(slashed)
+
+		lost := state.Power
+		state.Power = big.NewInt(0)
+		return lost, nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	lost, ok := ret.(*big.Int)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected *big.Int to be returned, but got %T instead", ret)
+	}
+
+	return lost, 0, nil
+}
+
+// scheduleProvingCheck has the storage market check the proving period
+// starting at start once its grace period is over.  Bootstrap miners aren't
+// checked.
+func (ma *Actor) scheduleProvingCheck(ctx exec.VMContext, start *types.BlockHeight) error {
+	if ma.Bootstrap {
+		return nil
+	}
+
+	checkAt := start.Add(types.NewBlockHeight(ProvingPeriodBlocks + GracePeriodBlocks + 1))
+	_, ret, err := ctx.Send(address.StorageMarketAddress, "scheduleProvingCheck", nil, []interface{}{checkAt})
+	if err != nil {
+		return err
+	}
+	if ret != 0 {
+		return Errors[ErrStoragemarketCallFailed]
+	}
+	return nil
+}
+
 // GetProvingPeriodStart returns the current ProvingPeriodStart value.
 func (ma *Actor) GetProvingPeriodStart(ctx exec.VMContext) (*types.BlockHeight, uint8, error) {
 	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
@@ -839,7 +926,7 @@ func GetProofsMode(ctx exec.VMContext) (types.ProofsMode, error) {
 // see https://github.com/filecoin-project/go-filecoin/issues/2629
 func verifyInclusionProof(commP types.CommP, commD types.CommD, proof []byte) (bool, error) {
 	if len(proof) != 2*int(types.CommitmentBytesLen) {
-		return false, errors.NewRevertError("malformed inclusion proof")
+		return false, errors.NewCodedRevertError(exitcode.IllegalArgument, "malformed inclusion proof")
 	}
 	combined := []byte{}
 	combined = append(combined, commP[:]...)
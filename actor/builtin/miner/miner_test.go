@@ -9,9 +9,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	. "github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	atesting "github.com/filecoin-project/go-filecoin/actor/testing"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
@@ -345,6 +347,60 @@ func TestMinerSubmitPoSt(t *testing.T) {
 	require.EqualError(t, res.ExecutionError, "submitted PoSt late, need to pay a fee")
 }
 
+func TestMinerCheckProvingPeriod(t *testing.T) {
+	tf.UnitTest(t)
+
+	minerAddr := address.NewForTestGetter()()
+	collateral := types.NewAttoFILFromFIL(10)
+	newRuntime := func(t *testing.T, act *Actor) *atesting.Runtime {
+		rt := atesting.NewRuntime(minerAddr, types.MinerActorCodeCid, collateral)
+		minerState := NewState(address.TestAddress, []byte("my public key"), big.NewInt(10), th.RequireRandomPeerID(t), collateral, types.OneKiBSectorSize)
+		minerState.Power = big.NewInt(2)
+		minerState.ProvingPeriodStart = types.NewBlockHeight(3)
+		require.NoError(t, rt.Initialize(act, minerState))
+		rt.Caller = address.StorageMarketAddress
+		return rt
+	}
+	checkProvingPeriod := func(t *testing.T, rt *atesting.Runtime, act *Actor, height uint64) *big.Int {
+		rt.Height = types.NewBlockHeight(height)
+		ret, code, err := rt.Call(act, "checkProvingPeriod")
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), code)
+		lost, err := abi.Deserialize(ret[0], abi.Integer)
+		require.NoError(t, err)
+		return lost.Val.(*big.Int)
+	}
+	deadline := uint64(3 + ProvingPeriodBlocks + GracePeriodBlocks)
+
+	t.Run("only the storage market may check the proving period", func(t *testing.T) {
+		rt := newRuntime(t, &Actor{})
+		rt.Caller = address.TestAddress
+		_, code, err := rt.Call(&Actor{}, "checkProvingPeriod")
+		assert.Error(t, err)
+		assert.Equal(t, uint8(ErrCallerUnauthorized), code)
+	})
+
+	t.Run("removes the power of a miner past its grace period and burns its collateral", func(t *testing.T) {
+		rt := newRuntime(t, &Actor{})
+		assert.Equal(t, big.NewInt(0), checkProvingPeriod(t, rt, &Actor{}, deadline))
+		assert.Equal(t, big.NewInt(2), checkProvingPeriod(t, rt, &Actor{}, deadline+1))
+
+		var minerState State
+		require.NoError(t, rt.State(&minerState))
+		assert.Equal(t, big.NewInt(0), minerState.Power)
+		assert.Equal(t, types.ZeroAttoFIL, minerState.Collateral)
+		assert.Equal(t, collateral, rt.BalanceOf(address.BurntFundsAddress))
+		assert.Equal(t, big.NewInt(0), checkProvingPeriod(t, rt, &Actor{}, deadline+2))
+	})
+
+	t.Run("bootstrap miners keep their power", func(t *testing.T) {
+		bootstrap := &Actor{Bootstrap: true}
+		rt := newRuntime(t, bootstrap)
+		assert.Equal(t, big.NewInt(0), checkProvingPeriod(t, rt, bootstrap, deadline+1))
+		assert.Equal(t, types.ZeroAttoFIL, rt.BalanceOf(address.BurntFundsAddress))
+	})
+}
+
 func TestVerifyPIP(t *testing.T) {
 	tf.UnitTest(t)
 
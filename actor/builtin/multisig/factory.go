@@ -0,0 +1,80 @@
+package multisig
+
+import (
+	"math/big"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+// FactoryActor creates multisig wallets.  It is installed at genesis at
+// address.MultisigFactoryAddress and holds no state.
+type FactoryActor struct{}
+
+// NewFactoryActor returns a new multisig factory actor.
+func NewFactoryActor() *actor.Actor {
+	return actor.NewActor(types.MultisigFactoryActorCodeCid, types.NewZeroAttoFIL())
+}
+
+// InitializeState stores the actor's initial data structure.
+func (fa *FactoryActor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	// the factory has no state, so this method is a no-op
+	return nil
+}
+
+var _ exec.ExecutableActor = (*FactoryActor)(nil)
+
+var factoryExports = exec.Exports{
+	"createMultisig": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Addresses, abi.Integer, abi.BlockHeight},
+		Return: []abi.Type{abi.Address},
+	},
+}
+
+// Exports returns the factory's exported functions.
+func (fa *FactoryActor) Exports() exec.Exports {
+	return factoryExports
+}
+
+// CreateMultisig creates a wallet spending its funds with the approval of
+// required of the given signers, and returns its address.  The value of the
+// message funds the wallet.  If unlockDuration is not zero these funds are
+// locked and unlock linearly over that many blocks from now.
+func (fa *FactoryActor) CreateMultisig(vmctx exec.VMContext, signers []address.Address, required *big.Int, unlockDuration *types.BlockHeight) (address.Address, uint8, error) {
+	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+		return address.Undef, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	if !required.IsUint64() {
+		return address.Undef, errors.CodeError(Errors[ErrInvalidRequired]), Errors[ErrInvalidRequired]
+	}
+
+	addr, err := vmctx.AddressForNewActor()
+	if err != nil {
+		err = errors.FaultErrorWrap(err, "could not get address for new actor")
+		return address.Undef, errors.CodeError(err), err
+	}
+
+	value := vmctx.Message().Value
+	if value == nil {
+		value = types.NewZeroAttoFIL()
+	}
+	if unlockDuration == nil {
+		unlockDuration = types.NewBlockHeight(0)
+	}
+	walletState := NewState(signers, required.Uint64(), value, vmctx.BlockHeight(), unlockDuration)
+
+	if err := vmctx.CreateNewActor(addr, types.MultisigActorCodeCid, walletState); err != nil {
+		return address.Undef, errors.CodeError(err), err
+	}
+
+	if _, _, err := vmctx.Send(addr, "", value, nil); err != nil {
+		return address.Undef, errors.CodeError(err), err
+	}
+
+	return addr, 0, nil
+}
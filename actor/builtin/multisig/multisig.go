@@ -0,0 +1,454 @@
+// Package multisig implements a wallet actor whose funds are only spent with
+// the approval of a number of its signers, and the factory creating them.
+package multisig
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	xerrors "github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+func init() {
+	cbor.RegisterCborType(State{})
+	cbor.RegisterCborType(Transaction{})
+}
+
+// MaxSigners is the maximum number of signers of a multisig wallet.
+const MaxSigners = 256
+
+const (
+	// ErrInvalidSigners indicates a wallet created without signers, with too
+	// many or with duplicate signers.
+	ErrInvalidSigners = 33
+	// ErrInvalidRequired indicates a number of required approvals of zero or
+	// larger than the number of signers.
+	ErrInvalidRequired = 34
+	// ErrNotSigner indicates a caller that is not a signer of the wallet.
+	ErrNotSigner = 35
+	// ErrUnknownTransaction indicates an invalid transaction id.
+	ErrUnknownTransaction = 36
+	// ErrAlreadyApproved indicates a signer approving a transaction twice.
+	ErrAlreadyApproved = 37
+	// ErrNotProposer indicates an attempt to cancel a transaction proposed by
+	// another signer.
+	ErrNotProposer = 38
+	// ErrInsufficientUnlocked indicates a transaction spending funds still
+	// locked by the vesting schedule of the wallet.
+	ErrInsufficientUnlocked = 39
+	// ErrSelfTransaction indicates a transaction sent to the wallet itself.
+	ErrSelfTransaction = 40
+)
+
+// Errors map error codes to revert errors this actor may return.
+var Errors = map[uint8]error{
+	ErrInvalidSigners:       errors.NewCodedRevertErrorf(ErrInvalidSigners, "signers must be between 1 and %d distinct addresses", MaxSigners),
+	ErrInvalidRequired:      errors.NewCodedRevertErrorf(ErrInvalidRequired, "required approvals must be between 1 and the number of signers"),
+	ErrNotSigner:            errors.NewCodedRevertErrorf(ErrNotSigner, "caller is not a signer of the wallet"),
+	ErrUnknownTransaction:   errors.NewCodedRevertErrorf(ErrUnknownTransaction, "transaction is unknown"),
+	ErrAlreadyApproved:      errors.NewCodedRevertErrorf(ErrAlreadyApproved, "transaction already approved by caller"),
+	ErrNotProposer:          errors.NewCodedRevertErrorf(ErrNotProposer, "only the proposer may cancel a transaction"),
+	ErrInsufficientUnlocked: errors.NewCodedRevertErrorf(ErrInsufficientUnlocked, "transaction value exceeds the unlocked funds of the wallet"),
+	ErrSelfTransaction:      errors.NewCodedRevertErrorf(ErrSelfTransaction, "transactions may not be sent to the wallet itself"),
+}
+
+// Actor is a wallet spending its funds in transactions proposed by one of its
+// signers and approved by Required of them.  Funds given at creation can be
+// locked, and unlock linearly over a number of blocks.
+type Actor struct{}
+
+// Transaction is a message the wallet sends once enough signers approve it.
+type Transaction struct {
+	ID     uint64
+	To     address.Address
+	Value  *types.AttoFIL
+	Method string
+	Params []interface{}
+
+	// Approved lists the signers that approved the transaction, starting
+	// with its proposer.
+	Approved []address.Address
+}
+
+// State is the multisig wallet's storage.
+type State struct {
+	Signers  []address.Address
+	Required uint64
+
+	// Transactions maps transaction id to the transactions awaiting
+	// approvals. Due to a bug in refmt, the ids need to be stringified.
+	//
+	// See also: https://github.com/polydawn/refmt/issues/35
+	Transactions map[string]*Transaction
+	NextTxID     uint64
+
+	// InitialBalance is locked at StartHeight and unlocks linearly over
+	// UnlockDuration blocks.  A zero UnlockDuration leaves all funds
+	// unlocked.
+	InitialBalance *types.AttoFIL
+	StartHeight    *types.BlockHeight
+	UnlockDuration *types.BlockHeight
+}
+
+// NewActor returns a new multisig wallet actor.
+func NewActor() *actor.Actor {
+	return actor.NewActor(types.MultisigActorCodeCid, types.NewZeroAttoFIL())
+}
+
+// NewState creates a multisig wallet state struct.
+func NewState(signers []address.Address, required uint64, initialBalance *types.AttoFIL, start, unlockDuration *types.BlockHeight) *State {
+	return &State{
+		Signers:        signers,
+		Required:       required,
+		Transactions:   make(map[string]*Transaction),
+		InitialBalance: initialBalance,
+		StartHeight:    start,
+		UnlockDuration: unlockDuration,
+	}
+}
+
+// EmptyState returns an empty multisig wallet state for decoding its storage.
+func (ma *Actor) EmptyState() interface{} {
+	return &State{}
+}
+
+// InitializeState stores this wallet's initial data structure.
+func (ma *Actor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	walletState, ok := initializerData.(*State)
+	if !ok {
+		return errors.NewFaultError("Initial state to multisig actor is not a multisig.State struct")
+	}
+
+	if len(walletState.Signers) == 0 || len(walletState.Signers) > MaxSigners {
+		return Errors[ErrInvalidSigners]
+	}
+	seen := make(map[address.Address]struct{}, len(walletState.Signers))
+	for _, s := range walletState.Signers {
+		if _, ok := seen[s]; ok {
+			return Errors[ErrInvalidSigners]
+		}
+		seen[s] = struct{}{}
+	}
+	if walletState.Required == 0 || walletState.Required > uint64(len(walletState.Signers)) {
+		return Errors[ErrInvalidRequired]
+	}
+
+	stateBytes, err := cbor.DumpObject(walletState)
+	if err != nil {
+		return xerrors.Wrap(err, "failed to cbor marshal object")
+	}
+
+	id, err := storage.Put(stateBytes)
+	if err != nil {
+		return err
+	}
+
+	return storage.Commit(id, cid.Undef)
+}
+
+var _ exec.ExecutableActor = (*Actor)(nil)
+
+var multisigExports = exec.Exports{
+	"propose": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Address, abi.AttoFIL, abi.String, abi.Parameters},
+		Return: []abi.Type{abi.Integer},
+	},
+	"approve": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Integer},
+		Return: nil,
+	},
+	"cancel": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Integer},
+		Return: nil,
+	},
+	"getSigners": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Addresses},
+	},
+	"getRequired": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Integer},
+	},
+	"getTransactions": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Bytes},
+	},
+	"getLocked": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.AttoFIL},
+	},
+}
+
+// Exports returns the multisig actor's exported functions.
+func (ma *Actor) Exports() exec.Exports {
+	return multisigExports
+}
+
+// Propose records a transaction sending value to the given method of to,
+// approved by the calling signer, and returns its id.  The transaction is
+// sent right away if it needs no other approval.
+func (ma *Actor) Propose(ctx exec.VMContext, to address.Address, value *types.AttoFIL, method string, params []interface{}) (*big.Int, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	if to == ctx.Message().To {
+		return nil, errors.CodeError(Errors[ErrSelfTransaction]), Errors[ErrSelfTransaction]
+	}
+
+	var state State
+	var ready *Transaction
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		caller := ctx.Message().From
+		if !state.isSigner(caller) {
+			return nil, Errors[ErrNotSigner]
+		}
+
+		tx := &Transaction{
+			ID:       state.NextTxID,
+			To:       to,
+			Value:    value,
+			Method:   method,
+			Params:   params,
+			Approved: []address.Address{caller},
+		}
+		state.NextTxID++
+
+		if uint64(len(tx.Approved)) >= state.Required {
+			ready = tx
+		} else {
+			state.Transactions[txKey(tx.ID)] = tx
+		}
+
+		return big.NewInt(0).SetUint64(tx.ID), nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	if ready != nil {
+		if err := execute(ctx, &state, ready); err != nil {
+			return nil, errors.CodeError(err), err
+		}
+	}
+
+	return out.(*big.Int), 0, nil
+}
+
+// Approve records the approval of the transaction with the given id by the
+// calling signer, and sends the transaction once it has as many approvals as
+// the wallet requires.  Sending fails, and the approval is not recorded, if the
+// transaction spends funds that are still locked.
+func (ma *Actor) Approve(ctx exec.VMContext, txid *big.Int) (uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	var ready *Transaction
+	_, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		caller := ctx.Message().From
+		if !state.isSigner(caller) {
+			return nil, Errors[ErrNotSigner]
+		}
+
+		tx, ok := state.Transactions[txid.String()]
+		if !ok {
+			return nil, Errors[ErrUnknownTransaction]
+		}
+		for _, a := range tx.Approved {
+			if a == caller {
+				return nil, Errors[ErrAlreadyApproved]
+			}
+		}
+
+		tx.Approved = append(tx.Approved, caller)
+		if uint64(len(tx.Approved)) >= state.Required {
+			ready = tx
+			delete(state.Transactions, txid.String())
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	if ready != nil {
+		if err := execute(ctx, &state, ready); err != nil {
+			return errors.CodeError(err), err
+		}
+	}
+
+	return 0, nil
+}
+
+// Cancel drops the transaction with the given id.  Only the signer that
+// proposed a transaction may cancel it.
+func (ma *Actor) Cancel(ctx exec.VMContext, txid *big.Int) (uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	_, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		tx, ok := state.Transactions[txid.String()]
+		if !ok {
+			return nil, Errors[ErrUnknownTransaction]
+		}
+		if tx.Approved[0] != ctx.Message().From {
+			return nil, Errors[ErrNotProposer]
+		}
+
+		delete(state.Transactions, txid.String())
+		return nil, nil
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
+// GetSigners returns the signers of the wallet.
+func (ma *Actor) GetSigners(ctx exec.VMContext) ([]address.Address, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		return state.Signers, nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	signers, ok := out.([]address.Address)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected []address.Address to be returned, but got %T instead", out)
+	}
+
+	return signers, 0, nil
+}
+
+// GetRequired returns the number of approvals transactions need.
+func (ma *Actor) GetRequired(ctx exec.VMContext) (*big.Int, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		return big.NewInt(0).SetUint64(state.Required), nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	required, ok := out.(*big.Int)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected *big.Int to be returned, but got %T instead", out)
+	}
+
+	return required, 0, nil
+}
+
+// GetTransactions returns the cbor encoded map of the transactions awaiting
+// approvals, keyed by stringified id.
+func (ma *Actor) GetTransactions(ctx exec.VMContext) ([]byte, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		return cbor.DumpObject(state.Transactions)
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	txs, ok := out.([]byte)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected a Bytes return value from call, but got %T instead", out)
+	}
+
+	return txs, 0, nil
+}
+
+// GetLocked returns the funds of the wallet still locked by its vesting
+// schedule.
+func (ma *Actor) GetLocked(ctx exec.VMContext) (*types.AttoFIL, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		return state.Locked(ctx.BlockHeight()), nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	locked, ok := out.(*types.AttoFIL)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected *types.AttoFIL to be returned, but got %T instead", out)
+	}
+
+	return locked, 0, nil
+}
+
+// Locked returns the part of the initial balance of the wallet that is still
+// locked at the given height, rounded up.
+func (st *State) Locked(height *types.BlockHeight) *types.AttoFIL {
+	if st.UnlockDuration == nil || st.UnlockDuration.Equal(types.NewBlockHeight(0)) {
+		return types.NewZeroAttoFIL()
+	}
+
+	end := st.StartHeight.Add(st.UnlockDuration)
+	if height.GreaterEqual(end) {
+		return types.NewZeroAttoFIL()
+	}
+	if height.LessThan(st.StartHeight) {
+		return st.InitialBalance
+	}
+
+	remaining := end.Sub(height)
+	return st.InitialBalance.MulBigInt(remaining.AsBigInt()).DivCeil(types.NewAttoFIL(st.UnlockDuration.AsBigInt()))
+}
+
+func (st *State) isSigner(addr address.Address) bool {
+	for _, s := range st.Signers {
+		if s == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// execute sends tx from the wallet, if its value leaves the locked funds in
+// the wallet.  The transaction must already be removed from the stored state.
+func execute(ctx exec.VMContext, state *State, tx *Transaction) error {
+	remaining := ctx.Balance().Sub(tx.Value)
+	if remaining.LessThan(state.Locked(ctx.BlockHeight())) {
+		return Errors[ErrInsufficientUnlocked]
+	}
+
+	_, _, err := ctx.Send(tx.To, tx.Method, tx.Value, tx.Params)
+	return err
+}
+
+func txKey(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
@@ -0,0 +1,189 @@
+package multisig_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	. "github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+func TestMultisigCreate(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	st, vms := requireGenesis(ctx, t)
+	signers := []address.Address{address.TestAddress, address.TestAddress2}
+
+	t.Run("creates a funded wallet", func(t *testing.T) {
+		wallet := requireCreateMultisig(t, st, vms, signers, 2, 100, 0)
+
+		walletActor := state.MustGetActor(st, wallet)
+		assert.Equal(t, types.MultisigActorCodeCid, walletActor.Code)
+		assert.Equal(t, types.NewAttoFILFromFIL(100), walletActor.Balance)
+
+		var walletState State
+		builtin.RequireReadState(t, vms, wallet, walletActor, &walletState)
+		assert.Equal(t, signers, walletState.Signers)
+		assert.Equal(t, uint64(2), walletState.Required)
+	})
+
+	t.Run("rejects more required approvals than signers", func(t *testing.T) {
+		result := applyMessage(t, st, vms, address.TestAddress, address.MultisigFactoryAddress, 100, 0, "createMultisig", signers, big.NewInt(3), types.NewBlockHeight(0))
+		assert.Equal(t, uint8(ErrInvalidRequired), result.Receipt.ExitCode)
+	})
+
+	t.Run("rejects duplicate signers", func(t *testing.T) {
+		dup := []address.Address{address.TestAddress, address.TestAddress}
+		result := applyMessage(t, st, vms, address.TestAddress, address.MultisigFactoryAddress, 100, 0, "createMultisig", dup, big.NewInt(1), types.NewBlockHeight(0))
+		assert.Equal(t, uint8(ErrInvalidSigners), result.Receipt.ExitCode)
+	})
+}
+
+func TestMultisigApprovals(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signers := []address.Address{address.TestAddress, address.TestAddress2}
+	target := address.NewForTestGetter()()
+
+	t.Run("sends a transaction once enough signers approve", func(t *testing.T) {
+		st, vms := requireGenesis(ctx, t)
+		wallet := requireCreateMultisig(t, st, vms, signers, 2, 100, 0)
+
+		result := applyMessage(t, st, vms, address.TestAddress, wallet, 0, 0, "propose", target, types.NewAttoFILFromFIL(30), "", []interface{}{})
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+		txid := big.NewInt(0).SetBytes(result.Receipt.Return[0])
+		assert.Equal(t, types.NewAttoFILFromFIL(100), state.MustGetActor(st, wallet).Balance)
+
+		result = applyMessage(t, st, vms, address.TestAddress, wallet, 0, 0, "approve", txid)
+		assert.Equal(t, uint8(ErrAlreadyApproved), result.Receipt.ExitCode)
+
+		result = applyMessage(t, st, vms, address.TestAddress2, wallet, 0, 0, "approve", txid)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+		assert.Equal(t, types.NewAttoFILFromFIL(70), state.MustGetActor(st, wallet).Balance)
+		assert.Equal(t, types.NewAttoFILFromFIL(30), state.MustGetActor(st, target).Balance)
+
+		result = applyMessage(t, st, vms, address.TestAddress2, wallet, 0, 0, "approve", txid)
+		assert.Equal(t, uint8(ErrUnknownTransaction), result.Receipt.ExitCode)
+	})
+
+	t.Run("sends right away when one approval is required", func(t *testing.T) {
+		st, vms := requireGenesis(ctx, t)
+		wallet := requireCreateMultisig(t, st, vms, signers, 1, 100, 0)
+
+		result := applyMessage(t, st, vms, address.TestAddress2, wallet, 0, 0, "propose", target, types.NewAttoFILFromFIL(30), "", []interface{}{})
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+		assert.Equal(t, types.NewAttoFILFromFIL(30), state.MustGetActor(st, target).Balance)
+	})
+
+	t.Run("rejects callers other than signers", func(t *testing.T) {
+		st, vms := requireGenesis(ctx, t)
+		wallet := requireCreateMultisig(t, st, vms, []address.Address{address.TestAddress2}, 1, 100, 0)
+
+		result := applyMessage(t, st, vms, address.TestAddress, wallet, 0, 0, "propose", target, types.NewAttoFILFromFIL(30), "", []interface{}{})
+		assert.Equal(t, uint8(ErrNotSigner), result.Receipt.ExitCode)
+	})
+
+	t.Run("only the proposer cancels a transaction", func(t *testing.T) {
+		st, vms := requireGenesis(ctx, t)
+		wallet := requireCreateMultisig(t, st, vms, signers, 2, 100, 0)
+
+		result := applyMessage(t, st, vms, address.TestAddress, wallet, 0, 0, "propose", target, types.NewAttoFILFromFIL(30), "", []interface{}{})
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+		txid := big.NewInt(0).SetBytes(result.Receipt.Return[0])
+
+		result = applyMessage(t, st, vms, address.TestAddress2, wallet, 0, 0, "cancel", txid)
+		assert.Equal(t, uint8(ErrNotProposer), result.Receipt.ExitCode)
+
+		result = applyMessage(t, st, vms, address.TestAddress, wallet, 0, 0, "cancel", txid)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		result = applyMessage(t, st, vms, address.TestAddress2, wallet, 0, 0, "approve", txid)
+		assert.Equal(t, uint8(ErrUnknownTransaction), result.Receipt.ExitCode)
+	})
+}
+
+func TestMultisigVesting(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	target := address.NewForTestGetter()()
+	st, vms := requireGenesis(ctx, t)
+	wallet := requireCreateMultisig(t, st, vms, []address.Address{address.TestAddress}, 1, 100, 100)
+
+	// Half of the funds are unlocked at height 50.
+	result := applyMessage(t, st, vms, address.TestAddress, wallet, 0, 50, "propose", target, types.NewAttoFILFromFIL(60), "", []interface{}{})
+	assert.Equal(t, uint8(ErrInsufficientUnlocked), result.Receipt.ExitCode)
+
+	result = applyMessage(t, st, vms, address.TestAddress, wallet, 0, 50, "propose", target, types.NewAttoFILFromFIL(50), "", []interface{}{})
+	require.Equal(t, uint8(0), result.Receipt.ExitCode)
+	assert.Equal(t, types.NewAttoFILFromFIL(50), state.MustGetActor(st, target).Balance)
+
+	result = applyMessage(t, st, vms, address.TestAddress, wallet, 0, 50, "getLocked")
+	require.Equal(t, uint8(0), result.Receipt.ExitCode)
+	assert.Equal(t, types.NewAttoFILFromFIL(50), types.NewAttoFILFromBytes(result.Receipt.Return[0]))
+}
+
+func TestStateLocked(t *testing.T) {
+	tf.UnitTest(t)
+
+	st := NewState(nil, 1, types.NewAttoFILFromFIL(10), types.NewBlockHeight(10), types.NewBlockHeight(4))
+
+	assert.Equal(t, types.NewAttoFILFromFIL(10), st.Locked(types.NewBlockHeight(5)))
+	assert.Equal(t, types.NewAttoFILFromFIL(10), st.Locked(types.NewBlockHeight(10)))
+	assert.Equal(t, types.NewAttoFILFromFIL(5), st.Locked(types.NewBlockHeight(12)))
+	assert.Equal(t, types.NewZeroAttoFIL(), st.Locked(types.NewBlockHeight(14)))
+
+	unlocked := NewState(nil, 1, types.NewAttoFILFromFIL(10), types.NewBlockHeight(10), types.NewBlockHeight(0))
+	assert.Equal(t, types.NewZeroAttoFIL(), unlocked.Locked(types.NewBlockHeight(10)))
+}
+
+func requireGenesis(ctx context.Context, t *testing.T) (state.Tree, vm.StorageMap) {
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := vm.NewStorageMap(bs)
+
+	cst := hamt.NewCborStore()
+	blk, err := consensus.DefaultGenesis(cst, bs)
+	require.NoError(t, err)
+
+	st, err := state.LoadStateTree(ctx, cst, blk.StateRoot, builtin.Actors)
+	require.NoError(t, err)
+
+	return st, vms
+}
+
+func requireCreateMultisig(t *testing.T, st state.Tree, vms vm.StorageMap, signers []address.Address, required int64, value, unlockDuration uint64) address.Address {
+	result := applyMessage(t, st, vms, address.TestAddress, address.MultisigFactoryAddress, value, 0, "createMultisig", signers, big.NewInt(required), types.NewBlockHeight(unlockDuration))
+	require.NoError(t, result.ExecutionError)
+	require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+	wallet, err := address.NewFromBytes(result.Receipt.Return[0])
+	require.NoError(t, err)
+	return wallet
+}
+
+func applyMessage(t *testing.T, st state.Tree, vms vm.StorageMap, from, to address.Address, value, height uint64, method string, params ...interface{}) *consensus.ApplicationResult {
+	nonce, err := actor.NextNonce(state.MustGetActor(st, from))
+	require.NoError(t, err)
+
+	msg := types.NewMessage(from, to, nonce, types.NewAttoFILFromFIL(value), method, actor.MustConvertParams(params...))
+	result, err := th.ApplyTestMessage(st, vms, msg, types.NewBlockHeight(height))
+	require.NoError(t, err)
+	return result
+}
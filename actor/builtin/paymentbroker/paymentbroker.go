@@ -2,7 +2,9 @@ package paymentbroker
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/filecoin-project/go-leb128"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-hamt-ipld"
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -13,23 +15,24 @@ import (
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm/errors"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 )
 
 const (
 	// ErrNonAccountActor indicates an non-account actor attempted to create a payment channel.
 	ErrNonAccountActor = 33
 	// ErrDuplicateChannel indicates an attempt to create a payment channel with an existing id.
-	ErrDuplicateChannel = 34
+	ErrDuplicateChannel = exitcode.AlreadyExists
 	// ErrEolTooLow indicates an attempt to lower the Eol of a payment channel.
 	ErrEolTooLow = 35
 	// ErrReclaimBeforeEol indicates an attempt to reclaim funds before the eol of the channel.
 	ErrReclaimBeforeEol = 36
 	// ErrInsufficientChannelFunds indicates an attempt to take more funds than the channel contains.
-	ErrInsufficientChannelFunds = 37
+	ErrInsufficientChannelFunds = exitcode.InsufficientFunds
 	// ErrUnknownChannel indicates an invalid channel id.
-	ErrUnknownChannel = 38
+	ErrUnknownChannel = exitcode.NotFound
 	// ErrWrongTarget indicates attempt to redeem from wrong target account.
-	ErrWrongTarget = 39
+	ErrWrongTarget = exitcode.Forbidden
 	// ErrExpired indicates the block height has exceeded the eol.
 	ErrExpired = 40
 	// ErrAlreadyWithdrawn indicates amount of the voucher has already been withdrawn.
@@ -42,6 +45,12 @@ const (
 	ErrConditionInvalid = 44
 	//ErrInvalidCancel indicates that the condition attached to a voucher did execute successfully and therefore can't be cancelled
 	ErrInvalidCancel = 45
+	// ErrStaleVoucher indicates a lane voucher with a nonce no higher than the last one redeemed in its lane.
+	ErrStaleVoucher = 46
+	// ErrInvalidVoucher indicates a lane voucher that can't be decoded, has no nonce or merges lanes it can't.
+	ErrInvalidVoucher = exitcode.IllegalArgument
+	// ErrNotCron indicates a call to a method only the cron actor may call.
+	ErrNotCron = 47
 )
 
 // CancelDelayBlockTime is the number of rounds given to the target to respond after the channel
@@ -63,10 +72,28 @@ var Errors = map[uint8]error{
 	ErrExpired:                  errors.NewCodedRevertError(ErrExpired, "block height has exceeded channel's end of life"),
 	ErrAlreadyWithdrawn:         errors.NewCodedRevertError(ErrAlreadyWithdrawn, "update amount has already been redeemed"),
 	ErrInvalidSignature:         errors.NewCodedRevertErrorf(ErrInvalidSignature, "signature failed to validate"),
+	ErrStaleVoucher:             errors.NewCodedRevertError(ErrStaleVoucher, "voucher nonce is not higher than the last redeemed in its lane"),
+	ErrInvalidVoucher:           errors.NewCodedRevertError(ErrInvalidVoucher, "voucher is not a valid lane voucher"),
+	ErrNotCron:                  errors.NewCodedRevertError(ErrNotCron, "only the cron actor may call the method"),
 }
 
 func init() {
 	cbor.RegisterCborType(PaymentChannel{})
+	cbor.RegisterCborType(LaneState{})
+	cbor.RegisterCborType(ExpiringChannel{})
+}
+
+// expiriesKey is the key of the lookup of expiring channels among the payers
+// of the actor's storage.  It can't be the string of an address.
+const expiriesKey = "expiries"
+
+// ExpiringChannel designates a payment channel whose Eol is the height it is
+// listed under in the lookup of expiring channels.  Channels are listed again
+// when their Eol changes, so a listed channel may since have been extended,
+// cancelled or closed.
+type ExpiringChannel struct {
+	Payer   address.Address
+	Channel *types.ChannelID
 }
 
 // PaymentChannel records the intent to pay funds to a target account.
@@ -96,6 +123,22 @@ type PaymentChannel struct {
 	// payment channel yet. This is necessary because AmountRedeemed can still be
 	// zero in the event of a zero-value voucher
 	Redeemed bool `json:"redeemed"`
+
+	// Lanes maps the stringified ids of the lanes in which vouchers have been
+	// redeemed to their state.  AmountRedeemed includes the amounts redeemed
+	// in all lanes, so a channel paying through lanes should not also be
+	// redeemed with single lane vouchers.
+	Lanes map[string]*LaneState `json:"lanes,omitempty"`
+}
+
+// LaneState records the vouchers redeemed in a lane of a payment channel.
+type LaneState struct {
+	// Redeemed is the amount of the last voucher redeemed in the lane, or
+	// zero once a voucher of another lane merged it.
+	Redeemed *types.AttoFIL `json:"redeemed"`
+
+	// Nonce is the nonce of the last voucher redeemed in the lane.
+	Nonce uint64 `json:"nonce"`
 }
 
 // Actor provides a mechanism for off chain payments.
@@ -126,10 +169,18 @@ var paymentBrokerExports = exec.Exports{
 		Params: []abi.Type{abi.Address, abi.ChannelID, abi.AttoFIL, abi.BlockHeight, abi.Predicate, abi.Bytes, abi.Parameters},
 		Return: nil,
 	},
+	"closeVoucher": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Bytes, abi.Parameters},
+		Return: nil,
+	},
 	"createChannel": &exec.FunctionSignature{
 		Params: []abi.Type{abi.Address, abi.BlockHeight},
 		Return: []abi.Type{abi.ChannelID},
 	},
+	"expireChannels": &exec.FunctionSignature{
+		Params: []abi.Type{abi.BlockHeight},
+		Return: nil,
+	},
 	"extend": &exec.FunctionSignature{
 		Params: []abi.Type{abi.ChannelID, abi.BlockHeight},
 		Return: nil,
@@ -146,6 +197,10 @@ var paymentBrokerExports = exec.Exports{
 		Params: []abi.Type{abi.Address, abi.ChannelID, abi.AttoFIL, abi.BlockHeight, abi.Predicate, abi.Bytes, abi.Parameters},
 		Return: nil,
 	},
+	"redeemVoucher": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Bytes, abi.Parameters},
+		Return: nil,
+	},
 	"voucher": &exec.FunctionSignature{
 		Params: []abi.Type{abi.ChannelID, abi.AttoFIL, abi.BlockHeight, abi.Predicate},
 		Return: []abi.Type{abi.Bytes},
@@ -195,6 +250,10 @@ func (pb *Actor) CreateChannel(vmctx exec.VMContext, target address.Address, eol
 		return nil
 	})
 
+	if err == nil {
+		err = scheduleExpiry(ctx, storage, payerAddress, channelID, eol)
+	}
+
 	if err != nil {
 		// ensure error is properly wrapped
 		if !errors.IsFault(err) && !errors.ShouldRevert(err) {
@@ -338,6 +397,90 @@ func (pb *Actor) Close(vmctx exec.VMContext, payer address.Address, chid *types.
 	return 0, nil
 }
 
+// RedeemVoucher is called by the target of a channel to withdraw funds with a
+// signed, cbor encoded lane voucher.  The amount of a lane voucher is the total
+// authorized in its lane and in the lanes it merges, so that only the
+// difference with the amounts already redeemed in these lanes is transferred.
+// For instance, after redeeming a voucher of 100 in lane 0 and one of 50 in
+// lane 1, a voucher of 300 in lane 0 merging lane 1 transfers 150 more.
+//
+// Conditions of lane vouchers are checked as they are by Redeem.
+func (pb *Actor) RedeemVoucher(vmctx exec.VMContext, voucherBytes []byte, redeemerConditionParams []interface{}) (uint8, error) {
+	return redeemLaneVoucher(vmctx, voucherBytes, redeemerConditionParams, false)
+}
+
+// CloseVoucher first executes the logic performed in the RedeemVoucher method,
+// then returns all funds remaining in the channel to the payer account and
+// deletes the channel.
+func (pb *Actor) CloseVoucher(vmctx exec.VMContext, voucherBytes []byte, redeemerConditionParams []interface{}) (uint8, error) {
+	return redeemLaneVoucher(vmctx, voucherBytes, redeemerConditionParams, true)
+}
+
+func redeemLaneVoucher(vmctx exec.VMContext, voucherBytes []byte, redeemerConditionParams []interface{}, closeChannel bool) (uint8, error) {
+	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var voucher types.PaymentVoucher
+	if err := cbor.DecodeInto(voucherBytes, &voucher); err != nil || voucher.Nonce == 0 {
+		return errors.CodeError(Errors[ErrInvalidVoucher]), Errors[ErrInvalidVoucher]
+	}
+
+	if !VerifyLaneVoucherSignature(&voucher) {
+		return errors.CodeError(Errors[ErrInvalidSignature]), Errors[ErrInvalidSignature]
+	}
+
+	ctx := context.Background()
+	storage := vmctx.Storage()
+	chid := &voucher.Channel
+
+	err := withPayerChannels(ctx, storage, voucher.Payer, func(byChannelID exec.Lookup) error {
+		chInt, err := byChannelID.Find(ctx, chid.KeyString())
+		if err != nil {
+			if err == hamt.ErrNotFound {
+				return Errors[ErrUnknownChannel]
+			}
+			return errors.FaultErrorWrapf(err, "Could not retrieve payment channel with ID: %s", chid)
+		}
+
+		channel, ok := chInt.(*PaymentChannel)
+		if !ok {
+			return errors.NewFaultError("Expected PaymentChannel from channels lookup")
+		}
+
+		// validate the amount can be sent to the target and send payment to that address.
+		err = validateAndUpdateLane(vmctx, vmctx.Message().From, channel, &voucher, redeemerConditionParams)
+		if err != nil {
+			return err
+		}
+
+		// Reset the EOL to the originally agreed upon EOL in the event that the
+		// channel has been cancelled.
+		channel.Eol = channel.AgreedEol
+		channel.Redeemed = true
+
+		if err := byChannelID.Set(ctx, chid.KeyString(), channel); err != nil {
+			return err
+		}
+
+		if closeChannel {
+			// return funds to payer
+			return reclaim(ctx, vmctx, byChannelID, voucher.Payer, chid, channel)
+		}
+		return nil
+	})
+
+	if err != nil {
+		// ensure error is properly wrapped
+		if !errors.IsFault(err) && !errors.ShouldRevert(err) {
+			return 1, errors.FaultErrorWrap(err, "Error redeeming lane voucher")
+		}
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
 // Extend can be used by the owner of a channel to add more funds to it and
 // extend the Channel's lifespan.
 func (pb *Actor) Extend(vmctx exec.VMContext, chid *types.ChannelID, eol *types.BlockHeight) (uint8, error) {
@@ -378,6 +521,10 @@ func (pb *Actor) Extend(vmctx exec.VMContext, chid *types.ChannelID, eol *types.
 		return byChannelID.Set(ctx, chid.KeyString(), channel)
 	})
 
+	if err == nil {
+		err = scheduleExpiry(ctx, storage, payerAddress, chid, eol)
+	}
+
 	if err != nil {
 		// ensure error is properly wrapped
 		if !errors.IsFault(err) && !errors.ShouldRevert(err) {
@@ -405,6 +552,7 @@ func (pb *Actor) Cancel(vmctx exec.VMContext, chid *types.ChannelID) (uint8, err
 	storage := vmctx.Storage()
 	payerAddress := vmctx.Message().From
 
+	var cancelledEol *types.BlockHeight
 	err := withPayerChannels(ctx, storage, payerAddress, func(byChannelID exec.Lookup) error {
 		chInt, err := byChannelID.Find(ctx, chid.KeyString())
 		if err != nil {
@@ -443,11 +591,16 @@ func (pb *Actor) Cancel(vmctx exec.VMContext, chid *types.ChannelID) (uint8, err
 		// eol can only be decreased
 		if channel.Eol.GreaterThan(eol) {
 			channel.Eol = eol
+			cancelledEol = eol
 		}
 
 		return byChannelID.Set(ctx, chid.KeyString(), channel)
 	})
 
+	if err == nil && cancelledEol != nil {
+		err = scheduleExpiry(ctx, storage, payerAddress, chid, cancelledEol)
+	}
+
 	if err != nil {
 		// ensure error is properly wrapped
 		if !errors.IsFault(err) && !errors.ShouldRevert(err) {
@@ -504,6 +657,112 @@ func (pb *Actor) Reclaim(vmctx exec.VMContext, chid *types.ChannelID) (uint8, er
 	return 0, nil
 }
 
+// ExpireChannels reclaims for their payer the channels whose Eol is after
+// since and at most the current height, so that the unspent funds of expired
+// deals return to their clients without them sending a reclaim message.  It
+// is called by the cron actor on every tipset with the height of the previous
+// one, and only visits the channels listed as expiring at these heights, each
+// for gas.  Channels created before the listing was introduced aren't listed
+// and are only reclaimed by their payer.
+func (pb *Actor) ExpireChannels(vmctx exec.VMContext, since *types.BlockHeight) (uint8, error) {
+	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	if vmctx.Message().From != address.CronAddress {
+		return ErrNotCron, Errors[ErrNotCron]
+	}
+
+	ctx := context.Background()
+	storage := vmctx.Storage()
+
+	var heights []string
+	var expiring []ExpiringChannel
+	err := withExpiriesForReading(ctx, storage, func(expiries exec.Lookup) error {
+		for h := since.Add(types.NewBlockHeight(1)); h.LessEqual(vmctx.BlockHeight()); h = h.Add(types.NewBlockHeight(1)) {
+			if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+				return errors.RevertErrorWrap(err, "Insufficient gas")
+			}
+			val, err := expiries.Find(ctx, h.String())
+			if err == hamt.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return errors.FaultErrorWrapf(err, "could not get channels expiring at %s", h)
+			}
+			channels, ok := val.([]ExpiringChannel)
+			if !ok {
+				return errors.NewFaultError("Expected list of channels from expiries lookup")
+			}
+			heights = append(heights, h.String())
+			expiring = append(expiring, channels...)
+		}
+		return nil
+	})
+	if err == nil && len(heights) > 0 {
+		err = expireChannels(ctx, vmctx, storage, heights, expiring)
+	}
+	if err != nil {
+		// ensure error is properly wrapped
+		if !errors.IsFault(err) && !errors.ShouldRevert(err) {
+			return 1, errors.FaultErrorWrap(err, "Error expiring channels")
+		}
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
+// expireChannels reclaims the expiring channels that reached their Eol, and
+// removes the heights they are listed under from the expiring channels.
+func expireChannels(ctx context.Context, vmctx exec.VMContext, storage exec.Storage, heights []string, expiring []ExpiringChannel) error {
+	for _, exp := range expiring {
+		if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+			return errors.RevertErrorWrap(err, "Insufficient gas")
+		}
+
+		err := withPayerChannels(ctx, storage, exp.Payer, func(byChannelID exec.Lookup) error {
+			chInt, err := byChannelID.Find(ctx, exp.Channel.KeyString())
+			if err == hamt.ErrNotFound {
+				// closed or reclaimed since
+				return nil
+			}
+			if err != nil {
+				return errors.FaultErrorWrapf(err, "Could not retrieve payment channel with ID: %s", exp.Channel)
+			}
+			channel, ok := chInt.(*PaymentChannel)
+			if !ok {
+				return errors.NewFaultError("Expected PaymentChannel from channels lookup")
+			}
+			if vmctx.BlockHeight().LessThan(channel.Eol) {
+				// extended since
+				return nil
+			}
+
+			// spent channels are only removed, reclaim would keep them
+			if channel.Amount.Sub(channel.AmountRedeemed).LessEqual(types.ZeroAttoFIL) {
+				if err := byChannelID.Delete(ctx, exp.Channel.KeyString()); err != nil {
+					return errors.FaultErrorWrapf(err, "could not delete payment channel %s", exp.Channel)
+				}
+				return nil
+			}
+			return reclaim(ctx, vmctx, byChannelID, exp.Payer, exp.Channel, channel)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return withExpiries(ctx, storage, func(expiries exec.Lookup) error {
+		for _, h := range heights {
+			if err := expiries.Delete(ctx, h); err != nil {
+				return errors.FaultErrorWrapf(err, "could not delete channels expiring at %s", h)
+			}
+		}
+		return nil
+	})
+}
+
 // Voucher takes a channel id and amount creates a new unsigned PaymentVoucher
 // against the given channel.  It also takes a block height parameter "validAt"
 // enforcing that the voucher is not reclaimed until the given block height
@@ -656,6 +915,85 @@ func validateAndUpdateChannel(ctx exec.VMContext, target address.Address, channe
 	return nil
 }
 
+// validateAndUpdateLane transfers to the target the part of the voucher's
+// amount not yet redeemed in its lane and the lanes it merges, and records the
+// voucher in these lanes.
+func validateAndUpdateLane(ctx exec.VMContext, target address.Address, channel *PaymentChannel, voucher *types.PaymentVoucher, redeemerSuppliedParams []interface{}) error {
+	cacheCondition(channel, voucher.Condition, redeemerSuppliedParams)
+
+	if err := checkCondition(ctx, channel); err != nil {
+		return err
+	}
+
+	if target != channel.Target {
+		return Errors[ErrWrongTarget]
+	}
+
+	if ctx.BlockHeight().LessThan(&voucher.ValidAt) {
+		return Errors[ErrTooEarly]
+	}
+
+	if ctx.BlockHeight().GreaterEqual(channel.Eol) {
+		return Errors[ErrExpired]
+	}
+
+	if channel.Lanes == nil {
+		channel.Lanes = make(map[string]*LaneState)
+	}
+	lane, ok := channel.Lanes[laneKey(voucher.Lane)]
+	if !ok {
+		lane = &LaneState{Redeemed: types.NewZeroAttoFIL()}
+	}
+	if voucher.Nonce <= lane.Nonce {
+		return Errors[ErrStaleVoucher]
+	}
+
+	// the amounts redeemed in merged lanes are part of the voucher's amount,
+	// and from now on of the voucher's lane, so they aren't counted again
+	// by later vouchers merging these lanes
+	redeemed := lane.Redeemed
+	for _, merge := range voucher.Merges {
+		merged, ok := channel.Lanes[laneKey(merge.Lane)]
+		if merge.Lane == voucher.Lane || !ok {
+			return Errors[ErrInvalidVoucher]
+		}
+		if merge.Nonce <= merged.Nonce {
+			return Errors[ErrStaleVoucher]
+		}
+		redeemed = redeemed.Add(merged.Redeemed)
+		merged.Redeemed = types.NewZeroAttoFIL()
+		merged.Nonce = merge.Nonce
+	}
+
+	updateAmount := voucher.Amount.Sub(redeemed)
+	if updateAmount.IsNegative() {
+		return Errors[ErrAlreadyWithdrawn]
+	}
+
+	if channel.AmountRedeemed.Add(updateAmount).GreaterThan(channel.Amount) {
+		return Errors[ErrInsufficientChannelFunds]
+	}
+
+	// transfer funds to sender
+	if updateAmount.IsPositive() {
+		if _, _, err := ctx.Send(ctx.Message().From, "", updateAmount, nil); err != nil {
+			return err
+		}
+	}
+
+	amount := voucher.Amount
+	lane.Redeemed = &amount
+	lane.Nonce = voucher.Nonce
+	channel.Lanes[laneKey(voucher.Lane)] = lane
+	channel.AmountRedeemed = channel.AmountRedeemed.Add(updateAmount)
+
+	return nil
+}
+
+func laneKey(lane uint64) string {
+	return strconv.FormatUint(lane, 10)
+}
+
 func reclaim(ctx context.Context, vmctx exec.VMContext, byChannelID exec.Lookup, payer address.Address, chid *types.ChannelID, channel *PaymentChannel) error {
 	amt := channel.Amount.Sub(channel.AmountRedeemed)
 	if amt.LessEqual(types.ZeroAttoFIL) {
@@ -702,6 +1040,44 @@ func VerifyVoucherSignature(payer address.Address, chid *types.ChannelID, amt *t
 	return types.IsValidSignature(data, payer, sig)
 }
 
+// SignLaneVoucher creates the signature of a lane voucher.  It signs the data
+// of a single lane voucher followed by the voucher's lane, nonce and merges,
+// so that lane voucher signatures are never valid for single lane vouchers.
+func SignLaneVoucher(voucher *types.PaymentVoucher, signer types.Signer) (types.Signature, error) {
+	data, err := createLaneVoucherSignatureData(voucher)
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignBytes(data, voucher.Payer)
+}
+
+// VerifyLaneVoucherSignature returns whether the lane voucher's signature is
+// valid.
+func VerifyLaneVoucherSignature(voucher *types.PaymentVoucher) bool {
+	data, err := createLaneVoucherSignatureData(voucher)
+	// the only error is failure to encode the values
+	if err != nil {
+		return false
+	}
+	return types.IsValidSignature(data, voucher.Payer, voucher.Signature)
+}
+
+func createLaneVoucherSignatureData(voucher *types.PaymentVoucher) ([]byte, error) {
+	data, err := createVoucherSignatureData(&voucher.Channel, &voucher.Amount, &voucher.ValidAt, voucher.Condition)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, separator)
+	data = append(data, leb128.FromUInt64(voucher.Lane)...)
+	data = append(data, leb128.FromUInt64(voucher.Nonce)...)
+	for _, merge := range voucher.Merges {
+		data = append(data, separator)
+		data = append(data, leb128.FromUInt64(merge.Lane)...)
+		data = append(data, leb128.FromUInt64(merge.Nonce)...)
+	}
+	return data, nil
+}
+
 func createVoucherSignatureData(channelID *types.ChannelID, amount *types.AttoFIL, validAt *types.BlockHeight, condition *types.Predicate) ([]byte, error) {
 	data := append(channelID.Bytes(), separator)
 	data = append(data, amount.Bytes()...)
@@ -764,6 +1140,85 @@ func withPayerChannelsForReading(ctx context.Context, storage exec.Storage, paye
 	})
 }
 
+// scheduleExpiry lists the channel chid of payer as expiring at eol.
+func scheduleExpiry(ctx context.Context, storage exec.Storage, payer address.Address, chid *types.ChannelID, eol *types.BlockHeight) error {
+	return withExpiries(ctx, storage, func(expiries exec.Lookup) error {
+		var channels []ExpiringChannel
+		val, err := expiries.Find(ctx, eol.String())
+		if err != nil && err != hamt.ErrNotFound {
+			return errors.FaultErrorWrapf(err, "could not get channels expiring at %s", eol)
+		}
+		if err == nil {
+			var ok bool
+			if channels, ok = val.([]ExpiringChannel); !ok {
+				return errors.NewFaultError("Expected list of channels from expiries lookup")
+			}
+		}
+		channels = append(channels, ExpiringChannel{Payer: payer, Channel: chid})
+		return expiries.Set(ctx, eol.String(), channels)
+	})
+}
+
+// withExpiries calls f with the lookup of the expiring channels by height,
+// and saves its changes.
+func withExpiries(ctx context.Context, storage exec.Storage, f func(exec.Lookup) error) error {
+	stateCid, err := actor.WithLookup(ctx, storage, storage.Head(), func(byPayer exec.Lookup) error {
+		expiries, found, err := findExpiriesLookup(ctx, storage, byPayer)
+		if err != nil {
+			return err
+		}
+
+		if err := f(expiries); err != nil {
+			return err
+		}
+
+		if expiries.IsEmpty() {
+			if !found {
+				return nil
+			}
+			return byPayer.Delete(ctx, expiriesKey)
+		}
+		committedCID, err := expiries.Commit(ctx)
+		if err != nil {
+			return err
+		}
+		return byPayer.Set(ctx, expiriesKey, committedCID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return storage.Commit(stateCid, storage.Head())
+}
+
+func withExpiriesForReading(ctx context.Context, storage exec.Storage, f func(exec.Lookup) error) error {
+	return actor.WithLookupForReading(ctx, storage, storage.Head(), func(byPayer exec.Lookup) error {
+		expiries, _, err := findExpiriesLookup(ctx, storage, byPayer)
+		if err != nil {
+			return err
+		}
+		return f(expiries)
+	})
+}
+
+func findExpiriesLookup(ctx context.Context, storage exec.Storage, byPayer exec.Lookup) (exec.Lookup, bool, error) {
+	val, err := byPayer.Find(ctx, expiriesKey)
+	if err == hamt.ErrNotFound {
+		expiries, err := actor.LoadTypedLookup(ctx, storage, cid.Undef, []ExpiringChannel{})
+		return expiries, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	expiriesCID, ok := val.(cid.Cid)
+	if !ok {
+		return nil, false, errors.NewFaultError("Paymentbroker expiries is not a Cid")
+	}
+
+	expiries, err := actor.LoadTypedLookup(ctx, storage, expiriesCID, []ExpiringChannel{})
+	return expiries, true, err
+}
+
 func findByChannelLookup(ctx context.Context, storage exec.Storage, byPayer exec.Lookup, payer address.Address) (exec.Lookup, error) {
 	byChannelID, err := byPayer.Find(ctx, payer.String())
 	if err != nil {
@@ -739,6 +739,72 @@ func TestPaymentBrokerReclaimFailsBeforeChannelEol(t *testing.T) {
 	assert.Contains(t, result.ExecutionError.Error(), "eol")
 }
 
+func TestPaymentBrokerExpireChannels(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("only the cron actor may expire channels", func(t *testing.T) {
+		sys := setup(t)
+
+		msg := types.NewMessage(sys.payer, address.PaymentBrokerAddress, 1, types.ZeroAttoFIL, "expireChannels", core.MustConvertParams(types.NewBlockHeight(0)))
+		result, err := sys.ApplyMessage(msg, 20000)
+		require.NoError(t, err)
+		assert.Equal(t, uint8(ErrNotCron), result.Receipt.ExitCode)
+	})
+
+	t.Run("reclaims channels at their eol on every tipset", func(t *testing.T) {
+		sys := setup(t)
+		spentID := establishChannel(sys.ctx, sys.st, sys.vms, sys.payer, sys.target, 1, types.NewAttoFILFromFIL(100), types.NewBlockHeight(10))
+		spent := requireGetPaymentChannel(t, sys.ctx, sys.st, sys.vms, sys.payer, spentID)
+
+		sig, err := SignVoucher(spentID, spent.Amount, sys.defaultValidAt, sys.payer, nil, mockSigner)
+		require.NoError(t, err)
+		pdata := core.MustConvertParams(sys.payer, spentID, spent.Amount, sys.defaultValidAt, (*types.Predicate)(nil), []byte(sig), []interface{}(nil))
+		msg := types.NewMessage(sys.target, address.PaymentBrokerAddress, 0, types.ZeroAttoFIL, "redeem", pdata)
+		result, err := sys.ApplyMessage(msg, 1)
+		require.NoError(t, err)
+		require.NoError(t, result.ExecutionError)
+
+		payerBalance := state.MustGetActor(sys.st, sys.payer).Balance
+		processor := consensus.NewDefaultProcessor()
+		minerOwner := sys.addressGetter()
+
+		// the redeemed channel is removed at its eol
+		_, err = processor.ApplyMessagesAndPayRewards(sys.ctx, sys.st, sys.vms, nil, minerOwner, types.NewBlockHeight(10), nil)
+		require.NoError(t, err)
+		channels := sys.requireChannels()
+		assert.Len(t, channels, 1)
+		assert.Contains(t, channels, sys.channelID.KeyString())
+		assert.Equal(t, payerBalance, state.MustGetActor(sys.st, sys.payer).Balance)
+
+		// the other returns its funds to the payer at its eol
+		_, err = processor.ApplyMessagesAndPayRewards(sys.ctx, sys.st, sys.vms, nil, minerOwner, types.NewBlockHeight(20000), nil)
+		require.NoError(t, err)
+		assert.Empty(t, sys.requireChannels())
+		assert.Equal(t, payerBalance.Add(types.NewAttoFILFromFIL(1000)), state.MustGetActor(sys.st, sys.payer).Balance)
+		assert.Equal(t, types.ZeroAttoFIL, state.MustGetActor(sys.st, address.PaymentBrokerAddress).Balance)
+	})
+
+	t.Run("leaves channels extended since they were listed", func(t *testing.T) {
+		sys := setup(t)
+
+		pdata := core.MustConvertParams(sys.channelID, types.NewBlockHeight(30000))
+		msg := types.NewMessage(sys.payer, address.PaymentBrokerAddress, 1, types.ZeroAttoFIL, "extend", pdata)
+		result, err := sys.ApplyMessage(msg, 1)
+		require.NoError(t, err)
+		require.NoError(t, result.ExecutionError)
+
+		processor := consensus.NewDefaultProcessor()
+		minerOwner := sys.addressGetter()
+		_, err = processor.ApplyMessagesAndPayRewards(sys.ctx, sys.st, sys.vms, nil, minerOwner, types.NewBlockHeight(20000), nil)
+		require.NoError(t, err)
+		assert.Len(t, sys.requireChannels(), 1)
+
+		_, err = processor.ApplyMessagesAndPayRewards(sys.ctx, sys.st, sys.vms, nil, minerOwner, types.NewBlockHeight(30000), nil)
+		require.NoError(t, err)
+		assert.Empty(t, sys.requireChannels())
+	})
+}
+
 func TestPaymentBrokerExtend(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -1103,6 +1169,130 @@ func TestSignVoucher(t *testing.T) {
 	})
 }
 
+func TestPaymentBrokerRedeemLaneVouchers(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("redeems the increase of each lane", func(t *testing.T) {
+		sys := setup(t)
+
+		result, err := sys.applyLaneVoucherMessage(sys.laneVoucher(0, 1, 100), "redeemVoucher", 0)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		result, err = sys.applyLaneVoucherMessage(sys.laneVoucher(1, 1, 200), "redeemVoucher", 1)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		result, err = sys.applyLaneVoucherMessage(sys.laneVoucher(0, 2, 150), "redeemVoucher", 2)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		assert.Equal(t, types.NewAttoFILFromFIL(350), state.MustGetActor(sys.st, sys.target).Balance)
+
+		channel := requireGetPaymentChannel(t, sys.ctx, sys.st, sys.vms, sys.payer, sys.channelID)
+		assert.Equal(t, types.NewAttoFILFromFIL(350), channel.AmountRedeemed)
+		assert.Equal(t, uint64(2), channel.Lanes["0"].Nonce)
+		assert.Equal(t, types.NewAttoFILFromFIL(200), channel.Lanes["1"].Redeemed)
+	})
+
+	t.Run("merged lanes are part of the voucher's amount", func(t *testing.T) {
+		sys := setup(t)
+
+		result, err := sys.applyLaneVoucherMessage(sys.laneVoucher(0, 1, 100), "redeemVoucher", 0)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		result, err = sys.applyLaneVoucherMessage(sys.laneVoucher(1, 1, 200), "redeemVoucher", 1)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		merged := sys.laneVoucher(1, 2, 400, types.LaneMerge{Lane: 0, Nonce: 2})
+		result, err = sys.applyLaneVoucherMessage(merged, "redeemVoucher", 2)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		assert.Equal(t, types.NewAttoFILFromFIL(400), state.MustGetActor(sys.st, sys.target).Balance)
+
+		channel := requireGetPaymentChannel(t, sys.ctx, sys.st, sys.vms, sys.payer, sys.channelID)
+		assert.Equal(t, types.NewAttoFILFromFIL(400), channel.AmountRedeemed)
+		assert.Equal(t, uint64(2), channel.Lanes["0"].Nonce)
+	})
+
+	t.Run("counts merged lanes once", func(t *testing.T) {
+		sys := setup(t)
+
+		result, err := sys.applyLaneVoucherMessage(sys.laneVoucher(0, 1, 100), "redeemVoucher", 0)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		result, err = sys.applyLaneVoucherMessage(sys.laneVoucher(1, 1, 200), "redeemVoucher", 1)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		merged := sys.laneVoucher(1, 2, 400, types.LaneMerge{Lane: 0, Nonce: 2})
+		result, err = sys.applyLaneVoucherMessage(merged, "redeemVoucher", 2)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		// Lane 1's 400 already include the 100 of lane 0.
+		mergedAgain := sys.laneVoucher(2, 1, 600, types.LaneMerge{Lane: 0, Nonce: 3}, types.LaneMerge{Lane: 1, Nonce: 3})
+		result, err = sys.applyLaneVoucherMessage(mergedAgain, "redeemVoucher", 3)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		assert.Equal(t, types.NewAttoFILFromFIL(600), state.MustGetActor(sys.st, sys.target).Balance)
+
+		channel := requireGetPaymentChannel(t, sys.ctx, sys.st, sys.vms, sys.payer, sys.channelID)
+		assert.Equal(t, types.NewAttoFILFromFIL(600), channel.AmountRedeemed)
+		assert.Equal(t, types.NewZeroAttoFIL(), channel.Lanes["0"].Redeemed)
+		assert.Equal(t, types.NewZeroAttoFIL(), channel.Lanes["1"].Redeemed)
+	})
+
+	t.Run("rejects stale vouchers", func(t *testing.T) {
+		sys := setup(t)
+
+		result, err := sys.applyLaneVoucherMessage(sys.laneVoucher(0, 2, 100), "redeemVoucher", 0)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		result, err = sys.applyLaneVoucherMessage(sys.laneVoucher(0, 1, 200), "redeemVoucher", 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint8(ErrStaleVoucher), result.Receipt.ExitCode)
+	})
+
+	t.Run("rejects merges of unknown lanes", func(t *testing.T) {
+		sys := setup(t)
+
+		voucher := sys.laneVoucher(0, 1, 100, types.LaneMerge{Lane: 3, Nonce: 1})
+		result, err := sys.applyLaneVoucherMessage(voucher, "redeemVoucher", 0)
+		require.NoError(t, err)
+		assert.Equal(t, uint8(ErrInvalidVoucher), result.Receipt.ExitCode)
+	})
+
+	t.Run("rejects vouchers with a modified lane", func(t *testing.T) {
+		sys := setup(t)
+
+		voucher := sys.laneVoucher(0, 1, 100)
+		voucher.Lane = 1
+		result, err := sys.applyLaneVoucherMessage(voucher, "redeemVoucher", 0)
+		require.NoError(t, err)
+		assert.Equal(t, uint8(ErrInvalidSignature), result.Receipt.ExitCode)
+	})
+
+	t.Run("closes the channel", func(t *testing.T) {
+		sys := setup(t)
+
+		payerBalance := state.MustGetActor(sys.st, sys.payer).Balance
+
+		result, err := sys.applyLaneVoucherMessage(sys.laneVoucher(0, 1, 100), "closeVoucher", 0)
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), result.Receipt.ExitCode)
+
+		assert.Equal(t, types.NewAttoFILFromFIL(100), state.MustGetActor(sys.st, sys.target).Balance)
+		assert.Equal(t, payerBalance.Add(types.NewAttoFILFromFIL(900)), state.MustGetActor(sys.st, sys.payer).Balance)
+	})
+}
+
 func establishChannel(ctx context.Context, st state.Tree, vms vm.StorageMap, from address.Address, target address.Address, nonce uint64, amt *types.AttoFIL, eol *types.BlockHeight) *types.ChannelID {
 	pdata := core.MustConvertParams(target, eol)
 	msg := types.NewMessage(from, address.PaymentBrokerAddress, nonce, amt, "createChannel", pdata)
@@ -1263,10 +1453,56 @@ func (sys *system) applySignatureMessage(target address.Address, amtInt uint64,
 	return sys.ApplyMessage(msg, height)
 }
 
+// laneVoucher returns a voucher signed by the payer paying amtInt FIL in the
+// lane of the channel.
+func (sys *system) laneVoucher(lane, nonce, amtInt uint64, merges ...types.LaneMerge) *types.PaymentVoucher {
+	sys.t.Helper()
+
+	voucher := &types.PaymentVoucher{
+		Channel: *sys.channelID,
+		Payer:   sys.payer,
+		Target:  sys.target,
+		Amount:  *types.NewAttoFILFromFIL(amtInt),
+		ValidAt: *sys.defaultValidAt,
+		Lane:    lane,
+		Nonce:   nonce,
+		Merges:  merges,
+	}
+	sig, err := SignLaneVoucher(voucher, mockSigner)
+	require.NoError(sys.t, err)
+	voucher.Signature = sig
+
+	return voucher
+}
+
+func (sys *system) applyLaneVoucherMessage(voucher *types.PaymentVoucher, method string, nonce uint64) (*consensus.ApplicationResult, error) {
+	sys.t.Helper()
+
+	voucherBytes, err := cbor.DumpObject(voucher)
+	require.NoError(sys.t, err)
+
+	pdata := core.MustConvertParams(voucherBytes, []interface{}{})
+	msg := types.NewMessage(sys.target, address.PaymentBrokerAddress, nonce, types.NewAttoFILFromFIL(0), method, pdata)
+
+	return sys.ApplyMessage(msg, 0)
+}
+
 func (sys *system) ApplyMessage(msg *types.Message, height uint64) (*consensus.ApplicationResult, error) {
 	return th.ApplyTestMessage(sys.st, sys.vms, msg, types.NewBlockHeight(height))
 }
 
+func (sys *system) requireChannels() map[string]*PaymentChannel {
+	sys.t.Helper()
+
+	returnValue, exitCode, err := sys.CallQueryMethod("ls", 0, sys.payer)
+	require.NoError(sys.t, err)
+	require.Equal(sys.t, uint8(0), exitCode)
+
+	channels := make(map[string]*PaymentChannel)
+	require.NoError(sys.t, cbor.DecodeInto(returnValue[0], &channels))
+	return channels
+}
+
 func requireGetPaymentChannel(t *testing.T, ctx context.Context, st state.Tree, vms vm.StorageMap, payer address.Address, channelId *types.ChannelID) *PaymentChannel {
 	var paymentMap map[string]*PaymentChannel
 
@@ -0,0 +1,164 @@
+// Package reward implements the actor holding the funds paid out as block
+// rewards and the parameters of the distribution of gas fees.
+package reward
+
+import (
+	"math/big"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	xerrors "github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+func init() {
+	cbor.RegisterCborType(State{})
+}
+
+// DefaultBlockReward is the block reward of networks created without another.
+var DefaultBlockReward = types.NewAttoFILFromFIL(1000)
+
+// DefaultGasBurnPercent is the percentage of gas fees burnt in networks
+// created without another.
+const DefaultGasBurnPercent = 0
+
+// Actor holds the funds minted at genesis to reward the miners of blocks.  It
+// is installed at address.NetworkAddress.  Block processing pays the reward
+// of each block and distributes the gas fees of its messages according to
+// the actor's state, which is fixed at genesis.
+type Actor struct{}
+
+// State is the reward actor's storage.
+type State struct {
+	// BlockReward is paid to the owner of the miner of each block as long as
+	// the actor's balance allows.
+	BlockReward *types.AttoFIL
+	// GasBurnPercent is the percentage of the gas fee of each message that is
+	// burnt, rounded up.  The rest is paid to the owner of the miner of the
+	// message's block as a tip.
+	GasBurnPercent uint64
+}
+
+// NewActor returns a new reward actor holding balance.
+func NewActor(balance *types.AttoFIL) *actor.Actor {
+	return actor.NewActor(types.RewardActorCodeCid, balance)
+}
+
+// NewState creates a reward actor state struct.
+func NewState(blockReward *types.AttoFIL, gasBurnPercent uint64) *State {
+	return &State{
+		BlockReward:    blockReward,
+		GasBurnPercent: gasBurnPercent,
+	}
+}
+
+// DefaultState returns the reward actor state of networks created without
+// other parameters.
+func DefaultState() *State {
+	return NewState(DefaultBlockReward, DefaultGasBurnPercent)
+}
+
+// EmptyState returns an empty reward actor state for decoding its storage.
+func (ra *Actor) EmptyState() interface{} {
+	return &State{}
+}
+
+// InitializeState stores the actor's initial data structure.
+func (ra *Actor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	rewardState, ok := initializerData.(*State)
+	if !ok {
+		return errors.NewFaultError("Initial state to reward actor is not a reward.State struct")
+	}
+	if rewardState.GasBurnPercent > 100 {
+		return xerrors.Errorf("gas burn percent %d is above 100", rewardState.GasBurnPercent)
+	}
+
+	stateBytes, err := cbor.DumpObject(rewardState)
+	if err != nil {
+		return xerrors.Wrap(err, "failed to cbor marshal object")
+	}
+
+	id, err := storage.Put(stateBytes)
+	if err != nil {
+		return err
+	}
+
+	return storage.Commit(id, cid.Undef)
+}
+
+var _ exec.ExecutableActor = (*Actor)(nil)
+
+var rewardExports = exec.Exports{
+	"getBlockReward": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.AttoFIL},
+	},
+	"getGasBurnPercent": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Integer},
+	},
+}
+
+// Exports returns the reward actor's exported functions.
+func (ra *Actor) Exports() exec.Exports {
+	return rewardExports
+}
+
+// GetBlockReward returns the reward paid for each block.
+func (ra *Actor) GetBlockReward(ctx exec.VMContext) (*types.AttoFIL, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		return state.BlockReward, nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	reward, ok := out.(*types.AttoFIL)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected *types.AttoFIL to be returned, but got %T instead", out)
+	}
+
+	return reward, 0, nil
+}
+
+// GetGasBurnPercent returns the percentage of gas fees that is burnt.
+func (ra *Actor) GetGasBurnPercent(ctx exec.VMContext) (*big.Int, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		return big.NewInt(0).SetUint64(state.GasBurnPercent), nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	percent, ok := out.(*big.Int)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected *big.Int to be returned, but got %T instead", out)
+	}
+
+	return percent, 0, nil
+}
+
+// SplitGas splits the gas fee of a message into the tip paid to the owner of
+// the miner of its block and the part that is burnt.
+func (st *State) SplitGas(gas *types.AttoFIL) (tip, burn *types.AttoFIL) {
+	if st.GasBurnPercent == 0 || gas.IsZero() {
+		return gas, types.NewZeroAttoFIL()
+	}
+	burn = gas.MulBigInt(big.NewInt(0).SetUint64(st.GasBurnPercent)).DivCeil(types.NewAttoFIL(big.NewInt(100)))
+	return gas.Sub(burn), burn
+}
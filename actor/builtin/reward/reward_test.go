@@ -0,0 +1,67 @@
+package reward_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	. "github.com/filecoin-project/go-filecoin/actor/builtin/reward"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+func TestRewardActorGenesis(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := vm.NewStorageMap(bs)
+	cst := hamt.NewCborStore()
+
+	blk, err := consensus.DefaultGenesis(cst, bs)
+	require.NoError(t, err)
+	st, err := state.LoadStateTree(ctx, cst, blk.StateRoot, builtin.Actors)
+	require.NoError(t, err)
+
+	networkActor := state.MustGetActor(st, address.NetworkAddress)
+	assert.Equal(t, types.RewardActorCodeCid, networkActor.Code)
+	assert.Equal(t, consensus.DefaultNetworkBalance, networkActor.Balance)
+
+	ret, code, err := consensus.CallQueryMethod(ctx, st, vms, address.NetworkAddress, "getBlockReward", nil, address.Undef, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), code)
+	assert.Equal(t, DefaultBlockReward, types.NewAttoFILFromBytes(ret[0]))
+
+	ret, code, err = consensus.CallQueryMethod(ctx, st, vms, address.NetworkAddress, "getGasBurnPercent", nil, address.Undef, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), code)
+	assert.Equal(t, uint64(DefaultGasBurnPercent), big.NewInt(0).SetBytes(ret[0]).Uint64())
+}
+
+func TestStateSplitGas(t *testing.T) {
+	tf.UnitTest(t)
+
+	tip, burn := NewState(DefaultBlockReward, 0).SplitGas(types.NewAttoFILFromFIL(10))
+	assert.Equal(t, types.NewAttoFILFromFIL(10), tip)
+	assert.Equal(t, types.NewZeroAttoFIL(), burn)
+
+	tip, burn = NewState(DefaultBlockReward, 25).SplitGas(types.NewAttoFILFromFIL(10))
+	assert.Equal(t, types.NewAttoFIL(big.NewInt(75e17)), tip)
+	assert.Equal(t, types.NewAttoFIL(big.NewInt(25e17)), burn)
+
+	// burns are rounded up
+	tip, burn = NewState(DefaultBlockReward, 50).SplitGas(types.NewAttoFIL(big.NewInt(3)))
+	assert.Equal(t, types.NewAttoFIL(big.NewInt(1)), tip)
+	assert.Equal(t, types.NewAttoFIL(big.NewInt(2)), burn)
+}
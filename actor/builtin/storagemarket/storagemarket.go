@@ -17,6 +17,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm/errors"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 )
 
 // MinimumPledge is the minimum amount of sectors a user can pledge.
@@ -29,11 +30,13 @@ const (
 	// ErrPledgeTooLow is the error code for a pledge under the MinimumPledge.
 	ErrPledgeTooLow = 33
 	// ErrUnknownMiner indicates a pledge under the MinimumPledge.
-	ErrUnknownMiner = 34
+	ErrUnknownMiner = exitcode.NotFound
 	// ErrInsufficientCollateral indicates the collateral is too low.
-	ErrInsufficientCollateral = 43
+	ErrInsufficientCollateral = exitcode.InsufficientFunds
 	// ErrUnsupportedSectorSize indicates that the sector size is incompatible with the proofs mode.
-	ErrUnsupportedSectorSize = 44
+	ErrUnsupportedSectorSize = exitcode.IllegalArgument
+	// ErrCallerUnauthorized indicates a caller not allowed to call the method.
+	ErrCallerUnauthorized = exitcode.Forbidden
 )
 
 // Errors map error codes to revert errors this actor may return.
@@ -42,6 +45,7 @@ var Errors = map[uint8]error{
 	ErrUnknownMiner:           errors.NewCodedRevertErrorf(ErrUnknownMiner, "unknown miner"),
 	ErrInsufficientCollateral: errors.NewCodedRevertErrorf(ErrInsufficientCollateral, "collateral must be more than %s FIL per sector", MinimumCollateralPerSector),
 	ErrUnsupportedSectorSize:  errors.NewCodedRevertErrorf(ErrUnsupportedSectorSize, "sector size is not supported"),
+	ErrCallerUnauthorized:     errors.NewCodedRevertErrorf(ErrCallerUnauthorized, "not authorized to call the method"),
 }
 
 func init() {
@@ -57,6 +61,10 @@ type Actor struct{}
 type State struct {
 	Miners cid.Cid `refmt:",omitempty"`
 
+	// ProvingDeadlines lists by height the miners whose proving period is
+	// to be checked at that height.
+	ProvingDeadlines cid.Cid `refmt:",omitempty"`
+
 	// TotalCommitedStorage is the number of sectors that are currently committed
 	// in the whole network.
 	TotalCommittedStorage *big.Int
@@ -69,6 +77,11 @@ func NewActor() (*actor.Actor, error) {
 	return actor.NewActor(types.StorageMarketActorCodeCid, types.NewZeroAttoFIL()), nil
 }
 
+// EmptyState returns an empty storage market state for decoding its storage.
+func (sma *Actor) EmptyState() interface{} {
+	return &State{}
+}
+
 // InitializeState stores the actor's initial data structure.
 func (sma *Actor) InitializeState(storage exec.Storage, proofsModeInterface interface{}) error {
 	proofsMode := proofsModeInterface.(types.ProofsMode)
@@ -106,6 +119,14 @@ var storageMarketExports = exec.Exports{
 		Params: []abi.Type{abi.Integer},
 		Return: nil,
 	},
+	"scheduleProvingCheck": &exec.FunctionSignature{
+		Params: []abi.Type{abi.BlockHeight},
+		Return: nil,
+	},
+	"checkProvingPeriods": &exec.FunctionSignature{
+		Params: []abi.Type{abi.BlockHeight},
+		Return: nil,
+	},
 	"getTotalStorage": &exec.FunctionSignature{
 		Params: []abi.Type{},
 		Return: []abi.Type{abi.Integer},
@@ -219,6 +240,153 @@ func (sma *Actor) UpdatePower(vmctx exec.VMContext, delta *big.Int) (uint8, erro
 	return 0, nil
 }
 
+// ScheduleProvingCheck lists the calling miner to have its proving period
+// checked at the given height, which is after the end of its grace period.
+// Miners schedule a check every time their proving period starts.
+func (sma *Actor) ScheduleProvingCheck(vmctx exec.VMContext, height *types.BlockHeight) (uint8, error) {
+	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	_, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		miner := vmctx.Message().From
+		ctx := context.Background()
+
+		miners, err := actor.LoadLookup(ctx, vmctx.Storage(), state.Miners)
+		if err != nil {
+			return nil, errors.FaultErrorWrapf(err, "could not load lookup for miner with CID: %s", state.Miners)
+		}
+
+		_, err = miners.Find(ctx, miner.String())
+		if err != nil {
+			if err == hamt.ErrNotFound {
+				return nil, Errors[ErrUnknownMiner]
+			}
+			return nil, errors.FaultErrorWrapf(err, "could not load lookup for miner with address: %s", miner)
+		}
+
+		deadlines, err := actor.LoadTypedLookup(ctx, vmctx.Storage(), state.ProvingDeadlines, []address.Address{})
+		if err != nil {
+			return nil, errors.FaultErrorWrapf(err, "could not load lookup for proving deadlines with CID: %s", state.ProvingDeadlines)
+		}
+
+		var scheduled []address.Address
+		val, err := deadlines.Find(ctx, height.String())
+		if err != nil && err != hamt.ErrNotFound {
+			return nil, errors.FaultErrorWrapf(err, "could not get miners to check at %s", height)
+		}
+		if err == nil {
+			var ok bool
+			if scheduled, ok = val.([]address.Address); !ok {
+				return nil, errors.NewFaultErrorf("expected []address.Address to be scheduled at %s, got %T", height, val)
+			}
+		}
+		if err := deadlines.Set(ctx, height.String(), append(scheduled, miner)); err != nil {
+			return nil, errors.FaultErrorWrapf(err, "could not schedule miner %s", miner)
+		}
+
+		state.ProvingDeadlines, err = deadlines.Commit(ctx)
+		if err != nil {
+			return nil, errors.FaultErrorWrap(err, "could not commit proving deadlines")
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
+// CheckProvingPeriods has the miners scheduled to be checked after since and
+// at most at the current height check their proving period, and removes the
+// power of the miners that missed it from the total.  It is called by the
+// cron actor on every tipset with the height of the previous one, and charges
+// gas for every height and every miner checked.  A miner failing the check is
+// skipped, and checked again once it schedules a new check.
+func (sma *Actor) CheckProvingPeriods(vmctx exec.VMContext, since *types.BlockHeight) (uint8, error) {
+	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	_, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		if vmctx.Message().From != address.CronAddress {
+			return nil, Errors[ErrCallerUnauthorized]
+		}
+
+		ctx := context.Background()
+		deadlines, err := actor.LoadTypedLookup(ctx, vmctx.Storage(), state.ProvingDeadlines, []address.Address{})
+		if err != nil {
+			return nil, errors.FaultErrorWrapf(err, "could not load lookup for proving deadlines with CID: %s", state.ProvingDeadlines)
+		}
+
+		checked := false
+		for h := since.Add(types.NewBlockHeight(1)); h.LessEqual(vmctx.BlockHeight()); h = h.Add(types.NewBlockHeight(1)) {
+			if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+				return nil, errors.RevertErrorWrap(err, "Insufficient gas")
+			}
+
+			val, err := deadlines.Find(ctx, h.String())
+			if err == hamt.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, errors.FaultErrorWrapf(err, "could not get miners to check at %s", h)
+			}
+			scheduled, ok := val.([]address.Address)
+			if !ok {
+				return nil, errors.NewFaultErrorf("expected []address.Address to be scheduled at %s, got %T", h, val)
+			}
+
+			for _, minerAddr := range scheduled {
+				if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+					return nil, errors.RevertErrorWrap(err, "Insufficient gas")
+				}
+
+				ret, _, err := vmctx.Send(minerAddr, "checkProvingPeriod", types.ZeroAttoFIL, nil)
+				if errors.IsFault(err) {
+					return nil, err
+				}
+				if err != nil {
+					continue
+				}
+
+				val, err := abi.Deserialize(ret[0], abi.Integer)
+				if err != nil {
+					return nil, errors.FaultErrorWrapf(err, "could not decode power lost by miner %s", minerAddr)
+				}
+				lost, ok := val.Val.(*big.Int)
+				if !ok {
+					return nil, errors.NewFaultErrorf("expected *big.Int from miner %s, got %T", minerAddr, val.Val)
+				}
+				state.TotalCommittedStorage = state.TotalCommittedStorage.Sub(state.TotalCommittedStorage, lost)
+			}
+
+			if err := deadlines.Delete(ctx, h.String()); err != nil {
+				return nil, errors.FaultErrorWrapf(err, "could not delete miners to check at %s", h)
+			}
+			checked = true
+		}
+
+		if checked {
+			state.ProvingDeadlines, err = deadlines.Commit(ctx)
+			if err != nil {
+				return nil, errors.FaultErrorWrap(err, "could not commit proving deadlines")
+			}
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
 // GetTotalStorage returns the total amount of proven storage in the system.
 func (sma *Actor) GetTotalStorage(vmctx exec.VMContext) (*big.Int, uint8, error) {
 	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	. "github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
+	atesting "github.com/filecoin-project/go-filecoin/actor/testing"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/core"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
@@ -141,6 +142,76 @@ func TestStorageMarkeCreateMinerErrorsOnInvalidKey(t *testing.T) {
 	assert.Contains(t, result.ExecutionError.Error(), miner.Errors[miner.ErrPublicKeyTooBig].Error())
 }
 
+func TestStorageMarketCheckProvingPeriods(t *testing.T) {
+	tf.UnitTest(t)
+
+	rt := atesting.NewRuntime(address.StorageMarketAddress, types.StorageMarketActorCodeCid, types.ZeroAttoFIL)
+	require.NoError(t, rt.Initialize(&Actor{}, types.TestProofsMode))
+
+	rt.Value = types.NewAttoFILFromFIL(100)
+	ret, code, err := rt.Call(&Actor{}, "createMiner", big.NewInt(10), []byte{}, th.RequireRandomPeerID(t))
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), code)
+	minerAddr, err := address.NewFromBytes(ret[0])
+	require.NoError(t, err)
+
+	rt.Value = types.ZeroAttoFIL
+	rt.Caller = minerAddr
+	_, code, err = rt.Call(&Actor{}, "updatePower", big.NewInt(5))
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), code)
+	_, code, err = rt.Call(&Actor{}, "scheduleProvingCheck", types.NewBlockHeight(10))
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), code)
+
+	checked := 0
+	rt.OnSend(minerAddr, "checkProvingPeriod", func(value *types.AttoFIL, params []interface{}) ([][]byte, uint8, error) {
+		checked++
+		lost, err := (&abi.Value{Type: abi.Integer, Val: big.NewInt(2)}).Serialize()
+		return [][]byte{lost}, 0, err
+	})
+
+	t.Run("only the cron actor may check proving periods", func(t *testing.T) {
+		rt.Caller = address.TestAddress
+		_, code, err := rt.Call(&Actor{}, "checkProvingPeriods", types.NewBlockHeight(0))
+		assert.Error(t, err)
+		assert.Equal(t, uint8(ErrCallerUnauthorized), code)
+	})
+
+	t.Run("only checks the miners scheduled since the given height", func(t *testing.T) {
+		rt.Caller = address.CronAddress
+		rt.Height = types.NewBlockHeight(9)
+		_, code, err := rt.Call(&Actor{}, "checkProvingPeriods", types.NewBlockHeight(0))
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), code)
+		assert.Equal(t, 0, checked)
+
+		rt.Height = types.NewBlockHeight(12)
+		_, code, err = rt.Call(&Actor{}, "checkProvingPeriods", types.NewBlockHeight(9))
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), code)
+		assert.Equal(t, 1, checked)
+
+		_, code, err = rt.Call(&Actor{}, "checkProvingPeriods", types.NewBlockHeight(9))
+		require.NoError(t, err)
+		require.Equal(t, uint8(0), code)
+		assert.Equal(t, 1, checked, "a miner is checked once per schedule")
+	})
+
+	t.Run("removes the power lost by miners from the total", func(t *testing.T) {
+		var state State
+		require.NoError(t, rt.State(&state))
+		assert.Equal(t, big.NewInt(3), state.TotalCommittedStorage)
+	})
+
+	t.Run("only registered miners may schedule a check", func(t *testing.T) {
+		rt.Caller = address.TestAddress
+		_, code, err := rt.Call(&Actor{}, "scheduleProvingCheck", types.NewBlockHeight(20))
+		assert.Error(t, err)
+		assert.Equal(t, uint8(ErrUnknownMiner), code)
+	})
+}
+
 func TestMinimumCollateral(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -42,6 +42,14 @@ var FakeActorExports = exec.Exports{
 		Params: nil,
 		Return: nil,
 	},
+	"cronRevertError": &exec.FunctionSignature{
+		Params: []abi.Type{abi.BlockHeight},
+		Return: nil,
+	},
+	"cronGoodCall": &exec.FunctionSignature{
+		Params: []abi.Type{abi.BlockHeight},
+		Return: nil,
+	},
 	"nonZeroExitCode": &exec.FunctionSignature{
 		Params: nil,
 		Return: nil,
@@ -145,6 +153,16 @@ func (ma *FakeActor) GoodCall(ctx exec.VMContext) (uint8, error) {
 	return 0, nil
 }
 
+// CronRevertError is ReturnRevertError taking the parameter of a cron entry.
+func (ma *FakeActor) CronRevertError(ctx exec.VMContext, since *types.BlockHeight) (uint8, error) {
+	return ma.ReturnRevertError(ctx)
+}
+
+// CronGoodCall is GoodCall taking the parameter of a cron entry.
+func (ma *FakeActor) CronGoodCall(ctx exec.VMContext, since *types.BlockHeight) (uint8, error) {
+	return ma.GoodCall(ctx)
+}
+
 // NonZeroExitCode returns a nonzero exit code but no error.
 func (ma *FakeActor) NonZeroExitCode(ctx exec.VMContext) (uint8, error) {
 	return 42, nil
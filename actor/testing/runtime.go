@@ -0,0 +1,302 @@
+// Package testing provides a mock of the runtime the VM exposes to actors, so
+// that actor methods can be unit tested without a state tree or a chain.
+package testing
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
+)
+
+// SendHandler handles a send intercepted by a Runtime, returning what the
+// called method would.
+type SendHandler func(value *types.AttoFIL, params []interface{}) ([][]byte, uint8, error)
+
+// Send is a send made by the actor under test.
+type Send struct {
+	To     address.Address
+	Method string
+	Value  *types.AttoFIL
+	Params []interface{}
+}
+
+// CreatedActor is an actor created by the actor under test.
+type CreatedActor struct {
+	Code            cid.Cid
+	InitializerData interface{}
+}
+
+// Runtime is a mock of the VM context an actor method runs in.  Tests set the
+// caller, value and randomness of calls through its exported fields and call
+// methods with Call.  Sends the actor makes are recorded and answered by the
+// handlers registered with OnSend; transfers of value without a method
+// succeed as long as the actor's balance covers them.
+type Runtime struct {
+	// Caller is the address messages are sent from.
+	Caller address.Address
+	// CallerIsAccount is returned by IsFromAccountActor.
+	CallerIsAccount bool
+	// Value is the value of the messages sent, credited to the actor's
+	// balance before each call.
+	Value *types.AttoFIL
+	// Height is the block height of the calls.
+	Height *types.BlockHeight
+	// Randomness is returned by SampleChainRandomness whatever the height.
+	Randomness []byte
+	// GasLimit limits the gas of each call, if not zero.
+	GasLimit types.GasUnits
+
+	receiver address.Address
+	actor    *actor.Actor
+	storage  vm.Storage
+	message  *types.Message
+	gasUsed  types.GasUnits
+
+	handlers     map[string]SendHandler
+	sends        []*Send
+	balances     map[address.Address]*types.AttoFIL
+	created      map[address.Address]*CreatedActor
+	createdCount uint64
+}
+
+var _ exec.VMContext = (*Runtime)(nil)
+
+// NewRuntime returns a runtime for the actor with code at address receiver,
+// holding balance.  Calls are made from the account at address.TestAddress.
+func NewRuntime(receiver address.Address, code cid.Cid, balance *types.AttoFIL) *Runtime {
+	act := actor.NewActor(code, balance)
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	return &Runtime{
+		Caller:          address.TestAddress,
+		CallerIsAccount: true,
+		Value:           types.ZeroAttoFIL,
+		Height:          types.NewBlockHeight(0),
+		receiver:        receiver,
+		actor:           act,
+		storage:         vm.NewStorage(bs, act),
+		handlers:        make(map[string]SendHandler),
+		balances:        make(map[address.Address]*types.AttoFIL),
+		created:         make(map[address.Address]*CreatedActor),
+	}
+}
+
+// Initialize initializes the storage of the actor under test with
+// initializerData, as when it is installed.
+func (rt *Runtime) Initialize(act exec.ExecutableActor, initializerData interface{}) error {
+	return act.InitializeState(rt.storage, initializerData)
+}
+
+// Call calls method of the actor under test with params, as vm.Send would.
+// The state and balance of the actor are rolled back if the method fails.
+func (rt *Runtime) Call(act exec.ExecutableActor, method string, params ...interface{}) ([][]byte, uint8, error) {
+	if !act.Exports().Has(method) {
+		return nil, errors.ErrMissingExport, errors.Errors[errors.ErrMissingExport]
+	}
+
+	rt.message = types.NewMessage(rt.Caller, rt.receiver, 0, rt.Value, method, actor.MustConvertParams(params...))
+	rt.gasUsed = 0
+
+	head, balance := rt.actor.Head, rt.actor.Balance
+	if rt.Value != nil {
+		rt.actor.Balance = rt.actor.Balance.Add(rt.Value)
+	}
+
+	r, code, err := actor.MakeTypedExport(act, method)(rt)
+	if err != nil {
+		rt.actor.Head, rt.actor.Balance = head, balance
+		return nil, code, err
+	}
+	if r == nil {
+		return nil, code, nil
+	}
+
+	var ret [][]byte
+	if err := cbor.DecodeInto(r, &ret); err != nil {
+		return nil, exitcode.SerializationError, errors.NewCodedRevertErrorf(exitcode.SerializationError, "method return doesn't decode as array: %s", err)
+	}
+	return ret, code, nil
+}
+
+// OnSend makes handler answer the sends of the actor under test to method of
+// the actor at address to.
+func (rt *Runtime) OnSend(to address.Address, method string, handler SendHandler) {
+	rt.handlers[sendKey(to, method)] = handler
+}
+
+// Sends returns the sends made by the actor under test, in order.
+func (rt *Runtime) Sends() []*Send {
+	return rt.sends
+}
+
+// Head returns the head of the storage of the actor under test.
+func (rt *Runtime) Head() cid.Cid {
+	return rt.actor.Head
+}
+
+// State decodes the storage of the actor under test into state.
+func (rt *Runtime) State(state interface{}) error {
+	chunk, err := rt.storage.Get(rt.actor.Head)
+	if err != nil {
+		return err
+	}
+	return actor.UnmarshalStorage(chunk, state)
+}
+
+// BalanceOf returns the value the actor under test transferred to the actor
+// at addr.
+func (rt *Runtime) BalanceOf(addr address.Address) *types.AttoFIL {
+	if b, ok := rt.balances[addr]; ok {
+		return b
+	}
+	return types.ZeroAttoFIL
+}
+
+// Created returns the actor the actor under test created at addr, or nil.
+func (rt *Runtime) Created(addr address.Address) *CreatedActor {
+	return rt.created[addr]
+}
+
+// GasUsed returns the gas charged by the last call.
+func (rt *Runtime) GasUsed() types.GasUnits {
+	return rt.gasUsed
+}
+
+// Message implements exec.VMContext.
+func (rt *Runtime) Message() *types.Message {
+	return rt.message
+}
+
+// Storage implements exec.VMContext.
+func (rt *Runtime) Storage() exec.Storage {
+	return rt.storage
+}
+
+// Send implements exec.VMContext.  Sends with a method are answered by the
+// handler registered for it and fail if there is none.
+func (rt *Runtime) Send(to address.Address, method string, value *types.AttoFIL, params []interface{}) ([][]byte, uint8, error) {
+	rt.sends = append(rt.sends, &Send{To: to, Method: method, Value: value, Params: params})
+
+	if to == rt.receiver {
+		return nil, 1, errors.NewFaultErrorf("unhandled: sending to self (%s)", to)
+	}
+	if _, err := abi.ToValues(params); err != nil {
+		return nil, 1, errors.FaultErrorWrap(err, "failed to convert inputs to abi values")
+	}
+
+	if value != nil {
+		if value.IsNegative() {
+			return nil, errors.ErrCannotTransferNegativeValue, errors.Errors[errors.ErrCannotTransferNegativeValue]
+		}
+		if rt.actor.Balance.LessThan(value) {
+			return nil, errors.ErrInsufficientBalance, errors.Errors[errors.ErrInsufficientBalance]
+		}
+	}
+
+	var ret [][]byte
+	if method != "" {
+		handler, ok := rt.handlers[sendKey(to, method)]
+		if !ok {
+			return nil, errors.ErrMissingExport, errors.NewCodedRevertErrorf(errors.ErrMissingExport, "unexpected send to %s %s", to, method)
+		}
+		r, code, err := handler(value, params)
+		if err != nil || code != 0 {
+			return nil, code, err
+		}
+		ret = r
+	}
+
+	if value != nil {
+		rt.actor.Balance = rt.actor.Balance.Sub(value)
+		rt.balances[to] = rt.BalanceOf(to).Add(value)
+	}
+	return ret, 0, nil
+}
+
+// AddressForNewActor implements exec.VMContext.  It returns a new address on
+// each call.
+func (rt *Runtime) AddressForNewActor() (address.Address, error) {
+	rt.createdCount++
+	return address.NewActorAddress([]byte(fmt.Sprintf("%s-%d", rt.receiver, rt.createdCount)))
+}
+
+// BlockHeight implements exec.VMContext.
+func (rt *Runtime) BlockHeight() *types.BlockHeight {
+	return rt.Height
+}
+
+// IsFromAccountActor implements exec.VMContext.
+func (rt *Runtime) IsFromAccountActor() bool {
+	return rt.CallerIsAccount
+}
+
+// Balance implements exec.VMContext.
+func (rt *Runtime) Balance() *types.AttoFIL {
+	return rt.actor.Balance
+}
+
+// Charge implements exec.VMContext.
+func (rt *Runtime) Charge(cost types.GasUnits) error {
+	if rt.GasLimit != 0 && rt.gasUsed+cost > rt.GasLimit {
+		rt.gasUsed = rt.GasLimit
+		return errors.NewRevertError("gas cost exceeds gas limit")
+	}
+	rt.gasUsed += cost
+	return nil
+}
+
+// SampleChainRandomness implements exec.VMContext.
+func (rt *Runtime) SampleChainRandomness(sampleHeight *types.BlockHeight) ([]byte, error) {
+	return rt.Randomness, nil
+}
+
+// CreateNewActor implements exec.VMContext.  The actor is recorded but its
+// state is not initialized.
+func (rt *Runtime) CreateNewActor(addr address.Address, code cid.Cid, initializerData interface{}) error {
+	if _, ok := rt.created[addr]; ok {
+		return errors.NewRevertErrorf("attempt to create actor at address %s but a non-empty actor is already installed", addr)
+	}
+	rt.created[addr] = &CreatedActor{Code: code, InitializerData: initializerData}
+	return nil
+}
+
+// ReadStorage implements exec.VMContext.
+func (rt *Runtime) ReadStorage() ([]byte, error) {
+	chunk, err := rt.storage.Get(rt.actor.Head)
+	if err != nil {
+		if err == vm.ErrNotFound {
+			return nil, errors.NewRevertErrorf("actor state not found at cid %s", rt.actor.Head)
+		}
+		return nil, err
+	}
+	out := make([]byte, len(chunk))
+	copy(out, chunk)
+	return out, nil
+}
+
+// WriteStorage implements exec.VMContext.
+func (rt *Runtime) WriteStorage(memory interface{}) error {
+	c, err := rt.storage.Put(memory)
+	if err != nil {
+		return errors.RevertErrorWrap(err, "Could not stage memory chunk")
+	}
+	if err := rt.storage.Commit(c, rt.actor.Head); err != nil {
+		return errors.RevertErrorWrap(err, "Could not commit actor memory")
+	}
+	return nil
+}
+
+func sendKey(to address.Address, method string) string {
+	return to.String() + "/" + method
+}
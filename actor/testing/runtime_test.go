@@ -0,0 +1,86 @@
+package testing_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	. "github.com/filecoin-project/go-filecoin/actor/testing"
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+func TestRuntime(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	newRuntime := func(t *testing.T) (*Runtime, *actor.FakeActor) {
+		rt := NewRuntime(newAddress(), types.NewCidForTestGetter()(), types.NewAttoFILFromFIL(1000))
+		fake := &actor.FakeActor{}
+		require.NoError(t, rt.Initialize(fake, &actor.FakeActorStorage{}))
+		return rt, fake
+	}
+
+	t.Run("transfers value", func(t *testing.T) {
+		rt, fake := newRuntime(t)
+		target := newAddress()
+
+		_, code, err := rt.Call(fake, "sendTokens", target)
+		require.NoError(t, err)
+		assert.Equal(t, uint8(0), code)
+		assert.Equal(t, types.NewAttoFILFromFIL(100), rt.BalanceOf(target))
+		assert.Equal(t, types.NewAttoFILFromFIL(900), rt.Balance())
+	})
+
+	t.Run("commits state", func(t *testing.T) {
+		rt, fake := newRuntime(t)
+
+		_, _, err := rt.Call(fake, "goodCall")
+		require.NoError(t, err)
+
+		var st actor.FakeActorStorage
+		require.NoError(t, rt.State(&st))
+		assert.True(t, st.Changed)
+	})
+
+	t.Run("rolls back state on error", func(t *testing.T) {
+		rt, fake := newRuntime(t)
+		head := rt.Head()
+
+		_, code, err := rt.Call(fake, "returnRevertError")
+		assert.Error(t, err)
+		assert.Equal(t, uint8(1), code)
+		assert.Equal(t, head, rt.Head())
+	})
+
+	t.Run("fails unhandled sends", func(t *testing.T) {
+		rt, fake := newRuntime(t)
+		target, to := newAddress(), newAddress()
+
+		_, code, err := rt.Call(fake, "callSendTokens", target, to)
+		assert.Error(t, err)
+		assert.Equal(t, uint8(errors.ErrMissingExport), code)
+		require.Len(t, rt.Sends(), 1)
+		assert.Equal(t, "sendTokens", rt.Sends()[0].Method)
+	})
+
+	t.Run("answers sends with handlers", func(t *testing.T) {
+		rt, fake := newRuntime(t)
+		target, to := newAddress(), newAddress()
+
+		var got []interface{}
+		rt.OnSend(target, "sendTokens", func(value *types.AttoFIL, params []interface{}) ([][]byte, uint8, error) {
+			got = params
+			return nil, 0, nil
+		})
+
+		_, code, err := rt.Call(fake, "callSendTokens", target, to)
+		require.NoError(t, err)
+		assert.Equal(t, uint8(0), code)
+		assert.Equal(t, []interface{}{to}, got)
+	})
+}
@@ -35,6 +35,21 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	MultisigFactoryAddress, err = NewActorAddress([]byte("multisig"))
+	if err != nil {
+		panic(err)
+	}
+
+	BurntFundsAddress, err = NewActorAddress([]byte("burnt"))
+	if err != nil {
+		panic(err)
+	}
+
+	CronAddress, err = NewActorAddress([]byte("cron"))
+	if err != nil {
+		panic(err)
+	}
 }
 
 var (
@@ -49,6 +64,13 @@ var (
 	StorageMarketAddress Address
 	// PaymentBrokerAddress is the hard-coded address of the filecoin payment broker.
 	PaymentBrokerAddress Address
+	// MultisigFactoryAddress is the hard-coded address of the filecoin multisig wallet factory.
+	MultisigFactoryAddress Address
+	// BurntFundsAddress is the hard-coded address where burnt funds are sent.
+	// Nobody controls it.
+	BurntFundsAddress Address
+	// CronAddress is the hard-coded address of the filecoin cron actor.
+	CronAddress Address
 )
 
 var (
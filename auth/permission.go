@@ -0,0 +1,114 @@
+// Package auth implements API tokens and the permissions they grant, so that
+// the API can be exposed to clients that must not control the whole node.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Permission is the level of access to the API a request has.  Each level
+// includes the ones below it.
+type Permission int
+
+const (
+	// PermNone grants no access.
+	PermNone = Permission(iota)
+	// PermRead grants reading the chain, state and the node's status.
+	PermRead
+	// PermWrite grants changing the node's state without using its keys,
+	// e.g. broadcasting signed messages or connecting to peers.
+	PermWrite
+	// PermSign grants signing and sending messages with the node's keys.
+	PermSign
+	// PermAdmin grants everything, including configuring the node and
+	// exporting its keys.
+	PermAdmin
+)
+
+var permissionNames = []string{"none", "read", "write", "sign", "admin"}
+
+// ErrPermissionDenied is returned when a request lacks the permission an
+// endpoint requires.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ParsePermission returns the permission named s.
+func ParsePermission(s string) (Permission, error) {
+	for i, name := range permissionNames {
+		if s == name {
+			return Permission(i), nil
+		}
+	}
+	return PermNone, errors.Errorf("unknown permission %q, expected one of none, read, write, sign or admin", s)
+}
+
+func (p Permission) String() string {
+	if p < 0 || int(p) >= len(permissionNames) {
+		return "unknown"
+	}
+	return permissionNames[p]
+}
+
+// MarshalJSON encodes p as its name.
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes p from its name.
+func (p *Permission) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := ParsePermission(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+type permissionKey struct{}
+
+// WithPermission returns a context for a request with permission p.
+func WithPermission(ctx context.Context, p Permission) context.Context {
+	return context.WithValue(ctx, permissionKey{}, p)
+}
+
+// PermissionFromContext returns the permission of the request of ctx.
+// Requests that weren't given a permission with WithPermission, e.g. by a
+// Handler, have none.
+func PermissionFromContext(ctx context.Context) Permission {
+	if p, ok := ctx.Value(permissionKey{}).(Permission); ok {
+		return p
+	}
+	return PermNone
+}
+
+// Require returns ErrPermissionDenied if the request of ctx lacks p.
+func Require(ctx context.Context, p Permission) error {
+	if has := PermissionFromContext(ctx); has < p {
+		return errors.Wrapf(ErrPermissionDenied, "%s permission required, request has %s", p, has)
+	}
+	return nil
+}
+
+// Restrict returns a handler refusing the requests lacking the permission
+// required returns for them, and passing the others to next.
+func Restrict(required func(*http.Request) Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := Require(r.Context(), required(r)); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Requires returns a function requiring p of every request, for Restrict.
+func Requires(p Permission) func(*http.Request) Permission {
+	return func(*http.Request) Permission { return p }
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+func init() {
+	cbor.RegisterCborType(Token{})
+}
+
+// Prefix is the datastore prefix for API tokens.
+const Prefix = "auth/tokens"
+
+// Sizes in bytes of the random parts of tokens.
+const (
+	tokenIDSize     = 8
+	tokenSecretSize = 32
+)
+
+// ErrInvalidToken is returned when verifying a token that is malformed,
+// unknown or revoked.
+var ErrInvalidToken = errors.New("invalid api token")
+
+// ErrTokenNotFound is returned when revoking a token that doesn't exist.
+var ErrTokenNotFound = errors.New("no api token with this id")
+
+// Token describes an API token.  The token itself is only known to its
+// holder: the store keeps the hash of its secret.
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Permission Permission `json:"permission"`
+	// Created is the unix time the token was created at.
+	Created int64 `json:"created"`
+	// Hash is the sha256 hash of the token's secret.
+	Hash []byte `json:"-"`
+}
+
+// TokenStore is a persisted set of API tokens.
+type TokenStore struct {
+	ds repo.Datastore
+}
+
+// NewTokenStore returns a TokenStore persisted to ds.
+func NewTokenStore(ds repo.Datastore) *TokenStore {
+	return &TokenStore{ds: ds}
+}
+
+// Create creates a token granting perm, returning the token to give to its
+// holder along with its description.  The token can't be recovered later.
+func (s *TokenStore) Create(name string, perm Permission) (string, *Token, error) {
+	if perm <= PermNone || perm > PermAdmin {
+		return "", nil, errors.Errorf("tokens can't grant %s permission", perm)
+	}
+	id, err := randomHex(tokenIDSize)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(tokenSecretSize)
+	if err != nil {
+		return "", nil, err
+	}
+	hash := sha256.Sum256([]byte(secret))
+	tok := &Token{
+		ID:         id,
+		Name:       name,
+		Permission: perm,
+		Created:    time.Now().Unix(),
+		Hash:       hash[:],
+	}
+
+	datum, err := cbor.DumpObject(tok)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "could not marshal api token")
+	}
+	if err := s.ds.Put(tokenKey(id), datum); err != nil {
+		return "", nil, errors.Wrap(err, "could not save api token")
+	}
+	return id + "." + secret, tok, nil
+}
+
+// Ls returns the tokens of the store, oldest first.
+func (s *TokenStore) Ls() ([]*Token, error) {
+	results, err := s.ds.Query(query.Query{Prefix: "/" + Prefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query api tokens")
+	}
+
+	var toks []*Token
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, errors.Wrap(result.Error, "failed to query api tokens")
+		}
+		var tok Token
+		if err := cbor.DecodeInto(result.Value, &tok); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal api token")
+		}
+		toks = append(toks, &tok)
+	}
+	sort.Slice(toks, func(i, j int) bool {
+		if toks[i].Created != toks[j].Created {
+			return toks[i].Created < toks[j].Created
+		}
+		return toks[i].ID < toks[j].ID
+	})
+	return toks, nil
+}
+
+// Revoke deletes the token with the given id, which is no longer valid.
+func (s *TokenStore) Revoke(id string) error {
+	key := tokenKey(id)
+	has, err := s.ds.Has(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to read api tokens")
+	}
+	if !has {
+		return errors.Wrap(ErrTokenNotFound, id)
+	}
+	return s.ds.Delete(key)
+}
+
+// Verify returns the description of token, or ErrInvalidToken if it isn't a
+// token of the store.
+func (s *TokenStore) Verify(token string) (*Token, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], "/") {
+		return nil, ErrInvalidToken
+	}
+	datum, err := s.ds.Get(tokenKey(parts[0]))
+	if err == datastore.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read api tokens")
+	}
+	var tok Token
+	if err := cbor.DecodeInto(datum, &tok); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal api token")
+	}
+	hash := sha256.Sum256([]byte(parts[1]))
+	if subtle.ConstantTimeCompare(hash[:], tok.Hash) != 1 {
+		return nil, ErrInvalidToken
+	}
+	return &tok, nil
+}
+
+// Verifier verifies API tokens.
+type Verifier interface {
+	AuthTokenVerify(token string) (*Token, error)
+}
+
+// NewHandler returns a handler passing requests to next with the permission
+// of their bearer token in their context, or anonymous if they have none.
+// Requests with an invalid token are refused.
+func NewHandler(v Verifier, anonymous Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		perm := anonymous
+		if header := r.Header.Get("Authorization"); header != "" {
+			token := strings.TrimPrefix(header, "Bearer ")
+			if token == header {
+				http.Error(w, "the Authorization header must hold a bearer token", http.StatusUnauthorized)
+				return
+			}
+			tok, err := v.AuthTokenVerify(token)
+			if err == ErrInvalidToken {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			perm = tok.Permission
+		}
+		next.ServeHTTP(w, r.WithContext(WithPermission(r.Context(), perm)))
+	})
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate random bytes")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func tokenKey(id string) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{Prefix, id})
+}
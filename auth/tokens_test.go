@@ -0,0 +1,117 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/auth"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+type storeVerifier struct {
+	*auth.TokenStore
+}
+
+func (v storeVerifier) AuthTokenVerify(token string) (*auth.Token, error) {
+	return v.Verify(token)
+}
+
+func TestTokenStore(t *testing.T) {
+	tf.UnitTest(t)
+
+	store := auth.NewTokenStore(datastore.NewMapDatastore())
+
+	readToken, readInfo, err := store.Create("explorer", auth.PermRead)
+	require.NoError(t, err)
+	signToken, _, err := store.Create("wallet", auth.PermSign)
+	require.NoError(t, err)
+
+	_, _, err = store.Create("nothing", auth.PermNone)
+	assert.Error(t, err)
+
+	t.Run("verifies tokens", func(t *testing.T) {
+		tok, err := store.Verify(readToken)
+		require.NoError(t, err)
+		assert.Equal(t, readInfo.ID, tok.ID)
+		assert.Equal(t, "explorer", tok.Name)
+		assert.Equal(t, auth.PermRead, tok.Permission)
+
+		tok, err = store.Verify(signToken)
+		require.NoError(t, err)
+		assert.Equal(t, auth.PermSign, tok.Permission)
+
+		for _, bad := range []string{"", "nodot", readInfo.ID + ".", readInfo.ID + ".00", signToken[:len(signToken)-1] + "x"} {
+			_, err := store.Verify(bad)
+			assert.Equal(t, auth.ErrInvalidToken, err, bad)
+		}
+	})
+
+	t.Run("lists and revokes tokens", func(t *testing.T) {
+		toks, err := store.Ls()
+		require.NoError(t, err)
+		assert.Len(t, toks, 2)
+
+		require.NoError(t, store.Revoke(readInfo.ID))
+		_, err = store.Verify(readToken)
+		assert.Equal(t, auth.ErrInvalidToken, err)
+		assert.Error(t, store.Revoke(readInfo.ID))
+
+		toks, err = store.Ls()
+		require.NoError(t, err)
+		require.Len(t, toks, 1)
+		assert.Equal(t, "wallet", toks[0].Name)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	tf.UnitTest(t)
+
+	store := auth.NewTokenStore(datastore.NewMapDatastore())
+	writeToken, _, err := store.Create("relay", auth.PermWrite)
+	require.NoError(t, err)
+
+	var got auth.Permission
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = auth.PermissionFromContext(r.Context())
+	})
+	restricted := auth.Restrict(auth.Requires(auth.PermWrite), next)
+	ts := httptest.NewServer(auth.NewHandler(storeVerifier{store}, auth.PermRead, restricted))
+	defer ts.Close()
+
+	do := func(authorization string) int {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close() // nolint: errcheck
+		return resp.StatusCode
+	}
+
+	// Anonymous requests are read-only here.
+	assert.Equal(t, http.StatusForbidden, do(""))
+
+	assert.Equal(t, http.StatusOK, do("Bearer "+writeToken))
+	assert.Equal(t, auth.PermWrite, got)
+
+	assert.Equal(t, http.StatusUnauthorized, do("Bearer nope.nope"))
+	assert.Equal(t, http.StatusUnauthorized, do(writeToken))
+
+	// Requests that didn't go through a handler have no permission.
+	assert.Equal(t, auth.PermNone, auth.PermissionFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()))
+
+	// Nor are preflight requests let through without one.
+	req, err := http.NewRequest(http.MethodOptions, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close() // nolint: errcheck
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var (
+	// ErrCheckpointMismatch is returned when syncing from a checkpoint a
+	// chain that doesn't include it.
+	ErrCheckpointMismatch = errors.New("chain does not include the checkpoint")
+	// ErrStateDeferred is returned by the store when the state of a tipset
+	// below the checkpoint is needed, since syncing from a checkpoint
+	// doesn't compute it.
+	ErrStateDeferred = errors.New("state of tipset was not computed by checkpoint sync")
+)
+
+// Checkpoint is a tipset trusted to be on the canonical chain, with the root
+// of the state resulting from it.  A syncer with a checkpoint fetches the
+// state at the checkpoint instead of running the messages of the chain below
+// it, and only checks that the headers of that chain link back to genesis.
+// Tipsets after the checkpoint are validated as usual.
+type Checkpoint struct {
+	TipSet    types.SortedCidSet
+	StateRoot cid.Cid
+}
+
+// stateFetcher is implemented by fetchers that can fetch a whole state tree
+// over the network, with the actors' storage it references.
+type stateFetcher interface {
+	FetchState(ctx context.Context, root cid.Cid) error
+}
+
+// SetCheckpoint makes the syncer sync from cp the first time it syncs a
+// chain including it.  Once the checkpoint is in the store it has no effect.
+func (syncer *DefaultSyncer) SetCheckpoint(cp *Checkpoint) {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+	syncer.checkpoint = cp
+}
+
+// pendingCheckpoint returns the syncer's checkpoint if it is not yet in the
+// store, and nil otherwise.
+func (syncer *DefaultSyncer) pendingCheckpoint(ctx context.Context) *Checkpoint {
+	if syncer.checkpoint == nil || syncer.chainStore.HasTipSetAndState(ctx, syncer.checkpoint.TipSet.String()) {
+		return nil
+	}
+	return syncer.checkpoint
+}
+
+// syncToCheckpoint adds the tipsets of chain up to the checkpoint to the
+// store without running their messages, then fetches the checkpoint's state
+// and makes the checkpoint the head.  parent is the tipset of the store chain
+// extends.  It returns the checkpoint and the rest of chain, which remains
+// to be synced from it.
+func (syncer *DefaultSyncer) syncToCheckpoint(ctx context.Context, cp *Checkpoint, parent types.TipSet, chain []types.TipSet) (types.TipSet, []types.TipSet, error) {
+	idx := -1
+	for i, ts := range chain {
+		if ts.ToSortedCidSet().Equals(cp.TipSet) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, nil, errors.Wrapf(ErrCheckpointMismatch, "chain with head %s does not include checkpoint %s", chain[len(chain)-1].String(), cp.TipSet.String())
+	}
+
+	for _, ts := range chain[:idx] {
+		if err := syncer.syncHeaders(ctx, parent, ts, cid.Undef); err != nil {
+			return nil, nil, err
+		}
+		parent = ts
+	}
+
+	if sf, ok := syncer.fetcher.(stateFetcher); ok {
+		logSyncer.Infof("fetching checkpoint state %s", cp.StateRoot)
+		if err := sf.FetchState(ctx, cp.StateRoot); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to fetch checkpoint state %s", cp.StateRoot)
+		}
+	}
+	if _, err := state.LoadStateTree(ctx, syncer.stateStore, cp.StateRoot, builtin.Actors); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load checkpoint state %s", cp.StateRoot)
+	}
+
+	checkpoint := chain[idx]
+	if err := syncer.syncHeaders(ctx, parent, checkpoint, cp.StateRoot); err != nil {
+		return nil, nil, err
+	}
+	if err := syncer.chainStore.SetHead(ctx, checkpoint); err != nil {
+		return nil, nil, err
+	}
+	logSyncer.Infof("synced to checkpoint %s", checkpoint.String())
+	return checkpoint, chain[idx+1:], nil
+}
+
+// syncHeaders adds next to the store with the given state root after checking
+// the timestamps of its blocks against parent, without running its messages.
+// The structure of the blocks has been checked while collecting the chain.
+// The signatures of their messages are not checked, since the messages are
+// not run and the tipset is linked to the trusted checkpoint.
+func (syncer *DefaultSyncer) syncHeaders(ctx context.Context, parent, next types.TipSet, stateRoot cid.Cid) error {
+	for _, blk := range next.ToSlice() {
+		if err := consensus.ValidateTimestampOrder(parent, blk); err != nil {
+			return err
+		}
+	}
+	return syncer.chainStore.PutTipSetAndState(ctx, &TipSetAndState{
+		TipSet:          next,
+		TipSetStateRoot: stateRoot,
+	})
+}
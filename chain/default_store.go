@@ -14,6 +14,9 @@ import (
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/metrics/tracing"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -21,6 +24,8 @@ import (
 
 var logStore = logging.Logger("chain.store")
 
+var headHeightGauge = metrics.NewInt64Gauge("chain/head_height", "Height of the head of the chain")
+
 var headKey = datastore.NewKey("/chain/heaviestTipSet")
 
 // DefaultStore is a generic implementation of the Store interface.
@@ -55,19 +60,34 @@ type DefaultStore struct {
 
 	// Tracks tipsets by height/parentset for use by expected consensus.
 	tipIndex *TipIndex
+
+	// msgIndex maps message cids to their location on the heaviest chain.
+	msgIndex *MessageIndex
+
+	// cache holds recently read blocks and tipsets.
+	cache *ReadCache
 }
 
 // Ensure DefaultStore satisfies the Store interface at compile time.
 var _ Store = (*DefaultStore)(nil)
 
-// NewDefaultStore constructs a new default store.
+// NewDefaultStore constructs a new default store with a read cache of the
+// default size.
 func NewDefaultStore(ds repo.Datastore, genesisCid cid.Cid) *DefaultStore {
+	return NewDefaultStoreWithCache(ds, genesisCid, NewReadCache(config.DefaultBlockCacheSize, config.DefaultTipSetCacheSize))
+}
+
+// NewDefaultStoreWithCache constructs a new default store reading through the
+// given cache.  A nil cache disables caching.
+func NewDefaultStoreWithCache(ds repo.Datastore, genesisCid cid.Cid, cache *ReadCache) *DefaultStore {
 	priv := bstore.NewBlockstore(ds)
 	return &DefaultStore{
 		bsPriv:     priv,
 		ds:         ds,
 		headEvents: pubsub.New(128),
 		tipIndex:   NewTipIndex(),
+		msgIndex:   NewMessageIndex(ds),
+		cache:      cache,
 		genesis:    genesisCid,
 	}
 }
@@ -196,6 +216,9 @@ func (store *DefaultStore) putBlk(ctx context.Context, block *types.Block) error
 	if err := store.bsPriv.Put(block.ToNode()); err != nil {
 		return errors.Wrap(err, "failed to put block")
 	}
+	// Only cache blocks that are persisted, so that a failed write isn't
+	// hidden by the cache until the node restarts.
+	store.cache.AddBlock(block)
 	return nil
 }
 
@@ -228,9 +251,17 @@ func (store *DefaultStore) GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, e
 }
 
 // GetTipSetStateRoot returns the state of the tipset whose block
-// cids correspond to the input sorted cid set.
+// cids correspond to the input sorted cid set.  It returns ErrStateDeferred
+// for the tipsets below a checkpoint, whose state was never computed.
 func (store *DefaultStore) GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error) {
-	return store.tipIndex.GetTipSetStateRoot(tsKey.String())
+	stateRoot, err := store.tipIndex.GetTipSetStateRoot(tsKey.String())
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !stateRoot.Defined() {
+		return cid.Undef, errors.Wrapf(ErrStateDeferred, "tipset %s", tsKey.String())
+	}
+	return stateRoot, nil
 }
 
 // HasTipSetAndState returns true iff the default store's tipindex is indexing
@@ -272,11 +303,39 @@ func (store *DefaultStore) GetBlocks(ctx context.Context, cids types.SortedCidSe
 
 // GetBlock retrieves a block by cid.
 func (store *DefaultStore) GetBlock(ctx context.Context, c cid.Cid) (*types.Block, error) {
+	if blk, ok := store.cache.GetBlock(c); ok {
+		return blk, nil
+	}
 	data, err := store.bsPriv.Get(c)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get block %s", c.String())
 	}
-	return types.DecodeBlock(data.RawData())
+	blk, err := types.DecodeBlock(data.RawData())
+	if err != nil {
+		return nil, err
+	}
+	store.cache.AddBlock(blk)
+	return blk, nil
+}
+
+// LoadTipSet assembles the tipset with the given key from stored blocks.
+// Unlike GetTipSet the tipset need not have been put in the store's tip index.
+func (store *DefaultStore) LoadTipSet(ctx context.Context, key types.SortedCidSet) (types.TipSet, error) {
+	if ts, ok := store.cache.GetTipSet(key); ok {
+		return ts, nil
+	}
+	ts := types.TipSet{}
+	for it := key.Iter(); !it.Complete(); it.Next() {
+		blk, err := store.GetBlock(ctx, it.Value())
+		if err != nil {
+			return nil, err
+		}
+		if err := ts.AddBlock(blk); err != nil {
+			return nil, err
+		}
+	}
+	store.cache.AddTipSet(ts)
+	return ts, nil
 }
 
 // HasAllBlocks indicates whether the blocks are in the store.
@@ -317,6 +376,11 @@ func (store *DefaultStore) SetHead(ctx context.Context, ts types.TipSet) error {
 	if err := store.setHeadPersistent(ctx, ts); err != nil {
 		return err
 	}
+	store.updateMessageIndex(ctx)
+
+	if h, err := ts.Height(); err == nil {
+		headHeightGauge.Set(ctx, int64(h))
+	}
 
 	// Publish an event that we have a new head.
 	store.HeadEvents().Pub(ts, NewHeadTopic)
@@ -324,13 +388,30 @@ func (store *DefaultStore) SetHead(ctx context.Context, ts types.TipSet) error {
 	return nil
 }
 
+// setHeadPersistent moves the head to ts on disk and in memory.  The head's
+// blocks are persisted first, and the new head is then committed in a single
+// batch, so that the head on disk is either the previous or the new one and
+// never references missing blocks.
 func (store *DefaultStore) setHeadPersistent(ctx context.Context, ts types.TipSet) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
+	for _, blk := range ts {
+		if err := store.putBlk(ctx, blk); err != nil {
+			return err
+		}
+	}
+
+	batch, err := store.ds.Batch()
+	if err != nil {
+		return err
+	}
 	// Ensure consistency by storing this new head on disk.
-	if errInner := store.writeHead(ctx, ts.ToSortedCidSet()); errInner != nil {
-		return errors.Wrap(errInner, "failed to write new Head to datastore")
+	if err := writeHead(batch, ts.ToSortedCidSet()); err != nil {
+		return errors.Wrap(err, "failed to write new Head to datastore")
+	}
+	if err := batch.Commit(); err != nil {
+		return errors.Wrap(err, "failed to write new Head to datastore")
 	}
 
 	store.head = ts
@@ -338,15 +419,30 @@ func (store *DefaultStore) setHeadPersistent(ctx context.Context, ts types.TipSe
 	return nil
 }
 
-// writeHead writes the given cid set as head to disk.
-func (store *DefaultStore) writeHead(ctx context.Context, cids types.SortedCidSet) error {
+// updateMessageIndex moves the message index to the current head.  It runs
+// after the head update without holding the store's lock, since walking the
+// chain can take long.  The message index is secondary data: failing to
+// update it, or a concurrent head update overtaking it, leaves it stale,
+// which readers detect.
+func (store *DefaultStore) updateMessageIndex(ctx context.Context) {
+	store.mu.RLock()
+	head := store.head
+	store.mu.RUnlock()
+
+	if err := store.msgIndex.Update(ctx, store, head); err != nil {
+		logStore.Warningf("failed to update message index to %s: %s", head.String(), err)
+	}
+}
+
+// writeHead adds a write of the given cid set as head to the batch.
+func writeHead(batch datastore.Batch, cids types.SortedCidSet) error {
 	logStore.Debugf("WriteHead %s", cids.String())
 	val, err := json.Marshal(cids)
 	if err != nil {
 		return err
 	}
 
-	return store.ds.Put(headKey, val)
+	return batch.Put(headKey, val)
 }
 
 // writeTipSetAndState writes the tipset key and the state root id to the
@@ -366,6 +462,41 @@ func (store *DefaultStore) writeTipSetAndState(tsas *TipSetAndState) error {
 	return store.ds.Put(key, val)
 }
 
+// SetMessageReceipts makes the message index record the receipts fn returns
+// with the locations of messages.
+func (store *DefaultStore) SetMessageReceipts(fn ReceiptsFunc) {
+	store.msgIndex.SetReceiptsFunc(fn)
+}
+
+// GetMessageLocation returns the location of a message on the heaviest chain
+// and whether it was found.  It returns ErrMessageIndexStale if the message
+// index has not been updated to the current head, in which case callers must
+// fall back to searching the chain.
+func (store *DefaultStore) GetMessageLocation(msgCid cid.Cid) (*MessageLocation, bool, error) {
+	indexHead, err := store.msgIndex.Head()
+	if err != nil {
+		return nil, false, err
+	}
+	if !indexHead.Equals(store.GetHead()) {
+		return nil, false, ErrMessageIndexStale
+	}
+	return store.msgIndex.Get(msgCid)
+}
+
+// GetAddressMessages returns the messages on the heaviest chain that addr
+// sent or received, newest first.  It returns ErrMessageIndexStale if the
+// message index has not been updated to the current head.
+func (store *DefaultStore) GetAddressMessages(addr address.Address) ([]AddressMessage, error) {
+	indexHead, err := store.msgIndex.Head()
+	if err != nil {
+		return nil, err
+	}
+	if !indexHead.Equals(store.GetHead()) {
+		return nil, ErrMessageIndexStale
+	}
+	return store.msgIndex.AddressMessages(addr)
+}
+
 // GetHead returns the current head tipset cids.
 func (store *DefaultStore) GetHead() types.SortedCidSet {
 	store.mu.RLock()
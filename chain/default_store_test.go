@@ -8,8 +8,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-hamt-ipld"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/consensus"
@@ -97,6 +99,30 @@ func TestPutTipSet(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// failingPutDatastore is a datastore whose writes fail.
+type failingPutDatastore struct {
+	repo.Datastore
+}
+
+func (ds failingPutDatastore) Put(datastore.Key, []byte) error {
+	return errors.New("disk full")
+}
+
+// Blocks that fail to be written are not cached.
+func TestPutTipSetFailureLeavesNoBlocks(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	ctx := context.Background()
+	initStoreTest(ctx, t)
+	cs := chain.NewDefaultStore(failingPutDatastore{repo.NewInMemoryRepo().Datastore()}, genCid)
+	genTsas := &chain.TipSetAndState{
+		TipSet:          genTS,
+		TipSetStateRoot: genStateRoot,
+	}
+	require.Error(t, cs.PutTipSetAndState(ctx, genTsas))
+	assert.False(t, cs.HasAllBlocks(ctx, genTS.ToSortedCidSet().ToSlice()))
+}
+
 // Tipsets can be retrieved by key (all block cids).
 func TestGetByKey(t *testing.T) {
 	tf.BadUnitTestWithSideEffects(t)
@@ -2,6 +2,7 @@ package chain
 
 import (
 	"context"
+	"runtime"
 	"sync"
 	"time"
 
@@ -29,8 +30,28 @@ var (
 	ErrNewChainTooLong = errors.New("input chain forked from best chain too far in the past")
 	// ErrUnexpectedStoreState indicates that the syncer's chain store is violating expected invariants.
 	ErrUnexpectedStoreState = errors.New("the chain store is in an unexpected state")
+	// ErrInconsistentWeight is returned when the weights of a fetched chain do not support the weight claimed for its head.
+	ErrInconsistentWeight = errors.New("chain weight is inconsistent with the claimed weight")
+	// ErrInvalidTipSet is returned when a fetched tipset fails validation.
+	ErrInvalidTipSet = errors.New("input chain contains an invalid tipset")
 )
 
+type claimedWeightKey struct{}
+
+// WithClaimedWeight returns a context recording that the peer announcing the
+// head to sync claims it has the given parent weight.  Syncing under it checks
+// the weights of the fetched tipsets against the claim and stops fetching as
+// soon as they are inconsistent, so a peer cannot make the syncer download a
+// long branch by lying about its weight.
+func WithClaimedWeight(ctx context.Context, parentWeight uint64) context.Context {
+	return context.WithValue(ctx, claimedWeightKey{}, parentWeight)
+}
+
+func claimedWeight(ctx context.Context) (uint64, bool) {
+	w, ok := ctx.Value(claimedWeightKey{}).(uint64)
+	return w, ok
+}
+
 var logSyncer = logging.Logger("chain.syncer")
 
 type syncerChainReader interface {
@@ -50,6 +71,29 @@ type syncFetcher interface {
 	GetBlocks(context.Context, []cid.Cid) ([]*types.Block, error)
 }
 
+// ancestorFetcher is implemented by fetchers that can retrieve a whole chain
+// of ancestors in a single request, which is much cheaper than fetching it
+// one tipset at a time.
+type ancestorFetcher interface {
+	FetchAncestors(ctx context.Context, head types.SortedCidSet, length uint64) error
+}
+
+// sessionFetcher is implemented by fetchers that can fetch the blocks of a
+// single sync operation together, from the peers that announced its head.
+type sessionFetcher interface {
+	// WithSession returns a context under which the blocks of one sync
+	// operation are fetched.  The session ends when ctx is done.
+	WithSession(ctx context.Context) context.Context
+}
+
+// ancestorsBatchSize is the number of tipsets requested at once from an
+// ancestorFetcher.
+const ancestorsBatchSize = 500
+
+// ChainValidationWorkers is the greatest number of tipsets of a chain being
+// synced validated concurrently, ahead of running their state transitions.
+var ChainValidationWorkers = runtime.NumCPU()
+
 // DefaultSyncer updates its chain.Store according to the methods of its
 // consensus.Protocol.  It uses a bad tipset cache and a limit on new
 // blocks to traverse during chain collection.  The DefaultSyncer can query the
@@ -83,6 +127,17 @@ type DefaultSyncer struct {
 	badTipSets *badTipSetCache
 	consensus  consensus.Protocol
 	chainStore syncerChainReader
+	// checkpoint, if set, is the trusted tipset the syncer syncs from
+	// instead of running the messages of the chain from genesis.
+	checkpoint *Checkpoint
+	// status tracks the progress of sync operations.
+	status *syncStatusTracker
+	// maxReorgDepth is the number of rounds of the head's chain the syncer
+	// reverts at most to switch to a heavier chain.  Zero is no limit.
+	maxReorgDepth uint64
+	// timestampRules, if set, bound the timestamps of the blocks synced
+	// beyond increasing with height.
+	timestampRules *consensus.TimestampRules
 }
 
 var _ Syncer = (*DefaultSyncer)(nil)
@@ -97,9 +152,20 @@ func NewDefaultSyncer(cst *hamt.CborIpldStore, c consensus.Protocol, s syncerCha
 		},
 		consensus:  c,
 		chainStore: s,
+		status:     newSyncStatusTracker(),
+
+		maxReorgDepth: DefaultMaxReorgDepth,
 	}
 }
 
+// SetTimestampRules makes the syncer reject blocks timestamped too far in the
+// future or before the epoch of their height.
+func (syncer *DefaultSyncer) SetTimestampRules(rules *consensus.TimestampRules) {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+	syncer.timestampRules = rules
+}
+
 // getBlksMaybeFromNet resolves cids of blocks.  It gets blocks through the
 // fetcher.  The fetcher wraps a bitswap session which wraps a bitswap exchange,
 // and the bitswap exchange wraps the node's shared blockstore.  So if blocks
@@ -120,9 +186,12 @@ func (syncer *DefaultSyncer) getBlksMaybeFromNet(ctx context.Context, blkCids []
 // parent tipset already synced into the store.  collectChain resolves cids
 // from the syncer's fetcher.  In production the fetcher wraps a bitswap
 // session.  collectChain errors if any set of cids in the chain resolves to
-// blocks that do not form a tipset, or if any tipset has already been recorded
-// as the head of an invalid chain.  collectChain is the entrypoint to the code
-// that interacts with the network. It does NOT add tipsets to the chainStore..
+// blocks that are not well formed or do not form a tipset, or if any tipset
+// has already been recorded as the head of an invalid chain.  Only the
+// headers are checked: the signatures of the messages are checked by
+// validateChain once the chain reaches the store.  collectChain is the
+// entrypoint to the code that interacts with the network. It does NOT add
+// tipsets to the chainStore..
 func (syncer *DefaultSyncer) collectChain(ctx context.Context, tipsetCids types.SortedCidSet) (ts []types.TipSet, err error) {
 	ctx, span := trace.StartSpan(ctx, "DefaultSyncer.collectChain")
 	span.AddAttributes(trace.StringAttribute("tipset", tipsetCids.String()))
@@ -149,18 +218,37 @@ func (syncer *DefaultSyncer) collectChain(ctx context.Context, tipsetCids types.
 			return nil, ErrChainHasBadTipSet
 		}
 
+		syncer.prefetchAncestors(ctx, tipsetCids)
+
 		blks, err := syncer.getBlksMaybeFromNet(ctx, tipsetCids.ToSlice())
 		if err != nil {
 			return nil, err
 		}
 
-		ts, err := syncer.consensus.NewValidTipSet(ctx, blks)
+		ts, err := syncer.newHeaderTipSet(blks)
 		if err != nil {
 			syncer.badTipSets.Add(tsKey)
 			syncer.badTipSets.AddChain(chain)
+			return nil, errors.Wrapf(ErrInvalidTipSet, "%s: %s", tsKey, err)
+		}
+		// Blocks from the future are not bad: they become valid once the
+		// local clock catches up.
+		if err := syncer.validateFutureDrift(ctx, ts); err != nil {
 			return nil, err
 		}
 
+		if claimed, ok := claimedWeight(ctx); ok {
+			if err := checkClaimedWeight(ts, chain, claimed); err != nil {
+				return nil, err
+			}
+		}
+
+		height, err := ts.Height()
+		if err != nil {
+			return nil, err
+		}
+		syncer.status.fetched(height)
+
 		count++
 		if count%500 == 0 {
 			logSyncer.Infof("fetching the chain, %d blocks fetched", count)
@@ -175,6 +263,127 @@ func (syncer *DefaultSyncer) collectChain(ctx context.Context, tipsetCids types.
 	}
 }
 
+// newHeaderTipSet returns the tipset formed by blks after checking the
+// structure of each block and that its timestamp falls in the epoch of its
+// height, but not the signatures of their messages.
+func (syncer *DefaultSyncer) newHeaderTipSet(blks []*types.Block) (types.TipSet, error) {
+	for _, blk := range blks {
+		if err := consensus.ValidateBlockStructure(blk); err != nil {
+			return nil, err
+		}
+		if syncer.timestampRules != nil {
+			if err := syncer.timestampRules.ValidateEpoch(blk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return types.NewTipSet(blks...)
+}
+
+// validateFutureDrift returns an error if a block of ts is timestamped too
+// far ahead of the local clock.
+func (syncer *DefaultSyncer) validateFutureDrift(ctx context.Context, ts types.TipSet) error {
+	if syncer.timestampRules == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, blk := range ts.ToSlice() {
+		if err := syncer.timestampRules.ValidateFutureDrift(ctx, blk, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateChain validates the tipsets of chain with consensus, up to
+// ChainValidationWorkers tipsets at a time, while the caller runs their state
+// transitions in order.  It returns a channel per tipset, on which the
+// result of its validation is sent.  Validation stops when ctx is done.
+func (syncer *DefaultSyncer) validateChain(ctx context.Context, chain []types.TipSet) []chan error {
+	results := make([]chan error, len(chain))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+
+	go func() {
+		sem := make(chan struct{}, ChainValidationWorkers)
+		for i, ts := range chain {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				for _, res := range results[i:] {
+					res <- ctx.Err()
+				}
+				return
+			}
+			go func(ts types.TipSet, res chan error) {
+				defer func() { <-sem }()
+				_, err := syncer.consensus.NewValidTipSet(ctx, ts.ToSlice())
+				if ctx.Err() != nil {
+					res <- ctx.Err()
+					return
+				}
+				if err != nil {
+					res <- errors.Wrapf(ErrInvalidTipSet, "%s: %s", ts.String(), err)
+					return
+				}
+				res <- nil
+			}(ts, results[i])
+		}
+	}()
+	return results
+}
+
+// checkClaimedWeight checks that ts, the next tipset of a chain whose head is
+// claimed to have the given parent weight, is consistent with the claim: the
+// head must carry the claimed weight and every other tipset must account for
+// the weight its child adds to it.  chain holds the tipsets collected so far,
+// ts's child first.
+func checkClaimedWeight(ts types.TipSet, chain []types.TipSet, claimed uint64) error {
+	weight, err := ts.ParentWeight()
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		if weight != claimed {
+			return errors.Wrapf(ErrInconsistentWeight, "head %s has parent weight %d, claimed %d", ts.String(), weight, claimed)
+		}
+		return nil
+	}
+
+	childWeight, err := chain[0].ParentWeight()
+	if err != nil {
+		return err
+	}
+	height, err := ts.Height()
+	if err != nil {
+		return err
+	}
+	min, max := consensus.WeightIncreaseBounds(height, len(ts))
+	if childWeight < weight || childWeight-weight < min || childWeight-weight > max {
+		return errors.Wrapf(ErrInconsistentWeight, "tipset %s with parent weight %d cannot have a child with parent weight %d", ts.String(), weight, childWeight)
+	}
+	return nil
+}
+
+// prefetchAncestors asks the fetcher, if it supports it, for a batch of
+// ancestors starting at tipsetCids so that the tipsets collectChain walks
+// next resolve locally.  The fetcher does nothing if the blocks are already
+// local.  Failure is not fatal since blocks can still be fetched one tipset
+// at a time.
+func (syncer *DefaultSyncer) prefetchAncestors(ctx context.Context, tipsetCids types.SortedCidSet) {
+	af, ok := syncer.fetcher.(ancestorFetcher)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, blkWaitTime)
+	defer cancel()
+
+	if err := af.FetchAncestors(ctx, tipsetCids, ancestorsBatchSize); err != nil {
+		logSyncer.Debugf("failed to prefetch ancestors of %s: %s", tipsetCids.String(), err)
+	}
+}
+
 // tipSetState returns the state resulting from applying the input tipset to
 // the chain.  Precondition: the tipset must be in the store
 func (syncer *DefaultSyncer) tipSetState(ctx context.Context, tsKey types.SortedCidSet) (state.Tree, error) {
@@ -208,6 +417,12 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 		return nil
 	}
 
+	for _, blk := range next.ToSlice() {
+		if err := consensus.ValidateTimestampOrder(parent, blk); err != nil {
+			return err
+		}
+	}
+
 	// Lookup parent state. It is guaranteed by the syncer that it is in
 	// the chainStore.
 	st, err := syncer.tipSetState(ctx, parent.ToSortedCidSet())
@@ -248,6 +463,17 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 
 	// TipSet is validated and added to store, now check if it is the heaviest.
 	// If it is the heaviest update the chainStore.
+	return syncer.updateHead(ctx, parent, next)
+}
+
+// updateHead makes next, a tipset of the store whose parent is parent, the
+// head of the store if it is heavier than the head.  It errors with
+// ErrReorgTooDeep if that would revert more of the head's chain than the
+// syncer's reorg depth limit allows, unless ctx is from WithoutReorgLimit.
+//
+// Precondition: the caller must hold the syncer's lock (syncer.mu).
+func (syncer *DefaultSyncer) updateHead(ctx context.Context, parent, next types.TipSet) error {
+	head := syncer.chainStore.GetHead()
 	nextParentSt, err := syncer.tipSetState(ctx, parent.ToSortedCidSet()) // call again to get a copy
 	if err != nil {
 		return err
@@ -261,16 +487,25 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 		return err
 	}
 	var headParentSt state.Tree
+	// extendsCheckpoint is true if next is a child of a head synced from a
+	// checkpoint, whose weight can't be computed without the state of its
+	// parent.  A child is always heavier than its parent.
+	extendsCheckpoint := false
 	if headParentCids.Len() != 0 { // head is not genesis
 		headParentSt, err = syncer.tipSetState(ctx, headParentCids)
-		if err != nil {
+		if errors.Cause(err) == ErrStateDeferred && parent.ToSortedCidSet().Equals(head) {
+			extendsCheckpoint = true
+		} else if err != nil {
 			return err
 		}
 	}
 
-	heavier, err := syncer.consensus.IsHeavier(ctx, next, *headTipSet, nextParentSt, headParentSt)
-	if err != nil {
-		return err
+	heavier := extendsCheckpoint
+	if !heavier {
+		heavier, err = syncer.consensus.IsHeavier(ctx, next, *headTipSet, nextParentSt, headParentSt)
+		if err != nil {
+			return err
+		}
 	}
 
 	if heavier {
@@ -283,6 +518,9 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 		}
 		newChain = append(newChain, next)
 		if IsReorg(*headTipSet, newChain) {
+			if err := syncer.checkReorgDepth(ctx, *headTipSet, next); err != nil {
+				return err
+			}
 			logSyncer.Infof("reorg occurring while switching from %s to %s", headTipSet.String(), next.String())
 		}
 		if err = syncer.chainStore.SetHead(ctx, next); err != nil {
@@ -343,6 +581,20 @@ func (syncer *DefaultSyncer) widen(ctx context.Context, ts types.TipSet) (types.
 	return wts, nil
 }
 
+// headHeight returns the height of the store's head, or zero if it can't be
+// read.
+func (syncer *DefaultSyncer) headHeight() uint64 {
+	head, err := syncer.chainStore.GetTipSet(syncer.chainStore.GetHead())
+	if err != nil {
+		return 0
+	}
+	height, err := head.Height()
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
 // HandleNewTipset extends the Syncer's chain store with the given tipset if they
 // represent a valid extension. It limits the length of new chains it will
 // attempt to validate and caches invalid blocks it has encountered to
@@ -359,15 +611,29 @@ func (syncer *DefaultSyncer) HandleNewTipset(ctx context.Context, tipsetCids typ
 	syncer.mu.Lock()
 	defer syncer.mu.Unlock()
 
-	// If the store already has all these blocks the syncer is finished.
+	// If the store already has all these blocks the syncer is finished,
+	// unless it is forced to switch to a tipset it rejected as too deep a
+	// reorg.
 	if syncer.chainStore.HasAllBlocks(ctx, tipsetCids.ToSlice()) {
+		if reorgLimitOverridden(ctx) && syncer.chainStore.HasTipSetAndState(ctx, tipsetCids.String()) {
+			return syncer.reconsiderHead(ctx, tipsetCids)
+		}
 		return nil
 	}
 
+	syncer.status.start(tipsetCids, syncer.headHeight())
+	defer func() { syncer.status.finish(err) }()
+
 	// Walk the chain given by the input blocks back to a known tipset in
 	// the store. This is the only code that may go to the network to
-	// resolve cids to blocks.
-	chain, err := syncer.collectChain(ctx, tipsetCids)
+	// resolve cids to blocks, within a fetch session ending with it.
+	collectCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if sf, ok := syncer.fetcher.(sessionFetcher); ok {
+		collectCtx = sf.WithSession(collectCtx)
+	}
+	chain, err := syncer.collectChain(collectCtx, tipsetCids)
+	cancel()
 	if err != nil {
 		return err
 	}
@@ -381,9 +647,36 @@ func (syncer *DefaultSyncer) HandleNewTipset(ctx context.Context, tipsetCids typ
 	}
 	parent := *parentTs
 
+	// Sync the chain up to the checkpoint, if it includes it, without
+	// running messages.
+	if cp := syncer.pendingCheckpoint(ctx); cp != nil {
+		parent, chain, err = syncer.syncToCheckpoint(ctx, cp, parent, chain)
+		if err != nil {
+			return err
+		}
+	}
+
+	baseHeight, err := parent.Height()
+	if err != nil {
+		return err
+	}
+	syncer.status.validating(baseHeight)
+
+	// Validate the tipsets of the chain ahead of running their state
+	// transitions, so that checking signatures overlaps running messages.
+	validateCtx, cancelValidation := context.WithCancel(ctx)
+	defer cancelValidation()
+	validated := syncer.validateChain(validateCtx, chain)
+
 	// Try adding the tipsets of the chain to the store, checking for new
 	// heaviest tipsets.
 	for i, ts := range chain {
+		if err := <-validated[i]; err != nil {
+			if errors.Cause(err) == ErrInvalidTipSet {
+				syncer.badTipSets.AddChain(chain[i:])
+			}
+			return err
+		}
 		// TODO: this "i==0" leaks EC specifics into syncer abstraction
 		// for the sake of efficiency, consider plugging up this leak.
 		if i == 0 {
@@ -405,9 +698,18 @@ func (syncer *DefaultSyncer) HandleNewTipset(ctx context.Context, tipsetCids typ
 			// have access to the chain. If syncOne fails for non-consensus reasons,
 			// there is no assumption that the running node's data is valid at all,
 			// so we don't really lose anything with this simplification.
-			syncer.badTipSets.AddChain(chain[i:])
+			// Chains rejected as too deep a reorg are valid, and may be
+			// forced by the operator.
+			if errors.Cause(err) != ErrReorgTooDeep {
+				syncer.badTipSets.AddChain(chain[i:])
+			}
+			return err
+		}
+		height, err := ts.Height()
+		if err != nil {
 			return err
 		}
+		syncer.status.synced(height)
 		if i%500 == 0 {
 			logSyncer.Infof("processing block %d of %v for chain with head at %v", i, len(chain), tipsetCids.String())
 		}
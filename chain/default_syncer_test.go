@@ -1,13 +1,17 @@
 package chain_test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-hamt-ipld"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/address"
@@ -447,6 +451,196 @@ func TestSyncChainHead(t *testing.T) {
 	assertHead(t, chainStore, link4)
 }
 
+// Syncer checks the chain it fetches against the weight claimed for its head.
+func TestSyncChainHeadWithClaimedWeight(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	weight, err := link4.ParentWeight()
+	require.NoError(t, err)
+
+	t.Run("syncs a chain consistent with the claim", func(t *testing.T) {
+		syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+		ctx := chain.WithClaimedWeight(context.Background(), weight)
+
+		_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+		_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+		_ = requirePutBlocks(t, blockSource, link3.ToSlice()...)
+		cids4 := requirePutBlocks(t, blockSource, link4.ToSlice()...)
+
+		require.NoError(t, syncer.HandleNewTipset(ctx, cids4))
+		assertHead(t, chainStore, link4)
+	})
+
+	t.Run("stops at a head inconsistent with the claim", func(t *testing.T) {
+		syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+		ctx := chain.WithClaimedWeight(context.Background(), weight+100000)
+
+		_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+		_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+		_ = requirePutBlocks(t, blockSource, link3.ToSlice()...)
+		cids4 := requirePutBlocks(t, blockSource, link4.ToSlice()...)
+
+		err := syncer.HandleNewTipset(ctx, cids4)
+		assert.Equal(t, chain.ErrInconsistentWeight, errors.Cause(err))
+		assertHead(t, chainStore, genTS)
+	})
+}
+
+// Syncer reports the status of its last sync.
+func TestSyncStatus(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	syncer, _, _, blockSource := initSyncTestDefault(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	status := syncer.Status()
+	assert.Equal(t, chain.SyncIdle, status.Stage)
+	assert.True(t, status.Target.Empty())
+	updates := syncer.SubscribeStatus(ctx)
+	assert.Equal(t, status, <-updates)
+
+	_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, link3.ToSlice()...)
+	cids4 := requirePutBlocks(t, blockSource, link4.ToSlice()...)
+	require.NoError(t, syncer.HandleNewTipset(ctx, cids4))
+
+	height, err := link4.Height()
+	require.NoError(t, err)
+	status = syncer.Status()
+	assert.Equal(t, chain.SyncIdle, status.Stage)
+	assert.Equal(t, cids4, status.Target)
+	assert.Equal(t, height, status.TargetHeight)
+	assert.Equal(t, height, status.Height)
+	assert.Equal(t, uint64(0), status.BaseHeight)
+	assert.Equal(t, "", status.Error)
+	assert.Equal(t, status, <-updates)
+}
+
+// Syncer syncs from a checkpoint without computing the state below it.
+func TestSyncFromCheckpoint(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	t.Run("syncs the chain through the checkpoint", func(t *testing.T) {
+		syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+		ctx := context.Background()
+		syncer.SetCheckpoint(&chain.Checkpoint{TipSet: link2.ToSortedCidSet(), StateRoot: link2State})
+
+		_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+		_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+		_ = requirePutBlocks(t, blockSource, link3.ToSlice()...)
+		cids4 := requirePutBlocks(t, blockSource, link4.ToSlice()...)
+
+		require.NoError(t, syncer.HandleNewTipset(ctx, cids4))
+		assertTsAdded(t, chainStore, link1)
+		assertTsAdded(t, chainStore, link2)
+		assertTsAdded(t, chainStore, link4)
+		assertHead(t, chainStore, link4)
+
+		// The state below the checkpoint is not computed.
+		_, err := chainStore.GetTipSetStateRoot(link1.ToSortedCidSet())
+		assert.Equal(t, chain.ErrStateDeferred, errors.Cause(err))
+		assert.Equal(t, link2State, requireGetTipSetStateRoot(ctx, t, chainStore, link2.ToSortedCidSet()))
+	})
+
+	t.Run("rejects a chain without the checkpoint", func(t *testing.T) {
+		syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+		ctx := context.Background()
+		other := th.RequireNewTipSet(t, link2blk1)
+		syncer.SetCheckpoint(&chain.Checkpoint{TipSet: other.ToSortedCidSet(), StateRoot: link2State})
+
+		_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+		_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+		cids3 := requirePutBlocks(t, blockSource, link3.ToSlice()...)
+
+		err := syncer.HandleNewTipset(ctx, cids3)
+		assert.Equal(t, chain.ErrCheckpointMismatch, errors.Cause(err))
+		assertHead(t, chainStore, genTS)
+	})
+}
+
+// Syncer runs the tipsets of a chain up to the first one failing validation.
+func TestSyncValidatesChain(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+	ctx := context.Background()
+
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
+	msg := types.NewSignedMessageForTestGetter(mockSigner)()
+	msg.Signature = []byte("not a signature")
+	invalid := th.RequireMkFakeChild(t, th.FakeChildParams{
+		Parent:      link2,
+		GenesisCid:  genCid,
+		StateRoot:   link2State,
+		MinerAddr:   minerAddress,
+		MinerPubKey: mockSigner.PubKeys[0],
+		Signer:      mockSigner,
+	})
+	invalid.Messages = []*types.SignedMessage{msg}
+
+	_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+	invalidCids := requirePutBlocks(t, blockSource, invalid)
+
+	err := syncer.HandleNewTipset(ctx, invalidCids)
+	assert.Equal(t, chain.ErrInvalidTipSet, errors.Cause(err))
+	assertTsAdded(t, chainStore, link2)
+	assertHead(t, chainStore, link2)
+	assertNoAdd(t, chainStore, invalidCids)
+
+	// The invalid tipset is remembered.
+	err = syncer.HandleNewTipset(ctx, invalidCids)
+	assert.Equal(t, chain.ErrChainHasBadTipSet, errors.Cause(err))
+}
+
+// Syncer rejects blocks timestamped too far in the future, without marking
+// them bad, and blocks timestamped before the epoch of their height.
+func TestSyncChecksTimestamps(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+	ctx := context.Background()
+	now := time.Now()
+	genesisTime := now.Add(-time.Hour)
+	syncer.SetTimestampRules(&consensus.TimestampRules{
+		GenesisTime:    uint64(genesisTime.Unix()),
+		BlockTime:      time.Minute,
+		MaxFutureDrift: 10 * time.Second,
+	})
+
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
+	_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+	newChild := func(timestamp time.Time) *types.Block {
+		blk := th.RequireMkFakeChild(t, th.FakeChildParams{
+			Parent:      link2,
+			GenesisCid:  genCid,
+			StateRoot:   link2State,
+			MinerAddr:   minerAddress,
+			MinerPubKey: mockSigner.PubKeys[0],
+			Signer:      mockSigner,
+		})
+		blk.Timestamp = types.Uint64(timestamp.Unix())
+		return blk
+	}
+
+	futureCids := requirePutBlocks(t, blockSource, newChild(now.Add(time.Hour)))
+	err := syncer.HandleNewTipset(ctx, futureCids)
+	require.Error(t, err)
+	assert.NotEqual(t, chain.ErrInvalidTipSet, errors.Cause(err))
+	err = syncer.HandleNewTipset(ctx, futureCids)
+	assert.NotEqual(t, chain.ErrChainHasBadTipSet, errors.Cause(err))
+	assertNoAdd(t, chainStore, futureCids)
+
+	// The child is at height 3, whose epoch starts 3 minutes after genesis.
+	earlyCids := requirePutBlocks(t, blockSource, newChild(genesisTime.Add(time.Minute)))
+	err = syncer.HandleNewTipset(ctx, earlyCids)
+	assert.Equal(t, chain.ErrInvalidTipSet, errors.Cause(err))
+	assertNoAdd(t, chainStore, earlyCids)
+}
+
 // Syncer determines the heavier fork.
 func TestSyncIgnoreLightFork(t *testing.T) {
 	tf.BadUnitTestWithSideEffects(t)
@@ -489,12 +683,9 @@ func TestSyncIgnoreLightFork(t *testing.T) {
 }
 
 // Correctly sync a heavier fork
-func TestHeavierFork(t *testing.T) {
-	tf.BadUnitTestWithSideEffects(t)
-
-	syncer, chainStore, _, blockSource := initSyncTestDefault(t)
-	ctx := context.Background()
-
+// requireMkHeavierFork returns the tipsets of a fork of the test chain from
+// link1 that is heavier than link4.
+func requireMkHeavierFork(t *testing.T) (forklink1, forklink2, forklink3 types.TipSet) {
 	signer, ki := types.NewMockSignersAndKeyInfo(2)
 	mockSignerPubKey := ki[0].PublicKey()
 
@@ -517,7 +708,7 @@ func TestHeavierFork(t *testing.T) {
 	fakeChildParams.Nonce = uint64(2)
 	forklink1blk3 := th.RequireMkFakeChild(t, fakeChildParams)
 
-	forklink1 := th.RequireNewTipSet(t, forklink1blk1, forklink1blk2, forklink1blk3)
+	forklink1 = th.RequireNewTipSet(t, forklink1blk1, forklink1blk2, forklink1blk3)
 
 	fakeChildParams.Parent = forklink1
 	fakeChildParams.Nonce = uint64(0)
@@ -528,7 +719,7 @@ func TestHeavierFork(t *testing.T) {
 
 	fakeChildParams.Nonce = uint64(2)
 	forklink2blk3 := th.RequireMkFakeChild(t, fakeChildParams)
-	forklink2 := th.RequireNewTipSet(t, forklink2blk1, forklink2blk2, forklink2blk3)
+	forklink2 = th.RequireNewTipSet(t, forklink2blk1, forklink2blk2, forklink2blk3)
 
 	fakeChildParams.Nonce = uint64(0)
 	fakeChildParams.Parent = forklink2
@@ -536,7 +727,17 @@ func TestHeavierFork(t *testing.T) {
 
 	fakeChildParams.Nonce = uint64(1)
 	forklink3blk2 := th.RequireMkFakeChild(t, fakeChildParams)
-	forklink3 := th.RequireNewTipSet(t, forklink3blk1, forklink3blk2)
+	forklink3 = th.RequireNewTipSet(t, forklink3blk1, forklink3blk2)
+	return forklink1, forklink2, forklink3
+}
+
+func TestHeavierFork(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+	ctx := context.Background()
+
+	forklink1, forklink2, forklink3 := requireMkHeavierFork(t)
 
 	_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
 	_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
@@ -560,6 +761,93 @@ func TestHeavierFork(t *testing.T) {
 	assertHead(t, chainStore, forklink3)
 }
 
+// Syncer settles on the same head among forks of equal weight whatever order
+// it receives them in.
+func TestEqualWeightForks(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	for _, reversed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reversed %t", reversed), func(t *testing.T) {
+			syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+			ctx := context.Background()
+
+			// Children of link4 at different heights are not merged into a
+			// single tipset, and have the same weight.
+			signer, ki := types.NewMockSignersAndKeyInfo(1)
+			fakeChildParams := th.FakeChildParams{
+				Parent:      link4,
+				GenesisCid:  genCid,
+				StateRoot:   genStateRoot,
+				MinerAddr:   minerAddress,
+				Signer:      signer,
+				MinerPubKey: ki[0].PublicKey(),
+			}
+			fork1 := th.RequireNewTipSet(t, th.RequireMkFakeChild(t, fakeChildParams))
+			fakeChildParams.NullBlockCount = 1
+			fork2 := th.RequireNewTipSet(t, th.RequireMkFakeChild(t, fakeChildParams))
+
+			// The smallest ticket wins, then the smallest cids.
+			winner := fork1
+			ticket1, err := fork1.MinTicket()
+			require.NoError(t, err)
+			ticket2, err := fork2.MinTicket()
+			require.NoError(t, err)
+			cmp := bytes.Compare(ticket1, ticket2)
+			if cmp == 0 {
+				cmp = fork1.ToSortedCidSet().Compare(fork2.ToSortedCidSet())
+			}
+			if cmp > 0 {
+				winner = fork2
+			}
+
+			_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+			_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+			_ = requirePutBlocks(t, blockSource, link3.ToSlice()...)
+			_ = requirePutBlocks(t, blockSource, link4.ToSlice()...)
+			first := requirePutBlocks(t, blockSource, fork1.ToSlice()...)
+			second := requirePutBlocks(t, blockSource, fork2.ToSlice()...)
+			if reversed {
+				first, second = second, first
+			}
+
+			require.NoError(t, syncer.HandleNewTipset(ctx, first))
+			require.NoError(t, syncer.HandleNewTipset(ctx, second))
+			assertTsAdded(t, chainStore, fork1)
+			assertTsAdded(t, chainStore, fork2)
+			assertHead(t, chainStore, winner)
+		})
+	}
+}
+
+// Syncer rejects a heavier fork deeper than the reorg depth limit unless forced.
+func TestReorgDepthLimit(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	syncer, chainStore, _, blockSource := initSyncTestDefault(t)
+	ctx := context.Background()
+	syncer.SetMaxReorgDepth(1)
+
+	forklink1, forklink2, forklink3 := requireMkHeavierFork(t)
+	_ = requirePutBlocks(t, blockSource, link1.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, link2.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, link3.ToSlice()...)
+	cids4 := requirePutBlocks(t, blockSource, link4.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, forklink1.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, forklink2.ToSlice()...)
+	forkHead := requirePutBlocks(t, blockSource, forklink3.ToSlice()...)
+
+	require.NoError(t, syncer.HandleNewTipset(ctx, cids4))
+	assertHead(t, chainStore, link4)
+
+	err := syncer.HandleNewTipset(ctx, forkHead)
+	assert.Equal(t, chain.ErrReorgTooDeep, errors.Cause(err))
+	assertHead(t, chainStore, link4)
+
+	require.NoError(t, syncer.HandleNewTipset(chain.WithoutReorgLimit(ctx), forkHead))
+	assertTsAdded(t, chainStore, forklink3)
+	assertHead(t, chainStore, forklink3)
+}
+
 // Syncer errors if blocks don't form a tipset
 func TestBlocksNotATipSet(t *testing.T) {
 	tf.BadUnitTestWithSideEffects(t)
@@ -777,12 +1065,12 @@ func TestWidenChainAncestor(t *testing.T) {
 
 type powerTableForWidenTest struct{}
 
-func (pt *powerTableForWidenTest) Total(ctx context.Context, st state.Tree, bs bstore.Blockstore) (uint64, error) {
-	return uint64(100), nil
+func (pt *powerTableForWidenTest) Total(ctx context.Context, st state.Tree, bs bstore.Blockstore) (*types.BytesAmount, error) {
+	return types.NewBytesAmount(100), nil
 }
 
-func (pt *powerTableForWidenTest) Miner(ctx context.Context, st state.Tree, bs bstore.Blockstore, mAddr address.Address) (uint64, error) {
-	return uint64(25), nil
+func (pt *powerTableForWidenTest) Miner(ctx context.Context, st state.Tree, bs bstore.Blockstore, mAddr address.Address) (*types.BytesAmount, error) {
+	return types.NewBytesAmount(25), nil
 }
 
 func (pt *powerTableForWidenTest) HasPower(ctx context.Context, st state.Tree, bs bstore.Blockstore, mAddr address.Address) bool {
@@ -0,0 +1,440 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var (
+	// ErrMessageIndexStale is returned when the message index has not caught
+	// up with the head of the chain and cannot answer a lookup authoritatively.
+	ErrMessageIndexStale = errors.New("message index is behind the chain head")
+)
+
+// msgIndexHeadKey is the key at which the tipset the index was last updated
+// to is written in the datastore.
+var msgIndexHeadKey = datastore.NewKey("/chain/msgIndexHead")
+
+// msgIndexPrefix is the datastore namespace holding message locations.
+var msgIndexPrefix = datastore.NewKey("/chain/msgIndex")
+
+// msgAddrIndexPrefix is the datastore namespace holding the messages sent
+// and received by each address, keyed by address, height and message cid.
+var msgAddrIndexPrefix = datastore.NewKey("/chain/msgAddrIndex")
+
+// msgAddrIndexedKey is written once the index also indexes messages by
+// address.  Indexes written before that are rebuilt on their next update.
+var msgAddrIndexedKey = datastore.NewKey("/chain/msgAddrIndexed")
+
+// msgReceiptsIndexedKey is written once the index also records the receipts
+// of messages.  Indexes written before that are rebuilt on their next update.
+var msgReceiptsIndexedKey = datastore.NewKey("/chain/msgReceiptsIndexed")
+
+// ReceiptsFunc returns the receipts of the messages applied by ts, keyed by
+// message cid.  Messages of ts that were not applied have no receipt.
+type ReceiptsFunc func(ctx context.Context, ts types.TipSet) (map[cid.Cid]*types.MessageReceipt, error)
+
+// MessageLocation records where a message was included on the heaviest chain.
+type MessageLocation struct {
+	// BlockCid is the cid of the first block, in canonical message order,
+	// that included the message.
+	BlockCid cid.Cid `json:"blockCid"`
+	// TipSet is the key of the tipset that included the message.
+	TipSet types.SortedCidSet `json:"tipSet"`
+	// Height is the height of the tipset that included the message.
+	Height uint64 `json:"height"`
+	// HasReceipt is true if the index recorded the outcome of the message,
+	// which it does when it has a ReceiptsFunc.
+	HasReceipt bool `json:"hasReceipt"`
+	// Receipt is the receipt of the message as applied by its tipset.  It
+	// is nil if the message was included but not applied, e.g. because it
+	// conflicted with another message of the tipset.
+	Receipt *types.MessageReceipt `json:"receipt,omitempty"`
+}
+
+// AddressMessage is a message sent or received by an address, as returned by
+// MessageIndex.AddressMessages.
+type AddressMessage struct {
+	Cid    cid.Cid `json:"cid"`
+	Height uint64  `json:"height"`
+}
+
+// MessageIndex is a persistent secondary index from message cid to the
+// location of the message on the heaviest chain.  It is updated each time the
+// chain head changes: tipsets leaving the heaviest chain are unindexed and
+// tipsets joining it are indexed, so lookups never need to walk the chain.
+type MessageIndex struct {
+	ds repo.Datastore
+	// Serializes updates so that head and entries stay consistent.
+	mu sync.Mutex
+	// receipts computes the receipts recorded with the locations, nil if
+	// they aren't recorded.  Protected by mu.
+	receipts ReceiptsFunc
+}
+
+// NewMessageIndex returns a MessageIndex persisting to the given datastore.
+func NewMessageIndex(ds repo.Datastore) *MessageIndex {
+	return &MessageIndex{ds: ds}
+}
+
+// SetReceiptsFunc makes the index record the receipts fn returns with the
+// locations of messages indexed from now on.  Messages already indexed are
+// indexed again on the next update.
+func (mi *MessageIndex) SetReceiptsFunc(fn ReceiptsFunc) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.receipts = fn
+}
+
+// Get returns the location of the message with the given cid on the chain
+// ending at the index head, and whether the message was found.
+func (mi *MessageIndex) Get(msgCid cid.Cid) (*MessageLocation, bool, error) {
+	bb, err := mi.ds.Get(msgIndexKey(msgCid))
+	if err == datastore.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to read message index entry for %s", msgCid)
+	}
+
+	var loc MessageLocation
+	if err := json.Unmarshal(bb, &loc); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to decode message index entry for %s", msgCid)
+	}
+	return &loc, true, nil
+}
+
+// AddressMessages returns the messages on the chain ending at the index head
+// that addr sent or received, newest first.
+func (mi *MessageIndex) AddressMessages(addr address.Address) ([]AddressMessage, error) {
+	results, err := mi.ds.Query(query.Query{
+		Prefix:   msgAddrIndexPrefix.ChildString(addr.String()).String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query messages of %s", addr)
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read messages of %s", addr)
+	}
+
+	msgs := make([]AddressMessage, 0, len(entries))
+	for _, e := range entries {
+		// Keys end with /<height>/<cid>.
+		ns := datastore.NewKey(e.Key).Namespaces()
+		if len(ns) < 2 {
+			return nil, errors.Errorf("invalid message index key %s", e.Key)
+		}
+		h, err := strconv.ParseUint(ns[len(ns)-2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid message index key %s", e.Key)
+		}
+		c, err := cid.Decode(ns[len(ns)-1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid message index key %s", e.Key)
+		}
+		msgs = append(msgs, AddressMessage{Cid: c, Height: h})
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].Height != msgs[j].Height {
+			return msgs[i].Height > msgs[j].Height
+		}
+		return msgs[i].Cid.String() < msgs[j].Cid.String()
+	})
+	return msgs, nil
+}
+
+// Head returns the key of the tipset the index was last updated to.  The key
+// is empty if the index has never been updated.
+func (mi *MessageIndex) Head() (types.SortedCidSet, error) {
+	bb, err := mi.ds.Get(msgIndexHeadKey)
+	if err == datastore.ErrNotFound {
+		return types.SortedCidSet{}, nil
+	}
+	if err != nil {
+		return types.SortedCidSet{}, errors.Wrap(err, "failed to read message index head")
+	}
+
+	var head types.SortedCidSet
+	if err := json.Unmarshal(bb, &head); err != nil {
+		return types.SortedCidSet{}, errors.Wrap(err, "failed to decode message index head")
+	}
+	return head, nil
+}
+
+// Update moves the index from its current head to newHead.  Messages of
+// tipsets that are no longer ancestors of newHead are removed and messages of
+// new ancestors are added.  If the index has never been updated the whole
+// chain ending at newHead is indexed.  All writes are committed in a single
+// batch together with the new index head.
+func (mi *MessageIndex) Update(ctx context.Context, provider BlockProvider, newHead types.TipSet) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return err
+	}
+	if err := mi.stageUpdate(ctx, provider, newHead, batch); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// stageUpdate adds the writes moving the index to newHead to batch without
+// committing it.  The caller must hold mi.mu until the batch is committed or
+// discarded.
+func (mi *MessageIndex) stageUpdate(ctx context.Context, provider BlockProvider, newHead types.TipSet, batch datastore.Batch) error {
+	oldKey, err := mi.Head()
+	if err != nil {
+		return err
+	}
+	newKey := newHead.ToSortedCidSet()
+
+	// Track pending writes since the batch is not visible until committed.
+	deleted := make(map[cid.Cid]struct{})
+	written := make(map[cid.Cid]struct{})
+
+	if !oldKey.Empty() {
+		indexed, err := mi.indexed()
+		if err != nil {
+			return err
+		}
+		if !indexed {
+			if err := mi.stageReset(batch, deleted); err != nil {
+				return errors.Wrap(err, "failed to reset message index")
+			}
+			oldKey = types.SortedCidSet{}
+		}
+	}
+	if oldKey.Equals(newKey) {
+		return nil
+	}
+
+	var stop types.TipSet
+	var removed []types.TipSet
+	if !oldKey.Empty() {
+		oldHead, err := loadTipSet(ctx, provider, oldKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to load message index head")
+		}
+		stop, err = FindCommonAncestor(IterAncestors(ctx, provider, oldHead), IterAncestors(ctx, provider, newHead))
+		if err != nil {
+			return err
+		}
+		removed, err = collectAncestorsUntil(ctx, provider, oldHead, stop)
+		if err != nil {
+			return err
+		}
+	}
+	added, err := collectAncestorsUntil(ctx, provider, newHead, stop)
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range removed {
+		tsKey := ts.ToSortedCidSet()
+		err := forEachTipSetMessage(ts, func(blk *types.Block, msg *types.SignedMessage, msgCid cid.Cid) error {
+			loc, found, err := mi.Get(msgCid)
+			if err != nil || !found || !loc.TipSet.Equals(tsKey) {
+				return err
+			}
+			deleted[msgCid] = struct{}{}
+			for _, addr := range []address.Address{msg.From, msg.To} {
+				if err := batch.Delete(msgAddrIndexKey(addr, loc.Height, msgCid)); err != nil {
+					return err
+				}
+			}
+			return batch.Delete(msgIndexKey(msgCid))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Index oldest first so that the earliest inclusion of a message wins.
+	for i := len(added) - 1; i >= 0; i-- {
+		ts := added[i]
+		h, err := ts.Height()
+		if err != nil {
+			return err
+		}
+		tsKey := ts.ToSortedCidSet()
+		var receipts map[cid.Cid]*types.MessageReceipt
+		if mi.receipts != nil {
+			if receipts, err = mi.receipts(ctx, ts); err != nil {
+				return errors.Wrapf(err, "failed to get receipts of tipset %s", tsKey.String())
+			}
+		}
+		err = forEachTipSetMessage(ts, func(blk *types.Block, msg *types.SignedMessage, msgCid cid.Cid) error {
+			if _, ok := written[msgCid]; ok {
+				return nil
+			}
+			if _, ok := deleted[msgCid]; !ok {
+				has, err := mi.ds.Has(msgIndexKey(msgCid))
+				if err != nil || has {
+					return err
+				}
+			}
+			val, err := json.Marshal(&MessageLocation{
+				BlockCid:   blk.Cid(),
+				TipSet:     tsKey,
+				Height:     h,
+				HasReceipt: mi.receipts != nil,
+				Receipt:    receipts[msgCid],
+			})
+			if err != nil {
+				return err
+			}
+			written[msgCid] = struct{}{}
+			for _, addr := range []address.Address{msg.From, msg.To} {
+				if err := batch.Put(msgAddrIndexKey(addr, h, msgCid), []byte{}); err != nil {
+					return err
+				}
+			}
+			return batch.Put(msgIndexKey(msgCid), val)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := batch.Put(msgAddrIndexedKey, []byte{1}); err != nil {
+		return err
+	}
+	if mi.receipts != nil {
+		err = batch.Put(msgReceiptsIndexedKey, []byte{1})
+	} else {
+		err = batch.Delete(msgReceiptsIndexedKey)
+	}
+	if err != nil {
+		return err
+	}
+	val, err := json.Marshal(newKey)
+	if err != nil {
+		return err
+	}
+	return batch.Put(msgIndexHeadKey, val)
+}
+
+// indexed returns whether the index holds all the data the index records now.
+func (mi *MessageIndex) indexed() (bool, error) {
+	indexed, err := mi.ds.Has(msgAddrIndexedKey)
+	if err != nil || !indexed || mi.receipts == nil {
+		return indexed, err
+	}
+	return mi.ds.Has(msgReceiptsIndexedKey)
+}
+
+// stageReset adds the deletion of all message locations to batch, recording
+// the messages deleted in deleted, so that the whole chain is indexed again.
+func (mi *MessageIndex) stageReset(batch datastore.Batch, deleted map[cid.Cid]struct{}) error {
+	results, err := mi.ds.Query(query.Query{Prefix: msgIndexPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		k := datastore.NewKey(e.Key)
+		// The prefix also matches the index head.
+		if !k.Parent().Equal(msgIndexPrefix) {
+			continue
+		}
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			return errors.Wrapf(err, "invalid message index key %s", e.Key)
+		}
+		deleted[c] = struct{}{}
+		if err := batch.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgIndexKey returns the datastore key of the index entry for msgCid.
+func msgIndexKey(msgCid cid.Cid) datastore.Key {
+	return msgIndexPrefix.ChildString(msgCid.String())
+}
+
+// msgAddrIndexKey returns the datastore key of the entry for msgCid, included
+// at height, in the messages of addr.  Heights are zero padded so that keys
+// sort by height.
+func msgAddrIndexKey(addr address.Address, height uint64, msgCid cid.Cid) datastore.Key {
+	return msgAddrIndexPrefix.ChildString(addr.String()).ChildString(fmt.Sprintf("%020d", height)).ChildString(msgCid.String())
+}
+
+// forEachTipSetMessage calls cb with every message of the tipset and its cid
+// in canonical message order along with the block containing it.
+func forEachTipSetMessage(ts types.TipSet, cb func(*types.Block, *types.SignedMessage, cid.Cid) error) error {
+	blks := ts.ToSlice()
+	types.SortBlocks(blks)
+	for _, blk := range blks {
+		for _, msg := range blk.Messages {
+			c, err := msg.Cid()
+			if err != nil {
+				return err
+			}
+			if err := cb(blk, msg, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadTipSet assembles the tipset with the given key from its blocks.
+func loadTipSet(ctx context.Context, provider BlockProvider, key types.SortedCidSet) (types.TipSet, error) {
+	if loader, ok := provider.(tipSetLoader); ok {
+		return loader.LoadTipSet(ctx, key)
+	}
+	ts := types.TipSet{}
+	for it := key.Iter(); !it.Complete(); it.Next() {
+		blk, err := provider.GetBlock(ctx, it.Value())
+		if err != nil {
+			return nil, err
+		}
+		if err := ts.AddBlock(blk); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
+// collectAncestorsUntil returns start and its ancestors, newest first, up to
+// but excluding stop.  If stop is empty all ancestors including genesis are
+// returned.
+func collectAncestorsUntil(ctx context.Context, provider BlockProvider, start, stop types.TipSet) ([]types.TipSet, error) {
+	var ret []types.TipSet
+	var err error
+	for iterator := IterAncestors(ctx, provider, start); !iterator.Complete(); err = iterator.Next() {
+		if err != nil {
+			return nil, err
+		}
+		if len(stop) > 0 && iterator.Value().Equals(stop) {
+			break
+		}
+		ret = append(ret, iterator.Value())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,174 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/repo"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessageIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	newMsg := types.NewSignedMessageForTestGetter(signer)
+
+	requireCid := func(t *testing.T, msg *types.SignedMessage) cid.Cid {
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("indexes the whole chain on first update", func(t *testing.T) {
+		store := th.NewFakeBlockProvider()
+		m1, m2 := newMsg(), newMsg()
+		root := store.NewBlock(0)
+		b1 := store.NewBlockWithMessages(1, []*types.SignedMessage{m1}, root)
+		b2 := store.NewBlockWithMessages(2, []*types.SignedMessage{m2}, b1)
+
+		index := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore())
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, b2)))
+
+		loc, found, err := index.Get(requireCid(t, m1))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, b1.Cid(), loc.BlockCid)
+		assert.True(t, requireTipset(t, b1).ToSortedCidSet().Equals(loc.TipSet))
+		assert.Equal(t, uint64(1), loc.Height)
+
+		loc, found, err = index.Get(requireCid(t, m2))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, b2.Cid(), loc.BlockCid)
+
+		head, err := index.Head()
+		require.NoError(t, err)
+		assert.True(t, requireTipset(t, b2).ToSortedCidSet().Equals(head))
+	})
+
+	t.Run("first inclusion wins", func(t *testing.T) {
+		store := th.NewFakeBlockProvider()
+		m1 := newMsg()
+		root := store.NewBlock(0)
+		b1 := store.NewBlockWithMessages(1, []*types.SignedMessage{m1}, root)
+		b2 := store.NewBlockWithMessages(2, []*types.SignedMessage{m1}, b1)
+
+		index := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore())
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, b2)))
+
+		loc, found, err := index.Get(requireCid(t, m1))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, b1.Cid(), loc.BlockCid)
+	})
+
+	t.Run("reorg moves messages between forks", func(t *testing.T) {
+		store := th.NewFakeBlockProvider()
+		mShared, mLeft, mRight := newMsg(), newMsg(), newMsg()
+		root := store.NewBlock(0)
+		left := store.NewBlockWithMessages(1, []*types.SignedMessage{mShared, mLeft}, root)
+		right1 := store.NewBlockWithMessages(2, []*types.SignedMessage{mRight}, root)
+		right2 := store.NewBlockWithMessages(3, []*types.SignedMessage{mShared}, right1)
+
+		index := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore())
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, left)))
+		_, found, err := index.Get(requireCid(t, mLeft))
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, right2)))
+
+		_, found, err = index.Get(requireCid(t, mLeft))
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		loc, found, err := index.Get(requireCid(t, mShared))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, right2.Cid(), loc.BlockCid)
+
+		loc, found, err = index.Get(requireCid(t, mRight))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, right1.Cid(), loc.BlockCid)
+	})
+
+	t.Run("indexes messages by address", func(t *testing.T) {
+		store := th.NewFakeBlockProvider()
+		m1, m2, m3 := newMsg(), newMsg(), newMsg()
+		root := store.NewBlock(0)
+		b1 := store.NewBlockWithMessages(1, []*types.SignedMessage{m1}, root)
+		left := store.NewBlockWithMessages(2, []*types.SignedMessage{m2}, b1)
+		right := store.NewBlockWithMessages(3, []*types.SignedMessage{m3}, b1)
+
+		index := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore())
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, left)))
+
+		sent, err := index.AddressMessages(m1.From)
+		require.NoError(t, err)
+		assert.Equal(t, []chain.AddressMessage{
+			{Cid: requireCid(t, m2), Height: 2},
+			{Cid: requireCid(t, m1), Height: 1},
+		}, sent)
+		received, err := index.AddressMessages(m2.To)
+		require.NoError(t, err)
+		assert.Equal(t, []chain.AddressMessage{{Cid: requireCid(t, m2), Height: 2}}, received)
+
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, right)))
+
+		sent, err = index.AddressMessages(m1.From)
+		require.NoError(t, err)
+		assert.Equal(t, []chain.AddressMessage{
+			{Cid: requireCid(t, m3), Height: 2},
+			{Cid: requireCid(t, m1), Height: 1},
+		}, sent)
+		received, err = index.AddressMessages(m2.To)
+		require.NoError(t, err)
+		assert.Empty(t, received)
+	})
+
+	t.Run("records receipts", func(t *testing.T) {
+		store := th.NewFakeBlockProvider()
+		m1, m2 := newMsg(), newMsg()
+		root := store.NewBlock(0)
+		b1 := store.NewBlockWithMessages(1, []*types.SignedMessage{m1, m2}, root)
+
+		index := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore())
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, b1)))
+		loc, _, err := index.Get(requireCid(t, m1))
+		require.NoError(t, err)
+		assert.False(t, loc.HasReceipt)
+
+		// Setting the receipts func indexes the chain again with receipts.
+		rcpt := &types.MessageReceipt{ExitCode: 3}
+		var runs int
+		index.SetReceiptsFunc(func(ctx context.Context, ts types.TipSet) (map[cid.Cid]*types.MessageReceipt, error) {
+			runs++
+			// m2 was not applied.
+			return map[cid.Cid]*types.MessageReceipt{requireCid(t, m1): rcpt}, nil
+		})
+		require.NoError(t, index.Update(ctx, store, requireTipset(t, b1)))
+		assert.Equal(t, 2, runs)
+
+		loc, found, err := index.Get(requireCid(t, m1))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.True(t, loc.HasReceipt)
+		assert.Equal(t, rcpt, loc.Receipt)
+
+		loc, found, err = index.Get(requireCid(t, m2))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.True(t, loc.HasReceipt)
+		assert.Nil(t, loc.Receipt)
+	})
+}
@@ -0,0 +1,108 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p-peer"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// DefaultOrphanCacheSize is the number of orphan blocks kept by default.
+const DefaultOrphanCacheSize = 100
+
+// Orphan is a block received before its parents were synced, with the peer
+// that announced it.
+type Orphan struct {
+	Block *types.Block
+	From  peer.ID
+}
+
+// OrphanCache keeps blocks received while their parents are not in the
+// store, typically blocks gossiped during the initial sync, so that they can
+// be synced as soon as their parents are rather than fetched again.  It keeps
+// at most size blocks, evicting the oldest.
+type OrphanCache struct {
+	lk   sync.Mutex
+	size int
+	// byParents holds the orphans by the key of their parent tipset.
+	byParents map[string][]*Orphan
+	// order holds the orphans in the order they were added.
+	order []*Orphan
+}
+
+// NewOrphanCache returns an OrphanCache keeping at most size blocks.
+func NewOrphanCache(size int) *OrphanCache {
+	return &OrphanCache{
+		size:      size,
+		byParents: make(map[string][]*Orphan),
+	}
+}
+
+// Add keeps blk, announced by from, until its parents are synced.  It returns
+// false if blk was already kept.
+func (oc *OrphanCache) Add(blk *types.Block, from peer.ID) bool {
+	oc.lk.Lock()
+	defer oc.lk.Unlock()
+
+	key := blk.Parents.String()
+	for _, o := range oc.byParents[key] {
+		if o.Block.Cid().Equals(blk.Cid()) {
+			return false
+		}
+	}
+	if oc.size <= 0 {
+		return false
+	}
+	if len(oc.order) >= oc.size {
+		oc.remove(oc.order[0])
+	}
+
+	o := &Orphan{Block: blk, From: from}
+	oc.byParents[key] = append(oc.byParents[key], o)
+	oc.order = append(oc.order, o)
+	return true
+}
+
+// Take removes and returns the orphans whose parent tipset has key parents,
+// in the order they were added.
+func (oc *OrphanCache) Take(parents types.SortedCidSet) []*Orphan {
+	oc.lk.Lock()
+	defer oc.lk.Unlock()
+
+	orphans := oc.byParents[parents.String()]
+	for _, o := range orphans {
+		oc.remove(o)
+	}
+	return orphans
+}
+
+// Len returns the number of orphans kept.
+func (oc *OrphanCache) Len() int {
+	oc.lk.Lock()
+	defer oc.lk.Unlock()
+	return len(oc.order)
+}
+
+// remove forgets o.
+func (oc *OrphanCache) remove(o *Orphan) {
+	for i, other := range oc.order {
+		if other == o {
+			oc.order = append(oc.order[:i], oc.order[i+1:]...)
+			break
+		}
+	}
+	key := o.Block.Parents.String()
+	siblings := oc.byParents[key]
+	for i, other := range siblings {
+		if other == o {
+			siblings = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(oc.byParents, key)
+	} else {
+		oc.byParents[key] = siblings
+	}
+}
@@ -0,0 +1,59 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestOrphanCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	from, err := th.RandPeerID()
+	require.NoError(t, err)
+	parent := types.NewBlockForTest(nil, 0)
+	parentKey := types.NewSortedCidSet(parent.Cid())
+	child1 := types.NewBlockForTest(parent, 1)
+	child2 := types.NewBlockForTest(parent, 2)
+	grandchild := types.NewBlockForTest(child1, 3)
+
+	t.Run("takes orphans by parents", func(t *testing.T) {
+		oc := chain.NewOrphanCache(10)
+		assert.True(t, oc.Add(child1, from))
+		assert.True(t, oc.Add(grandchild, from))
+		assert.True(t, oc.Add(child2, from))
+		assert.False(t, oc.Add(child1, from))
+		assert.Equal(t, 3, oc.Len())
+
+		orphans := oc.Take(parentKey)
+		require.Equal(t, 2, len(orphans))
+		assert.Equal(t, child1, orphans[0].Block)
+		assert.Equal(t, child2, orphans[1].Block)
+		assert.Equal(t, from, orphans[0].From)
+		assert.Equal(t, 1, oc.Len())
+		assert.Equal(t, 0, len(oc.Take(parentKey)))
+
+		orphans = oc.Take(types.NewSortedCidSet(child1.Cid()))
+		require.Equal(t, 1, len(orphans))
+		assert.Equal(t, grandchild, orphans[0].Block)
+		assert.Equal(t, 0, oc.Len())
+	})
+
+	t.Run("evicts the oldest orphan", func(t *testing.T) {
+		oc := chain.NewOrphanCache(2)
+		oc.Add(child1, from)
+		oc.Add(child2, from)
+		oc.Add(grandchild, from)
+		assert.Equal(t, 2, oc.Len())
+
+		orphans := oc.Take(parentKey)
+		require.Equal(t, 1, len(orphans))
+		assert.Equal(t, child2, orphans[0].Block)
+	})
+}
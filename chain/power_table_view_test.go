@@ -31,7 +31,7 @@ func TestTotal(t *testing.T) {
 	actual, err := (&consensus.MarketView{}).Total(ctx, st, bs)
 	require.NoError(t, err)
 
-	assert.Equal(t, power, actual)
+	assert.Equal(t, types.NewBytesAmount(power), actual)
 }
 
 func TestMiner(t *testing.T) {
@@ -45,7 +45,7 @@ func TestMiner(t *testing.T) {
 	actual, err := (&consensus.MarketView{}).Miner(ctx, st, bs, addr)
 	require.NoError(t, err)
 
-	assert.Equal(t, power, actual)
+	assert.Equal(t, types.NewBytesAmount(power), actual)
 }
 
 func requireMinerWithPower(ctx context.Context, t *testing.T, power uint64) (bstore.Blockstore, address.Address, state.Tree) {
@@ -0,0 +1,86 @@
+package chain
+
+import (
+	"github.com/hashicorp/golang-lru"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ReadCache is an in-memory LRU cache of decoded blocks and assembled tipsets
+// sitting in front of the chain store's datastore.  Validation and ancestor
+// walks read the same recent blocks over and over; caching them avoids
+// repeatedly fetching them and computing their cids.
+//
+// The cache keeps its own copies of the blocks it is given and returns fresh
+// copies, so callers may modify the blocks they add or get.  A nil *ReadCache
+// is valid and caches nothing.
+type ReadCache struct {
+	blocks  *lru.Cache
+	tipsets *lru.Cache
+}
+
+// NewReadCache returns a cache holding up to blockSize blocks and tipSetSize
+// tipsets.  If either size is not positive the corresponding values are not
+// cached.
+func NewReadCache(blockSize, tipSetSize int) *ReadCache {
+	c := &ReadCache{}
+	if blockSize > 0 {
+		// lru.New only fails for non-positive sizes.
+		c.blocks, _ = lru.New(blockSize)
+	}
+	if tipSetSize > 0 {
+		c.tipsets, _ = lru.New(tipSetSize)
+	}
+	return c
+}
+
+// GetBlock returns a copy of the cached block with the given cid, if present.
+func (c *ReadCache) GetBlock(blkCid cid.Cid) (*types.Block, bool) {
+	if c == nil || c.blocks == nil {
+		return nil, false
+	}
+	v, ok := c.blocks.Get(blkCid)
+	if !ok {
+		return nil, false
+	}
+	return v.(*types.Block).Clone(), true
+}
+
+// AddBlock caches a copy of a decoded block.
+func (c *ReadCache) AddBlock(blk *types.Block) {
+	if c == nil || c.blocks == nil {
+		return
+	}
+	c.blocks.Add(blk.Cid(), blk.Clone())
+}
+
+// GetTipSet returns a copy of the cached tipset with the given key, if
+// present.
+func (c *ReadCache) GetTipSet(key types.SortedCidSet) (types.TipSet, bool) {
+	if c == nil || c.tipsets == nil {
+		return nil, false
+	}
+	v, ok := c.tipsets.Get(key.String())
+	if !ok {
+		return nil, false
+	}
+	return cloneTipSet(v.(types.TipSet)), true
+}
+
+// AddTipSet caches a copy of an assembled tipset.
+func (c *ReadCache) AddTipSet(ts types.TipSet) {
+	if c == nil || c.tipsets == nil || len(ts) == 0 {
+		return
+	}
+	c.tipsets.Add(ts.String(), cloneTipSet(ts))
+}
+
+// cloneTipSet returns a tipset holding copies of the blocks of ts.
+func cloneTipSet(ts types.TipSet) types.TipSet {
+	cpy := make(types.TipSet, len(ts))
+	for k, blk := range ts {
+		cpy[k] = blk.Clone()
+	}
+	return cpy
+}
@@ -0,0 +1,95 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestReadCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	store := th.NewFakeBlockProvider()
+	root := store.NewBlock(0)
+	b1 := store.NewBlock(1, root)
+	b2 := store.NewBlock(2, root)
+
+	t.Run("caches blocks and evicts the least recently used", func(t *testing.T) {
+		cache := chain.NewReadCache(2, 0)
+		cache.AddBlock(root)
+		cache.AddBlock(b1)
+
+		got, ok := cache.GetBlock(root.Cid())
+		require.True(t, ok)
+		assert.Equal(t, root, got)
+
+		// b1 is now the least recently used.
+		cache.AddBlock(b2)
+		_, ok = cache.GetBlock(b1.Cid())
+		assert.False(t, ok)
+		_, ok = cache.GetBlock(root.Cid())
+		assert.True(t, ok)
+		_, ok = cache.GetBlock(b2.Cid())
+		assert.True(t, ok)
+	})
+
+	t.Run("added and returned blocks are copies", func(t *testing.T) {
+		cache := chain.NewReadCache(10, 0)
+		blk := store.NewBlock(3, root)
+		c, h := blk.Cid(), blk.Height
+		cache.AddBlock(blk)
+		blk.Height = 100
+
+		got, ok := cache.GetBlock(c)
+		require.True(t, ok)
+		assert.Equal(t, h, got.Height)
+		got.Height = 100
+		got.Parents = types.SortedCidSet{}
+
+		again, ok := cache.GetBlock(c)
+		require.True(t, ok)
+		assert.Equal(t, h, again.Height)
+		assert.True(t, again.Parents.Has(root.Cid()))
+		assert.Equal(t, c, again.Cid())
+	})
+
+	t.Run("returned tipsets are copies", func(t *testing.T) {
+		cache := chain.NewReadCache(0, 10)
+		ts := requireTipset(t, b1, b2)
+		cache.AddTipSet(ts)
+
+		got, ok := cache.GetTipSet(ts.ToSortedCidSet())
+		require.True(t, ok)
+		assert.True(t, ts.Equals(got))
+
+		delete(got, b1.Cid())
+		got[b2.Cid()].Height = 100
+		again, ok := cache.GetTipSet(ts.ToSortedCidSet())
+		require.True(t, ok)
+		assert.Len(t, again, 2)
+		assert.Equal(t, b2.Height, again[b2.Cid()].Height)
+	})
+
+	t.Run("zero sizes and nil caches cache nothing", func(t *testing.T) {
+		cache := chain.NewReadCache(0, 0)
+		cache.AddBlock(root)
+		cache.AddTipSet(requireTipset(t, root))
+		_, ok := cache.GetBlock(root.Cid())
+		assert.False(t, ok)
+		_, ok = cache.GetTipSet(requireTipset(t, root).ToSortedCidSet())
+		assert.False(t, ok)
+
+		var nilCache *chain.ReadCache
+		nilCache.AddBlock(root)
+		_, ok = nilCache.GetBlock(root.Cid())
+		assert.False(t, ok)
+		_, ok = nilCache.GetTipSet(types.SortedCidSet{})
+		assert.False(t, ok)
+	})
+}
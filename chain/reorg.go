@@ -1,6 +1,8 @@
 package chain
 
 import (
+	"context"
+
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -22,3 +24,22 @@ func IsReorg(curHead types.TipSet, newChain []types.TipSet) bool {
 	}
 	return true
 }
+
+// ReorgDepth returns the number of rounds of the chain ending in curHead that
+// choosing newHead as the head reverts, that is the difference in height
+// between curHead and the common ancestor of the two tipsets.
+func ReorgDepth(ctx context.Context, store BlockProvider, curHead, newHead types.TipSet) (uint64, error) {
+	ancestor, err := FindCommonAncestor(IterAncestors(ctx, store, curHead), IterAncestors(ctx, store, newHead))
+	if err != nil {
+		return 0, err
+	}
+	curHeight, err := curHead.Height()
+	if err != nil {
+		return 0, err
+	}
+	ancestorHeight, err := ancestor.Height()
+	if err != nil {
+		return 0, err
+	}
+	return curHeight - ancestorHeight, nil
+}
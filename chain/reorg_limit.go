@@ -0,0 +1,90 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// DefaultMaxReorgDepth is the number of rounds of its chain the syncer reverts
+// at most to switch to a heavier chain, unless configured otherwise.
+const DefaultMaxReorgDepth = uint64(900)
+
+// ErrReorgTooDeep is returned when switching to a heavier chain would revert
+// more of the current chain than the syncer's reorg depth limit allows.
+var ErrReorgTooDeep = errors.New("chain forked from the head further back than the reorg depth limit")
+
+var reorgRejectedCt = metrics.NewInt64Counter("chain/reorg_rejected", "Number of heavier chains rejected for forking deeper than the reorg depth limit")
+
+type noReorgLimitKey struct{}
+
+// WithoutReorgLimit returns a context under which syncing switches to a
+// heavier chain however deep it forked from the head.  Operators use it to
+// accept a chain the syncer rejected.
+func WithoutReorgLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noReorgLimitKey{}, true)
+}
+
+func reorgLimitOverridden(ctx context.Context) bool {
+	overridden, _ := ctx.Value(noReorgLimitKey{}).(bool)
+	return overridden
+}
+
+// SetMaxReorgDepth sets the number of rounds of its chain the syncer reverts
+// at most to switch to a heavier chain.  Zero removes the limit.
+func (syncer *DefaultSyncer) SetMaxReorgDepth(depth uint64) {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+	syncer.maxReorgDepth = depth
+}
+
+// checkReorgDepth returns ErrReorgTooDeep if switching from head to next
+// reverts more rounds of head's chain than the limit, unless ctx overrides
+// the limit.
+func (syncer *DefaultSyncer) checkReorgDepth(ctx context.Context, head, next types.TipSet) error {
+	if syncer.maxReorgDepth == 0 {
+		return nil
+	}
+	depth, err := ReorgDepth(ctx, syncer.chainStore, head, next)
+	if err != nil {
+		return err
+	}
+	if depth <= syncer.maxReorgDepth {
+		return nil
+	}
+	if reorgLimitOverridden(ctx) {
+		logSyncer.Warningf("forced reorg of depth %d, beyond the limit of %d, from %s to %s", depth, syncer.maxReorgDepth, head.String(), next.String())
+		return nil
+	}
+
+	reorgRejectedCt.Inc(ctx, 1)
+	logSyncer.Errorf("REJECTED heavier chain with head %s forking %d rounds below head %s, beyond the reorg depth limit of %d: if this chain is canonical, force it with `go-filecoin chain force-sync`", next.String(), depth, head.String(), syncer.maxReorgDepth)
+	return errors.Wrapf(ErrReorgTooDeep, "switching from %s to %s reverts %d rounds, limit is %d", head.String(), next.String(), depth, syncer.maxReorgDepth)
+}
+
+// reconsiderHead makes the tipset with key tsKey, which is in the store, the
+// head if it is heavier.  It is used to force a tipset that was stored but
+// rejected as too deep a reorg.
+//
+// Precondition: the caller must hold the syncer's lock (syncer.mu).
+func (syncer *DefaultSyncer) reconsiderHead(ctx context.Context, tsKey types.SortedCidSet) error {
+	ts, err := syncer.chainStore.GetTipSet(tsKey)
+	if err != nil {
+		return err
+	}
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return err
+	}
+	if parentKey.Empty() {
+		return nil
+	}
+	parent, err := syncer.chainStore.GetTipSet(parentKey)
+	if err != nil {
+		return err
+	}
+	return syncer.updateHead(ctx, *parent, *ts)
+}
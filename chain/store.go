@@ -31,14 +31,19 @@ type ReadStore interface {
 	// exist.
 	GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
 
-	// GetTipSet retrieves the state at the
+	// GetTipSetStateRoot retrieves the state at the
 	// provided tipset key if in the store and an error if it does not
-	// exist.
+	// exist.  It returns ErrStateDeferred for the tipsets below a
+	// checkpoint.
 	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
 
 	// GetBlock gets a block by cid.
 	GetBlock(ctx context.Context, id cid.Cid) (*types.Block, error)
 
+	// GetMessageLocation returns where a message was included on the
+	// heaviest chain, if it was.
+	GetMessageLocation(msgCid cid.Cid) (*MessageLocation, bool, error)
+
 	HeadEvents() *pubsub.PubSub
 	// GetHead returns the head of the chain tracked by the store.
 	GetHead() types.SortedCidSet
@@ -0,0 +1,222 @@
+package chain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var (
+	syncHeightGauge       = metrics.NewInt64Gauge("chain/sync_height", "Height of the tipset last fetched or synced by the sync operation in progress")
+	syncTargetHeightGauge = metrics.NewInt64Gauge("chain/sync_target_height", "Height of the head of the chain being synced")
+	syncCompletedCt       = metrics.NewInt64Counter("chain/sync_completed", "Number of sync operations that completed")
+	syncFailedCt          = metrics.NewInt64Counter("chain/sync_failed", "Number of sync operations that failed")
+)
+
+// SyncStage is the stage a sync operation is in.
+type SyncStage string
+
+const (
+	// SyncIdle is the stage of a syncer not syncing a chain.
+	SyncIdle = SyncStage("idle")
+	// SyncFetching is the stage of fetching the chain from its head back to
+	// a tipset in the store.
+	SyncFetching = SyncStage("fetching")
+	// SyncValidating is the stage of validating the fetched chain and
+	// running its state transitions.
+	SyncValidating = SyncStage("validating")
+)
+
+// SyncStatus describes the sync operation in progress or, when the syncer is
+// idle, the last one.  Heights are approximate counts of tipsets, since null
+// rounds have no tipsets.
+type SyncStatus struct {
+	Stage SyncStage `json:"stage"`
+	// Target is the head of the chain being synced.
+	Target       types.SortedCidSet `json:"target"`
+	TargetHeight uint64             `json:"targetHeight"`
+	// BaseHeight is the height of the tipset in the store the chain
+	// extends.  While fetching it is estimated by the height of the head.
+	BaseHeight uint64 `json:"baseHeight"`
+	// Height is the height of the tipset last fetched while fetching, which
+	// decreases towards the base, and of the tipset last synced while
+	// validating.
+	Height uint64 `json:"height"`
+	// Start is the time the stage started.
+	Start time.Time `json:"start"`
+	// TipSetsPerSecond is the rate at which the stage has processed tipsets.
+	TipSetsPerSecond float64 `json:"tipSetsPerSecond"`
+	// ETA is the estimated time left for the stage, zero if unknown.
+	ETA time.Duration `json:"eta"`
+	// Error is the error the last sync operation failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// remaining returns the number of tipsets the stage has left to process.
+func (s *SyncStatus) remaining() uint64 {
+	switch s.Stage {
+	case SyncFetching:
+		if s.Height > s.BaseHeight {
+			return s.Height - s.BaseHeight
+		}
+	case SyncValidating:
+		if s.TargetHeight > s.Height {
+			return s.TargetHeight - s.Height
+		}
+	}
+	return 0
+}
+
+// syncStatusTracker records the status of the sync operations of a syncer
+// and notifies subscribers of each change.
+type syncStatusTracker struct {
+	mu     sync.Mutex
+	status SyncStatus
+	// done is the number of tipsets processed in the current stage.
+	done   uint64
+	nextID int
+	subs   map[int]chan SyncStatus
+}
+
+func newSyncStatusTracker() *syncStatusTracker {
+	return &syncStatusTracker{
+		status: SyncStatus{Stage: SyncIdle},
+		subs:   make(map[int]chan SyncStatus),
+	}
+}
+
+func (st *syncStatusTracker) get() SyncStatus {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.status
+}
+
+// start records the start of syncing the chain with head target onto a store
+// whose head is at baseHeight.
+func (st *syncStatusTracker) start(target types.SortedCidSet, baseHeight uint64) {
+	st.update(func(s *SyncStatus) {
+		*s = SyncStatus{
+			Stage:      SyncFetching,
+			Target:     target,
+			BaseHeight: baseHeight,
+			Start:      time.Now(),
+		}
+	}, false)
+}
+
+// fetched records that the tipset at height was fetched.
+func (st *syncStatusTracker) fetched(height uint64) {
+	st.update(func(s *SyncStatus) {
+		if s.TargetHeight == 0 {
+			s.TargetHeight = height
+		}
+		s.Height = height
+	}, true)
+}
+
+// validating records that the chain was fetched down to a base tipset at
+// baseHeight and starts the validating stage.
+func (st *syncStatusTracker) validating(baseHeight uint64) {
+	st.update(func(s *SyncStatus) {
+		s.Stage = SyncValidating
+		s.BaseHeight = baseHeight
+		s.Height = baseHeight
+		s.Start = time.Now()
+		s.TipSetsPerSecond = 0
+		s.ETA = 0
+	}, false)
+}
+
+// synced records that the tipset at height was synced.
+func (st *syncStatusTracker) synced(height uint64) {
+	st.update(func(s *SyncStatus) { s.Height = height }, true)
+}
+
+// finish records the end of the sync operation, with the error it failed
+// with if any.
+func (st *syncStatusTracker) finish(err error) {
+	st.update(func(s *SyncStatus) {
+		s.Stage = SyncIdle
+		s.ETA = 0
+		if err != nil {
+			s.Error = err.Error()
+		}
+	}, false)
+	if err != nil {
+		syncFailedCt.Inc(context.Background(), 1)
+	} else {
+		syncCompletedCt.Inc(context.Background(), 1)
+	}
+}
+
+// update applies f to the status, counting a tipset processed by the stage if
+// progressed is true, updates the rate and estimate, and sends the result to
+// subscribers, replacing any status they have yet to receive.
+func (st *syncStatusTracker) update(f func(*SyncStatus), progressed bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	prevStage := st.status.Stage
+	f(&st.status)
+	if st.status.Stage != prevStage {
+		st.done = 0
+	}
+	if progressed {
+		st.done++
+		if elapsed := time.Since(st.status.Start).Seconds(); elapsed > 0 {
+			st.status.TipSetsPerSecond = float64(st.done) / elapsed
+		}
+		if st.status.TipSetsPerSecond > 0 {
+			st.status.ETA = time.Duration(float64(st.status.remaining()) / st.status.TipSetsPerSecond * float64(time.Second))
+		}
+	}
+	syncHeightGauge.Set(context.Background(), int64(st.status.Height))
+	syncTargetHeightGauge.Set(context.Background(), int64(st.status.TargetHeight))
+
+	for _, ch := range st.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		// Only update sends, under the lock, so there is room now.
+		ch <- st.status
+	}
+}
+
+// subscribe returns a channel receiving the current status followed by the
+// latest status after each change, which is closed when ctx is done.
+func (st *syncStatusTracker) subscribe(ctx context.Context) <-chan SyncStatus {
+	ch := make(chan SyncStatus, 1)
+
+	st.mu.Lock()
+	id := st.nextID
+	st.nextID++
+	st.subs[id] = ch
+	ch <- st.status
+	st.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		delete(st.subs, id)
+		close(ch)
+	}()
+	return ch
+}
+
+// Status returns the status of the sync operation in progress, or of the last
+// one if the syncer is idle.
+func (syncer *DefaultSyncer) Status() SyncStatus {
+	return syncer.status.get()
+}
+
+// SubscribeStatus returns a channel receiving the syncer's status followed by
+// its latest status whenever it changes.  Slow receivers only miss
+// intermediate updates.  The channel is closed when ctx is done.
+func (syncer *DefaultSyncer) SubscribeStatus(ctx context.Context) <-chan SyncStatus {
+	return syncer.status.subscribe(ctx)
+}
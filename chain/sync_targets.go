@@ -0,0 +1,275 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// syncTargetKey holds the target being synced, so that syncing it resumes
+// after a restart.
+var syncTargetKey = datastore.NewKey("/chain/syncTarget")
+
+// persistedTarget is the record of the target being synced.
+type persistedTarget struct {
+	TipSet       types.SortedCidSet `json:"tipSet"`
+	Height       uint64             `json:"height"`
+	ParentWeight uint64             `json:"parentWeight"`
+}
+
+// SyncTarget is a head announced by peers, a candidate for syncing.
+type SyncTarget struct {
+	TipSet types.SortedCidSet `json:"tipSet"`
+	Height uint64             `json:"height"`
+	// ParentWeight is the parent weight the peers claim for the tipset.
+	ParentWeight uint64    `json:"parentWeight"`
+	Peers        []peer.ID `json:"peers"`
+	// Syncing is true while the target is being synced.
+	Syncing bool `json:"syncing"`
+	// Error is the error syncing the target failed with, if it did.  Failed
+	// targets are not synced again unless announced again.
+	Error string `json:"error,omitempty"`
+}
+
+// TargetTracker keeps the latest head announced by each peer so that the
+// heaviest one is synced first, whatever order the announcements arrive in.
+type TargetTracker struct {
+	lk sync.Mutex
+	// targets are keyed by tipset and claimed weight, so that a peer lying
+	// about the weight of a tipset doesn't get the honest peers announcing
+	// it blamed for the lie.
+	targets map[string]*SyncTarget
+	// heads is the key of the target each peer announced last.
+	heads map[peer.ID]string
+	// ready is signaled when a target is added.
+	ready chan struct{}
+	// ds, if set, keeps the target being synced.
+	ds repo.Datastore
+}
+
+// NewTargetTracker returns a TargetTracker without targets.
+func NewTargetTracker() *TargetTracker {
+	return &TargetTracker{
+		targets: make(map[string]*SyncTarget),
+		heads:   make(map[peer.ID]string),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// Add records that p announced head, with the given height and claimed
+// parent weight, replacing the head p announced before.
+func (tt *TargetTracker) Add(p peer.ID, head types.SortedCidSet, height, parentWeight uint64) {
+	tt.lk.Lock()
+	defer tt.lk.Unlock()
+
+	key := targetKey(head, parentWeight)
+	if prev, ok := tt.heads[p]; ok && prev != key {
+		tt.removePeer(p, prev)
+	}
+	tt.heads[p] = key
+
+	target := tt.add(head, height, parentWeight)
+	found := false
+	for _, tp := range target.Peers {
+		found = found || tp == p
+	}
+	if !found {
+		target.Peers = append(target.Peers, p)
+	}
+}
+
+// add returns the target for head and parentWeight, adding it if it isn't
+// tracked, and clears its error so that it can be synced again.
+func (tt *TargetTracker) add(head types.SortedCidSet, height, parentWeight uint64) *SyncTarget {
+	key := targetKey(head, parentWeight)
+	target, ok := tt.targets[key]
+	if !ok {
+		target = &SyncTarget{TipSet: head, Height: height, ParentWeight: parentWeight}
+		tt.targets[key] = target
+	}
+	target.Error = ""
+
+	select {
+	case tt.ready <- struct{}{}:
+	default:
+	}
+	return target
+}
+
+// Resume makes the tracker keep the target being synced in ds, and adds the
+// target that was being synced when the node stopped, if any, so that its
+// sync resumes.  Resuming is cheap: the blocks fetched are in the blockstore
+// and the tipsets validated are in the chain store up to its head, so only
+// the local blocks between the target and the head are walked again.
+func (tt *TargetTracker) Resume(ds repo.Datastore) error {
+	tt.lk.Lock()
+	defer tt.lk.Unlock()
+	tt.ds = ds
+
+	bb, err := ds.Get(syncTargetKey)
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read sync target")
+	}
+	var pt persistedTarget
+	if err := json.Unmarshal(bb, &pt); err != nil {
+		return errors.Wrap(err, "failed to decode sync target")
+	}
+	logSyncer.Infof("resuming sync of chain with head %s at height %d", pt.TipSet.String(), pt.Height)
+	tt.add(pt.TipSet, pt.Height, pt.ParentWeight)
+	return nil
+}
+
+// removePeer removes p from the peers of the target with key, and the target
+// if no other peer announced it and it isn't being synced.
+func (tt *TargetTracker) removePeer(p peer.ID, key string) {
+	target, ok := tt.targets[key]
+	if !ok {
+		return
+	}
+	for i, tp := range target.Peers {
+		if tp == p {
+			target.Peers = append(target.Peers[:i], target.Peers[i+1:]...)
+			break
+		}
+	}
+	if len(target.Peers) == 0 && !target.Syncing {
+		delete(tt.targets, key)
+	}
+}
+
+// Ready returns a channel signaled when targets are added.
+func (tt *TargetTracker) Ready() <-chan struct{} {
+	return tt.ready
+}
+
+// Select returns the heaviest target that is neither being synced nor
+// failed, and marks it as being synced.  Targets claiming a lower parent
+// weight than minWeight, the parent weight of the current head, can't be
+// heavier than it and are dropped.  It returns false if there is no target
+// to sync.
+func (tt *TargetTracker) Select(minWeight uint64) (SyncTarget, bool) {
+	tt.lk.Lock()
+	defer tt.lk.Unlock()
+
+	var best *SyncTarget
+	for key, target := range tt.targets {
+		if target.Syncing || target.Error != "" {
+			continue
+		}
+		if target.ParentWeight < minWeight {
+			tt.drop(key)
+			continue
+		}
+		if best == nil || heavierTarget(target, best) {
+			best = target
+		}
+	}
+	if best == nil {
+		return SyncTarget{}, false
+	}
+	best.Syncing = true
+	tt.persist(best)
+	return copyTarget(best), true
+}
+
+// persist keeps target as the target being synced, if the tracker has a
+// datastore.  Failing to is not fatal, the sync only won't resume.
+func (tt *TargetTracker) persist(target *SyncTarget) {
+	if tt.ds == nil {
+		return
+	}
+	bb, err := json.Marshal(persistedTarget{TipSet: target.TipSet, Height: target.Height, ParentWeight: target.ParentWeight})
+	if err == nil {
+		err = tt.ds.Put(syncTargetKey, bb)
+	}
+	if err != nil {
+		logSyncer.Warningf("failed to persist sync target %s: %s", target.TipSet.String(), err)
+	}
+}
+
+// Done records the outcome of syncing target.  Synced targets are dropped,
+// failed ones kept with their error until announced again.
+func (tt *TargetTracker) Done(target SyncTarget, err error) {
+	tt.lk.Lock()
+	defer tt.lk.Unlock()
+
+	if tt.ds != nil {
+		if err := tt.ds.Delete(syncTargetKey); err != nil {
+			logSyncer.Warningf("failed to clear sync target: %s", err)
+		}
+	}
+
+	key := targetKey(target.TipSet, target.ParentWeight)
+	t, ok := tt.targets[key]
+	if !ok {
+		return
+	}
+	t.Syncing = false
+	if err == nil {
+		tt.drop(key)
+		return
+	}
+	t.Error = err.Error()
+	if len(t.Peers) == 0 {
+		delete(tt.targets, key)
+	}
+}
+
+// drop removes the target with key and forgets it as the head of its peers.
+func (tt *TargetTracker) drop(key string) {
+	target := tt.targets[key]
+	for _, p := range target.Peers {
+		if tt.heads[p] == key {
+			delete(tt.heads, p)
+		}
+	}
+	delete(tt.targets, key)
+}
+
+// Candidates returns the targets tracked, heaviest first.
+func (tt *TargetTracker) Candidates() []SyncTarget {
+	tt.lk.Lock()
+	defer tt.lk.Unlock()
+
+	targets := make([]SyncTarget, 0, len(tt.targets))
+	for _, target := range tt.targets {
+		targets = append(targets, copyTarget(target))
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return heavierTarget(&targets[i], &targets[j])
+	})
+	return targets
+}
+
+func targetKey(head types.SortedCidSet, parentWeight uint64) string {
+	return fmt.Sprintf("%s/%d", head.String(), parentWeight)
+}
+
+// heavierTarget orders targets by claimed parent weight, then height, then
+// the order of their tipsets' cids so that the order is deterministic.
+func heavierTarget(a, b *SyncTarget) bool {
+	if a.ParentWeight != b.ParentWeight {
+		return a.ParentWeight > b.ParentWeight
+	}
+	if a.Height != b.Height {
+		return a.Height > b.Height
+	}
+	return a.TipSet.Compare(b.TipSet) < 0
+}
+
+func copyTarget(t *SyncTarget) SyncTarget {
+	c := *t
+	c.Peers = append([]peer.ID(nil), t.Peers...)
+	return c
+}
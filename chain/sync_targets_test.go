@@ -0,0 +1,118 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/repo"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestTargetTracker(t *testing.T) {
+	tf.UnitTest(t)
+
+	p1, err := th.RandPeerID()
+	require.NoError(t, err)
+	p2, err := th.RandPeerID()
+	require.NoError(t, err)
+	newCid := types.NewCidForTestGetter()
+	light := types.NewSortedCidSet(newCid())
+	heavy := types.NewSortedCidSet(newCid())
+
+	t.Run("selects the heaviest target first", func(t *testing.T) {
+		tt := chain.NewTargetTracker()
+		tt.Add(p1, light, 10, 100)
+		tt.Add(p2, heavy, 9, 200)
+
+		candidates := tt.Candidates()
+		require.Equal(t, 2, len(candidates))
+		assert.Equal(t, heavy, candidates[0].TipSet)
+		assert.Equal(t, light, candidates[1].TipSet)
+
+		target, ok := tt.Select(0)
+		require.True(t, ok)
+		assert.Equal(t, heavy, target.TipSet)
+		assert.True(t, tt.Candidates()[0].Syncing)
+
+		tt.Done(target, nil)
+		target, ok = tt.Select(0)
+		require.True(t, ok)
+		assert.Equal(t, light, target.TipSet)
+		_, ok = tt.Select(0)
+		assert.False(t, ok)
+	})
+
+	t.Run("drops targets lighter than the head", func(t *testing.T) {
+		tt := chain.NewTargetTracker()
+		tt.Add(p1, light, 10, 100)
+		_, ok := tt.Select(150)
+		assert.False(t, ok)
+		assert.Equal(t, 0, len(tt.Candidates()))
+	})
+
+	t.Run("merges peers announcing the same head", func(t *testing.T) {
+		tt := chain.NewTargetTracker()
+		tt.Add(p1, heavy, 9, 200)
+		tt.Add(p2, heavy, 9, 200)
+		target, ok := tt.Select(0)
+		require.True(t, ok)
+		assert.Equal(t, 2, len(target.Peers))
+
+		// A peer's new head replaces its previous one.
+		tt.Done(target, nil)
+		tt.Add(p1, light, 10, 100)
+		tt.Add(p1, heavy, 11, 300)
+		candidates := tt.Candidates()
+		require.Equal(t, 1, len(candidates))
+		assert.Equal(t, uint64(300), candidates[0].ParentWeight)
+	})
+
+	t.Run("keeps failed targets until announced again", func(t *testing.T) {
+		tt := chain.NewTargetTracker()
+		tt.Add(p1, heavy, 9, 200)
+		target, ok := tt.Select(0)
+		require.True(t, ok)
+		tt.Done(target, errors.New("boom"))
+
+		candidates := tt.Candidates()
+		require.Equal(t, 1, len(candidates))
+		assert.Equal(t, "boom", candidates[0].Error)
+		_, ok = tt.Select(0)
+		assert.False(t, ok)
+
+		tt.Add(p2, heavy, 9, 200)
+		_, ok = tt.Select(0)
+		assert.True(t, ok)
+	})
+
+	t.Run("resumes the target being synced", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().ChainDatastore()
+		tt := chain.NewTargetTracker()
+		require.NoError(t, tt.Resume(ds))
+		tt.Add(p1, heavy, 9, 200)
+		_, ok := tt.Select(0)
+		require.True(t, ok)
+
+		// The node restarts before the sync ends.
+		resumed := chain.NewTargetTracker()
+		require.NoError(t, resumed.Resume(ds))
+		target, ok := resumed.Select(0)
+		require.True(t, ok)
+		assert.Equal(t, heavy, target.TipSet)
+		assert.Equal(t, uint64(200), target.ParentWeight)
+		assert.Equal(t, 0, len(target.Peers))
+
+		// The target isn't resumed once synced.
+		resumed.Done(target, nil)
+		again := chain.NewTargetTracker()
+		require.NoError(t, again.Resume(ds))
+		_, ok = again.Select(0)
+		assert.False(t, ok)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -13,6 +14,12 @@ type BlockProvider interface {
 	GetBlock(ctx context.Context, cid cid.Cid) (*types.Block, error)
 }
 
+// tipSetLoader is implemented by block providers that can assemble tipsets
+// more cheaply than block by block, e.g. by caching them.
+type tipSetLoader interface {
+	LoadTipSet(ctx context.Context, key types.SortedCidSet) (types.TipSet, error)
+}
+
 // GetParentTipSet returns the parent tipset of a tipset.
 // The result is empty if the tipset has no parents (including if it is empty itself)
 func GetParentTipSet(ctx context.Context, store BlockProvider, ts types.TipSet) (types.TipSet, error) {
@@ -21,6 +28,9 @@ func GetParentTipSet(ctx context.Context, store BlockProvider, ts types.TipSet)
 	if err != nil {
 		return nil, err
 	}
+	if loader, ok := store.(tipSetLoader); ok && !parents.Empty() {
+		return loader.LoadTipSet(ctx, parents)
+	}
 	for it := parents.Iter(); !it.Complete() && ctx.Err() == nil; it.Next() {
 		newBlk, err := store.GetBlock(ctx, it.Value())
 		if err != nil {
@@ -67,3 +77,41 @@ func (it *TipsetIterator) Next() error {
 	}
 	return err
 }
+
+// GetTipSetsInRange returns the tipsets on the chain ending at head with
+// heights in the inclusive range [fromHeight, toHeight], in ascending order of
+// height.  Heights at which no tipset exists because of null blocks are
+// skipped, so the result may contain fewer than toHeight-fromHeight+1
+// tipsets.
+func GetTipSetsInRange(ctx context.Context, store BlockProvider, head types.TipSet, fromHeight, toHeight uint64) ([]types.TipSet, error) {
+	if fromHeight > toHeight {
+		return nil, errors.Errorf("invalid range: from height %d is greater than to height %d", fromHeight, toHeight)
+	}
+
+	var ret []types.TipSet
+	var err error
+	for iterator := IterAncestors(ctx, store, head); !iterator.Complete(); err = iterator.Next() {
+		if err != nil {
+			return nil, err
+		}
+		h, err := iterator.Value().Height()
+		if err != nil {
+			return nil, err
+		}
+		if h < fromHeight {
+			break
+		}
+		if h <= toHeight {
+			ret = append(ret, iterator.Value())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Ancestors are visited newest first; reverse into ascending order.
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return ret, nil
+}
@@ -108,3 +108,48 @@ func requireTipset(t *testing.T, blocks ...*types.Block) types.TipSet {
 	require.NoError(t, err)
 	return set
 }
+
+func TestGetTipSetsInRange(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	store := th.NewFakeBlockProvider()
+
+	// Heights: root 0, b1 1, b2 4 (after two null rounds), b3 5.
+	root := store.NewBlock(0)
+	b1 := store.NewBlock(1, root)
+	b2 := store.NewBlockAfterNullRounds(2, 2, b1)
+	b3 := store.NewBlock(3, b2)
+
+	t0 := requireTipset(t, root)
+	t1 := requireTipset(t, b1)
+	t2 := requireTipset(t, b2)
+	t3 := requireTipset(t, b3)
+
+	requireRange := func(t *testing.T, from, to uint64, expected ...types.TipSet) {
+		got, err := chain.GetTipSetsInRange(ctx, store, t3, from, to)
+		require.NoError(t, err)
+		require.Len(t, got, len(expected))
+		for i := range expected {
+			assert.True(t, expected[i].Equals(got[i]), "tipset %d differs", i)
+		}
+	}
+
+	t.Run("whole chain ascending", func(t *testing.T) {
+		requireRange(t, 0, 5, t0, t1, t2, t3)
+	})
+	t.Run("skips null rounds", func(t *testing.T) {
+		requireRange(t, 1, 4, t1, t2)
+		requireRange(t, 2, 3)
+	})
+	t.Run("range above head", func(t *testing.T) {
+		requireRange(t, 5, 10, t3)
+	})
+	t.Run("single height", func(t *testing.T) {
+		requireRange(t, 0, 0, t0)
+	})
+	t.Run("invalid range", func(t *testing.T) {
+		_, err := chain.GetTipSetsInRange(ctx, store, t3, 3, 2)
+		assert.Error(t, err)
+	})
+}
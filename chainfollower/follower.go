@@ -0,0 +1,230 @@
+// Package chainfollower follows the head of a node's chain and hands the
+// tipsets joining and leaving the chain to a Handler, in order, so that
+// indexers such as explorers and exchange integrations don't each have to
+// implement head tracking and reorg handling against the node's API.
+package chainfollower
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// API is the part of the node's API a Follower uses.
+type API interface {
+	ChainHead() (*types.TipSet, error)
+	ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error)
+}
+
+// Message is a message included in a followed tipset, along with its
+// receipt.
+type Message struct {
+	Cid cid.Cid
+	// Block is the cid of the block that included the message.
+	Block   cid.Cid
+	Message *types.SignedMessage
+	Receipt *types.MessageReceipt
+}
+
+// TipSet is a tipset passed to a Handler along with its decoded messages.
+type TipSet struct {
+	types.TipSet
+	Height uint64
+	// Messages are the tipset's messages in the order they are applied,
+	// with blocks taken in ticket order.  A message included by several of
+	// the tipset's blocks appears only once, for the first block including
+	// it.
+	Messages []*Message
+}
+
+// Handler processes the changes to the followed chain.
+type Handler interface {
+	// Apply is called for each tipset joining the chain, parents before
+	// their children.
+	Apply(ctx context.Context, ts *TipSet) error
+	// Revert is called for each tipset leaving the chain in a reorg,
+	// children before their parents, before the tipsets replacing them are
+	// applied.
+	Revert(ctx context.Context, ts *TipSet) error
+}
+
+// Follower tracks the node's chain head and calls its Handler to move the
+// last tipset it handled to the new head.
+type Follower struct {
+	api     API
+	handler Handler
+
+	// head is the last tipset applied, nil until the first update.
+	head *TipSet
+}
+
+// New returns a Follower that hands the changes from the tipset from to the
+// node's head to handler.  If from is nil, the first update applies only the
+// node's head at that time.
+func New(api API, handler Handler, from types.TipSet) (*Follower, error) {
+	f := &Follower{api: api, handler: handler}
+	if from != nil {
+		head, err := newTipSet(from)
+		if err != nil {
+			return nil, err
+		}
+		f.head = head
+	}
+	return f, nil
+}
+
+// Head returns the last tipset applied, or nil if none was.
+func (f *Follower) Head() types.TipSet {
+	if f.head == nil {
+		return nil
+	}
+	return f.head.TipSet
+}
+
+// Run updates the follower every interval until ctx is done or an update
+// fails.
+func (f *Follower) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.Update(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update reverts the tipsets of the followed chain that are no longer on the
+// node's chain and applies the ones that joined it, up to the node's head.
+// If the handler fails, the follower stays at the last tipset handled and
+// the next update resumes from there.
+func (f *Follower) Update(ctx context.Context) error {
+	nodeHead, err := f.api.ChainHead()
+	if err != nil {
+		return errors.Wrap(err, "failed to get chain head")
+	}
+	if f.head != nil && f.head.ToSortedCidSet().Equals(nodeHead.ToSortedCidSet()) {
+		return nil
+	}
+	head, err := f.loadTipSet(ctx, nodeHead.ToSortedCidSet())
+	if err != nil {
+		return err
+	}
+	if f.head == nil {
+		if err := f.handler.Apply(ctx, head); err != nil {
+			return errors.Wrapf(err, "failed to apply tipset %s", head.String())
+		}
+		f.head = head
+		return nil
+	}
+
+	reverts, applies, err := f.path(ctx, f.head, head)
+	if err != nil {
+		return err
+	}
+	for _, ts := range reverts {
+		if err := f.handler.Revert(ctx, ts); err != nil {
+			return errors.Wrapf(err, "failed to revert tipset %s", ts.String())
+		}
+		parent, err := f.loadParent(ctx, ts)
+		if err != nil {
+			return err
+		}
+		f.head = parent
+	}
+	for i := len(applies) - 1; i >= 0; i-- {
+		if err := f.handler.Apply(ctx, applies[i]); err != nil {
+			return errors.Wrapf(err, "failed to apply tipset %s", applies[i].String())
+		}
+		f.head = applies[i]
+	}
+	return nil
+}
+
+// path walks back from from and to to their common ancestor, returning the
+// tipsets leaving the chain, children first, and those joining it, children
+// first.
+func (f *Follower) path(ctx context.Context, from, to *TipSet) (reverts []*TipSet, applies []*TipSet, err error) {
+	for !from.ToSortedCidSet().Equals(to.ToSortedCidSet()) {
+		if from.Height >= to.Height {
+			reverts = append(reverts, from)
+			if from, err = f.loadParent(ctx, from); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			applies = append(applies, to)
+			if to, err = f.loadParent(ctx, to); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return reverts, applies, nil
+}
+
+func (f *Follower) loadParent(ctx context.Context, ts *TipSet) (*TipSet, error) {
+	parents, err := ts.Parents()
+	if err != nil {
+		return nil, err
+	}
+	if parents.Empty() {
+		return nil, errors.Errorf("reached genesis %s without finding a common ancestor", ts.String())
+	}
+	return f.loadTipSet(ctx, parents)
+}
+
+func (f *Follower) loadTipSet(ctx context.Context, key types.SortedCidSet) (*TipSet, error) {
+	var blks []*types.Block
+	for _, c := range key.ToSlice() {
+		blk, err := f.api.ChainGetBlock(ctx, c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get block %s", c)
+		}
+		blks = append(blks, blk)
+	}
+	ts, err := types.NewTipSet(blks...)
+	if err != nil {
+		return nil, err
+	}
+	return newTipSet(ts)
+}
+
+// newTipSet decodes the messages and receipts of ts.
+func newTipSet(ts types.TipSet) (*TipSet, error) {
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	out := &TipSet{TipSet: ts, Height: height}
+	seen := make(map[cid.Cid]struct{})
+	// Walk the blocks in the order their messages are applied.
+	blks := ts.ToSlice()
+	types.SortBlocks(blks)
+	for _, blk := range blks {
+		for i, msg := range blk.Messages {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to compute cid of message %d of block %s", i, blk.Cid())
+			}
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+
+			m := &Message{Cid: c, Block: blk.Cid(), Message: msg}
+			if i < len(blk.MessageReceipts) {
+				m.Receipt = blk.MessageReceipts[i]
+			}
+			out.Messages = append(out.Messages, m)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package chainfollower_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chainfollower"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestFollower(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	// genesis <- a1 <- a2
+	//             \-- b2 <- b3
+	api := newFakeChainAPI()
+	genesis := api.add(t, 0, 0)
+	a1 := api.add(t, 1, 0, genesis)
+	a2 := api.add(t, 2, 0, a1)
+	b2 := api.add(t, 2, 1, a1)
+	b3 := api.add(t, 3, 0, b2)
+
+	t.Run("starts at the head", func(t *testing.T) {
+		h := &recordingHandler{}
+		f, err := chainfollower.New(api, h, nil)
+		require.NoError(t, err)
+
+		api.head = a2
+		require.NoError(t, f.Update(ctx))
+		assert.Equal(t, []string{"apply 2"}, h.events)
+		assert.True(t, a2.Equals(f.Head()))
+
+		require.NoError(t, f.Update(ctx))
+		assert.Len(t, h.events, 1)
+	})
+
+	t.Run("catches up from a tipset", func(t *testing.T) {
+		h := &recordingHandler{}
+		f, err := chainfollower.New(api, h, genesis)
+		require.NoError(t, err)
+
+		api.head = a2
+		require.NoError(t, f.Update(ctx))
+		assert.Equal(t, []string{"apply 1", "apply 2"}, h.events)
+	})
+
+	t.Run("reverts tipsets leaving the chain", func(t *testing.T) {
+		h := &recordingHandler{}
+		f, err := chainfollower.New(api, h, a2)
+		require.NoError(t, err)
+
+		api.head = b3
+		require.NoError(t, f.Update(ctx))
+		assert.Equal(t, []string{"revert 2", "apply 2", "apply 3"}, h.events)
+		assert.True(t, b3.Equals(f.Head()))
+	})
+
+	t.Run("resumes after the handler fails", func(t *testing.T) {
+		h := &recordingHandler{failAt: 3}
+		f, err := chainfollower.New(api, h, genesis)
+		require.NoError(t, err)
+
+		api.head = b3
+		require.Error(t, f.Update(ctx))
+		assert.True(t, b2.Equals(f.Head()))
+
+		h.failAt = 0
+		require.NoError(t, f.Update(ctx))
+		assert.Equal(t, []string{"apply 1", "apply 2", "apply 3"}, h.events)
+	})
+
+	t.Run("decodes messages and their receipts", func(t *testing.T) {
+		signer, _ := types.NewMockSignersAndKeyInfo(1)
+		newMsg := types.NewSignedMessageForTestGetter(signer)
+		shared, own := newMsg(), newMsg()
+
+		api := newFakeChainAPI()
+		genesis := api.add(t, 0, 0)
+		blk1 := &types.Block{Height: 1, Parents: genesis.ToSortedCidSet(), StateRoot: types.SomeCid(), Ticket: []byte{1},
+			Messages:        []*types.SignedMessage{shared},
+			MessageReceipts: []*types.MessageReceipt{{ExitCode: 1}}}
+		blk2 := &types.Block{Height: 1, Parents: genesis.ToSortedCidSet(), StateRoot: types.SomeCid(), Ticket: []byte{2},
+			Messages:        []*types.SignedMessage{shared, own},
+			MessageReceipts: []*types.MessageReceipt{{ExitCode: 1}, {ExitCode: 2}}}
+		api.blocks[blk1.Cid()] = blk1
+		api.blocks[blk2.Cid()] = blk2
+		head, err := types.NewTipSet(blk1, blk2)
+		require.NoError(t, err)
+		api.head = head
+
+		h := &recordingHandler{}
+		f, err := chainfollower.New(api, h, genesis)
+		require.NoError(t, err)
+		require.NoError(t, f.Update(ctx))
+
+		require.Len(t, h.applied, 1)
+		msgs := h.applied[0].Messages
+		require.Len(t, msgs, 2)
+		assert.Equal(t, shared, msgs[0].Message)
+		assert.Equal(t, blk1.Cid(), msgs[0].Block)
+		assert.Equal(t, uint8(1), msgs[0].Receipt.ExitCode)
+		assert.Equal(t, own, msgs[1].Message)
+		assert.Equal(t, blk2.Cid(), msgs[1].Block)
+		assert.Equal(t, uint8(2), msgs[1].Receipt.ExitCode)
+	})
+}
+
+type fakeChainAPI struct {
+	head   types.TipSet
+	blocks map[cid.Cid]*types.Block
+}
+
+func newFakeChainAPI() *fakeChainAPI {
+	return &fakeChainAPI{blocks: make(map[cid.Cid]*types.Block)}
+}
+
+// add adds a single block tipset at height on top of parent.
+func (api *fakeChainAPI) add(t *testing.T, height uint64, nonce uint64, parent ...types.TipSet) types.TipSet {
+	blk := &types.Block{Height: types.Uint64(height), Nonce: types.Uint64(nonce), StateRoot: types.SomeCid()}
+	if len(parent) > 0 {
+		blk.Parents = parent[0].ToSortedCidSet()
+	}
+	api.blocks[blk.Cid()] = blk
+	ts, err := types.NewTipSet(blk)
+	require.NoError(t, err)
+	return ts
+}
+
+func (api *fakeChainAPI) ChainHead() (*types.TipSet, error) {
+	return &api.head, nil
+}
+
+func (api *fakeChainAPI) ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error) {
+	blk, ok := api.blocks[id]
+	if !ok {
+		return nil, errors.Errorf("no block %s", id)
+	}
+	return blk, nil
+}
+
+// recordingHandler records the heights of the tipsets it is passed.  If
+// failAt is set, applying a tipset at that height fails.
+type recordingHandler struct {
+	events  []string
+	applied []*chainfollower.TipSet
+	failAt  uint64
+}
+
+func (h *recordingHandler) Apply(ctx context.Context, ts *chainfollower.TipSet) error {
+	if h.failAt != 0 && ts.Height == h.failAt {
+		return errors.New("apply failed")
+	}
+	h.events = append(h.events, fmt.Sprintf("apply %d", ts.Height))
+	h.applied = append(h.applied, ts)
+	return nil
+}
+
+func (h *recordingHandler) Revert(ctx context.Context, ts *chainfollower.TipSet) error {
+	h.events = append(h.events, fmt.Sprintf("revert %d", ts.Height))
+	return nil
+}
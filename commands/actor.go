@@ -8,15 +8,21 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/cron"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
 )
 
 // ActorView represents a generic way to represent details about any actor to the user.
@@ -46,7 +52,8 @@ var actorCmd = &cmds.Command{
 		Tagline: "Interact with actors. Actors are built-in smart contracts.",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"ls": actorLsCmd,
+		"dump": actorDumpCmd,
+		"ls":   actorLsCmd,
 	},
 }
 
@@ -77,6 +84,14 @@ var actorLsCmd = &cmds.Command{
 				output = makeActorView(result.Actor, result.Address, &miner.Actor{})
 			case result.Actor.Code.Equals(types.BootstrapMinerActorCodeCid):
 				output = makeActorView(result.Actor, result.Address, &miner.Actor{})
+			case result.Actor.Code.Equals(types.MultisigActorCodeCid):
+				output = makeActorView(result.Actor, result.Address, &multisig.Actor{})
+			case result.Actor.Code.Equals(types.MultisigFactoryActorCodeCid):
+				output = makeActorView(result.Actor, result.Address, &multisig.FactoryActor{})
+			case result.Actor.Code.Equals(types.RewardActorCodeCid):
+				output = makeActorView(result.Actor, result.Address, &reward.Actor{})
+			case result.Actor.Code.Equals(types.CronActorCodeCid):
+				output = makeActorView(result.Actor, result.Address, &cron.Actor{})
 			default:
 				output = makeActorView(result.Actor, result.Address, nil)
 			}
@@ -104,6 +119,67 @@ var actorLsCmd = &cmds.Command{
 	},
 }
 
+var actorDumpCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show an actor along with its decoded state",
+		ShortDescription: `
+Shows the code, head, nonce and balance of the actor at the given address
+along with the fields of its state, in the state of the tipset made of the
+given blocks, or at the given height, or of the head if neither is given. The
+state of actors whose storage is not a single structure, such as the payment
+broker, is not shown.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "address of the actor"),
+		cmdkit.StringArg("cids", false, true, "CIDs of the blocks of the tipset"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("height", "Read the state of the tipset of the chain at this height, or before it if there is none at this height"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		var blks []cid.Cid
+		for _, arg := range req.Arguments[1:] {
+			c, err := cid.Parse(arg)
+			if err != nil {
+				return errors.Wrap(err, "invalid cid "+arg)
+			}
+			blks = append(blks, c)
+		}
+		tsKey := types.NewSortedCidSet(blks...)
+		if height, ok := req.Options["height"].(uint); ok {
+			if len(blks) > 0 {
+				return errors.New("only one of cids and --height may be given")
+			}
+			if tsKey, err = tipSetKeyAtHeight(req, env, height); err != nil {
+				return err
+			}
+		}
+
+		dump, err := GetPorcelainAPI(env).ActorDump(req.Context, tsKey, addr)
+		if err != nil {
+			return err
+		}
+		return re.Emit(dump)
+	},
+	Type: state.ActorDump{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, dump *state.ActorDump) error {
+			marshaled, err := json.MarshalIndent(dump, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(marshaled, '\n'))
+			return err
+		}),
+	},
+}
+
 func makeActorView(act *actor.Actor, addr string, actType exec.ExecutableActor) *ActorView {
 	var actorType string
 	var exports readableExports
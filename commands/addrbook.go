@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"io"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	"github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/addrbook"
+)
+
+var addrBookCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage named addresses of frequently used counterparties",
+		ShortDescription: `
+The address book maps names to addresses. Commands accepting an address also
+accept the name of an address book entry prefixed with '@', e.g. @alice.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": addrBookAddCmd,
+		"ls":  addrBookLsCmd,
+		"rm":  addrBookRmCmd,
+	},
+}
+
+var addrBookAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add a named address to the address book",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "Name of the entry"),
+		cmdkit.StringArg("address", true, false, "Address of the entry"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("type", "Kind of counterparty, e.g. miner or client"),
+		cmdkit.StringOption("notes", "Free-form notes about the counterparty"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := address.NewFromString(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		typ, _ := req.Options["type"].(string)
+		notes, _ := req.Options["notes"].(string)
+
+		entry := &addrbook.Entry{
+			Name:    req.Arguments[0],
+			Address: addr,
+			Type:    typ,
+			Notes:   notes,
+		}
+		if err := GetPorcelainAPI(env).AddrBookAdd(entry); err != nil {
+			return err
+		}
+		return re.Emit(entry)
+	},
+	Type: addrbook.Entry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, entry *addrbook.Entry) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("added %s%s: %s\n", addrBookPrefix, entry.Name, entry.Address)
+			return sw.Error()
+		}),
+	},
+}
+
+var addrBookRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove a named address from the address book",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "Name of the entry"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return GetPorcelainAPI(env).AddrBookRemove(req.Arguments[0])
+	},
+}
+
+var addrBookLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the address book",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		entries, err := GetPorcelainAPI(env).AddrBookLs()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := re.Emit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: addrbook.Entry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, entry *addrbook.Entry) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("%s%s\t%s\t%s\t%s\n", addrBookPrefix, entry.Name, entry.Address, entry.Type, entry.Notes)
+			return sw.Error()
+		}),
+	},
+}
@@ -0,0 +1,36 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/fixtures"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestAddrBook(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	addr := fixtures.TestAddresses[0]
+	d.RunSuccess("addrbook", "add", "alice", addr, "--type=client", "--notes=genesis funds")
+
+	ls := d.RunSuccess("addrbook", "ls").ReadStdoutTrimNewlines()
+	assert.Contains(t, ls, "@alice")
+	assert.Contains(t, ls, addr)
+	assert.Contains(t, ls, "genesis funds")
+
+	// Names resolve wherever an address is accepted.
+	byName := d.RunSuccess("wallet", "balance", "@alice").ReadStdoutTrimNewlines()
+	byAddr := d.RunSuccess("wallet", "balance", addr).ReadStdoutTrimNewlines()
+	assert.Equal(t, byAddr, byName)
+
+	d.RunFail("already has an entry", "addrbook", "add", "alice", addr)
+
+	d.RunSuccess("addrbook", "rm", "alice")
+	d.RunFail("no entry", "wallet", "balance", "@alice")
+}
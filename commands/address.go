@@ -1,18 +1,28 @@
 package commands
 
 import (
+	"bufio"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-ipfs-files"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
 var walletCmd = &cmds.Command{
@@ -23,6 +33,14 @@ var walletCmd = &cmds.Command{
 		"balance": balanceCmd,
 		"import":  walletImportCmd,
 		"export":  walletExportCmd,
+		"history": walletHistoryCmd,
+		"keyfile": walletKeyFileCmd,
+		"ls":      walletLsCmd,
+		"seed":    walletSeedCmd,
+		"encrypt": walletEncryptCmd,
+		"unlock":  walletUnlockCmd,
+		"lock":    walletLockCmd,
+		"ledger":  walletLedgerCmd,
 	},
 }
 
@@ -35,6 +53,7 @@ var addrsCmd = &cmds.Command{
 		"new":     addrsNewCmd,
 		"lookup":  addrsLookupCmd,
 		"default": defaultAddressCmd,
+		"label":   addrsLabelCmd,
 	},
 }
 
@@ -48,8 +67,21 @@ type AddressLsResult struct {
 }
 
 var addrsNewCmd = &cmds.Command{
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("type", "The type of key to create the address from: secp256k1 or bls").WithDefault(types.SECP256K1),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		addr, err := GetPorcelainAPI(env).WalletNewAddress()
+		var protocol address.Protocol
+		switch keyType, _ := req.Options["type"].(string); keyType {
+		case types.SECP256K1:
+			protocol = address.SECP256K1
+		case types.BLS:
+			protocol = address.BLS
+		default:
+			return errors.Errorf("unknown key type %q", keyType)
+		}
+
+		addr, err := GetPorcelainAPI(env).WalletNewAddress(protocol)
 		if err != nil {
 			return err
 		}
@@ -94,7 +126,7 @@ var addrsLookupCmd = &cmds.Command{
 		cmdkit.StringArg("address", true, false, "Miner address to find peerId for"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		addr, err := address.NewFromString(req.Arguments[0])
+		addr, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -115,7 +147,28 @@ var addrsLookupCmd = &cmds.Command{
 }
 
 var defaultAddressCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show or set the default address",
+		ShortDescription: `
+Messages are sent from the default address when no address is given. Without
+an argument, prints the default address. With an address of the wallet, makes
+it the default.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", false, false, "Wallet address to make the default"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if len(req.Arguments) > 0 {
+			addr, err := resolveAddr(env, req.Arguments[0])
+			if err != nil {
+				return err
+			}
+			if err := GetPorcelainAPI(env).WalletSetDefaultAddress(addr); err != nil {
+				return err
+			}
+		}
+
 		addr, err := GetPorcelainAPI(env).WalletDefaultAddress()
 		if err != nil {
 			return err
@@ -132,17 +185,96 @@ var defaultAddressCmd = &cmds.Command{
 	},
 }
 
+var addrsLabelCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Label an address of the wallet",
+		ShortDescription: `
+Adds an address book entry naming a wallet address, so that it can be given as
+@<name> to other commands. Remove labels with 'addrbook rm'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Wallet address to label, or @<name> of one"),
+		cmdkit.StringArg("name", true, false, "Label of the address"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		if err := GetPorcelainAPI(env).WalletLabel(addr, req.Arguments[1]); err != nil {
+			return err
+		}
+		return re.Emit(&addressResult{addr.String()})
+	},
+	Type: &addressResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, a *addressResult) error {
+			_, err := fmt.Fprintln(w, a.Address)
+			return err
+		}),
+	},
+}
+
+var walletLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the addresses of the wallet with their balances",
+		ShortDescription: `
+Prints each address of the wallet with its balance and labels. The default
+address is marked with a *.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		infos, err := GetPorcelainAPI(env).WalletLs(req.Context)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if err := re.Emit(info); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: porcelain.WalletAddressInfo{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, info *porcelain.WalletAddressInfo) error {
+			mark := " "
+			if info.Default {
+				mark = "*"
+			}
+			_, err := fmt.Fprintf(w, "%s %s\t%s\t%s\n", mark, info.Address, info.Balance, strings.Join(info.Labels, ","))
+			return err
+		}),
+	},
+}
+
 var balanceCmd = &cmds.Command{
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("address", true, false, "Address to get balance for"),
 	},
+	Options: append([]cmdkit.Option{
+		cmdkit.BoolOption("available", "Only count the balance not committed to messages pending in the message pool"),
+	}, tipSetOptions...),
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		addr, err := address.NewFromString(req.Arguments[0])
+		addr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		tsKey, err := tipSetKeyOption(req, env)
 		if err != nil {
 			return err
 		}
 
-		balance, err := GetPorcelainAPI(env).WalletBalance(req.Context, addr)
+		var balance *types.AttoFIL
+		if available, _ := req.Options["available"].(bool); available {
+			if tsKey.Len() > 0 {
+				return errors.New("--available only applies to the balance at the head")
+			}
+			balance, err = GetPorcelainAPI(env).WalletAvailableBalance(req.Context, addr)
+		} else {
+			balance, err = GetPorcelainAPI(env).WalletBalanceAt(req.Context, tsKey, addr)
+		}
 		if err != nil {
 			return err
 		}
@@ -254,3 +386,414 @@ var walletExportCmd = &cmds.Command{
 		}),
 	},
 }
+
+var walletHistoryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the messages on chain an address sent or received",
+		ShortDescription: `
+Prints the messages an address sent or received, newest first, with the height
+of their block, their status (applied, failed or skipped when they were
+included but not applied) and the gas cost paid by their sender. Use --offset
+and --limit to page through long histories.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Address to show the history of"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("offset", "Number of newest messages to skip").WithDefault(uint(0)),
+		cmdkit.UintOption("limit", "Maximum number of messages to show, 0 for all").WithDefault(uint(20)),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		offset, _ := req.Options["offset"].(uint)
+		limit, _ := req.Options["limit"].(uint)
+
+		entries, err := GetPorcelainAPI(env).MessageHistory(req.Context, addr, offset, limit)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := re.Emit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: msg.HistoryEntry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, entry *msg.HistoryEntry) error {
+			direction, other := "in", entry.Message.From
+			if entry.Sent {
+				direction, other = "out", entry.Message.To
+			}
+			_, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Height, entry.Cid, direction, other, entry.Message.Value, entry.Status, entry.GasCost)
+			return err
+		}),
+	},
+}
+
+var walletKeyFileCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export and import keys in the versioned key file format",
+		ShortDescription: `
+Key files are JSON documents holding a version and a list of keys, each with
+its type (secp256k1 or bls), its hex encoded private key and its address. They
+move keys between nodes and other tools.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"export": walletKeyFileExportCmd,
+		"import": walletKeyFileImportCmd,
+	},
+}
+
+var walletKeyFileExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export keys of the wallet to a key file",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("addresses", true, true, "Addresses of keys to export").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addrs := make([]address.Address, len(req.Arguments))
+		for i, arg := range req.Arguments {
+			addr, err := address.NewFromString(arg)
+			if err != nil {
+				return err
+			}
+			addrs[i] = addr
+		}
+
+		kf, err := GetPorcelainAPI(env).WalletExportKeyFile(addrs)
+		if err != nil {
+			return err
+		}
+		return re.Emit(kf)
+	},
+	Type: &wallet.KeyFile{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, kf *wallet.KeyFile) error {
+			return json.NewEncoder(w).Encode(kf)
+		}),
+	},
+}
+
+var walletKeyFileImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import the keys of a key file into the wallet",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("keyFile", true, false, "Key file to import").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		iter := req.Files.Entries()
+		if !iter.Next() {
+			return fmt.Errorf("no file given: %s", iter.Err())
+		}
+
+		fi, ok := iter.Node().(files.File)
+		if !ok {
+			return fmt.Errorf("given file was not a files.File")
+		}
+
+		var kf wallet.KeyFile
+		if err := json.NewDecoder(fi).Decode(&kf); err != nil {
+			return errors.Wrap(err, "invalid key file")
+		}
+		if len(kf.Keys) == 0 {
+			return fmt.Errorf("no keys in key file")
+		}
+
+		addrs, err := GetPorcelainAPI(env).WalletImportKeyFile(&kf)
+		if err != nil {
+			return err
+		}
+
+		var alr AddressLsResult
+		for _, addr := range addrs {
+			alr.Addresses = append(alr.Addresses, addr.String())
+		}
+		return re.Emit(&alr)
+	},
+	Type: &AddressLsResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, addrs *AddressLsResult) error {
+			for _, addr := range addrs.Addresses {
+				if _, err := fmt.Fprintln(w, addr); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var walletSeedCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the seed addresses are derived from",
+		ShortDescription: `
+Once the wallet has a seed, new secp256k1 addresses are derived from it, so
+that they can be restored from the mnemonic of the seed if the repo is lost.
+Addresses created before the wallet had a seed are not derived from it, and
+must be backed up with 'wallet export'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"new":     walletSeedNewCmd,
+		"show":    walletSeedShowCmd,
+		"restore": walletSeedRestoreCmd,
+	},
+}
+
+// WalletMnemonicResult is the result of the wallet seed commands that show a
+// mnemonic.
+type WalletMnemonicResult struct {
+	Mnemonic string
+}
+
+var walletMnemonicEncoders = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, r *WalletMnemonicResult) error {
+		_, err := fmt.Fprintln(w, r.Mnemonic)
+		return err
+	}),
+}
+
+var walletSeedNewCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create the seed of the wallet and print its mnemonic",
+		ShortDescription: `
+Prints the mnemonic of the new seed, which should be written down and kept
+safe: anyone knowing it can spend the funds of the addresses derived from it.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		mnemonic, err := GetPorcelainAPI(env).WalletNewSeed()
+		if err != nil {
+			return err
+		}
+		return re.Emit(&WalletMnemonicResult{Mnemonic: mnemonic})
+	},
+	Type:     &WalletMnemonicResult{},
+	Encoders: walletMnemonicEncoders,
+}
+
+var walletSeedShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the mnemonic of the seed of the wallet to back it up",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		mnemonic, err := GetPorcelainAPI(env).WalletMnemonic()
+		if err != nil {
+			return err
+		}
+		return re.Emit(&WalletMnemonicResult{Mnemonic: mnemonic})
+	},
+	Type:     &WalletMnemonicResult{},
+	Encoders: walletMnemonicEncoders,
+}
+
+var walletSeedRestoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Restore the seed of the wallet and the addresses derived from it",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("mnemonic", true, true, "Words of the mnemonic of the seed").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("count", "Number of addresses to restore").WithDefault(uint(1)),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		count, _ := req.Options["count"].(uint)
+		addrs, err := GetPorcelainAPI(env).WalletRestoreSeed(strings.Join(req.Arguments, " "), count)
+		if err != nil {
+			return err
+		}
+
+		var alr AddressLsResult
+		for _, addr := range addrs {
+			alr.Addresses = append(alr.Addresses, addr.String())
+		}
+		return re.Emit(&alr)
+	},
+	Type: &AddressLsResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, addrs *AddressLsResult) error {
+			for _, addr := range addrs.Addresses {
+				if _, err := fmt.Fprintln(w, addr); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var walletEncryptCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Encrypt the keys of the wallet with a passphrase",
+		ShortDescription: `
+Encrypts the private keys and the seed of the wallet with a key derived from
+the passphrase, and locks the wallet.  A locked wallet can't sign messages
+until it is unlocked with 'wallet unlock'.  The passphrase can't be recovered:
+back up the keys first.
+
+The passphrase is prompted for, or read from the first line of stdin when it
+isn't a terminal.
+`,
+	},
+	PreRun: readPassphrase("Passphrase to encrypt the keys with: "),
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrase, err := passphraseFromRequest(req)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).WalletEncrypt(passphrase)
+	},
+}
+
+var walletUnlockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Unlock an encrypted wallet",
+		ShortDescription: `
+The passphrase is prompted for, or read from the first line of stdin when it
+isn't a terminal.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("timeout", "Duration after which the wallet locks again, or 0 to keep it unlocked until 'wallet lock'").WithDefault("5m"),
+	},
+	PreRun: readPassphrase("Passphrase: "),
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		timeout, err := time.ParseDuration(req.Options["timeout"].(string))
+		if err != nil {
+			return errors.Wrap(err, "invalid timeout")
+		}
+		passphrase, err := passphraseFromRequest(req)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).WalletUnlock(passphrase, timeout)
+	},
+}
+
+// readPassphrase returns a PreRun reading the passphrase of a command on the
+// client, by prompting for it when stdin is a terminal and else reading the
+// first line of stdin, and sending it to the daemon in the request body.  This
+// keeps passphrases out of the shell history, the process list and the urls
+// of the api.
+func readPassphrase(prompt string) func(*cmds.Request, cmds.Environment) error {
+	return func(req *cmds.Request, env cmds.Environment) error {
+		var passphrase []byte
+		if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+			if _, err := fmt.Fprint(os.Stderr, prompt); err != nil {
+				return err
+			}
+			var err error
+			passphrase, err = terminal.ReadPassword(fd)
+			if err != nil {
+				return errors.Wrap(err, "failed to read passphrase")
+			}
+			if _, err := fmt.Fprintln(os.Stderr); err != nil {
+				return err
+			}
+		} else {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && err != io.EOF {
+				return errors.Wrap(err, "failed to read passphrase")
+			}
+			passphrase = []byte(strings.TrimRight(line, "\r\n"))
+		}
+		if len(passphrase) == 0 {
+			return errors.New("passphrase must not be empty")
+		}
+
+		req.Files = files.NewMapDirectory(map[string]files.Node{
+			"passphrase": files.NewBytesFile(passphrase),
+		})
+		return nil
+	}
+}
+
+// passphraseFromRequest returns the passphrase sent by readPassphrase.
+func passphraseFromRequest(req *cmds.Request) (string, error) {
+	if req.Files == nil {
+		return "", errors.New("no passphrase given")
+	}
+	iter := req.Files.Entries()
+	if !iter.Next() {
+		return "", fmt.Errorf("no passphrase given: %s", iter.Err())
+	}
+
+	fi, ok := iter.Node().(files.File)
+	if !ok {
+		return "", fmt.Errorf("given passphrase was not a files.File")
+	}
+
+	passphrase, err := ioutil.ReadAll(fi)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read passphrase")
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("passphrase must not be empty")
+	}
+	return string(passphrase), nil
+}
+
+var walletLockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Lock an encrypted wallet",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return GetPorcelainAPI(env).WalletLock()
+	},
+}
+
+var walletLedgerCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the addresses of a Ledger device",
+		ShortDescription: `
+The keys of these addresses are held by a Ledger device running the Filecoin
+app, which signs messages after they are confirmed on its screen.  Requires
+wallet.ledger to be set in the config.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": walletLedgerAddCmd,
+	},
+}
+
+var walletLedgerAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add the address of a key of the Ledger device to the wallet",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("index", true, false, "Index of the key in the derivation path m/44'/461'/0'/0/index"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("confirm", "Display the address on the device and wait for the user to confirm it"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		index, err := strconv.ParseUint(req.Arguments[0], 10, 31)
+		if err != nil {
+			return errors.Wrap(err, "invalid key index")
+		}
+		confirm, _ := req.Options["confirm"].(bool)
+
+		addr, err := GetPorcelainAPI(env).WalletNewLedgerAddress(uint32(index), confirm)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&addressResult{addr.String()})
+	},
+	Type: &addressResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, a *addressResult) error {
+			_, err := fmt.Fprintln(w, a.Address)
+			return err
+		}),
+	},
+}
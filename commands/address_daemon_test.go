@@ -1,6 +1,7 @@
 package commands_test
 
 import (
+	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
@@ -48,12 +49,54 @@ func TestWalletBalance(t *testing.T) {
 	balance = d.RunSuccess("wallet", "balance", address.NetworkAddress.String())
 	assert.Equal(t, "9999900000", balance.ReadStdoutTrimNewlines())
 
+	t.Log("[success] available balance with no pending messages")
+	balance = d.RunSuccess("wallet", "balance", "--available", address.NetworkAddress.String())
+	assert.Equal(t, "9999900000", balance.ReadStdoutTrimNewlines())
+
 	t.Log("[success] newly generated one")
 	addrNew := d.RunSuccess("address new")
 	balance = d.RunSuccess("wallet", "balance", addrNew.ReadStdoutTrimNewlines())
 	assert.Equal(t, "0", balance.ReadStdoutTrimNewlines())
 }
 
+func TestWalletBalanceAtTipSet(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(
+		t,
+		th.DefaultAddress(fixtures.TestAddresses[0]),
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	to := d.CreateAddress()
+	d.RunSuccess("message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--gas-price", "1",
+		"--gas-limit", "300",
+		"--value", "10",
+		to,
+	)
+	block := d.RunSuccess("mining", "once", "--enc", "text").ReadStdoutTrimNewlines()
+
+	t.Log("[success] at the head")
+	balance := d.RunSuccess("wallet", "balance", to)
+	assert.Equal(t, "10", balance.ReadStdoutTrimNewlines())
+	balance = d.RunSuccess("wallet", "balance", "--tipset", block, to)
+	assert.Equal(t, "10", balance.ReadStdoutTrimNewlines())
+
+	t.Log("[success] before the message")
+	balance = d.RunSuccess("wallet", "balance", "--height", "0", to)
+	assert.Equal(t, "0", balance.ReadStdoutTrimNewlines())
+
+	t.Log("[failure] both a tipset and a height")
+	d.RunFail("only one of --tipset and --height", "wallet", "balance", "--tipset", block, "--height", "0", to)
+
+	t.Log("[failure] available balance at an earlier tipset")
+	d.RunFail("--available only applies", "wallet", "balance", "--available", "--height", "0", to)
+}
+
 func TestAddrLookupAndUpdate(t *testing.T) {
 	tf.IntegrationTest(t)
 
@@ -146,6 +189,44 @@ func TestWalletExportImportRoundTrip(t *testing.T) {
 
 }
 
+func TestWalletEncryptReadsPassphraseFromStdin(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	d.RunFail("Unknown Command", "wallet", "encrypt", "secret")
+
+	d.RunWithStdin(strings.NewReader("secret\n"), "wallet", "encrypt").AssertSuccess()
+	d.RunWithStdin(strings.NewReader("wrong\n"), "wallet", "unlock").AssertFail("wrong passphrase")
+	d.RunWithStdin(strings.NewReader("secret\n"), "wallet", "unlock").AssertSuccess()
+}
+
+func TestWalletKeyFileExportImport(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+	other := th.NewDaemon(t).Start()
+	defer other.ShutdownSuccess()
+
+	dw := d.RunSuccess("address", "ls").ReadStdoutTrimNewlines()
+	kf := d.RunSuccess("wallet", "keyfile", "export", dw).ReadStdoutTrimNewlines()
+	assert.Contains(t, kf, `"version":1`)
+
+	kff, err := ioutil.TempFile("", "keyfile")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Remove(kff.Name()))
+	}()
+	_, err = kff.WriteString(kf)
+	require.NoError(t, err)
+	require.NoError(t, kff.Close())
+
+	imported := other.RunSuccess("wallet", "keyfile", "import", kff.Name()).ReadStdoutTrimNewlines()
+	assert.Equal(t, dw, imported)
+}
+
 func TestWalletExportPrivateKeyConsistentDisplay(t *testing.T) {
 	tf.IntegrationTest(t)
 
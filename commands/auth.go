@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"io"
+	"time"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	"github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/auth"
+)
+
+var authCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the tokens authenticating api requests",
+		ShortDescription: `
+API tokens grant a permission, one of read, write, sign or admin, each
+including the ones before it:
+
+  read   reads the chain, the state and the node's status
+  write  changes the node's state without its keys, e.g. broadcasts signed
+         messages or connects to peers
+  sign   signs and sends messages with the node's keys
+  admin  everything, including configuring the node and exporting its keys
+
+Requests send tokens in an "Authorization: Bearer <token>" header, or the
+command line with --api-token or FIL_API_TOKEN.  Requests without a token to
+the api address are admin.  Requests without a token to the additional
+listeners of api.listeners have the permission of their listener, or none if
+it sets no permission.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create-token": authCreateTokenCmd,
+		"ls":           authLsCmd,
+		"revoke-token": authRevokeTokenCmd,
+	},
+}
+
+// AuthCreateTokenResult is the result of creating an api token.
+type AuthCreateTokenResult struct {
+	Token string      `json:"token"`
+	Info  *auth.Token `json:"info"`
+}
+
+var authCreateTokenCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an api token",
+		ShortDescription: `
+Prints a new api token granting the permission given.  The token is not
+stored and can't be shown again: revoke it and create another if it is lost.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "Name describing the holder of the token"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("permission", "Permission granted: read, write, sign or admin").WithDefault("read"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		perm, err := auth.ParsePermission(req.Options["permission"].(string))
+		if err != nil {
+			return err
+		}
+		token, info, err := GetPorcelainAPI(env).AuthTokenCreate(req.Arguments[0], perm)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&AuthCreateTokenResult{Token: token, Info: info})
+	},
+	Type: AuthCreateTokenResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *AuthCreateTokenResult) error {
+			sw := NewSilentWriter(w)
+			sw.Println(res.Token)
+			return sw.Error()
+		}),
+	},
+}
+
+var authLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the api tokens",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		toks, err := GetPorcelainAPI(env).AuthTokenLs()
+		if err != nil {
+			return err
+		}
+		for _, tok := range toks {
+			if err := re.Emit(tok); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: auth.Token{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, tok *auth.Token) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("%s\t%s\t%s\t%s\n", tok.ID, tok.Permission, time.Unix(tok.Created, 0).Format(time.RFC3339), tok.Name)
+			return sw.Error()
+		}),
+	},
+}
+
+var authRevokeTokenCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Revoke an api token",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("id", true, false, "ID of the token, as listed by auth ls"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return GetPorcelainAPI(env).AuthTokenRevoke(req.Arguments[0])
+	},
+}
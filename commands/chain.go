@@ -6,11 +6,20 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/net"
+	"github.com/filecoin-project/go-filecoin/plumbing/chainstats"
+	"github.com/filecoin-project/go-filecoin/plumbing/faults"
+	"github.com/filecoin-project/go-filecoin/plumbing/notary"
+	"github.com/filecoin-project/go-filecoin/plumbing/powerevents"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -19,8 +28,19 @@ var chainCmd = &cmds.Command{
 		Tagline: "Inspect the filecoin blockchain",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"head": chainHeadCmd,
-		"ls":   chainLsCmd,
+		"attestation":     chainAttestationCmd,
+		"attestations":    chainAttestationsCmd,
+		"faults":          chainFaultsCmd,
+		"fetch-progress":  chainFetchProgressCmd,
+		"force-sync":      chainForceSyncCmd,
+		"head":            chainHeadCmd,
+		"ls":              chainLsCmd,
+		"power-changes":   chainPowerChangesCmd,
+		"replay":          chainReplayCmd,
+		"stats":           chainStatsCmd,
+		"sync-candidates": chainSyncCandidatesCmd,
+		"sync-status":     chainSyncStatusCmd,
+		"upgrade-dry-run": chainUpgradeDryRunCmd,
 	},
 }
 
@@ -108,3 +128,520 @@ var chainLsCmd = &cmds.Command{
 		}),
 	},
 }
+
+var chainUpgradeDryRunCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Rehearse a protocol upgrade against the local chain",
+		ShortDescription: `
+Applies the state migration of an upcoming protocol upgrade and processes a
+range of the local chain under both the current and the upgraded rules. Reports
+every message whose outcome differs and how long migration and processing took.
+The chain and its state are not modified.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("upgrade", true, false, "name of the upgrade to rehearse"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("from", "height of the first tipset to process under the upgraded rules").WithDefault(uint64(1)),
+		cmdkit.Uint64Option("to", "height of the last tipset to process, defaults to the head"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		from, _ := req.Options["from"].(uint64)
+		to, _ := req.Options["to"].(uint64)
+
+		report, err := GetPorcelainAPI(env).ChainUpgradeDryRun(req.Context, req.Arguments[0], from, to)
+		if err != nil {
+			return err
+		}
+		return re.Emit(report)
+	},
+	Type: consensus.UpgradeDryRunReport{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *consensus.UpgradeDryRunReport) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("Upgrade %s: processed %d tipsets with %d messages\n", res.Upgrade, res.TipSets, res.Messages)
+			sw.Printf("Migration took %s\n", res.MigrationTime)
+			sw.Printf("Processing took %s under current rules, %s under upgraded rules\n", res.CurrentTime, res.UpgradedTime)
+			sw.Printf("%d diverging messages\n", len(res.Divergences))
+			for _, d := range res.Divergences {
+				sw.Printf("\theight %d message %s: current %s, upgraded %s\n", d.Height, d.Message, formatDryRunReceipt(d.Current), formatDryRunReceipt(d.Upgraded))
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var chainStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show samples of the chain's state size and message volume",
+		ShortDescription: `
+Lists the samples of the state tree size, actor counts and message volume the
+node records periodically as its head advances, oldest first.  The sampling
+interval and number of samples kept are set in the observability.chainStats
+section of the config.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("from", "height of the first sample to show"),
+		cmdkit.Uint64Option("to", "height of the last sample to show, defaults to the latest"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		from, _ := req.Options["from"].(uint64)
+		to, _ := req.Options["to"].(uint64)
+
+		samples, err := GetPorcelainAPI(env).ChainStatsSamples(from, to)
+		if err != nil {
+			return err
+		}
+		return re.Emit(samples)
+	},
+	Type: []*chainstats.Sample{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, samples []*chainstats.Sample) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("HEIGHT\tSTATE NODES\tSTATE BYTES\tACTORS\tMESSAGES\tMESSAGE BYTES\n")
+			for _, s := range samples {
+				sw.Printf("%d\t%d\t%d\t%d\t%d\t%d\n", s.Height, s.StateNodes, s.StateBytes, s.Actors, s.Messages, s.MessageBytes)
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var chainAttestationCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the node's latest signed attestation of its head",
+		ShortDescription: `
+Shows the latest attestation of its head the node signed as a notary.  A node
+is a notary if chain.notaryAddress is set to a wallet address, whose key signs
+an attestation of the head's height, tipset and state root every
+chain.notaryPeriod and publishes it to the network.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		att, err := GetPorcelainAPI(env).ChainAttestation()
+		if err != nil {
+			return err
+		}
+		return re.Emit(att)
+	},
+	Type: notary.Attestation{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, att *notary.Attestation) error {
+			return writeAttestations(w, []*notary.Attestation{att})
+		}),
+	},
+}
+
+var chainAttestationsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the latest attestations received from notaries",
+		ShortDescription: `
+Lists the latest validly signed attestation received from each notary on the
+network.  Notaries attesting a different tipset or state root at the same
+height are on a different chain than each other.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).ChainAttestations())
+	},
+	Type: []*notary.Attestation{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, atts []*notary.Attestation) error {
+			return writeAttestations(w, atts)
+		}),
+	},
+}
+
+func writeAttestations(w io.Writer, atts []*notary.Attestation) error {
+	sw := NewSilentWriter(w)
+	sw.Printf("NOTARY\tHEIGHT\tTIPSET\tSTATE ROOT\tTIMESTAMP\n")
+	for _, att := range atts {
+		sw.Printf("%s\t%d\t%s\t%s\t%d\n", att.Notary, att.Height, att.TipSet, att.StateRoot, att.Timestamp)
+	}
+	return sw.Error()
+}
+
+var chainFaultsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the consensus faults detected",
+		ShortDescription: `
+Lists the miners the node has seen announce two distinct blocks at the same
+height, with the cids of both blocks, ordered by height.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		recorded, err := GetPorcelainAPI(env).ChainFaults()
+		if err != nil {
+			return err
+		}
+		return re.Emit(recorded)
+	},
+	Type: []*faults.Fault{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, recorded []*faults.Fault) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("HEIGHT\tMINER\tFIRST BLOCK\tSECOND BLOCK\n")
+			for _, f := range recorded {
+				sw.Printf("%d\t%s\t%s\t%s\n", f.Height, f.Miner, f.First.Cid, f.Second.Cid)
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var chainPowerChangesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the changes in miners' power made by a tipset",
+		ShortDescription: `
+Shows the miners added, whose power increased or decreased, or that were
+slashed, between the tipset made of the given blocks, or the head if none are
+given, and its parent. With --watch, the changes made by each new head are
+shown as it arrives until the command is interrupted.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", false, true, "CIDs of the blocks of the tipset"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("watch", "w", "keep showing the changes made by new heads"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if watch, _ := req.Options["watch"].(bool); watch {
+			for change := range GetPorcelainAPI(env).ChainSubscribePowerChanges(req.Context) {
+				if err := re.Emit(change); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var tsKey types.SortedCidSet
+		if len(req.Arguments) == 0 {
+			head, err := GetPorcelainAPI(env).ChainHead()
+			if err != nil {
+				return err
+			}
+			tsKey = head.ToSortedCidSet()
+		} else {
+			var blks []cid.Cid
+			for _, arg := range req.Arguments {
+				c, err := cid.Parse(arg)
+				if err != nil {
+					return errors.Wrap(err, "invalid cid "+arg)
+				}
+				blks = append(blks, c)
+			}
+			tsKey = types.NewSortedCidSet(blks...)
+		}
+
+		changes, err := GetPorcelainAPI(env).ChainPowerChanges(req.Context, tsKey)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if err := re.Emit(change); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: powerevents.Change{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c *powerevents.Change) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("height %d: miner %s %s, power %s -> %s\n", c.Height, c.Miner, c.Kind, formatPower(c.Before), formatPower(c.After))
+			return sw.Error()
+		}),
+	},
+}
+
+func formatPower(power *types.BytesAmount) string {
+	if power == nil {
+		return "<none>"
+	}
+	return power.String()
+}
+
+var chainReplayCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Re-execute a tipset against the state of its parent",
+		ShortDescription: `
+Re-executes the messages of the tipset made of the given blocks against the
+state of its parent and shows the receipt and gas usage of each message and
+the state root computed. If it differs from the state root recorded for the
+tipset, the actors whose state differs are listed. Use it to diagnose state
+root mismatches. The chain's state is not modified.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CIDs of the blocks of the tipset"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("verbose", "v", "show the sends and state changes of each message"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var blks []cid.Cid
+		for _, arg := range req.Arguments {
+			c, err := cid.Parse(arg)
+			if err != nil {
+				return errors.Wrap(err, "invalid cid "+arg)
+			}
+			blks = append(blks, c)
+		}
+
+		replay, err := GetPorcelainAPI(env).ChainReplayTipSet(req.Context, types.NewSortedCidSet(blks...))
+		if err != nil {
+			return err
+		}
+		return re.Emit(replay)
+	},
+	Type: consensus.TipSetReplay{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *consensus.TipSetReplay) error {
+			verbose, _ := req.Options["verbose"].(bool)
+			sw := NewSilentWriter(w)
+			sw.Printf("Tipset %s at height %d\n", res.TipSet, res.Height)
+			sw.Printf("Parent state root: %s\n", res.ParentStateRoot)
+			for _, m := range res.Messages {
+				mCid, err := m.Message.Cid()
+				if err != nil {
+					return err
+				}
+				sw.Printf("Message %s: ", mCid)
+				switch {
+				case m.ApplyError != "":
+					sw.Printf("apply error: %s", m.ApplyError)
+				case m.ExecutionError != "":
+					sw.Printf("exit %d, execution error: %s", m.Receipt.ExitCode, m.ExecutionError)
+				default:
+					sw.Printf("exit %d", m.Receipt.ExitCode)
+				}
+				sw.Printf(", gas used %d\n", m.GasUsed)
+				if verbose {
+					if m.Trace != nil {
+						printSendTrace(sw, m.Trace, 1)
+					}
+					for _, change := range m.StateChanges {
+						sw.Printf("\t%s: %s -> %s\n", change.Address, formatReplayActor(change.Before), formatReplayActor(change.After))
+					}
+				}
+			}
+			sw.Printf("Gas used: %d\n", res.GasUsed)
+			sw.Printf("Computed state root: %s\n", res.StateRoot)
+			sw.Printf("Recorded state root: %s\n", res.RecordedStateRoot)
+			if res.Matches() {
+				sw.Println("State roots match")
+				return sw.Error()
+			}
+			sw.Println("State roots differ:")
+			for _, d := range res.Diff {
+				sw.Printf("\t%s\n", d.Address)
+				sw.Printf("\t\trecorded: %s\n", formatReplayActor(d.Recorded))
+				sw.Printf("\t\tcomputed: %s\n", formatReplayActor(d.Computed))
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var chainFetchProgressCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the progress of fetching blocks from the network",
+		ShortDescription: `
+Shows how many blocks the node has requested and received from its peers since
+it started, and the height of the blocks last received.  Syncing fetches the
+chain from the head down, so during the initial sync the height decreases
+towards the node's previous head.  With --watch, progress is shown whenever it
+changes until the command is interrupted.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("watch", "w", "keep showing progress as it changes"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		watch, _ := req.Options["watch"].(bool)
+		if !watch {
+			return re.Emit(GetPorcelainAPI(env).ChainFetchProgress())
+		}
+
+		for progress := range GetPorcelainAPI(env).ChainSubscribeFetchProgress(req.Context) {
+			if err := re.Emit(progress); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: net.FetchProgress{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, p *net.FetchProgress) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("height %d: received %d blocks (%d bytes), requested %d blocks and %d tipsets of ancestors\n",
+				p.Height, p.BlocksReceived, p.BytesReceived, p.BlocksRequested, p.TipSetsRequested)
+			return sw.Error()
+		}),
+	},
+}
+
+var chainForceSyncCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sync to a tipset ignoring the reorg depth limit",
+		ShortDescription: `
+Syncs the chain whose head is the tipset made of the given blocks, switching to
+it if it is heavier than the head even if it forked from the head's chain
+further back than chain.maxReorgDepth rounds.  The node rejects such chains on
+its own to protect against long-range attacks, so only force a chain known to
+be canonical.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CIDs of the blocks of the tipset"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var blks []cid.Cid
+		for _, arg := range req.Arguments {
+			c, err := cid.Parse(arg)
+			if err != nil {
+				return errors.Wrap(err, "invalid cid "+arg)
+			}
+			blks = append(blks, c)
+		}
+		return GetPorcelainAPI(env).ChainForceSync(req.Context, types.NewSortedCidSet(blks...))
+	},
+}
+
+var chainSyncCandidatesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the heads announced by peers that the node may sync",
+		ShortDescription: `
+Lists the heads peers announced when connecting, heaviest first by the weight
+they claim, with the peers that announced them.  The node syncs the heaviest
+head first, and the next heaviest when a sync ends.  Heads that failed to sync
+are listed with their error until announced again.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).ChainSyncCandidates())
+	},
+	Type: []chain.SyncTarget{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, targets *[]chain.SyncTarget) error {
+			sw := NewSilentWriter(w)
+			for _, t := range *targets {
+				sw.Printf("%s height %d weight %d from %d peers", t.TipSet.String(), t.Height, t.ParentWeight, len(t.Peers))
+				if t.Syncing {
+					sw.Print(" (syncing)")
+				}
+				if t.Error != "" {
+					sw.Printf(" failed: %s", t.Error)
+				}
+				sw.Println()
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var chainSyncStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the status of syncing the chain",
+		ShortDescription: `
+Shows whether the node is syncing a chain and, if it is, the stage of the sync:
+fetching the chain from its head back to a tipset the node has, or validating
+the fetched tipsets and running their messages.  Progress is shown as heights
+with the rate tipsets are processed at and an estimate of the time left in the
+stage.  When the node is idle the last sync is shown, with its error if it
+failed.  With --watch, the status is shown whenever it changes until the
+command is interrupted.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("watch", "w", "keep showing the status as it changes"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		watch, _ := req.Options["watch"].(bool)
+		if !watch {
+			return re.Emit(GetPorcelainAPI(env).ChainSyncStatus())
+		}
+
+		for status := range GetPorcelainAPI(env).ChainSubscribeSyncStatus(req.Context) {
+			if err := re.Emit(status); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: chain.SyncStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *chain.SyncStatus) error {
+			sw := NewSilentWriter(w)
+			switch s.Stage {
+			case chain.SyncIdle:
+				if s.Target.Empty() {
+					sw.Println("idle")
+				} else if s.Error != "" {
+					sw.Printf("idle: sync of %s failed: %s\n", s.Target.String(), s.Error)
+				} else {
+					sw.Printf("idle: synced %s at height %d\n", s.Target.String(), s.Height)
+				}
+			default:
+				sw.Printf("%s %s: height %d (base %d, target %d), %.1f tipsets/s", s.Stage, s.Target.String(), s.Height, s.BaseHeight, s.TargetHeight, s.TipSetsPerSecond)
+				if s.ETA > 0 {
+					sw.Printf(", %s left", s.ETA.Round(time.Second))
+				}
+				sw.Println()
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+func formatDryRunReceipt(r *types.MessageReceipt) string {
+	if r == nil {
+		return "not applied"
+	}
+	return fmt.Sprintf("exit %d", r.ExitCode)
+}
+
+// tipSetOptions select the tipset whose state a command reads, instead of
+// the head.
+var tipSetOptions = []cmdkit.Option{
+	cmdkit.StringOption("tipset", "Read the state of the tipset made of these comma separated block CIDs"),
+	cmdkit.UintOption("height", "Read the state of the tipset of the chain at this height, or before it if there is none at this height"),
+}
+
+// tipSetKeyOption returns the key of the tipset selected by the tipset or
+// height option of req, or an empty key, meaning the head, if neither is set.
+func tipSetKeyOption(req *cmds.Request, env cmds.Environment) (types.SortedCidSet, error) {
+	tipset, _ := req.Options["tipset"].(string)
+	height, hasHeight := req.Options["height"].(uint)
+	if tipset != "" && hasHeight {
+		return types.SortedCidSet{}, errors.New("only one of --tipset and --height may be given")
+	}
+	if hasHeight {
+		return tipSetKeyAtHeight(req, env, height)
+	}
+
+	var blks []cid.Cid
+	if tipset != "" {
+		for _, s := range strings.Split(tipset, ",") {
+			c, err := cid.Parse(strings.TrimSpace(s))
+			if err != nil {
+				return types.SortedCidSet{}, errors.Wrap(err, "invalid cid "+s)
+			}
+			blks = append(blks, c)
+		}
+	}
+	return types.NewSortedCidSet(blks...), nil
+}
+
+// tipSetKeyAtHeight returns the key of the tipset of the chain at height, or
+// of the one before it if there is none at height.
+func tipSetKeyAtHeight(req *cmds.Request, env cmds.Environment, height uint) (types.SortedCidSet, error) {
+	ts, err := GetPorcelainAPI(env).ChainGetTipSetAtHeight(req.Context, uint64(height))
+	if err != nil {
+		return types.SortedCidSet{}, err
+	}
+	return ts.ToSortedCidSet(), nil
+}
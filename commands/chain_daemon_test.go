@@ -5,12 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/fixtures"
+	"github.com/filecoin-project/go-filecoin/net"
+	"github.com/filecoin-project/go-filecoin/plumbing/chainstats"
+	"github.com/filecoin-project/go-filecoin/plumbing/notary"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -128,3 +132,76 @@ func TestChainLs(t *testing.T) {
 		assert.Contains(t, chainLsResult, `"nonce":"0"`)
 	})
 }
+
+func TestChainStats(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := makeTestDaemonWithMinerAndStart(t)
+	defer d.ShutdownSuccess()
+
+	d.RunSuccess("mining", "once")
+
+	// Samples are taken in the background as the head advances.
+	var samples []chainstats.Sample
+	for i := 0; i < 50 && len(samples) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+		out := d.RunSuccess("chain", "stats", "--enc", "json").ReadStdoutTrimNewlines()
+		require.NoError(t, json.Unmarshal([]byte(out), &samples))
+	}
+	require.Len(t, samples, 1)
+	assert.Equal(t, uint64(1), samples[0].Height)
+	assert.True(t, samples[0].StateBytes > 0)
+	assert.True(t, samples[0].Actors > 0)
+}
+
+func TestChainAttestation(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	d.RunFail("node is not a notary", "chain", "attestation")
+
+	notaryAddr := d.CreateAddress()
+	d.RunSuccess("config", "chain.notaryAddress", fmt.Sprintf("%q", notaryAddr))
+	d.RunSuccess("config", "chain.notaryPeriod", `"100ms"`)
+	d.Restart()
+
+	// Attestations are signed in the background every period.
+	var att notary.Attestation
+	for i := 0; i < 50; i++ {
+		time.Sleep(100 * time.Millisecond)
+		out := d.Run("chain", "attestation", "--enc", "json")
+		if out.Error == nil && out.Code == 0 {
+			require.NoError(t, json.Unmarshal([]byte(out.ReadStdoutTrimNewlines()), &att))
+			break
+		}
+	}
+	assert.Equal(t, notaryAddr, att.Notary.String())
+	assert.NoError(t, att.Verify())
+
+	var head []cid.Cid
+	require.NoError(t, json.Unmarshal([]byte(d.RunSuccess("chain", "head", "--enc", "json").ReadStdoutTrimNewlines()), &head))
+	assert.Equal(t, types.NewSortedCidSet(head...).String(), att.TipSet.String())
+}
+
+func TestChainFetchProgress(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	miner := makeTestDaemonWithMinerAndStart(t)
+	defer miner.ShutdownSuccess()
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+	miner.ConnectSuccess(d)
+
+	// The daemon fetches the mined block from the miner.
+	miner.MineAndPropagate(10*time.Second, d)
+
+	var progress net.FetchProgress
+	out := d.RunSuccess("chain", "fetch-progress", "--enc", "json").ReadStdoutTrimNewlines()
+	require.NoError(t, json.Unmarshal([]byte(out), &progress))
+	assert.True(t, progress.BlocksReceived > 0)
+	assert.True(t, progress.BytesReceived > 0)
+	assert.Equal(t, uint64(1), progress.Height)
+}
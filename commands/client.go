@@ -10,7 +10,6 @@ import (
 	"github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-ipfs-files"
 
-	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -125,7 +124,7 @@ be 2, 1 hour would be 120, and 1 day would be 2880.
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		allowDuplicates, _ := req.Options["allow-duplicates"].(bool)
 
-		miner, err := address.NewFromString(req.Arguments[0])
+		miner, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -2,7 +2,9 @@ package commands
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof" // nolint: golint
 	"os"
@@ -18,11 +20,15 @@ import (
 	"github.com/multiformats/go-multiaddr-net"
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/auth"
 	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/gateway"
+	"github.com/filecoin-project/go-filecoin/graphql"
 	"github.com/filecoin-project/go-filecoin/mining"
 	"github.com/filecoin-project/go-filecoin/node"
 	"github.com/filecoin-project/go-filecoin/paths"
 	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/rpc"
 )
 
 // exposed here, to be available during testing
@@ -39,6 +45,7 @@ var daemonCmd = &cmds.Command{
 		cmdkit.BoolOption(ELStdout),
 		cmdkit.BoolOption(IsRelay, "advertise and allow filecoin network traffic to be relayed through this node"),
 		cmdkit.StringOption(BlockTime, "time a node waits before trying to mine the next block").WithDefault(mining.DefaultBlockTime.String()),
+		cmdkit.StringOption(MetricsAddress, "multiaddress to serve Prometheus metrics on at /metrics, enabling them"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		return daemonRun(req, re, env)
@@ -73,6 +80,11 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment)
 		rep.Config().Swarm.PublicRelayAddress = publicRelayAddress
 	}
 
+	if metricsAddress, ok := req.Options[MetricsAddress].(string); ok && metricsAddress != "" {
+		rep.Config().Observability.Metrics.PrometheusEnabled = true
+		rep.Config().Observability.Metrics.PrometheusEndpoint = metricsAddress
+	}
+
 	opts, err := node.OptionsFromRepo(rep)
 	if err != nil {
 		return err
@@ -152,34 +164,101 @@ func runAPIAndWait(ctx context.Context, nd *node.Node, config *config.Config, re
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	defer signal.Stop(sigCh)
 
-	maddr, err := ma.NewMultiaddr(config.API.Address)
-	if err != nil {
-		return err
-	}
-
 	// For the case when /ip4/127.0.0.1/tcp/0 is passed,
 	// we want to fetch the new multiaddr from the listener, as it may (should)
 	// have resolved to some other value. i.e. resolve port zero to real value.
-	apiLis, err := manet.Listen(maddr)
+	apiLis, apiAddr, err := listenAPI(config.API.Address, "", "")
 	if err != nil {
 		return err
 	}
-	config.API.Address = apiLis.Multiaddr().String()
+	config.API.Address = apiAddr.String()
+
+	// Requests without a token to the additional listeners have the
+	// permission of their listener.
+	var extraPerms []auth.Permission
+	for _, lcfg := range config.API.Listeners {
+		perm := auth.PermNone
+		if lcfg.Permission != "" {
+			if perm, err = auth.ParsePermission(lcfg.Permission); err != nil {
+				apiLis.Close() // nolint: errcheck
+				return errors.Wrapf(err, "invalid permission of api listener %s", lcfg.Address)
+			}
+		}
+		extraPerms = append(extraPerms, perm)
+	}
+
+	var extraLis []net.Listener
+	for _, lcfg := range config.API.Listeners {
+		lis, _, err := listenAPI(lcfg.Address, lcfg.TLSCertFile, lcfg.TLSKeyFile)
+		if err != nil {
+			for _, l := range append(extraLis, apiLis) {
+				l.Close() // nolint: errcheck
+			}
+			return errors.Wrapf(err, "failed to listen on api address %s", lcfg.Address)
+		}
+		extraLis = append(extraLis, lis)
+	}
+
+	apiHandler := cmdhttp.NewHandler(servenv, rootCmdDaemon, cfg)
+
+	rpcServer := rpc.NewServer()
+	if err := rpc.RegisterAPI(rpcServer, nd.PorcelainAPI); err != nil {
+		return errors.Wrap(err, "failed to register rpc methods")
+	}
+
+	// The gateway's paths are under the api prefix but don't collide with
+	// commands, and take precedence over it as they are longer.
+	gw := gateway.New(nd.PorcelainAPI)
+	var gql http.Handler
+	if config.API.GraphQL {
+		gql = graphql.Handler(graphql.NewChainSchema(nd.PorcelainAPI))
+	}
 
 	handler := http.NewServeMux()
 	handler.Handle("/debug/pprof/", http.DefaultServeMux)
-	handler.Handle(APIPrefix+"/", cmdhttp.NewHandler(servenv, rootCmdDaemon, cfg))
+	handler.Handle(APIPrefix+"/", apiHandler)
+	handler.Handle(RPCPath, rpcServer)
+	for _, p := range gateway.Paths {
+		handler.Handle(p, gw)
+	}
+	if gql != nil {
+		handler.Handle(GraphQLPath, gql)
+	}
 
+	// The additional listeners may be exposed beyond the local machine, so
+	// they only serve the api, to the extent the permission of each request
+	// allows.  The rpc server checks the permission of each method.
+	extraHandler := http.NewServeMux()
+	extraHandler.Handle(APIPrefix+"/", auth.Restrict(requestPermission, apiHandler))
+	extraHandler.Handle(RPCPath, rpcServer)
+	for _, p := range gateway.Paths {
+		extraHandler.Handle(p, auth.Restrict(auth.Requires(auth.PermRead), gw))
+	}
+	if gql != nil {
+		extraHandler.Handle(GraphQLPath, auth.Restrict(auth.Requires(auth.PermRead), gql))
+	}
+
+	// The local api is admin unless a request's token says otherwise.
 	apiserv := http.Server{
-		Handler: handler,
+		Handler: auth.NewHandler(nd.PorcelainAPI, auth.PermAdmin, handler),
+	}
+	var extraservs []*http.Server
+	for _, perm := range extraPerms {
+		extraservs = append(extraservs, &http.Server{
+			Handler: auth.NewHandler(nd.PorcelainAPI, perm, extraHandler),
+		})
 	}
 
-	go func() {
-		err := apiserv.Serve(manet.NetListener(apiLis))
+	serve := func(srv *http.Server, lis net.Listener) {
+		err := srv.Serve(lis)
 		if err != nil && err != http.ErrServerClosed {
 			panic(err)
 		}
-	}()
+	}
+	go serve(&apiserv, apiLis)
+	for i, lis := range extraLis {
+		go serve(extraservs[i], lis)
+	}
 
 	// write our api address to file
 	if err := nd.Repo.SetAPIAddr(config.API.Address); err != nil {
@@ -196,6 +275,40 @@ func runAPIAndWait(ctx context.Context, nd *node.Node, config *config.Config, re
 	if err := apiserv.Shutdown(ctx); err != nil {
 		fmt.Println("failed to shut down api server:", err)
 	}
+	for _, srv := range extraservs {
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Println("failed to shut down api server:", err)
+		}
+	}
 
 	return nil
 }
+
+// listenAPI opens a listener for the api on address, wrapping it in TLS if a
+// certificate file is given.  It returns the listener and the address it is
+// bound to.
+func listenAPI(address, tlsCertFile, tlsKeyFile string) (net.Listener, ma.Multiaddr, error) {
+	maddr, err := ma.NewMultiaddr(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	mlis, err := manet.Listen(maddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	lis := manet.NetListener(mlis)
+	if tlsCertFile == "" {
+		return lis, mlis.Multiaddr(), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+	if err != nil {
+		lis.Close() // nolint: errcheck
+		return nil, nil, errors.Wrap(err, "failed to load api TLS certificate")
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return tls.NewListener(lis, tlsCfg), mlis.Multiaddr(), nil
+}
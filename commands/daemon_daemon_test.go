@@ -1,11 +1,16 @@
 package commands_test
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr-net"
@@ -124,3 +129,72 @@ func TestDaemonOverHttp(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, http.StatusNotFound, res.StatusCode)
 }
+
+func TestDaemonAPIListeners(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	dir, err := ioutil.TempDir("", "fcapi")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	sock := filepath.Join(dir, "api.sock")
+
+	td := th.NewDaemon(t).Start()
+	defer td.ShutdownSuccess()
+
+	td.RunSuccess("config", "api.listeners", fmt.Sprintf(`[{"address": "/unix%s", "permission": "read"}]`, sock))
+	td.Restart()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+			},
+		},
+	}
+
+	res, err := client.Post("http://unix/api/id", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Requests beyond the listener's permission need a token.
+	res, err = client.Post("http://unix/api/config?arg=api", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	// The additional listeners do not serve the debug endpoints.
+	res, err = client.Get("http://unix/debug/pprof/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestDaemonPrometheusMetrics(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	port, err := th.GetFreePort()
+	require.NoError(t, err)
+
+	td := th.NewDaemon(t).Start()
+	defer td.ShutdownSuccess()
+
+	td.RunSuccess("config", "observability.metrics", fmt.Sprintf(`{"prometheusEnabled": true, "reportInterval": "1s", "prometheusEndpoint": "/ip4/127.0.0.1/tcp/%d"}`, port))
+	td.Restart()
+
+	scrape := func() string {
+		res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", port))
+		require.NoError(t, err)
+		defer res.Body.Close() // nolint: errcheck
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	// Metrics are exported once per report interval.
+	body := scrape()
+	for i := 0; i < 10 && !strings.Contains(body, "filecoin_chain_head_height"); i++ {
+		time.Sleep(500 * time.Millisecond)
+		body = scrape()
+	}
+	assert.Contains(t, body, "filecoin_chain_head_height")
+	assert.Contains(t, body, "go_goroutines")
+}
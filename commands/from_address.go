@@ -1,14 +1,34 @@
 package commands
 
 import (
+	"strings"
+
+	"github.com/ipfs/go-ipfs-cmds"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
 )
 
-func optionalAddr(o interface{}) (ret address.Address, err error) {
+// addrBookPrefix marks an address argument as the name of an address book
+// entry, e.g. `--to @alice`.
+const addrBookPrefix = "@"
+
+// resolveAddr parses s as an address or, if it starts with addrBookPrefix,
+// looks the address up in the address book.
+func resolveAddr(env cmds.Environment, s string) (address.Address, error) {
+	if !strings.HasPrefix(s, addrBookPrefix) {
+		return address.NewFromString(s)
+	}
+	entry, err := GetPorcelainAPI(env).AddrBookGet(strings.TrimPrefix(s, addrBookPrefix))
+	if err != nil {
+		return address.Undef, err
+	}
+	return entry.Address, nil
+}
+
+func optionalAddr(env cmds.Environment, o interface{}) (ret address.Address, err error) {
 	if o != nil {
-		ret, err = address.NewFromString(o.(string))
+		ret, err = resolveAddr(env, o.(string))
 		if err != nil {
 			err = errors.Wrap(err, "invalid from address")
 		}
@@ -22,7 +22,7 @@ func TestOptionalAddr(t *testing.T) {
 		require.NoError(t, err)
 		opts["from"] = specifiedAddr.String()
 
-		addr, err := optionalAddr(opts["from"])
+		addr, err := optionalAddr(nil, opts["from"])
 		require.NoError(t, err)
 		assert.Equal(t, specifiedAddr, addr)
 	})
@@ -31,7 +31,7 @@ func TestOptionalAddr(t *testing.T) {
 
 		opts := make(cmdkit.OptMap)
 
-		addr, err := optionalAddr(opts["from"])
+		addr, err := optionalAddr(nil, opts["from"])
 		require.NoError(t, err)
 		assert.Equal(t, address.Undef, addr)
 	})
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -20,6 +21,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/fixtures"
+	"github.com/filecoin-project/go-filecoin/gengen/util"
 	"github.com/filecoin-project/go-filecoin/node"
 	"github.com/filecoin-project/go-filecoin/paths"
 	"github.com/filecoin-project/go-filecoin/repo"
@@ -32,6 +34,8 @@ var initCmd = &cmds.Command{
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption(GenesisFile, "path of file or HTTP(S) URL containing archive of genesis block DAG data"),
+		cmdkit.StringOption(GenesisSpec, "path of json genesis configuration (see gengen) from which to generate the genesis block, instead of loading it from a genesis file"),
+		cmdkit.IntOption(GenesisSeed, "seed used when generating the genesis block from a genesis configuration. All nodes of a network must use the same seed").WithDefault(0),
 		cmdkit.StringOption(PeerKeyFile, "path of file containing key to use for new node's libp2p identity"),
 		cmdkit.StringOption(WithMiner, "when set, creates a custom genesis block with a pre generated miner account, requires running the daemon using dev mode (--dev)"),
 		cmdkit.StringOption(OptionSectorDir, "path of directory into which staged and sealed sectors will be written"),
@@ -63,7 +67,17 @@ var initCmd = &cmds.Command{
 		defer rep.Close() // nolint: errcheck
 
 		genesisFileSource, _ := req.Options[GenesisFile].(string)
-		genesisFile, err := loadGenesis(req.Context, rep, genesisFileSource)
+		genesisSpec, _ := req.Options[GenesisSpec].(string)
+		var genesisFile consensus.GenesisInitFunc
+		if genesisSpec != "" {
+			if genesisFileSource != "" {
+				return fmt.Errorf("cannot specify both %s and %s", GenesisFile, GenesisSpec)
+			}
+			genesisSeed, _ := req.Options[GenesisSeed].(int)
+			genesisFile, err = loadGenesisSpec(genesisSpec, int64(genesisSeed))
+		} else {
+			genesisFile, err = loadGenesis(req.Context, rep, genesisFileSource)
+		}
 		if err != nil {
 			return err
 		}
@@ -197,6 +211,23 @@ func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (consens
 	return gif, nil
 }
 
+// loadGenesisSpec reads the genesis configuration at path and returns a
+// genesis function generating the block it describes.
+func loadGenesisSpec(path string, seed int64) (consensus.GenesisInitFunc, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() // nolint: errcheck
+
+	var cfg gengen.GenesisCfg
+	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse genesis configuration: %s", err)
+	}
+
+	return gengen.MakeGenesisFunc(&cfg, seed), nil
+}
+
 func getNodeInitOpts(autoSealIntervalSeconds uint, peerKeyFile string) ([]node.InitOpt, error) {
 	var initOpts []node.InitOpt
 	if peerKeyFile != "" {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"syscall"
@@ -25,6 +26,10 @@ const (
 	// OptionAPI is the name of the option for specifying the api port.
 	OptionAPI = "cmdapiaddr"
 
+	// OptionAPIToken is the name of the option for specifying the token
+	// authenticating requests to the api.
+	OptionAPIToken = "api-token"
+
 	// OptionRepoDir is the name of the option for specifying the directory of the repo.
 	OptionRepoDir = "repodir"
 
@@ -34,6 +39,14 @@ const (
 	// APIPrefix is the prefix for the http version of the api.
 	APIPrefix = "/api"
 
+	// RPCPath is the path of the JSON-RPC 2.0 endpoint, over HTTP and
+	// websockets.
+	RPCPath = "/rpc"
+
+	// GraphQLPath is the path of the GraphQL endpoint, served when
+	// api.graphql is set in the config.
+	GraphQLPath = "/graphql"
+
 	// OfflineMode tells us if we should try to connect this Filecoin node to the network
 	OfflineMode = "offline"
 
@@ -46,6 +59,10 @@ const (
 	// SwarmAddress is the multiaddr for this Filecoin node
 	SwarmAddress = "swarmlisten"
 
+	// MetricsAddress is the multiaddr the daemon serves Prometheus metrics
+	// on, which enables them.
+	MetricsAddress = "metrics-address"
+
 	// SwarmPublicRelayAddress is a public address that the filecoin node
 	// will listen on if it is operating as a relay.  We use this to specify
 	// the public ip:port of a relay node that is sitting behind a static
@@ -69,6 +86,12 @@ const (
 	// GenesisFile is the path of file containing archive of genesis block DAG data
 	GenesisFile = "genesisfile"
 
+	// GenesisSpec is the path of a json genesis configuration from which the genesis block is generated
+	GenesisSpec = "genesis-spec"
+
+	// GenesisSeed is the seed used to generate the genesis block from a genesis configuration
+	GenesisSeed = "genesis-seed"
+
 	// DevnetTest populates config bootstrap addrs with the dns multiaddrs of the test devnet and other test devnet specific bootstrap parameters
 	DevnetTest = "devnet-test"
 
@@ -92,8 +115,10 @@ START RUNNING FILECOIN
   go-filecoin init                   - Initialize a filecoin repo
   go-filecoin config <key> [<value>] - Get and set filecoin config values
   go-filecoin daemon                 - Start a long-running daemon process
+  go-filecoin auth                   - Manage the tokens authenticating api requests
   go-filecoin wallet                 - Manage your filecoin wallets
   go-filecoin address                - Interact with addresses
+  go-filecoin addrbook               - Manage named addresses of counterparties
 
 STORE AND RETRIEVE DATA
   go-filecoin client                 - Make deals, store data, retrieve data
@@ -120,6 +145,8 @@ NETWORK COMMANDS
 ACTOR COMMANDS
   go-filecoin actor                  - Interact with actors. Actors are built-in smart contracts
   go-filecoin paych                  - Payment channel operations
+  go-filecoin multisig               - Multisig wallet operations
+  go-filecoin reward                 - Show the funds and parameters of block rewards
 
 MESSAGE COMMANDS
   go-filecoin message                - Manage messages
@@ -134,6 +161,7 @@ TOOL COMMANDS
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption(OptionAPI, "set the api port to use"),
+		cmdkit.StringOption(OptionAPIToken, "set the token authenticating api requests, defaults to $FIL_API_TOKEN"),
 		cmdkit.StringOption(OptionRepoDir, "set the repo directory, defaults to ~/.filecoin/repo"),
 		cmds.OptionEncodingType,
 		cmdkit.BoolOption("help", "Show the full command help text."),
@@ -158,6 +186,8 @@ var rootSubcmdsLocal = map[string]*cmds.Command{
 var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"actor":            actorCmd,
 	"address":          addrsCmd,
+	"addrbook":         addrBookCmd,
+	"auth":             authCmd,
 	"bitswap":          bitswapCmd,
 	"bootstrap":        bootstrapCmd,
 	"chain":            chainCmd,
@@ -172,11 +202,13 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"miner":            minerCmd,
 	"mining":           miningCmd,
 	"mpool":            mpoolCmd,
+	"multisig":         multisigCmd,
 	"outbox":           outboxCmd,
 	"paych":            paymentChannelCmd,
 	"ping":             pingCmd,
 	"protocol":         protocolCmd,
 	"retrieval-client": retrievalClientCmd,
+	"reward":           rewardCmd,
 	"show":             showCmd,
 	"stats":            statsCmd,
 	"swarm":            swarmCmd,
@@ -211,8 +243,9 @@ func buildEnv(ctx context.Context, req *cmds.Request) (cmds.Environment, error)
 }
 
 type executor struct {
-	api  string
-	exec cmds.Executor
+	api   string
+	token string
+	exec  cmds.Executor
 }
 
 func (e *executor) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -220,7 +253,7 @@ func (e *executor) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.
 		return e.exec.Execute(req, re, env)
 	}
 
-	client := cmdhttp.NewClient(e.api, cmdhttp.ClientWithAPIPrefix(APIPrefix))
+	client := newAPIClient(e.api, e.token)
 
 	res, err := client.Send(req)
 	if err != nil {
@@ -238,6 +271,24 @@ func (e *executor) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.
 	return nil
 }
 
+// newAPIClient returns a commands client for the api at address, sending
+// token with its requests if it isn't empty.
+func newAPIClient(address, token string) cmdhttp.Client {
+	if token == "" {
+		return cmdhttp.NewClient(address, cmdhttp.ClientWithAPIPrefix(APIPrefix))
+	}
+
+	// The commands client has no option for the http client it sends with
+	// and uses the one of http.DefaultClient when created, so hand it a
+	// dedicated one there and restore the default right away.
+	defaultClient := http.DefaultClient
+	defer func() { http.DefaultClient = defaultClient }()
+	http.DefaultClient = &http.Client{
+		Transport: &tokenTransport{token: token, base: http.DefaultTransport},
+	}
+	return cmdhttp.NewClient(address, cmdhttp.ClientWithAPIPrefix(APIPrefix))
+}
+
 func makeExecutor(req *cmds.Request, env interface{}) (cmds.Executor, error) {
 	isDaemonRequired := requiresDaemon(req)
 	var api string
@@ -253,12 +304,36 @@ func makeExecutor(req *cmds.Request, env interface{}) (cmds.Executor, error) {
 		return nil, ErrMissingDaemon
 	}
 
+	token := os.Getenv("FIL_API_TOKEN")
+	if flagToken, ok := req.Options[OptionAPIToken].(string); ok && flagToken != "" {
+		token = flagToken
+	}
+
 	return &executor{
-		api:  api,
-		exec: cmds.NewExecutor(rootCmd),
+		api:   api,
+		token: token,
+		exec:  cmds.NewExecutor(rootCmd),
 	}, nil
 }
 
+// tokenTransport authenticates the requests it sends with an api token.
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the requests they are given.
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		r2.Header[k] = v
+	}
+	r2.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(r2)
+}
+
 func getAPIAddress(req *cmds.Request) (string, error) {
 	var rawAddr string
 	var err error
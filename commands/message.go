@@ -1,10 +1,12 @@
 package commands
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
@@ -12,12 +14,16 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/plumbing/bcf"
 	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 )
 
 var msgCmd = &cmds.Command{
@@ -25,9 +31,14 @@ var msgCmd = &cmds.Command{
 		Tagline: "Send and monitor messages",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"send":   msgSendCmd,
-		"status": msgStatusCmd,
-		"wait":   msgWaitCmd,
+		"broadcast": msgBroadcastCmd,
+		"create":    msgCreateCmd,
+		"replay":    msgReplayCmd,
+		"send":      msgSendCmd,
+		"sign":      msgSignCmd,
+		"status":    msgStatusCmd,
+		"trace":     msgTraceCmd,
+		"wait":      msgWaitCmd,
 	},
 }
 
@@ -55,7 +66,7 @@ var msgSendCmd = &cmds.Command{
 		// TODO: (per dignifiedquire) add an option to set the nonce and method explicitly
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		target, err := address.NewFromString(req.Arguments[0])
+		target, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -73,7 +84,7 @@ var msgSendCmd = &cmds.Command{
 		var fromAddr address.Address
 		if o != nil {
 			var err error
-			fromAddr, err = address.NewFromString(o.(string))
+			fromAddr, err = resolveAddr(env, o.(string))
 			if err != nil {
 				return errors.Wrap(err, "invalid from address")
 			}
@@ -138,6 +149,173 @@ var msgSendCmd = &cmds.Command{
 	},
 }
 
+// RawMessageResult is the return type of the message create and sign
+// commands: a message and its hex encoded serialization, which the message
+// sign and broadcast commands take.
+type RawMessageResult struct {
+	Message interface{}
+	Hex     string
+}
+
+var msgCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an unsigned message to be signed offline",
+		ShortDescription: `
+Creates a message without signing it and prints it hex encoded, so that it can
+be signed with 'message sign' by a node holding the key of the sender, e.g. an
+offline node on an air-gapped machine, then sent with 'message broadcast'. The
+sender defaults to the default address, and the nonce to the next one of the
+sender on this node.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send message from"),
+		cmdkit.StringOption("value", "Value to send with message in FIL"),
+		cmdkit.StringOption("method", "The method to invoke on the target actor"),
+		cmdkit.Uint64Option("nonce", "Nonce of the message"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		from, err := optionalAddr(env, req.Options["from"])
+		if err != nil {
+			return err
+		}
+		if from.Empty() {
+			if from, err = GetPorcelainAPI(env).WalletDefaultAddress(); err != nil {
+				return err
+			}
+		}
+
+		rawVal := req.Options["value"]
+		if rawVal == nil {
+			rawVal = "0"
+		}
+		val, ok := types.NewAttoFILFromFILString(rawVal.(string))
+		if !ok {
+			return errors.New("mal-formed value")
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		method, _ := req.Options["method"].(string)
+
+		nonce, ok := req.Options["nonce"].(uint64)
+		if !ok {
+			nonce, err = GetPorcelainAPI(env).MessagePoolNextNonce(req.Context, from)
+			if err != nil {
+				return errors.Wrap(err, "failed to get nonce, specify it with --nonce")
+			}
+		}
+
+		mmsg := types.NewMeteredMessage(*types.NewMessage(from, target, nonce, val, method, nil), gasPrice, gasLimit)
+		b, err := mmsg.Marshal()
+		if err != nil {
+			return err
+		}
+		return re.Emit(&RawMessageResult{
+			Message: mmsg,
+			Hex:     hex.EncodeToString(b),
+		})
+	},
+	Type: &RawMessageResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *RawMessageResult) error {
+			_, err := fmt.Fprintln(w, res.Hex)
+			return err
+		}),
+	},
+}
+
+var msgSignCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign a message created with 'message create'",
+		ShortDescription: `
+Signs a hex encoded unsigned message with the key of its sender in the wallet
+and prints the signed message hex encoded, for 'message broadcast'. It doesn't
+need the node to be online.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("message", true, false, "Hex encoded unsigned message"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		b, err := hex.DecodeString(strings.TrimSpace(req.Arguments[0]))
+		if err != nil {
+			return errors.Wrap(err, "invalid hex encoding")
+		}
+		var mmsg types.MeteredMessage
+		if err := mmsg.Unmarshal(b); err != nil {
+			return errors.Wrap(err, "invalid message")
+		}
+
+		smsg, err := GetPorcelainAPI(env).MessageSign(&mmsg)
+		if err != nil {
+			return err
+		}
+		b, err = smsg.Marshal()
+		if err != nil {
+			return err
+		}
+		return re.Emit(&RawMessageResult{
+			Message: smsg,
+			Hex:     hex.EncodeToString(b),
+		})
+	},
+	Type: &RawMessageResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *RawMessageResult) error {
+			_, err := fmt.Fprintln(w, res.Hex)
+			return err
+		}),
+	},
+}
+
+var msgBroadcastCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Send a message signed with 'message sign'",
+		ShortDescription: `
+Validates a hex encoded signed message, adds it to the message pool and
+publishes it to the network. Its sender needn't be in the wallet of the node.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("message", true, false, "Hex encoded signed message"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		b, err := hex.DecodeString(strings.TrimSpace(req.Arguments[0]))
+		if err != nil {
+			return errors.Wrap(err, "invalid hex encoding")
+		}
+		var smsg types.SignedMessage
+		if err := smsg.Unmarshal(b); err != nil {
+			return errors.Wrap(err, "invalid signed message")
+		}
+
+		c, err := GetPorcelainAPI(env).MessageSendSigned(req.Context, &smsg)
+		if err != nil {
+			return err
+		}
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
 // WaitResult is the result of a message wait call.
 type WaitResult struct {
 	Message   *types.SignedMessage
@@ -303,6 +481,121 @@ var msgStatusCmd = &cmds.Command{
 	},
 }
 
+var msgReplayCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Re-execute a message that is on chain",
+		ShortDescription: `
+Re-executes a message against the state it was originally applied to and
+shows its gas usage, the sends it made to other actors, and the actor state
+changes it caused. The chain's state is not modified.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of the message to replay"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Parse(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid cid "+req.Arguments[0])
+		}
+
+		replay, err := GetPorcelainAPI(env).MessageReplay(req.Context, msgCid)
+		if err != nil {
+			return err
+		}
+		return re.Emit(replay)
+	},
+	Type: consensus.MessageReplay{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *consensus.MessageReplay) error {
+			sw := NewSilentWriter(w)
+			if res.ApplyError != "" {
+				sw.Printf("Apply error: %s\n", res.ApplyError)
+			}
+			if res.ExecutionError != "" {
+				sw.Printf("Execution error: %s\n", res.ExecutionError)
+			}
+			if res.Receipt != nil {
+				sw.Printf("Exit code: %d%s\n", res.Receipt.ExitCode, formatExitCodeName(res.Receipt.ExitCode))
+			}
+			sw.Printf("Gas: used %d of limit %d at price %s, cost %s\n", res.GasUsed, res.GasLimit, res.GasPrice, res.GasCost)
+			if res.Trace != nil {
+				sw.Println("Sends:")
+				printSendTrace(sw, res.Trace, 1)
+			}
+			if len(res.StateChanges) > 0 {
+				sw.Println("State changes:")
+				for _, change := range res.StateChanges {
+					sw.Printf("\t%s\n", change.Address)
+					sw.Printf("\t\tbefore: %s\n", formatReplayActor(change.Before))
+					sw.Printf("\t\tafter:  %s\n", formatReplayActor(change.After))
+				}
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var msgTraceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the internal sends made by a message that is on chain",
+		ShortDescription: `
+Re-executes a message against the state it was originally applied to and
+shows the tree of sends it made to other actors, with the method, value, exit
+code and gas used of each. If the message failed, the send that caused the
+failure is shown last. The chain's state is not modified.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of the message to trace"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Parse(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid cid "+req.Arguments[0])
+		}
+
+		trace, err := GetPorcelainAPI(env).MessageTrace(req.Context, msgCid)
+		if err != nil {
+			return err
+		}
+		return re.Emit(trace)
+	},
+	Type: vm.SendTrace{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, trace *vm.SendTrace) error {
+			sw := NewSilentWriter(w)
+			printSendTrace(sw, trace, 0)
+			if cause := trace.FirstFailure(); cause != nil {
+				sw.Printf("Failed at %s -> %s %s: exit %d %s\n", cause.From, cause.To, cause.Method, cause.ExitCode, cause.Error)
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+func printSendTrace(sw *SilentWriter, trace *vm.SendTrace, depth int) {
+	method := trace.Method
+	if method == "" {
+		method = "<transfer>"
+	}
+	sw.Printf("%s%s -> %s %s value %s gas %d exit %d", strings.Repeat("\t", depth), trace.From, trace.To, method, trace.Value, trace.GasUsed, trace.ExitCode)
+	if trace.Error != "" {
+		sw.Printf(" error: %s", trace.Error)
+	}
+	sw.Println()
+	for _, sub := range trace.Subcalls {
+		printSendTrace(sw, sub, depth+1)
+	}
+}
+
+func formatReplayActor(act *actor.Actor) string {
+	if act == nil {
+		return "<none>"
+	}
+	return fmt.Sprintf("nonce %d balance %s head %s", act.Nonce, act.Balance, act.Head)
+}
+
 func appendJSON(val interface{}, out []byte) ([]byte, error) {
 	m, err := json.MarshalIndent(val, "", "\t")
 	if err != nil {
@@ -312,3 +605,13 @@ func appendJSON(val interface{}, out []byte) ([]byte, error) {
 	out = append(out, byte('\n'))
 	return out, nil
 }
+
+// formatExitCodeName returns the name of a canonical exit code in parentheses,
+// or nothing for codes specific to an actor.
+func formatExitCodeName(code uint8) string {
+	name := exitcode.Name(code)
+	if name == "" {
+		return ""
+	}
+	return " (" + name + ")"
+}
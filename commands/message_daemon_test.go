@@ -186,3 +186,35 @@ func TestMessageStatus(t *testing.T) {
 		assert.NotContains(t, status, "On chain")
 	})
 }
+
+func TestMessageSignOffline(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	online := makeTestDaemonWithMinerAndStart(t)
+	defer online.ShutdownSuccess()
+	// The cold node holds the key of the sender and isn't connected to the
+	// online one.
+	cold := th.NewDaemon(t, th.KeyFile(fixtures.KeyFilePaths()[1])).Start()
+	defer cold.ShutdownSuccess()
+
+	unsigned := online.RunSuccess(
+		"message", "create",
+		"--from", fixtures.TestAddresses[1],
+		"--nonce", "0",
+		"--gas-price", "1", "--gas-limit", "300",
+		"--value=1234",
+		fixtures.TestAddresses[2],
+	).ReadStdoutTrimNewlines()
+
+	t.Run("[failure] the online node can't sign", func(t *testing.T) {
+		online.RunFail("could not find address", "message", "sign", unsigned)
+	})
+
+	signed := cold.RunSuccess("message", "sign", unsigned).ReadStdoutTrimNewlines()
+	msgcid := online.RunSuccess("message", "broadcast", signed).ReadStdoutTrimNewlines()
+
+	online.RunSuccess("mining", "once")
+	status := online.RunSuccess("message", "status", msgcid).ReadStdout()
+	assert.Contains(t, status, "On chain")
+	assert.Contains(t, status, "1234")
+}
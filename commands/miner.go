@@ -14,6 +14,8 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/protocol/retrieval"
+	"github.com/filecoin-project/go-filecoin/protocol/storage"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -26,7 +28,10 @@ var minerCmd = &cmds.Command{
 		"owner":         minerOwnerCmd,
 		"pledge":        minerPledgeCmd,
 		"power":         minerPowerCmd,
+		"proving":       minerProvingCmd,
 		"set-price":     minerSetPriceCmd,
+		"stats":         minerStatsCmd,
+		"unseal-jobs":   minerUnsealJobsCmd,
 		"update-peerid": minerUpdatePeerIDCmd,
 	},
 }
@@ -42,7 +47,7 @@ var minerPledgeCmd = &cmds.Command{
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		var err error
 
-		minerAddr, err := optionalAddr(req.Arguments[0])
+		minerAddr, err := optionalAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -91,7 +96,7 @@ Collateral must be greater than 0.001 FIL per pledged sector.`,
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		var err error
 
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -203,14 +208,14 @@ This command waits for the ask to be mined.`,
 			return ErrInvalidPrice
 		}
 
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
 
 		var minerAddr address.Address
 		if req.Options["miner"] != nil {
-			minerAddr, err = address.NewFromString(req.Options["miner"].(string))
+			minerAddr, err = resolveAddr(env, req.Options["miner"].(string))
 			if err != nil {
 				return errors.Wrap(err, "miner must be an address")
 			}
@@ -306,12 +311,12 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := address.NewFromString(req.Arguments[0])
+		minerAddr, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
 
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -384,7 +389,7 @@ var minerOwnerCmd = &cmds.Command{
 		ShortDescription: `Given <miner> miner address, output the address of the actor that owns the miner.`,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := optionalAddr(req.Arguments[0])
+		minerAddr, err := optionalAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -420,42 +425,31 @@ var minerPowerCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Get the power of a miner versus the total storage market power",
 		ShortDescription: `Check the current power of a given miner and total power of the storage market.
-Values will be output as a ratio where the first number is the miner power and second is the total market power.`,
+Values will be output as a ratio where the first number is the miner power and second is the total market power.
+The power at an earlier tipset of the chain can be checked with --tipset or --height.`,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := optionalAddr(req.Arguments[0])
+		minerAddr, err := optionalAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
-
-		bytes, err := GetPorcelainAPI(env).MessageQuery(
-			req.Context,
-			address.Undef,
-			minerAddr,
-			"getPower",
-		)
+		tsKey, err := tipSetKeyOption(req, env)
 		if err != nil {
 			return err
 		}
-		power := big.NewInt(0).SetBytes(bytes[0])
 
-		bytes, err = GetPorcelainAPI(env).MessageQuery(
-			req.Context,
-			address.Undef,
-			address.StorageMarketAddress,
-			"getTotalStorage",
-		)
+		power, err := GetPorcelainAPI(env).MinerGetPower(req.Context, tsKey, minerAddr)
 		if err != nil {
 			return err
 		}
-		total := big.NewInt(0).SetBytes(bytes[0])
 
-		str := fmt.Sprintf("%d / %d", power, total) // nolint: govet
+		str := fmt.Sprintf("%s / %s", power.Power, power.Total)
 		return re.Emit(str)
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("miner", true, false, "The address of the miner"),
 	},
+	Options: tipSetOptions,
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, a string) error {
 			_, err := fmt.Fprintln(w, a)
@@ -463,3 +457,119 @@ Values will be output as a ratio where the first number is the miner power and s
 		}),
 	},
 }
+
+var minerStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show a summary of the node's storage miner",
+		ShortDescription: `Shows the storage miner's current asks, deals by state, pledged and committed
+capacity, recent PoSt submissions and proving deadlines. Use --enc=json for
+a document suitable for a dashboard.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		stats, err := GetStorageAPI(env).MinerStats(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(stats)
+	},
+	Type: storage.MinerStats{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, stats *storage.MinerStats) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("Miner:\t%s\n", stats.Miner)
+			sw.Printf("Height:\t%s\n", stats.Height)
+			sw.Printf("Asks:\t%d\n", len(stats.Asks))
+			for _, ask := range stats.Asks {
+				sw.Printf("\t%s: %s FIL/byte/block until %s\n", ask.ID, ask.Price, ask.Expiry)
+			}
+			sw.Println("Deals:")
+			for state, count := range stats.DealsByState {
+				sw.Printf("\t%s:\t%d\n", state, count)
+			}
+			sw.Printf("Sectors:\t%d pledged, %d committed (sector size %s bytes)\n", stats.PledgedSectors, stats.CommittedSectors, stats.SectorSize)
+			if stats.ProvingPeriodEnd != nil {
+				sw.Printf("Proving period:\t%s to %s, grace period until %s\n", stats.ProvingPeriodStart, stats.ProvingPeriodEnd, stats.GracePeriodEnd)
+			}
+			sw.Println("Recent PoSts:")
+			for _, post := range stats.RecentPoSts {
+				if post.Error != "" {
+					sw.Printf("\t%s-%s:\tfailed: %s\n", post.ProvingPeriodStart, post.ProvingPeriodEnd, post.Error)
+				} else {
+					sw.Printf("\t%s-%s:\t%s at height %s, %d faults\n", post.ProvingPeriodStart, post.ProvingPeriodEnd, post.Message, post.Height, post.Faults)
+				}
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var minerProvingCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the status of the node's storage miner's proving period",
+		ShortDescription: `Shows the current proving period of the node's storage miner, its PoSt
+submission deadline and grace period, the sectors the PoSt must prove and the
+last PoSt submitted along with the status of its message on chain.  The state
+is one of idle, waiting, due, generating, submitted, failed, late or missed.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		status, err := GetStorageAPI(env).MinerProvingStatus(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: storage.ProvingStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *storage.ProvingStatus) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("Miner:\t%s\n", status.Miner)
+			sw.Printf("Height:\t%s\n", status.Height)
+			sw.Printf("State:\t%s\n", status.State)
+			if status.ProvingPeriodEnd != nil {
+				sw.Printf("Proving period:\t%s to %s, grace period until %s\n", status.ProvingPeriodStart, status.ProvingPeriodEnd, status.GracePeriodEnd)
+			}
+			sw.Printf("Sectors:\t%v\n", status.Sectors)
+			if post := status.LastPoSt; post != nil {
+				if post.Error != "" {
+					sw.Printf("Last PoSt:\t%s-%s failed: %s\n", post.ProvingPeriodStart, post.ProvingPeriodEnd, post.Error)
+				} else {
+					sw.Printf("Last PoSt:\t%s-%s %s %s", post.ProvingPeriodStart, post.ProvingPeriodEnd, post.Message, status.LastPoStStatus)
+					if status.LastPoStStatus == storage.PoStRejected {
+						sw.Printf(" (exit code %d)", status.LastPoStExitCode)
+					}
+					sw.Println()
+				}
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var minerUnsealJobsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the status of the miner's sector unsealing jobs",
+		ShortDescription: `Shows the queued and running jobs unsealing pieces for retrieval, along with
+the most recently finished ones.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		jobs, err := GetRetrievalAPI(env).UnsealJobs()
+		if err != nil {
+			return err
+		}
+		return re.Emit(jobs)
+	},
+	Type: []retrieval.UnsealJob{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, jobs *[]retrieval.UnsealJob) error {
+			sw := NewSilentWriter(w)
+			for _, job := range *jobs {
+				sw.Printf("%d\t%s\t%s\t%s\t%d requests", job.ID, job.PieceRef, job.State, job.Priority, job.Requests)
+				if job.Error != "" {
+					sw.Printf("\t%s", job.Error)
+				}
+				sw.Println()
+			}
+			return sw.Error()
+		}),
+	},
+}
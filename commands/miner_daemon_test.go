@@ -22,6 +22,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/fixtures"
 	"github.com/filecoin-project/go-filecoin/gengen/util"
+	"github.com/filecoin-project/go-filecoin/protocol/retrieval"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -446,6 +447,18 @@ func TestMinerPower(t *testing.T) {
 	assert.Equal(t, "3 / 6", power)
 }
 
+func TestMinerUnsealJobs(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	out := d.RunSuccess("miner", "unseal-jobs", "--enc=json")
+	var jobs []retrieval.UnsealJob
+	require.NoError(t, json.Unmarshal([]byte(out.ReadStdout()), &jobs))
+	assert.Empty(t, jobs)
+}
+
 var testConfig = &gengen.GenesisCfg{
 	Keys: 4,
 	PreAlloc: []string{
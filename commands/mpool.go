@@ -11,6 +11,8 @@ import (
 	"github.com/ipfs/go-ipfs-cmds"
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -19,9 +21,12 @@ var mpoolCmd = &cmds.Command{
 		Tagline: "Manage the message pool",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"ls":   mpoolLsCmd,
-		"show": mpoolShowCmd,
-		"rm":   mpoolRemoveCmd,
+		"ls":      mpoolLsCmd,
+		"show":    mpoolShowCmd,
+		"stats":   mpoolStatsCmd,
+		"replace": mpoolReplaceCmd,
+		"rm":      mpoolRemoveCmd,
+		"watch":   mpoolWatchCmd,
 	},
 }
 
@@ -31,8 +36,19 @@ var mpoolLsCmd = &cmds.Command{
 	},
 	Options: []cmdkit.Option{
 		cmdkit.UintOption("wait-for-count", "Block until this number of messages are in the pool").WithDefault(0),
+		cmdkit.BoolOption("queued", "List the messages waiting for a gap in their sender's nonces to fill instead"),
+		fromFilterOption,
+		toFilterOption,
+		methodFilterOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		filter, err := parseMessagePoolFilter(req)
+		if err != nil {
+			return err
+		}
+		if queued, _ := req.Options["queued"].(bool); queued {
+			return re.Emit(filterMessages(GetPorcelainAPI(env).MessagePoolQueued(), filter))
+		}
 		messageCount, _ := req.Options["wait-for-count"].(uint)
 
 		pending, err := GetPorcelainAPI(env).MessagePoolWait(req.Context, messageCount)
@@ -40,7 +56,7 @@ var mpoolLsCmd = &cmds.Command{
 			return err
 		}
 
-		return re.Emit(pending)
+		return re.Emit(filterMessages(pending, filter))
 	},
 	Type: []*types.SignedMessage{},
 	Encoders: cmds.EncoderMap{
@@ -57,6 +73,124 @@ var mpoolLsCmd = &cmds.Command{
 	},
 }
 
+var mpoolWatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show messages as they are added to and removed from the pool",
+		ShortDescription: `
+Shows a line for every message added to the message pool, replaced by a
+message paying a higher gas price, mined, or removed otherwise, e.g. because it
+expired, until the command is interrupted.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		for ev := range GetPorcelainAPI(env).MessagePoolSubscribe(req.Context) {
+			if err := re.Emit(ev); err != nil {
+				return err
+			}
+		}
+		if req.Context.Err() == nil {
+			return errors.New("fell behind the message pool, watch again")
+		}
+		return nil
+	},
+	Type: core.MessagePoolEvent{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ev *core.MessagePoolEvent) error {
+			sw := NewSilentWriter(w)
+			switch ev.Type {
+			case core.MessagePoolReplace:
+				sw.Printf("%s %s by %s\n", ev.Type, ev.Cid.String(), ev.Reason)
+			default:
+				sw.Printf("%s %s", ev.Type, ev.Cid.String())
+				if ev.Reason != "" {
+					sw.Printf(" (%s)", ev.Reason)
+				}
+				sw.Println()
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var mpoolStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show statistics about the messages in the pool",
+		ShortDescription: `
+Shows the number and size of the messages in the message pool, how many wait
+for a gap in their sender's nonces to fill, the distribution of their gas
+prices and their ages in blocks since the pool received them.  The messages
+described can be restricted to those from or to an address, or calling a
+method.
+`,
+	},
+	Options: []cmdkit.Option{
+		fromFilterOption,
+		toFilterOption,
+		methodFilterOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		filter, err := parseMessagePoolFilter(req)
+		if err != nil {
+			return err
+		}
+		stats, err := GetPorcelainAPI(env).MessagePoolStats(filter)
+		if err != nil {
+			return err
+		}
+		return re.Emit(stats)
+	},
+	Type: core.MessagePoolStats{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, stats *core.MessagePoolStats) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("Messages:  %d (%d queued) from %d senders\n", stats.Count, stats.Queued, stats.Senders)
+			sw.Printf("Size:      %d bytes\n", stats.Bytes)
+			if stats.GasPrice != nil {
+				sw.Printf("Gas price: min %s, median %s, max %s FIL/GU\n", stats.GasPrice.Min, stats.GasPrice.Median, stats.GasPrice.Max)
+				sw.Printf("Age:       median %d, 90th percentile %d, max %d blocks\n", stats.AgeMedian, stats.AgeP90, stats.AgeMax)
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var (
+	fromFilterOption   = cmdkit.StringOption("from", "Only messages from this address")
+	toFilterOption     = cmdkit.StringOption("to", "Only messages to this address")
+	methodFilterOption = cmdkit.StringOption("method", "Only messages calling this method")
+)
+
+// parseMessagePoolFilter reads the filter options of a message pool command.
+func parseMessagePoolFilter(req *cmds.Request) (core.MessagePoolFilter, error) {
+	var filter core.MessagePoolFilter
+	if from, ok := req.Options["from"].(string); ok && from != "" {
+		addr, err := address.NewFromString(from)
+		if err != nil {
+			return filter, errors.Wrap(err, "invalid from address")
+		}
+		filter.From = addr
+	}
+	if to, ok := req.Options["to"].(string); ok && to != "" {
+		addr, err := address.NewFromString(to)
+		if err != nil {
+			return filter, errors.Wrap(err, "invalid to address")
+		}
+		filter.To = addr
+	}
+	filter.Method, _ = req.Options["method"].(string)
+	return filter, nil
+}
+
+func filterMessages(msgs []*types.SignedMessage, filter core.MessagePoolFilter) []*types.SignedMessage {
+	out := []*types.SignedMessage{}
+	for _, msg := range msgs {
+		if filter.Matches(msg) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
 var mpoolShowCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Show content of an outstanding message",
@@ -105,6 +239,52 @@ Signature: %s
 	},
 }
 
+var mpoolReplaceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Send a pending message again with a higher gas price",
+		ShortDescription: `
+Replaces a pending message sent from this node's wallet, e.g. one stuck with
+too low a gas price, by the same message paying a higher gas price. The gas
+price defaults to the least price the message pool accepts for a replacement.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The CID of the message to replace"),
+	},
+	Options: []cmdkit.Option{
+		priceOption,
+		cmdkit.Uint64Option("gas-limit", "Maximum number of GasUnits the message is allowed to consume, the original's by default"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Parse(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid message cid")
+		}
+
+		gasPrice := types.ZeroAttoFIL
+		if rawPrice, ok := req.Options["gas-price"].(string); ok {
+			var valid bool
+			gasPrice, valid = types.NewAttoFILFromFILString(rawPrice)
+			if !valid {
+				return errors.New("invalid gas price (specify FIL as a decimal number)")
+			}
+		}
+		gasLimit, _ := req.Options["gas-limit"].(uint64)
+
+		c, err := GetPorcelainAPI(env).MessageReplace(req.Context, msgCid, *gasPrice, types.NewGasUnits(gasLimit))
+		if err != nil {
+			return err
+		}
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
 var mpoolRemoveCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Delete a message from the message pool",
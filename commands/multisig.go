@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-cmdkit"
+	"github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var multisigCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Multisig wallet operations",
+		ShortDescription: `A multisig wallet spends its funds in transactions proposed by one of its
+signers once enough of them approve.`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"approve": multisigApproveCmd,
+		"cancel":  multisigCancelCmd,
+		"create":  multisigCreateCmd,
+		"propose": multisigProposeCmd,
+		"show":    multisigShowCmd,
+	},
+}
+
+var multisigCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a multisig wallet requiring <required> approvals of <signers>",
+		ShortDescription: `Issues a new message to the network to create the wallet, then waits for the
+message to be mined as this is required to return the address of the new wallet.
+The wallet is funded with --value FIL, which are locked and unlock linearly over
+--unlock-duration blocks if it is set.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("required", true, false, "Number of signers that must approve transactions"),
+		cmdkit.StringArg("signers", true, true, "Addresses of the signers of the wallet"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send from"),
+		cmdkit.StringOption("value", "Amount in FIL to fund the wallet with").WithDefault("0"),
+		cmdkit.Uint64Option("unlock-duration", "Number of blocks over which the funds of the wallet unlock").WithDefault(uint64(0)),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fromAddr, err := optionalAddr(env, req.Options["from"])
+		if err != nil {
+			return err
+		}
+
+		required, err := strconv.ParseUint(req.Arguments[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number of required approvals: %s", req.Arguments[0])
+		}
+
+		var signers []address.Address
+		for _, s := range req.Arguments[1:] {
+			signer, err := resolveAddr(env, s)
+			if err != nil {
+				return err
+			}
+			signers = append(signers, signer)
+		}
+
+		value, ok := types.NewAttoFILFromFILString(req.Options["value"].(string))
+		if !ok {
+			return ErrInvalidAmount
+		}
+
+		unlockDuration := types.NewBlockHeight(req.Options["unlock-duration"].(uint64))
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		addr, err := GetPorcelainAPI(env).MultisigCreate(
+			req.Context,
+			fromAddr,
+			gasPrice,
+			gasLimit,
+			signers,
+			required,
+			value,
+			unlockDuration,
+		)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(&addr)
+	},
+	Type: address.Address{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, addr *address.Address) error {
+			return PrintString(w, addr)
+		}),
+	},
+}
+
+var multisigProposeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Propose a transaction of <wallet> sending <value> FIL to <target>",
+		ShortDescription: `Issues a message proposing the transaction, approved by the sender, which must
+be a signer of the wallet. The transaction is sent by the wallet once enough
+signers approve it.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("target", true, false, "Address the transaction is sent to"),
+		cmdkit.StringArg("value", true, false, "Amount in FIL sent by the transaction"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address of the signer proposing the transaction"),
+		cmdkit.StringOption("method", "Method of the target called by the transaction"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fromAddr, err := optionalAddr(env, req.Options["from"])
+		if err != nil {
+			return err
+		}
+
+		wallet, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		target, err := resolveAddr(env, req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		value, ok := types.NewAttoFILFromFILString(req.Arguments[2])
+		if !ok {
+			return ErrInvalidAmount
+		}
+
+		method, _ := req.Options["method"].(string)
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		c, err := GetPorcelainAPI(env).MessageSendWithDefaultAddress(
+			req.Context,
+			fromAddr,
+			wallet,
+			types.NewAttoFILFromFIL(0),
+			gasPrice,
+			gasLimit,
+			"propose",
+			target,
+			value,
+			method,
+			[]interface{}{},
+		)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
+var multisigApproveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Approve the transaction <id> of <wallet>",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("id", true, false, "Id of the transaction"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address of the approving signer"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return sendMultisigTxMessage(req, re, env, "approve")
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
+var multisigCancelCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Cancel the transaction <id> of <wallet>",
+		ShortDescription: `Only the signer that proposed a transaction may cancel it.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("id", true, false, "Id of the transaction"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address of the signer that proposed the transaction"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return sendMultisigTxMessage(req, re, env, "cancel")
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
+// sendMultisigTxMessage sends a message calling method of the wallet with the
+// id of a transaction, as given in the arguments of req.
+func sendMultisigTxMessage(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment, method string) error {
+	fromAddr, err := optionalAddr(env, req.Options["from"])
+	if err != nil {
+		return err
+	}
+
+	wallet, err := resolveAddr(env, req.Arguments[0])
+	if err != nil {
+		return err
+	}
+
+	id, ok := big.NewInt(0).SetString(req.Arguments[1], 10)
+	if !ok || id.Sign() < 0 {
+		return fmt.Errorf("invalid transaction id: %s", req.Arguments[1])
+	}
+
+	gasPrice, gasLimit, _, err := parseGasOptions(req)
+	if err != nil {
+		return err
+	}
+
+	c, err := GetPorcelainAPI(env).MessageSendWithDefaultAddress(
+		req.Context,
+		fromAddr,
+		wallet,
+		types.NewAttoFILFromFIL(0),
+		gasPrice,
+		gasLimit,
+		method,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return re.Emit(c)
+}
+
+var multisigShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the signers, funds and pending transactions of <wallet>",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		wallet, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		info, err := GetPorcelainAPI(env).MultisigShow(req.Context, wallet)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(info)
+	},
+	Type: porcelain.MultisigInfo{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, info *porcelain.MultisigInfo) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("Required: %d of %d\n", info.Required, len(info.Signers))
+			for _, s := range info.Signers {
+				sw.Printf("Signer:   %s\n", s)
+			}
+			sw.Printf("Balance:  %s\n", info.Balance)
+			sw.Printf("Locked:   %s\n", info.Locked)
+
+			ids := make([]string, 0, len(info.Transactions))
+			for id := range info.Transactions {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return info.Transactions[ids[i]].ID < info.Transactions[ids[j]].ID })
+			for _, id := range ids {
+				tx := info.Transactions[id]
+				sw.Printf("Transaction %s: to: %s, value: %s, method: %q, approvals: %d\n", id, tx.To, tx.Value, tx.Method, len(tx.Approved))
+			}
+			return sw.Error()
+		}),
+	},
+}
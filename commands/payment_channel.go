@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
@@ -11,6 +12,7 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
+	cbor "github.com/ipfs/go-ipld-cbor"
 )
 
 var paymentChannelCmd = &cmds.Command{
@@ -54,12 +56,12 @@ message to be mined to get the channelID.`,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
 
-		target, err := address.NewFromString(req.Arguments[0])
+		target, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -141,13 +143,13 @@ var lsCmd = &cmds.Command{
 		cmdkit.StringOption("payer", "Address for which to retrieve channels (defaults to from if omitted)"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
 
 		payerOption := req.Options["payer"]
-		payerAddr, err := optionalAddr(payerOption)
+		payerAddr, err := optionalAddr(env, payerOption)
 		if err != nil {
 			return err
 		}
@@ -180,8 +182,10 @@ var lsCmd = &cmds.Command{
 
 var voucherCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline:          "Create a new voucher from a payment channel",
-		ShortDescription: `Generate a new signed payment voucher for the target of a payment channel.`,
+		Tagline: "Create a new voucher from a payment channel",
+		ShortDescription: `Generate a new signed payment voucher for the target of a payment channel.
+With --nonce the voucher pays in the --lane of the channel, and only transfers
+the part of its amount not yet redeemed in the lane and in the lanes it merges.`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("channel", true, false, "Channel id of channel from which to create voucher"),
@@ -190,9 +194,12 @@ var voucherCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("from", "Address for which to retrieve channels"),
 		cmdkit.StringOption("validat", "Smallest block height at which target can redeem"),
+		cmdkit.Uint64Option("lane", "Lane of the channel the voucher pays in").WithDefault(uint64(0)),
+		cmdkit.Uint64Option("nonce", "Nonce of the voucher in its lane, higher than those of the lane's previous vouchers").WithDefault(uint64(0)),
+		cmdkit.StringOption("merge", "Comma separated lane:nonce pairs of the lanes merged by the voucher"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -212,9 +219,22 @@ var voucherCmd = &cmds.Command{
 			return err
 		}
 
-		voucher, err := GetPorcelainAPI(env).PaymentChannelVoucher(req.Context, fromAddr, channel, amount, validAt, nil)
-		if err != nil {
-			return err
+		var voucher *types.PaymentVoucher
+		if nonce := req.Options["nonce"].(uint64); nonce > 0 {
+			merges, err := parseLaneMerges(req.Options["merge"])
+			if err != nil {
+				return err
+			}
+			lane := req.Options["lane"].(uint64)
+			voucher, err = GetPorcelainAPI(env).PaymentChannelLaneVoucher(req.Context, fromAddr, channel, amount, validAt, nil, lane, nonce, merges)
+			if err != nil {
+				return err
+			}
+		} else {
+			voucher, err = GetPorcelainAPI(env).PaymentChannelVoucher(req.Context, fromAddr, channel, amount, validAt, nil)
+			if err != nil {
+				return err
+			}
 		}
 
 		v, err := voucher.Encode()
@@ -253,7 +273,7 @@ var redeemCmd = &cmds.Command{
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -270,14 +290,9 @@ var redeemCmd = &cmds.Command{
 
 		result := &ReclaimResult{Preview: preview}
 
-		params := []interface{}{
-			voucher.Payer,
-			&voucher.Channel,
-			&voucher.Amount,
-			&voucher.ValidAt,
-			voucher.Condition,
-			[]byte(voucher.Signature),
-			[]interface{}{},
+		method, params, err := voucherRedeemParams(voucher, "redeem")
+		if err != nil {
+			return err
 		}
 
 		if preview {
@@ -285,7 +300,7 @@ var redeemCmd = &cmds.Command{
 				req.Context,
 				fromAddr,
 				address.PaymentBrokerAddress,
-				"redeem",
+				method,
 				params...,
 			)
 		} else {
@@ -296,7 +311,7 @@ var redeemCmd = &cmds.Command{
 				types.NewAttoFILFromFIL(0),
 				gasPrice,
 				gasLimit,
-				"redeem",
+				method,
 				params...,
 			)
 		}
@@ -341,7 +356,7 @@ var reclaimCmd = &cmds.Command{
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -428,7 +443,7 @@ var closeCmd = &cmds.Command{
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -445,14 +460,9 @@ var closeCmd = &cmds.Command{
 
 		result := &CloseResult{Preview: preview}
 
-		params := []interface{}{
-			voucher.Payer,
-			&voucher.Channel,
-			&voucher.Amount,
-			&voucher.ValidAt,
-			voucher.Condition,
-			[]byte(voucher.Signature),
-			[]interface{}{},
+		method, params, err := voucherRedeemParams(voucher, "close")
+		if err != nil {
+			return err
 		}
 
 		if preview {
@@ -460,7 +470,7 @@ var closeCmd = &cmds.Command{
 				req.Context,
 				fromAddr,
 				address.PaymentBrokerAddress,
-				"close",
+				method,
 				params...,
 			)
 		} else {
@@ -471,7 +481,7 @@ var closeCmd = &cmds.Command{
 				types.NewAttoFILFromFIL(0),
 				gasPrice,
 				gasLimit,
-				"close",
+				method,
 				params...,
 			)
 		}
@@ -518,7 +528,7 @@ var extendCmd = &cmds.Command{
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -615,7 +625,7 @@ var cancelCmd = &cmds.Command{
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		fromAddr, err := optionalAddr(req.Options["from"])
+		fromAddr, err := optionalAddr(env, req.Options["from"])
 		if err != nil {
 			return err
 		}
@@ -680,3 +690,53 @@ var cancelCmd = &cmds.Command{
 		}),
 	},
 }
+
+// voucherRedeemParams returns the payment broker method redeeming or closing
+// (as given by method) a channel with voucher, and its parameters.  Lane
+// vouchers are passed whole to the lane variant of the method.
+func voucherRedeemParams(voucher *types.PaymentVoucher, method string) (string, []interface{}, error) {
+	if voucher.Nonce > 0 {
+		voucherBytes, err := cbor.DumpObject(voucher)
+		if err != nil {
+			return "", nil, err
+		}
+		return method + "Voucher", []interface{}{voucherBytes, []interface{}{}}, nil
+	}
+
+	return method, []interface{}{
+		voucher.Payer,
+		&voucher.Channel,
+		&voucher.Amount,
+		&voucher.ValidAt,
+		voucher.Condition,
+		[]byte(voucher.Signature),
+		[]interface{}{},
+	}, nil
+}
+
+// parseLaneMerges parses the comma separated lane:nonce pairs of the merge
+// option, if set.
+func parseLaneMerges(opt interface{}) ([]types.LaneMerge, error) {
+	s, _ := opt.(string)
+	if s == "" {
+		return nil, nil
+	}
+
+	var merges []types.LaneMerge
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid merge %q, expected lane:nonce", pair)
+		}
+		lane, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lane in merge %q", pair)
+		}
+		nonce, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nonce in merge %q", pair)
+		}
+		merges = append(merges, types.LaneMerge{Lane: lane, Nonce: nonce})
+	}
+	return merges, nil
+}
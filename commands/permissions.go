@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/go-filecoin/auth"
+)
+
+// commandPermissions are the permissions required by commands and groups of
+// commands, by path.  A command requires the permission of its longest
+// listed prefix, or admin if it has none, so that new commands are safe by
+// default.
+var commandPermissions = map[string]auth.Permission{
+	"actor":                     auth.PermRead,
+	"address/default":           auth.PermWrite,
+	"address/label":             auth.PermWrite,
+	"address/lookup":            auth.PermRead,
+	"address/ls":                auth.PermRead,
+	"addrbook":                  auth.PermWrite,
+	"addrbook/ls":               auth.PermRead,
+	"bitswap":                   auth.PermRead,
+	"bootstrap/ls":              auth.PermRead,
+	"chain":                     auth.PermRead,
+	"chain/force-sync":          auth.PermAdmin,
+	"client":                    auth.PermSign,
+	"client/cat":                auth.PermRead,
+	"client/import":             auth.PermWrite,
+	"client/list-asks":          auth.PermRead,
+	"client/payments":           auth.PermRead,
+	"client/query-storage-deal": auth.PermRead,
+	"dag":                       auth.PermRead,
+	"dht":                       auth.PermRead,
+	"id":                        auth.PermRead,
+	"message":                   auth.PermRead,
+	"message/broadcast":         auth.PermWrite,
+	"message/send":              auth.PermSign,
+	"message/sign":              auth.PermSign,
+	"miner":                     auth.PermSign,
+	"miner/owner":               auth.PermRead,
+	"miner/power":               auth.PermRead,
+	"miner/proving":             auth.PermRead,
+	"miner/stats":               auth.PermRead,
+	"miner/unseal-jobs":         auth.PermRead,
+	"mpool":                     auth.PermRead,
+	"mpool/replace":             auth.PermSign,
+	"mpool/rm":                  auth.PermWrite,
+	"multisig":                  auth.PermSign,
+	"multisig/show":             auth.PermRead,
+	"outbox/clear":              auth.PermWrite,
+	"outbox/ls":                 auth.PermRead,
+	"paych":                     auth.PermSign,
+	"paych/ls":                  auth.PermRead,
+	"ping":                      auth.PermRead,
+	"protocol":                  auth.PermRead,
+	"retrieval-client":          auth.PermWrite,
+	"reward":                    auth.PermRead,
+	"show":                      auth.PermRead,
+	"stats":                     auth.PermRead,
+	"swarm":                     auth.PermRead,
+	"swarm/connect":             auth.PermWrite,
+	"swarm/unban":               auth.PermAdmin,
+	"wallet/balance":            auth.PermRead,
+	"wallet/history":            auth.PermRead,
+	"wallet/ls":                 auth.PermRead,
+}
+
+// CommandPermission returns the permission required to run the command at
+// path, e.g. []string{"wallet", "balance"}.
+func CommandPermission(path []string) auth.Permission {
+	for i := len(path); i > 0; i-- {
+		if perm, ok := commandPermissions[strings.Join(path[:i], "/")]; ok {
+			return perm
+		}
+	}
+	return auth.PermAdmin
+}
+
+// requestPermission returns the permission required by an http request to
+// the command api.
+func requestPermission(r *http.Request) auth.Permission {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefix), "/")
+	return CommandPermission(strings.Split(path, "/"))
+}
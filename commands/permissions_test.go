@@ -0,0 +1,32 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/auth"
+	"github.com/filecoin-project/go-filecoin/commands"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestCommandPermission(t *testing.T) {
+	tf.UnitTest(t)
+
+	for path, want := range map[string]auth.Permission{
+		"chain/head":          auth.PermRead,
+		"chain/force-sync":    auth.PermAdmin,
+		"message/send":        auth.PermSign,
+		"message/status":      auth.PermRead,
+		"miner/power":         auth.PermRead,
+		"miner/create":        auth.PermSign,
+		"wallet/balance":      auth.PermRead,
+		"wallet/export":       auth.PermAdmin,
+		"config":              auth.PermAdmin,
+		"auth/create-token":   auth.PermAdmin,
+		"some/future/command": auth.PermAdmin,
+	} {
+		assert.Equal(t, want, commands.CommandPermission(strings.Split(path, "/")), path)
+	}
+}
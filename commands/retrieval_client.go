@@ -4,8 +4,6 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
-
-	"github.com/filecoin-project/go-filecoin/address"
 )
 
 var retrievalClientCmd = &cmds.Command{
@@ -26,7 +24,7 @@ var clientRetrievePieceCmd = &cmds.Command{
 		cmdkit.StringArg("cid", true, false, "Content identifier of piece to read"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := address.NewFromString(req.Arguments[0])
+		minerAddr, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-ipfs-cmdkit"
+	"github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/porcelain"
+)
+
+var rewardCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the funds and parameters of block rewards",
+		ShortDescription: `Shows the funds held by the reward actor at the network address for block
+rewards, the reward paid for each block and the percentage of the gas fees of
+messages that is burnt rather than paid to the miner.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		info, err := GetPorcelainAPI(env).RewardShow(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(info)
+	},
+	Type: porcelain.RewardInfo{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, info *porcelain.RewardInfo) error {
+			_, err := fmt.Fprintf(w, "Balance:      %s\nBlock reward: %s\nGas burnt:    %d%%\n", info.Balance, info.BlockReward, info.GasBurnPercent)
+			return err
+		}),
+	},
+}
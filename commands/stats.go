@@ -11,7 +11,9 @@ var statsCmd = &cmds.Command{
 		Tagline: "View various filecoin node statistics",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"bandwidth": statsBandwidthCmd,
+		"bandwidth":             statsBandwidthCmd,
+		"bandwidth-by-peer":     statsBandwidthByPeerCmd,
+		"bandwidth-by-protocol": statsBandwidthByProtocolCmd,
 	},
 }
 
@@ -26,3 +28,23 @@ var statsBandwidthCmd = &cmds.Command{
 	},
 	Type: metrics.Stats{},
 }
+
+var statsBandwidthByPeerCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "View bandwidth usage metrics for each connected peer",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).NetworkGetBandwidthStatsByPeer())
+	},
+	Type: map[string]metrics.Stats{},
+}
+
+var statsBandwidthByProtocolCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "View bandwidth usage metrics for each protocol",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).NetworkGetBandwidthStatsByProtocol())
+	},
+	Type: map[string]metrics.Stats{},
+}
@@ -1,9 +1,12 @@
 package commands_test
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/libp2p/go-libp2p-metrics"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
@@ -19,3 +22,23 @@ func TestStatsBandwidth(t *testing.T) {
 
 	assert.Equal(t, "{\"TotalIn\":0,\"TotalOut\":0,\"RateIn\":0,\"RateOut\":0}", stats)
 }
+
+func TestStatsBandwidthByPeerAndProtocol(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d1 := th.NewDaemon(t, th.SwarmAddr("/ip4/0.0.0.0/tcp/6000")).Start()
+	defer d1.ShutdownSuccess()
+	d2 := th.NewDaemon(t, th.SwarmAddr("/ip4/0.0.0.0/tcp/6001")).Start()
+	defer d2.ShutdownSuccess()
+	d1.ConnectSuccess(d2)
+
+	var byPeer map[string]metrics.Stats
+	out := d1.RunSuccess("stats", "bandwidth-by-peer").ReadStdoutTrimNewlines()
+	require.NoError(t, json.Unmarshal([]byte(out), &byPeer))
+	assert.Contains(t, byPeer, d2.GetID())
+
+	var byProtocol map[string]metrics.Stats
+	out = d1.RunSuccess("stats", "bandwidth-by-protocol").ReadStdoutTrimNewlines()
+	require.NoError(t, json.Unmarshal([]byte(out), &byProtocol))
+	assert.Contains(t, byProtocol, "/fil/chain/ancestors/1.0.0")
+}
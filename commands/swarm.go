@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
@@ -26,6 +28,9 @@ libp2p peers on the internet.
 	Subcommands: map[string]*cmds.Command{
 		"connect": swarmConnectCmd,
 		"peers":   swarmPeersCmd,
+		"scores":  swarmScoresCmd,
+		"status":  swarmStatusCmd,
+		"unban":   swarmUnbanCmd,
 	},
 }
 
@@ -34,22 +39,32 @@ var swarmPeersCmd = &cmds.Command{
 		Tagline: "List peers with open connections.",
 		ShortDescription: `
 'go-filecoin swarm peers' lists the set of peers this node is connected to.
+
+With --sync-stats, it also lists the requests made to every peer that served
+chain syncs, connected or not, and how well they served them, which helps in
+choosing bootstrap peers.
 `,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("verbose", "v", "Display all extra information"),
 		cmdkit.BoolOption("streams", "Also list information about open streams for each peer"),
 		cmdkit.BoolOption("latency", "Also list information about latency to each peer"),
+		cmdkit.BoolOption("sync-stats", "Also list the chain sync stats of every peer synced from"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		verbose, _ := req.Options["verbose"].(bool)
 		latency, _ := req.Options["latency"].(bool)
 		streams, _ := req.Options["streams"].(bool)
 
+		syncStats, _ := req.Options["sync-stats"].(bool)
+
 		out, err := GetPorcelainAPI(env).NetworkPeers(req.Context, verbose, latency, streams)
 		if err != nil {
 			return err
 		}
+		if syncStats {
+			out.SyncStats = GetPorcelainAPI(env).NetworkPeerSyncStats()
+		}
 
 		return re.Emit(&out)
 	},
@@ -77,7 +92,16 @@ var swarmPeersCmd = &cmds.Command{
 				}
 			}
 
-			return nil
+			if len(ci.SyncStats) == 0 {
+				return nil
+			}
+			fmt.Fprintln(w) // nolint: errcheck
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PEER\tREQUESTS\tERROR RATE\tBLOCKS\tBYTES\tBLOCKS/S\tLATENCY") // nolint: errcheck
+			for _, stat := range ci.SyncStats {
+				fmt.Fprintf(tw, "%s\t%d\t%.2f\t%d\t%d\t%.1f\t%s\n", stat.Peer.Pretty(), stat.Requests, stat.ErrorRate, stat.Blocks, stat.Bytes, stat.BlocksPerSecond, stat.Latency) // nolint: errcheck
+			}
+			return tw.Flush()
 		}),
 	},
 	Type: net.SwarmConnInfos{},
@@ -122,3 +146,83 @@ go-filecoin swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUE
 		}),
 	},
 }
+
+var swarmScoresCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the scores of misbehaving peers.",
+		ShortDescription: `
+'go-filecoin swarm scores' lists the peers that recently sent invalid blocks,
+failed to provide blocks they announced or violated a protocol, lowest score
+first.  Peers whose score drops below zero are banned for a while.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).NetworkPeerScores())
+	},
+	Type: []net.PeerScore{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, scores []net.PeerScore) error {
+			sw := NewSilentWriter(w)
+			for _, ps := range scores {
+				sw.Printf("%s %d", ps.Peer.Pretty(), ps.Score)
+				if ps.BannedUntil != nil {
+					sw.Printf(" banned until %s", ps.BannedUntil.Format(time.RFC3339))
+				}
+				sw.Println()
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var swarmStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show whether peers can reach this node.",
+		ShortDescription: `
+'go-filecoin swarm status' shows whether peers running the AutoNAT service
+could dial this node directly (public), could not (private), or have not
+reported yet (unknown), along with the relay addresses through which a node
+behind a NAT can be reached.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		status, err := GetPorcelainAPI(env).NetworkGetReachability()
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: net.ReachabilityStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *net.ReachabilityStatus) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("Reachability:\t%s\n", status.Reachability)
+			if status.PublicAddr != "" {
+				sw.Printf("Public address:\t%s\n", status.PublicAddr)
+			}
+			for _, a := range status.RelayAddrs {
+				sw.Printf("Relay address:\t%s\n", a)
+			}
+			for _, a := range status.ListenAddrs {
+				sw.Printf("Address:\t%s\n", a)
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+var swarmUnbanCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Lift the ban on a peer.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer", true, false, "ID of the banned peer."),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		pid, err := peer.IDB58Decode(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).NetworkUnbanPeer(pid)
+	},
+}
@@ -3,6 +3,8 @@ package commands_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 )
@@ -29,3 +31,38 @@ func TestSwarmConnectPeersInvalid(t *testing.T) {
 		"swarm connect /ip4/hello",
 	)
 }
+
+func TestSwarmScoresAndUnban(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d1 := th.NewDaemon(t).Start()
+	defer d1.ShutdownSuccess()
+
+	out := d1.RunSuccess("swarm", "scores")
+	assert.Equal(t, "", out.ReadStdoutTrimNewlines())
+
+	d1.RunFail("peer is not banned",
+		"swarm", "unban", "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ",
+	)
+}
+
+func TestSwarmStatus(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	out := d.RunSuccess("swarm", "status")
+	assert.Contains(t, out.ReadStdout(), "Reachability:\tunknown")
+}
+
+func TestSwarmPeersSyncStats(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	// A fresh node has synced from no one.
+	out := d.RunSuccess("swarm", "peers", "--sync-stats")
+	assert.Equal(t, "", out.ReadStdoutTrimNewlines())
+}
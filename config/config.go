@@ -8,7 +8,10 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/ipfs/go-cid"
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
@@ -19,12 +22,14 @@ import (
 type Config struct {
 	API           *APIConfig           `json:"api"`
 	Bootstrap     *BootstrapConfig     `json:"bootstrap"`
+	Chain         *ChainConfig         `json:"chain"`
 	Datastore     *DatastoreConfig     `json:"datastore"`
 	Heartbeat     *HeartbeatConfig     `json:"heartbeat"`
 	Mining        *MiningConfig        `json:"mining"`
 	Mpool         *MessagePoolConfig   `json:"mpool"`
 	Net           string               `json:"net"`
 	Observability *ObservabilityConfig `json:"observability"`
+	Pubsub        *PubsubConfig        `json:"pubsub"`
 	SectorBase    *SectorBaseConfig    `json:"sectorbase"`
 	Swarm         *SwarmConfig         `json:"swarm"`
 	Wallet        *WalletConfig        `json:"wallet"`
@@ -32,10 +37,33 @@ type Config struct {
 
 // APIConfig holds all configuration options related to the api.
 type APIConfig struct {
-	Address                       string   `json:"address"`
-	AccessControlAllowOrigin      []string `json:"accessControlAllowOrigin"`
-	AccessControlAllowCredentials bool     `json:"accessControlAllowCredentials"`
-	AccessControlAllowMethods     []string `json:"accessControlAllowMethods"`
+	// Address is the address the api listens on and which local commands
+	// use to reach the daemon.
+	Address string `json:"address"`
+	// Listeners are further addresses the api is served on, e.g. an external
+	// interface protected by TLS.
+	Listeners                     []*APIListenerConfig `json:"listeners,omitempty"`
+	AccessControlAllowOrigin      []string             `json:"accessControlAllowOrigin"`
+	AccessControlAllowCredentials bool                 `json:"accessControlAllowCredentials"`
+	AccessControlAllowMethods     []string             `json:"accessControlAllowMethods"`
+	// GraphQL enables serving chain and state queries over GraphQL at the
+	// graphql path of the api.
+	GraphQL bool `json:"graphql"`
+}
+
+// APIListenerConfig configures an additional address the api is served on.
+type APIListenerConfig struct {
+	// Address is a tcp or unix socket multiaddr.
+	Address string `json:"address"`
+	// TLSCertFile and TLSKeyFile are paths to a PEM encoded certificate and
+	// private key.  When set, the listener only accepts TLS connections.
+	TLSCertFile string `json:"tlsCertFile,omitempty"`
+	TLSKeyFile  string `json:"tlsKeyFile,omitempty"`
+	// Permission is the permission of requests without an api token: one of
+	// none, read, write, sign or admin.  Empty means none, so that every
+	// request needs a token.  Requests with a token have the token's
+	// permission.
+	Permission string `json:"permission,omitempty"`
 }
 
 func newDefaultAPIConfig() *APIConfig {
@@ -64,7 +92,16 @@ type DatastoreConfig struct {
 // the given key and value are valid. Validators will only be run if a property
 // being set matches the name given in this map.
 var Validators = map[string]func(string, string) error{
-	"heartbeat.nickname": validateLettersOnly,
+	"api.listeners":            validateAPIListeners,
+	"bootstrap.redialPeriod":   validateDuration,
+	"chain.maxFutureDrift":     validateDuration,
+	"chain.notaryPeriod":       validateDuration,
+	"heartbeat.nickname":       validateLettersOnly,
+	"mining.propagationDelay":  validateDuration,
+	"pubsub.seenMessagesTTL":   validateDuration,
+	"pubsub.blocks.ttl":        validateDuration,
+	"pubsub.messages.ttl":      validateDuration,
+	"swarm.connMgrGracePeriod": validateDuration,
 }
 
 func newDefaultDatastoreConfig() *DatastoreConfig {
@@ -78,11 +115,39 @@ func newDefaultDatastoreConfig() *DatastoreConfig {
 type SwarmConfig struct {
 	Address            string `json:"address"`
 	PublicRelayAddress string `json:"public_relay_address,omitempty"`
+	// PeerUploadLimit caps, in bytes per second, the data sent to each peer
+	// while serving blocks, chains and pieces.  Zero means no limit.
+	PeerUploadLimit uint64 `json:"peerUploadLimit,omitempty"`
+	// EnableQUIC adds the QUIC transport alongside TCP.  The node listens for
+	// QUIC on QUICAddress, or on the UDP port matching Address if unset.
+	EnableQUIC  bool   `json:"enableQuic,omitempty"`
+	QUICAddress string `json:"quicAddress,omitempty"`
+	// DisableAutoRelay stops the node from announcing relay addresses when it
+	// finds it cannot be dialed directly.
+	DisableAutoRelay bool `json:"disableAutoRelay,omitempty"`
+	// NATPortMap asks the local router, via UPnP or NAT-PMP, to forward the
+	// swarm's ports to this node so that peers can dial it from outside.
+	NATPortMap bool `json:"natPortMap,omitempty"`
+	// PrivateNetworkKey is the path, relative to the repo if not absolute,
+	// of a swarm.key file holding a pre-shared network key.  If set, the
+	// node only connects to peers holding the same key, and refuses peers
+	// announcing another genesis block.
+	PrivateNetworkKey string `json:"privateNetworkKey,omitempty"`
+	// ConnMgrHighWater is the number of connections above which the
+	// connection manager prunes the least useful down to ConnMgrLowWater.
+	// Connections younger than ConnMgrGracePeriod and peers serving a sync
+	// or a storage deal are kept.  Zero disables pruning.
+	ConnMgrLowWater    int    `json:"connMgrLowWater"`
+	ConnMgrHighWater   int    `json:"connMgrHighWater"`
+	ConnMgrGracePeriod string `json:"connMgrGracePeriod,omitempty"`
 }
 
 func newDefaultSwarmConfig() *SwarmConfig {
 	return &SwarmConfig{
-		Address: "/ip4/0.0.0.0/tcp/6000",
+		Address:            "/ip4/0.0.0.0/tcp/6000",
+		ConnMgrLowWater:    100,
+		ConnMgrHighWater:   200,
+		ConnMgrGracePeriod: "20s",
 	}
 }
 
@@ -91,6 +156,10 @@ type BootstrapConfig struct {
 	Addresses        []string `json:"addresses"`
 	MinPeerThreshold int      `json:"minPeerThreshold"`
 	Period           string   `json:"period,omitempty"`
+	// MaxPeerThreshold is the number of connections up to which previously
+	// seen peers are redialed every RedialPeriod. Zero disables redialing.
+	MaxPeerThreshold int    `json:"maxPeerThreshold"`
+	RedialPeriod     string `json:"redialPeriod,omitempty"`
 }
 
 // TODO: provide bootstrap node addresses
@@ -99,6 +168,66 @@ func newDefaultBootstrapConfig() *BootstrapConfig {
 		Addresses:        []string{},
 		MinPeerThreshold: 0, // TODO: we don't actually have an bootstrap peers yet.
 		Period:           "1m",
+		MaxPeerThreshold: 20,
+		RedialPeriod:     "5m",
+	}
+}
+
+// DefaultBlockCacheSize is the default number of decoded blocks the chain
+// store keeps in memory.
+const DefaultBlockCacheSize = 5000
+
+// DefaultTipSetCacheSize is the default number of assembled tipsets the chain
+// store keeps in memory.
+const DefaultTipSetCacheSize = 1000
+
+// ChainConfig holds all configuration options related to the chain store.
+type ChainConfig struct {
+	// BlockCacheSize is the number of decoded blocks kept in memory.
+	// Zero disables the block cache.
+	BlockCacheSize int `json:"blockCacheSize"`
+	// TipSetCacheSize is the number of assembled tipsets kept in memory.
+	// Zero disables the tipset cache.
+	TipSetCacheSize int `json:"tipSetCacheSize"`
+	// NotaryAddress, if set, is the wallet address whose key the node signs
+	// attestations of its head with, publishing them to the network every
+	// NotaryPeriod. The key should be dedicated to attestations.
+	NotaryAddress address.Address `json:"notaryAddress"`
+	// NotaryPeriod is the time between attestations. Golang duration units
+	// are accepted. If empty, the node attests every block time.
+	NotaryPeriod string `json:"notaryPeriod,omitempty"`
+	// MaxFutureDrift is how far ahead of the local clock the timestamp of
+	// a block received can be before the block is rejected. Golang duration
+	// units are accepted. If empty, 10 seconds are allowed.
+	MaxFutureDrift string `json:"maxFutureDrift,omitempty"`
+	// Checkpoint, if set, is a tipset trusted to be on the canonical chain.
+	// A node syncing from genesis fetches the state at the checkpoint
+	// instead of running the messages of the chain below it, and only
+	// checks that the chain's headers link back to genesis.
+	Checkpoint *CheckpointConfig `json:"checkpoint,omitempty"`
+	// MaxReorgDepth is the number of rounds of its chain the node reverts at
+	// most to switch to a heavier chain.  Heavier chains forking further
+	// back are rejected unless forced with chain force-sync.  If zero, 900
+	// rounds are allowed.
+	MaxReorgDepth uint64 `json:"maxReorgDepth,omitempty"`
+	// Forks maps the names of protocol upgrades to the heights at which they
+	// activate.  The state migration of an upgrade runs when processing the
+	// first tipset at or above its height.  All nodes of a network must
+	// configure the same forks.
+	Forks map[string]uint64 `json:"forks,omitempty"`
+}
+
+// CheckpointConfig identifies a checkpoint tipset and the root of the state
+// resulting from it.
+type CheckpointConfig struct {
+	TipSet    types.SortedCidSet `json:"tipSet"`
+	StateRoot cid.Cid            `json:"stateRoot"`
+}
+
+func newDefaultChainConfig() *ChainConfig {
+	return &ChainConfig{
+		BlockCacheSize:  DefaultBlockCacheSize,
+		TipSetCacheSize: DefaultTipSetCacheSize,
 	}
 }
 
@@ -107,6 +236,20 @@ type MiningConfig struct {
 	MinerAddress            address.Address `json:"minerAddress"`
 	AutoSealIntervalSeconds uint            `json:"autoSealIntervalSeconds"`
 	StoragePrice            *types.AttoFIL  `json:"storagePrice"`
+	// PropagationDelay is how long the miner waits after the start of each
+	// round for late blocks and messages to arrive before choosing a mining
+	// base and assembling a block. Golang duration units are accepted. If
+	// empty, a fixed fraction of the block time is used.
+	PropagationDelay string `json:"propagationDelay,omitempty"`
+	// ScheduledProducer, if set, is the only miner allowed to mine, and mines
+	// a block every round without an election so that block times are
+	// predictable. Setting it switches the node from expected consensus to
+	// proof of authority consensus. It may only be set on devnets.
+	ScheduledProducer address.Address `json:"scheduledProducer,omitempty"`
+	// BlockGasLimit caps the total gas limit of the messages the miner packs
+	// into a block below the protocol's block gas limit.  Zero packs up to
+	// the protocol limit.
+	BlockGasLimit uint64 `json:"blockGasLimit,omitempty"`
 }
 
 func newDefaultMiningConfig() *MiningConfig {
@@ -120,6 +263,19 @@ func newDefaultMiningConfig() *MiningConfig {
 // WalletConfig holds all configuration options related to the wallet.
 type WalletConfig struct {
 	DefaultAddress address.Address `json:"defaultAddress,omitempty"`
+	// NotifyWebhook is a URL that events concerning the wallet's addresses
+	// are posted to, as JSON.
+	NotifyWebhook string `json:"notifyWebhook,omitempty"`
+	// NotifyJournal is the path, relative to the repo, of a file that events
+	// concerning the wallet's addresses are appended to, one JSON object per
+	// line.
+	NotifyJournal string `json:"notifyJournal,omitempty"`
+	// NotifyEvents are the types of events notified: "received", "mined" or
+	// "failed".  All are notified if empty.
+	NotifyEvents []string `json:"notifyEvents,omitempty"`
+	// Ledger enables the wallet backend whose keys are held by a Ledger
+	// device.
+	Ledger bool `json:"ledger,omitempty"`
 }
 
 func newDefaultWalletConfig() *WalletConfig {
@@ -153,14 +309,16 @@ func newDefaultHeartbeatConfig() *HeartbeatConfig {
 
 // ObservabilityConfig is a container for configuration related to observables.
 type ObservabilityConfig struct {
-	Metrics *MetricsConfig `json:"metrics"`
-	Tracing *TraceConfig   `json:"tracing"`
+	Metrics    *MetricsConfig    `json:"metrics"`
+	Tracing    *TraceConfig      `json:"tracing"`
+	ChainStats *ChainStatsConfig `json:"chainStats"`
 }
 
 func newDefaultObservabilityConfig() *ObservabilityConfig {
 	return &ObservabilityConfig{
-		Metrics: newDefaultMetricsConfig(),
-		Tracing: newDefaultTraceConfig(),
+		Metrics:    newDefaultMetricsConfig(),
+		Tracing:    newDefaultTraceConfig(),
+		ChainStats: newDefaultChainStatsConfig(),
 	}
 }
 
@@ -193,6 +351,23 @@ type TraceConfig struct {
 	JaegerEndpoint string `json:"jaegerEndpoint"`
 }
 
+// ChainStatsConfig holds all configuration options related to sampling the
+// size of the chain and its state for capacity planning.
+type ChainStatsConfig struct {
+	// SampleInterval is the number of epochs between samples.  Zero
+	// disables sampling.
+	SampleInterval uint64 `json:"sampleInterval"`
+	// MaxSamples is the number of samples kept, oldest discarded first.
+	MaxSamples int `json:"maxSamples"`
+}
+
+func newDefaultChainStatsConfig() *ChainStatsConfig {
+	return &ChainStatsConfig{
+		SampleInterval: 10,
+		MaxSamples:     10000,
+	}
+}
+
 func newDefaultTraceConfig() *TraceConfig {
 	return &TraceConfig{
 		JaegerEndpoint:       "http://localhost:14268/api/traces",
@@ -205,8 +380,27 @@ func newDefaultTraceConfig() *TraceConfig {
 type MessagePoolConfig struct {
 	// MaxPoolSize is the maximum number of pending messages will will allow in the message pool at any time
 	MaxPoolSize int `json:"maxPoolSize"`
+	// MaxSenderPoolSize is the maximum number of pending messages from a
+	// single sender in the message pool.  Zero means the default of 1000.
+	MaxSenderPoolSize int `json:"maxSenderPoolSize,omitempty"`
 	// MaxNonceGap is the maximum nonce of a message past the last received on chain
 	MaxNonceGap types.Uint64 `json:"maxNonceGap"`
+	// MaxMessageAge is the number of tipsets after which a message still
+	// pending expires from the pool.  Zero means the default of 6.
+	MaxMessageAge uint64 `json:"maxMessageAge,omitempty"`
+	// MinGasPrice is the lowest gas price of the messages admitted to the
+	// pool or relayed.  Unset, any positive gas price is admitted.
+	MinGasPrice *types.AttoFIL `json:"minGasPrice,omitempty"`
+	// MinGasLimit is the lowest gas limit of the messages admitted to the
+	// pool or relayed.
+	MinGasLimit types.GasUnits `json:"minGasLimit,omitempty"`
+	// PersistAll makes the pool persist the messages received from the
+	// network across restarts, not only those submitted locally.
+	PersistAll bool `json:"persistAll,omitempty"`
+	// ReplaceByFeePercent is the least percentage by which a message must
+	// raise the gas price of the pending message with the same sender and
+	// nonce to replace it.  Zero means the default of 25%.
+	ReplaceByFeePercent uint64 `json:"replaceByFeePercent,omitempty"`
 }
 
 func newDefaultMessagePoolConfig() *MessagePoolConfig {
@@ -216,6 +410,42 @@ func newDefaultMessagePoolConfig() *MessagePoolConfig {
 	}
 }
 
+// PubsubConfig holds all configuration options related to gossip.
+type PubsubConfig struct {
+	// SeenMessagesTTL is how long the ids of gossiped messages are remembered
+	// so that the copies relayed by other peers are dropped. Golang duration
+	// units are accepted.
+	SeenMessagesTTL string `json:"seenMessagesTTL"`
+	// Blocks and Messages configure the deduplication of gossiped blocks and
+	// messages by content, which catches copies published under other ids.
+	Blocks   *GossipDedupConfig `json:"blocks"`
+	Messages *GossipDedupConfig `json:"messages"`
+}
+
+// GossipDedupConfig configures the cache of the validation results of the
+// content recently received on a pubsub topic.
+type GossipDedupConfig struct {
+	// CacheSize is the number of results kept. Zero disables the cache.
+	CacheSize int `json:"cacheSize"`
+	// TTL is how long a result is reused before the content is validated
+	// again. Golang duration units are accepted.
+	TTL string `json:"ttl"`
+}
+
+func newDefaultPubsubConfig() *PubsubConfig {
+	return &PubsubConfig{
+		SeenMessagesTTL: "2m",
+		Blocks: &GossipDedupConfig{
+			CacheSize: 1000,
+			TTL:       "2m",
+		},
+		Messages: &GossipDedupConfig{
+			CacheSize: 10000,
+			TTL:       "2m",
+		},
+	}
+}
+
 // SectorBaseConfig holds all configuration options related to the node's
 // sector storage.
 type SectorBaseConfig struct {
@@ -236,6 +466,7 @@ func NewDefaultConfig() *Config {
 	return &Config{
 		API:           newDefaultAPIConfig(),
 		Bootstrap:     newDefaultBootstrapConfig(),
+		Chain:         newDefaultChainConfig(),
 		Datastore:     newDefaultDatastoreConfig(),
 		Swarm:         newDefaultSwarmConfig(),
 		Mining:        newDefaultMiningConfig(),
@@ -245,6 +476,7 @@ func NewDefaultConfig() *Config {
 		Mpool:         newDefaultMessagePoolConfig(),
 		SectorBase:    newDefaultSectorbaseConfig(),
 		Observability: newDefaultObservabilityConfig(),
+		Pubsub:        newDefaultPubsubConfig(),
 	}
 }
 
@@ -369,6 +601,44 @@ func validate(dottedKey string, jsonString string) error {
 	return nil
 }
 
+// validateDuration validates that a given value is a string parseable as a
+// duration. If it is not, an error is returned using the given key for the
+// message.
+func validateDuration(key string, value string) error {
+	var str string
+	if err := json.Unmarshal([]byte(value), &str); err != nil {
+		return errors.Errorf(`"%s" must be a duration string`, key)
+	}
+	if _, err := time.ParseDuration(str); err != nil {
+		return errors.Wrapf(err, `"%s" must be a duration`, key)
+	}
+	return nil
+}
+
+// validateAPIListeners validates that a given value is a list of api
+// listeners with valid addresses and permissions, each with both or neither
+// of a TLS certificate and key.
+func validateAPIListeners(key string, value string) error {
+	var listeners []*APIListenerConfig
+	if err := json.Unmarshal([]byte(value), &listeners); err != nil {
+		return errors.Errorf(`"%s" must be a list of listeners`, key)
+	}
+	for _, l := range listeners {
+		if _, err := ma.NewMultiaddr(l.Address); err != nil {
+			return errors.Wrapf(err, `"%s" contains invalid address %q`, key, l.Address)
+		}
+		if (l.TLSCertFile == "") != (l.TLSKeyFile == "") {
+			return errors.Errorf(`"%s" listener %s must set both or neither of tlsCertFile and tlsKeyFile`, key, l.Address)
+		}
+		switch l.Permission {
+		case "", "none", "read", "write", "sign", "admin":
+		default:
+			return errors.Errorf(`"%s" listener %s has unknown permission %q`, key, l.Address, l.Permission)
+		}
+	}
+	return nil
+}
+
 // validateLettersOnly validates that a given value contains only letters. If it
 // does not, an error is returned using the given key for the message.
 func validateLettersOnly(key string, value string) error {
@@ -55,12 +55,20 @@ func TestWriteFile(t *testing.T) {
 			"GET",
 			"POST",
 			"PUT"
-		]
+		],
+		"graphql": false
 	},
 	"bootstrap": {
 		"addresses": [],
 		"minPeerThreshold": 0,
-		"period": "1m"
+		"period": "1m",
+		"maxPeerThreshold": 20,
+		"redialPeriod": "5m"
+	},
+	"chain": {
+		"blockCacheSize": 5000,
+		"tipSetCacheSize": 1000,
+		"notaryAddress": "empty"
 	},
 	"datastore": {
 		"type": "badgerds",
@@ -75,7 +83,8 @@ func TestWriteFile(t *testing.T) {
 	"mining": {
 		"minerAddress": "empty",
 		"autoSealIntervalSeconds": 120,
-		"storagePrice": "0"
+		"storagePrice": "0",
+		"scheduledProducer": "empty"
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
@@ -92,13 +101,31 @@ func TestWriteFile(t *testing.T) {
 			"jaegerTracingEnabled": false,
 			"probabilitySampler": 1,
 			"jaegerEndpoint": "http://localhost:14268/api/traces"
+		},
+		"chainStats": {
+			"sampleInterval": 10,
+			"maxSamples": 10000
+		}
+	},
+	"pubsub": {
+		"seenMessagesTTL": "2m",
+		"blocks": {
+			"cacheSize": 1000,
+			"ttl": "2m"
+		},
+		"messages": {
+			"cacheSize": 10000,
+			"ttl": "2m"
 		}
 	},
 	"sectorbase": {
 		"rootdir": ""
 	},
 	"swarm": {
-		"address": "/ip4/0.0.0.0/tcp/6000"
+		"address": "/ip4/0.0.0.0/tcp/6000",
+		"connMgrLowWater": 100,
+		"connMgrHighWater": 200,
+		"connMgrGracePeriod": "20s"
 	},
 	"wallet": {
 		"defaultAddress": "empty"
@@ -124,6 +151,51 @@ func TestSetRejectsInvalidNicks(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSetRejectsInvalidPropagationDelay(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	err := cfg.Set("mining.propagationDelay", "\"1.5s\"")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5s", cfg.Mining.PropagationDelay)
+	err = cfg.Set("mining.propagationDelay", "\"soon\"")
+	assert.Error(t, err)
+	err = cfg.Set("mining.propagationDelay", "3")
+	assert.Error(t, err)
+}
+
+func TestSetRejectsInvalidPubsubTTLs(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("pubsub.blocks.ttl", "\"5m\""))
+	assert.Equal(t, "5m", cfg.Pubsub.Blocks.TTL)
+	assert.Error(t, cfg.Set("pubsub.messages.ttl", "\"later\""))
+	assert.Error(t, cfg.Set("pubsub.seenMessagesTTL", "120"))
+}
+
+func TestSetRejectsInvalidAPIListeners(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	err := cfg.Set("api.listeners", `[{"address": "/unix/tmp/filecoin.sock"}, {"address": "/ip4/0.0.0.0/tcp/3454", "tlsCertFile": "api.crt", "tlsKeyFile": "api.key"}]`)
+	assert.NoError(t, err)
+	require.Len(t, cfg.API.Listeners, 2)
+	assert.Equal(t, "api.crt", cfg.API.Listeners[1].TLSCertFile)
+	err = cfg.Set("api.listeners", `[{"address": "localhost:3454"}]`)
+	assert.Error(t, err)
+	err = cfg.Set("api.listeners", `[{"address": "/ip4/0.0.0.0/tcp/3454", "tlsCertFile": "api.crt"}]`)
+	assert.Error(t, err)
+	err = cfg.Set("api.listeners", `[{"address": "/ip4/0.0.0.0/tcp/3454", "permission": "read"}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "read", cfg.API.Listeners[0].Permission)
+	err = cfg.Set("api.listeners", `[{"address": "/ip4/0.0.0.0/tcp/3454", "permission": "everything"}]`)
+	assert.Error(t, err)
+}
+
 func TestConfigRoundtrip(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -0,0 +1,123 @@
+package consensus
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/metrics/tracing"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// Authority implements a proof of authority consensus: a fixed set of
+// producers mine a block every round, without tickets, proofs or a power
+// table.  It makes block production deterministic on development networks and
+// in integration tests, and must not be used anywhere else.
+type Authority struct {
+	// cstore is used for loading state trees during message running.
+	cstore *hamt.CborIpldStore
+
+	// bstore contains data referenced by actors within the state during
+	// message running.
+	bstore blockstore.Blockstore
+
+	// processor is what we use to process messages and pay rewards
+	processor Processor
+
+	genesisCid cid.Cid
+
+	// producers is the set of miners allowed to mine blocks.
+	producers map[address.Address]bool
+}
+
+// Ensure Authority satisfies the Protocol interface at compile time.
+var _ Protocol = (*Authority)(nil)
+
+// NewAuthority is the constructor for the Authority consensus.Protocol
+// module, in which only producers mine blocks.
+func NewAuthority(cs *hamt.CborIpldStore, bs blockstore.Blockstore, processor Processor, gCid cid.Cid, producers ...address.Address) Protocol {
+	a := &Authority{
+		cstore:     cs,
+		bstore:     bs,
+		processor:  processor,
+		genesisCid: gCid,
+		producers:  make(map[address.Address]bool),
+	}
+	for _, p := range producers {
+		a.producers[p] = true
+	}
+	return a
+}
+
+// NewValidTipSet creates a new tipset from the input blocks after checking
+// their structure and message signatures, as Expected does.
+func (a *Authority) NewValidTipSet(ctx context.Context, blks []*types.Block) (types.TipSet, error) {
+	if err := ValidateBlocks(ctx, blks); err != nil {
+		return nil, err
+	}
+	return types.NewTipSet(blks...)
+}
+
+// Weight returns the weight of ts in uint64 encoded fixed point
+// representation.  Each block adds ECV to its parent weight, so that the
+// weight of the chain is the one Expected gives, under its genesis weight
+// algorithm, to a chain of miners without power.
+func (a *Authority) Weight(ctx context.Context, ts types.TipSet, pSt state.Tree) (uint64, error) {
+	if len(ts) == 1 && ts.ToSlice()[0].Cid().Equals(a.genesisCid) {
+		return uint64(0), nil
+	}
+	parentW, err := ts.ParentWeight()
+	if err != nil {
+		return uint64(0), err
+	}
+	return parentW + uint64(len(ts))*ECV*1000, nil
+}
+
+// IsHeavier returns true if tipset a is heavier than tipset b.  Ties are
+// broken as in Expected.
+func (a *Authority) IsHeavier(ctx context.Context, ta, tb types.TipSet, aSt, bSt state.Tree) (bool, error) {
+	aW, err := a.Weight(ctx, ta, aSt)
+	if err != nil {
+		return false, err
+	}
+	bW, err := a.Weight(ctx, tb, bSt)
+	if err != nil {
+		return false, err
+	}
+	if aW != bW {
+		return aW > bW, nil
+	}
+	return breakWeightTie(ta, tb)
+}
+
+// RunStateTransition returns the state resulting from applying ts to pSt.
+// It errors if a block of ts was not mined by one of the producers, or if
+// running the messages in the tipset results in an error.
+func (a *Authority) RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (st state.Tree, err error) {
+	ctx, span := trace.StartSpan(ctx, "Authority.RunStateTransition")
+	span.AddAttributes(trace.StringAttribute("tipset", ts.String()))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	for _, blk := range ts.ToSlice() {
+		if !a.producers[blk.Miner] {
+			return nil, errors.Errorf("block mined by %s, which is not an authorized producer", blk.Miner)
+		}
+	}
+
+	vms := vm.NewStorageMap(a.bstore)
+	st, err = runMessages(ctx, a.cstore, a.processor, pSt, vms, ts, ancestors)
+	if err != nil {
+		return nil, err
+	}
+	if err := vms.Flush(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
@@ -0,0 +1,87 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+func TestAuthority_RunStateTransition(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	cistore, bstore, _ := setupCborBlockstoreProofs()
+	genesisBlock, err := consensus.DefaultGenesis(cistore, bstore)
+	require.NoError(t, err)
+
+	setup := func(t *testing.T) (types.TipSet, state.Tree, []*types.Block) {
+		pTipSet, err := types.NewTipSet(genesisBlock)
+		require.NoError(t, err)
+		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+		return pTipSet, stateTree, requireMakeBlocks(ctx, t, pTipSet, stateTree, vm.NewStorageMap(bstore))
+	}
+
+	t.Run("accepts blocks of the producers without an election", func(t *testing.T) {
+		pTipSet, stateTree, blocks := setup(t)
+		// None of the miners has power, so none of them could win an election.
+		auth := consensus.NewAuthority(cistore, bstore, testhelpers.NewTestProcessor(), genesisBlock.Cid(), blocks[0].Miner, blocks[1].Miner)
+
+		tipSet, err := auth.NewValidTipSet(ctx, blocks[:2])
+		require.NoError(t, err)
+
+		_, err = auth.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects blocks of other miners", func(t *testing.T) {
+		pTipSet, stateTree, blocks := setup(t)
+		auth := consensus.NewAuthority(cistore, bstore, testhelpers.NewTestProcessor(), genesisBlock.Cid(), blocks[0].Miner)
+
+		tipSet, err := auth.NewValidTipSet(ctx, blocks)
+		require.NoError(t, err)
+
+		_, err = auth.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not an authorized producer")
+	})
+}
+
+func TestAuthority_Weight(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	cistore, bstore, _ := setupCborBlockstoreProofs()
+	genesisBlock, err := consensus.DefaultGenesis(cistore, bstore)
+	require.NoError(t, err)
+	auth := consensus.NewAuthority(cistore, bstore, testhelpers.NewTestProcessor(), genesisBlock.Cid())
+
+	genTs, err := types.NewTipSet(genesisBlock)
+	require.NoError(t, err)
+	w, err := auth.Weight(ctx, genTs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), w)
+
+	blk1 := &types.Block{Height: 1, ParentWeight: types.Uint64(20000), Ticket: []byte{1}}
+	blk2 := &types.Block{Height: 1, ParentWeight: types.Uint64(20000), Ticket: []byte{2}}
+	ts, err := types.NewTipSet(blk1, blk2)
+	require.NoError(t, err)
+	w, err = auth.Weight(ctx, ts, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20000+2*consensus.ECV*1000), w)
+
+	min, max := consensus.WeightIncreaseBounds(1, 2)
+	assert.True(t, w-20000 >= min && w-20000 <= max)
+}
@@ -0,0 +1,86 @@
+package consensus
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ValidationWorkers is the greatest number of blocks of a tipset validated
+// concurrently.
+var ValidationWorkers = runtime.NumCPU()
+
+// ValidateBlocks checks the structure and the message signatures of each of
+// blks, validating up to ValidationWorkers blocks concurrently.  It returns
+// the error of the first invalid block in the order of blks, whatever order
+// the blocks finish validating in.
+func ValidateBlocks(ctx context.Context, blks []*types.Block) error {
+	return validateConcurrently(ctx, blks, ValidationWorkers, validateBlock)
+}
+
+// validateBlock checks the structure of b and the signatures of its
+// messages: the signatures of messages from BLS addresses are checked at once
+// against the block's aggregate signature, and the others one by one.  It
+// only depends on the block, so blocks can be validated concurrently.
+func validateBlock(b *types.Block) error {
+	if err := ValidateBlockStructure(b); err != nil {
+		return err
+	}
+	if err := b.VerifyBLSAggregate(); err != nil {
+		return err
+	}
+	for _, msg := range b.Messages {
+		if !msg.From.Empty() && msg.From.Protocol() == address.BLS {
+			continue
+		}
+		if !msg.VerifySignature() {
+			return errors.Errorf("block contains message with invalid signature from %s", msg.From)
+		}
+	}
+	return nil
+}
+
+// validateConcurrently runs validate on each of blks with at most workers
+// running at a time, and returns the error of the first block in blks that
+// failed.
+func validateConcurrently(ctx context.Context, blks []*types.Block, workers int, validate func(*types.Block) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	errs := make([]error, len(blks))
+	if len(blks) == 1 || workers == 1 {
+		for i, blk := range blks {
+			errs[i] = validate(blk)
+		}
+	} else {
+		sem := make(chan struct{}, workers)
+		var wg sync.WaitGroup
+		for i, blk := range blks {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return ctx.Err()
+			}
+			wg.Add(1)
+			go func(i int, blk *types.Block) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				errs[i] = validate(blk)
+			}(i, blk)
+		}
+		wg.Wait()
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/consensus"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestValidateBlocks(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	validBlock := func() *types.Block {
+		return &types.Block{
+			StateRoot: types.SomeCid(),
+			Messages:  types.NewSignedMsgs(2, signer),
+		}
+	}
+
+	t.Run("accepts valid blocks", func(t *testing.T) {
+		blks := []*types.Block{validBlock(), validBlock(), validBlock()}
+		assert.NoError(t, consensus.ValidateBlocks(ctx, blks))
+	})
+
+	t.Run("rejects a message with an invalid signature", func(t *testing.T) {
+		bad := validBlock()
+		bad.Messages[1].Signature = []byte("not a signature")
+		err := consensus.ValidateBlocks(ctx, []*types.Block{validBlock(), bad})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid signature")
+	})
+
+	t.Run("returns the error of the first invalid block whatever the workers", func(t *testing.T) {
+		defer func(workers int) { consensus.ValidationWorkers = workers }(consensus.ValidationWorkers)
+
+		noStateRoot := validBlock()
+		noStateRoot.StateRoot = cid.Undef
+		badSignature := validBlock()
+		badSignature.Messages[0].Signature = []byte("not a signature")
+		blks := []*types.Block{validBlock(), noStateRoot, badSignature, validBlock()}
+
+		for _, workers := range []int{1, 2, 8} {
+			consensus.ValidationWorkers = workers
+			assert.EqualError(t, consensus.ValidateBlocks(ctx, blks), "block has nil StateRoot")
+		}
+	})
+}
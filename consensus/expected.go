@@ -9,7 +9,6 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math/big"
-	"strings"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-hamt-ipld"
@@ -118,29 +117,60 @@ func NewExpected(cs *hamt.CborIpldStore, bs blockstore.Blockstore, processor Pro
 }
 
 // NewValidTipSet creates a new tipset from the input blocks that is guaranteed
-// to be valid. It operates by validating each block and further checking that
-// this tipset contains only blocks with the same heights, parent weights,
-// and parent sets.
+// to be valid. It operates by validating each block, concurrently, and further
+// checking that this tipset contains only blocks with the same heights, parent
+// weights, and parent sets.
 func (c *Expected) NewValidTipSet(ctx context.Context, blks []*types.Block) (types.TipSet, error) {
-	for _, blk := range blks {
-		if err := c.validateBlockStructure(ctx, blk); err != nil {
-			return nil, err
-		}
+	if err := ValidateBlocks(ctx, blks); err != nil {
+		return nil, err
 	}
 	return types.NewTipSet(blks...)
 }
 
+// validateBlockGas checks that the gas limits of a block's messages add up to
+// no more than the block gas limit.
+func validateBlockGas(msgs []*types.SignedMessage) error {
+	remaining := types.BlockGasLimit
+	for _, msg := range msgs {
+		// Compared one by one so that the total can't overflow.
+		if msg.GasLimit > remaining {
+			return errors.Errorf("block messages have a total gas limit over the block limit of %d", types.BlockGasLimit)
+		}
+		remaining -= msg.GasLimit
+	}
+	return nil
+}
+
 // ValidateBlockStructure verifies that this block, on its own, is structurally and
 // cryptographically valid. This means checking that all of its fields are
 // properly filled out and its signatures are correct. Checking the validity of
 // state changes must be done separately and only once the state of the
 // previous block has been validated. TODO: not yet signature checking
-func (c *Expected) validateBlockStructure(ctx context.Context, b *types.Block) error {
+func ValidateBlockStructure(b *types.Block) error {
 	// TODO: validate signature on block
 	if !b.StateRoot.Defined() {
 		return fmt.Errorf("block has nil StateRoot")
 	}
 
+	if len(b.Messages) > types.BlockMessageLimit {
+		return errors.Errorf("block has %d messages, limit is %d", len(b.Messages), types.BlockMessageLimit)
+	}
+	if size := b.Size(); size > types.BlockSizeLimit {
+		return errors.Errorf("block is %d bytes, limit is %d", size, types.BlockSizeLimit)
+	}
+	if err := validateBlockGas(b.Messages); err != nil {
+		return err
+	}
+	for _, msg := range b.Messages {
+		size, err := msg.Size()
+		if err != nil {
+			return errors.Wrap(err, "failed to serialize message")
+		}
+		if size > types.MessageSizeLimit {
+			return errors.Errorf("block contains message of %d bytes, limit is %d", size, types.MessageSizeLimit)
+		}
+	}
+
 	return nil
 }
 
@@ -162,29 +192,34 @@ func (c *Expected) Weight(ctx context.Context, ts types.TipSet, pSt state.Tree)
 	if err != nil {
 		return uint64(0), err
 	}
-	// Each block in the tipset adds ECV + ECPrm * miner_power to parent weight.
+	// Each block in the tipset adds to the parent weight according to the
+	// weight algorithm in force at its height.
+	height, err := ts.Height()
+	if err != nil {
+		return uint64(0), err
+	}
+	alg := Weights.At(height)
 	totalBytes, err := c.PwrTableView.Total(ctx, pSt, c.bstore)
 	if err != nil {
 		return uint64(0), err
 	}
-	floatTotalBytes := new(big.Float).SetInt64(int64(totalBytes))
-	floatECV := new(big.Float).SetInt64(int64(ECV))
-	floatECPrM := new(big.Float).SetInt64(int64(ECPrM))
 	for _, blk := range ts.ToSlice() {
 		minerBytes, err := c.PwrTableView.Miner(ctx, pSt, c.bstore, blk.Miner)
 		if err != nil {
 			return uint64(0), err
 		}
-		floatOwnBytes := new(big.Float).SetInt64(int64(minerBytes))
-		wBlk := new(big.Float)
-		wBlk.Quo(floatOwnBytes, floatTotalBytes)
-		wBlk.Mul(wBlk, floatECPrM) // Power addition
-		wBlk.Add(wBlk, floatECV)   // Constant addition
-		w.Add(w, wBlk)
+		w.Add(w, alg.BlockWeight(minerBytes, totalBytes))
 	}
 	return types.BigToFixed(w)
 }
 
+// WeightIncreaseBounds returns the least and greatest amounts, in fixed point,
+// by which a tipset of n blocks at height can add to its parent weight under
+// the network's weight schedule.
+func WeightIncreaseBounds(height uint64, n int) (min uint64, max uint64) {
+	return Weights.IncreaseBounds(height, n)
+}
+
 // IsHeavier returns true if tipset a is heavier than tipset b, and false
 // vice versa.  In the rare case where two tipsets have the same weight ties
 // are broken by taking the tipset with the smallest ticket.  In the event that
@@ -207,7 +242,16 @@ func (c *Expected) IsHeavier(ctx context.Context, a, b types.TipSet, aSt, bSt st
 		return aW > bW, nil
 	}
 
-	// To break ties compare the min tickets.
+	return breakWeightTie(a, b)
+}
+
+// breakWeightTie returns true if tipset a wins over tipset b of the same
+// weight.  The tipset with the smallest min ticket wins and, if the min
+// tickets are equal, the one whose sorted block cids sort first.  The rule
+// only depends on the two tipsets, so every node picks the same head among
+// equal-weight forks whatever order it receives them in.
+func breakWeightTie(a, b types.TipSet) (bool, error) {
+	// Compare the min tickets first.
 	aTicket, err := a.MinTicket()
 	if err != nil {
 		return false, err
@@ -217,21 +261,18 @@ func (c *Expected) IsHeavier(ctx context.Context, a, b types.TipSet, aSt, bSt st
 		return false, err
 	}
 
-	cmp := bytes.Compare(bTicket, aTicket)
+	cmp := bytes.Compare(aTicket, bTicket)
 	if cmp != 0 {
-		// a is heavier if b's ticket is greater than a's ticket.
-		return cmp == 1, nil
+		return cmp < 0, nil
 	}
 
-	// Tie break on cid ids.
-	// TODO: I think this is drastically impacted by number of blocks in tipset
-	// i.e. bigger tipset is always heavier.  Not sure if this is ok, need to revist.
-	cmp = strings.Compare(a.String(), b.String())
+	// Then the block cids.
+	cmp = a.ToSortedCidSet().Compare(b.ToSortedCidSet())
 	if cmp == 0 {
 		// Caller is mistakenly calling on two identical tipsets.
 		return false, ErrUnorderedTipSets
 	}
-	return cmp == 1, nil
+	return cmp < 0, nil
 }
 
 // RunStateTransition is the chain transition function that goes from a
@@ -261,7 +302,7 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ance
 	}
 
 	vms := vm.NewStorageMap(c.bstore)
-	st, err = c.runMessages(ctx, pSt, vms, ts, ancestors)
+	st, err = runMessages(ctx, c.cstore, c.processor, pSt, vms, ts, ancestors)
 	if err != nil {
 		return nil, err
 	}
@@ -280,24 +321,36 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ance
 //    Returns nil if all the above checks pass.
 // See https://github.com/filecoin-project/specs/blob/master/mining.md#chain-validation
 func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.TipSet, parentTs types.TipSet) error {
-	for _, blk := range ts.ToSlice() {
-		// TODO: Also need to validate BlockSig
+	// TODO: Also need to validate BlockSig
 
-		// TODO: Once we've picked a delay function (see #2119), we need to
-		// verify its proof here. The proof will likely be written to a field on
-		// the mined block.
+	// TODO: Once we've picked a delay function (see #2119), we need to
+	// verify its proof here. The proof will likely be written to a field on
+	// the mined block.
 
-		// See https://github.com/filecoin-project/specs/blob/master/mining.md#ticket-checking
-		result, err := IsWinningTicket(ctx, c.bstore, c.PwrTableView, st, blk.Ticket, blk.Miner)
+	// Looking up power reads the state tree, which is not safe for
+	// concurrent use, so the powers are looked up before the tickets of the
+	// blocks are checked concurrently.
+	blks := ts.ToSlice()
+	totalPower, err := c.PwrTableView.Total(ctx, st, c.bstore)
+	if err != nil {
+		return errors.Wrap(err, "can't check for winning ticket: Couldn't get totalPower")
+	}
+	minerPowers := make(map[*types.Block]*types.BytesAmount, len(blks))
+	for _, blk := range blks {
+		minerPower, err := c.PwrTableView.Miner(ctx, st, c.bstore, blk.Miner)
 		if err != nil {
-			return errors.Wrap(err, "can't check for winning ticket")
+			return errors.Wrap(err, "can't check for winning ticket: Couldn't get minerPower")
 		}
+		minerPowers[blk] = minerPower
+	}
 
-		if !result {
+	// See https://github.com/filecoin-project/specs/blob/master/mining.md#ticket-checking
+	return validateConcurrently(ctx, blks, ValidationWorkers, func(blk *types.Block) error {
+		if !CompareTicketPower(blk.Ticket, minerPowers[blk], totalPower) {
 			return errors.New("not a winning ticket")
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // IsWinningTicket fetches miner power & total power, returns true if it's a winning ticket, false if not,
@@ -322,12 +375,12 @@ func IsWinningTicket(ctx context.Context, bs blockstore.Blockstore, ptv PowerTab
 
 // CompareTicketPower abstracts the actual comparison logic so it can be used by some test
 // helpers
-func CompareTicketPower(ticket types.Signature, minerPower uint64, totalPower uint64) bool {
+func CompareTicketPower(ticket types.Signature, minerPower *types.BytesAmount, totalPower *types.BytesAmount) bool {
 	lhs := &big.Int{}
 	lhs.SetBytes(ticket)
-	lhs.Mul(lhs, big.NewInt(int64(totalPower)))
+	lhs.Mul(lhs, totalPower.BigInt())
 	rhs := &big.Int{}
-	rhs.Mul(big.NewInt(int64(minerPower)), ticketDomain)
+	rhs.Mul(minerPower.BigInt(), ticketDomain)
 	return lhs.Cmp(rhs) < 0
 }
 
@@ -357,7 +410,7 @@ func CreateChallengeSeed(parents types.TipSet, nullBlkCount uint64) (types.PoStC
 // An error is returned if individual blocks contain messages that do not
 // lead to successful state transitions.  An error is also returned if the node
 // faults while running aggregate state computation.
-func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (state.Tree, error) {
+func runMessages(ctx context.Context, cstore *hamt.CborIpldStore, processor Processor, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (state.Tree, error) {
 	var cpySt state.Tree
 
 	// TODO: order blocks in the tipset by ticket
@@ -368,12 +421,12 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 			return nil, errors.Wrap(err, "error validating block state")
 		}
 		// state copied so changes don't propagate between block validations
-		cpySt, err = state.LoadStateTree(ctx, c.cstore, cpyCid, builtin.Actors)
+		cpySt, err = state.LoadStateTree(ctx, cstore, cpyCid, builtin.Actors)
 		if err != nil {
 			return nil, errors.Wrap(err, "error validating block state")
 		}
 
-		receipts, err := c.processor.ProcessBlock(ctx, cpySt, vms, blk, ancestors)
+		receipts, err := processor.ProcessBlock(ctx, cpySt, vms, blk, ancestors)
 		if err != nil {
 			return nil, errors.Wrap(err, "error validating block state")
 		}
@@ -397,7 +450,7 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 	// NOTE: It is possible to optimize further by applying block validation
 	// in sorted order to reuse first block transitions as the starting state
 	// for the tipSetProcessor.
-	_, err := c.processor.ProcessTipSet(ctx, st, vms, ts, ancestors)
+	_, err := processor.ProcessTipSet(ctx, st, vms, ts, ancestors)
 	if err != nil {
 		return nil, errors.Wrap(err, "error validating tipset")
 	}
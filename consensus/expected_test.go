@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
@@ -38,7 +39,7 @@ func TestNewExpected(t *testing.T) {
 	})
 }
 
-// TestExpected_NewValidTipSet also tests validateBlockStructure.
+// TestExpected_NewValidTipSet also tests ValidateBlockStructure.
 func TestExpected_NewValidTipSet(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -92,6 +93,44 @@ func TestExpected_NewValidTipSet(t *testing.T) {
 		assert.Error(t, err, "Foo")
 		assert.Nil(t, tipSet)
 	})
+
+	t.Run("NewValidTipSet rejects blocks over the message limit", func(t *testing.T) {
+		parentBlock := types.NewBlockForTest(nil, 0)
+		blk := types.NewBlockForTest(parentBlock, 1)
+		blk.StateRoot = types.SomeCid()
+		mockSigner := types.NewMockSigner(types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed()))
+		blk.Messages = types.NewSignedMsgs(types.BlockMessageLimit+1, mockSigner)
+
+		exp := consensus.NewExpected(cistore, bstore, consensus.NewDefaultProcessor(), ptv, types.SomeCid(), verifier)
+
+		tipSet, err := exp.NewValidTipSet(ctx, []*types.Block{blk})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "limit")
+		assert.Nil(t, tipSet)
+	})
+
+	t.Run("NewValidTipSet rejects blocks over the gas limit", func(t *testing.T) {
+		parentBlock := types.NewBlockForTest(nil, 0)
+		blk := types.NewBlockForTest(parentBlock, 1)
+		blk.StateRoot = types.SomeCid()
+		mockSigner := types.NewMockSigner(types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed()))
+		newMsg := types.NewMessageForTestGetter()
+		for i := 0; i < 2; i++ {
+			msg := newMsg()
+			msg.From = mockSigner.Addresses[0]
+			msg.Nonce = types.Uint64(i)
+			smsg, err := types.NewSignedMessage(*msg, mockSigner, types.NewGasPrice(1), types.BlockGasLimit/2+1)
+			require.NoError(t, err)
+			blk.Messages = append(blk.Messages, smsg)
+		}
+
+		exp := consensus.NewExpected(cistore, bstore, consensus.NewDefaultProcessor(), ptv, types.SomeCid(), verifier)
+
+		tipSet, err := exp.NewValidTipSet(ctx, []*types.Block{blk})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "gas limit")
+		assert.Nil(t, tipSet)
+	})
 }
 
 // requireMakeBlocks sets up 3 blocks with 3 owner actors and 3 miner actors and puts them in the state tree.
@@ -280,9 +319,82 @@ func TestCompareTicketPower(t *testing.T) {
 	for _, c := range cases {
 		ticket := [65]byte{}
 		ticket[0] = c.ticket
-		res := consensus.CompareTicketPower(ticket[:], c.myPower, c.totalPower)
+		res := consensus.CompareTicketPower(ticket[:], types.NewBytesAmount(c.myPower), types.NewBytesAmount(c.totalPower))
 		assert.Equal(t, c.wins, res, "%+v", c)
 	}
+
+	// Power beyond what a uint64 holds is compared exactly.
+	exabytes := new(big.Int).Lsh(big.NewInt(1), 70)
+	ticket := [65]byte{}
+	ticket[0] = 0x30
+	half := types.NewBytesAmountFromBigInt(exabytes)
+	total := half.Add(half)
+	assert.True(t, consensus.CompareTicketPower(ticket[:], half, total))
+	ticket[0] = 0x90
+	assert.False(t, consensus.CompareTicketPower(ticket[:], half, total))
+}
+
+func TestExpected_IsHeavier(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, bstore, verifier := setupCborBlockstoreProofs()
+	ptv := testhelpers.NewTestPowerTableView(1, 5)
+	exp := consensus.NewExpected(cst, bstore, consensus.NewDefaultProcessor(), ptv, types.SomeCid(), verifier)
+
+	mkTipSet := func(parentWeight uint64, ticket byte, nonce uint64) types.TipSet {
+		return testhelpers.RequireNewTipSet(t, &types.Block{
+			Height:       types.Uint64(1),
+			ParentWeight: types.Uint64(parentWeight),
+			Ticket:       types.Signature{ticket},
+			Nonce:        types.Uint64(nonce),
+		})
+	}
+
+	t.Run("the heavier tipset wins whatever its ticket", func(t *testing.T) {
+		heavy := mkTipSet(20, 2, 0)
+		light := mkTipSet(10, 1, 0)
+
+		heavier, err := exp.IsHeavier(ctx, heavy, light, nil, nil)
+		require.NoError(t, err)
+		assert.True(t, heavier)
+		heavier, err = exp.IsHeavier(ctx, light, heavy, nil, nil)
+		require.NoError(t, err)
+		assert.False(t, heavier)
+	})
+
+	t.Run("the smallest ticket wins a tie", func(t *testing.T) {
+		small := mkTipSet(10, 1, 0)
+		big := mkTipSet(10, 2, 0)
+
+		heavier, err := exp.IsHeavier(ctx, small, big, nil, nil)
+		require.NoError(t, err)
+		assert.True(t, heavier)
+		heavier, err = exp.IsHeavier(ctx, big, small, nil, nil)
+		require.NoError(t, err)
+		assert.False(t, heavier)
+	})
+
+	t.Run("the smallest cids win a tie with equal tickets", func(t *testing.T) {
+		first := mkTipSet(10, 1, 0)
+		second := mkTipSet(10, 1, 1)
+		if first.ToSortedCidSet().Compare(second.ToSortedCidSet()) > 0 {
+			first, second = second, first
+		}
+
+		heavier, err := exp.IsHeavier(ctx, first, second, nil, nil)
+		require.NoError(t, err)
+		assert.True(t, heavier)
+		heavier, err = exp.IsHeavier(ctx, second, first, nil, nil)
+		require.NoError(t, err)
+		assert.False(t, heavier)
+	})
+
+	t.Run("identical tipsets are not ordered", func(t *testing.T) {
+		ts := mkTipSet(10, 1, 0)
+		_, err := exp.IsHeavier(ctx, ts, ts, nil, nil)
+		assert.Equal(t, consensus.ErrUnorderedTipSets, err)
+	})
 }
 
 func TestCreateChallenge(t *testing.T) {
@@ -339,12 +451,12 @@ func NewFailingTestPowerTableView(minerPower int64, totalPower int64) *FailingTe
 	return &FailingTestPowerTableView{uint64(minerPower), uint64(totalPower)}
 }
 
-func (tv *FailingTestPowerTableView) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (uint64, error) {
-	return tv.totalPower, errors.New("something went wrong with the total power")
+func (tv *FailingTestPowerTableView) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (*types.BytesAmount, error) {
+	return types.NewBytesAmount(tv.totalPower), errors.New("something went wrong with the total power")
 }
 
-func (tv *FailingTestPowerTableView) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (uint64, error) {
-	return tv.minerPower, nil
+func (tv *FailingTestPowerTableView) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (*types.BytesAmount, error) {
+	return types.NewBytesAmount(tv.minerPower), nil
 }
 
 func (tv *FailingTestPowerTableView) HasPower(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) bool {
@@ -357,12 +469,12 @@ func NewFailingMinerTestPowerTableView(minerPower int64, totalPower int64) *Fail
 	return &FailingMinerTestPowerTableView{uint64(minerPower), uint64(totalPower)}
 }
 
-func (tv *FailingMinerTestPowerTableView) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (uint64, error) {
-	return tv.totalPower, nil
+func (tv *FailingMinerTestPowerTableView) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (*types.BytesAmount, error) {
+	return types.NewBytesAmount(tv.totalPower), nil
 }
 
-func (tv *FailingMinerTestPowerTableView) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (uint64, error) {
-	return tv.minerPower, errors.New("something went wrong with the miner power")
+func (tv *FailingMinerTestPowerTableView) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (*types.BytesAmount, error) {
+	return types.NewBytesAmount(tv.minerPower), errors.New("something went wrong with the miner power")
 }
 
 func (tv *FailingMinerTestPowerTableView) HasPower(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) bool {
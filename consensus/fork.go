@@ -0,0 +1,142 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// Fork activates a registered upgrade at a height of the chain.  The state
+// migration of the upgrade runs over the state of the parent of the first
+// tipset at or above Height, before any of the tipset's messages is applied.
+type Fork struct {
+	Upgrade string `json:"upgrade"`
+	Height  uint64 `json:"height"`
+}
+
+var (
+	forksLk sync.RWMutex
+	// forks is kept sorted by height.
+	forks []*Fork
+)
+
+// ScheduleFork activates the registered upgrade named name at height.  All
+// nodes of a network must schedule the same forks, so forks are scheduled
+// from configuration at node startup, before any tipset is processed.
+func ScheduleFork(name string, height uint64) error {
+	u, ok := LookupUpgrade(name)
+	if !ok {
+		return fmt.Errorf("unknown upgrade %s", name)
+	}
+	if u.Migrate == nil {
+		return fmt.Errorf("upgrade %s has no state migration", name)
+	}
+
+	forksLk.Lock()
+	defer forksLk.Unlock()
+
+	for _, f := range forks {
+		if f.Upgrade == name {
+			if f.Height == height {
+				return nil
+			}
+			return fmt.Errorf("upgrade %s already scheduled at height %d", name, f.Height)
+		}
+	}
+	forks = append(forks, &Fork{Upgrade: name, Height: height})
+	sort.SliceStable(forks, func(i, j int) bool { return forks[i].Height < forks[j].Height })
+	return nil
+}
+
+// ScheduledForks returns the scheduled forks in order of activation.
+func ScheduledForks() []Fork {
+	forksLk.RLock()
+	defer forksLk.RUnlock()
+
+	scheduled := make([]Fork, len(forks))
+	for i, f := range forks {
+		scheduled[i] = *f
+	}
+	return scheduled
+}
+
+// MigrateState runs the state migrations of the forks activating between the
+// height of a parent tipset, exclusive, and height, inclusive, in order of
+// activation.  Null rounds between the parent and its child do not skip
+// forks: a fork scheduled during one activates with the next tipset.
+func MigrateState(ctx context.Context, st state.Tree, vms vm.StorageMap, parentHeight, height uint64) error {
+	for _, f := range ScheduledForks() {
+		if f.Height <= parentHeight || f.Height > height {
+			continue
+		}
+		u, _ := LookupUpgrade(f.Upgrade)
+		log.Infof("running state migration of upgrade %s at height %d", f.Upgrade, height)
+		if err := u.Migrate(ctx, st, vms); err != nil {
+			return errors.Wrapf(err, "state migration of upgrade %s failed", f.Upgrade)
+		}
+	}
+	return nil
+}
+
+// ActorMigration rewrites the state of an actor held in storage, committing
+// the new head of the actor.
+type ActorMigration func(ctx context.Context, storage exec.Storage) error
+
+// MigrateActors returns a state migration rewriting the state of all actors
+// with code with migrate, if not nil, and giving them newCode.
+func MigrateActors(code, newCode cid.Cid, migrate ActorMigration) StateMigration {
+	return func(ctx context.Context, st state.Tree, vms vm.StorageMap) error {
+		// collect the actors first as the tree can't be modified while walked
+		var addrs []address.Address
+		err := st.ForEachActor(ctx, func(addr address.Address, act *actor.Actor) error {
+			if act.Code.Equals(code) {
+				addrs = append(addrs, addr)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range addrs {
+			act, err := st.GetActor(ctx, addr)
+			if err != nil {
+				return err
+			}
+			if migrate != nil {
+				if err := migrate(ctx, vms.NewStorage(addr, act)); err != nil {
+					return errors.Wrapf(err, "failed to migrate state of actor %s", addr)
+				}
+			}
+			act.Code = newCode
+			if err := st.SetActor(ctx, addr, act); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// parentHeight returns the height of the parent of a tipset at bh from its
+// ancestors, or the height right below bh if they are not known.
+func parentHeight(bh *types.BlockHeight, ancestors []types.TipSet) (uint64, error) {
+	if len(ancestors) > 0 && len(ancestors[0]) > 0 {
+		return ancestors[0].Height()
+	}
+	h := bh.AsBigInt().Uint64()
+	if h == 0 {
+		return 0, nil
+	}
+	return h - 1, nil
+}
@@ -0,0 +1,91 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	. "github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+func TestMigrateState(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	newCode := types.NewCidForTestGetter()()
+	addr := address.NewForTestGetter()()
+
+	// far above the heights reached by other tests, as forks are global
+	const forkHeight = uint64(1000000000)
+
+	migrated := "migrated"
+	require.NoError(t, RegisterUpgrade(&Upgrade{
+		Name: "fork-test",
+		Migrate: MigrateActors(types.AccountActorCodeCid, newCode, func(ctx context.Context, storage exec.Storage) error {
+			c, err := storage.Put(migrated)
+			if err != nil {
+				return err
+			}
+			return storage.Commit(c, storage.Head())
+		}),
+		Processor: NewDefaultProcessor(),
+	}))
+
+	t.Run("rejects unknown upgrades", func(t *testing.T) {
+		assert.Error(t, ScheduleFork("unknown", forkHeight))
+	})
+
+	t.Run("schedules an upgrade once", func(t *testing.T) {
+		require.NoError(t, ScheduleFork("fork-test", forkHeight))
+		require.NoError(t, ScheduleFork("fork-test", forkHeight))
+		assert.Error(t, ScheduleFork("fork-test", forkHeight+1))
+		assert.Contains(t, ScheduledForks(), Fork{Upgrade: "fork-test", Height: forkHeight})
+	})
+
+	t.Run("migrates actors when the fork activates", func(t *testing.T) {
+		vms := vm.NewStorageMap(bs)
+		_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+			addr: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10)),
+		})
+
+		require.NoError(t, MigrateState(ctx, st, vms, forkHeight-2, forkHeight-1))
+		assert.Equal(t, types.AccountActorCodeCid, state.MustGetActor(st, addr).Code)
+
+		// the fork falls in the null rounds between the parent and its child
+		require.NoError(t, MigrateState(ctx, st, vms, forkHeight-1, forkHeight+2))
+		act := state.MustGetActor(st, addr)
+		assert.Equal(t, newCode, act.Code)
+		assert.Equal(t, types.NewAttoFILFromFIL(10), act.Balance)
+
+		var head string
+		chunk, err := vms.NewStorage(addr, act).Get(act.Head)
+		require.NoError(t, err)
+		require.NoError(t, actor.UnmarshalStorage(chunk, &head))
+		assert.Equal(t, migrated, head)
+	})
+
+	t.Run("does not migrate again after the fork", func(t *testing.T) {
+		vms := vm.NewStorageMap(bs)
+		_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+			addr: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10)),
+		})
+
+		require.NoError(t, MigrateState(ctx, st, vms, forkHeight, forkHeight+1))
+		assert.Equal(t, types.AccountActorCodeCid, state.MustGetActor(st, addr).Code)
+	})
+}
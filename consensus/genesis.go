@@ -11,8 +11,11 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/cron"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/state"
@@ -27,11 +30,14 @@ var (
 	defaultAccounts map[address.Address]*types.AttoFIL
 )
 
+// DefaultNetworkBalance is the balance of the reward actor at the network
+// address in the default genesis.
+var DefaultNetworkBalance = types.NewAttoFILFromFIL(10000000000)
+
 func init() {
 	defaultAccounts = map[address.Address]*types.AttoFIL{
-		address.NetworkAddress: types.NewAttoFILFromFIL(10000000000),
-		address.TestAddress:    types.NewAttoFILFromFIL(50000),
-		address.TestAddress2:   types.NewAttoFILFromFIL(60000),
+		address.TestAddress:  types.NewAttoFILFromFIL(50000),
+		address.TestAddress2: types.NewAttoFILFromFIL(60000),
 	}
 }
 
@@ -190,6 +196,14 @@ func DefaultGenesis(cst *hamt.CborIpldStore, bs blockstore.Blockstore) (*types.B
 	return MakeGenesisFunc()(cst, bs)
 }
 
+// defaultCronEntries are the methods the state transition calls on every
+// tipset: the checks of the proving periods of miners and the expiry of the
+// payment channels of deals.
+var defaultCronEntries = []cron.Entry{
+	{Actor: address.StorageMarketAddress, Method: "checkProvingPeriods"},
+	{Actor: address.PaymentBrokerAddress, Method: "expireChannels"},
+}
+
 // SetupDefaultActors inits the builtin actors that are required to run filecoin.
 func SetupDefaultActors(ctx context.Context, st state.Tree, storageMap vm.StorageMap, storeType types.ProofsMode) error {
 	for addr, val := range defaultAccounts {
@@ -203,6 +217,24 @@ func SetupDefaultActors(ctx context.Context, st state.Tree, storageMap vm.Storag
 		}
 	}
 
+	rwAct := reward.NewActor(DefaultNetworkBalance)
+	err := (&reward.Actor{}).InitializeState(storageMap.NewStorage(address.NetworkAddress, rwAct), reward.DefaultState())
+	if err != nil {
+		return err
+	}
+	if err := st.SetActor(ctx, address.NetworkAddress, rwAct); err != nil {
+		return err
+	}
+
+	crAct := cron.NewActor()
+	err = (&cron.Actor{}).InitializeState(storageMap.NewStorage(address.CronAddress, crAct), defaultCronEntries)
+	if err != nil {
+		return err
+	}
+	if err := st.SetActor(ctx, address.CronAddress, crAct); err != nil {
+		return err
+	}
+
 	stAct, err := storagemarket.NewActor()
 	if err != nil {
 		return err
@@ -224,5 +256,9 @@ func SetupDefaultActors(ctx context.Context, st state.Tree, storageMap vm.Storag
 
 	pbAct.Balance = types.NewAttoFILFromFIL(0)
 
-	return st.SetActor(ctx, address.PaymentBrokerAddress, pbAct)
+	if err := st.SetActor(ctx, address.PaymentBrokerAddress, pbAct); err != nil {
+		return err
+	}
+
+	return st.SetActor(ctx, address.MultisigFactoryAddress, multisig.NewFactoryActor())
 }
@@ -0,0 +1,92 @@
+package consensus
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// blockGossipValidatorAPI allows the validator to look up miners' keys in
+// the latest state.
+type blockGossipValidatorAPI interface {
+	MinerGetKey(ctx context.Context, minerAddr address.Address) ([]byte, error)
+}
+
+// BlockGossipValidator performs the checks on a block header received over
+// pubsub that are cheap enough to run before the header is relayed to other
+// peers and the block's messages are fetched.  Blocks that pass may still be
+// invalid, full validation happens when the block is synced.
+type BlockGossipValidator struct {
+	api blockGossipValidatorAPI
+	// maxFutureDrift is how far ahead of the local clock a block can be
+	// timestamped.
+	maxFutureDrift time.Duration
+}
+
+// NewBlockGossipValidator creates a new BlockGossipValidator with an api,
+// rejecting blocks timestamped more than maxFutureDrift in the future.
+func NewBlockGossipValidator(api blockGossipValidatorAPI, maxFutureDrift time.Duration) *BlockGossipValidator {
+	return &BlockGossipValidator{api: api, maxFutureDrift: maxFutureDrift}
+}
+
+// Validate returns an error if the header is structurally invalid, is
+// timestamped too far in the future, comes from a miner unknown to the latest
+// state or carries a ticket its miner did not sign.
+func (v *BlockGossipValidator) Validate(ctx context.Context, h *types.BlockHeader) error {
+	if !h.Cid.Defined() {
+		return errors.New("header has no block cid")
+	}
+	if !h.StateRoot.Defined() {
+		return errors.New("block has nil StateRoot")
+	}
+	if len(h.MessageCids) > types.BlockMessageLimit {
+		return errors.Errorf("block has %d messages, limit is %d", len(h.MessageCids), types.BlockMessageLimit)
+	}
+	if h.Miner.Empty() {
+		return errors.New("block has no miner")
+	}
+	if len(h.Ticket) == 0 {
+		return errors.New("block has no ticket")
+	}
+	if h.Height > 0 && h.Parents.Empty() {
+		return errors.New("block above genesis has no parents")
+	}
+	if err := ValidateFutureDrift(ctx, h.Cid, uint64(h.Timestamp), time.Now(), v.maxFutureDrift); err != nil {
+		return err
+	}
+	return v.validateTicket(ctx, h)
+}
+
+// ValidateBlock returns an error if b, fetched after its header passed
+// Validate, is not the block the header announced, is structurally invalid
+// or contains a message with an invalid signature.
+func (v *BlockGossipValidator) ValidateBlock(h *types.BlockHeader, b *types.Block) error {
+	if err := h.CheckBlock(b); err != nil {
+		return err
+	}
+	return validateBlock(b)
+}
+
+// validateTicket checks that the block's ticket was signed with its miner's
+// key.  Blocks from miners unknown to the latest state can't be checked and
+// are rejected, so that they aren't relayed.  A node that is behind catches up
+// on them by syncing.
+func (v *BlockGossipValidator) validateTicket(ctx context.Context, h *types.BlockHeader) error {
+	minerKey, err := v.api.MinerGetKey(ctx, h.Miner)
+	if err != nil {
+		return errors.Wrapf(err, "cannot check ticket of block from unknown miner %s", h.Miner)
+	}
+	signerAddr, err := address.NewSecp256k1Address(minerKey)
+	if err != nil {
+		return errors.Wrapf(err, "miner %s has invalid key", h.Miner)
+	}
+	signed := append(append([]byte{}, h.Proof...), signerAddr.Bytes()...)
+	if !types.IsValidSignature(signed, signerAddr, h.Ticket) {
+		return errors.Errorf("block ticket was not signed by miner %s", h.Miner)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestBlockGossipValidator(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	minerAddr := address.NewForTestGetter()()
+	api := &fakeMinerKeyAPI{keys: map[address.Address][]byte{minerAddr: keys[0].PublicKey()}}
+	validator := consensus.NewBlockGossipValidator(api, consensus.DefaultMaxFutureDrift)
+
+	newBlock := func(t *testing.T) *types.Block {
+		proof := types.PoStProof([]byte{1, 2, 3})
+		ticket, err := consensus.CreateTicket(proof, keys[0].PublicKey(), signer)
+		require.NoError(t, err)
+		return &types.Block{
+			Miner:     minerAddr,
+			Ticket:    ticket,
+			Proof:     proof,
+			Height:    1,
+			Parents:   types.NewSortedCidSet(types.SomeCid()),
+			StateRoot: types.SomeCid(),
+			Messages:  []*types.SignedMessage{newMessage(t, addresses[0], addresses[1], 0, 5, 1, 0)},
+		}
+	}
+
+	newHeader := func(t *testing.T, blk *types.Block) *types.BlockHeader {
+		header, err := types.NewBlockHeader(blk)
+		require.NoError(t, err)
+		return header
+	}
+
+	t.Run("accepts a valid header", func(t *testing.T) {
+		assert.NoError(t, validator.Validate(ctx, newHeader(t, newBlock(t))))
+	})
+
+	t.Run("rejects a header timestamped too far in the future", func(t *testing.T) {
+		blk := newBlock(t)
+		blk.Timestamp = types.Uint64(time.Now().Add(time.Hour).Unix())
+		assert.Error(t, validator.Validate(ctx, newHeader(t, blk)))
+
+		blk.Timestamp = types.Uint64(time.Now().Unix())
+		assert.NoError(t, validator.Validate(ctx, newHeader(t, blk)))
+	})
+
+	t.Run("rejects a header without a state root", func(t *testing.T) {
+		blk := newBlock(t)
+		blk.StateRoot = cid.Undef
+		assert.Error(t, validator.Validate(ctx, newHeader(t, blk)))
+	})
+
+	t.Run("rejects a header without parents", func(t *testing.T) {
+		blk := newBlock(t)
+		blk.Parents = types.SortedCidSet{}
+		assert.Error(t, validator.Validate(ctx, newHeader(t, blk)))
+	})
+
+	t.Run("rejects a header with too many messages", func(t *testing.T) {
+		header := newHeader(t, newBlock(t))
+		header.MessageCids = make([]cid.Cid, types.BlockMessageLimit+1)
+		assert.Error(t, validator.Validate(ctx, header))
+	})
+
+	t.Run("rejects a ticket signed by another key", func(t *testing.T) {
+		blk := newBlock(t)
+		ticket, err := consensus.CreateTicket(blk.Proof, keys[1].PublicKey(), signer)
+		require.NoError(t, err)
+		blk.Ticket = ticket
+		assert.Error(t, validator.Validate(ctx, newHeader(t, blk)))
+	})
+
+	t.Run("rejects a ticket of an unknown miner", func(t *testing.T) {
+		blk := newBlock(t)
+		blk.Miner = addresses[1]
+		assert.Error(t, validator.Validate(ctx, newHeader(t, blk)))
+	})
+
+	t.Run("accepts the announced block", func(t *testing.T) {
+		blk := newBlock(t)
+		assert.NoError(t, validator.ValidateBlock(newHeader(t, blk), blk))
+	})
+
+	t.Run("rejects a block that was not announced", func(t *testing.T) {
+		header := newHeader(t, newBlock(t))
+		other := newBlock(t)
+		other.Nonce = 1
+		assert.Error(t, validator.ValidateBlock(header, other))
+	})
+
+	t.Run("rejects a message with an invalid signature", func(t *testing.T) {
+		blk := newBlock(t)
+		blk.Messages[0].Signature = []byte{}
+		assert.Error(t, validator.ValidateBlock(newHeader(t, blk), blk))
+	})
+}
+
+type fakeMinerKeyAPI struct {
+	keys map[address.Address][]byte
+}
+
+func (api *fakeMinerKeyAPI) MinerGetKey(ctx context.Context, minerAddr address.Address) ([]byte, error) {
+	key, ok := api.keys[minerAddr]
+	if !ok {
+		return nil, errors.New("miner not found")
+	}
+	return key, nil
+}
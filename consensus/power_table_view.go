@@ -7,8 +7,10 @@ import (
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
 )
 
@@ -17,11 +19,11 @@ import (
 type PowerTableView interface {
 	// Total returns the total bytes stored by all miners in the given
 	// state.
-	Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (uint64, error)
+	Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (*types.BytesAmount, error)
 
 	// Miner returns the total bytes stored by the miner of the
 	// input address in the given state.
-	Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (uint64, error)
+	Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (*types.BytesAmount, error)
 
 	// HasPower returns true if the input address is associated with a
 	// miner that has storage power in the network.
@@ -35,45 +37,15 @@ type MarketView struct{}
 
 var _ PowerTableView = &MarketView{}
 
-// Total returns the total storage as a uint64.  If the total storage
-// value exceeds the max value of a uint64 this method errors.
-// TODO: uint64 has enough bits to express about 1 exabyte of total storage.
-// This should be increased for v1.
-func (v *MarketView) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (uint64, error) {
-	vms := vm.NewStorageMap(bstore)
-	rets, ec, err := CallQueryMethod(ctx, st, vms, address.StorageMarketAddress, "getTotalStorage", []byte{}, address.Undef, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	if ec != 0 {
-		return 0, errors.Errorf("non-zero return code from query message: %d", ec)
-	}
-	res := big.NewInt(0)
-	res.SetBytes(rets[0])
-
-	return res.Uint64(), nil
+// Total returns the total storage committed to the storage market.
+func (v *MarketView) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (*types.BytesAmount, error) {
+	return queryPower(ctx, st, bstore, address.StorageMarketAddress, "getTotalStorage")
 }
 
-// Miner returns the storage that this miner has committed as a uint64.
-// If the total storage value exceeds the max value of a uint64 this method
-// errors.
+// Miner returns the storage that this miner has committed.
 // TODO: currently power is in sectors, figure out if & how it should be converted to bytes.
-// TODO: uint64 has enough bits to express about 1 exabyte.  This
-// should probably be increased for v1.
-func (v *MarketView) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (uint64, error) {
-	vms := vm.NewStorageMap(bstore)
-	rets, ec, err := CallQueryMethod(ctx, st, vms, mAddr, "getPower", []byte{}, address.Undef, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	if ec != 0 {
-		return 0, errors.Errorf("non-zero return code from query message: %d", ec)
-	}
-	ret := big.NewInt(0).SetBytes(rets[0])
-
-	return ret.Uint64(), nil
+func (v *MarketView) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (*types.BytesAmount, error) {
+	return queryPower(ctx, st, bstore, mAddr, "getPower")
 }
 
 // HasPower returns true if the provided address belongs to a miner with power
@@ -88,5 +60,29 @@ func (v *MarketView) HasPower(ctx context.Context, st state.Tree, bstore blockst
 		panic(err) //hey guys, dropping errors is BAD
 	}
 
-	return numBytes > 0
+	return numBytes.IsPositive()
+}
+
+// queryPower calls a method of the actor at to returning an amount of storage
+// as an abi.Integer.
+func queryPower(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, to address.Address, method string) (*types.BytesAmount, error) {
+	vms := vm.NewStorageMap(bstore)
+	rets, ec, err := CallQueryMethod(ctx, st, vms, to, method, []byte{}, address.Undef, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if ec != 0 {
+		return nil, errors.Errorf("non-zero return code from query message: %d", ec)
+	}
+	ret, err := abi.Deserialize(rets[0], abi.Integer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s result", method)
+	}
+	power, ok := ret.Val.(*big.Int)
+	if !ok {
+		return nil, errors.Errorf("expected *big.Int from %s, got %T", method, ret.Val)
+	}
+
+	return types.NewBytesAmountFromBigInt(power), nil
 }
@@ -6,8 +6,11 @@ import (
 
 	"go.opencensus.io/trace"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/cron"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/metrics/tracing"
@@ -19,19 +22,21 @@ import (
 
 var pbTimer *metrics.Float64Timer
 var amTimer *metrics.Float64Timer
+var cronFailureCt *metrics.Int64Counter
 
 func init() {
 	amTimer = metrics.NewTimer("consensus/apply_message", "Duration of message application in milliseconds")
 	pbTimer = metrics.NewTimer("consensus/process_block", "Duration of block processing in milliseconds")
+	cronFailureCt = metrics.NewInt64Counter("consensus/cron_failure", "Number of cron entry calls that failed")
 }
 
 // BlockRewarder applies all rewards due to the miner's owner for processing a block including block reward and gas
 type BlockRewarder interface {
 	// BlockReward pays out the mining reward
-	BlockReward(ctx context.Context, st state.Tree, minerOwnerAddr address.Address) error
+	BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerOwnerAddr address.Address) error
 
 	// GasReward pays gas from the sender to the miner
-	GasReward(ctx context.Context, st state.Tree, minerOwnerAddr address.Address, msg *types.SignedMessage, cost *types.AttoFIL) error
+	GasReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerOwnerAddr address.Address, msg *types.SignedMessage, cost *types.AttoFIL) error
 }
 
 // ApplicationResult contains the result of successfully applying one message.
@@ -50,6 +55,16 @@ type ProcessTipSetResponse struct {
 	Results   []*ApplicationResult
 	Successes types.SortedCidSet
 	Failures  types.SortedCidSet
+
+	// CronFailures are the cron entries whose call failed after the messages
+	// were applied, e.g. for lack of gas, and whose work was not done.
+	CronFailures []*CronFailure
+}
+
+// CronFailure is a cron entry whose call failed.
+type CronFailure struct {
+	Entry cron.Entry
+	Err   error
 }
 
 // DefaultProcessor handles all block processing.
@@ -68,6 +83,16 @@ func NewDefaultProcessor() *DefaultProcessor {
 	}
 }
 
+// NewBlockProcessor creates a default processor for the messages of blocks
+// that passed block validation, which checked the aggregate signature of
+// their BLS messages.  It must not process messages from anywhere else.
+func NewBlockProcessor() *DefaultProcessor {
+	return &DefaultProcessor{
+		signedMessageValidator: NewBlockMessageValidator(),
+		blockRewarder:          NewDefaultBlockRewarder(),
+	}
+}
+
 // NewConfiguredProcessor creates a default processor with custom validation and rewards.
 func NewConfiguredProcessor(validator SignedMessageValidator, rewarder BlockRewarder) *DefaultProcessor {
 	return &DefaultProcessor{
@@ -156,6 +181,10 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 	bh := types.NewBlockHeight(h)
 	msgFilter := make(map[string]struct{})
 
+	if err := migrateForks(ctx, st, vms, bh, ancestors); err != nil {
+		return &emptyRes, err
+	}
+
 	tips := ts.ToSlice()
 	types.SortBlocks(tips)
 
@@ -184,7 +213,7 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 			// TODO is there ever a reason to try a duplicate failed message again within the same tipset?
 			msgFilter[mCid.String()] = struct{}{}
 		}
-		amRes, err := p.ApplyMessagesAndPayRewards(ctx, st, vms, msgs, minerOwnerAddr, bh, ancestors)
+		amRes, err := p.applyMessagesAndPayRewards(ctx, st, vms, msgs, minerOwnerAddr, bh, ancestors)
 		if err != nil {
 			return &emptyRes, err
 		}
@@ -212,6 +241,12 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 		}
 	}
 
+	cronFailures, err := runCron(ctx, st, vms, bh, ancestors)
+	if err != nil {
+		return &emptyRes, err
+	}
+	res.CronFailures = cronFailures
+
 	return &res, nil
 }
 
@@ -305,7 +340,7 @@ func (p *DefaultProcessor) ApplyMessage(ctx context.Context, st state.Tree, vms
 	}
 
 	if r.GasAttoFIL.IsPositive() {
-		gasError := p.blockRewarder.GasReward(ctx, st, minerOwnerAddr, msg, r.GasAttoFIL)
+		gasError := p.blockRewarder.GasReward(ctx, st, vms, minerOwnerAddr, msg, r.GasAttoFIL)
 		if gasError != nil {
 			return nil, errors.NewFaultError("failed to transfer gas reward to owner of miner")
 		}
@@ -494,6 +529,7 @@ func (p *DefaultProcessor) attemptApplyMessage(ctx context.Context, st *state.Ca
 		GasTracker:  gasTracker,
 		BlockHeight: bh,
 		Ancestors:   ancestors,
+		Tracer:      vm.TracerFromContext(ctx),
 	}
 	vmCtx := vm.NewVMContext(vmCtxParams)
 
@@ -526,6 +562,10 @@ type ApplyMessagesResponse struct {
 	// Application Errors
 	PermanentErrors []error
 	TemporaryErrors []error
+
+	// CronFailures are the cron entries whose call failed after the messages
+	// were applied.
+	CronFailures []*CronFailure
 }
 
 // ApplyMessagesAndPayRewards begins by paying the block mining reward to the miner's owner. It then applies messages to a state tree.
@@ -533,13 +573,32 @@ type ApplyMessagesResponse struct {
 // groupings of messages with permanent failures, temporary failures, and
 // successes, and the permanent and temporary errors raised during application.
 // ApplyMessages will return an error iff a fault message occurs.
+// The state migrations of the forks activating at bh run before the reward is
+// paid, as for the first block of a tipset, and the cron actor is ticked after
+// the messages are applied, as for the last.
 // Precondition: signatures of messages are checked by the caller.
 func (p *DefaultProcessor) ApplyMessagesAndPayRewards(ctx context.Context, st state.Tree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []types.TipSet) (ApplyMessagesResponse, error) {
+	if err := migrateForks(ctx, st, vms, bh, ancestors); err != nil {
+		return ApplyMessagesResponse{}, err
+	}
+	ret, err := p.applyMessagesAndPayRewards(ctx, st, vms, messages, minerOwnerAddr, bh, ancestors)
+	if err != nil {
+		return ApplyMessagesResponse{}, err
+	}
+	cronFailures, err := runCron(ctx, st, vms, bh, ancestors)
+	if err != nil {
+		return ApplyMessagesResponse{}, err
+	}
+	ret.CronFailures = cronFailures
+	return ret, nil
+}
+
+func (p *DefaultProcessor) applyMessagesAndPayRewards(ctx context.Context, st state.Tree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []types.TipSet) (ApplyMessagesResponse, error) {
 	var emptyRet ApplyMessagesResponse
 	var ret ApplyMessagesResponse
 
 	// transfer block reward to miner's owner from network address.
-	if err := p.blockRewarder.BlockReward(ctx, st, minerOwnerAddr); err != nil {
+	if err := p.blockRewarder.BlockReward(ctx, st, vms, minerOwnerAddr); err != nil {
 		return ApplyMessagesResponse{}, err
 	}
 
@@ -568,7 +627,127 @@ func (p *DefaultProcessor) ApplyMessagesAndPayRewards(ctx context.Context, st st
 	return ret, nil
 }
 
-// DefaultBlockRewarder pays the block reward from the network actor to the miner's owner.
+// migrateForks runs the state migrations of the forks activating with a tipset
+// at bh.  A failed migration is a fault.
+func migrateForks(ctx context.Context, st state.Tree, vms vm.StorageMap, bh *types.BlockHeight, ancestors []types.TipSet) error {
+	ph, err := parentHeight(bh, ancestors)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "failed to get parent height")
+	}
+	if err := MigrateState(ctx, st, vms, ph, bh.AsBigInt().Uint64()); err != nil {
+		return errors.FaultErrorWrap(err, "state migration failed")
+	}
+	return nil
+}
+
+// runCron calls the entries of the cron actor once the messages of a tipset
+// at bh are applied.  Calls are free and the gas of each is bounded by the
+// block gas limit.  Each entry runs in its own cached state tree, discarded if
+// the call fails as for a failed message, so one failing entry doesn't undo or
+// prevent the others.  The failed entries are returned, logged and counted:
+// their work, e.g. checking proving periods, is not done for the tipset.  The
+// error is only set for faults, and chains created before the cron actor have
+// no entries.
+func runCron(ctx context.Context, st state.Tree, vms vm.StorageMap, bh *types.BlockHeight, ancestors []types.TipSet) ([]*CronFailure, error) {
+	entries, err := cronEntries(ctx, st, vms)
+	if err != nil {
+		return nil, errors.FaultErrorWrap(err, "failed to get cron entries")
+	}
+
+	var failures []*CronFailure
+	for _, entry := range entries {
+		err := runCronEntry(ctx, st, vms, bh, ancestors, entry)
+		if errors.IsFault(err) {
+			return nil, err
+		}
+		if err != nil {
+			log.Errorf("cron entry %s.%s at height %s failed: %s", entry.Actor, entry.Method, bh, err)
+			cronFailureCt.Inc(ctx, 1)
+			failures = append(failures, &CronFailure{Entry: entry, Err: err})
+		}
+	}
+	return failures, nil
+}
+
+// runCronEntry sends the message of a cron entry, committing its writes to st
+// only if it succeeds.  Errors other than faults are failures of the call.
+func runCronEntry(ctx context.Context, st state.Tree, vms vm.StorageMap, bh *types.BlockHeight, ancestors []types.TipSet, entry cron.Entry) error {
+	cachedSt := state.NewCachedStateTree(st)
+	cronActor, err := cachedSt.GetActor(ctx, address.CronAddress)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "failed to get cron actor")
+	}
+	toActor, err := cachedSt.GetActor(ctx, entry.Actor)
+	if state.IsActorNotFoundError(err) {
+		return errors.NewRevertErrorf("cron entry %s.%s has no actor", entry.Actor, entry.Method)
+	}
+	if err != nil {
+		return errors.FaultErrorWrapf(err, "failed to get actor of cron entry %s", entry.Actor)
+	}
+
+	// entries handle the heights since the previous tipset, null rounds
+	// included
+	ph, err := parentHeight(bh, ancestors)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "failed to get parent height")
+	}
+	params, err := abi.ToEncodedValues(types.NewBlockHeight(ph))
+	if err != nil {
+		return errors.FaultErrorWrap(err, "failed to encode cron entry params")
+	}
+
+	msg := types.NewMessage(address.CronAddress, entry.Actor, 0, nil, entry.Method, params)
+	gasTracker := vm.NewGasTracker()
+	gasTracker.MsgGasLimit = types.BlockGasLimit
+
+	vmCtxParams := vm.NewContextParams{
+		From:        cronActor,
+		To:          toActor,
+		Message:     msg,
+		State:       cachedSt,
+		StorageMap:  vms,
+		GasTracker:  gasTracker,
+		BlockHeight: bh,
+		Ancestors:   ancestors,
+		Tracer:      vm.TracerFromContext(ctx),
+	}
+	vmCtx := vm.NewVMContext(vmCtxParams)
+	if _, _, err := vm.Send(ctx, vmCtx); err != nil {
+		return err
+	}
+
+	if err := cachedSt.Commit(ctx); err != nil {
+		return errors.FaultErrorWrap(err, "could not commit cron state")
+	}
+	return nil
+}
+
+// cronEntries returns the entries of the cron actor, none if there is no
+// cron actor.
+func cronEntries(ctx context.Context, st state.Tree, vms vm.StorageMap) ([]cron.Entry, error) {
+	cronActor, err := st.GetActor(ctx, address.CronAddress)
+	if state.IsActorNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	chunk, err := vms.NewStorage(address.CronAddress, cronActor).Get(cronActor.Head)
+	if err != nil {
+		return nil, err
+	}
+	var cronState cron.State
+	if err := actor.UnmarshalStorage(chunk, &cronState); err != nil {
+		return nil, err
+	}
+	return cronState.Entries, nil
+}
+
+// DefaultBlockRewarder pays the rewards set by the reward actor at the network
+// address: the block reward from the actor's funds and the gas fees of
+// messages, part of which may be burnt.  Chains whose network actor is a
+// plain account, created before the reward actor, are paid the default
+// rewards.
 type DefaultBlockRewarder struct{}
 
 // NewDefaultBlockRewarder creates a new rewarder that actually pays the appropriate rewards.
@@ -579,28 +758,78 @@ func NewDefaultBlockRewarder() *DefaultBlockRewarder {
 var _ BlockRewarder = (*DefaultBlockRewarder)(nil)
 
 // BlockReward transfers the block reward from the network actor to the miner's owner.
-func (br *DefaultBlockRewarder) BlockReward(ctx context.Context, st state.Tree, minerOwnerAddr address.Address) error {
+func (br *DefaultBlockRewarder) BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerOwnerAddr address.Address) error {
+	params, err := rewardParams(ctx, st, vms)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "Error attempting to read block reward")
+	}
+
+	// the reward is capped by the funds left to the network actor
+	amount := params.BlockReward
+	networkActor, err := st.GetActor(ctx, address.NetworkAddress)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "Error attempting to read network balance")
+	}
+	if networkActor.Balance.LessThan(amount) {
+		amount = networkActor.Balance
+	}
+
 	cachedTree := state.NewCachedStateTree(st)
-	if err := rewardTransfer(ctx, address.NetworkAddress, minerOwnerAddr, br.BlockRewardAmount(), cachedTree); err != nil {
+	if err := rewardTransfer(ctx, address.NetworkAddress, minerOwnerAddr, amount, cachedTree); err != nil {
 		return errors.FaultErrorWrap(err, "Error attempting to pay block reward")
 	}
 	return cachedTree.Commit(ctx)
 }
 
-// GasReward transfers the gas cost reward from the sender actor to the minerOwnerAddr
-func (br *DefaultBlockRewarder) GasReward(ctx context.Context, st state.Tree, minerOwnerAddr address.Address, msg *types.SignedMessage, gas *types.AttoFIL) error {
+// GasReward transfers the gas cost from the sender actor, as a tip to the
+// minerOwnerAddr and to the burnt funds address for the part that is burnt.
+func (br *DefaultBlockRewarder) GasReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerOwnerAddr address.Address, msg *types.SignedMessage, gas *types.AttoFIL) error {
+	params, err := rewardParams(ctx, st, vms)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "Error attempting to read gas burn percent")
+	}
+	tip, burn := params.SplitGas(gas)
+
 	cachedTree := state.NewCachedStateTree(st)
-	if err := rewardTransfer(ctx, msg.From, minerOwnerAddr, gas, cachedTree); err != nil {
+	if err := rewardTransfer(ctx, msg.From, minerOwnerAddr, tip, cachedTree); err != nil {
 		return errors.FaultErrorWrap(err, "Error attempting to pay gas reward")
 	}
+	if burn.IsPositive() {
+		if err := rewardTransfer(ctx, msg.From, address.BurntFundsAddress, burn, cachedTree); err != nil {
+			return errors.FaultErrorWrap(err, "Error attempting to burn gas fee")
+		}
+	}
 	return cachedTree.Commit(ctx)
 }
 
-// BlockRewardAmount returns the max FIL value miners can claim as the block reward.
-// TODO this is one of the system parameters that should be configured as part of
-// https://github.com/filecoin-project/go-filecoin/issues/884.
+// BlockRewardAmount returns the block reward of networks created with the
+// default reward parameters.
 func (br *DefaultBlockRewarder) BlockRewardAmount() *types.AttoFIL {
-	return types.NewAttoFILFromFIL(1000)
+	return reward.DefaultBlockReward
+}
+
+// rewardParams reads the state of the reward actor at the network address, or
+// returns the default parameters if the network actor is not a reward actor.
+func rewardParams(ctx context.Context, st state.Tree, vms vm.StorageMap) (*reward.State, error) {
+	networkActor, err := st.GetActor(ctx, address.NetworkAddress)
+	if state.IsActorNotFoundError(err) {
+		return reward.DefaultState(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !networkActor.Code.Equals(types.RewardActorCodeCid) {
+		return reward.DefaultState(), nil
+	}
+
+	chunk, err := vms.NewStorage(address.NetworkAddress, networkActor).Get(networkActor.Head)
+	if err != nil {
+		return nil, err
+	}
+	var params reward.State
+	if err := actor.UnmarshalStorage(chunk, &params); err != nil {
+		return nil, err
+	}
+	return &params, nil
 }
 
 // rewardTransfer retrieves two actors from the given addresses and attempts to transfer the given value from the balance of the first's to the second.
@@ -15,6 +15,8 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/cron"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/address"
 	. "github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/state"
@@ -315,6 +317,42 @@ func TestProcessBlockReward(t *testing.T) {
 	assert.Equal(t, minerBalance.Add(blockRewardAmount), minerOwnerActor.Balance)
 }
 
+func TestDefaultBlockRewarderUsesRewardActor(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	vms := th.VMStorage()
+
+	newAddress := address.NewForTestGetter()
+	ownerAddr := newAddress()
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
+	fromAddr := mockSigner.Addresses[0]
+
+	networkAct := reward.NewActor(types.NewAttoFILFromFIL(12))
+	require.NoError(t, (&reward.Actor{}).InitializeState(vms.NewStorage(address.NetworkAddress, networkAct), reward.NewState(types.NewAttoFILFromFIL(7), 40)))
+	_, st := requireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: networkAct,
+		fromAddr:               th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(100)),
+	})
+
+	rewarder := NewDefaultBlockRewarder()
+	require.NoError(t, rewarder.BlockReward(ctx, st, vms, ownerAddr))
+	assert.Equal(t, types.NewAttoFILFromFIL(7), state.MustGetActor(st, ownerAddr).Balance)
+
+	// the reward is capped by the funds of the reward actor
+	require.NoError(t, rewarder.BlockReward(ctx, st, vms, ownerAddr))
+	assert.Equal(t, types.NewAttoFILFromFIL(12), state.MustGetActor(st, ownerAddr).Balance)
+	assert.Equal(t, types.NewZeroAttoFIL(), state.MustGetActor(st, address.NetworkAddress).Balance)
+
+	smsg, err := types.NewSignedMessage(*types.NewMessage(fromAddr, newAddress(), 0, nil, "", nil), &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	require.NoError(t, rewarder.GasReward(ctx, st, vms, ownerAddr, smsg, types.NewAttoFILFromFIL(10)))
+	assert.Equal(t, types.NewAttoFILFromFIL(90), state.MustGetActor(st, fromAddr).Balance)
+	assert.Equal(t, types.NewAttoFILFromFIL(18), state.MustGetActor(st, ownerAddr).Balance)
+	assert.Equal(t, types.NewAttoFILFromFIL(4), state.MustGetActor(st, address.BurntFundsAddress).Balance)
+}
+
 func TestProcessBlockVMErrors(t *testing.T) {
 	tf.BadUnitTestWithSideEffects(t)
 
@@ -590,6 +628,29 @@ func TestApplyMessagesValidation(t *testing.T) {
 		require.Error(t, err)
 		assert.Equal(t, "balance insufficient to cover transfer+gas", err.(*errors.ApplyErrorPermanent).Cause().Error())
 	})
+
+	t.Run("only accepts unsigned BLS messages from blocks", func(t *testing.T) {
+		ctx := context.Background()
+		blsKeys := types.MustGenerateBLSKeyInfo(1)
+		addr1, err := blsKeys[0].Address()
+		require.NoError(t, err)
+		addr2 := address.NewForTestGetter()()
+		_, st := requireMakeStateTree(t, hamt.NewCborStore(), map[address.Address]*actor.Actor{
+			addr1: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(1000)),
+			addr2: th.RequireNewAccountActor(t, types.ZeroAttoFIL),
+		})
+		msg := types.NewMessage(addr1, addr2, 0, types.NewAttoFILFromFIL(550), "", []byte{})
+		smsg, err := types.NewSignedMessage(*msg, types.NewMockSigner(blsKeys), types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		smsg.Signature = nil
+
+		_, err = NewDefaultProcessor().ApplyMessage(ctx, st, th.VMStorage(), smsg, addr2, types.NewBlockHeight(0), vm.NewGasTracker(), nil)
+		require.Error(t, err)
+		assert.Equal(t, "invalid signature by sender over message data", err.(*errors.ApplyErrorPermanent).Cause().Error())
+
+		_, err = NewBlockProcessor().ApplyMessage(ctx, st, th.VMStorage(), smsg, addr2, types.NewBlockHeight(0), vm.NewGasTracker(), nil)
+		assert.NoError(t, err)
+	})
 }
 
 // TODO add more test cases that cover the intent expressed
@@ -672,14 +733,15 @@ func TestReentrantTransferDoesntAllowMultiSpending(t *testing.T) {
 		addr2: act2,
 	})
 
-	// addr1 will attempt to double spend to addr2 by sending a reentrant message that spends twice
+	// addr1 will attempt to double spend to addr2 by sending a reentrant message that spends twice,
+	// the reentrant call is reverted
 	params, err := abi.ToEncodedValues(addr1, addr2)
 	assert.NoError(t, err)
 	msg := types.NewMessage(addr0, addr1, 0, types.ZeroAttoFIL, "attemptMultiSpend1", params)
 	_, err = th.ApplyTestMessage(st, th.VMStorage(), msg, types.NewBlockHeight(0))
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "second callSendTokens")
-	assert.Contains(t, err.Error(), "not enough balance")
+	assert.Contains(t, err.Error(), "first callSendTokens")
+	assert.Contains(t, err.Error(), "reentrant call")
 
 	// addr1 will attempt to double spend to addr2 by sending a reentrant message that spends and then spending directly
 	params, err = abi.ToEncodedValues(addr1, addr2)
@@ -687,8 +749,11 @@ func TestReentrantTransferDoesntAllowMultiSpending(t *testing.T) {
 	msg = types.NewMessage(addr0, addr1, 0, types.ZeroAttoFIL, "attemptMultiSpend2", params)
 	_, err = th.ApplyTestMessage(st, th.VMStorage(), msg, types.NewBlockHeight(0))
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed sendTokens")
-	assert.Contains(t, err.Error(), "not enough balance")
+	assert.Contains(t, err.Error(), "first callSendTokens")
+	assert.Contains(t, err.Error(), "reentrant call")
+
+	assert.Equal(t, types.NewAttoFILFromFIL(100), state.MustGetActor(st, addr1).Balance)
+	assert.True(t, state.MustGetActor(st, addr2).Balance.IsZero())
 }
 
 func TestSendToNonexistentAddressThenSpendFromIt(t *testing.T) {
@@ -1075,3 +1140,56 @@ func mustCreateMiner(ctx context.Context, t *testing.T, st state.Tree, vms vm.St
 	require.NoError(t, err)
 	return stCid, miner
 }
+
+func TestCronEntriesRunInTheirOwnState(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	vms := th.VMStorage()
+
+	// Install the fake actor so we can execute it.
+	fakeActorCodeCid := types.NewCidForTestGetter()()
+	builtin.Actors[fakeActorCodeCid] = &actor.FakeActor{}
+	defer func() {
+		delete(builtin.Actors, fakeActorCodeCid)
+	}()
+
+	newAddress := address.NewForTestGetter()
+	failing, succeeding, minerOwner := newAddress(), newAddress(), newAddress()
+	failingAct := th.RequireNewFakeActor(t, vms, failing, fakeActorCodeCid)
+	succeedingAct := th.RequireNewFakeActor(t, vms, succeeding, fakeActorCodeCid)
+
+	cronAct := cron.NewActor()
+	cronStorage := vms.NewStorage(address.CronAddress, cronAct)
+	require.NoError(t, (&cron.Actor{}).InitializeState(cronStorage, []cron.Entry{
+		{Actor: failing, Method: "cronRevertError"},
+		{Actor: succeeding, Method: "cronGoodCall"},
+	}))
+	require.NoError(t, cronStorage.Flush())
+
+	_, st := requireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+		address.CronAddress:    cronAct,
+		failing:                failingAct,
+		succeeding:             succeedingAct,
+	})
+
+	res, err := NewDefaultProcessor().ApplyMessagesAndPayRewards(ctx, st, vms, nil, minerOwner, types.NewBlockHeight(1), nil)
+	require.NoError(t, err)
+	require.Len(t, res.CronFailures, 1)
+	assert.Equal(t, failing, res.CronFailures[0].Entry.Actor)
+	assert.Error(t, res.CronFailures[0].Err)
+
+	changed := func(addr address.Address) bool {
+		act := state.MustGetActor(st, addr)
+		chunk, err := vms.NewStorage(addr, act).Get(act.Head)
+		require.NoError(t, err)
+		var fakeState actor.FakeActorStorage
+		require.NoError(t, actor.UnmarshalStorage(chunk, &fakeState))
+		return fakeState.Changed
+	}
+	// the write of the failing entry is discarded, the next entry still runs
+	assert.False(t, changed(failing))
+	assert.True(t, changed(succeeding))
+}
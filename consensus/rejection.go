@@ -0,0 +1,110 @@
+package consensus
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// RejectCode identifies why a message was refused by the message pool or by
+// pubsub validation, so that submitters can tell failures apart without
+// parsing error messages.
+type RejectCode string
+
+const (
+	// RejectInvalidSignature is the code of messages not signed by their
+	// sender.
+	RejectInvalidSignature = RejectCode("invalid-signature")
+	// RejectSelfSend is the code of messages sent to their sender.
+	RejectSelfSend = RejectCode("self-send")
+	// RejectNonAccountSender is the code of messages from actors other than
+	// accounts.
+	RejectNonAccountSender = RejectCode("non-account-sender")
+	// RejectNegativeValue is the code of messages transferring a negative
+	// value.
+	RejectNegativeValue = RejectCode("negative-value")
+	// RejectGasPriceTooLow is the code of messages paying less than the
+	// minimum gas price.
+	RejectGasPriceTooLow = RejectCode("gas-price-too-low")
+	// RejectGasLimitTooLow is the code of messages with a gas limit below the
+	// minimum.
+	RejectGasLimitTooLow = RejectCode("gas-limit-too-low")
+	// RejectGasLimitTooHigh is the code of messages with a gas limit above
+	// the block gas limit.
+	RejectGasLimitTooHigh = RejectCode("gas-limit-too-high")
+	// RejectInsufficientFunds is the code of messages whose sender cannot pay
+	// for their value and gas, along with its other pending messages.
+	RejectInsufficientFunds = RejectCode("insufficient-funds")
+	// RejectNonceTooLow is the code of messages with a nonce already used.
+	RejectNonceTooLow = RejectCode("nonce-too-low")
+	// RejectNonceTooHigh is the code of messages with a nonce too far past
+	// their sender's.
+	RejectNonceTooHigh = RejectCode("nonce-too-high")
+	// RejectTooLarge is the code of messages too large to be included in a
+	// block.
+	RejectTooLarge = RejectCode("too-large")
+	// RejectDuplicateNonce is the code of messages with the sender and nonce
+	// of a pending message they don't pay enough to replace.
+	RejectDuplicateNonce = RejectCode("duplicate-nonce")
+	// RejectPoolFull is the code of messages refused by a full message pool.
+	RejectPoolFull = RejectCode("pool-full")
+	// RejectSenderLimit is the code of messages from senders with the
+	// maximum number of pending messages.
+	RejectSenderLimit = RejectCode("sender-limit")
+)
+
+// MessageRejection is the error of a message refused by the message pool or
+// by pubsub validation.
+type MessageRejection struct {
+	Code RejectCode
+	err  error
+}
+
+// Error returns the reason of the rejection followed by its code.
+func (r *MessageRejection) Error() string {
+	return fmt.Sprintf("%s [%s]", r.err, r.Code)
+}
+
+// NewMessageRejection returns a rejection with code and an error formatted
+// according to format.
+func NewMessageRejection(code RejectCode, format string, args ...interface{}) error {
+	return &MessageRejection{Code: code, err: errors.Errorf(format, args...)}
+}
+
+// AsRejection returns err, an error of a SignedMessageValidator, as a
+// MessageRejection with the code of the problem found.  Other errors, e.g.
+// failures to read state, are returned unchanged.
+func AsRejection(err error) error {
+	var code RejectCode
+	switch err {
+	case errInvalidSignature:
+		code = RejectInvalidSignature
+	case errSelfSend:
+		code = RejectSelfSend
+	case errGasPriceZero:
+		code = RejectGasPriceTooLow
+	case errNonAccountActor:
+		code = RejectNonAccountSender
+	case errNegativeValue:
+		code = RejectNegativeValue
+	case errGasAboveBlockLimit:
+		code = RejectGasLimitTooHigh
+	case errInsufficientGas:
+		code = RejectInsufficientFunds
+	case errNonceTooLow:
+		code = RejectNonceTooLow
+	case errNonceTooHigh:
+		code = RejectNonceTooHigh
+	default:
+		return err
+	}
+	return &MessageRejection{Code: code, err: err}
+}
+
+// RejectCodeOf returns the code of the rejection err is or wraps, if any.
+func RejectCodeOf(err error) (RejectCode, bool) {
+	if r, ok := errors.Cause(err).(*MessageRejection); ok {
+		return r.Code, true
+	}
+	return "", false
+}
@@ -0,0 +1,429 @@
+package consensus
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	"go.opencensus.io/trace"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/metrics/tracing"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+// ActorStateChange records the state of an actor before and after a message
+// was applied.  Before is nil if the actor did not exist.
+type ActorStateChange struct {
+	Address address.Address `json:"address"`
+	Before  *actor.Actor    `json:"before"`
+	After   *actor.Actor    `json:"after"`
+}
+
+// MessageReplay is the result of re-executing a single message against the
+// state it was originally applied to.
+type MessageReplay struct {
+	Message *types.SignedMessage  `json:"message"`
+	Receipt *types.MessageReceipt `json:"receipt"`
+	// ApplyError is set if the message could not be applied, in which case
+	// Receipt is nil.
+	ApplyError string `json:"applyError,omitempty"`
+	// ExecutionError is set if the message was applied but its execution
+	// failed and its changes were reverted.
+	ExecutionError string `json:"executionError,omitempty"`
+
+	GasLimit types.GasUnits `json:"gasLimit"`
+	GasPrice *types.AttoFIL `json:"gasPrice"`
+	GasUsed  types.GasUnits `json:"gasUsed"`
+	GasCost  *types.AttoFIL `json:"gasCost"`
+
+	// Trace is the tree of sends made while executing the message.  It is
+	// nil if the message was rejected before reaching the VM.
+	Trace *vm.SendTrace `json:"trace"`
+	// StateChanges lists the actors whose state was changed by the message,
+	// in the order they were first accessed.
+	StateChanges []*ActorStateChange `json:"stateChanges"`
+}
+
+// ReplayMessage re-executes the message with cid msgCid from tipset ts
+// against st, the state of ts's parent.  The blocks and messages of ts
+// preceding the message are applied exactly as ProcessTipSet would apply them
+// so that the message sees the same state it saw originally.  The target
+// message is then applied with tracing enabled and the resulting gas usage,
+// sends and actor state changes are returned.  st is mutated; callers must
+// not flush it if they wish to preserve the original state.
+func (p *DefaultProcessor) ReplayMessage(ctx context.Context, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet, msgCid cid.Cid) (replay *MessageReplay, err error) {
+	ctx, span := trace.StartSpan(ctx, "DefaultProcessor.ReplayMessage")
+	span.AddAttributes(trace.StringAttribute("message", msgCid.String()))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	h, err := ts.Height()
+	if err != nil {
+		return nil, errors.FaultErrorWrap(err, "replaying message in empty tipset")
+	}
+	bh := types.NewBlockHeight(h)
+	msgFilter := make(map[string]struct{})
+
+	if err := migrateForks(ctx, st, vms, bh, ancestors); err != nil {
+		return nil, err
+	}
+
+	tips := ts.ToSlice()
+	types.SortBlocks(tips)
+
+	for _, blk := range tips {
+		minerOwnerAddr, err := minerOwnerAddress(ctx, st, vms, blk.Miner)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.blockRewarder.BlockReward(ctx, st, vms, minerOwnerAddr); err != nil {
+			return nil, err
+		}
+		gasTracker := vm.NewGasTracker()
+
+		for _, msg := range blk.Messages {
+			mCid, err := msg.Cid()
+			if err != nil {
+				return nil, errors.FaultErrorWrap(err, "error getting message cid")
+			}
+			if _, ok := msgFilter[mCid.String()]; ok {
+				continue
+			}
+			msgFilter[mCid.String()] = struct{}{}
+
+			if mCid.Equals(msgCid) {
+				return p.replayTarget(ctx, st, vms, msg, minerOwnerAddr, bh, gasTracker, ancestors)
+			}
+
+			// As in ApplyMessagesAndPayRewards only faults abort processing.
+			if _, err := p.ApplyMessage(ctx, st, vms, msg, minerOwnerAddr, bh, gasTracker, ancestors); errors.IsFault(err) {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, errors.NewFaultErrorf("message %s not found in tipset %s", msgCid, ts.String())
+}
+
+// TipSetReplay is the result of re-executing a whole tipset against the state
+// of its parent.
+type TipSetReplay struct {
+	TipSet          types.SortedCidSet `json:"tipSet"`
+	Height          uint64             `json:"height"`
+	ParentStateRoot cid.Cid            `json:"parentStateRoot"`
+	// Messages holds the replay of each message of the tipset in the order it
+	// was applied.  Messages included by more than one block appear once.
+	Messages []*MessageReplay `json:"messages"`
+	// GasUsed is the total gas used by the messages of the tipset.
+	GasUsed types.GasUnits `json:"gasUsed"`
+	// StateRoot is the state root computed by the replay.
+	StateRoot cid.Cid `json:"stateRoot"`
+	// RecordedStateRoot is the state root stored for the tipset, if any.
+	RecordedStateRoot cid.Cid `json:"recordedStateRoot"`
+	// Diff lists the actors whose recorded state differs from the computed
+	// state.  It is empty when the state roots match.
+	Diff []*StateRootDiff `json:"diff"`
+}
+
+// Matches returns true if the replay computed the recorded state root.
+func (r *TipSetReplay) Matches() bool {
+	return r.RecordedStateRoot.Defined() && r.StateRoot.Equals(r.RecordedStateRoot)
+}
+
+// StateRootDiff records an actor whose state differs between the recorded and
+// the computed state of a tipset.  Recorded or Computed is nil if the actor is
+// absent from that state.
+type StateRootDiff struct {
+	Address  address.Address `json:"address"`
+	Recorded *actor.Actor    `json:"recorded"`
+	Computed *actor.Actor    `json:"computed"`
+}
+
+// ReplayTipSet re-executes every message of ts against st, the state of ts's
+// parent, exactly as ProcessTipSet would apply them, and reports the receipt,
+// gas usage, sends and actor state changes of each message along with the
+// resulting state root.  st is mutated and flushed to the cbor store backing
+// it so that the computed state can be inspected afterwards; vms is not
+// flushed.
+func (p *DefaultProcessor) ReplayTipSet(ctx context.Context, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (replay *TipSetReplay, err error) {
+	ctx, span := trace.StartSpan(ctx, "DefaultProcessor.ReplayTipSet")
+	span.AddAttributes(trace.StringAttribute("tipset", ts.String()))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	h, err := ts.Height()
+	if err != nil {
+		return nil, errors.FaultErrorWrap(err, "replaying empty tipset")
+	}
+	bh := types.NewBlockHeight(h)
+	parentRoot, err := st.Flush(ctx)
+	if err != nil {
+		return nil, errors.FaultErrorWrap(err, "failed to flush parent state")
+	}
+	replay = &TipSetReplay{
+		TipSet:          ts.ToSortedCidSet(),
+		Height:          h,
+		ParentStateRoot: parentRoot,
+	}
+	msgFilter := make(map[string]struct{})
+
+	if err := migrateForks(ctx, st, vms, bh, ancestors); err != nil {
+		return nil, err
+	}
+
+	tips := ts.ToSlice()
+	types.SortBlocks(tips)
+
+	for _, blk := range tips {
+		minerOwnerAddr, err := minerOwnerAddress(ctx, st, vms, blk.Miner)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.blockRewarder.BlockReward(ctx, st, vms, minerOwnerAddr); err != nil {
+			return nil, err
+		}
+		gasTracker := vm.NewGasTracker()
+
+		for _, msg := range blk.Messages {
+			mCid, err := msg.Cid()
+			if err != nil {
+				return nil, errors.FaultErrorWrap(err, "error getting message cid")
+			}
+			if _, ok := msgFilter[mCid.String()]; ok {
+				continue
+			}
+			msgFilter[mCid.String()] = struct{}{}
+
+			res, err := p.replayTarget(ctx, st, vms, msg, minerOwnerAddr, bh, gasTracker, ancestors)
+			if err != nil {
+				return nil, err
+			}
+			replay.Messages = append(replay.Messages, res)
+			replay.GasUsed += res.GasUsed
+		}
+	}
+
+	if _, err := runCron(ctx, st, vms, bh, ancestors); err != nil {
+		return nil, err
+	}
+
+	replay.StateRoot, err = st.Flush(ctx)
+	if err != nil {
+		return nil, errors.FaultErrorWrap(err, "failed to flush replayed state")
+	}
+	return replay, nil
+}
+
+// DiffStates returns the actors whose state differs between recorded and
+// computed, ordered by address.
+func DiffStates(ctx context.Context, recorded, computed state.Tree) ([]*StateRootDiff, error) {
+	recordedActors, err := collectActors(ctx, recorded)
+	if err != nil {
+		return nil, err
+	}
+	computedActors, err := collectActors(ctx, computed)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []address.Address
+	for a := range recordedActors {
+		addrs = append(addrs, a)
+	}
+	for a := range computedActors {
+		if _, ok := recordedActors[a]; !ok {
+			addrs = append(addrs, a)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].String() < addrs[j].String()
+	})
+
+	var diff []*StateRootDiff
+	for _, a := range addrs {
+		changed, err := actorChanged(recordedActors[a], computedActors[a])
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			diff = append(diff, &StateRootDiff{Address: a, Recorded: recordedActors[a], Computed: computedActors[a]})
+		}
+	}
+	return diff, nil
+}
+
+func collectActors(ctx context.Context, st state.Tree) (map[address.Address]*actor.Actor, error) {
+	actors := make(map[address.Address]*actor.Actor)
+	err := st.ForEachActor(ctx, func(a address.Address, act *actor.Actor) error {
+		cpy := *act
+		actors[a] = &cpy
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return actors, nil
+}
+
+// ComputeMessages applies messages to st in order, as the messages of a block
+// mined by minerOwnerAddr at height bh, and reports for each its receipt,
+// sends and actor state changes.  Messages that fail to apply are reported
+// with their ApplyError and do not stop the computation.  No block reward is
+// paid.  st is mutated; callers must not flush it if they wish to preserve
+// the original state.
+func (p *DefaultProcessor) ComputeMessages(ctx context.Context, st state.Tree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []types.TipSet) (results []*MessageReplay, err error) {
+	ctx, span := trace.StartSpan(ctx, "DefaultProcessor.ComputeMessages")
+	span.AddAttributes(trace.Int64Attribute("messages", int64(len(messages))))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	if err := migrateForks(ctx, st, vms, bh, ancestors); err != nil {
+		return nil, err
+	}
+
+	gasTracker := vm.NewGasTracker()
+	for _, msg := range messages {
+		res, err := p.replayTarget(ctx, st, vms, msg, minerOwnerAddr, bh, gasTracker, ancestors)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// replayTarget applies msg with tracing and state change recording enabled.
+func (p *DefaultProcessor) replayTarget(ctx context.Context, st state.Tree, vms vm.StorageMap, msg *types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, gasTracker *vm.GasTracker, ancestors []types.TipSet) (*MessageReplay, error) {
+	tracer := vm.NewTracer()
+	rt := newRecordingTree(st)
+
+	res, err := p.ApplyMessage(vm.WithTracer(ctx, tracer), rt, vms, msg, minerOwnerAddr, bh, gasTracker, ancestors)
+	if errors.IsFault(err) {
+		return nil, err
+	}
+
+	replay := &MessageReplay{
+		Message:  msg,
+		GasLimit: msg.GasLimit,
+		GasPrice: &msg.GasPrice,
+		GasCost:  types.ZeroAttoFIL,
+		Trace:    tracer.Root(),
+	}
+	if err != nil {
+		replay.ApplyError = err.Error()
+	} else {
+		replay.Receipt = res.Receipt
+		replay.GasCost = res.Receipt.GasAttoFIL
+		if res.ExecutionError != nil {
+			replay.ExecutionError = res.ExecutionError.Error()
+		}
+	}
+	if replay.Trace != nil {
+		replay.GasUsed = replay.Trace.GasUsed
+	}
+
+	replay.StateChanges, err = rt.changes(ctx)
+	if err != nil {
+		return nil, errors.FaultErrorWrap(err, "failed to compute state changes")
+	}
+	return replay, nil
+}
+
+// recordingTree is a state.Tree that remembers the state of every actor
+// before it was first accessed so that changes can be reported afterwards.
+type recordingTree struct {
+	state.Tree
+	before map[address.Address]*actor.Actor
+	order  []address.Address
+}
+
+var _ state.Tree = (*recordingTree)(nil)
+
+func newRecordingTree(st state.Tree) *recordingTree {
+	return &recordingTree{
+		Tree:   st,
+		before: make(map[address.Address]*actor.Actor),
+	}
+}
+
+// record captures the current state of the actor at a if it has not been
+// captured yet.
+func (rt *recordingTree) record(ctx context.Context, a address.Address) error {
+	if _, ok := rt.before[a]; ok {
+		return nil
+	}
+	act, err := rt.Tree.GetActor(ctx, a)
+	if err != nil && !state.IsActorNotFoundError(err) {
+		return err
+	}
+	if act != nil {
+		// Copy so later mutations by the caller don't affect the record.
+		cpy := *act
+		act = &cpy
+	}
+	rt.before[a] = act
+	rt.order = append(rt.order, a)
+	return nil
+}
+
+func (rt *recordingTree) GetActor(ctx context.Context, a address.Address) (*actor.Actor, error) {
+	if err := rt.record(ctx, a); err != nil {
+		return nil, err
+	}
+	return rt.Tree.GetActor(ctx, a)
+}
+
+func (rt *recordingTree) GetOrCreateActor(ctx context.Context, a address.Address, c func() (*actor.Actor, error)) (*actor.Actor, error) {
+	if err := rt.record(ctx, a); err != nil {
+		return nil, err
+	}
+	return rt.Tree.GetOrCreateActor(ctx, a, c)
+}
+
+func (rt *recordingTree) SetActor(ctx context.Context, a address.Address, act *actor.Actor) error {
+	if err := rt.record(ctx, a); err != nil {
+		return err
+	}
+	return rt.Tree.SetActor(ctx, a, act)
+}
+
+// changes returns the recorded actors whose state differs from the current
+// state of the underlying tree.
+func (rt *recordingTree) changes(ctx context.Context) ([]*ActorStateChange, error) {
+	var changes []*ActorStateChange
+	for _, a := range rt.order {
+		before := rt.before[a]
+		after, err := rt.Tree.GetActor(ctx, a)
+		if err != nil && !state.IsActorNotFoundError(err) {
+			return nil, err
+		}
+
+		changed, err := actorChanged(before, after)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, &ActorStateChange{Address: a, Before: before, After: after})
+		}
+	}
+	return changes, nil
+}
+
+func actorChanged(before, after *actor.Actor) (bool, error) {
+	if before == nil || after == nil {
+		return before != after, nil
+	}
+	beforeCid, err := before.Cid()
+	if err != nil {
+		return false, err
+	}
+	afterCid, err := after.Cid()
+	if err != nil {
+		return false, err
+	}
+	return !beforeCid.Equals(afterCid), nil
+}
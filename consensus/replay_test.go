@@ -0,0 +1,264 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
+	. "github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestReplayMessage(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	vms := th.VMStorage()
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(2)
+
+	// Install the fake actor so we can execute it.
+	fakeActorCodeCid := types.NewCidForTestGetter()()
+	builtin.Actors[fakeActorCodeCid] = &actor.FakeActor{}
+	defer func() {
+		delete(builtin.Actors, fakeActorCodeCid)
+	}()
+
+	minerAddr, toAddr, fakeAddr1, fakeAddr2 := newAddress(), newAddress(), newAddress(), newAddress()
+	fromAddr1, fromAddr2 := mockSigner.Addresses[0], mockSigner.Addresses[1]
+
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(1000000)),
+		fromAddr1:              th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+		fromAddr2:              th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+		fakeAddr1:              th.RequireNewFakeActorWithTokens(t, vms, fakeAddr1, fakeActorCodeCid, types.NewAttoFILFromFIL(102)),
+		fakeAddr2:              th.RequireNewFakeActorWithTokens(t, vms, fakeAddr2, fakeActorCodeCid, types.NewAttoFILFromFIL(0)),
+	})
+	minerOwner, err := address.NewActorAddress([]byte("mo"))
+	require.NoError(t, err)
+	stCid, _ := mustCreateMiner(ctx, t, st, vms, minerAddr, minerOwner)
+
+	msg1 := types.NewMessage(fromAddr1, toAddr, 0, types.NewAttoFILFromFIL(550), "", nil)
+	smsg1, err := types.NewSignedMessage(*msg1, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+
+	// Sends 100 from fakeAddr1 to fakeAddr2 on behalf of fromAddr2.
+	params, err := abi.ToEncodedValues(fakeAddr2)
+	require.NoError(t, err)
+	msg2 := types.NewMessage(fromAddr2, fakeAddr1, 0, types.ZeroAttoFIL, "nestedBalance", params)
+	smsg2, err := types.NewSignedMessage(*msg2, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(1000))
+	require.NoError(t, err)
+
+	blk := &types.Block{
+		Height:    20,
+		StateRoot: stCid,
+		Messages:  []*types.SignedMessage{smsg1, smsg2},
+		Miner:     minerAddr,
+	}
+	ts := th.RequireNewTipSet(t, blk)
+
+	t.Run("replays a message with its sends and state changes", func(t *testing.T) {
+		st, err := state.LoadStateTree(ctx, cst, stCid, builtin.Actors)
+		require.NoError(t, err)
+
+		msgCid, err := smsg2.Cid()
+		require.NoError(t, err)
+		replay, err := NewDefaultProcessor().ReplayMessage(ctx, st, vms, ts, nil, msgCid)
+		require.NoError(t, err)
+
+		assert.Empty(t, replay.ApplyError)
+		assert.Empty(t, replay.ExecutionError)
+		require.NotNil(t, replay.Receipt)
+		assert.Equal(t, uint8(0), replay.Receipt.ExitCode)
+		assert.Equal(t, types.NewGasUnits(1000), replay.GasLimit)
+
+		require.NotNil(t, replay.Trace)
+		assert.Equal(t, fromAddr2, replay.Trace.From)
+		assert.Equal(t, fakeAddr1, replay.Trace.To)
+		assert.Equal(t, "nestedBalance", replay.Trace.Method)
+		require.Len(t, replay.Trace.Subcalls, 1)
+		assert.Equal(t, fakeAddr1, replay.Trace.Subcalls[0].From)
+		assert.Equal(t, fakeAddr2, replay.Trace.Subcalls[0].To)
+		assert.Equal(t, types.NewAttoFILFromFIL(100), replay.Trace.Subcalls[0].Value)
+		assert.Equal(t, replay.GasUsed, replay.Trace.GasUsed)
+
+		changes := make(map[address.Address]*ActorStateChange)
+		for _, change := range replay.StateChanges {
+			changes[change.Address] = change
+		}
+		require.Contains(t, changes, fakeAddr1)
+		assert.Equal(t, types.NewAttoFILFromFIL(102), changes[fakeAddr1].Before.Balance)
+		assert.Equal(t, types.NewAttoFILFromFIL(2), changes[fakeAddr1].After.Balance)
+		require.Contains(t, changes, fakeAddr2)
+		assert.Equal(t, types.NewAttoFILFromFIL(100), changes[fakeAddr2].After.Balance)
+		require.Contains(t, changes, fromAddr2)
+		assert.Equal(t, types.Uint64(1), changes[fromAddr2].After.Nonce)
+		// The earlier message was applied but is not part of the replay.
+		assert.NotContains(t, changes, fromAddr1)
+		assert.NotContains(t, changes, toAddr)
+	})
+
+	t.Run("replays a plain transfer", func(t *testing.T) {
+		st, err := state.LoadStateTree(ctx, cst, stCid, builtin.Actors)
+		require.NoError(t, err)
+
+		msgCid, err := smsg1.Cid()
+		require.NoError(t, err)
+		replay, err := NewDefaultProcessor().ReplayMessage(ctx, st, vms, ts, nil, msgCid)
+		require.NoError(t, err)
+
+		require.NotNil(t, replay.Trace)
+		assert.Empty(t, replay.Trace.Subcalls)
+		changes := make(map[address.Address]*ActorStateChange)
+		for _, change := range replay.StateChanges {
+			changes[change.Address] = change
+		}
+		require.Contains(t, changes, toAddr)
+		assert.Nil(t, changes[toAddr].Before)
+		assert.Equal(t, types.NewAttoFILFromFIL(550), changes[toAddr].After.Balance)
+	})
+
+	t.Run("message not in tipset", func(t *testing.T) {
+		st, err := state.LoadStateTree(ctx, cst, stCid, builtin.Actors)
+		require.NoError(t, err)
+
+		_, err = NewDefaultProcessor().ReplayMessage(ctx, st, vms, ts, nil, types.NewCidForTestGetter()())
+		assert.Error(t, err)
+	})
+}
+
+func TestComputeMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	vms := th.VMStorage()
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
+
+	fromAddr, toAddr := mockSigner.Addresses[0], newAddress()
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(1000000)),
+		fromAddr:               th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+	})
+
+	msg1 := types.NewMessage(fromAddr, toAddr, 0, types.NewAttoFILFromFIL(550), "", nil)
+	smsg1, err := types.NewSignedMessage(*msg1, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	// Reuses the nonce of the first message.
+	msg2 := types.NewMessage(fromAddr, toAddr, 0, types.NewAttoFILFromFIL(10), "", nil)
+	smsg2, err := types.NewSignedMessage(*msg2, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	msg3 := types.NewMessage(fromAddr, toAddr, 1, types.NewAttoFILFromFIL(50), "", nil)
+	smsg3, err := types.NewSignedMessage(*msg3, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+
+	results, err := NewDefaultProcessor().ComputeMessages(ctx, st, vms, []*types.SignedMessage{smsg1, smsg2, smsg3}, address.NetworkAddress, types.NewBlockHeight(1), nil)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	require.NotNil(t, results[0].Receipt)
+	assert.Equal(t, uint8(0), results[0].Receipt.ExitCode)
+	assert.Nil(t, results[1].Receipt)
+	assert.NotEmpty(t, results[1].ApplyError)
+	require.NotNil(t, results[2].Receipt)
+	assert.Equal(t, uint8(0), results[2].Receipt.ExitCode)
+
+	to, err := st.GetActor(ctx, toAddr)
+	require.NoError(t, err)
+	assert.Equal(t, types.NewAttoFILFromFIL(600), to.Balance)
+}
+
+func TestReplayTipSet(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	vms := th.VMStorage()
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(2)
+
+	minerAddr, toAddr := newAddress(), newAddress()
+	fromAddr1, fromAddr2 := mockSigner.Addresses[0], mockSigner.Addresses[1]
+
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(1000000)),
+		fromAddr1:              th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+		fromAddr2:              th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+	})
+	minerOwner, err := address.NewActorAddress([]byte("mo"))
+	require.NoError(t, err)
+	stCid, _ := mustCreateMiner(ctx, t, st, vms, minerAddr, minerOwner)
+
+	msg1 := types.NewMessage(fromAddr1, toAddr, 0, types.NewAttoFILFromFIL(550), "", nil)
+	smsg1, err := types.NewSignedMessage(*msg1, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	msg2 := types.NewMessage(fromAddr2, toAddr, 0, types.NewAttoFILFromFIL(50), "", nil)
+	smsg2, err := types.NewSignedMessage(*msg2, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+
+	blk1 := &types.Block{Height: 20, StateRoot: stCid, Messages: []*types.SignedMessage{smsg1}, Miner: minerAddr}
+	blk2 := &types.Block{Height: 20, StateRoot: stCid, Messages: []*types.SignedMessage{smsg1, smsg2}, Miner: minerAddr, Nonce: 1}
+	ts := th.RequireNewTipSet(t, blk1, blk2)
+
+	st, err = state.LoadStateTree(ctx, cst, stCid, builtin.Actors)
+	require.NoError(t, err)
+	_, err = NewDefaultProcessor().ProcessTipSet(ctx, st, vms, ts, nil)
+	require.NoError(t, err)
+	recordedRoot, err := st.Flush(ctx)
+	require.NoError(t, err)
+
+	st, err = state.LoadStateTree(ctx, cst, stCid, builtin.Actors)
+	require.NoError(t, err)
+	replay, err := NewDefaultProcessor().ReplayTipSet(ctx, st, vms, ts, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(20), replay.Height)
+	assert.Equal(t, stCid, replay.ParentStateRoot)
+	// The message included by both blocks is replayed once.
+	require.Len(t, replay.Messages, 2)
+	for _, m := range replay.Messages {
+		require.NotNil(t, m.Receipt)
+		assert.Equal(t, uint8(0), m.Receipt.ExitCode)
+	}
+	assert.Equal(t, recordedRoot, replay.StateRoot)
+
+	replay.RecordedStateRoot = recordedRoot
+	assert.True(t, replay.Matches())
+
+	t.Run("diffs the computed state against the recorded one", func(t *testing.T) {
+		recorded, err := state.LoadStateTree(ctx, cst, recordedRoot, builtin.Actors)
+		require.NoError(t, err)
+		computed, err := state.LoadStateTree(ctx, cst, replay.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+
+		diff, err := DiffStates(ctx, recorded, computed)
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+
+		to, err := computed.GetActor(ctx, toAddr)
+		require.NoError(t, err)
+		to.Balance = types.NewAttoFILFromFIL(1)
+		require.NoError(t, computed.SetActor(ctx, toAddr, to))
+		_, err = computed.Flush(ctx)
+		require.NoError(t, err)
+
+		diff, err = DiffStates(ctx, recorded, computed)
+		require.NoError(t, err)
+		require.Len(t, diff, 1)
+		assert.Equal(t, toAddr, diff[0].Address)
+		assert.Equal(t, types.NewAttoFILFromFIL(600), diff[0].Recorded.Balance)
+		assert.Equal(t, types.NewAttoFILFromFIL(1), diff[0].Computed.Balance)
+	})
+}
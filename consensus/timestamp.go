@@ -0,0 +1,89 @@
+package consensus
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// DefaultMaxFutureDrift is how far ahead of the local clock the timestamp of
+// a block can be before the block is rejected.
+const DefaultMaxFutureDrift = 10 * time.Second
+
+var futureBlockCt = metrics.NewInt64Counter("consensus/future_block", "Number of blocks rejected for a timestamp too far in the future")
+
+// ValidateFutureDrift returns an error if timestamp, the unix time of block
+// blk, is more than maxDrift ahead of now.  The rejection is logged as a
+// warning since it is either a miner's or this node's clock that is off.
+func ValidateFutureDrift(ctx context.Context, blk cid.Cid, timestamp uint64, now time.Time, maxDrift time.Duration) error {
+	ahead := time.Unix(int64(timestamp), 0).Sub(now)
+	if ahead <= maxDrift {
+		return nil
+	}
+	futureBlockCt.Inc(ctx, 1)
+	log.Warningf("rejecting block %s timestamped %s ahead of the local clock, the clock of its miner or of this node is wrong", blk, ahead)
+	return errors.Errorf("block %s is timestamped %s in the future, more than the %s allowed", blk, ahead, maxDrift)
+}
+
+// ValidateTimestampOrder returns an error if blk is timestamped before any of
+// the blocks of parent: timestamps can't decrease with height.  Timestamps
+// are in seconds and blocks can be mined faster on test networks, so a block
+// can have its parent's timestamp.  Blocks without a timestamp, mined before
+// blocks carried one, are not checked.
+func ValidateTimestampOrder(parent types.TipSet, blk *types.Block) error {
+	if blk.Timestamp == 0 {
+		return nil
+	}
+	for _, p := range parent.ToSlice() {
+		if p.Timestamp != 0 && blk.Timestamp < p.Timestamp {
+			return errors.Errorf("block %s timestamped %d is before its parent %s timestamped %d", blk.Cid(), blk.Timestamp, p.Cid(), p.Timestamp)
+		}
+	}
+	return nil
+}
+
+// ValidateEpochTimestamp returns an error if blk is timestamped more than
+// maxDrift before the start of the epoch of its height: genesisTime plus
+// height block times.  Heights fall behind this schedule when rounds take
+// longer than the block time, but can't get ahead of it.  Blocks are not
+// checked if they or the genesis block are not timestamped.
+func ValidateEpochTimestamp(genesisTime uint64, blockTime, maxDrift time.Duration, blk *types.Block) error {
+	if genesisTime == 0 || blk.Timestamp == 0 {
+		return nil
+	}
+	epochStart := time.Unix(int64(genesisTime), 0).Add(time.Duration(blk.Height) * blockTime)
+	if time.Unix(int64(blk.Timestamp), 0).Add(maxDrift).Before(epochStart) {
+		return errors.Errorf("block %s at height %d is timestamped %d, before its epoch starts at %d", blk.Cid(), blk.Height, blk.Timestamp, epochStart.Unix())
+	}
+	return nil
+}
+
+// TimestampRules are the bounds on the timestamp of a block set by the local
+// clock and the schedule of epochs.
+type TimestampRules struct {
+	// GenesisTime is the timestamp of the genesis block, zero if it has
+	// none.
+	GenesisTime uint64
+	// BlockTime is the duration of an epoch.
+	BlockTime time.Duration
+	// MaxFutureDrift is how far ahead of the local clock, or of the start
+	// of its epoch, a block can be timestamped.
+	MaxFutureDrift time.Duration
+}
+
+// ValidateEpoch returns an error if blk is timestamped before the epoch of
+// its height.  Such a block is invalid whenever it is checked.
+func (r *TimestampRules) ValidateEpoch(blk *types.Block) error {
+	return ValidateEpochTimestamp(r.GenesisTime, r.BlockTime, r.MaxFutureDrift, blk)
+}
+
+// ValidateFutureDrift returns an error if blk is timestamped too far ahead
+// of now.  Such a block can become valid as time passes.
+func (r *TimestampRules) ValidateFutureDrift(ctx context.Context, blk *types.Block, now time.Time) error {
+	return ValidateFutureDrift(ctx, blk.Cid(), uint64(blk.Timestamp), now, r.MaxFutureDrift)
+}
@@ -0,0 +1,78 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/consensus"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestValidateFutureDrift(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	now := time.Unix(1000000, 0)
+	blk := types.SomeCid()
+
+	assert.NoError(t, consensus.ValidateFutureDrift(ctx, blk, 999990, now, 10*time.Second))
+	assert.NoError(t, consensus.ValidateFutureDrift(ctx, blk, 1000010, now, 10*time.Second))
+	assert.Error(t, consensus.ValidateFutureDrift(ctx, blk, 1000011, now, 10*time.Second))
+}
+
+func TestValidateTimestampOrder(t *testing.T) {
+	tf.UnitTest(t)
+
+	parent1 := &types.Block{Height: 1, Timestamp: 100}
+	parent2 := &types.Block{Height: 1, Timestamp: 110, Nonce: 1}
+	parent := th.RequireNewTipSet(t, parent1, parent2)
+
+	assert.NoError(t, consensus.ValidateTimestampOrder(parent, &types.Block{Height: 2, Timestamp: 111}))
+	assert.NoError(t, consensus.ValidateTimestampOrder(parent, &types.Block{Height: 2, Timestamp: 110}))
+	assert.Error(t, consensus.ValidateTimestampOrder(parent, &types.Block{Height: 2, Timestamp: 109}))
+	assert.Error(t, consensus.ValidateTimestampOrder(parent, &types.Block{Height: 2, Timestamp: 105}))
+
+	// Blocks mined before timestamps are not checked, nor are their children.
+	assert.NoError(t, consensus.ValidateTimestampOrder(parent, &types.Block{Height: 2}))
+	untimed := th.RequireNewTipSet(t, &types.Block{Height: 1})
+	assert.NoError(t, consensus.ValidateTimestampOrder(untimed, &types.Block{Height: 2, Timestamp: 1}))
+}
+
+func TestValidateEpochTimestamp(t *testing.T) {
+	tf.UnitTest(t)
+
+	genesisTime := uint64(1000)
+	blockTime := 30 * time.Second
+
+	// Height 10 starts at 1300, and with 10s of drift can be timestamped
+	// from 1290.
+	assert.NoError(t, consensus.ValidateEpochTimestamp(genesisTime, blockTime, 10*time.Second, &types.Block{Height: 10, Timestamp: 1300}))
+	assert.NoError(t, consensus.ValidateEpochTimestamp(genesisTime, blockTime, 10*time.Second, &types.Block{Height: 10, Timestamp: 1290}))
+	assert.Error(t, consensus.ValidateEpochTimestamp(genesisTime, blockTime, 10*time.Second, &types.Block{Height: 10, Timestamp: 1289}))
+	// Heights can lag behind the schedule.
+	assert.NoError(t, consensus.ValidateEpochTimestamp(genesisTime, blockTime, 10*time.Second, &types.Block{Height: 10, Timestamp: 5000}))
+
+	// Blocks are not checked without timestamps.
+	assert.NoError(t, consensus.ValidateEpochTimestamp(0, blockTime, 10*time.Second, &types.Block{Height: 10, Timestamp: 1}))
+	assert.NoError(t, consensus.ValidateEpochTimestamp(genesisTime, blockTime, 10*time.Second, &types.Block{Height: 10}))
+}
+
+func TestTimestampRules(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	rules := &consensus.TimestampRules{GenesisTime: 1000, BlockTime: 30 * time.Second, MaxFutureDrift: 10 * time.Second}
+	now := time.Unix(2000, 0)
+
+	blk := &types.Block{Height: 10, Timestamp: 1300}
+	assert.NoError(t, rules.ValidateEpoch(blk))
+	assert.NoError(t, rules.ValidateFutureDrift(ctx, blk, now))
+
+	assert.Error(t, rules.ValidateFutureDrift(ctx, &types.Block{Height: 10, Timestamp: 2011}, now))
+	assert.Error(t, rules.ValidateEpoch(&types.Block{Height: 10, Timestamp: 1200}))
+}
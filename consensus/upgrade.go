@@ -0,0 +1,243 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// StateMigration transforms the state at the activation of an upgrade.
+type StateMigration func(ctx context.Context, st state.Tree, vms vm.StorageMap) error
+
+// Upgrade describes a future version of the protocol: the migration applied
+// to the state when it activates and the processor implementing its rules.
+type Upgrade struct {
+	// Name identifies the upgrade.
+	Name string
+	// Migrate is applied to the state before the first tipset processed under
+	// the new rules.  It may be nil if the upgrade does not change the state.
+	Migrate StateMigration
+	// Processor applies messages under the new rules.
+	Processor Processor
+}
+
+var upgrades = make(map[string]*Upgrade)
+
+// RegisterUpgrade makes an upgrade available for dry runs.  It is intended to
+// be called from init functions.
+func RegisterUpgrade(u *Upgrade) error {
+	if u.Name == "" || u.Processor == nil {
+		return errors.New("upgrade must have a name and a processor")
+	}
+	if _, ok := upgrades[u.Name]; ok {
+		return fmt.Errorf("upgrade %s already registered", u.Name)
+	}
+	upgrades[u.Name] = u
+	return nil
+}
+
+// LookupUpgrade returns the registered upgrade with the given name.
+func LookupUpgrade(name string) (*Upgrade, bool) {
+	u, ok := upgrades[name]
+	return u, ok
+}
+
+// UpgradeNames returns the names of all registered upgrades in sorted order.
+func UpgradeNames() []string {
+	var names []string
+	for name := range upgrades {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UpgradeDivergence describes a message whose outcome under the upgraded rules
+// differs from its outcome under the current rules.  A nil receipt means the
+// message failed to apply.
+type UpgradeDivergence struct {
+	Height   uint64                `json:"height"`
+	TipSet   types.SortedCidSet    `json:"tipSet"`
+	Message  cid.Cid               `json:"message"`
+	Current  *types.MessageReceipt `json:"current"`
+	Upgraded *types.MessageReceipt `json:"upgraded"`
+}
+
+// UpgradeDryRunReport is the result of rehearsing an upgrade against a range
+// of the chain.
+type UpgradeDryRunReport struct {
+	Upgrade  string `json:"upgrade"`
+	TipSets  int    `json:"tipSets"`
+	Messages int    `json:"messages"`
+
+	// MigrationTime is the time taken by the state migration.
+	MigrationTime time.Duration `json:"migrationTime"`
+	// CurrentTime and UpgradedTime are the total time taken to process the
+	// tipsets under the current and the upgraded rules.
+	CurrentTime  time.Duration `json:"currentTime"`
+	UpgradedTime time.Duration `json:"upgradedTime"`
+
+	Divergences []*UpgradeDivergence `json:"divergences"`
+}
+
+// AncestorsFunc returns the ancestors needed to process a tipset.
+type AncestorsFunc func(ts types.TipSet) ([]types.TipSet, error)
+
+// DryRunUpgrade processes tipsets, which must be consecutive and in ascending
+// order, twice starting from parentState, the state of the first tipset's
+// parent: once with current and once with the upgrade's processor after
+// applying its migration.  The outcome of every message is compared and each
+// difference is reported along with timings.  The state trees are never
+// flushed so the rehearsal leaves the node's chain and state untouched.
+func DryRunUpgrade(ctx context.Context, upgrade *Upgrade, current Processor, cst *hamt.CborIpldStore, bs blockstore.Blockstore, parentState cid.Cid, tipsets []types.TipSet, ancestors AncestorsFunc) (*UpgradeDryRunReport, error) {
+	currentSt, err := state.LoadStateTree(ctx, cst, parentState, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load parent state")
+	}
+	upgradedSt, err := state.LoadStateTree(ctx, cst, parentState, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load parent state")
+	}
+	currentVms := vm.NewStorageMap(bs)
+	upgradedVms := vm.NewStorageMap(bs)
+
+	report := &UpgradeDryRunReport{Upgrade: upgrade.Name}
+
+	if upgrade.Migrate != nil {
+		start := time.Now()
+		if err := upgrade.Migrate(ctx, upgradedSt, upgradedVms); err != nil {
+			return nil, errors.Wrap(err, "state migration failed")
+		}
+		report.MigrationTime = time.Since(start)
+	}
+
+	for _, ts := range tipsets {
+		anc, err := ancestors(ts)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		currentRes, err := current.ProcessTipSet(ctx, currentSt, currentVms, ts, anc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to process tipset %s under current rules", ts.String())
+		}
+		report.CurrentTime += time.Since(start)
+
+		start = time.Now()
+		upgradedRes, err := upgrade.Processor.ProcessTipSet(ctx, upgradedSt, upgradedVms, ts, anc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to process tipset %s under upgraded rules", ts.String())
+		}
+		report.UpgradedTime += time.Since(start)
+
+		msgCids, err := tipSetMessageCids(ts)
+		if err != nil {
+			return nil, err
+		}
+		h, err := ts.Height()
+		if err != nil {
+			return nil, err
+		}
+
+		currentReceipts := receiptsByMessage(msgCids, currentRes)
+		upgradedReceipts := receiptsByMessage(msgCids, upgradedRes)
+		for _, c := range msgCids {
+			if receiptsEqual(currentReceipts[c], upgradedReceipts[c]) {
+				continue
+			}
+			report.Divergences = append(report.Divergences, &UpgradeDivergence{
+				Height:   h,
+				TipSet:   ts.ToSortedCidSet(),
+				Message:  c,
+				Current:  currentReceipts[c],
+				Upgraded: upgradedReceipts[c],
+			})
+		}
+
+		report.TipSets++
+		report.Messages += len(msgCids)
+	}
+
+	return report, nil
+}
+
+// TipSetReceipts matches the results of processing ts with its messages,
+// returning the receipt of each message applied keyed by message cid.
+func TipSetReceipts(ts types.TipSet, res *ProcessTipSetResponse) (map[cid.Cid]*types.MessageReceipt, error) {
+	msgCids, err := tipSetMessageCids(ts)
+	if err != nil {
+		return nil, err
+	}
+	return receiptsByMessage(msgCids, res), nil
+}
+
+// tipSetMessageCids returns the cids of the messages of ts in the order
+// ProcessTipSet applies them, skipping duplicates.
+func tipSetMessageCids(ts types.TipSet) ([]cid.Cid, error) {
+	tips := ts.ToSlice()
+	types.SortBlocks(tips)
+
+	var cids []cid.Cid
+	seen := make(map[cid.Cid]struct{})
+	for _, blk := range tips {
+		for _, msg := range blk.Messages {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			cids = append(cids, c)
+		}
+	}
+	return cids, nil
+}
+
+// receiptsByMessage matches the results of processing a tipset with its
+// messages.  Results are only produced for successful messages, in the order
+// they were applied.
+func receiptsByMessage(msgCids []cid.Cid, res *ProcessTipSetResponse) map[cid.Cid]*types.MessageReceipt {
+	receipts := make(map[cid.Cid]*types.MessageReceipt)
+	i := 0
+	for _, c := range msgCids {
+		if !res.Successes.Has(c) || i >= len(res.Results) {
+			continue
+		}
+		receipts[c] = res.Results[i].Receipt
+		i++
+	}
+	return receipts
+}
+
+func receiptsEqual(a, b *types.MessageReceipt) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.ExitCode != b.ExitCode || len(a.Return) != len(b.Return) {
+		return false
+	}
+	for i := range a.Return {
+		if !bytes.Equal(a.Return[i], b.Return[i]) {
+			return false
+		}
+	}
+	if a.GasAttoFIL == nil || b.GasAttoFIL == nil {
+		return a.GasAttoFIL == b.GasAttoFIL
+	}
+	return a.GasAttoFIL.Equal(b.GasAttoFIL)
+}
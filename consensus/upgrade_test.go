@@ -0,0 +1,147 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	. "github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// rejectingProcessor is a Processor whose rules reject every message.
+type rejectingProcessor struct {
+	*DefaultProcessor
+}
+
+func (p *rejectingProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (*ProcessTipSetResponse, error) {
+	var res ProcessTipSetResponse
+	for _, blk := range ts.ToSlice() {
+		for _, msg := range blk.Messages {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			(&res.Failures).Add(c)
+		}
+	}
+	return &res, nil
+}
+
+func TestDryRunUpgrade(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := vm.NewStorageMap(bs)
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
+
+	minerAddr, toAddr := newAddress(), newAddress()
+	fromAddr := mockSigner.Addresses[0]
+
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(1000000)),
+		fromAddr:               th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+	})
+	minerOwner, err := address.NewActorAddress([]byte("mo"))
+	require.NoError(t, err)
+	stCid, _ := mustCreateMiner(ctx, t, st, vms, minerAddr, minerOwner)
+	require.NoError(t, vms.Flush())
+
+	msg := types.NewMessage(fromAddr, toAddr, 0, types.NewAttoFILFromFIL(550), "", nil)
+	smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	msgCid, err := smsg.Cid()
+	require.NoError(t, err)
+
+	blk := &types.Block{
+		Height:    20,
+		StateRoot: stCid,
+		Messages:  []*types.SignedMessage{smsg},
+		Miner:     minerAddr,
+	}
+	ts := th.RequireNewTipSet(t, blk)
+	noAncestors := func(types.TipSet) ([]types.TipSet, error) { return nil, nil }
+
+	t.Run("identical rules do not diverge", func(t *testing.T) {
+		migrated := false
+		upgrade := &Upgrade{
+			Name: "same",
+			Migrate: func(ctx context.Context, st state.Tree, vms vm.StorageMap) error {
+				migrated = true
+				return nil
+			},
+			Processor: NewDefaultProcessor(),
+		}
+
+		report, err := DryRunUpgrade(ctx, upgrade, NewDefaultProcessor(), cst, bs, stCid, []types.TipSet{ts}, noAncestors)
+		require.NoError(t, err)
+		assert.True(t, migrated)
+		assert.Equal(t, "same", report.Upgrade)
+		assert.Equal(t, 1, report.TipSets)
+		assert.Equal(t, 1, report.Messages)
+		assert.Empty(t, report.Divergences)
+	})
+
+	t.Run("reports messages with different outcomes", func(t *testing.T) {
+		upgrade := &Upgrade{Name: "reject", Processor: &rejectingProcessor{NewDefaultProcessor()}}
+
+		report, err := DryRunUpgrade(ctx, upgrade, NewDefaultProcessor(), cst, bs, stCid, []types.TipSet{ts}, noAncestors)
+		require.NoError(t, err)
+		require.Len(t, report.Divergences, 1)
+		div := report.Divergences[0]
+		assert.Equal(t, msgCid, div.Message)
+		assert.Equal(t, uint64(20), div.Height)
+		require.NotNil(t, div.Current)
+		assert.Equal(t, uint8(0), div.Current.ExitCode)
+		assert.Nil(t, div.Upgraded)
+	})
+
+	t.Run("leaves the original state untouched", func(t *testing.T) {
+		upgrade := &Upgrade{
+			Name: "wipe",
+			Migrate: func(ctx context.Context, st state.Tree, vms vm.StorageMap) error {
+				return st.SetActor(ctx, fromAddr, th.RequireNewAccountActor(t, types.ZeroAttoFIL))
+			},
+			Processor: NewDefaultProcessor(),
+		}
+
+		report, err := DryRunUpgrade(ctx, upgrade, NewDefaultProcessor(), cst, bs, stCid, []types.TipSet{ts}, noAncestors)
+		require.NoError(t, err)
+		// Without funds the transfer can no longer be applied.
+		require.Len(t, report.Divergences, 1)
+
+		st, err := state.LoadStateTree(ctx, cst, stCid, nil)
+		require.NoError(t, err)
+		from, err := st.GetActor(ctx, fromAddr)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewAttoFILFromFIL(10000), from.Balance)
+	})
+}
+
+func TestRegisterUpgrade(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.Error(t, RegisterUpgrade(&Upgrade{Name: "incomplete"}))
+
+	require.NoError(t, RegisterUpgrade(&Upgrade{Name: "test-upgrade", Processor: NewDefaultProcessor()}))
+	assert.Error(t, RegisterUpgrade(&Upgrade{Name: "test-upgrade", Processor: NewDefaultProcessor()}))
+
+	u, ok := LookupUpgrade("test-upgrade")
+	require.True(t, ok)
+	assert.Equal(t, "test-upgrade", u.Name)
+	assert.Contains(t, UpgradeNames(), "test-upgrade")
+}
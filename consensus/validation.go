@@ -19,6 +19,7 @@ var errGasAboveBlockLimitCt *metrics.Int64Counter
 var errInsufficientGasCt *metrics.Int64Counter
 var errNonceTooLowCt *metrics.Int64Counter
 var errNonceTooHighCt *metrics.Int64Counter
+var errInsufficientPendingFundsCt *metrics.Int64Counter
 
 func init() {
 	errNegativeValueCt = metrics.NewInt64Counter("consensus/msg_negative_value_err", "Number of negative valuedmessage")
@@ -26,6 +27,7 @@ func init() {
 	errInsufficientGasCt = metrics.NewInt64Counter("consensus/msg_insufficient_gas_err", "Number of messages with insufficient gas")
 	errNonceTooLowCt = metrics.NewInt64Counter("consensus/msg_nonce_low_err", "Number of messages with nonce too low")
 	errNonceTooHighCt = metrics.NewInt64Counter("consensus/msg_nonce_high_err", "Number of messages with nonce too high")
+	errInsufficientPendingFundsCt = metrics.NewInt64Counter("consensus/msg_insufficient_pending_funds_err", "Number of messages whose sender cannot also pay for its pending messages")
 }
 
 // SignedMessageValidator validates incoming signed messages.
@@ -37,6 +39,9 @@ type SignedMessageValidator interface {
 
 type defaultMessageValidator struct {
 	allowHighNonce bool
+	// aggregatedBLS accepts messages from BLS addresses without signatures,
+	// whose signatures blocks replace with an aggregate checked with the block.
+	aggregatedBLS bool
 }
 
 // NewDefaultMessageValidator creates a new default validator.
@@ -53,10 +58,18 @@ func NewOutboundMessageValidator() SignedMessageValidator {
 	return &defaultMessageValidator{allowHighNonce: true}
 }
 
+// NewBlockMessageValidator creates a new validator for the messages of
+// blocks.  It matches the default behaviour but accepts messages from BLS
+// addresses without signatures, since block validation checks their
+// signatures against the aggregate signature of the block.
+func NewBlockMessageValidator() SignedMessageValidator {
+	return &defaultMessageValidator{aggregatedBLS: true}
+}
+
 var _ SignedMessageValidator = (*defaultMessageValidator)(nil)
 
 func (v *defaultMessageValidator) Validate(ctx context.Context, msg *types.SignedMessage, fromActor *actor.Actor) error {
-	if !msg.VerifySignature() {
+	if !v.verifySignature(msg) {
 		return errInvalidSignature
 	}
 
@@ -108,6 +121,15 @@ func (v *defaultMessageValidator) Validate(ctx context.Context, msg *types.Signe
 	return nil
 }
 
+// verifySignature returns true if the signature of msg is valid, or if msg is
+// from a BLS address and its signature was aggregated into a block's.
+func (v *defaultMessageValidator) verifySignature(msg *types.SignedMessage) bool {
+	if v.aggregatedBLS && !msg.From.Empty() && msg.From.Protocol() == address.BLS && len(msg.Signature) == 0 {
+		return true
+	}
+	return msg.VerifySignature()
+}
+
 // Check's whether the maximum gas charge + message value is within the actor's balance.
 // Note that this is an imperfect test, since nested messages invoked by this one may transfer
 // more value from the actor's balance.
@@ -121,7 +143,9 @@ type ingestionValidatorAPI interface {
 	GetActor(context.Context, address.Address) (*actor.Actor, error)
 }
 
-// IngestionValidator can access latest state and runs additional checks to mitigate DoS attacks
+// IngestionValidator can access latest state and runs additional checks to mitigate DoS attacks.
+// It validates messages before they are admitted to the message pool or relayed over pubsub,
+// rejecting invalid ones with a MessageRejection.
 type IngestionValidator struct {
 	api       ingestionValidatorAPI
 	cfg       *config.MessagePoolConfig
@@ -140,6 +164,24 @@ func NewIngestionValidator(api ingestionValidatorAPI, cfg *config.MessagePoolCon
 // Validate validates the signed message.
 // Errors probably mean the validation failed, but possibly indicate a failure to retrieve state
 func (v *IngestionValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	// check that the message is not too large to be included in a block
+	size, err := msg.Size()
+	if err != nil {
+		return errors.FaultErrorWrap(err, "failed to serialize message")
+	}
+	if size > types.MessageSizeLimit {
+		return NewMessageRejection(RejectTooLarge, "message size (%d) exceeds limit (%d)", size, types.MessageSizeLimit)
+	}
+
+	// check that the message pays at least the minimum gas price and allows
+	// at least the minimum gas, so that miners have a reason to include it
+	if v.cfg.MinGasPrice != nil && msg.GasPrice.LessThan(v.cfg.MinGasPrice) {
+		return NewMessageRejection(RejectGasPriceTooLow, "message gas price %s is below the minimum %s", msg.GasPrice.String(), v.cfg.MinGasPrice.String())
+	}
+	if msg.GasLimit < v.cfg.MinGasLimit {
+		return NewMessageRejection(RejectGasLimitTooLow, "message gas limit %d is below the minimum %d", msg.GasLimit, v.cfg.MinGasLimit)
+	}
+
 	// retrieve from actor
 	fromActor, err := v.api.GetActor(ctx, msg.From)
 	if err != nil {
@@ -152,8 +194,41 @@ func (v *IngestionValidator) Validate(ctx context.Context, msg *types.SignedMess
 
 	// check that message nonce is not too high
 	if msg.Nonce > fromActor.Nonce && msg.Nonce-fromActor.Nonce > v.cfg.MaxNonceGap {
-		return errors.NewRevertErrorf("message nonce (%d) is too much greater than actor nonce (%d)", msg.Nonce, fromActor.Nonce)
+		return NewMessageRejection(RejectNonceTooHigh, "message nonce (%d) is too much greater than actor nonce (%d)", msg.Nonce, fromActor.Nonce)
 	}
 
-	return v.validator.Validate(ctx, msg, fromActor)
+	return AsRejection(v.validator.Validate(ctx, msg, fromActor))
+}
+
+// ValidateSpend checks that the sender's balance covers the most msg can
+// cost on top of pendingSpend, so that messages are not admitted when the
+// sender's pending messages could not all be paid for.
+func (v *IngestionValidator) ValidateSpend(ctx context.Context, msg *types.SignedMessage, pendingSpend *types.AttoFIL) error {
+	balance := types.ZeroAttoFIL
+	fromActor, err := v.api.GetActor(ctx, msg.From)
+	if err == nil {
+		balance = fromActor.Balance
+	} else if !state.IsActorNotFoundError(err) {
+		return err
+	}
+
+	if required := pendingSpend.Add(msg.MaxCost()); balance.LessThan(required) {
+		errInsufficientPendingFundsCt.Inc(ctx, 1)
+		return NewMessageRejection(RejectInsufficientFunds, "sender balance (%s) cannot cover message cost (%s) along with pending messages (%s)", balance, msg.MaxCost(), pendingSpend)
+	}
+	return nil
+}
+
+// ActorNonce returns the nonce of the actor at addr in the latest state, the
+// nonce of the next message from addr to be mined.  It is zero if there is no
+// actor at addr yet.
+func (v *IngestionValidator) ActorNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	fromActor, err := v.api.GetActor(ctx, addr)
+	if err != nil {
+		if state.IsActorNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return uint64(fromActor.Nonce), nil
 }
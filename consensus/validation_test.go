@@ -110,6 +110,43 @@ func TestOutboundMessageValidator(t *testing.T) {
 	})
 }
 
+func TestBlockMessageValidator(t *testing.T) {
+	tf.UnitTest(t)
+
+	blsKeys := types.MustGenerateBLSKeyInfo(1)
+	blsSigner := types.NewMockSigner(blsKeys)
+	alice, err := blsKeys[0].Address()
+	require.NoError(t, err)
+	bob := addresses[1]
+	actor := newActor(t, 1000, 100)
+
+	ctx := context.Background()
+	msg := types.NewMessage(alice, bob, 100, attoFil(5), "method", []byte("params"))
+	signed, err := types.NewSignedMessage(*msg, blsSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	unsigned := *signed
+	unsigned.Signature = nil
+
+	t.Run("accepts BLS messages with aggregated signatures", func(t *testing.T) {
+		validator := consensus.NewBlockMessageValidator()
+		assert.NoError(t, validator.Validate(ctx, signed, actor))
+		assert.NoError(t, validator.Validate(ctx, &unsigned, actor))
+	})
+
+	t.Run("default validator requires BLS signatures", func(t *testing.T) {
+		validator := consensus.NewDefaultMessageValidator()
+		assert.NoError(t, validator.Validate(ctx, signed, actor))
+		assert.Error(t, validator.Validate(ctx, &unsigned, actor))
+	})
+
+	t.Run("rejects unsigned secp256k1 messages", func(t *testing.T) {
+		validator := consensus.NewBlockMessageValidator()
+		msg := newMessage(t, addresses[0], bob, 100, 5, 1, 0)
+		msg.Signature = nil
+		assert.Error(t, validator.Validate(ctx, msg, actor))
+	})
+}
+
 func TestIngestionValidator(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -139,6 +176,71 @@ func TestIngestionValidator(t *testing.T) {
 		msg := newMessage(t, bob, alice, 0, 0, 1, 0)
 		assert.NoError(t, validator.Validate(ctx, msg))
 	})
+
+	t.Run("Rejects oversized messages", func(t *testing.T) {
+		msg := types.NewMessage(alice, bob, 53, attoFil(5), "method", make([]byte, types.MessageSizeLimit))
+		signed, err := types.NewSignedMessage(*msg, signer, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+
+		err = validator.Validate(ctx, signed)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds limit")
+	})
+
+	t.Run("Rejects messages below the minimum gas price and limit", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		minGasPrice := types.NewGasPrice(10)
+		cfg.MinGasPrice = &minGasPrice
+		cfg.MinGasLimit = types.NewGasUnits(5)
+		validator := consensus.NewIngestionValidator(api, cfg)
+
+		err := validator.Validate(ctx, newMessage(t, alice, bob, 53, 5, 9, 5))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "below the minimum")
+		code, _ := consensus.RejectCodeOf(err)
+		assert.Equal(t, consensus.RejectGasPriceTooLow, code)
+
+		err = validator.Validate(ctx, newMessage(t, alice, bob, 53, 5, 10, 4))
+		require.Error(t, err)
+		code, _ = consensus.RejectCodeOf(err)
+		assert.Equal(t, consensus.RejectGasLimitTooLow, code)
+
+		assert.NoError(t, validator.Validate(ctx, newMessage(t, alice, bob, 53, 5, 10, 5)))
+	})
+
+	t.Run("Rejects invalid messages with distinct codes", func(t *testing.T) {
+		badSig := newMessage(t, alice, bob, 53, 5, 1, 0)
+		badSig.Signature = []byte{}
+
+		for expected, msg := range map[consensus.RejectCode]*types.SignedMessage{
+			consensus.RejectInvalidSignature:  badSig,
+			consensus.RejectSelfSend:          newMessage(t, alice, alice, 53, 5, 1, 0),
+			consensus.RejectGasPriceTooLow:    newMessage(t, alice, bob, 53, 5, 0, 0),
+			consensus.RejectInsufficientFunds: newMessage(t, alice, bob, 53, 1001, 1, 0),
+			consensus.RejectNonceTooLow:       newMessage(t, alice, bob, 52, 5, 1, 0),
+		} {
+			err := validator.Validate(ctx, msg)
+			require.Error(t, err)
+			code, ok := consensus.RejectCodeOf(err)
+			assert.True(t, ok)
+			assert.Equal(t, expected, code)
+			assert.Contains(t, err.Error(), string(expected))
+		}
+
+		err := validator.ValidateSpend(ctx, newMessage(t, alice, bob, 53, 100, 5, 10), attoFil(851))
+		code, _ := consensus.RejectCodeOf(err)
+		assert.Equal(t, consensus.RejectInsufficientFunds, code)
+	})
+
+	t.Run("Validates spend against pending messages", func(t *testing.T) {
+		// Costs 100 + 5*10 = 150 of alice's 1000.
+		msg := newMessage(t, alice, bob, 53, 100, 5, 10)
+		assert.NoError(t, validator.ValidateSpend(ctx, msg, attoFil(850)))
+
+		err := validator.ValidateSpend(ctx, msg, attoFil(851))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot cover message cost")
+	})
 }
 
 func newActor(t *testing.T, balanceAF int, nonce uint64) *actor.Actor {
@@ -0,0 +1,137 @@
+package consensus
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// WeightAlgorithm is a version of the rule giving weight to the blocks of
+// the chain.
+type WeightAlgorithm struct {
+	// Name identifies the algorithm.
+	Name string
+	// BlockWeight returns the weight a block mined by a miner holding
+	// minerPower out of totalPower adds to its parent weight.
+	BlockWeight func(minerPower, totalPower *types.BytesAmount) *big.Float
+	// MinBlockWeight and MaxBlockWeight bound, in fixed point, the weight a
+	// block can add whatever the power of its miner.
+	MinBlockWeight uint64
+	MaxBlockWeight uint64
+}
+
+// ECWeightV1 is the weight of expected consensus as specified at genesis:
+// each block adds ECV plus ECPrM times its miner's share of the power.
+var ECWeightV1 = &WeightAlgorithm{
+	Name: "ec-v1",
+	BlockWeight: func(minerPower, totalPower *types.BytesAmount) *big.Float {
+		floatOwnBytes := new(big.Float).SetInt(minerPower.BigInt())
+		floatTotalBytes := new(big.Float).SetInt(totalPower.BigInt())
+		wBlk := new(big.Float)
+		wBlk.Quo(floatOwnBytes, floatTotalBytes)
+		wBlk.Mul(wBlk, new(big.Float).SetInt64(int64(ECPrM))) // Power addition
+		wBlk.Add(wBlk, new(big.Float).SetInt64(int64(ECV)))   // Constant addition
+		return wBlk
+	},
+	MinBlockWeight: ECV * 1000,
+	MaxBlockWeight: (ECV + ECPrM) * 1000,
+}
+
+var (
+	weightAlgorithmsLk sync.Mutex
+	weightAlgorithms   = map[string]*WeightAlgorithm{ECWeightV1.Name: ECWeightV1}
+)
+
+// RegisterWeightAlgorithm makes a weight algorithm available to activate by
+// name.  It is intended to be called from init functions.
+func RegisterWeightAlgorithm(alg *WeightAlgorithm) error {
+	if alg.Name == "" || alg.BlockWeight == nil {
+		return errors.New("weight algorithm must have a name and a block weight")
+	}
+	if alg.MinBlockWeight > alg.MaxBlockWeight {
+		return errors.Errorf("weight algorithm %s has a minimum block weight above its maximum", alg.Name)
+	}
+	weightAlgorithmsLk.Lock()
+	defer weightAlgorithmsLk.Unlock()
+	if _, ok := weightAlgorithms[alg.Name]; ok {
+		return errors.Errorf("weight algorithm %s already registered", alg.Name)
+	}
+	weightAlgorithms[alg.Name] = alg
+	return nil
+}
+
+// LookupWeightAlgorithm returns the registered weight algorithm with the
+// given name.
+func LookupWeightAlgorithm(name string) (*WeightAlgorithm, bool) {
+	weightAlgorithmsLk.Lock()
+	defer weightAlgorithmsLk.Unlock()
+	alg, ok := weightAlgorithms[name]
+	return alg, ok
+}
+
+type weightActivation struct {
+	height    uint64
+	algorithm *WeightAlgorithm
+}
+
+// WeightSchedule selects the weight algorithm in force at each height, so
+// that a new algorithm activates at a fork height rather than requiring all
+// nodes to restart at once.
+type WeightSchedule struct {
+	lk sync.RWMutex
+	// activations is sorted by ascending height and starts at height 0.
+	activations []weightActivation
+}
+
+// NewWeightSchedule returns a schedule in which genesis is in force from
+// genesis on.
+func NewWeightSchedule(genesis *WeightAlgorithm) *WeightSchedule {
+	return &WeightSchedule{activations: []weightActivation{{0, genesis}}}
+}
+
+// Weights is the weight schedule of the network.
+var Weights = NewWeightSchedule(ECWeightV1)
+
+// Activate puts the registered weight algorithm with the given name in force
+// from height on.  Activations must be made in ascending order of height.
+func (s *WeightSchedule) Activate(height uint64, name string) error {
+	alg, ok := LookupWeightAlgorithm(name)
+	if !ok {
+		return errors.Errorf("unknown weight algorithm %q", name)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if last := s.activations[len(s.activations)-1]; height <= last.height {
+		return errors.Errorf("weight algorithm %s must activate above height %d, where %s activates", name, last.height, last.algorithm.Name)
+	}
+	s.activations = append(s.activations, weightActivation{height, alg})
+	return nil
+}
+
+// At returns the weight algorithm in force at height.
+func (s *WeightSchedule) At(height uint64) *WeightAlgorithm {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	i := sort.Search(len(s.activations), func(i int) bool {
+		return s.activations[i].height > height
+	})
+	return s.activations[i-1].algorithm
+}
+
+// IncreaseBounds returns the least and greatest amounts, in fixed point, by
+// which a tipset of n blocks at height can add to its parent weight.  The
+// bounds allow for the rounding of fixed point values.
+func (s *WeightSchedule) IncreaseBounds(height uint64, n int) (min uint64, max uint64) {
+	alg := s.At(height)
+	min = uint64(n) * alg.MinBlockWeight
+	max = uint64(n) * alg.MaxBlockWeight
+	if min > 0 {
+		min--
+	}
+	return min, max + 1
+}
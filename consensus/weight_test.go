@@ -0,0 +1,62 @@
+package consensus_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/consensus"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestECWeightV1(t *testing.T) {
+	tf.UnitTest(t)
+
+	w := consensus.ECWeightV1.BlockWeight(types.NewBytesAmount(1), types.NewBytesAmount(4))
+	fixed, err := types.BigToFixed(w)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(consensus.ECV*1000+consensus.ECPrM*1000/4), fixed)
+}
+
+func TestWeightSchedule(t *testing.T) {
+	tf.UnitTest(t)
+
+	// A weight giving a constant 1 to every block, to tell it apart.
+	flat := &consensus.WeightAlgorithm{
+		Name: "test-flat",
+		BlockWeight: func(minerPower, totalPower *types.BytesAmount) *big.Float {
+			return big.NewFloat(1)
+		},
+		MinBlockWeight: 1000,
+		MaxBlockWeight: 1000,
+	}
+	require.NoError(t, consensus.RegisterWeightAlgorithm(flat))
+	assert.Error(t, consensus.RegisterWeightAlgorithm(flat))
+
+	s := consensus.NewWeightSchedule(consensus.ECWeightV1)
+	require.NoError(t, s.Activate(100, "test-flat"))
+
+	assert.Equal(t, consensus.ECWeightV1, s.At(0))
+	assert.Equal(t, consensus.ECWeightV1, s.At(99))
+	assert.Equal(t, flat, s.At(100))
+	assert.Equal(t, flat, s.At(1000))
+
+	min, max := s.IncreaseBounds(99, 2)
+	assert.Equal(t, 2*consensus.ECV*1000-1, min)
+	assert.Equal(t, 2*(consensus.ECV+consensus.ECPrM)*1000+1, max)
+	min, max = s.IncreaseBounds(100, 2)
+	assert.Equal(t, uint64(1999), min)
+	assert.Equal(t, uint64(2001), max)
+
+	t.Run("activations must be in ascending order of height", func(t *testing.T) {
+		assert.Error(t, s.Activate(100, "ec-v1"))
+		assert.Error(t, s.Activate(50, "ec-v1"))
+	})
+
+	t.Run("only registered algorithms can be activated", func(t *testing.T) {
+		assert.Error(t, s.Activate(200, "unknown"))
+	})
+}
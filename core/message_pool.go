@@ -2,26 +2,55 @@ package core
 
 import (
 	"context"
+	"math/big"
+	"sort"
 	"sync"
 
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
-var mpSize = metrics.NewInt64Gauge("message_pool_size", "The size of the message pool")
+var (
+	mpSize    = metrics.NewInt64Gauge("message_pool_size", "The size of the message pool")
+	mpEvictCt = metrics.NewInt64Counter("message_pool_evict", "The number of messages evicted from the full message pool")
+)
 
-// MessageTimeOut is the number of tipsets we should receive before timing out messages
+// MessageTimeOut is the number of tipsets we should receive before timing out messages,
+// unless configured otherwise.
 const MessageTimeOut = 6
 
+// DefaultReplaceByFeePercent is the least percentage by which a message must
+// raise the gas price of the pending message with the same sender and nonce
+// to replace it, unless configured otherwise.
+const DefaultReplaceByFeePercent = 25
+
+// DefaultMaxSenderPoolSize is the maximum number of pending messages from a
+// single sender, unless configured otherwise.
+const DefaultMaxSenderPoolSize = 1000
+
 type timedmessage struct {
 	message *types.SignedMessage
 	addedAt uint64
+	// queued is true while the message waits for messages with lower nonces
+	// from its sender.
+	queued bool
+	// local is true if the message was submitted through this node.
+	local bool
+	// persisted is true if the message is in the pool's datastore.
+	persisted bool
+	// size is the size in bytes of the serialized message.
+	size int
+	// reinjected is true if the message returns to the pool from a block
+	// dropped from the chain.
+	reinjected bool
 }
 
 // MessagePoolAPI defines an interface to api resources the message pool needs.
@@ -32,6 +61,16 @@ type MessagePoolAPI interface {
 // MessagePoolValidator defines a validator that ensures a message can go through the pool.
 type MessagePoolValidator interface {
 	Validate(ctx context.Context, msg *types.SignedMessage) error
+	// ValidateSpend checks that the sender can afford the message on top of
+	// pendingSpend, the most its messages already in the pool can cost.
+	ValidateSpend(ctx context.Context, msg *types.SignedMessage, pendingSpend *types.AttoFIL) error
+}
+
+// actorNonceReader is implemented by validators that can read the nonce of
+// a sender's actor in the latest state.  A pool whose validator implements it
+// queues messages that follow a nonce gap.
+type actorNonceReader interface {
+	ActorNonce(ctx context.Context, addr address.Address) (uint64, error)
 }
 
 type addressNonce struct {
@@ -49,6 +88,20 @@ func newAddressNonce(msg *types.SignedMessage) addressNonce {
 // via network or directly created via user command that have yet to be included
 // in a block. Messages are removed as they are processed.
 //
+// A message with the sender and nonce of a pending message replaces it if it
+// pays a high enough gas price, see MinReplacementGasPrice, so that a message
+// stuck with too low a gas price can be sent again.
+//
+// The pool holds at most MaxPoolSize messages, and at most MaxSenderPoolSize
+// from any sender.  When full, a new message evicts the message received from
+// the network with the lowest gas price, oldest first, if it pays more.  Only
+// the message with the highest nonce of a sender is evicted, so that eviction
+// opens no nonce gap.
+//
+// A message whose nonce follows a gap, i.e. whose sender's messages with
+// lower nonces are neither on chain nor in the pool, is queued: it is kept but
+// neither returned by Pending for mining nor published until the gap fills.
+//
 // MessagePool is safe for concurrent access.
 type MessagePool struct {
 	lk sync.RWMutex
@@ -56,8 +109,18 @@ type MessagePool struct {
 	api           MessagePoolAPI
 	cfg           *config.MessagePoolConfig
 	validator     MessagePoolValidator
-	pending       map[cid.Cid]*timedmessage // all pending messages
-	addressNonces map[addressNonce]bool     // set of address nonce pairs used to efficiently validate duplicate nonces
+	pending       map[cid.Cid]*timedmessage           // all pending messages, including queued ones
+	addressNonces map[addressNonce]cid.Cid            // cids of the messages by address nonce pair, used to efficiently validate duplicate nonces
+	bySender      map[address.Address][]*timedmessage // pending messages of each sender sorted by nonce
+	onPromote     func([]*types.SignedMessage)        // called with queued messages once their gaps fill
+	onReinject    func([]*types.SignedMessage)        // called with messages returned to the pool by reorgs
+	events        messagePoolEvents
+	bytes         int // total size of the pending messages
+
+	// ds, if set, keeps the local messages, and all messages if persistAll
+	// is true, across restarts.
+	ds         datastore.Datastore
+	persistAll bool
 }
 
 // Add adds a message to the pool.
@@ -67,48 +130,206 @@ func (pool *MessagePool) Add(ctx context.Context, msg *types.SignedMessage) (cid
 		return cid.Undef, err
 	}
 
-	return pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: blockTime})
+	c, promoted, err := pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: blockTime})
+	if err != nil {
+		return cid.Undef, err
+	}
+	pool.promote(promoted)
+	return c, nil
 }
 
 // An error coming out of addTimedMessage probably means the message failed to validate,
-// but it could indicate a more serious problem with the system.
-func (pool *MessagePool) addTimedMessage(ctx context.Context, msg *timedmessage) (cid.Cid, error) {
+// but it could indicate a more serious problem with the system.  It also
+// returns the queued messages the new message promoted.
+func (pool *MessagePool) addTimedMessage(ctx context.Context, msg *timedmessage) (cid.Cid, []*types.SignedMessage, error) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
 
 	c, err := msg.message.Cid()
 	if err != nil {
-		return cid.Undef, errors.Wrap(err, "failed to create CID")
+		return cid.Undef, nil, errors.Wrap(err, "failed to create CID")
 	}
 
 	// ignore message prior to validation if it is already in pool
-	_, found := pool.pending[c]
-	if found {
-		return c, nil
+	if existing, found := pool.pending[c]; found {
+		// A message received from the network may be submitted locally too.
+		if msg.local && !existing.local {
+			existing.local = true
+			if err := pool.persist(c, existing); err != nil {
+				log.Warningf("failed to persist message %s: %s", c, err)
+			}
+		}
+		return c, nil, nil
+	}
+
+	data, err := msg.message.Marshal()
+	if err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "failed to marshal message")
+	}
+	msg.size = len(data)
+	// A message too large for any block would never leave the pool, and
+	// would hold back the later messages of its sender.
+	if msg.size > types.MessageSizeLimit {
+		return cid.Undef, nil, errors.Wrap(consensus.NewMessageRejection(consensus.RejectTooLarge, "message size (%d) exceeds limit (%d)", msg.size, types.MessageSizeLimit), "validation error adding message to pool")
 	}
 
-	if err = pool.validateMessage(ctx, msg.message); err != nil {
-		return cid.Undef, errors.Wrap(err, "validation error adding message to pool")
+	replaced, err := pool.validateMessage(ctx, msg.message)
+	if err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "validation error adding message to pool")
+	}
+	if replaced.Defined() {
+		log.Infof("message %s replaces message %s with the same sender and nonce", c, replaced)
+		pool.remove(replaced, MessagePoolReplace, c.String())
+	} else if len(pool.pending) >= pool.cfg.MaxPoolSize {
+		evicted := pool.evictionCandidate(msg.message)
+		if !evicted.Defined() {
+			return cid.Undef, nil, errors.Wrap(consensus.NewMessageRejection(consensus.RejectPoolFull, "message pool is full (%d messages)", pool.cfg.MaxPoolSize), "validation error adding message to pool")
+		}
+		log.Infof("message %s evicts message %s from the full message pool", c, evicted)
+		pool.remove(evicted, MessagePoolRemove, RemoveReasonEvicted)
+		mpEvictCt.Inc(ctx, 1)
 	}
 
 	pool.pending[c] = msg
-	pool.addressNonces[newAddressNonce(msg.message)] = true
-	mpSize.Set(ctx, int64(len(pool.pending)))
-	return c, nil
+	pool.addressNonces[newAddressNonce(msg.message)] = c
+	pool.index(msg)
+	pool.bytes += msg.size
+	pool.updateSizeMetrics(ctx)
+	ev := MessagePoolEvent{Type: MessagePoolAdd, Cid: c, Height: msg.addedAt}
+	if msg.reinjected {
+		ev.Reason = AddReasonReorg
+	}
+	pool.events.emit(ev)
+	if err := pool.persist(c, msg); err != nil {
+		// The message is only lost if the node restarts before it is mined.
+		log.Warningf("failed to persist message %s: %s", c, err)
+	}
+	return c, pool.updateQueued(ctx, msg.message.From), nil
+}
+
+// updateQueued queues the messages of sender that follow a nonce gap and
+// unqueues the others, returning the messages unqueued.  Without a way to
+// read the sender's nonce no message is queued.  pool.lk must be held.
+func (pool *MessagePool) updateQueued(ctx context.Context, sender address.Address) []*types.SignedMessage {
+	nr, ok := pool.validator.(actorNonceReader)
+	if !ok {
+		return nil
+	}
+	next, err := nr.ActorNonce(ctx, sender)
+	if err != nil {
+		log.Warningf("failed to read nonce of %s, leaving its messages queued as they are: %s", sender, err)
+		return nil
+	}
+
+	// The messages are sorted by nonce, so those up to the first gap
+	// follow each other from the actor's nonce.
+	var promoted []*types.SignedMessage
+	for _, tm := range pool.bySender[sender] {
+		nonce := uint64(tm.message.Nonce)
+		if nonce == next {
+			next++
+		}
+		queued := nonce >= next
+		if tm.queued && !queued {
+			promoted = append(promoted, tm.message)
+		}
+		tm.queued = queued
+	}
+	return promoted
+}
+
+// index adds msg to the messages of its sender.  pool.lk must be held.
+func (pool *MessagePool) index(msg *timedmessage) {
+	sender := msg.message.From
+	msgs := pool.bySender[sender]
+	i := sort.Search(len(msgs), func(i int) bool { return msgs[i].message.Nonce >= msg.message.Nonce })
+	msgs = append(msgs, nil)
+	copy(msgs[i+1:], msgs[i:])
+	msgs[i] = msg
+	pool.bySender[sender] = msgs
+}
+
+// unindex removes msg from the messages of its sender.  pool.lk must be
+// held.
+func (pool *MessagePool) unindex(msg *timedmessage) {
+	sender := msg.message.From
+	msgs := pool.bySender[sender]
+	i := sort.Search(len(msgs), func(i int) bool { return msgs[i].message.Nonce >= msg.message.Nonce })
+	if i == len(msgs) || msgs[i] != msg {
+		return
+	}
+	if len(msgs) == 1 {
+		delete(pool.bySender, sender)
+		return
+	}
+	pool.bySender[sender] = append(msgs[:i], msgs[i+1:]...)
 }
 
-// Pending returns all pending messages.
+// promote hands messages unqueued to the promotion handler.
+func (pool *MessagePool) promote(msgs []*types.SignedMessage) {
+	pool.lk.RLock()
+	onPromote := pool.onPromote
+	pool.lk.RUnlock()
+
+	if len(msgs) > 0 && onPromote != nil {
+		onPromote(msgs)
+	}
+}
+
+// OnPromote sets f to be called with queued messages once the messages
+// filling their nonce gap are added to the pool or mined, e.g. to publish
+// them.
+func (pool *MessagePool) OnPromote(f func([]*types.SignedMessage)) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.onPromote = f
+}
+
+// OnReinject sets f to be called with the messages of blocks dropped from the
+// chain by a reorg that the new chain doesn't include, once they are back in
+// the pool and not queued, e.g. to publish them again.
+func (pool *MessagePool) OnReinject(f func([]*types.SignedMessage)) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.onReinject = f
+}
+
+// Pending returns all pending messages, except queued ones.
 func (pool *MessagePool) Pending() []*types.SignedMessage {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
 	out := make([]*types.SignedMessage, 0, len(pool.pending))
 	for _, msg := range pool.pending {
-		out = append(out, msg.message)
+		if !msg.queued {
+			out = append(out, msg.message)
+		}
 	}
 
 	return out
 }
 
+// Queued returns the messages waiting for messages with lower nonces from
+// their senders.
+func (pool *MessagePool) Queued() []*types.SignedMessage {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	var out []*types.SignedMessage
+	for _, msg := range pool.pending {
+		if msg.queued {
+			out = append(out, msg.message)
+		}
+	}
+	return out
+}
+
+// IsQueued returns true if the message with cid c is in the pool and queued.
+func (pool *MessagePool) IsQueued(c cid.Cid) bool {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	msg, ok := pool.pending[c]
+	return ok && msg.queued
+}
+
 // Get retrieves a message from the pool by CID.
 func (pool *MessagePool) Get(c cid.Cid) (*types.SignedMessage, bool) {
 	pool.lk.RLock()
@@ -124,15 +345,29 @@ func (pool *MessagePool) Get(c cid.Cid) (*types.SignedMessage, bool) {
 
 // Remove removes the message by CID from the pending pool.
 func (pool *MessagePool) Remove(c cid.Cid) {
+	pool.drop(c, MessagePoolRemove, RemoveReasonRequested)
+}
+
+// drop removes the message by CID from the pending pool, reporting it to
+// subscribers as an event of type typ with reason.
+func (pool *MessagePool) drop(c cid.Cid, typ MessagePoolEventType, reason string) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
+	pool.remove(c, typ, reason)
+}
 
+// remove is drop without locking. pool.lk must be held.
+func (pool *MessagePool) remove(c cid.Cid, typ MessagePoolEventType, reason string) {
 	msg, ok := pool.pending[c]
 	if ok {
 		delete(pool.addressNonces, newAddressNonce(msg.message))
 		delete(pool.pending, c)
+		pool.unindex(msg)
+		pool.bytes -= msg.size
+		pool.unpersist(c, msg)
+		pool.events.emit(MessagePoolEvent{Type: typ, Cid: c, Reason: reason, Height: msg.addedAt})
 	}
-	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+	pool.updateSizeMetrics(context.TODO())
 }
 
 // NewMessagePool constructs a new MessagePool.
@@ -142,7 +377,8 @@ func NewMessagePool(api MessagePoolAPI, cfg *config.MessagePoolConfig, validator
 		cfg:           cfg,
 		validator:     validator,
 		pending:       make(map[cid.Cid]*timedmessage),
-		addressNonces: make(map[addressNonce]bool),
+		addressNonces: make(map[addressNonce]cid.Cid),
+		bySender:      make(map[address.Address][]*timedmessage),
 	}
 }
 
@@ -158,68 +394,167 @@ func (pool *MessagePool) UpdateMessagePool(ctx context.Context, store chain.Bloc
 		return err
 	}
 
-	// Add all message from the old blocks to the message pool, so they can be mined again.
-	for _, blk := range oldBlocks {
+	// Cid() can error, so collect all the CIDs of the new blocks up front.
+	// Only the senders of the messages of the blocks have their nonces
+	// moved by the new head.
+	var removeCids []cid.Cid
+	mined := make(map[cid.Cid]struct{})
+	senders := make(map[address.Address]struct{})
+	for _, blk := range newBlocks {
 		for _, msg := range blk.Messages {
-			_, err = pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: uint64(blk.Height)})
+			cid, err := msg.Cid()
 			if err != nil {
-				log.Info(err)
+				return err
 			}
+			removeCids = append(removeCids, cid)
+			mined[cid] = struct{}{}
+			senders[msg.From] = struct{}{}
 		}
 	}
 
-	// Remove all messages in the new blocks from the pool, now mined.
-	// Cid() can error, so collect all the CIDs up front.
-	var removeCids []cid.Cid
-	for _, blk := range newBlocks {
+	// Add all message from the old blocks the new blocks don't include to
+	// the message pool, so they can be mined again.
+	var reinjected, promoted []*types.SignedMessage
+	for _, blk := range oldBlocks {
 		for _, msg := range blk.Messages {
-			cid, err := msg.Cid()
+			c, err := msg.Cid()
 			if err != nil {
 				return err
 			}
-			removeCids = append(removeCids, cid)
+			if _, ok := mined[c]; ok {
+				continue
+			}
+			senders[msg.From] = struct{}{}
+			if _, ok := pool.Get(c); ok {
+				continue
+			}
+			_, p, err := pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: uint64(blk.Height), reinjected: true})
+			if err != nil {
+				log.Info(err)
+				continue
+			}
+			reinjected = append(reinjected, msg)
+			promoted = append(promoted, p...)
 		}
 	}
+
+	// Remove all messages in the new blocks from the pool, now mined.
 	for _, c := range removeCids {
-		pool.Remove(c)
+		pool.drop(c, MessagePoolMined, "")
 	}
 
 	// prune all messages that have been in the pool too long
-	return pool.timeoutMessages(ctx, store, newHead)
+	expired, err := pool.timeoutMessages(ctx, store, newHead)
+	if err != nil {
+		return err
+	}
+	for _, sender := range expired {
+		senders[sender] = struct{}{}
+	}
+
+	// The new head may have moved the nonces of these senders, and expired
+	// messages may have opened gaps.
+	pool.promote(append(promoted, pool.updateQueuedSenders(ctx, senders)...))
+	pool.reinject(reinjected)
+	return nil
+}
+
+// reinject hands the messages reinjected still in the pool and not queued to
+// the reinjection handler.  Queued ones are promoted once their gaps fill.
+func (pool *MessagePool) reinject(msgs []*types.SignedMessage) {
+	pool.lk.RLock()
+	onReinject := pool.onReinject
+	var ready []*types.SignedMessage
+	for _, msg := range msgs {
+		c, err := msg.Cid()
+		if err != nil {
+			continue
+		}
+		if tm, ok := pool.pending[c]; ok && !tm.queued {
+			ready = append(ready, msg)
+		}
+	}
+	pool.lk.RUnlock()
+
+	if len(ready) > 0 && onReinject != nil {
+		log.Infof("reinjected %d messages of blocks dropped by a reorg", len(ready))
+		onReinject(ready)
+	}
+}
+
+// updateQueuedSenders updates the queued messages of senders, returning the
+// messages unqueued.
+func (pool *MessagePool) updateQueuedSenders(ctx context.Context, senders map[address.Address]struct{}) []*types.SignedMessage {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	var promoted []*types.SignedMessage
+	for sender := range senders {
+		promoted = append(promoted, pool.updateQueued(ctx, sender)...)
+	}
+	return promoted
 }
 
-// timeoutMessages removes all messages from the pool that arrived more than MessageTimeout tip sets ago.
+// MessageTimeOut returns the number of tipsets after which pending messages
+// time out.
+func (pool *MessagePool) MessageTimeOut() uint64 {
+	if pool.cfg.MaxMessageAge == 0 {
+		return MessageTimeOut
+	}
+	return pool.cfg.MaxMessageAge
+}
+
+// timeoutMessages removes all messages from the pool that arrived more than MessageTimeOut() tip sets ago,
+// and returns the senders of the messages removed.
 // Note that we measure the timeout in the number of tip sets we have received rather than a fixed block
 // height. This prevents us from prematurely timing messages that arrive during long chains of null blocks.
 // Also when blocks fill, the rate of message processing will correspond more closely to rate of tip
 // sets than to the expected block time over short timescales.
-func (pool *MessagePool) timeoutMessages(ctx context.Context, store chain.BlockProvider, head types.TipSet) error {
+func (pool *MessagePool) timeoutMessages(ctx context.Context, store chain.BlockProvider, head types.TipSet) ([]address.Address, error) {
 	var err error
 
 	lowestTipSet := head
 	minimumHeight, err := lowestTipSet.Height()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// walk back MessageTimeout tip sets to arrive at the lowest viable block height
-	for i := 0; minimumHeight > 0 && i < MessageTimeOut; i++ {
+	for i := uint64(0); minimumHeight > 0 && i < pool.MessageTimeOut(); i++ {
 		lowestTipSet, err = chain.GetParentTipSet(ctx, store, lowestTipSet)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		minimumHeight, err = lowestTipSet.Height()
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	// remove all messages added before minimumHeight
+	var senders []address.Address
 	for _, cid := range pool.messagesToTimeOut(minimumHeight) {
-		pool.Remove(cid)
+		msg, ok := pool.Get(cid)
+		if !ok {
+			continue
+		}
+		if pool.isLocal(cid) {
+			log.Warningf("message %s sent from this node expired un-mined after %d tipsets", cid, pool.MessageTimeOut())
+		}
+		pool.drop(cid, MessagePoolRemove, RemoveReasonExpired)
+		senders = append(senders, msg.From)
 	}
 
-	return nil
+	return senders, nil
+}
+
+// isLocal returns true if the message with cid c was submitted through this
+// node.
+func (pool *MessagePool) isLocal(c cid.Cid) bool {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	msg, ok := pool.pending[c]
+	return ok && msg.local
 }
 
 // identify all messages that need to be timed out
@@ -250,19 +585,125 @@ func (pool *MessagePool) LargestNonce(address address.Address) (largest uint64,
 	return
 }
 
-// validateMessage validates that too many messages aren't added to the pool and the ones that are
-// have a high probability of making it through processing.
-func (pool *MessagePool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
-	if len(pool.pending) >= pool.cfg.MaxPoolSize {
-		return errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+// NextNonce returns the nonce of the next message from addr: one more than the
+// highest nonce of its messages in the pool, queued ones included, or the
+// nonce of its actor on chain if higher.  Without a way to read actor nonces
+// only the messages in the pool are considered.
+func (pool *MessagePool) NextNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	var next uint64
+	if nr, ok := pool.validator.(actorNonceReader); ok {
+		actorNonce, err := nr.ActorNonce(ctx, addr)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to read nonce of %s", addr)
+		}
+		next = actorNonce
+	}
+
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	if msgs := pool.bySender[addr]; len(msgs) > 0 {
+		if last := uint64(msgs[len(msgs)-1].message.Nonce); last >= next {
+			next = last + 1
+		}
+	}
+	return next, nil
+}
+
+// PendingSpend returns the most the pending messages from address can cost
+// it, i.e. the sum of their values and gas limit charges.
+func (pool *MessagePool) PendingSpend(address address.Address) *types.AttoFIL {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	return pool.pendingSpend(address)
+}
+
+// pendingSpend is PendingSpend without locking. pool.lk must be held.
+func (pool *MessagePool) pendingSpend(address address.Address) *types.AttoFIL {
+	spend := types.ZeroAttoFIL
+	for _, tm := range pool.bySender[address] {
+		spend = spend.Add(tm.message.MaxCost())
+	}
+	return spend
+}
+
+// senderCount returns the number of pending messages from sender.
+// pool.lk must be held.
+func (pool *MessagePool) senderCount(sender address.Address) int {
+	return len(pool.bySender[sender])
+}
+
+func (pool *MessagePool) maxSenderPoolSize() int {
+	if pool.cfg.MaxSenderPoolSize == 0 {
+		return DefaultMaxSenderPoolSize
+	}
+	return pool.cfg.MaxSenderPoolSize
+}
+
+// evictionCandidate returns the cid of the message msg evicts from the full
+// pool, or cid.Undef if there is none: the message with the lowest gas price,
+// oldest first, among the messages received from the network that are the
+// last of their senders, if msg pays a higher gas price.  Messages from the
+// sender of msg are not evicted.  Only the last message of each sender is
+// visited.  pool.lk must be held.
+func (pool *MessagePool) evictionCandidate(msg *types.SignedMessage) cid.Cid {
+	var lowest *timedmessage
+	for sender, msgs := range pool.bySender {
+		tm := msgs[len(msgs)-1]
+		if sender == msg.From || tm.local {
+			continue
+		}
+		if lowest == nil || tm.message.GasPrice.LessThan(&lowest.message.GasPrice) ||
+			(tm.message.GasPrice.Equal(&lowest.message.GasPrice) && tm.addedAt < lowest.addedAt) {
+			lowest = tm
+		}
+	}
+	if lowest == nil || !lowest.message.GasPrice.LessThan(&msg.GasPrice) {
+		return cid.Undef
 	}
+	return pool.addressNonces[newAddressNonce(lowest.message)]
+}
+
+// MinReplacementGasPrice returns the least gas price of a message replacing
+// msg, a pending message with the same sender and nonce: the gas price of msg
+// raised by the configured percentage, and at least by one attoFIL.
+func (pool *MessagePool) MinReplacementGasPrice(msg *types.SignedMessage) *types.AttoFIL {
+	percent := pool.cfg.ReplaceByFeePercent
+	if percent == 0 {
+		percent = DefaultReplaceByFeePercent
+	}
+	min := msg.GasPrice.MulBigInt(big.NewInt(int64(100 + percent))).DivCeil(types.NewAttoFIL(big.NewInt(100)))
+	if next := msg.GasPrice.Add(types.NewAttoFIL(big.NewInt(1))); min.LessThan(next) {
+		return next
+	}
+	return min
+}
 
-	// check that message with this nonce does not already exist
-	_, found := pool.addressNonces[newAddressNonce(message)]
-	if found {
-		return errors.Errorf("message pool contains message with same actor and nonce but different cid")
+// validateMessage validates that too many messages aren't added to the pool and the ones that are
+// have a high probability of making it through processing.  It returns the
+// cid of the pending message the message replaces, if any.
+func (pool *MessagePool) validateMessage(ctx context.Context, message *types.SignedMessage) (cid.Cid, error) {
+	// check that message with this nonce does not already exist, unless the
+	// message pays enough more gas to replace it
+	replaced := cid.Undef
+	pendingSpend := pool.pendingSpend(message.From)
+	if existing, found := pool.addressNonces[newAddressNonce(message)]; found {
+		old := pool.pending[existing].message
+		if min := pool.MinReplacementGasPrice(old); message.GasPrice.LessThan(min) {
+			return cid.Undef, consensus.NewMessageRejection(consensus.RejectDuplicateNonce, "message pool contains message with same actor and nonce but different cid, replacing it requires a gas price of at least %s", min)
+		}
+		replaced = existing
+		pendingSpend = pendingSpend.Sub(old.MaxCost())
+	} else if count := pool.senderCount(message.From); count >= pool.maxSenderPoolSize() {
+		return cid.Undef, consensus.NewMessageRejection(consensus.RejectSenderLimit, "message pool holds the maximum of %d messages from %s", count, message.From)
 	}
 
 	// check that the message is likely to succeed in processing
-	return pool.validator.Validate(ctx, message)
+	if err := pool.validator.Validate(ctx, message); err != nil {
+		return cid.Undef, err
+	}
+
+	// check that the sender can pay for this message along with all its
+	// other pending messages
+	return replaced, pool.validator.ValidateSpend(ctx, message, pendingSpend)
 }
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+)
+
+var (
+	mpEventTypeTagKey, _ = tag.NewKey("type")
+	mpEventsCt           = metrics.NewInt64Counter("message_pool_events", "Number of changes to the message pool, by type", mpEventTypeTagKey)
+)
+
+// MessagePoolEventType is the kind of change to the message pool an event
+// reports.
+type MessagePoolEventType string
+
+const (
+	// MessagePoolAdd reports a message added to the pool.
+	MessagePoolAdd = MessagePoolEventType("add")
+	// MessagePoolRemove reports a message dropped from the pool without
+	// being mined, e.g. because it expired or was evicted.
+	MessagePoolRemove = MessagePoolEventType("remove")
+	// MessagePoolReplace reports a message replaced by a message with the
+	// same sender and nonce paying a higher gas price.
+	MessagePoolReplace = MessagePoolEventType("replace")
+	// MessagePoolMined reports a message removed from the pool because it is
+	// included in the new head of the chain.
+	MessagePoolMined = MessagePoolEventType("mined")
+)
+
+// AddReasonReorg is the reason of MessagePoolAdd events of messages returning
+// to the pool from blocks a reorg dropped from the chain.
+const AddReasonReorg = "reorg"
+
+// Reasons of MessagePoolRemove events.
+const (
+	RemoveReasonRequested = "requested"
+	RemoveReasonExpired   = "expired"
+	RemoveReasonEvicted   = "evicted"
+)
+
+// messagePoolEventBuffer is the number of events a subscriber may fall behind
+// by before it is dropped.
+const messagePoolEventBuffer = 256
+
+// MessagePoolEvent describes a change to the message pool.
+type MessagePoolEvent struct {
+	Type MessagePoolEventType `json:"type"`
+	// Cid is the cid of the message added or removed.
+	Cid cid.Cid `json:"cid"`
+	// Reason details the change, e.g. why a message was removed or, for a
+	// replacement, the cid of the replacing message.
+	Reason string `json:"reason,omitempty"`
+	// Height is the block height at which the pool received the message.
+	Height uint64 `json:"height"`
+}
+
+// messagePoolEvents fans the pool's events out to subscribers.
+type messagePoolEvents struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]chan MessagePoolEvent
+}
+
+// emit sends ev to every subscriber without blocking.  A subscriber whose
+// buffer is full is dropped and its channel closed, so that it notices it
+// missed events.
+func (e *messagePoolEvents) emit(ev MessagePoolEvent) {
+	if ctx, err := tag.New(context.Background(), tag.Upsert(mpEventTypeTagKey, string(ev.Type))); err == nil {
+		mpEventsCt.Inc(ctx, 1)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for id, ch := range e.subs {
+		select {
+		case ch <- ev:
+		default:
+			log.Warningf("dropping message pool subscriber %d that fell behind by %d events", id, messagePoolEventBuffer)
+			delete(e.subs, id)
+			close(ch)
+		}
+	}
+}
+
+func (e *messagePoolEvents) subscribe(ctx context.Context) <-chan MessagePoolEvent {
+	ch := make(chan MessagePoolEvent, messagePoolEventBuffer)
+
+	e.mu.Lock()
+	if e.subs == nil {
+		e.subs = make(map[int]chan MessagePoolEvent)
+	}
+	id := e.nextID
+	e.nextID++
+	e.subs[id] = ch
+	e.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		// The subscriber may have been dropped already.
+		if _, ok := e.subs[id]; ok {
+			delete(e.subs, id)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+// Subscribe returns a channel receiving an event for every message added to
+// or removed from the pool from now on.  The channel is closed when ctx is
+// done, or when the receiver falls too far behind, after which it can
+// resynchronize with Pending and subscribe again.
+func (pool *MessagePool) Subscribe(ctx context.Context) <-chan MessagePoolEvent {
+	return pool.events.subscribe(ctx)
+}
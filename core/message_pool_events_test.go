@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolSubscribe(t *testing.T) {
+	tf.UnitTest(t)
+
+	newPool := func() *MessagePool {
+		return NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	}
+
+	t.Run("reports additions, replacements and removals", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		pool := newPool()
+		events := pool.Subscribe(ctx)
+
+		base := newSignedMessage().Message
+		withGasPrice := func(price int64) *types.SignedMessage {
+			smsg, err := types.NewSignedMessage(base, &mockSigner, types.NewGasPrice(price), types.NewGasUnits(0))
+			require.NoError(t, err)
+			return smsg
+		}
+
+		orig, err := pool.Add(ctx, withGasPrice(1))
+		require.NoError(t, err)
+		replacement, err := pool.Add(ctx, withGasPrice(2))
+		require.NoError(t, err)
+		pool.Remove(replacement)
+
+		assert.Equal(t, MessagePoolEvent{Type: MessagePoolAdd, Cid: orig}, <-events)
+		assert.Equal(t, MessagePoolEvent{Type: MessagePoolReplace, Cid: orig, Reason: replacement.String()}, <-events)
+		assert.Equal(t, MessagePoolEvent{Type: MessagePoolAdd, Cid: replacement}, <-events)
+		assert.Equal(t, MessagePoolEvent{Type: MessagePoolRemove, Cid: replacement, Reason: RemoveReasonRequested}, <-events)
+	})
+
+	t.Run("reports mined messages", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		store := hamt.NewCborStore()
+		pool := newPool()
+
+		m := types.NewSignedMsgs(1, mockSigner)
+		MustAdd(pool, m[0])
+		c, err := m[0].Cid()
+		require.NoError(t, err)
+		events := pool.Subscribe(ctx)
+
+		parent := types.TipSet{}
+		blk := types.Block{Height: 0}
+		parent[blk.Cid()] = &blk
+		oldTipSet := headOf(NewChainWithMessages(store, parent, [][]*types.SignedMessage{}))
+		newTipSet := headOf(NewChainWithMessages(store, parent, [][]*types.SignedMessage{{m[0]}}))
+		require.NoError(t, pool.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+
+		assert.Equal(t, MessagePoolEvent{Type: MessagePoolMined, Cid: c}, <-events)
+	})
+
+	t.Run("closes the channel when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := newPool().Subscribe(ctx)
+		cancel()
+		_, ok := <-events
+		assert.False(t, ok)
+	})
+
+	t.Run("drops subscribers that fall behind", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		pool := newPool()
+		events := pool.Subscribe(ctx)
+
+		for i := 0; i <= messagePoolEventBuffer; i++ {
+			pool.events.emit(MessagePoolEvent{Type: MessagePoolAdd})
+		}
+		received := 0
+		for range events {
+			received++
+		}
+		assert.Equal(t, messagePoolEventBuffer, received)
+	})
+}
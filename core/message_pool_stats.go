@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var mpBytes = metrics.NewInt64Gauge("message_pool_bytes", "The size in bytes of the messages in the message pool")
+
+// MessagePoolFilter selects messages of the pool.  Unset fields match any
+// message.
+type MessagePoolFilter struct {
+	From   address.Address
+	To     address.Address
+	Method string
+}
+
+// Matches returns true if msg passes the filter.
+func (f MessagePoolFilter) Matches(msg *types.SignedMessage) bool {
+	if !f.From.Empty() && msg.From != f.From {
+		return false
+	}
+	if !f.To.Empty() && msg.To != f.To {
+		return false
+	}
+	return f.Method == "" || msg.Method == f.Method
+}
+
+// GasPriceDistribution summarizes the gas prices of messages.
+type GasPriceDistribution struct {
+	Min    *types.AttoFIL `json:"min"`
+	Median *types.AttoFIL `json:"median"`
+	Max    *types.AttoFIL `json:"max"`
+}
+
+// MessagePoolStats describes the contents of the message pool.
+type MessagePoolStats struct {
+	// Count is the number of messages in the pool, of which Queued wait
+	// for messages with lower nonces from their senders.
+	Count  int `json:"count"`
+	Queued int `json:"queued"`
+	// Senders is the number of distinct senders of the messages.
+	Senders int `json:"senders"`
+	// Bytes is the size of the messages in the pool.
+	Bytes int `json:"bytes"`
+	// GasPrice is unset when the pool is empty.
+	GasPrice *GasPriceDistribution `json:"gasPrice,omitempty"`
+	// Ages of the messages, in block heights since the pool received them.
+	AgeMedian uint64 `json:"ageMedian"`
+	AgeP90    uint64 `json:"ageP90"`
+	AgeMax    uint64 `json:"ageMax"`
+}
+
+// Select returns the messages in the pool, queued ones included, that pass f.
+func (pool *MessagePool) Select(f MessagePoolFilter) []*types.SignedMessage {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	var out []*types.SignedMessage
+	for _, tm := range pool.pending {
+		if f.Matches(tm.message) {
+			out = append(out, tm.message)
+		}
+	}
+	return out
+}
+
+// Stats describes the messages in the pool that pass f.
+func (pool *MessagePool) Stats(f MessagePoolFilter) (MessagePoolStats, error) {
+	height, err := pool.api.BlockHeight()
+	if err != nil {
+		return MessagePoolStats{}, err
+	}
+
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	var stats MessagePoolStats
+	senders := make(map[address.Address]struct{})
+	var prices []*types.AttoFIL
+	var ages []uint64
+	for _, tm := range pool.pending {
+		if !f.Matches(tm.message) {
+			continue
+		}
+		stats.Count++
+		if tm.queued {
+			stats.Queued++
+		}
+		senders[tm.message.From] = struct{}{}
+		stats.Bytes += tm.size
+		prices = append(prices, &tm.message.GasPrice)
+		if height > tm.addedAt {
+			ages = append(ages, height-tm.addedAt)
+		} else {
+			ages = append(ages, 0)
+		}
+	}
+	stats.Senders = len(senders)
+	if len(prices) == 0 {
+		return stats, nil
+	}
+
+	sort.Slice(prices, func(i, j int) bool { return prices[i].LessThan(prices[j]) })
+	stats.GasPrice = &GasPriceDistribution{
+		Min:    prices[0],
+		Median: prices[percentileIndex(len(prices), 50)],
+		Max:    prices[len(prices)-1],
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+	stats.AgeMedian = ages[percentileIndex(len(ages), 50)]
+	stats.AgeP90 = ages[percentileIndex(len(ages), 90)]
+	stats.AgeMax = ages[len(ages)-1]
+	return stats, nil
+}
+
+// percentileIndex returns the index of the pth percentile of n sorted values,
+// using the nearest rank.
+func percentileIndex(n, p int) int {
+	rank := (p*n + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return rank - 1
+}
+
+// updateSizeMetrics records the number and size of the messages in the pool.
+// pool.lk must be held.
+func (pool *MessagePool) updateSizeMetrics(ctx context.Context) {
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	mpBytes.Set(ctx, int64(pool.bytes))
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolStats(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	api := th.NewTestMessagePoolAPI(0)
+	pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	// Three messages from one sender received at heights 0, 1 and 2, and one
+	// from another sender calling a method at height 2.
+	add := func(sender int, nonce uint64, price int64, method string, height uint64) *types.SignedMessage {
+		msg := newSignedMessage().Message
+		msg.From = mockSigner.Addresses[sender]
+		msg.Nonce = types.Uint64(nonce)
+		msg.Method = method
+		smsg, err := types.NewSignedMessage(msg, &mockSigner, types.NewGasPrice(price), types.NewGasUnits(0))
+		require.NoError(t, err)
+		api.Height = height
+		_, err = pool.Add(ctx, smsg)
+		require.NoError(t, err)
+		return smsg
+	}
+	msgs := []*types.SignedMessage{
+		add(0, 0, 3, "", 0),
+		add(0, 1, 1, "", 1),
+		add(0, 2, 4, "", 2),
+		add(1, 0, 2, "foo", 2),
+	}
+	api.Height = 10
+
+	gasPrice := func(price int64) *types.AttoFIL {
+		p := types.NewGasPrice(price)
+		return &p
+	}
+	size := 0
+	for _, msg := range msgs {
+		data, err := msg.Marshal()
+		require.NoError(t, err)
+		size += len(data)
+	}
+
+	t.Run("describes all messages", func(t *testing.T) {
+		stats, err := pool.Stats(MessagePoolFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, stats.Count)
+		assert.Equal(t, 2, stats.Senders)
+		assert.Equal(t, size, stats.Bytes)
+		require.NotNil(t, stats.GasPrice)
+		assert.True(t, gasPrice(1).Equal(stats.GasPrice.Min))
+		assert.True(t, gasPrice(2).Equal(stats.GasPrice.Median))
+		assert.True(t, gasPrice(4).Equal(stats.GasPrice.Max))
+		assert.Equal(t, uint64(8), stats.AgeMedian)
+		assert.Equal(t, uint64(10), stats.AgeP90)
+		assert.Equal(t, uint64(10), stats.AgeMax)
+	})
+
+	t.Run("describes filtered messages", func(t *testing.T) {
+		stats, err := pool.Stats(MessagePoolFilter{Method: "foo"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Count)
+		assert.Equal(t, uint64(8), stats.AgeMax)
+
+		stats, err = pool.Stats(MessagePoolFilter{From: mockSigner.Addresses[2]})
+		require.NoError(t, err)
+		assert.Equal(t, 0, stats.Count)
+		assert.Nil(t, stats.GasPrice)
+	})
+
+	t.Run("selects messages", func(t *testing.T) {
+		selected := pool.Select(MessagePoolFilter{From: mockSigner.Addresses[1]})
+		require.Len(t, selected, 1)
+		assert.True(t, msgs[3].Equals(selected[0]))
+
+		assert.Len(t, pool.Select(MessagePoolFilter{To: msgs[0].To, From: mockSigner.Addresses[0]}), 1)
+	})
+
+	t.Run("removing messages reduces the size", func(t *testing.T) {
+		c, err := msgs[3].Cid()
+		require.NoError(t, err)
+		pool.Remove(c)
+
+		stats, err := pool.Stats(MessagePoolFilter{})
+		require.NoError(t, err)
+		data, err := msgs[3].Marshal()
+		require.NoError(t, err)
+		assert.Equal(t, size-len(data), stats.Bytes)
+	})
+}
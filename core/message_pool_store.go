@@ -0,0 +1,177 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func init() {
+	cbor.RegisterCborType(persistedMessage{})
+}
+
+// MessagePoolPrefix is the datastore prefix for the messages persisted by the
+// message pool.
+const MessagePoolPrefix = "mpool"
+
+// persistedMessage is the record of a message persisted by the pool.
+type persistedMessage struct {
+	Message *types.SignedMessage
+	// Local is true for messages submitted through this node.
+	Local bool
+}
+
+func persistedMessageKey(c cid.Cid) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{MessagePoolPrefix, c.String()})
+}
+
+// Persist makes the pool keep its messages in ds so that they survive a
+// restart: the messages submitted locally and, if all is true, the messages
+// received from the network too.  Messages are deleted from ds when they
+// leave the pool.  It must be called before messages are added.
+func (pool *MessagePool) Persist(ds datastore.Datastore, all bool) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.ds = ds
+	pool.persistAll = all
+}
+
+// AddLocal adds a message submitted through this node to the pool.  Unlike
+// messages received from the network, local messages are always persisted if
+// the pool persists messages.
+func (pool *MessagePool) AddLocal(ctx context.Context, msg *types.SignedMessage) (cid.Cid, error) {
+	blockTime, err := pool.api.BlockHeight()
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	c, promoted, err := pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: blockTime, local: true})
+	if err != nil {
+		return cid.Undef, err
+	}
+	pool.promote(promoted)
+	return c, nil
+}
+
+// LoadPersisted adds the messages persisted before the node stopped back to
+// the pool.  They are validated again against the current head, and those no
+// longer valid, most likely mined meanwhile, are dropped.  It returns the
+// local messages loaded, ordered by sender and nonce.
+func (pool *MessagePool) LoadPersisted(ctx context.Context) ([]*types.SignedMessage, error) {
+	pool.lk.RLock()
+	ds := pool.ds
+	pool.lk.RUnlock()
+	if ds == nil {
+		return nil, nil
+	}
+
+	results, err := ds.Query(query.Query{Prefix: "/" + MessagePoolPrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query persisted messages")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query persisted messages")
+	}
+	blockTime, err := pool.api.BlockHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []persistedMessage
+	for _, entry := range entries {
+		var rec persistedMessage
+		if err := cbor.DecodeInto(entry.Value, &rec); err != nil || rec.Message == nil {
+			log.Warningf("dropping malformed persisted message %s", entry.Key)
+			if err := ds.Delete(datastore.NewKey(entry.Key)); err != nil {
+				return nil, errors.Wrap(err, "failed to delete persisted message")
+			}
+			continue
+		}
+		records = append(records, rec)
+	}
+	// Add the messages of each sender in nonce order, so that none waits
+	// for a nonce gap while the rest are loaded.
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i].Message, records[j].Message
+		if a.From != b.From {
+			return bytes.Compare(a.From.Bytes(), b.From.Bytes()) < 0
+		}
+		return a.Nonce < b.Nonce
+	})
+
+	var local []*types.SignedMessage
+	var promoted []*types.SignedMessage
+	loaded := 0
+	for _, rec := range records {
+		c, err := rec.Message.Cid()
+		if err != nil {
+			return nil, err
+		}
+		_, p, err := pool.addTimedMessage(ctx, &timedmessage{message: rec.Message, addedAt: blockTime, local: rec.Local})
+		// The record is kept only if the pool took the message back and
+		// still persists it.
+		if err != nil || !pool.isPersisted(c) {
+			if err := ds.Delete(persistedMessageKey(c)); err != nil {
+				return nil, errors.Wrap(err, "failed to delete persisted message")
+			}
+		}
+		if err != nil {
+			log.Infof("dropping persisted message %s: %s", c, err)
+			continue
+		}
+		loaded++
+		promoted = append(promoted, p...)
+		if rec.Local {
+			local = append(local, rec.Message)
+		}
+	}
+	pool.promote(promoted)
+	log.Infof("loaded %d of %d persisted messages into the message pool", loaded, len(records))
+	return local, nil
+}
+
+// persist writes tm to the pool's datastore if the pool persists it.
+// pool.lk must be held.
+func (pool *MessagePool) persist(c cid.Cid, tm *timedmessage) error {
+	if pool.ds == nil || !(tm.local || pool.persistAll) {
+		return nil
+	}
+	datum, err := cbor.DumpObject(persistedMessage{Message: tm.message, Local: tm.local})
+	if err != nil {
+		return errors.Wrap(err, "could not marshal message")
+	}
+	if err := pool.ds.Put(persistedMessageKey(c), datum); err != nil {
+		return errors.Wrap(err, "could not persist message")
+	}
+	tm.persisted = true
+	return nil
+}
+
+// isPersisted returns true if the message c is in the pool and in its
+// datastore.
+func (pool *MessagePool) isPersisted(c cid.Cid) bool {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	tm, ok := pool.pending[c]
+	return ok && tm.persisted
+}
+
+// unpersist deletes tm from the pool's datastore if it was persisted.
+// pool.lk must be held.
+func (pool *MessagePool) unpersist(c cid.Cid, tm *timedmessage) {
+	if !tm.persisted {
+		return
+	}
+	if err := pool.ds.Delete(persistedMessageKey(c)); err != nil {
+		log.Warningf("failed to delete persisted message %s: %s", c, err)
+	}
+}
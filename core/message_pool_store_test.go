@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolPersistence(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	newPool := func(ds datastore.Datastore, all bool, validator MessagePoolValidator) *MessagePool {
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+		pool.Persist(ds, all)
+		return pool
+	}
+	persisted := func(ds datastore.Datastore) int {
+		results, err := ds.Query(query.Query{Prefix: "/" + MessagePoolPrefix})
+		require.NoError(t, err)
+		entries, err := results.Rest()
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	t.Run("reloads local messages", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		pool := newPool(ds, false, th.NewMockMessagePoolValidator())
+		local := mustSetNonce(mockSigner, newSignedMessage(), 0)
+		remote := mustSetNonce(mockSigner, newSignedMessage(), 1)
+		_, err := pool.AddLocal(ctx, local)
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, remote)
+		require.NoError(t, err)
+		assert.Equal(t, 1, persisted(ds))
+
+		restarted := newPool(ds, false, th.NewMockMessagePoolValidator())
+		loaded, err := restarted.LoadPersisted(ctx)
+		require.NoError(t, err)
+		require.Len(t, loaded, 1)
+		assert.True(t, types.SmsgCidsEqual(local, loaded[0]))
+		assertPoolEquals(t, restarted, local)
+	})
+
+	t.Run("reloads all messages if configured to", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		pool := newPool(ds, true, th.NewMockMessagePoolValidator())
+		local := mustSetNonce(mockSigner, newSignedMessage(), 0)
+		remote := mustSetNonce(mockSigner, newSignedMessage(), 1)
+		_, err := pool.AddLocal(ctx, local)
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, remote)
+		require.NoError(t, err)
+
+		restarted := newPool(ds, true, th.NewMockMessagePoolValidator())
+		loaded, err := restarted.LoadPersisted(ctx)
+		require.NoError(t, err)
+		assert.Len(t, loaded, 1)
+		assertPoolEquals(t, restarted, local, remote)
+		assert.Equal(t, 2, persisted(ds))
+
+		// Loading again leaves the records of the messages already in the
+		// pool in place.
+		_, err = restarted.LoadPersisted(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, persisted(ds))
+	})
+
+	t.Run("forgets messages removed from the pool", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		pool := newPool(ds, false, th.NewMockMessagePoolValidator())
+		c, err := pool.AddLocal(ctx, newSignedMessage())
+		require.NoError(t, err)
+		assert.Equal(t, 1, persisted(ds))
+
+		pool.Remove(c)
+		assert.Equal(t, 0, persisted(ds))
+	})
+
+	t.Run("drops messages no longer valid", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		pool := newPool(ds, false, th.NewMockMessagePoolValidator())
+		_, err := pool.AddLocal(ctx, newSignedMessage())
+		require.NoError(t, err)
+
+		validator := th.NewMockMessagePoolValidator()
+		validator.Valid = false
+		restarted := newPool(ds, false, validator)
+		loaded, err := restarted.LoadPersisted(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, loaded)
+		assertPoolEquals(t, restarted)
+		assert.Equal(t, 0, persisted(ds))
+	})
+}
@@ -69,6 +69,7 @@ func TestMessagePoolValidate(t *testing.T) {
 		// pull the default size from the default config value
 		mpoolCfg := config.NewDefaultConfig().Mpool
 		maxMessagePoolSize := mpoolCfg.MaxPoolSize
+		mpoolCfg.MaxSenderPoolSize = maxMessagePoolSize + 1
 		ctx := context.Background()
 		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), mpoolCfg, th.NewMockMessagePoolValidator())
 
@@ -102,6 +103,19 @@ func TestMessagePoolValidate(t *testing.T) {
 		assert.Contains(t, err.Error(), "message with same actor and nonce")
 	})
 
+	t.Run("rejects messages too large for a block", func(t *testing.T) {
+		ctx := context.Background()
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		msg := types.NewMessage(mockSigner.Addresses[0], mockSigner.Addresses[1], 0, types.ZeroAttoFIL, "", make([]byte, types.MessageSizeLimit))
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, smsg)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds limit")
+		assert.Len(t, pool.Pending(), 0)
+	})
+
 	t.Run("validates using supplied validator", func(t *testing.T) {
 		ctx := context.Background()
 		api := th.NewTestMessagePoolAPI(0)
@@ -114,6 +128,256 @@ func TestMessagePoolValidate(t *testing.T) {
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "mock validation error")
 	})
+
+	t.Run("evicts the cheapest message from another sender when full", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPoolSize = 3
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), mpoolCfg, th.NewMockMessagePoolValidator())
+
+		fromWithGasPrice := func(sender int, nonce uint64, price int64) *types.SignedMessage {
+			msg := newSignedMessage().Message
+			msg.From = mockSigner.Addresses[sender]
+			msg.Nonce = types.Uint64(nonce)
+			smsg, err := types.NewSignedMessage(msg, &mockSigner, types.NewGasPrice(price), types.NewGasUnits(0))
+			require.NoError(t, err)
+			return smsg
+		}
+
+		cheap0 := fromWithGasPrice(0, 0, 1)
+		cheap1 := fromWithGasPrice(0, 1, 5)
+		other := fromWithGasPrice(1, 0, 2)
+		for _, msg := range []*types.SignedMessage{cheap0, cheap1, other} {
+			_, err := pool.Add(ctx, msg)
+			require.NoError(t, err)
+		}
+
+		// Only the last message of each sender may be evicted, and it must
+		// pay less than the new message.
+		_, err := pool.Add(ctx, fromWithGasPrice(2, 0, 2))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message pool is full")
+
+		// Messages from the sender of the new message are not evicted.
+		_, err = pool.Add(ctx, fromWithGasPrice(1, 1, 3))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message pool is full")
+
+		rich := fromWithGasPrice(2, 0, 3)
+		_, err = pool.Add(ctx, rich)
+		require.NoError(t, err)
+		assertPoolEquals(t, pool, cheap0, cheap1, rich)
+	})
+
+	t.Run("rejects messages past the sender's limit", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxSenderPoolSize = 2
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), mpoolCfg, th.NewMockMessagePoolValidator())
+
+		smsgs := types.NewSignedMsgs(3, mockSigner)
+		for _, smsg := range smsgs[:2] {
+			_, err := pool.Add(ctx, smsg)
+			require.NoError(t, err)
+		}
+		_, err := pool.Add(ctx, smsgs[2])
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "maximum of 2 messages")
+	})
+
+	t.Run("validates spend against the sender's pending messages", func(t *testing.T) {
+		ctx := context.Background()
+		validator := &balanceValidator{balance: types.NewAttoFILFromFIL(10)}
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+
+		withValue := func(nonce uint64, fil uint64) *types.SignedMessage {
+			return mustResignMessage(mockSigner, newSignedMessage(), func(m *types.Message) {
+				m.Nonce = types.Uint64(nonce)
+				m.Value = types.NewAttoFILFromFIL(fil)
+			})
+		}
+
+		_, err := pool.Add(ctx, withValue(0, 6))
+		require.NoError(t, err)
+		assert.True(t, types.NewAttoFILFromFIL(6).Equal(pool.PendingSpend(mockSigner.Addresses[0])))
+		assert.True(t, pool.PendingSpend(mockSigner.Addresses[1]).IsZero())
+
+		_, err = pool.Add(ctx, withValue(1, 5))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "insufficient balance")
+
+		_, err = pool.Add(ctx, withValue(1, 4))
+		require.NoError(t, err)
+		assert.True(t, types.NewAttoFILFromFIL(10).Equal(pool.PendingSpend(mockSigner.Addresses[0])))
+	})
+
+	t.Run("replaces messages with same nonce paying enough more gas", func(t *testing.T) {
+		ctx := context.Background()
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		base := newSignedMessage().Message
+		withGasPrice := func(price int64) *types.SignedMessage {
+			smsg, err := types.NewSignedMessage(base, &mockSigner, types.NewGasPrice(price), types.NewGasUnits(0))
+			require.NoError(t, err)
+			return smsg
+		}
+
+		orig := withGasPrice(100)
+		origCid, err := pool.Add(ctx, orig)
+		require.NoError(t, err)
+		minPrice := types.NewGasPrice(125)
+		assert.True(t, minPrice.Equal(pool.MinReplacementGasPrice(orig)))
+
+		_, err = pool.Add(ctx, withGasPrice(124))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires a gas price of at least")
+		assertPoolEquals(t, pool, orig)
+
+		replacement := withGasPrice(125)
+		_, err = pool.Add(ctx, replacement)
+		require.NoError(t, err)
+		assertPoolEquals(t, pool, replacement)
+		_, ok := pool.Get(origCid)
+		assert.False(t, ok)
+	})
+}
+
+func TestMessagePoolQueuesNonceGaps(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	sender := mockSigner.Addresses[0]
+
+	setup := func() (*MessagePool, *nonceValidator, *[]*types.SignedMessage) {
+		validator := &nonceValidator{
+			MockMessagePoolValidator: th.MockMessagePoolValidator{Valid: true},
+			nonces:                   map[address.Address]uint64{sender: 5},
+		}
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+		var promoted []*types.SignedMessage
+		pool.OnPromote(func(msgs []*types.SignedMessage) {
+			promoted = append(promoted, msgs...)
+		})
+		return pool, validator, &promoted
+	}
+
+	t.Run("queues messages until the gap fills", func(t *testing.T) {
+		pool, _, promoted := setup()
+		msg5 := mustSetNonce(mockSigner, newSignedMessage(), 5)
+		msg6 := mustSetNonce(mockSigner, newSignedMessage(), 6)
+		msg7 := mustSetNonce(mockSigner, newSignedMessage(), 7)
+
+		c7, err := pool.Add(ctx, msg7)
+		require.NoError(t, err)
+		assert.True(t, pool.IsQueued(c7))
+		assertPoolEquals(t, pool)
+		assert.Len(t, pool.Queued(), 1)
+
+		MustAdd(pool, msg5)
+		assertPoolEquals(t, pool, msg5)
+		assert.Empty(t, *promoted)
+
+		MustAdd(pool, msg6)
+		assertPoolEquals(t, pool, msg5, msg6, msg7)
+		assert.False(t, pool.IsQueued(c7))
+		assert.Empty(t, pool.Queued())
+		require.Len(t, *promoted, 1)
+		assert.True(t, types.SmsgCidsEqual(msg7, (*promoted)[0]))
+	})
+
+	t.Run("promotes messages when the sender's nonce catches up", func(t *testing.T) {
+		pool, validator, promoted := setup()
+		msg7 := mustSetNonce(mockSigner, newSignedMessage(), 7)
+		MustAdd(pool, msg7)
+		assertPoolEquals(t, pool)
+
+		// Messages 5 and 6 were mined without passing through the pool.
+		validator.nonces[sender] = 7
+		pool.promote(pool.updateQueuedSenders(ctx, map[address.Address]struct{}{sender: {}}))
+		assertPoolEquals(t, pool, msg7)
+		require.Len(t, *promoted, 1)
+	})
+
+	t.Run("promotes messages when their sender's messages are mined", func(t *testing.T) {
+		pool, validator, promoted := setup()
+		msg5 := mustSetNonce(mockSigner, newSignedMessage(), 5)
+		msg6 := mustSetNonce(mockSigner, newSignedMessage(), 6)
+		msg7 := mustSetNonce(mockSigner, newSignedMessage(), 7)
+		MustAdd(pool, msg7)
+		assertPoolEquals(t, pool)
+
+		store := hamt.NewCborStore()
+		parent := types.TipSet{}
+		blk := types.Block{Height: 0}
+		parent[blk.Cid()] = &blk
+		oldTipSet := headOf(NewChainWithMessages(store, parent, [][]*types.SignedMessage{{}}))
+		newTipSet := headOf(NewChainWithMessages(store, parent, [][]*types.SignedMessage{{msg5, msg6}}))
+
+		validator.nonces[sender] = 7
+		require.NoError(t, pool.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+		assertPoolEquals(t, pool, msg7)
+		require.Len(t, *promoted, 1)
+		assert.True(t, types.SmsgCidsEqual(msg7, (*promoted)[0]))
+	})
+
+	t.Run("does not queue without a way to read nonces", func(t *testing.T) {
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		msg7 := mustSetNonce(mockSigner, newSignedMessage(), 7)
+		MustAdd(pool, msg7)
+		assertPoolEquals(t, pool, msg7)
+	})
+}
+
+// nonceValidator accepts all messages and reads sender nonces from a map.
+func TestMessagePoolNextNonce(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	sender := mockSigner.Addresses[0]
+	validator := &nonceValidator{
+		MockMessagePoolValidator: th.MockMessagePoolValidator{Valid: true},
+		nonces:                   map[address.Address]uint64{sender: 5},
+	}
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+
+	nonce, err := pool.NextNonce(ctx, sender)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), nonce)
+
+	// Queued messages count too.
+	MustAdd(pool, mustSetNonce(mockSigner, newSignedMessage(), 5), mustSetNonce(mockSigner, newSignedMessage(), 7))
+	nonce, err = pool.NextNonce(ctx, sender)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), nonce)
+
+	nonce, err = pool.NextNonce(ctx, mockSigner.Addresses[1])
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), nonce)
+}
+
+type nonceValidator struct {
+	th.MockMessagePoolValidator
+	nonces map[address.Address]uint64
+}
+
+func (v *nonceValidator) ActorNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	return v.nonces[addr], nil
+}
+
+// balanceValidator accepts messages whose senders have a fixed balance.
+type balanceValidator struct {
+	balance *types.AttoFIL
+}
+
+func (v *balanceValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	return nil
+}
+
+func (v *balanceValidator) ValidateSpend(ctx context.Context, msg *types.SignedMessage, pendingSpend *types.AttoFIL) error {
+	if v.balance.LessThan(pendingSpend.Add(msg.MaxCost())) {
+		return errors.New("insufficient balance")
+	}
+	return nil
 }
 
 func TestMessagePoolDedup(t *testing.T) {
@@ -235,6 +499,40 @@ func TestUpdateMessagePool(t *testing.T) {
 		assertPoolEquals(t, p, m[0])
 	})
 
+	t.Run("Reinjects messages of dropped blocks", func(t *testing.T) {
+		// Msg pool: [],       Chain: b[m0, m1]
+		// to
+		// Msg pool: [m0],     Chain: b[m1]
+		store := hamt.NewCborStore()
+		p := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		var reinjected []*types.SignedMessage
+		p.OnReinject(func(msgs []*types.SignedMessage) {
+			reinjected = append(reinjected, msgs...)
+		})
+		events := p.Subscribe(ctx)
+
+		m := types.NewSignedMsgs(2, mockSigner)
+
+		parent := types.TipSet{}
+		blk := types.Block{Height: 0}
+		parent[blk.Cid()] = &blk
+
+		oldTipSet := headOf(NewChainWithMessages(store, parent, msgsSet{msgs{m[0], m[1]}}))
+		newTipSet := headOf(NewChainWithMessages(store, parent, msgsSet{msgs{m[1]}}))
+
+		assert.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+		assertPoolEquals(t, p, m[0])
+		require.Len(t, reinjected, 1)
+		assert.True(t, m[0].Equals(reinjected[0]))
+
+		c, err := m[0].Cid()
+		require.NoError(t, err)
+		ev := <-events
+		assert.Equal(t, MessagePoolAdd, ev.Type)
+		assert.Equal(t, c, ev.Cid)
+		assert.Equal(t, AddReasonReorg, ev.Reason)
+	})
+
 	t.Run("Replace head with self", func(t *testing.T) {
 		// Msg pool: [m0, m1], Chain: b[m2]
 		// to
@@ -524,6 +822,44 @@ func TestUpdateMessagePool(t *testing.T) {
 		assertPoolEquals(t, p, m[5:]...)
 	})
 
+	t.Run("Times out messages after the configured number of tipsets", func(t *testing.T) {
+		var err error
+		store := hamt.NewCborStore()
+		api := th.NewTestMessagePoolAPI(0)
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxMessageAge = 2
+		p := NewMessagePool(api, mpoolCfg, th.NewMockMessagePoolValidator())
+		events := p.Subscribe(ctx)
+
+		m := types.NewSignedMsgs(2, mockSigner)
+		head := headOf(NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}}))
+		for i := 0; i < 2; i++ {
+			api.Height, err = head.Height()
+			require.NoError(t, err)
+			MustAdd(p, m[i])
+
+			next := headOf(NewChainWithMessages(store, head, msgsSet{msgs{}}))
+			assert.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, head, next))
+			assertPoolEquals(t, p, m[:i+1]...)
+			head = next
+		}
+
+		next := headOf(NewChainWithMessages(store, head, msgsSet{msgs{}}))
+		assert.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, head, next))
+		assertPoolEquals(t, p, m[1])
+
+		expired, err := m[0].Cid()
+		require.NoError(t, err)
+		for ev := range events {
+			if ev.Type != MessagePoolAdd {
+				assert.Equal(t, MessagePoolRemove, ev.Type)
+				assert.Equal(t, expired, ev.Cid)
+				assert.Equal(t, RemoveReasonExpired, ev.Reason)
+				break
+			}
+		}
+	})
+
 	t.Run("Message timeout is unaffected by null tipsets", func(t *testing.T) {
 		var err error
 		store := hamt.NewCborStore()
@@ -67,6 +67,22 @@ func (mq *MessageQueue) Enqueue(msg *types.SignedMessage, stamp uint64) error {
 	return nil
 }
 
+// Replace replaces the message in the queue with the sender and nonce of msg
+// by msg, stamped with stamp.  It returns an error if there is no such message.
+func (mq *MessageQueue) Replace(msg *types.SignedMessage, stamp uint64) error {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	for _, qm := range mq.queues[msg.From] {
+		if qm.Msg.Nonce == msg.Nonce {
+			qm.Msg = msg
+			qm.Stamp = stamp
+			return nil
+		}
+	}
+	return errors.Errorf("no message from %s with nonce %d in queue", msg.From, msg.Nonce)
+}
+
 // RemoveNext removes and returns a single message from the queue, if it bears the expected nonce value, with found = true.
 // Returns found = false if the queue is empty or the expected nonce is less than any in the queue for that address
 // (indicating the message had already been removed).
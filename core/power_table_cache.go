@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var (
+	powerCacheHitCt  = metrics.NewInt64Counter("consensus/power_table_cache_hit", "Number of power lookups served from the power table cache")
+	powerCacheMissCt = metrics.NewInt64Counter("consensus/power_table_cache_miss", "Number of power lookups computed from the state")
+)
+
+// DefaultPowerTableCacheSize is the default number of power values kept in a
+// PowerTableCache, enough for the total and miners' power of the recent
+// states validation looks at.
+const DefaultPowerTableCacheSize = 10000
+
+// PowerTableCache is a consensus.PowerTableView remembering the power values
+// another view computes, by state root.  Validating a tipset looks up the
+// power of the same parent state for every block, each time decoding the
+// storage market and miner actors' state; the cache computes them once.
+//
+// States are immutable, so cached values never become stale.  Values of older
+// state roots are evicted as values of new ones are added.
+type PowerTableCache struct {
+	view  consensus.PowerTableView
+	cache *lru.Cache
+}
+
+var _ consensus.PowerTableView = (*PowerTableCache)(nil)
+
+// powerKey identifies a power value: the miner's power in the state with the
+// given root, or the total power if the miner is address.Undef.
+type powerKey struct {
+	root  cid.Cid
+	miner address.Address
+}
+
+// NewPowerTableCache returns a cache of the power values computed by view,
+// holding up to size values.
+func NewPowerTableCache(view consensus.PowerTableView, size int) *PowerTableCache {
+	// lru.New only fails for non-positive sizes, which is a developer error.
+	cache, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+	return &PowerTableCache{view: view, cache: cache}
+}
+
+// Total returns the total bytes stored by all miners in the given state.
+func (c *PowerTableCache) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (*types.BytesAmount, error) {
+	return c.get(ctx, st, address.Undef, func() (*types.BytesAmount, error) {
+		return c.view.Total(ctx, st, bstore)
+	})
+}
+
+// Miner returns the total bytes stored by the miner of the input address in
+// the given state.
+func (c *PowerTableCache) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (*types.BytesAmount, error) {
+	return c.get(ctx, st, mAddr, func() (*types.BytesAmount, error) {
+		return c.view.Miner(ctx, st, bstore, mAddr)
+	})
+}
+
+// HasPower returns true if the input address is associated with a miner that
+// has storage power in the network.
+func (c *PowerTableCache) HasPower(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) bool {
+	numBytes, err := c.Miner(ctx, st, bstore, mAddr)
+	if err != nil {
+		if state.IsActorNotFoundError(err) {
+			return false
+		}
+
+		panic(err) // as consensus.MarketView does
+	}
+
+	return numBytes.IsPositive()
+}
+
+// get returns the cached value for miner in st, computing it with compute if
+// it is not cached.
+func (c *PowerTableCache) get(ctx context.Context, st state.Tree, miner address.Address, compute func() (*types.BytesAmount, error)) (*types.BytesAmount, error) {
+	// The trees that power is looked up in are loaded from a state root and not
+	// modified, so flushing them only computes the root.
+	root, err := st.Flush(ctx)
+	if err != nil {
+		return compute()
+	}
+	key := powerKey{root: root, miner: miner}
+	if v, ok := c.cache.Get(key); ok {
+		powerCacheHitCt.Inc(ctx, 1)
+		return v.(*types.BytesAmount), nil
+	}
+
+	powerCacheMissCt.Inc(ctx, 1)
+	power, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(key, power)
+	return power, nil
+}
@@ -0,0 +1,91 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// countingPowerTableView counts lookups and returns the number of lookups
+// made so far as power.
+type countingPowerTableView struct {
+	calls uint64
+}
+
+func (v *countingPowerTableView) Total(ctx context.Context, st state.Tree, bstore blockstore.Blockstore) (*types.BytesAmount, error) {
+	v.calls++
+	return types.NewBytesAmount(v.calls), nil
+}
+
+func (v *countingPowerTableView) Miner(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) (*types.BytesAmount, error) {
+	v.calls++
+	return types.NewBytesAmount(v.calls), nil
+}
+
+func (v *countingPowerTableView) HasPower(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) bool {
+	panic("not used by the cache")
+}
+
+func TestPowerTableCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	newAddr := address.NewForTestGetter()
+	miner1, miner2 := newAddr(), newAddr()
+	_, st1 := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		newAddr(): th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(1)),
+	})
+	_, st2 := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		newAddr(): th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(2)),
+	})
+
+	t.Run("computes each value of a state once", func(t *testing.T) {
+		view := &countingPowerTableView{}
+		cache := core.NewPowerTableCache(view, 10)
+
+		for i := 0; i < 3; i++ {
+			total, err := cache.Total(ctx, st1, nil)
+			require.NoError(t, err)
+			assert.Equal(t, types.NewBytesAmount(1), total)
+			power, err := cache.Miner(ctx, st1, nil, miner1)
+			require.NoError(t, err)
+			assert.Equal(t, types.NewBytesAmount(2), power)
+		}
+		assert.True(t, cache.HasPower(ctx, st1, nil, miner1))
+
+		power, err := cache.Miner(ctx, st1, nil, miner2)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewBytesAmount(3), power)
+		// Another state has its own values.
+		total, err := cache.Total(ctx, st2, nil)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewBytesAmount(4), total)
+		assert.Equal(t, uint64(4), view.calls)
+	})
+
+	t.Run("evicts the values of older states", func(t *testing.T) {
+		view := &countingPowerTableView{}
+		cache := core.NewPowerTableCache(view, 1)
+
+		_, err := cache.Total(ctx, st1, nil)
+		require.NoError(t, err)
+		_, err = cache.Total(ctx, st2, nil)
+		require.NoError(t, err)
+		total, err := cache.Total(ctx, st1, nil)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewBytesAmount(3), total)
+	})
+}
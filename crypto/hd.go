@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	secp256k1 "github.com/ipsn/go-secp256k1"
+)
+
+// HardenedKeyStart is the index of the first hardened child key.  Hardened
+// keys are derived from the private key of their parent, so that the public
+// key and chain code of a parent don't reveal its hardened children.
+const HardenedKeyStart = uint32(1 << 31)
+
+// ErrInvalidDerivedKey is returned when a derived key is not a valid private
+// key, which happens with a probability lower than 1 in 2^127.  BIP32
+// prescribes moving on to the next index.
+var ErrInvalidDerivedKey = errors.New("derived key is invalid")
+
+var masterKeySecret = []byte("Bitcoin seed")
+
+// NewMasterKey returns the private key and the chain code at the root of the
+// hierarchy of keys derived from seed, as specified by BIP32.
+func NewMasterKey(seed []byte) (key, chainCode []byte, err error) {
+	mac := hmac.New(sha512.New, masterKeySecret)
+	mac.Write(seed) // nolint: errcheck
+	sum := mac.Sum(nil)
+
+	key, chainCode = sum[:32], sum[32:]
+	if !isValidKey(new(big.Int).SetBytes(key)) {
+		return nil, nil, ErrInvalidDerivedKey
+	}
+	return key, chainCode, nil
+}
+
+// DeriveChildKey returns the private key and the chain code of the child at
+// index of the key with chain code chainCode, as specified by BIP32.
+func DeriveChildKey(key, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	var data []byte
+	if index >= HardenedKeyStart {
+		data = append([]byte{0}, key...)
+	} else {
+		data = compressPublicKey(PublicKey(key))
+	}
+	data = append(data, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data) // nolint: errcheck
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(secp256k1.S256().Params().N) >= 0 {
+		return nil, nil, ErrInvalidDerivedKey
+	}
+	child := tweak.Add(tweak, new(big.Int).SetBytes(key))
+	child.Mod(child, secp256k1.S256().Params().N)
+	if !isValidKey(child) {
+		return nil, nil, ErrInvalidDerivedKey
+	}
+
+	childKey := make([]byte, PrivateKeyBytes)
+	blob := child.Bytes()
+	copy(childKey[PrivateKeyBytes-len(blob):], blob)
+	return childKey, sum[32:], nil
+}
+
+// DeriveKeyPath returns the private key at path in the hierarchy of keys
+// derived from seed.
+func DeriveKeyPath(seed []byte, path []uint32) ([]byte, error) {
+	key, chainCode, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		key, chainCode, err = DeriveChildKey(key, chainCode, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func isValidKey(k *big.Int) bool {
+	return k.Sign() > 0 && k.Cmp(secp256k1.S256().Params().N) < 0
+}
+
+// compressPublicKey returns the compressed form of the uncompressed public
+// key pk.
+func compressPublicKey(pk []byte) []byte {
+	x, y := pk[1:33], pk[33:]
+	return append([]byte{2 + y[31]&1}, x...)
+}
@@ -0,0 +1,34 @@
+package crypto_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/crypto"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestDeriveKeyPath(t *testing.T) {
+	tf.UnitTest(t)
+
+	// Test vector 1 of BIP32.
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	key, chainCode, err := crypto.NewMasterKey(seed)
+	require.NoError(t, err)
+	assert.Equal(t, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", hex.EncodeToString(key))
+	assert.Equal(t, "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", hex.EncodeToString(chainCode))
+
+	key, chainCode, err = crypto.DeriveChildKey(key, chainCode, crypto.HardenedKeyStart)
+	require.NoError(t, err)
+	assert.Equal(t, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", hex.EncodeToString(key))
+	assert.Equal(t, "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", hex.EncodeToString(chainCode))
+
+	key, err = crypto.DeriveKeyPath(seed, []uint32{crypto.HardenedKeyStart, 1, crypto.HardenedKeyStart + 2})
+	require.NoError(t, err)
+	assert.Equal(t, "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca", hex.EncodeToString(key))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm/errors"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 )
 
 // Error represents a storage related error
@@ -19,13 +20,13 @@ func (e Error) Error() string { return string(e) }
 
 const (
 	// ErrDecode indicates that a chunk an actor tried to write could not be decoded
-	ErrDecode = 33
+	ErrDecode = exitcode.SerializationError
 	// ErrDanglingPointer indicates that an actor attempted to commit a pointer to a non-existent chunk
-	ErrDanglingPointer = 34
+	ErrDanglingPointer = exitcode.NotFound
 	// ErrStaleHead indicates that an actor attempted to commit over a stale chunk
-	ErrStaleHead = 35
+	ErrStaleHead = exitcode.IllegalState
 	// ErrInsufficientGas indicates that an actor did not have sufficient gas to run a message
-	ErrInsufficientGas = 36
+	ErrInsufficientGas = exitcode.OutOfGas
 )
 
 // Errors map error codes to revert errors this actor may return
@@ -73,6 +74,7 @@ type VMContext interface {
 	AddressForNewActor() (address.Address, error)
 	BlockHeight() *types.BlockHeight
 	IsFromAccountActor() bool
+	Balance() *types.AttoFIL
 	Charge(cost types.GasUnits) error
 	SampleChainRandomness(sampleHeight *types.BlockHeight) ([]byte, error)
 
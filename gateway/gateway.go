@@ -0,0 +1,280 @@
+// Package gateway serves chain objects and actor state over a read-only HTTP
+// gateway, so that light clients can read them with plain GET requests that
+// caches and CDNs in front of the node can serve.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("gateway")
+
+// Paths served by the gateway.  Objects are identified by the last element
+// of the path.
+const (
+	BlockPath   = "/api/chain/block/"
+	MessagePath = "/api/chain/message/"
+	ReceiptPath = "/api/chain/receipt/"
+	ActorPath   = "/api/state/actor/"
+)
+
+// Paths are the paths the gateway must be mounted on.
+var Paths = []string{BlockPath, MessagePath, ReceiptPath, ActorPath}
+
+// Content types the gateway serves.
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeCBOR = "application/cbor"
+)
+
+// headMaxAge is how long responses depending on the head of the chain may be
+// cached, about a block time, as the next head may change them.
+const headMaxAge = 30 * time.Second
+
+// API is the part of the node's API the gateway reads from.
+type API interface {
+	ActorGetAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error)
+	ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error)
+	MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error)
+}
+
+// Gateway serves GET and HEAD requests for:
+//
+//   - blocks at BlockPath<cid>
+//   - messages included on chain at MessagePath<cid>
+//   - receipts of messages at ReceiptPath<message cid>
+//   - actors at ActorPath<address>, in the state of the head or of the
+//     tipset whose block cids are in the comma separated tipset parameter.
+//
+// Objects are encoded as JSON, or as CBOR if the request accepts
+// application/cbor over application/json.  Blocks, messages and actors at a
+// given tipset never change and are cacheable forever, while receipts and
+// actors at the head are cacheable for about a block time.
+type Gateway struct {
+	api API
+}
+
+// New returns a gateway reading from api.
+func New(api API) *Gateway {
+	return &Gateway{api: api}
+}
+
+// httpError is an error with the status of the response reporting it.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return &httpError{status: http.StatusBadRequest, err: err}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &httpError{status: http.StatusNotFound, err: errors.Errorf(format, args...)}
+}
+
+// ServeHTTP serves the object the path of r identifies.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "the gateway is read-only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var obj interface{}
+	var immutable bool
+	var err error
+	switch {
+	case strings.HasPrefix(r.URL.Path, BlockPath):
+		obj, err = g.block(r.Context(), strings.TrimPrefix(r.URL.Path, BlockPath))
+		immutable = true
+	case strings.HasPrefix(r.URL.Path, MessagePath):
+		obj, err = g.message(r.Context(), strings.TrimPrefix(r.URL.Path, MessagePath))
+		immutable = true
+	case strings.HasPrefix(r.URL.Path, ReceiptPath):
+		obj, err = g.receipt(r.Context(), strings.TrimPrefix(r.URL.Path, ReceiptPath))
+	case strings.HasPrefix(r.URL.Path, ActorPath):
+		tipset := r.URL.Query().Get("tipset")
+		obj, err = g.actor(r.Context(), strings.TrimPrefix(r.URL.Path, ActorPath), tipset)
+		immutable = tipset != ""
+	default:
+		err = notFound("no object at %s", r.URL.Path)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if herr, ok := err.(*httpError); ok {
+			status = herr.status
+		} else {
+			log.Warningf("failed to serve %s: %s", r.URL.Path, err)
+		}
+		// Missing objects may be found later, e.g. once a block is synced.
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	contentType := negotiate(r.Header.Get("Accept"))
+	var body []byte
+	if contentType == ContentTypeCBOR {
+		body, err = cbor.DumpObject(obj)
+	} else {
+		body, err = json.Marshal(obj)
+	}
+	if err != nil {
+		log.Warningf("failed to encode %s: %s", r.URL.Path, err)
+		http.Error(w, "failed to encode object", http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Vary", "Accept")
+	if immutable {
+		// The path identifies the content, up to its encoding.
+		etag := fmt.Sprintf(`"%s.%s"`, strings.TrimPrefix(r.URL.RequestURI(), "/"), strings.TrimPrefix(contentType, "application/"))
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+		h.Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(headMaxAge.Seconds())))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		log.Debugf("failed to write %s: %s", r.URL.Path, err)
+	}
+}
+
+func (g *Gateway) block(ctx context.Context, s string) (interface{}, error) {
+	c, err := cid.Decode(s)
+	if err != nil {
+		return nil, badRequest(errors.Wrapf(err, "invalid block cid %q", s))
+	}
+	blk, err := g.api.ChainGetBlock(ctx, c)
+	if err != nil {
+		return nil, notFound("block %s not found: %s", c, err)
+	}
+	return blk, nil
+}
+
+func (g *Gateway) findMessage(ctx context.Context, s string) (*msg.ChainMessage, error) {
+	c, err := cid.Decode(s)
+	if err != nil {
+		return nil, badRequest(errors.Wrapf(err, "invalid message cid %q", s))
+	}
+	chainMsg, found, err := g.api.MessageFind(ctx, c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find message %s", c)
+	}
+	if !found {
+		return nil, notFound("message %s not found on chain", c)
+	}
+	return chainMsg, nil
+}
+
+func (g *Gateway) message(ctx context.Context, s string) (interface{}, error) {
+	chainMsg, err := g.findMessage(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return chainMsg.Message, nil
+}
+
+func (g *Gateway) receipt(ctx context.Context, s string) (interface{}, error) {
+	chainMsg, err := g.findMessage(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if chainMsg.Receipt == nil {
+		return nil, notFound("message %s was not applied and has no receipt", s)
+	}
+	return chainMsg.Receipt, nil
+}
+
+func (g *Gateway) actor(ctx context.Context, s, tipset string) (interface{}, error) {
+	addr, err := address.NewFromString(s)
+	if err != nil {
+		return nil, badRequest(errors.Wrapf(err, "invalid address %q", s))
+	}
+	var blks []cid.Cid
+	if tipset != "" {
+		for _, cs := range strings.Split(tipset, ",") {
+			c, err := cid.Decode(cs)
+			if err != nil {
+				return nil, badRequest(errors.Wrapf(err, "invalid tipset block cid %q", cs))
+			}
+			blks = append(blks, c)
+		}
+	}
+	act, err := g.api.ActorGetAt(ctx, types.NewSortedCidSet(blks...), addr)
+	if state.IsActorNotFoundError(err) {
+		return nil, notFound("actor %s not found", addr)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get actor %s", addr)
+	}
+	return act, nil
+}
+
+// negotiate returns the content type to respond with to a request accepting
+// the media ranges of accept: CBOR if it prefers it over JSON, JSON
+// otherwise.
+func negotiate(accept string) string {
+	var jsonQ, cborQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case ContentTypeCBOR:
+			cborQ = maxQ(cborQ, q)
+		case ContentTypeJSON, "application/*", "*/*":
+			jsonQ = maxQ(jsonQ, q)
+		}
+	}
+	if cborQ > 0 && cborQ > jsonQ {
+		return ContentTypeCBOR
+	}
+	return ContentTypeJSON
+}
+
+func maxQ(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
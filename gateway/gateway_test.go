@@ -0,0 +1,169 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/gateway"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type fakeAPI struct {
+	blocks map[cid.Cid]*types.Block
+	msgs   map[cid.Cid]*msg.ChainMessage
+	actors map[address.Address]*actor.Actor
+	// tsKeys are the tipset keys actors were requested at.
+	tsKeys []types.SortedCidSet
+}
+
+func (api *fakeAPI) ActorGetAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error) {
+	api.tsKeys = append(api.tsKeys, tsKey)
+	act, ok := api.actors[addr]
+	if !ok {
+		return nil, errors.New("no actor")
+	}
+	return act, nil
+}
+
+func (api *fakeAPI) ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error) {
+	blk, ok := api.blocks[id]
+	if !ok {
+		return nil, errors.New("no block")
+	}
+	return blk, nil
+}
+
+func (api *fakeAPI) MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error) {
+	chainMsg, ok := api.msgs[msgCid]
+	return chainMsg, ok, nil
+}
+
+func get(t *testing.T, url string, header http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestGateway(t *testing.T) {
+	tf.UnitTest(t)
+
+	blk := types.NewBlockForTest(nil, 1)
+	smsg := types.NewSignedMsgs(1, types.NewMockSigner(types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())))[0]
+	msgCid, err := smsg.Cid()
+	require.NoError(t, err)
+	unapplied := types.NewSignedMsgs(2, types.NewMockSigner(types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())))[1]
+	unappliedCid, err := unapplied.Cid()
+	require.NoError(t, err)
+	act := actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(10))
+
+	api := &fakeAPI{
+		blocks: map[cid.Cid]*types.Block{blk.Cid(): blk},
+		msgs: map[cid.Cid]*msg.ChainMessage{
+			msgCid:       {Message: smsg, Block: blk, Receipt: &types.MessageReceipt{ExitCode: 0}},
+			unappliedCid: {Message: unapplied, Block: blk},
+		},
+		actors: map[address.Address]*actor.Actor{address.TestAddress: act},
+	}
+	ts := httptest.NewServer(gateway.New(api))
+	defer ts.Close()
+
+	t.Run("serves immutable blocks as JSON", func(t *testing.T) {
+		resp, body := get(t, ts.URL+gateway.BlockPath+blk.Cid().String(), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, gateway.ContentTypeJSON, resp.Header.Get("Content-Type"))
+		assert.Equal(t, "Accept", resp.Header.Get("Vary"))
+		assert.Contains(t, resp.Header.Get("Cache-Control"), "immutable")
+
+		var got types.Block
+		require.NoError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, blk.Cid(), got.Cid())
+
+		resp, _ = get(t, ts.URL+gateway.BlockPath+blk.Cid().String(), http.Header{"If-None-Match": {resp.Header.Get("ETag")}})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	})
+
+	t.Run("negotiates CBOR", func(t *testing.T) {
+		resp, body := get(t, ts.URL+gateway.BlockPath+blk.Cid().String(), http.Header{"Accept": {"application/json;q=0.5, application/cbor"}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, gateway.ContentTypeCBOR, resp.Header.Get("Content-Type"))
+		var got types.Block
+		require.NoError(t, cbor.DecodeInto(body, &got))
+		assert.Equal(t, blk.Cid(), got.Cid())
+
+		resp, _ = get(t, ts.URL+gateway.BlockPath+blk.Cid().String(), http.Header{"Accept": {"application/cbor;q=0.1, */*"}})
+		assert.Equal(t, gateway.ContentTypeJSON, resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("serves messages and receipts", func(t *testing.T) {
+		resp, body := get(t, ts.URL+gateway.MessagePath+msgCid.String(), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var got types.SignedMessage
+		require.NoError(t, json.Unmarshal(body, &got))
+		gotCid, err := got.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, msgCid, gotCid)
+
+		resp, _ = get(t, ts.URL+gateway.ReceiptPath+msgCid.String(), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotContains(t, resp.Header.Get("Cache-Control"), "immutable")
+
+		resp, _ = get(t, ts.URL+gateway.ReceiptPath+unappliedCid.String(), nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("serves actors at the head or a tipset", func(t *testing.T) {
+		resp, body := get(t, ts.URL+gateway.ActorPath+address.TestAddress.String(), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "public, max-age=30", resp.Header.Get("Cache-Control"))
+		var got actor.Actor
+		require.NoError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, act.Balance, got.Balance)
+
+		resp, _ = get(t, ts.URL+gateway.ActorPath+address.TestAddress.String()+"?tipset="+blk.Cid().String(), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Cache-Control"), "immutable")
+
+		require.Len(t, api.tsKeys, 2)
+		assert.Equal(t, 0, api.tsKeys[0].Len())
+		assert.Equal(t, types.NewSortedCidSet(blk.Cid()), api.tsKeys[1])
+	})
+
+	t.Run("reports errors uncached", func(t *testing.T) {
+		resp, _ := get(t, ts.URL+gateway.BlockPath+msgCid.String(), nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+		resp, _ = get(t, ts.URL+gateway.BlockPath+"notacid", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, _ = get(t, ts.URL+gateway.ActorPath+address.TestAddress.String()+"?tipset=notacid", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		postResp, err := http.Post(ts.URL+gateway.BlockPath+blk.Cid().String(), gateway.ContentTypeJSON, nil)
+		require.NoError(t, err)
+		postResp.Body.Close() // nolint: errcheck
+		assert.Equal(t, http.StatusMethodNotAllowed, postResp.StatusCode)
+	})
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/json"
 	flg "flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"time"
 
@@ -12,25 +16,29 @@ import (
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
-func writeKey(ki *types.KeyInfo, name string, jsonout bool) error {
+// writeKey exports ki to the file name.key and returns the file's content.
+func writeKey(ki *types.KeyInfo, name string, jsonout bool) ([]byte, error) {
 	addr, err := ki.Address()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !jsonout {
 		fmt.Fprintf(os.Stderr, "key: %s - %s\n", name, addr.String())                                                          // nolint: errcheck
 		fmt.Fprintf(os.Stderr, "run 'go-filecoin wallet import ./%s.key' to add private key for %[1]s to your wallet\n", name) // nolint: errcheck
 	}
-	fi, err := os.Create(name + ".key")
-	if err != nil {
-		return err
-	}
-	defer fi.Close() // nolint: errcheck
 
 	var wir commands.WalletSerializeResult
 	wir.KeyInfo = append(wir.KeyInfo, ki)
 
-	return json.NewEncoder(fi).Encode(wir)
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(wir); err != nil {
+		return nil, err
+	}
+	// Keys are secret, keep them from other users.
+	if err := ioutil.WriteFile(name+".key", buf.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 /* gengen takes as input a json encoded 'Genesis Config'
@@ -59,6 +67,13 @@ $ cat setup.json | gengen > genesis.car
 The outputted file can be used by go-filecoin during init to
 set the initial genesis block:
 $ go-filecoin init --genesisfile=genesis.car
+
+For network launches, gengen runs entirely offline.  With -keys-from-entropy
+the keys are generated from the system's secure source of randomness instead
+of the seed, and -manifest writes a manifest of the allocations, miners, keys
+and the hashes of the config, car and key files.  Anyone can then check a car
+against the manifest:
+$ gengen -manifest manifest.json -verify-car genesis.car
 */
 
 var (
@@ -75,13 +90,25 @@ func main() {
 	outCar := flag.String("out-car", "", "writes the generated car file to the give path, instead of stdout")
 	configFilePath := flag.String("config", "", "reads configuration from this json file, instead of stdin")
 	seed := flag.Int64("seed", defaultSeed, "provides the seed for randomization, defaults to current unix epoch")
+	keysFromEntropy := flag.Bool("keys-from-entropy", false, "generates keys from the system's secure source of randomness instead of the seed")
+	manifestPath := flag.String("manifest", "", "writes a manifest of the genesis to the given path, or reads it with -verify-car")
+	verifyCar := flag.String("verify-car", "", "checks the car at the given path against the manifest given by -manifest, then exits")
 
 	// ExitOnError is set
 	flag.Parse(os.Args[1:]) // nolint: errcheck
 
+	if *verifyCar != "" {
+		if err := verify(*manifestPath, *verifyCar); err != nil {
+			fmt.Fprintf(os.Stderr, "verification failed: %s\n", err) // nolint: errcheck
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%s matches the manifest\n", *verifyCar) // nolint: errcheck
+		return
+	}
+
 	jsonEnabled := *jsonout || *outJSON != ""
 
-	cfg, err := readConfig(*configFilePath)
+	cfg, cfgData, err := readConfig(*configFilePath)
 	if err != nil {
 		panic(err)
 	}
@@ -98,15 +125,53 @@ func main() {
 	if *testProofsMode {
 		cfg.ProofsMode = types.TestProofsMode
 	}
-	info, err := gengen.GenGenesisCar(cfg, outfile, *seed)
+
+	// The car is kept to be hashed into the manifest.
+	var carData bytes.Buffer
+	carOut := io.MultiWriter(outfile, &carData)
+	var info *gengen.RenderedGenInfo
+	if *keysFromEntropy {
+		var keys []*types.KeyInfo
+		keys, err = gengen.GenKeys(cfg.Keys, rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		info, err = gengen.GenGenesisCarWithKeys(cfg, keys, carOut, *seed)
+	} else {
+		info, err = gengen.GenGenesisCar(cfg, carOut, *seed)
+	}
 	if err != nil {
 		fmt.Println("ERROR", err)
 		panic(err)
 	}
 
+	var manifest *gengen.Manifest
+	if *manifestPath != "" {
+		manifest, err = gengen.NewManifest(cfg, cfgData, *seed, !*keysFromEntropy, info, carData.Bytes())
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	for name, k := range info.Keys {
 		n := fmt.Sprintf("%s/%d", *keypath, name)
-		if err := writeKey(k, n, jsonEnabled); err != nil {
+		data, err := writeKey(k, n, jsonEnabled)
+		if err != nil {
+			panic(err)
+		}
+		if manifest != nil {
+			if err := manifest.AddKeyFile(name, k, n+".key", data); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	if manifest != nil {
+		out, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(*manifestPath, out, 0644); err != nil {
 			panic(err)
 		}
 	}
@@ -137,20 +202,47 @@ func main() {
 	}
 }
 
-func readConfig(filePath string) (*gengen.GenesisCfg, error) {
+// readConfig returns the configuration read from filePath, or stdin, and its
+// raw content.
+func readConfig(filePath string) (*gengen.GenesisCfg, []byte, error) {
 	configFile := os.Stdin
 	if filePath != "" {
 		f, err := os.Open(filePath)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		defer f.Close() // nolint: errcheck
 		configFile = f
 	}
 
+	data, err := ioutil.ReadAll(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
 	var cfg gengen.GenesisCfg
-	if err := json.NewDecoder(configFile).Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %s", err)
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %s", err)
 	}
 
-	return &cfg, nil
+	return &cfg, data, nil
+}
+
+// verify checks the car at carPath against the manifest at manifestPath.
+func verify(manifestPath, carPath string) error {
+	if manifestPath == "" {
+		return fmt.Errorf("-verify-car requires -manifest")
+	}
+	data, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	var manifest gengen.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse manifest: %s", err)
+	}
+	carData, err := ioutil.ReadFile(carPath)
+	if err != nil {
+		return err
+	}
+	return manifest.VerifyCar(carData)
 }
@@ -59,10 +59,32 @@ type GenesisCfg struct {
 	// Miners is a list of miners that should be set up at the start of the network
 	Miners []Miner
 
+	// Accounts is a list of accounts at fixed addresses that will be created
+	// with the given balance. Unlike PreAlloc these do not need a key
+	// generated by gengen, so existing wallets can be funded at genesis.
+	Accounts []Account
+
+	// NetworkBalance is the string value of whole filecoin held by the
+	// network actor. DefaultNetworkBalance is used if it is empty.
+	NetworkBalance string
+
 	// ProofsMode affects sealing, sector packing, PoSt, etc. in the proofs library
 	ProofsMode types.ProofsMode
 }
 
+// Account is an account preallocated at a fixed address
+type Account struct {
+	// Address is the string encoding of the account's address
+	Address string
+
+	// Balance is the string value of whole filecoin held by the account
+	Balance string
+}
+
+// DefaultNetworkBalance is the balance of the network actor in whole filecoin
+// when a configuration does not specify one.
+const DefaultNetworkBalance = "10000000000"
+
 // RenderedGenInfo contains information about a genesis block creation
 type RenderedGenInfo struct {
 	// Keys is the set of keys generated
@@ -94,11 +116,24 @@ type RenderedMinerInfo struct {
 // WARNING: Do not use maps in this code, they will make this code non deterministic.
 func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs blockstore.Blockstore, seed int64) (*RenderedGenInfo, error) {
 	pnrg := mrand.New(mrand.NewSource(seed))
-	keys, err := genKeys(cfg.Keys, pnrg)
+	keys, err := GenKeys(cfg.Keys, pnrg)
 	if err != nil {
 		return nil, err
 	}
+	return genGen(ctx, cfg, cst, bs, keys, pnrg)
+}
 
+// GenGenWithKeys is like GenGen but uses the given keys, e.g. keys generated
+// offline from a secure source of randomness, instead of deriving them from
+// the seed, which then only determines the miners' sectors.
+func GenGenWithKeys(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs blockstore.Blockstore, keys []*types.KeyInfo, seed int64) (*RenderedGenInfo, error) {
+	if len(keys) != cfg.Keys {
+		return nil, fmt.Errorf("config requires %d keys but %d were given", cfg.Keys, len(keys))
+	}
+	return genGen(ctx, cfg, cst, bs, keys, mrand.New(mrand.NewSource(seed)))
+}
+
+func genGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs blockstore.Blockstore, keys []*types.KeyInfo, pnrg io.Reader) (*RenderedGenInfo, error) {
 	st := state.NewEmptyStateTreeWithActors(cst, builtin.Actors)
 	storageMap := vm.NewStorageMap(bs)
 
@@ -106,7 +141,7 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 		return nil, err
 	}
 
-	if err := setupPrealloc(st, keys, cfg.PreAlloc); err != nil {
+	if err := setupPrealloc(st, keys, cfg.PreAlloc, cfg.Accounts, cfg.NetworkBalance); err != nil {
 		return nil, err
 	}
 
@@ -130,6 +165,12 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 	if err := cst.Blocks.AddBlock(types.PaymentBrokerActorCodeObj); err != nil {
 		return nil, err
 	}
+	if err := cst.Blocks.AddBlock(types.RewardActorCodeObj); err != nil {
+		return nil, err
+	}
+	if err := cst.Blocks.AddBlock(types.CronActorCodeObj); err != nil {
+		return nil, err
+	}
 
 	stateRoot, err := st.Flush(ctx)
 	if err != nil {
@@ -157,7 +198,8 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 	}, nil
 }
 
-func genKeys(cfgkeys int, pnrg io.Reader) ([]*types.KeyInfo, error) {
+// GenKeys generates cfgkeys keys from the given source of randomness.
+func GenKeys(cfgkeys int, pnrg io.Reader) ([]*types.KeyInfo, error) {
 	keys := make([]*types.KeyInfo, cfgkeys)
 	for i := 0; i < cfgkeys; i++ {
 		sk, err := crypto.GenerateKeyFromSeed(pnrg) // TODO: GenerateKey should return a KeyInfo
@@ -176,7 +218,8 @@ func genKeys(cfgkeys int, pnrg io.Reader) ([]*types.KeyInfo, error) {
 	return keys, nil
 }
 
-func setupPrealloc(st state.Tree, keys []*types.KeyInfo, prealloc []string) error {
+func setupPrealloc(st state.Tree, keys []*types.KeyInfo, prealloc []string, accounts []Account, networkBalance string) error {
+	ctx := context.Background()
 
 	if len(keys) < len(prealloc) {
 		return fmt.Errorf("keys do not match prealloc")
@@ -189,26 +232,64 @@ func setupPrealloc(st state.Tree, keys []*types.KeyInfo, prealloc []string) erro
 			return err
 		}
 
-		valint, err := strconv.ParseUint(v, 10, 64)
-		if err != nil {
+		if err := setupAccount(ctx, st, addr, v); err != nil {
 			return err
 		}
+	}
 
-		act, err := account.NewActor(types.NewAttoFILFromFIL(valint))
+	for _, a := range accounts {
+		addr, err := address.NewFromString(a.Address)
 		if err != nil {
+			return errors.Wrapf(err, "invalid account address %q", a.Address)
+		}
+
+		if _, err := st.GetActor(ctx, addr); err == nil {
+			return fmt.Errorf("account %s is allocated more than once", addr)
+		} else if !state.IsActorNotFoundError(err) {
 			return err
 		}
-		if err := st.SetActor(context.Background(), addr, act); err != nil {
+
+		if err := setupAccount(ctx, st, addr, a.Balance); err != nil {
 			return err
 		}
 	}
 
-	netact, err := account.NewActor(types.NewAttoFILFromFIL(10000000000))
+	if networkBalance == "" {
+		networkBalance = DefaultNetworkBalance
+	}
+	return setupNetwork(ctx, st, networkBalance)
+}
+
+// setupNetwork sets the balance of the reward actor installed at the network
+// address to balance whole filecoin.
+func setupNetwork(ctx context.Context, st state.Tree, balance string) error {
+	valint, err := strconv.ParseUint(balance, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "invalid network balance %q", balance)
+	}
+
+	act, err := st.GetActor(ctx, address.NetworkAddress)
+	if err != nil {
+		return err
+	}
+	act.Balance = types.NewAttoFILFromFIL(valint)
+
+	return st.SetActor(ctx, address.NetworkAddress, act)
+}
+
+// setupAccount creates an account actor at addr holding balance whole filecoin.
+func setupAccount(ctx context.Context, st state.Tree, addr address.Address, balance string) error {
+	valint, err := strconv.ParseUint(balance, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "invalid balance %q for %s", balance, addr)
+	}
+
+	act, err := account.NewActor(types.NewAttoFILFromFIL(valint))
 	if err != nil {
 		return err
 	}
 
-	return st.SetActor(context.Background(), address.NetworkAddress, netact)
+	return st.SetActor(ctx, addr, act)
 }
 
 func setupMiners(st state.Tree, sm vm.StorageMap, keys []*types.KeyInfo, miners []Miner, pnrg io.Reader) ([]RenderedMinerInfo, error) {
@@ -297,6 +378,20 @@ func setupMiners(st state.Tree, sm vm.StorageMap, keys []*types.KeyInfo, miners
 
 // GenGenesisCar generates a car for the given genesis configuration
 func GenGenesisCar(cfg *GenesisCfg, out io.Writer, seed int64) (*RenderedGenInfo, error) {
+	return genGenesisCar(out, func(ctx context.Context, cst *hamt.CborIpldStore, bs blockstore.Blockstore) (*RenderedGenInfo, error) {
+		return GenGen(ctx, cfg, cst, bs, seed)
+	})
+}
+
+// GenGenesisCarWithKeys generates a car for the given genesis configuration
+// using the given keys, see GenGenWithKeys.
+func GenGenesisCarWithKeys(cfg *GenesisCfg, keys []*types.KeyInfo, out io.Writer, seed int64) (*RenderedGenInfo, error) {
+	return genGenesisCar(out, func(ctx context.Context, cst *hamt.CborIpldStore, bs blockstore.Blockstore) (*RenderedGenInfo, error) {
+		return GenGenWithKeys(ctx, cfg, cst, bs, keys, seed)
+	})
+}
+
+func genGenesisCar(out io.Writer, gen func(context.Context, *hamt.CborIpldStore, blockstore.Blockstore) (*RenderedGenInfo, error)) (*RenderedGenInfo, error) {
 	// TODO: these six lines are ugly. We can do better...
 	mds := ds.NewMapDatastore()
 	bstore := blockstore.NewBlockstore(mds)
@@ -307,7 +402,7 @@ func GenGenesisCar(cfg *GenesisCfg, out io.Writer, seed int64) (*RenderedGenInfo
 
 	ctx := context.Background()
 
-	info, err := GenGen(ctx, cfg, cst, bstore, seed)
+	info, err := gen(ctx, cst, bstore)
 	if err != nil {
 		return nil, err
 	}
@@ -315,6 +410,27 @@ func GenGenesisCar(cfg *GenesisCfg, out io.Writer, seed int64) (*RenderedGenInfo
 	return info, car.WriteCar(ctx, dserv, []cid.Cid{info.GenesisCid}, out)
 }
 
+// MakeGenesisFunc returns a genesis function that generates the genesis block
+// described by cfg. The generated keys are discarded, so accounts that need to
+// be spent from should be allocated through cfg.Accounts. The same cfg and
+// seed always produce the same genesis block.
+func MakeGenesisFunc(cfg *GenesisCfg, seed int64) consensus.GenesisInitFunc {
+	return func(cst *hamt.CborIpldStore, bs blockstore.Blockstore) (*types.Block, error) {
+		ctx := context.Background()
+
+		info, err := GenGen(ctx, cfg, cst, bs, seed)
+		if err != nil {
+			return nil, err
+		}
+
+		var blk types.Block
+		if err := cst.Get(ctx, info.GenesisCid, &blk); err != nil {
+			return nil, err
+		}
+		return &blk, nil
+	}
+}
+
 // applyMessageDirect applies a given message directly to the given state tree and storage map and returns the result of the message.
 // This is a shortcut to allow gengen to use built-in actor functionality to alter the genesis block's state.
 // Outside genesis, direct execution of actor code is a really bad idea.
@@ -363,12 +479,12 @@ type blockRewarder struct{}
 var _ consensus.BlockRewarder = (*blockRewarder)(nil)
 
 // BlockReward is a noop
-func (gbr *blockRewarder) BlockReward(ctx context.Context, st state.Tree, minerAddr address.Address) error {
+func (gbr *blockRewarder) BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address) error {
 	return nil
 }
 
 // GasReward is a noop
-func (gbr *blockRewarder) GasReward(ctx context.Context, st state.Tree, minerAddr address.Address, msg *types.SignedMessage, cost *types.AttoFIL) error {
+func (gbr *blockRewarder) GasReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address, msg *types.SignedMessage, cost *types.AttoFIL) error {
 	return nil
 }
 
@@ -1,7 +1,9 @@
 package gengen_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"io/ioutil"
 	"testing"
 
@@ -11,11 +13,16 @@ import (
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-exchange-offline"
 
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
 	. "github.com/filecoin-project/go-filecoin/gengen/util"
+	"github.com/filecoin-project/go-filecoin/state"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testConfig = &GenesisCfg{
@@ -75,3 +82,136 @@ func TestGenGenDeterministicBetweenBuilds(t *testing.T) {
 		}
 	}
 }
+
+func newTestStores() (*hamt.CborIpldStore, blockstore.Blockstore) {
+	bstore := blockstore.NewBlockstore(ds.NewMapDatastore())
+	blkserv := bserv.New(bstore, offline.Exchange(bstore))
+	return &hamt.CborIpldStore{Blocks: blkserv}, bstore
+}
+
+func TestGenGenAccounts(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	newAddr := address.NewForTestGetter()
+	addr1, addr2 := newAddr(), newAddr()
+
+	t.Run("allocates accounts and network balance", func(t *testing.T) {
+		cfg := &GenesisCfg{
+			Keys:     1,
+			PreAlloc: []string{"10"},
+			Accounts: []Account{
+				{Address: addr1.String(), Balance: "100"},
+				{Address: addr2.String(), Balance: "200"},
+			},
+			NetworkBalance: "5000",
+		}
+
+		cst, bs := newTestStores()
+		blk, err := MakeGenesisFunc(cfg, 0)(cst, bs)
+		require.NoError(t, err)
+
+		st, err := state.LoadStateTree(ctx, cst, blk.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+
+		act, err := st.GetActor(ctx, addr1)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewAttoFILFromFIL(100), act.Balance)
+		act, err = st.GetActor(ctx, addr2)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewAttoFILFromFIL(200), act.Balance)
+		act, err = st.GetActor(ctx, address.NetworkAddress)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewAttoFILFromFIL(5000), act.Balance)
+	})
+
+	t.Run("same config and seed give the same genesis block", func(t *testing.T) {
+		cst1, bs1 := newTestStores()
+		blk1, err := MakeGenesisFunc(testConfig, 7)(cst1, bs1)
+		require.NoError(t, err)
+
+		cst2, bs2 := newTestStores()
+		blk2, err := MakeGenesisFunc(testConfig, 7)(cst2, bs2)
+		require.NoError(t, err)
+
+		assert.Equal(t, blk1.Cid(), blk2.Cid())
+	})
+
+	t.Run("rejects duplicate accounts", func(t *testing.T) {
+		cfg := &GenesisCfg{
+			Accounts: []Account{
+				{Address: addr1.String(), Balance: "100"},
+				{Address: addr1.String(), Balance: "200"},
+			},
+		}
+
+		cst, bs := newTestStores()
+		_, err := GenGen(ctx, cfg, cst, bs, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects invalid accounts", func(t *testing.T) {
+		cst, bs := newTestStores()
+		_, err := GenGen(ctx, &GenesisCfg{Accounts: []Account{{Address: "notanaddress", Balance: "1"}}}, cst, bs, 0)
+		assert.Error(t, err)
+
+		_, err = GenGen(ctx, &GenesisCfg{Accounts: []Account{{Address: addr1.String(), Balance: "lots"}}}, cst, bs, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestManifest(t *testing.T) {
+	tf.UnitTest(t)
+
+	addr := address.NewForTestGetter()()
+	cfg := &GenesisCfg{
+		Keys:     2,
+		PreAlloc: []string{"10", "50"},
+		Miners:   []Miner{{Owner: 1, Power: 3}},
+		Accounts: []Account{{Address: addr.String(), Balance: "100"}},
+	}
+	keys, err := GenKeys(2, rand.Reader)
+	require.NoError(t, err)
+
+	var car bytes.Buffer
+	info, err := GenGenesisCarWithKeys(cfg, keys, &car, 0)
+	require.NoError(t, err)
+	assert.Equal(t, keys, info.Keys)
+
+	m, err := NewManifest(cfg, []byte("config"), 0, false, info, car.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, m.AddKeyFile(0, keys[0], "0.key", []byte("key")))
+
+	key0, err := keys[0].Address()
+	require.NoError(t, err)
+	key1, err := keys[1].Address()
+	require.NoError(t, err)
+	assert.Equal(t, info.GenesisCid, m.GenesisCid)
+	assert.Equal(t, []ManifestAllocation{
+		{Address: key0, Balance: "10", Key: 0},
+		{Address: key1, Balance: "50", Key: 1},
+		{Address: addr, Balance: "100", Key: -1},
+		{Address: address.NetworkAddress, Balance: DefaultNetworkBalance, Key: -1},
+	}, m.Allocations)
+	require.Len(t, m.Miners, 1)
+	assert.Equal(t, key1, m.Miners[0].Owner)
+	assert.Equal(t, uint64(3), m.Miners[0].Power)
+	require.Len(t, m.Keys, 1)
+	assert.Equal(t, key0, m.Keys[0].Address)
+
+	t.Run("verifies the car", func(t *testing.T) {
+		assert.NoError(t, m.VerifyCar(car.Bytes()))
+	})
+
+	t.Run("rejects another car", func(t *testing.T) {
+		var other bytes.Buffer
+		_, err := GenGenesisCar(cfg, &other, 1)
+		require.NoError(t, err)
+		assert.Error(t, m.VerifyCar(other.Bytes()))
+	})
+
+	t.Run("rejects a wrong number of keys", func(t *testing.T) {
+		_, err := GenGenesisCarWithKeys(cfg, keys[:1], &bytes.Buffer{}, 0)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,146 @@
+package gengen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ipfs/go-car"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-ipfs-blockstore"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Manifest records the inputs and outputs of a genesis creation so that a
+// network launch can be audited: anyone holding the genesis car can check it
+// against the manifest, and the allocations it lists against the genesis
+// state.
+type Manifest struct {
+	// GenesisCid is the cid of the genesis block.
+	GenesisCid cid.Cid `json:"genesisCid"`
+	// CarSHA256 is the hex encoded sha256 hash of the genesis car.
+	CarSHA256 string `json:"carSha256"`
+	// ConfigSHA256 is the hex encoded sha256 hash of the configuration file
+	// the genesis was created from.
+	ConfigSHA256 string `json:"configSha256"`
+	// Seed is the seed the genesis was created with.
+	Seed       int64            `json:"seed"`
+	ProofsMode types.ProofsMode `json:"proofsMode"`
+	// KeysFromSeed is true if the keys were derived from the seed, making
+	// them reproducible by anyone with the configuration, rather than
+	// generated from a secure source of randomness.
+	KeysFromSeed bool `json:"keysFromSeed"`
+
+	Allocations []ManifestAllocation `json:"allocations"`
+	Miners      []ManifestMiner      `json:"miners"`
+	Keys        []ManifestKey        `json:"keys"`
+}
+
+// ManifestAllocation is an account allocated a balance at genesis.
+type ManifestAllocation struct {
+	Address address.Address `json:"address"`
+	// Balance is the balance in whole filecoin.
+	Balance string `json:"balance"`
+	// Key is the index of the generated key controlling the account, or -1
+	// for accounts at fixed addresses.
+	Key int `json:"key"`
+}
+
+// ManifestMiner is a miner created at genesis.
+type ManifestMiner struct {
+	Address address.Address `json:"address"`
+	Owner   address.Address `json:"owner"`
+	Power   uint64          `json:"power"`
+}
+
+// ManifestKey is a generated key and the file it was exported to.
+type ManifestKey struct {
+	Index   int             `json:"index"`
+	Address address.Address `json:"address"`
+	// File is the path the key was exported to and FileSHA256 the hex
+	// encoded sha256 hash of its content.
+	File       string `json:"file"`
+	FileSHA256 string `json:"fileSha256"`
+}
+
+// NewManifest returns the manifest of the genesis described by cfg, rendered
+// with seed as info and written to carData.  The configuration is hashed as
+// given in configData.  Key files are added with AddKeyFile.
+func NewManifest(cfg *GenesisCfg, configData []byte, seed int64, keysFromSeed bool, info *RenderedGenInfo, carData []byte) (*Manifest, error) {
+	m := &Manifest{
+		GenesisCid:   info.GenesisCid,
+		CarSHA256:    hashHex(carData),
+		ConfigSHA256: hashHex(configData),
+		Seed:         seed,
+		ProofsMode:   cfg.ProofsMode,
+		KeysFromSeed: keysFromSeed,
+	}
+
+	for i, balance := range cfg.PreAlloc {
+		addr, err := info.Keys[i].Address()
+		if err != nil {
+			return nil, err
+		}
+		m.Allocations = append(m.Allocations, ManifestAllocation{Address: addr, Balance: balance, Key: i})
+	}
+	for _, a := range cfg.Accounts {
+		addr, err := address.NewFromString(a.Address)
+		if err != nil {
+			return nil, err
+		}
+		m.Allocations = append(m.Allocations, ManifestAllocation{Address: addr, Balance: a.Balance, Key: -1})
+	}
+	networkBalance := cfg.NetworkBalance
+	if networkBalance == "" {
+		networkBalance = DefaultNetworkBalance
+	}
+	m.Allocations = append(m.Allocations, ManifestAllocation{Address: address.NetworkAddress, Balance: networkBalance, Key: -1})
+
+	for _, miner := range info.Miners {
+		owner, err := info.Keys[miner.Owner].Address()
+		if err != nil {
+			return nil, err
+		}
+		m.Miners = append(m.Miners, ManifestMiner{Address: miner.Address, Owner: owner, Power: miner.Power})
+	}
+	return m, nil
+}
+
+// AddKeyFile records that the key at index was exported to file with the
+// given content.
+func (m *Manifest) AddKeyFile(index int, key *types.KeyInfo, file string, data []byte) error {
+	addr, err := key.Address()
+	if err != nil {
+		return err
+	}
+	m.Keys = append(m.Keys, ManifestKey{Index: index, Address: addr, File: file, FileSHA256: hashHex(data)})
+	return nil
+}
+
+// VerifyCar checks that carData is the genesis car the manifest describes.
+func (m *Manifest) VerifyCar(carData []byte) error {
+	if h := hashHex(carData); h != m.CarSHA256 {
+		return fmt.Errorf("car hash %s does not match manifest hash %s", h, m.CarSHA256)
+	}
+	bs := blockstore.NewBlockstore(ds.NewMapDatastore())
+	header, err := car.LoadCar(bs, bytes.NewReader(carData))
+	if err != nil {
+		return err
+	}
+	if len(header.Roots) != 1 || !header.Roots[0].Equals(m.GenesisCid) {
+		return fmt.Errorf("car roots %v do not match manifest genesis %s", header.Roots, m.GenesisCid)
+	}
+	if has, err := bs.Has(m.GenesisCid); err != nil || !has {
+		return fmt.Errorf("car does not contain genesis block %s", m.GenesisCid)
+	}
+	return nil
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
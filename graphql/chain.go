@@ -0,0 +1,347 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// API is the part of the node's API the chain schema reads from.
+type API interface {
+	ActorGetAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error)
+	ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error)
+	ChainGetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
+	ChainGetTipSetAtHeight(ctx context.Context, height uint64) (*types.TipSet, error)
+	ChainHead() (*types.TipSet, error)
+	DealsLs() ([]*storagedeal.Deal, error)
+	MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error)
+}
+
+// message is a message on chain, along with the block including it and its
+// receipt.
+type message struct {
+	cid     cid.Cid
+	msg     *types.SignedMessage
+	block   *types.Block
+	receipt *types.MessageReceipt
+}
+
+// actorAt is an actor in the state of a tipset.
+type actorAt struct {
+	addr  address.Address
+	actor *actor.Actor
+}
+
+// NewChainSchema returns a schema of the chain and state read from api,
+// whose query type has the fields:
+//
+//	head: TipSet
+//	tipset(key: [String!], height: Int): TipSet
+//	block(cid: String!): Block
+//	message(cid: String!): Message
+//	actor(address: String!, tipset: [String!]): Actor
+//	deals: [Deal]
+//	deal(proposal: String!): Deal
+//
+// Tipsets lead to their parents, blocks and actors, blocks to their
+// messages, and messages to their receipts and blocks.
+func NewChainSchema(api API) *Schema {
+	tipSetType := &Object{Name: "TipSet"}
+	blockType := &Object{Name: "Block"}
+	messageType := &Object{Name: "Message"}
+	receiptType := &Object{Name: "Receipt"}
+	actorType := &Object{Name: "Actor"}
+	dealType := &Object{Name: "Deal"}
+
+	tipSetType.Fields = map[string]*Field{
+		"key": scalar(func(s interface{}) interface{} { return cidStrings(s.(*types.TipSet).ToSortedCidSet().ToSlice()) }),
+		"height": {Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			v, err := source.(*types.TipSet).Height()
+			return types.Uint64(v), err
+		}},
+		"parentWeight": {Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			v, err := source.(*types.TipSet).ParentWeight()
+			return types.Uint64(v), err
+		}},
+		"parents": {Type: tipSetType, Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			parents, err := source.(*types.TipSet).Parents()
+			if err != nil || parents.Len() == 0 {
+				return nil, err
+			}
+			return api.ChainGetTipSet(parents)
+		}},
+		"blocks": {Type: blockType, Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			return source.(*types.TipSet).ToSlice(), nil
+		}},
+		"actor": {Type: actorType, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return getActor(ctx, api, source.(*types.TipSet).ToSortedCidSet(), args)
+		}},
+	}
+
+	blockType.Fields = map[string]*Field{
+		"cid":          scalar(func(s interface{}) interface{} { return s.(*types.Block).Cid().String() }),
+		"miner":        scalar(func(s interface{}) interface{} { return s.(*types.Block).Miner.String() }),
+		"height":       scalar(func(s interface{}) interface{} { return s.(*types.Block).Height }),
+		"parentWeight": scalar(func(s interface{}) interface{} { return s.(*types.Block).ParentWeight }),
+		"nonce":        scalar(func(s interface{}) interface{} { return s.(*types.Block).Nonce }),
+		"timestamp":    scalar(func(s interface{}) interface{} { return s.(*types.Block).Timestamp }),
+		"stateRoot":    scalar(func(s interface{}) interface{} { return cidString(s.(*types.Block).StateRoot) }),
+		"parents": {Type: tipSetType, Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			parents := source.(*types.Block).Parents
+			if parents.Len() == 0 {
+				return nil, nil
+			}
+			return api.ChainGetTipSet(parents)
+		}},
+		"messages": {Type: messageType, Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			return blockMessages(source.(*types.Block))
+		}},
+	}
+
+	messageType.Fields = map[string]*Field{
+		"cid":      scalar(func(s interface{}) interface{} { return s.(*message).cid.String() }),
+		"from":     scalar(func(s interface{}) interface{} { return s.(*message).msg.From.String() }),
+		"to":       scalar(func(s interface{}) interface{} { return s.(*message).msg.To.String() }),
+		"nonce":    scalar(func(s interface{}) interface{} { return s.(*message).msg.Nonce }),
+		"value":    scalar(func(s interface{}) interface{} { return attoFILString(s.(*message).msg.Value) }),
+		"method":   scalar(func(s interface{}) interface{} { return s.(*message).msg.Method }),
+		"params":   scalar(func(s interface{}) interface{} { return s.(*message).msg.Params }),
+		"gasPrice": scalar(func(s interface{}) interface{} { return s.(*message).msg.GasPrice.String() }),
+		"gasLimit": scalar(func(s interface{}) interface{} { return s.(*message).msg.GasLimit }),
+		"block": {Type: blockType, Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			return source.(*message).block, nil
+		}},
+		"receipt": {Type: receiptType, Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+			return source.(*message).receipt, nil
+		}},
+	}
+
+	receiptType.Fields = map[string]*Field{
+		"exitCode":   scalar(func(s interface{}) interface{} { return s.(*types.MessageReceipt).ExitCode }),
+		"return":     scalar(func(s interface{}) interface{} { return s.(*types.MessageReceipt).Return }),
+		"gasAttoFIL": scalar(func(s interface{}) interface{} { return attoFILString(s.(*types.MessageReceipt).GasAttoFIL) }),
+	}
+
+	actorType.Fields = map[string]*Field{
+		"address": scalar(func(s interface{}) interface{} { return s.(*actorAt).addr.String() }),
+		"code":    scalar(func(s interface{}) interface{} { return cidString(s.(*actorAt).actor.Code) }),
+		"head":    scalar(func(s interface{}) interface{} { return cidString(s.(*actorAt).actor.Head) }),
+		"nonce":   scalar(func(s interface{}) interface{} { return s.(*actorAt).actor.Nonce }),
+		"balance": scalar(func(s interface{}) interface{} { return attoFILString(s.(*actorAt).actor.Balance) }),
+	}
+
+	dealType.Fields = map[string]*Field{
+		"proposal":   scalar(func(s interface{}) interface{} { return cidString(s.(*storagedeal.Deal).Response.ProposalCid) }),
+		"miner":      scalar(func(s interface{}) interface{} { return s.(*storagedeal.Deal).Miner.String() }),
+		"state":      scalar(func(s interface{}) interface{} { return s.(*storagedeal.Deal).Response.State.String() }),
+		"message":    scalar(func(s interface{}) interface{} { return s.(*storagedeal.Deal).Response.Message }),
+		"pieceRef":   scalar(func(s interface{}) interface{} { return cidString(s.(*storagedeal.Deal).Proposal.PieceRef) }),
+		"size":       scalar(func(s interface{}) interface{} { return bytesString(s.(*storagedeal.Deal).Proposal.Size) }),
+		"totalPrice": scalar(func(s interface{}) interface{} { return attoFILString(s.(*storagedeal.Deal).Proposal.TotalPrice) }),
+		"duration":   scalar(func(s interface{}) interface{} { return s.(*storagedeal.Deal).Proposal.Duration }),
+		"commitmentMessage": {Type: messageType, Resolve: func(ctx context.Context, source interface{}, _ Args) (interface{}, error) {
+			proof := source.(*storagedeal.Deal).Response.ProofInfo
+			if proof == nil || proof.CommitmentMessage == nil {
+				return nil, nil
+			}
+			return findMessage(ctx, api, *proof.CommitmentMessage)
+		}},
+	}
+
+	queryType := &Object{Name: "Query", Fields: map[string]*Field{
+		"head": {Type: tipSetType, Resolve: func(context.Context, interface{}, Args) (interface{}, error) {
+			return api.ChainHead()
+		}},
+		"tipset": {Type: tipSetType, Resolve: func(ctx context.Context, _ interface{}, args Args) (interface{}, error) {
+			return getTipSet(ctx, api, args)
+		}},
+		"block": {Type: blockType, Resolve: func(ctx context.Context, _ interface{}, args Args) (interface{}, error) {
+			c, err := cidArg(args, "cid")
+			if err != nil {
+				return nil, err
+			}
+			return api.ChainGetBlock(ctx, c)
+		}},
+		"message": {Type: messageType, Resolve: func(ctx context.Context, _ interface{}, args Args) (interface{}, error) {
+			c, err := cidArg(args, "cid")
+			if err != nil {
+				return nil, err
+			}
+			return findMessage(ctx, api, c)
+		}},
+		"actor": {Type: actorType, Resolve: func(ctx context.Context, _ interface{}, args Args) (interface{}, error) {
+			tsKey, err := tipSetKeyArg(args, "tipset")
+			if err != nil {
+				return nil, err
+			}
+			return getActor(ctx, api, tsKey, args)
+		}},
+		"deals": {Type: dealType, Resolve: func(context.Context, interface{}, Args) (interface{}, error) {
+			return api.DealsLs()
+		}},
+		"deal": {Type: dealType, Resolve: func(_ context.Context, _ interface{}, args Args) (interface{}, error) {
+			c, err := cidArg(args, "proposal")
+			if err != nil {
+				return nil, err
+			}
+			deals, err := api.DealsLs()
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range deals {
+				if d.Response != nil && d.Response.ProposalCid.Equals(c) {
+					return d, nil
+				}
+			}
+			return nil, nil
+		}},
+	}}
+
+	return &Schema{Query: queryType}
+}
+
+// scalar returns a field of the scalar get returns for its source.
+func scalar(get func(source interface{}) interface{}) *Field {
+	return &Field{Resolve: func(_ context.Context, source interface{}, _ Args) (interface{}, error) {
+		return get(source), nil
+	}}
+}
+
+// getTipSet returns the tipset with the key or at the height of args, or the
+// head if args have neither.
+func getTipSet(ctx context.Context, api API, args Args) (*types.TipSet, error) {
+	tsKey, err := tipSetKeyArg(args, "key")
+	if err != nil {
+		return nil, err
+	}
+	height, hasHeight, err := args.Int("height")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tsKey.Len() > 0 && hasHeight:
+		return nil, errors.New("only one of key and height may be given")
+	case tsKey.Len() > 0:
+		return api.ChainGetTipSet(tsKey)
+	case hasHeight:
+		if height < 0 {
+			return nil, errors.New("height must not be negative")
+		}
+		return api.ChainGetTipSetAtHeight(ctx, uint64(height))
+	}
+	return api.ChainHead()
+}
+
+// getActor returns the actor at the address argument in the state of the
+// tipset with key tsKey, or nil if there is none.
+func getActor(ctx context.Context, api API, tsKey types.SortedCidSet, args Args) (*actorAt, error) {
+	s, _, err := args.String("address")
+	if err != nil {
+		return nil, err
+	}
+	addr, err := address.NewFromString(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid address %q", s)
+	}
+	act, err := api.ActorGetAt(ctx, tsKey, addr)
+	if state.IsActorNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get actor %s", addr)
+	}
+	return &actorAt{addr: addr, actor: act}, nil
+}
+
+// findMessage returns the message on chain with cid c, or nil if it isn't on
+// chain.
+func findMessage(ctx context.Context, api API, c cid.Cid) (*message, error) {
+	found, ok, err := api.MessageFind(ctx, c)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &message{cid: c, msg: found.Message, block: found.Block, receipt: found.Receipt}, nil
+}
+
+// blockMessages returns the messages of b along with their receipts.
+func blockMessages(b *types.Block) ([]*message, error) {
+	msgs := make([]*message, len(b.Messages))
+	for i, smsg := range b.Messages {
+		c, err := smsg.Cid()
+		if err != nil {
+			return nil, err
+		}
+		msgs[i] = &message{cid: c, msg: smsg, block: b}
+		if len(b.MessageReceipts) == len(b.Messages) {
+			msgs[i].receipt = b.MessageReceipts[i]
+		}
+	}
+	return msgs, nil
+}
+
+func cidArg(args Args, name string) (cid.Cid, error) {
+	s, _, err := args.String(name)
+	if err != nil {
+		return cid.Undef, err
+	}
+	c, err := cid.Decode(s)
+	if err != nil {
+		return cid.Undef, errors.Wrapf(err, "invalid cid %q", s)
+	}
+	return c, nil
+}
+
+// tipSetKeyArg returns the tipset key made of the block cids of the list
+// argument name, or an empty key if it is not set.
+func tipSetKeyArg(args Args, name string) (types.SortedCidSet, error) {
+	strs, err := args.Strings(name)
+	if err != nil {
+		return types.SortedCidSet{}, err
+	}
+	var tsKey types.SortedCidSet
+	for _, s := range strs {
+		c, err := cid.Decode(s)
+		if err != nil {
+			return types.SortedCidSet{}, errors.Wrapf(err, "invalid tipset block cid %q", s)
+		}
+		tsKey.Add(c)
+	}
+	return tsKey, nil
+}
+
+func cidString(c cid.Cid) interface{} {
+	if !c.Defined() {
+		return nil
+	}
+	return c.String()
+}
+
+func cidStrings(cids []cid.Cid) []string {
+	strs := make([]string, len(cids))
+	for i, c := range cids {
+		strs[i] = c.String()
+	}
+	return strs
+}
+
+func attoFILString(v *types.AttoFIL) interface{} {
+	if v == nil {
+		return nil
+	}
+	return v.String()
+}
+
+func bytesString(v *types.BytesAmount) interface{} {
+	if v == nil {
+		return nil
+	}
+	return v.String()
+}
@@ -0,0 +1,180 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/graphql"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type actorNotFound struct{}
+
+func (actorNotFound) Error() string       { return "actor not found" }
+func (actorNotFound) ActorNotFound() bool { return true }
+
+type fakeAPI struct {
+	tipsets []types.TipSet
+	msgs    map[cid.Cid]*msg.ChainMessage
+	actors  map[address.Address]*actor.Actor
+	deals   []*storagedeal.Deal
+	// tsKeys are the tipset keys actors were requested at.
+	tsKeys []types.SortedCidSet
+}
+
+func (api *fakeAPI) ActorGetAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error) {
+	api.tsKeys = append(api.tsKeys, tsKey)
+	act, ok := api.actors[addr]
+	if !ok {
+		return nil, actorNotFound{}
+	}
+	return act, nil
+}
+
+func (api *fakeAPI) ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error) {
+	for _, ts := range api.tipsets {
+		if blk, ok := ts[id]; ok {
+			return blk, nil
+		}
+	}
+	return nil, errors.New("no block")
+}
+
+func (api *fakeAPI) ChainGetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error) {
+	for i := range api.tipsets {
+		if api.tipsets[i].ToSortedCidSet().Equals(tsKey) {
+			return &api.tipsets[i], nil
+		}
+	}
+	return nil, errors.New("no tipset")
+}
+
+func (api *fakeAPI) ChainGetTipSetAtHeight(ctx context.Context, height uint64) (*types.TipSet, error) {
+	if height >= uint64(len(api.tipsets)) {
+		return nil, errors.New("no tipset")
+	}
+	return &api.tipsets[height], nil
+}
+
+func (api *fakeAPI) ChainHead() (*types.TipSet, error) {
+	return &api.tipsets[len(api.tipsets)-1], nil
+}
+
+func (api *fakeAPI) DealsLs() ([]*storagedeal.Deal, error) {
+	return api.deals, nil
+}
+
+func (api *fakeAPI) MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error) {
+	chainMsg, ok := api.msgs[msgCid]
+	return chainMsg, ok, nil
+}
+
+func TestChainSchema(t *testing.T) {
+	tf.UnitTest(t)
+
+	smsg := types.NewSignedMsgs(1, types.NewMockSigner(types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())))[0]
+	msgCid, err := smsg.Cid()
+	require.NoError(t, err)
+	receipt := &types.MessageReceipt{ExitCode: 3, GasAttoFIL: types.NewAttoFILFromFIL(1)}
+
+	genesis := types.NewBlockForTest(nil, 0)
+	blk := types.NewBlockForTest(genesis, 1)
+	blk.Messages = []*types.SignedMessage{smsg}
+	blk.MessageReceipts = []*types.MessageReceipt{receipt}
+
+	proposalCid := types.SomeCid()
+	api := &fakeAPI{
+		tipsets: []types.TipSet{types.RequireNewTipSet(t, genesis), types.RequireNewTipSet(t, blk)},
+		msgs:    map[cid.Cid]*msg.ChainMessage{msgCid: {Message: smsg, Block: blk, Receipt: receipt}},
+		actors:  map[address.Address]*actor.Actor{address.TestAddress: actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(10))},
+		deals: []*storagedeal.Deal{{
+			Miner:    address.TestAddress2,
+			Proposal: &storagedeal.Proposal{Size: types.NewBytesAmount(1024), TotalPrice: types.NewAttoFILFromFIL(2), Duration: 100},
+			Response: &storagedeal.Response{
+				State:       storagedeal.Posted,
+				ProposalCid: proposalCid,
+				ProofInfo:   &storagedeal.ProofInfo{CommitmentMessage: &msgCid},
+			},
+		}},
+	}
+	schema := graphql.NewChainSchema(api)
+
+	execute := func(query string) string {
+		out, err := json.Marshal(schema.Execute(context.Background(), query, nil))
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	t.Run("traverses from tipsets to receipts", func(t *testing.T) {
+		out := execute(`{
+			head {
+				height
+				key
+				parents { height parents { height } }
+				blocks {
+					cid
+					messages { cid from nonce receipt { exitCode gasAttoFIL } block { height } }
+				}
+			}
+		}`)
+		assert.JSONEq(t, fmt.Sprintf(`{"data": {"head": {
+			"height": "1",
+			"key": [%q],
+			"parents": {"height": "0", "parents": null},
+			"blocks": [{
+				"cid": %q,
+				"messages": [{"cid": %q, "from": %q, "nonce": "0", "receipt": {"exitCode": 3, "gasAttoFIL": "1"}, "block": {"height": "1"}}]
+			}]
+		}}}`, blk.Cid(), blk.Cid(), msgCid, smsg.From.String()), out)
+	})
+
+	t.Run("gets tipsets by key and height", func(t *testing.T) {
+		out := execute(fmt.Sprintf(`{ byKey: tipset(key: [%q]) { height } byHeight: tipset(height: 0) { key } }`, blk.Cid()))
+		assert.JSONEq(t, fmt.Sprintf(`{"data": {"byKey": {"height": "1"}, "byHeight": {"key": [%q]}}}`, genesis.Cid()), out)
+
+		out = execute(fmt.Sprintf(`{ tipset(key: [%q], height: 1) { height } }`, blk.Cid()))
+		assert.Contains(t, out, "only one of key and height may be given")
+	})
+
+	t.Run("gets messages and actors", func(t *testing.T) {
+		out := execute(fmt.Sprintf(`{
+			message(cid: %q) { to receipt { exitCode } }
+			missing: message(cid: %q) { to }
+		}`, msgCid, proposalCid))
+		assert.JSONEq(t, fmt.Sprintf(`{"data": {"message": {"to": %q, "receipt": {"exitCode": 3}}, "missing": null}}`, smsg.To.String()), out)
+
+		api.tsKeys = nil
+		out = execute(fmt.Sprintf(`{
+			actor(address: %q) { balance }
+			nobody: actor(address: %q) { balance }
+			tipset(height: 0) { actor(address: %q) { balance } }
+		}`, address.TestAddress.String(), address.TestAddress2.String(), address.TestAddress.String()))
+		assert.JSONEq(t, `{"data": {"actor": {"balance": "10"}, "nobody": null, "tipset": {"actor": {"balance": "10"}}}}`, out)
+		require.Len(t, api.tsKeys, 3)
+		assert.Equal(t, 0, api.tsKeys[0].Len())
+		assert.Equal(t, genesis.Cid(), api.tsKeys[2].ToSlice()[0])
+	})
+
+	t.Run("gets deals and their commitment messages", func(t *testing.T) {
+		out := execute(fmt.Sprintf(`{
+			deals { proposal state size totalPrice duration }
+			deal(proposal: %q) { miner commitmentMessage { cid } }
+		}`, proposalCid))
+		assert.JSONEq(t, fmt.Sprintf(`{"data": {
+			"deals": [{"proposal": %q, "state": "posted", "size": "1024", "totalPrice": "2", "duration": 100}],
+			"deal": {"miner": %q, "commitmentMessage": {"cid": %q}}
+		}}`, proposalCid, address.TestAddress2.String(), msgCid), out)
+	})
+}
@@ -0,0 +1,302 @@
+// Package graphql serves the chain and state over GraphQL, so that clients
+// such as block explorers can fetch related objects, e.g. a tipset, its
+// blocks, their messages and receipts, in a single query.
+//
+// It implements the subset of GraphQL these read-only queries need: a single
+// query operation with aliases, arguments and variables.  Fragments,
+// directives, mutations, subscriptions and introspection beyond __typename
+// are not supported.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// maxDepth is the deepest selection a query may have, bounding the work a
+// query traversing relationships can cause.
+const maxDepth = 12
+
+// Object is an object type of a schema.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type.
+type Field struct {
+	// Type is the object type of the field's values, or nil if they are
+	// scalars, which are encoded as JSON.
+	Type *Object
+	// Resolve returns the value of the field for source, a value of the
+	// object type the field belongs to.  Fields of object type may return a
+	// slice of values, and any field may return nil for null.
+	Resolve func(ctx context.Context, source interface{}, args Args) (interface{}, error)
+}
+
+// Args are the arguments of a field, with variables substituted.
+type Args map[string]interface{}
+
+// String returns the string argument name, and whether it is set.
+func (a Args) String(name string) (string, bool, error) {
+	v, ok := a[name]
+	if !ok || v == nil {
+		return "", false, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", false, errors.Errorf("argument %s must be a string", name)
+	}
+	return s, true, nil
+}
+
+// Int returns the integer argument name, and whether it is set.
+func (a Args) Int(name string) (int64, bool, error) {
+	v, ok := a[name]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+	switch n := v.(type) {
+	case int64:
+		return n, true, nil
+	case float64:
+		// Variables decoded from JSON are float64.
+		if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+			return int64(n), true, nil
+		}
+	}
+	return 0, false, errors.Errorf("argument %s must be an integer", name)
+}
+
+// Strings returns the list of strings argument name, or nil if it is not set.
+// A single string is a list of one.
+func (a Args) Strings(name string) ([]string, error) {
+	v, ok := a[name]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	if s, ok := v.(string); ok {
+		return []string{s}, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("argument %s must be a list of strings", name)
+	}
+	strs := make([]string, len(list))
+	for i, item := range list {
+		if strs[i], ok = item.(string); !ok {
+			return nil, errors.Errorf("argument %s must be a list of strings", name)
+		}
+	}
+	return strs, nil
+}
+
+// Schema is a set of types queries are executed against.
+type Schema struct {
+	// Query is the type of the root of queries.
+	Query *Object
+}
+
+// Response is the result of executing a query.
+type Response struct {
+	// Data holds the fields of the query, or is null if the query could not
+	// be executed at all.
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is an error executing a query.  Fields that fail are null in the
+// data, and the error holds their path.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute executes query with variables.
+func (s *Schema) Execute(ctx context.Context, query string, variables map[string]interface{}) *Response {
+	doc, err := parse(query)
+	if err != nil {
+		return failed(errors.Wrap(err, "syntax error"))
+	}
+
+	vars := map[string]interface{}{}
+	for _, def := range doc.variables {
+		v, ok := variables[def.name]
+		if !ok {
+			v = def.def
+		}
+		if v == nil && def.required {
+			return failed(errors.Errorf("variable $%s is required", def.name))
+		}
+		vars[def.name] = v
+	}
+	if err := validate(s.Query, doc.selection, vars, 1); err != nil {
+		return failed(err)
+	}
+
+	e := &executor{vars: vars}
+	data := e.object(ctx, s.Query, nil, doc.selection, nil)
+	return &Response{Data: data, Errors: e.errs}
+}
+
+func failed(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+// validate checks that a selection of typ only has fields of typ, selecting
+// subfields of exactly the object ones, and only uses declared variables.
+func validate(typ *Object, selection []*field, vars map[string]interface{}, depth int) error {
+	if depth > maxDepth {
+		return errors.Errorf("query is deeper than %d fields", maxDepth)
+	}
+	for _, f := range selection {
+		if f.name == "__typename" {
+			if f.selection != nil {
+				return errors.New("field __typename has no subfields")
+			}
+			continue
+		}
+		fld, ok := typ.Fields[f.name]
+		if !ok {
+			return errors.Errorf("type %s has no field %s", typ.Name, f.name)
+		}
+		for _, arg := range f.args {
+			if err := checkVariables(arg, vars); err != nil {
+				return err
+			}
+		}
+		switch {
+		case fld.Type == nil && f.selection != nil:
+			return errors.Errorf("field %s of %s is a scalar and has no subfields", f.name, typ.Name)
+		case fld.Type != nil && f.selection == nil:
+			return errors.Errorf("field %s of %s must select subfields of %s", f.name, typ.Name, fld.Type.Name)
+		case fld.Type != nil:
+			if err := validate(fld.Type, f.selection, vars, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkVariables(v interface{}, vars map[string]interface{}) error {
+	switch v := v.(type) {
+	case variable:
+		if _, ok := vars[string(v)]; !ok {
+			return errors.Errorf("variable $%s is not declared", v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := checkVariables(item, vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// executor executes a validated query, collecting the errors of fields.
+type executor struct {
+	vars map[string]interface{}
+	errs []*Error
+}
+
+// object returns the selected fields of source, of type typ, in the order of
+// the query.
+func (e *executor) object(ctx context.Context, typ *Object, source interface{}, selection []*field, path []interface{}) orderedObject {
+	obj := make(orderedObject, 0, len(selection))
+	for _, f := range selection {
+		fpath := append(append([]interface{}{}, path...), f.key())
+		if f.name == "__typename" {
+			obj = append(obj, entry{f.key(), typ.Name})
+			continue
+		}
+
+		fld := typ.Fields[f.name]
+		v, err := fld.Resolve(ctx, source, e.args(f.args))
+		if err != nil {
+			e.errs = append(e.errs, &Error{Message: err.Error(), Path: fpath})
+			obj = append(obj, entry{f.key(), nil})
+			continue
+		}
+		obj = append(obj, entry{f.key(), e.value(ctx, fld.Type, v, f.selection, fpath)})
+	}
+	return obj
+}
+
+// value returns v, a value of typ or a slice of them, with the selection of
+// objects.
+func (e *executor) value(ctx context.Context, typ *Object, v interface{}, selection []*field, path []interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if v == nil || ((rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Slice) && rv.IsNil()) {
+		return nil
+	}
+	if typ == nil {
+		return v
+	}
+	if rv.Kind() == reflect.Slice {
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			ipath := append(append([]interface{}{}, path...), i)
+			list[i] = e.value(ctx, typ, rv.Index(i).Interface(), selection, ipath)
+		}
+		return list
+	}
+	return e.object(ctx, typ, v, selection, path)
+}
+
+// args substitutes the variables of args.
+func (e *executor) args(args map[string]interface{}) Args {
+	out := Args{}
+	for name, v := range args {
+		out[name] = e.substitute(v)
+	}
+	return out
+}
+
+func (e *executor) substitute(v interface{}) interface{} {
+	switch v := v.(type) {
+	case variable:
+		return e.vars[string(v)]
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = e.substitute(item)
+		}
+		return list
+	}
+	return v
+}
+
+// orderedObject is an object encoding its fields in order, as responses
+// must follow the order of the query.
+type orderedObject []entry
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// MarshalJSON encodes o as a JSON object.
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	for i, ent := range o {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		k, err := json.Marshal(ent.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(ent.value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, k...), ':'), v...)
+	}
+	return append(buf, '}'), nil
+}
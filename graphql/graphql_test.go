@@ -0,0 +1,168 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/graphql"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+// numberSchema is a schema of numbers, each leading to the next.
+func numberSchema() *graphql.Schema {
+	numberType := &graphql.Object{Name: "Number"}
+	numberType.Fields = map[string]*graphql.Field{
+		"value": {Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+			return source, nil
+		}},
+		"next": {Type: numberType, Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+			return source.(int64) + 1, nil
+		}},
+		"upTo": {Type: numberType, Resolve: func(_ context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			end, _, err := args.Int("end")
+			if err != nil {
+				return nil, err
+			}
+			var nums []int64
+			for n := source.(int64); n <= end; n++ {
+				nums = append(nums, n)
+			}
+			return nums, nil
+		}},
+		"fail": {Resolve: func(context.Context, interface{}, graphql.Args) (interface{}, error) {
+			return nil, errors.New("failed")
+		}},
+	}
+	return &graphql.Schema{Query: &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"number": {Type: numberType, Resolve: func(_ context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+			n, ok, err := args.Int("n")
+			if err != nil || !ok {
+				return nil, err
+			}
+			return n, nil
+		}},
+		"echo": {Resolve: func(_ context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+			return args.Strings("s")
+		}},
+	}}}
+}
+
+func execute(t *testing.T, query string, vars map[string]interface{}) string {
+	out, err := json.Marshal(numberSchema().Execute(context.Background(), query, vars))
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestExecute(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("fields follow the order of the query", func(t *testing.T) {
+		out := execute(t, `{ number(n: 1) { value next { next { value } value } } }`, nil)
+		assert.Equal(t, `{"data":{"number":{"value":1,"next":{"next":{"value":3},"value":2}}}}`, out)
+	})
+
+	t.Run("aliases, lists and __typename", func(t *testing.T) {
+		out := execute(t, `{
+			one: number(n: 1) { __typename }
+			# comments are ignored
+			range: number(n: 2) { upTo(end: 4) { value } }
+			echo(s: ["a", "b\"c"])
+		}`, nil)
+		assert.Equal(t, `{"data":{"one":{"__typename":"Number"},"range":{"upTo":[{"value":2},{"value":3},{"value":4}]},"echo":["a","b\"c"]}}`, out)
+	})
+
+	t.Run("variables", func(t *testing.T) {
+		query := `query Numbers($n: Int!, $s: [String!] = ["x"]) { number(n: $n) { value } echo(s: $s) }`
+		// Variables decoded from JSON are float64.
+		assert.Equal(t, `{"data":{"number":{"value":5},"echo":["x"]}}`, execute(t, query, map[string]interface{}{"n": float64(5)}))
+		assert.Equal(t, `{"data":null,"errors":[{"message":"variable $n is required"}]}`, execute(t, query, nil))
+
+		out := execute(t, `{ number(n: $n) { value } }`, nil)
+		assert.Equal(t, `{"data":null,"errors":[{"message":"variable $n is not declared"}]}`, out)
+	})
+
+	t.Run("nulls", func(t *testing.T) {
+		assert.Equal(t, `{"data":{"number":null}}`, execute(t, `{ number { value } }`, nil))
+		assert.Equal(t, `{"data":{"number":{"upTo":null}}}`, execute(t, `{ number(n: 3) { upTo(end: 1) { value } } }`, nil))
+	})
+
+	t.Run("field errors null the field", func(t *testing.T) {
+		out := execute(t, `{ number(n: 1) { value fail } }`, nil)
+		assert.Equal(t, `{"data":{"number":{"value":1,"fail":null}},"errors":[{"message":"failed","path":["number","fail"]}]}`, out)
+
+		out = execute(t, `{ number(n: 1) { upTo(end: 2) { f: fail } } }`, nil)
+		assert.Contains(t, out, `"path":["number","upTo",1,"f"]`)
+
+		out = execute(t, `{ number(n: "one") { value } }`, nil)
+		assert.Equal(t, `{"data":{"number":null},"errors":[{"message":"argument n must be an integer","path":["number"]}]}`, out)
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		for query, msg := range map[string]string{
+			`{ number(n: 1) { value `:                 "syntax error",
+			`{ nope }`:                                "type Query has no field nope",
+			`{ number(n: 1) }`:                        "must select subfields of Number",
+			`{ number(n: 1) { value { next } } }`:     "is a scalar and has no subfields",
+			`mutation { number(n: 1) { value } }`:     "mutation operations are not supported",
+			`{ number(n: 1) { ...Fields } }`:          "fragments are not supported",
+			`{ a: echo(s: "a") } { b: echo(s: "b") }`: "expected a single operation",
+		} {
+			out := execute(t, query, nil)
+			assert.Contains(t, out, `"data":null`, query)
+			assert.Contains(t, out, msg, query)
+		}
+
+		deep := "{ number(n: 1) " + strings.Repeat("{ next ", 20) + "{ value }" + strings.Repeat("}", 21)
+		out := execute(t, deep, nil)
+		assert.Contains(t, out, "query is deeper than")
+	})
+}
+
+func TestHandler(t *testing.T) {
+	tf.UnitTest(t)
+
+	ts := httptest.NewServer(graphql.Handler(numberSchema()))
+	defer ts.Close()
+
+	read := func(resp *http.Response, err error) (int, string) {
+		require.NoError(t, err)
+		defer resp.Body.Close() // nolint: errcheck
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := read(http.Post(ts.URL, "application/json", bytes.NewReader([]byte(
+		`{"query": "query($n: Int) { number(n: $n) { value } }", "variables": {"n": 7}}`,
+	))))
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"data":{"number":{"value":7}}}`, body)
+
+	status, body = read(http.Get(ts.URL + "?" + url.Values{
+		"query":     {"query($n: Int) { number(n: $n) { value } }"},
+		"variables": {`{"n": 8}`},
+	}.Encode()))
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"data":{"number":{"value":8}}}`, body)
+
+	status, _ = read(http.Get(ts.URL))
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = read(http.Post(ts.URL, "application/json", bytes.NewReader([]byte("{"))))
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL, nil)
+	require.NoError(t, err)
+	status, _ = read(http.DefaultClient.Do(req))
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+}
@@ -0,0 +1,59 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("graphql")
+
+// maxRequestSize is the largest request body accepted, in bytes.
+const maxRequestSize = 1 << 20
+
+// request is a query as sent over HTTP.  Only one operation may be sent, so
+// the operation name, if any, is ignored.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Handler serves queries against schema, POSTed as a JSON object with query
+// and variables members, or sent in the query and variables parameters of a
+// GET.
+func Handler(schema *Schema) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		switch r.Method {
+		case http.MethodGet:
+			req.Query = r.URL.Query().Get("query")
+			if vars := r.URL.Query().Get("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					http.Error(w, "invalid variables: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+				http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if req.Query == "" {
+			http.Error(w, "missing query", http.StatusBadRequest)
+			return
+		}
+
+		resp := schema.Execute(r.Context(), req.Query, req.Variables)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Warningf("failed to write graphql response: %s", err)
+		}
+	})
+}
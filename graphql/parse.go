@@ -0,0 +1,373 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// document is a parsed query operation.
+type document struct {
+	variables []*variableDef
+	selection []*field
+}
+
+// variableDef declares a variable of the operation.
+type variableDef struct {
+	name     string
+	required bool
+	// def is the default value of the variable, if it has one.
+	def interface{}
+}
+
+// field is a selected field along with its arguments and, for fields of
+// object type, its own selection.
+type field struct {
+	alias     string
+	name      string
+	args      map[string]interface{}
+	selection []*field
+}
+
+// key returns the name of the field in the response.
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// variable is a reference to a variable in a value.
+type variable string
+
+// token kinds.
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// lex splits query into tokens, dropping whitespace, commas and comments.
+func lex(query string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "..."):
+			toks = append(toks, token{tokPunct, "...", i})
+			i += 3
+		case strings.IndexByte("{}()[]:$!=@", c) >= 0:
+			toks = append(toks, token{tokPunct, string(c), i})
+			i++
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(query) && (query[i] == '_' || unicode.IsLetter(rune(query[i])) || unicode.IsDigit(rune(query[i]))) {
+				i++
+			}
+			toks = append(toks, token{tokName, query[start:i], start})
+		case c == '-' || unicode.IsDigit(rune(c)):
+			start := i
+			i++
+			kind := tokInt
+			for i < len(query) && strings.IndexByte("0123456789.eE+-", query[i]) >= 0 {
+				if strings.IndexByte(".eE", query[i]) >= 0 {
+					kind = tokFloat
+				}
+				i++
+			}
+			toks = append(toks, token{kind, query[start:i], start})
+		case c == '"':
+			start := i
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(query) {
+				return nil, errors.Errorf("unterminated string at %d", start)
+			}
+			i++
+			toks = append(toks, token{tokString, query[start:i], start})
+		default:
+			return nil, errors.Errorf("unexpected character %q at %d", c, i)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(query)}), nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parse parses a query document holding a single query operation, in either
+// the shorthand form "{ ... }" or "query Name($var: Type) { ... }".
+// Mutations, subscriptions, fragments and directives are not supported.
+func parse(query string) (*document, error) {
+	toks, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+
+	doc := &document{}
+	if p.peek().kind == tokName {
+		switch op := p.next(); op.text {
+		case "query":
+		case "mutation", "subscription":
+			return nil, errors.Errorf("%s operations are not supported", op.text)
+		case "fragment":
+			return nil, errors.New("fragments are not supported")
+		default:
+			return nil, p.errorf(op, "expected an operation")
+		}
+		if p.peek().kind == tokName {
+			p.next()
+		}
+		if p.peekPunct("(") {
+			if doc.variables, err = p.parseVariableDefs(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if doc.selection, err = p.parseSelection(); err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "expected a single operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) peekPunct(s string) bool {
+	tok := p.peek()
+	return tok.kind == tokPunct && tok.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if tok := p.next(); tok.kind != tokPunct || tok.text != s {
+		return p.errorf(tok, "expected %q", s)
+	}
+	return nil
+}
+
+func (p *parser) expectName() (string, error) {
+	tok := p.next()
+	if tok.kind != tokName {
+		return "", p.errorf(tok, "expected a name")
+	}
+	return tok.text, nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	found := tok.text
+	if tok.kind == tokEOF {
+		found = "end of query"
+	}
+	return errors.Errorf("%s at %d, found %s", fmt.Sprintf(format, args...), tok.pos, found)
+}
+
+func (p *parser) parseVariableDefs() ([]*variableDef, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var defs []*variableDef
+	for !p.peekPunct(")") {
+		if err := p.expectPunct("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		def := &variableDef{name: name}
+		if def.required, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if p.peekPunct("=") {
+			p.next()
+			if def.def, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		defs = append(defs, def)
+	}
+	p.next()
+	return defs, nil
+}
+
+// parseType skips a type reference, returning whether it is non null.  The
+// types of variables aren't checked: arguments check the values they get.
+func (p *parser) parseType() (bool, error) {
+	if p.peekPunct("[") {
+		p.next()
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return false, err
+	}
+	if p.peekPunct("!") {
+		p.next()
+		return true, nil
+	}
+	return false, nil
+}
+
+func (p *parser) parseSelection() ([]*field, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var fields []*field
+	for !p.peekPunct("}") {
+		if p.peekPunct("...") {
+			return nil, p.errorf(p.peek(), "fragments are not supported")
+		}
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	p.next()
+	if len(fields) == 0 {
+		return nil, errors.New("empty selection")
+	}
+	return fields, nil
+}
+
+func (p *parser) parseField() (*field, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	f := &field{name: name}
+	if p.peekPunct(":") {
+		p.next()
+		f.alias = name
+		if f.name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peekPunct("(") {
+		p.next()
+		f.args = map[string]interface{}{}
+		for !p.peekPunct(")") {
+			arg, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(":"); err != nil {
+				return nil, err
+			}
+			if f.args[arg], err = p.parseValue(false); err != nil {
+				return nil, err
+			}
+		}
+		p.next()
+	}
+	if p.peekPunct("@") {
+		return nil, p.errorf(p.peek(), "directives are not supported")
+	}
+	if p.peekPunct("{") {
+		if f.selection, err = p.parseSelection(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// parseValue parses a literal or, unless constant, a variable.  Input
+// objects are not supported.
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid integer")
+		}
+		return n, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid float")
+		}
+		return f, nil
+	case tokString:
+		var s string
+		if err := json.Unmarshal([]byte(tok.text), &s); err != nil {
+			return nil, p.errorf(tok, "invalid string")
+		}
+		return s, nil
+	case tokName:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// Enum values are passed as their name.
+		return tok.text, nil
+	case tokPunct:
+		switch {
+		case tok.text == "$" && !constant:
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			return variable(name), nil
+		case tok.text == "[":
+			list := []interface{}{}
+			for !p.peekPunct("]") {
+				v, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			p.next()
+			return list, nil
+		}
+	}
+	return nil, p.errorf(tok, "expected a value")
+}
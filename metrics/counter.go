@@ -5,6 +5,7 @@ import (
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 // Int64Counter wraps an opencensus int64 measure that is uses as a counter.
@@ -14,7 +15,9 @@ type Int64Counter struct {
 }
 
 // NewInt64Counter creates a new Int64Counter with demensionless units.
-func NewInt64Counter(name, desc string) *Int64Counter {
+// Counts are broken down by the values of keys in the context they are
+// recorded with.
+func NewInt64Counter(name, desc string, keys ...tag.Key) *Int64Counter {
 	log.Infof("registering int64 counter: %s - %s", name, desc)
 	iMeasure := stats.Int64(name, desc, stats.UnitDimensionless)
 	iView := &view.View{
@@ -22,6 +25,7 @@ func NewInt64Counter(name, desc string) *Int64Counter {
 		Measure:     iMeasure,
 		Description: desc,
 		Aggregation: view.Count(),
+		TagKeys:     keys,
 	}
 	if err := view.Register(iView); err != nil {
 		// a panic here indicates a developer error when creating a view.
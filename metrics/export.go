@@ -8,6 +8,7 @@ import (
 	"contrib.go.opencensus.io/exporter/prometheus"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr-net"
+	"github.com/pkg/errors"
 	prom "github.com/prometheus/client_golang/prometheus"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/trace"
@@ -15,7 +16,9 @@ import (
 	"github.com/filecoin-project/go-filecoin/config"
 )
 
-// RegisterPrometheusEndpoint registers and serves prometheus metrics
+// RegisterPrometheusEndpoint registers and serves prometheus metrics at
+// /metrics on the endpoint of cfg, along with metrics of the Go runtime and
+// of the process.  It fails if the endpoint can't be listened on.
 func RegisterPrometheusEndpoint(cfg *config.MetricsConfig) error {
 	if !cfg.PrometheusEnabled {
 		return nil
@@ -33,18 +36,20 @@ func RegisterPrometheusEndpoint(cfg *config.MetricsConfig) error {
 		return err
 	}
 
-	_, promAddr, err := manet.DialArgs(promma)
+	lis, err := manet.Listen(promma)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "failed to listen on metrics endpoint %s", cfg.PrometheusEndpoint)
 	}
 
 	// setup prometheus
 	registry := prom.NewRegistry()
+	registry.MustRegister(prom.NewGoCollector(), prom.NewProcessCollector(prom.ProcessCollectorOpts{}))
 	pe, err := prometheus.NewExporter(prometheus.Options{
 		Namespace: "filecoin",
 		Registry:  registry,
 	})
 	if err != nil {
+		lis.Close() // nolint: errcheck
 		return err
 	}
 
@@ -54,7 +59,7 @@ func RegisterPrometheusEndpoint(cfg *config.MetricsConfig) error {
 	go func() {
 		mux := http.NewServeMux()
 		mux.Handle("/metrics", pe)
-		if err := http.ListenAndServe(promAddr, mux); err != nil {
+		if err := http.Serve(manet.NetListener(lis), mux); err != nil {
 			log.Errorf("failed to serve /metrics endpoint on %v", err)
 		}
 	}()
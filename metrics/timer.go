@@ -6,11 +6,13 @@ import (
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 // NewTimer creates a Float64Timer that wraps an opencensus float64 measurement.
-// The time defaults to milliseconds.
-func NewTimer(name, desc string) *Float64Timer {
+// The time defaults to milliseconds. Measurements are broken down by the
+// values of keys in the context they are recorded with.
+func NewTimer(name, desc string, keys ...tag.Key) *Float64Timer {
 	log.Infof("registering timer: %s - %s", name, desc)
 	fMeasure := stats.Float64(name, desc, stats.UnitMilliseconds)
 	fView := &view.View{
@@ -19,6 +21,7 @@ func NewTimer(name, desc string) *Float64Timer {
 		Description: desc,
 		// [>=0ms, >=25ms, >=50ms, >=75ms, >=100ms, >=200ms, >=400ms, >=600ms, >=800ms, >=1s, >=2s, >=4s, >=8s]
 		Aggregation: view.Distribution(25, 50, 75, 100, 200, 400, 600, 800, 1000, 2000, 4000, 8000),
+		TagKeys:     keys,
 	}
 	if err := view.Register(fView); err != nil {
 		// a panic here indicates a developer error when creating a view.
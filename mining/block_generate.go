@@ -10,10 +10,19 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
 )
 
+var (
+	blockMessagesGauge = metrics.NewInt64Gauge("mining/block_messages", "Number of messages in the last block generated")
+	blockGasGauge      = metrics.NewInt64Gauge("mining/block_gas_percent", "Total gas limit of the messages in the last block generated, as a percentage of the block gas limit")
+	blockSizeGauge     = metrics.NewInt64Gauge("mining/block_size_percent", "Size of the last block generated, as a percentage of the block size limit")
+)
+
 // Generate returns a new block created from the messages in the pool.
 func (w *DefaultWorker) Generate(ctx context.Context,
 	baseTipSet types.TipSet,
@@ -54,7 +63,7 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 
 	pending := w.messageSource.Pending()
 	mq := NewMessageQueue(pending)
-	messages := mq.Drain()
+	messages := selectMessages(&mq, w.blockGasLimit)
 
 	vms := vm.NewStorageMap(w.blockstore)
 	res, err := w.processor.ApplyMessagesAndPayRewards(ctx, stateTree, vms, messages, w.minerOwnerAddr, types.NewBlockHeight(blockHeight), ancestors)
@@ -76,17 +85,38 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 		receipts = append(receipts, r.Receipt)
 	}
 
+	// The signatures of BLS messages are replaced by their aggregate.
+	blockMessages, blsAggregateSig, err := types.AggregateBLSMessages(res.SuccessfulMessages)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate aggregate BLS signatures")
+	}
+
 	next := &types.Block{
 		Miner:           w.minerAddr,
 		Height:          types.Uint64(blockHeight),
-		Messages:        res.SuccessfulMessages,
+		Messages:        blockMessages,
+		BLSAggregateSig: blsAggregateSig,
 		MessageReceipts: receipts,
 		Parents:         baseTipSet.ToSortedCidSet(),
 		ParentWeight:    types.Uint64(weight),
 		Proof:           proof,
 		StateRoot:       newStateTreeCid,
 		Ticket:          ticket,
+		Timestamp:       types.Uint64(time.Now().Unix()),
+	}
+	// Peers would reject a block timestamped before its parents, which means
+	// the local clock is behind theirs.
+	if err := consensus.ValidateTimestampOrder(baseTipSet, next); err != nil {
+		log.Errorf("generated block is timestamped before its parents, check the system clock: %s", err)
+		return nil, errors.Wrap(err, "generate timestamp")
 	}
+	size := next.Size()
+	if size > types.BlockSizeLimit {
+		return nil, errors.Errorf("generated block is %d bytes, limit is %d", size, types.BlockSizeLimit)
+	}
+	blockMessagesGauge.Set(ctx, int64(len(next.Messages)))
+	blockGasGauge.Set(ctx, int64(types.TotalGasLimit(next.Messages)*100/types.BlockGasLimit))
+	blockSizeGauge.Set(ctx, int64(size*100/types.BlockSizeLimit))
 
 	for i, msg := range res.PermanentFailures {
 		// We will not be able to apply this message in the future because the error was permanent.
@@ -111,3 +141,51 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 
 	return next, nil
 }
+
+// messageSizeBudget is the total size of messages the miner packs into a
+// block.  The rest of the block size limit is left for the header and
+// receipts.
+const messageSizeBudget = types.BlockSizeLimit / 2
+
+// selectMessages packs messages from the queue into a block until the block
+// message limit or the message size budget is reached, or the queue is empty.
+// Messages over the per-message size limit are skipped.
+//
+// The total gas limit of the messages packed is at most gasLimit.  As the
+// fees a message pays are its gas limit times its gas price, packing is
+// solved greedily by taking messages in order of gas price, the fee per unit
+// of gas, and skipping those that don't fit in the remaining gas.  Once a
+// message of a sender is skipped its later messages can't be applied, so they
+// are skipped too.
+func selectMessages(mq *MessageQueue, gasLimit types.GasUnits) []*types.SignedMessage {
+	var out []*types.SignedMessage
+	totalSize := 0
+	remainingGas := gasLimit
+	skipped := make(map[address.Address]struct{})
+	for msg, ok := mq.Pop(); ok && len(out) < types.BlockMessageLimit; msg, ok = mq.Pop() {
+		if _, ok := skipped[msg.From]; ok {
+			continue
+		}
+		if msg.GasLimit > remainingGas {
+			skipped[msg.From] = struct{}{}
+			continue
+		}
+		size, err := msg.Size()
+		if err != nil {
+			log.Warningf("failed to serialize message: %s", err)
+			skipped[msg.From] = struct{}{}
+			continue
+		}
+		if size > types.MessageSizeLimit {
+			skipped[msg.From] = struct{}{}
+			continue
+		}
+		if totalSize+size > messageSizeBudget {
+			break
+		}
+		totalSize += size
+		remainingGas -= msg.GasLimit
+		out = append(out, msg)
+	}
+	return out
+}
@@ -10,8 +10,8 @@ import (
 )
 
 // MessageQueue is a priority queue of messages from different actors. Messages are ordered
-// by decreasing gas price, subject to the constraint that messages from a single actor are
-// always in increasing nonce order.
+// by decreasing gas price, then increasing nonce, subject to the constraint that messages
+// from a single actor are always in increasing nonce order.
 // All messages for a queue are inserted at construction, after which messages may only
 // be popped.
 // Potential improvements include:
@@ -91,13 +91,17 @@ type queueHeap []nonceQueue
 
 func (pq queueHeap) Len() int { return len(pq) }
 
-// Less implements Heap.Interface.Less to compare items on gas price and sender address.
+// Less implements Heap.Interface.Less to compare items on gas price, nonce and sender address.
 func (pq queueHeap) Less(i, j int) bool {
 	delta := pq[i][0].MeteredMessage.GasPrice.Sub(&pq[j][0].MeteredMessage.GasPrice)
 	if !delta.Equal(types.ZeroAttoFIL) {
 		// We want Pop to give us the highest gas price, so use GreaterThan.
 		return delta.GreaterThan(types.ZeroAttoFIL)
 	}
+	// At equal gas prices, older messages of their senders go first.
+	if pq[i][0].Nonce != pq[j][0].Nonce {
+		return pq[i][0].Nonce < pq[j][0].Nonce
+	}
 	// Secondarily order by address to give a stable ordering.
 	return bytes.Compare(pq[i][0].From.Bytes(), pq[j][0].From.Bytes()) < 0
 }
@@ -86,6 +86,20 @@ func TestMessageQueueOrder(t *testing.T) {
 		assert.True(t, q.Empty())
 	})
 
+	t.Run("orders equal gas prices by nonce", func(t *testing.T) {
+		msgs := []*types.SignedMessage{
+			sign(a0, to, 3, 0, 2),
+			sign(a1, to, 1, 0, 2),
+			sign(a2, to, 2, 0, 2),
+			sign(a2, to, 5, 0, 3),
+		}
+		q := NewMessageQueue(msgs)
+		expected := []*types.SignedMessage{msgs[1], msgs[2], msgs[3], msgs[0]}
+		actual := q.Drain()
+		assert.Equal(t, expected, actual)
+		assert.True(t, q.Empty())
+	})
+
 	t.Run("nonce overrides gas price", func(t *testing.T) {
 		msgs := []*types.SignedMessage{
 			sign(a0, to, 0, 0, 1),
@@ -100,3 +114,62 @@ func TestMessageQueueOrder(t *testing.T) {
 		assert.True(t, q.Empty())
 	})
 }
+
+func TestSelectMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	mockSigner := types.NewMockSigner(types.MustGenerateKeyInfo(2, types.GenerateKeyInfoSeed()))
+	from := mockSigner.Addresses[0]
+	to := mockSigner.Addresses[1]
+
+	sign := func(nonce uint64, params []byte) *types.SignedMessage {
+		msg := types.NewMessage(from, to, nonce, types.ZeroAttoFIL, "", params)
+		s, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("skips oversized messages and the later messages of their sender", func(t *testing.T) {
+		small1, big, small2 := sign(0, nil), sign(1, make([]byte, types.MessageSizeLimit)), sign(2, nil)
+		msg := types.NewMessage(to, from, 0, types.ZeroAttoFIL, "", nil)
+		other, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+
+		mq := NewMessageQueue([]*types.SignedMessage{small1, big, small2, other})
+		selected := selectMessages(&mq, types.BlockGasLimit)
+		assert.Len(t, selected, 2)
+		assert.Contains(t, selected, small1)
+		assert.Contains(t, selected, other)
+	})
+
+	t.Run("respects the block message limit", func(t *testing.T) {
+		var msgs []*types.SignedMessage
+		for i := 0; i < types.BlockMessageLimit+10; i++ {
+			msgs = append(msgs, sign(uint64(i), nil))
+		}
+		mq := NewMessageQueue(msgs)
+		selected := selectMessages(&mq, types.BlockGasLimit)
+		require.Len(t, selected, types.BlockMessageLimit)
+		assert.Equal(t, msgs[:types.BlockMessageLimit], selected)
+	})
+
+	t.Run("packs messages by gas price into the gas limit", func(t *testing.T) {
+		signer, _ := types.NewMockSignersAndKeyInfo(3)
+		a, b, c := signer.Addresses[0], signer.Addresses[1], signer.Addresses[2]
+		msg := func(from address.Address, nonce uint64, gasPrice int64, gasLimit uint64) *types.SignedMessage {
+			m := types.NewMessage(from, to, nonce, types.ZeroAttoFIL, "", nil)
+			s, err := types.NewSignedMessage(*m, &signer, types.NewGasPrice(gasPrice), types.NewGasUnits(gasLimit))
+			require.NoError(t, err)
+			return s
+		}
+
+		a0, a1 := msg(a, 0, 10, 600), msg(a, 1, 10, 300)
+		// b's first message doesn't fit once a's are packed, so neither can
+		// its second.
+		b0, b1 := msg(b, 0, 5, 200), msg(b, 1, 5, 50)
+		c0 := msg(c, 0, 1, 100)
+		mq := NewMessageQueue([]*types.SignedMessage{a0, a1, b0, b1, c0})
+
+		assert.Equal(t, []*types.SignedMessage{a0, a1, c0}, selectMessages(&mq, types.NewGasUnits(1000)))
+	})
+}
@@ -39,16 +39,22 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+var (
+	delayRoundsCt      = metrics.NewInt64Counter("mining/propagation_delay_rounds", "Number of mining rounds that waited for the propagation delay")
+	delayBaseChangedCt = metrics.NewInt64Counter("mining/propagation_delay_base_changed", "Number of mining rounds whose base tipset changed while waiting for the propagation delay")
+)
+
 // Scheduler is the mining interface consumers use. When you Start() the
 // scheduler it returns two channels (inCh, outCh) and a sync.WaitGroup:
 //   - inCh: the caller sends Inputs to mine on to this channel.
 //   - outCh: the scheduler sends Outputs to the caller on this channel.
 //   - doneWg: signals that the scheduler and any goroutines it launched
-//             have stopped. (Context cancelation happens async, so you
-//             need some way to know when it has actually stopped.)
+//     have stopped. (Context cancelation happens async, so you
+//     need some way to know when it has actually stopped.)
 //
 // Once Start()ed, the Scheduler can be stopped by canceling its miningCtx,
 // which will signal on doneWg when it's actually done. Canceling miningCtx
@@ -106,7 +112,14 @@ func (s *timingScheduler) Start(miningCtx context.Context) (<-chan Output, *sync
 				return
 			default:
 			}
-			// This is the sleep during which we collect. TODO: maybe this should vary?
+			// Note the head at the start of the round so we can tell whether
+			// waiting changed the mining base.
+			var earlyKey types.SortedCidSet
+			if early, _ := s.pollHeadFunc(); early != nil {
+				earlyKey = early.ToSortedCidSet()
+			}
+			// This is the sleep during which we collect late arriving blocks
+			// and messages.
 			time.Sleep(s.mineDelay)
 			// Ask for the heaviest tipset.
 			base, _ := s.pollHeadFunc()
@@ -114,6 +127,10 @@ func (s *timingScheduler) Start(miningCtx context.Context) (<-chan Output, *sync
 				outCh <- NewOutput(nil, errors.New("cannot mine on unset (nil) head"))
 				return
 			}
+			delayRoundsCt.Inc(miningCtx, 1)
+			if !earlyKey.Equals(base.ToSortedCidSet()) {
+				delayBaseChangedCt.Inc(miningCtx, 1)
+			}
 			if prevWon && prevBase.Equals(*base) {
 				// Skip this round, this likely means that the new head has not propagated yet through the system.
 				// TODO: investigate if there is a better way to handle this situation.
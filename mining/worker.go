@@ -16,6 +16,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
@@ -23,6 +24,12 @@ import (
 
 var log = logging.Logger("mining")
 
+var (
+	roundsCt      = metrics.NewInt64Counter("mining/rounds", "Number of mining rounds in which a ticket was drawn")
+	blocksMinedCt = metrics.NewInt64Counter("mining/blocks_mined", "Number of blocks generated from winning tickets")
+	minedErrCt    = metrics.NewInt64Counter("mining/block_generate_error", "Number of winning tickets whose block failed to generate")
+)
+
 // DefaultBlockTime is the estimated proving period time.
 // We define this so that we can fake mining in the current incomplete system.
 const DefaultBlockTime = 30 * time.Second
@@ -92,6 +99,14 @@ type DefaultWorker struct {
 	blockstore    blockstore.Blockstore
 	cstore        *hamt.CborIpldStore
 	blockTime     time.Duration
+
+	// scheduledProducer, if set, is the only miner that mines, winning
+	// every round without an election.
+	scheduledProducer address.Address
+
+	// blockGasLimit is the total gas limit of the messages packed into a
+	// block.
+	blockGasLimit types.GasUnits
 }
 
 // NewDefaultWorker instantiates a new Worker.
@@ -161,7 +176,27 @@ func NewDefaultWorkerWithDeps(messageSource MessageSource,
 		minerPubKey:    minerPubKey,
 		blockTime:      bt,
 		workerSigner:   workerSigner,
+		blockGasLimit:  types.BlockGasLimit,
+	}
+}
+
+// SetScheduledProducer makes the worker skip the election and mine a block
+// every round if it is mining for producer, and never mine otherwise.  The
+// chain must be validated by consensus.Authority with producer among its
+// producers.
+func (w *DefaultWorker) SetScheduledProducer(producer address.Address) {
+	w.scheduledProducer = producer
+}
+
+// SetBlockGasLimit makes the worker pack messages with a total gas limit of
+// at most limit into its blocks.  It can't be raised above the block gas
+// limit blocks are validated against.
+func (w *DefaultWorker) SetBlockGasLimit(limit types.GasUnits) error {
+	if limit > types.BlockGasLimit {
+		return errors.Errorf("gas limit %d is above the block gas limit %d", limit, types.BlockGasLimit)
 	}
+	w.blockGasLimit = limit
+	return nil
 }
 
 // DoSomeWorkFunc is a dummy function that mimics doing something time-consuming
@@ -219,15 +254,20 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 			return false
 		}
 	}
+	roundsCt.Inc(ctx, 1)
 
-	// TODO: Test the interplay of isWinningTicket() and createPoSTFunc()
-	// https://github.com/filecoin-project/go-filecoin/issues/1791
-	weHaveAWinner, err := consensus.IsWinningTicket(ctx, w.blockstore, w.powerTable, st, ticket, w.minerAddr)
-
-	if err != nil {
-		log.Errorf("Worker.Mine couldn't compute ticket: %s", err.Error())
-		outCh <- Output{Err: err}
-		return false
+	var weHaveAWinner bool
+	if w.scheduledProducer.Empty() {
+		// TODO: Test the interplay of isWinningTicket() and createPoSTFunc()
+		// https://github.com/filecoin-project/go-filecoin/issues/1791
+		weHaveAWinner, err = consensus.IsWinningTicket(ctx, w.blockstore, w.powerTable, st, ticket, w.minerAddr)
+		if err != nil {
+			log.Errorf("Worker.Mine couldn't compute ticket: %s", err.Error())
+			outCh <- Output{Err: err}
+			return false
+		}
+	} else {
+		weHaveAWinner = w.minerAddr == w.scheduledProducer
 	}
 
 	if weHaveAWinner {
@@ -235,6 +275,9 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 		if err == nil {
 			log.SetTag(ctx, "block", next)
 			log.Debugf("Worker.Mine generates new winning block! %s", next.Cid().String())
+			blocksMinedCt.Inc(ctx, 1)
+		} else {
+			minedErrCt.Inc(ctx, 1)
 		}
 		outCh <- NewOutput(next, err)
 		return true
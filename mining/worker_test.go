@@ -94,6 +94,33 @@ func Test_Mine(t *testing.T) {
 		assert.False(t, doSomeWorkCalled)
 		cancel()
 	})
+
+	t.Run("Scheduled producer mines without an election", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// The miner has no power, so it can't win an election.
+		worker := mining.NewDefaultWorkerWithDeps(pool, getStateTree, getWeightTest, getAncestors, th.NewTestProcessor(),
+			th.NewTestPowerTableView(0, 1), bs, cst, minerAddr, minerOwnerAddr, blockSignerAddr, mockSigner, th.BlockTimeTest, CreatePoSTFunc)
+		worker.SetScheduledProducer(minerAddr)
+
+		outCh := make(chan mining.Output, 1)
+		assert.True(t, worker.Mine(ctx, tipSet, 0, outCh))
+		r := <-outCh
+		require.NoError(t, r.Err)
+		assert.Equal(t, minerAddr, r.NewBlock.Miner)
+	})
+
+	t.Run("Other miners don't mine when a producer is scheduled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		worker := mining.NewDefaultWorkerWithDeps(pool, getStateTree, getWeightTest, getAncestors, th.NewTestProcessor(),
+			mining.NewTestPowerTableView(1), bs, cst, minerAddr, minerOwnerAddr, blockSignerAddr, mockSigner, th.BlockTimeTest, CreatePoSTFunc)
+		worker.SetScheduledProducer(minerOwnerAddr)
+
+		outCh := make(chan mining.Output, 1)
+		assert.False(t, worker.Mine(ctx, tipSet, 0, outCh))
+		assert.Empty(t, outCh)
+	})
 }
 
 func sharedSetupInitial() (*hamt.CborIpldStore, *core.MessagePool, cid.Cid) {
@@ -0,0 +1,158 @@
+package net
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	"github.com/pkg/errors"
+
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// AncestorsProtocol is the libp2p protocol identifier for fetching a chain
+// of ancestors in a single request.
+//
+// It does what a go-graphsync request with a selector over parent links
+// would.  go-graphsync is built on go-ipld-prime and the go-libp2p-core
+// interfaces, which the libp2p and ipld versions this node pins predate, so
+// it can't be used until they are upgraded.  Responses are also checked
+// tipset by tipset as they are read, so that a peer serving a chain that
+// doesn't link to the head is dropped after its first bad tipset.
+const AncestorsProtocol = "/fil/chain/ancestors/1.0.0"
+
+// MaxAncestorsPerRequest is the maximum number of tipsets served in response
+// to a single ancestors request.
+const MaxAncestorsPerRequest = 500
+
+// ancestorsTimeout bounds the time spent serving or reading a response.
+const ancestorsTimeout = time.Minute
+
+var logAncestors = logging.Logger("net.ancestors")
+
+func init() {
+	cbor.RegisterCborType(AncestorsRequest{})
+	cbor.RegisterCborType(AncestorsResponse{})
+}
+
+// AncestorsRequest asks a peer for the tipset with key Head and up to
+// Length - 1 of its ancestors.
+type AncestorsRequest struct {
+	Head   []cid.Cid
+	Length uint64
+}
+
+// AncestorsResponse carries the blocks of one tipset.  A response to an
+// ancestors request is a stream of these, starting at the requested head and
+// following parent links, which ends early if the peer runs out of blocks.
+type AncestorsResponse struct {
+	Blocks []*types.Block
+}
+
+type ancestorsBlockGetter interface {
+	GetBlock(context.Context, cid.Cid) (*types.Block, error)
+}
+
+// AncestorsService serves chains of ancestors to peers, sparing them one
+// bitswap want per block when they sync.
+type AncestorsService struct {
+	blocks ancestorsBlockGetter
+}
+
+// NewAncestorsService creates a service serving blocks from the given source
+// and registers it to the given host.
+func NewAncestorsService(h host.Host, blocks ancestorsBlockGetter) *AncestorsService {
+	as := &AncestorsService{blocks: blocks}
+	h.SetStreamHandler(AncestorsProtocol, as.handleNewStream)
+	return as
+}
+
+func (as *AncestorsService) handleNewStream(s inet.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), ancestorsTimeout)
+	defer cancel()
+
+	var req AncestorsRequest
+	if err := cbu.NewMsgReader(s).ReadMsg(&req); err != nil {
+		logAncestors.Debugf("bad ancestors request from peer %s: %s", s.Conn().RemotePeer(), err)
+		return
+	}
+	if err := as.serve(ctx, cbu.NewMsgWriter(s), req); err != nil {
+		logAncestors.Debugf("failed to serve ancestors to peer %s: %s", s.Conn().RemotePeer(), err)
+	}
+}
+
+// serve writes the requested tipsets to w until the request is satisfied, a
+// block is missing, a tipset is too large to send or the genesis is reached.
+func (as *AncestorsService) serve(ctx context.Context, w *cbu.MsgWriter, req AncestorsRequest) error {
+	length := req.Length
+	if length > MaxAncestorsPerRequest {
+		length = MaxAncestorsPerRequest
+	}
+
+	key := types.NewSortedCidSet(req.Head...)
+	for i := uint64(0); i < length && !key.Empty(); i++ {
+		ts := types.TipSet{}
+		for it := key.Iter(); !it.Complete(); it.Next() {
+			blk, err := as.blocks.GetBlock(ctx, it.Value())
+			if err != nil {
+				// We have served all we have.
+				return nil
+			}
+			if err := ts.AddBlock(blk); err != nil {
+				return err
+			}
+		}
+
+		resp := AncestorsResponse{Blocks: ts.ToSlice()}
+		data, err := cbor.DumpObject(resp)
+		if err != nil {
+			return err
+		}
+		if len(data) > cbu.MaxMessageSize {
+			return nil
+		}
+		if err := w.WriteMsg(resp); err != nil {
+			return err
+		}
+
+		if key, err = ts.Parents(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAncestors reads the tipsets of a response to a request for head from s
+// and checks that each links to the previous one.
+func readAncestors(s inet.Stream, head types.SortedCidSet, length uint64) ([]types.TipSet, error) {
+	r := cbu.NewMsgReader(s)
+	var tipsets []types.TipSet
+	expected := head
+	for uint64(len(tipsets)) < length && !expected.Empty() {
+		var resp AncestorsResponse
+		if err := r.ReadMsg(&resp); err != nil {
+			// The peer has no more to send or sent something we won't read,
+			// keep what has been received.
+			break
+		}
+		ts, err := types.NewTipSet(resp.Blocks...)
+		if err != nil {
+			return nil, errors.Wrap(err, "peer sent an invalid tipset")
+		}
+		if !ts.ToSortedCidSet().Equals(expected) {
+			return nil, errors.Errorf("peer sent tipset %s, expected %s", ts.String(), expected.String())
+		}
+		tipsets = append(tipsets, ts)
+		if expected, err = ts.Parents(); err != nil {
+			return nil, err
+		}
+	}
+	return tipsets, nil
+}
@@ -0,0 +1,155 @@
+package net_test
+
+import (
+	"context"
+	"testing"
+
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestFetchAncestors(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The serving peer has a chain of five blocks.
+	source := th.NewFakeBlockProvider()
+	chain := []*types.Block{source.NewBlock(0)}
+	for i := 1; i < 5; i++ {
+		chain = append(chain, source.NewBlock(uint64(i), chain[i-1]))
+	}
+	head := types.NewSortedCidSet(chain[4].Cid())
+
+	newFetcher := func(t *testing.T) (*net.Fetcher, bstore.Blockstore) {
+		mn, err := mocknet.WithNPeers(ctx, 2)
+		require.NoError(t, err)
+		net.NewAncestorsService(mn.Hosts()[0], source)
+		require.NoError(t, mn.LinkAll())
+		require.NoError(t, mn.ConnectAllButSelf())
+
+		bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
+		return net.NewChainFetcher(ctx, bserv.New(bs, offline.Exchange(bs)), mn.Hosts()[1], net.NewPeerStats()), bs
+	}
+
+	t.Run("fetches the whole chain in one request", func(t *testing.T) {
+		fetcher, _ := newFetcher(t)
+		require.NoError(t, fetcher.FetchAncestors(ctx, head, 10))
+
+		// The blocks now resolve without any network exchange.
+		for _, blk := range chain {
+			fetched, err := fetcher.GetBlocks(ctx, []cid.Cid{blk.Cid()})
+			require.NoError(t, err)
+			assert.Equal(t, blk.Cid(), fetched[0].Cid())
+		}
+	})
+
+	t.Run("fetches no more than the requested length", func(t *testing.T) {
+		fetcher, bs := newFetcher(t)
+		require.NoError(t, fetcher.FetchAncestors(ctx, head, 2))
+
+		for i, blk := range chain {
+			has, err := bs.Has(blk.Cid())
+			require.NoError(t, err)
+			assert.Equal(t, i >= 3, has)
+		}
+	})
+
+	t.Run("asks the peers that announced the head first", func(t *testing.T) {
+		mn, err := mocknet.WithNPeers(ctx, 3)
+		require.NoError(t, err)
+		other := &countingBlockGetter{blocks: source}
+		net.NewAncestorsService(mn.Hosts()[0], other)
+		net.NewAncestorsService(mn.Hosts()[1], source)
+		require.NoError(t, mn.LinkAll())
+		require.NoError(t, mn.ConnectAllButSelf())
+
+		bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
+		fetcher := net.NewChainFetcher(ctx, bserv.New(bs, offline.Exchange(bs)), mn.Hosts()[2], net.NewPeerStats())
+
+		sessionCtx := fetcher.WithSession(net.WithSourcePeers(ctx, mn.Hosts()[1].ID()))
+		require.NoError(t, fetcher.FetchAncestors(sessionCtx, head, 10))
+		assert.Equal(t, 0, other.calls)
+
+		fetched, err := fetcher.GetBlocks(sessionCtx, []cid.Cid{chain[0].Cid()})
+		require.NoError(t, err)
+		assert.Equal(t, chain[0].Cid(), fetched[0].Cid())
+	})
+
+	t.Run("asks the peers that announced the head first without a session", func(t *testing.T) {
+		mn, err := mocknet.WithNPeers(ctx, 6)
+		require.NoError(t, err)
+		other := &countingBlockGetter{blocks: source}
+		for _, h := range mn.Hosts()[:4] {
+			net.NewAncestorsService(h, other)
+		}
+		net.NewAncestorsService(mn.Hosts()[4], source)
+		require.NoError(t, mn.LinkAll())
+		require.NoError(t, mn.ConnectAllButSelf())
+
+		bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
+		fetcher := net.NewChainFetcher(ctx, bserv.New(bs, offline.Exchange(bs)), mn.Hosts()[5], net.NewPeerStats())
+
+		// The announcing peer is asked even with more other peers connected
+		// than are ever asked.
+		require.NoError(t, fetcher.FetchAncestors(net.WithSourcePeers(ctx, mn.Hosts()[4].ID()), head, 10))
+		assert.Equal(t, 0, other.calls)
+	})
+
+	t.Run("reports progress", func(t *testing.T) {
+		fetcher, _ := newFetcher(t)
+		subCtx, unsubscribe := context.WithCancel(ctx)
+		defer unsubscribe()
+		updates := fetcher.SubscribeProgress(subCtx)
+		assert.Equal(t, net.FetchProgress{}, <-updates)
+
+		require.NoError(t, fetcher.FetchAncestors(ctx, head, 3))
+		progress := fetcher.Progress()
+		assert.Equal(t, uint64(3), progress.TipSetsRequested)
+		assert.Equal(t, uint64(3), progress.BlocksReceived)
+		assert.True(t, progress.BytesReceived > 0)
+		assert.Equal(t, uint64(2), progress.Height)
+		// The subscriber receives the latest progress.
+		assert.Equal(t, progress, <-updates)
+
+		_, err := fetcher.GetBlocks(ctx, []cid.Cid{chain[3].Cid()})
+		require.NoError(t, err)
+		progress = fetcher.Progress()
+		assert.Equal(t, uint64(1), progress.BlocksRequested)
+		assert.Equal(t, uint64(4), progress.BlocksReceived)
+		assert.Equal(t, uint64(3), progress.Height)
+
+		unsubscribe()
+		for range updates {
+		}
+	})
+
+	t.Run("fails for a head no peer has", func(t *testing.T) {
+		fetcher, _ := newFetcher(t)
+		unknown := types.NewSortedCidSet(types.NewBlockForTest(nil, 100).Cid())
+		assert.Error(t, fetcher.FetchAncestors(ctx, unknown, 10))
+	})
+}
+
+type countingBlockGetter struct {
+	blocks *th.FakeBlockProvider
+	calls  int
+}
+
+func (g *countingBlockGetter) GetBlock(ctx context.Context, c cid.Cid) (*types.Block, error) {
+	g.calls++
+	return g.blocks.GetBlock(ctx, c)
+}
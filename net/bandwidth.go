@@ -0,0 +1,140 @@
+package net
+
+import (
+	"context"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/libp2p/go-libp2p-protocol"
+	"golang.org/x/time/rate"
+)
+
+// uploadBurst is the largest number of bytes written to a peer at once when
+// uploads are limited.
+const uploadBurst = 64 << 10
+
+// BandwidthManager limits the rate at which the node uploads to each peer
+// over a set of protocols, so that the traffic spent serving data such as
+// blocks and chains to other peers is bounded.
+type BandwidthManager struct {
+	limit     rate.Limit
+	protocols map[protocol.ID]struct{}
+
+	lk       sync.Mutex
+	limiters map[peer.ID]*rate.Limiter
+}
+
+// NewBandwidthManager returns a BandwidthManager that limits the data sent
+// to each peer over the given protocols to peerUploadLimit bytes per second.
+// A limit of zero means uploads are not limited.
+func NewBandwidthManager(peerUploadLimit uint64, protocols ...protocol.ID) *BandwidthManager {
+	bm := &BandwidthManager{
+		limit:     rate.Limit(peerUploadLimit),
+		protocols: make(map[protocol.ID]struct{}),
+		limiters:  make(map[peer.ID]*rate.Limiter),
+	}
+	for _, pid := range protocols {
+		bm.protocols[pid] = struct{}{}
+	}
+	return bm
+}
+
+// Host wraps h so that the streams it opens and accepts over the managed
+// protocols are subject to the manager's limits.
+func (bm *BandwidthManager) Host(h host.Host) host.Host {
+	if bm.limit == 0 {
+		return h
+	}
+	h.Network().Notify(&inet.NotifyBundle{
+		DisconnectedF: func(n inet.Network, c inet.Conn) {
+			if len(n.ConnsToPeer(c.RemotePeer())) == 0 {
+				bm.forget(c.RemotePeer())
+			}
+		},
+	})
+	return &limitedHost{Host: h, bm: bm}
+}
+
+// limiter returns the upload limiter for p, creating it if needed.
+func (bm *BandwidthManager) limiter(p peer.ID) *rate.Limiter {
+	bm.lk.Lock()
+	defer bm.lk.Unlock()
+
+	l, ok := bm.limiters[p]
+	if !ok {
+		l = rate.NewLimiter(bm.limit, uploadBurst)
+		bm.limiters[p] = l
+	}
+	return l
+}
+
+// forget drops the limiter of a peer that is no longer connected.
+func (bm *BandwidthManager) forget(p peer.ID) {
+	bm.lk.Lock()
+	defer bm.lk.Unlock()
+
+	delete(bm.limiters, p)
+}
+
+func (bm *BandwidthManager) wrapStream(s inet.Stream, pid protocol.ID) inet.Stream {
+	if _, ok := bm.protocols[pid]; !ok {
+		return s
+	}
+	return &limitedStream{Stream: s, limiter: bm.limiter(s.Conn().RemotePeer())}
+}
+
+// limitedHost is a host whose streams over the protocols of its bandwidth
+// manager are rate limited.
+type limitedHost struct {
+	host.Host
+	bm *BandwidthManager
+}
+
+func (h *limitedHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	return h.bm.wrapStream(s, s.Protocol()), nil
+}
+
+func (h *limitedHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.Host.SetStreamHandler(pid, func(s inet.Stream) {
+		handler(h.bm.wrapStream(s, s.Protocol()))
+	})
+}
+
+func (h *limitedHost) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler inet.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(pid, match, func(s inet.Stream) {
+		handler(h.bm.wrapStream(s, s.Protocol()))
+	})
+}
+
+// limitedStream is a stream whose writes wait on a rate limiter shared by
+// all limited streams to the same peer.
+type limitedStream struct {
+	inet.Stream
+	limiter *rate.Limiter
+}
+
+func (s *limitedStream) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > uploadBurst {
+			chunk = chunk[:uploadBurst]
+		}
+		if err := s.limiter.WaitN(context.Background(), len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := s.Stream.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
@@ -0,0 +1,68 @@
+package net_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	"github.com/libp2p/go-libp2p-protocol"
+	"github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestBandwidthManager(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const limited = "/test/limited"
+	const unlimited = "/test/unlimited"
+
+	// timeUpload returns how long it takes to upload size bytes over pid to a
+	// peer that reads everything.
+	timeUpload := func(t *testing.T, pid string, size int) time.Duration {
+		mn, err := mocknet.WithNPeers(ctx, 2)
+		require.NoError(t, err)
+		require.NoError(t, mn.LinkAll())
+		require.NoError(t, mn.ConnectAllButSelf())
+
+		done := make(chan struct{})
+		mn.Hosts()[1].SetStreamHandler(limited, func(s inet.Stream) { drain(s, done) })
+		mn.Hosts()[1].SetStreamHandler(unlimited, func(s inet.Stream) { drain(s, done) })
+
+		bm := net.NewBandwidthManager(64<<10, limited)
+		h := bm.Host(mn.Hosts()[0])
+		s, err := h.NewStream(ctx, mn.Hosts()[1].ID(), protocol.ID(pid))
+		require.NoError(t, err)
+
+		start := time.Now()
+		n, err := s.Write(make([]byte, size))
+		require.NoError(t, err)
+		require.Equal(t, size, n)
+		require.NoError(t, s.Close())
+		<-done
+		return time.Since(start)
+	}
+
+	t.Run("limits uploads over managed protocols", func(t *testing.T) {
+		// The first 64KiB are within the burst, the next 32KiB take half a
+		// second at 64KiB per second.
+		assert.True(t, timeUpload(t, limited, 96<<10) >= 400*time.Millisecond)
+	})
+
+	t.Run("does not limit other protocols", func(t *testing.T) {
+		assert.True(t, timeUpload(t, unlimited, 96<<10) < 400*time.Millisecond)
+	})
+}
+
+func drain(s inet.Stream, done chan<- struct{}) {
+	ioutil.ReadAll(s) // nolint: errcheck
+	close(done)
+}
@@ -25,9 +25,11 @@ var filecoinDHTBootstrapConfig = dht.BootstrapConfig{
 
 // Bootstrapper attempts to keep the p2p host connected to the filecoin network
 // by keeping a minimum threshold of connections. If the threshold isn't met it
-// connects to a random subset of the bootstrap peers. It does not use peer routing
-// to discover new peers. To stop a Bootstrapper cancel the context passed in Start()
-// or call Stop().
+// connects to a random subset of the bootstrap peers. If those are unreachable
+// and KnownPeers is set, it falls back to the peers it saw before and then to
+// the peers they are connected to. It does not use peer routing to discover
+// new peers. To stop a Bootstrapper cancel the context passed in Start() or
+// call Stop().
 type Bootstrapper struct {
 	// Config
 	// MinPeerThreshold is the number of connections it attempts to maintain.
@@ -39,6 +41,14 @@ type Bootstrapper struct {
 	Period time.Duration
 	// ConnectionTimeout is how long to wait before timing out a connection attempt.
 	ConnectionTimeout time.Duration
+	// MaxPeerThreshold is the number of connections up to which known peers
+	// are redialed every RedialPeriod. Known peers are not redialed if either
+	// is zero.
+	MaxPeerThreshold int
+	RedialPeriod     time.Duration
+	// KnownPeers are the peers to fall back to if the bootstrap peers are
+	// unreachable, and to redial. May be nil.
+	KnownPeers *KnownPeers
 
 	// Dependencies
 	h host.Host
@@ -77,8 +87,19 @@ func (b *Bootstrapper) Start(ctx context.Context) {
 	b.ctx, b.cancel = context.WithCancel(ctx)
 	b.ticker = time.NewTicker(b.Period)
 
+	// A nil channel never fires, disabling redials.
+	var redial <-chan time.Time
+	var redialTicker *time.Ticker
+	if b.KnownPeers != nil && b.MaxPeerThreshold > 0 && b.RedialPeriod > 0 {
+		redialTicker = time.NewTicker(b.RedialPeriod)
+		redial = redialTicker.C
+	}
+
 	go func() {
 		defer b.ticker.Stop()
+		if redialTicker != nil {
+			defer redialTicker.Stop()
+		}
 
 		for {
 			select {
@@ -86,6 +107,8 @@ func (b *Bootstrapper) Start(ctx context.Context) {
 				return
 			case <-b.ticker.C:
 				b.Bootstrap(b.d.Peers())
+			case <-redial:
+				b.redial(b.d.Peers())
 			}
 		}
 	}()
@@ -100,7 +123,8 @@ func (b *Bootstrapper) Stop() {
 
 // bootstrap does the actual work. If the number of connected peers
 // has fallen below b.MinPeerThreshold it will attempt to connect to
-// a random subset of its bootstrap peers.
+// a random subset of its bootstrap peers, falling back to known peers and the
+// peers they are connected to.
 func (b *Bootstrapper) bootstrap(currentPeers []peer.ID) {
 	peersNeeded := b.MinPeerThreshold - len(currentPeers)
 	if peersNeeded < 1 {
@@ -108,9 +132,7 @@ func (b *Bootstrapper) bootstrap(currentPeers []peer.ID) {
 	}
 
 	ctx, cancel := context.WithTimeout(b.ctx, b.ConnectionTimeout)
-	var wg sync.WaitGroup
 	defer func() {
-		wg.Wait()
 		// After connecting to bootstrap peers, bootstrap the DHT.
 		// DHT Bootstrap is a persistent process so only do this once.
 		if !b.dhtBootStarted {
@@ -123,27 +145,120 @@ func (b *Bootstrapper) bootstrap(currentPeers []peer.ID) {
 		cancel()
 	}()
 
-	peersAttempted := 0
-	for _, i := range rand.Perm(len(b.bootstrapPeers)) {
-		pinfo := b.bootstrapPeers[i]
-		// Don't try to connect to an already connected peer.
-		if hasPID(currentPeers, pinfo.ID) {
-			continue
+	tried := make(map[peer.ID]bool, len(currentPeers))
+	for _, p := range currentPeers {
+		tried[p] = true
+	}
+	bootstrapPeers := make([]pstore.PeerInfo, len(b.bootstrapPeers))
+	for i, j := range rand.Perm(len(b.bootstrapPeers)) {
+		bootstrapPeers[i] = b.bootstrapPeers[j]
+	}
+	connected := b.connect(ctx, bootstrapPeers, tried, peersNeeded)
+	peersNeeded -= len(connected)
+
+	if peersNeeded > 0 && b.KnownPeers != nil {
+		logBootstrap.Infof("connected to %d bootstrap nodes, falling back to known peers", len(connected))
+		known, err := b.KnownPeers.Peers()
+		if err != nil {
+			logBootstrap.Errorf("failed to load known peers: %s", err)
 		}
+		newPeers := b.connect(ctx, known, tried, peersNeeded)
+		peersNeeded -= len(newPeers)
+		connected = append(connected, newPeers...)
 
-		wg.Add(1)
-		go func() {
-			if err := b.h.Connect(ctx, pinfo); err != nil {
-				logBootstrap.Errorf("got error trying to connect to bootstrap node %+v: %s", pinfo, err.Error())
+		if peersNeeded > 0 {
+			peers := append(append([]peer.ID{}, currentPeers...), connected...)
+			exchanged := b.exchangePeers(ctx, peers)
+			peersNeeded -= len(b.connect(ctx, exchanged, tried, peersNeeded))
+		}
+	}
+
+	if peersNeeded > 0 {
+		logBootstrap.Warningf("not enough reachable peers to maintain %d connections (current connections: %d)", b.MinPeerThreshold, b.MinPeerThreshold-peersNeeded)
+	}
+}
+
+// redial reconnects to known peers until there are b.MaxPeerThreshold
+// connections.
+func (b *Bootstrapper) redial(currentPeers []peer.ID) {
+	peersWanted := b.MaxPeerThreshold - len(currentPeers)
+	if peersWanted < 1 {
+		return
+	}
+	known, err := b.KnownPeers.Peers()
+	if err != nil {
+		logBootstrap.Errorf("failed to load known peers: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, b.ConnectionTimeout)
+	defer cancel()
+	tried := make(map[peer.ID]bool, len(currentPeers))
+	for _, p := range currentPeers {
+		tried[p] = true
+	}
+	b.connect(ctx, known, tried, peersWanted)
+}
+
+// connect dials the candidates not yet tried in order, peersNeeded at a time,
+// until peersNeeded of them are connected or all have been tried.  It marks
+// the candidates dialed as tried and returns those it connected to.
+func (b *Bootstrapper) connect(ctx context.Context, candidates []pstore.PeerInfo, tried map[peer.ID]bool, peersNeeded int) []peer.ID {
+	var lk sync.Mutex
+	var connected []peer.ID
+	for len(candidates) > 0 && len(connected) < peersNeeded {
+		var batch []pstore.PeerInfo
+		for len(candidates) > 0 && len(batch) < peersNeeded-len(connected) {
+			pinfo := candidates[0]
+			candidates = candidates[1:]
+			// Don't try to connect to an already connected peer.
+			if tried[pinfo.ID] {
+				continue
 			}
-			wg.Done()
-		}()
-		peersAttempted++
-		if peersAttempted == peersNeeded {
-			return
+			tried[pinfo.ID] = true
+			batch = append(batch, pinfo)
+		}
+
+		var wg sync.WaitGroup
+		for _, pinfo := range batch {
+			wg.Add(1)
+			go func(pinfo pstore.PeerInfo) {
+				defer wg.Done()
+				if err := b.h.Connect(ctx, pinfo); err != nil {
+					logBootstrap.Errorf("got error trying to connect to node %+v: %s", pinfo, err.Error())
+					return
+				}
+				lk.Lock()
+				defer lk.Unlock()
+				connected = append(connected, pinfo.ID)
+			}(pinfo)
 		}
+		wg.Wait()
+	}
+	return connected
+}
+
+// exchangePeers asks peers for the peers they are connected to.
+func (b *Bootstrapper) exchangePeers(ctx context.Context, peers []peer.ID) []pstore.PeerInfo {
+	var lk sync.Mutex
+	var wg sync.WaitGroup
+	var exchanged []pstore.PeerInfo
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			infos, err := RequestPeers(ctx, b.h, p, MaxPeersPerExchange)
+			if err != nil {
+				logBootstrap.Debugf("failed to exchange peers with %s: %s", p, err)
+				return
+			}
+			lk.Lock()
+			defer lk.Unlock()
+			exchanged = append(exchanged, infos...)
+		}(p)
 	}
-	logBootstrap.Warningf("not enough bootstrap nodes to maintain %d connections (current connections: %d)", b.MinPeerThreshold, len(currentPeers))
+	wg.Wait()
+	return exchanged
 }
 
 func hasPID(pids []peer.ID, pid peer.ID) bool {
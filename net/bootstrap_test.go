@@ -9,7 +9,11 @@ import (
 	offroute "github.com/ipfs/go-ipfs-routing/offline"
 	"github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
+	"github.com/libp2p/go-libp2p-peerstore/pstoremem"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/repo"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
@@ -132,4 +136,36 @@ func TestBootstrapperBootstrap(t *testing.T) {
 		assert.Equal(t, 0, connectCount)
 		lk.Unlock()
 	})
+	t.Run("Falls back to known peers if bootstrap peers are unreachable", func(t *testing.T) {
+		unreachable := th.RequireRandomPeerID(t)
+		var connected []peer.ID
+		fakeHost := &th.FakeHost{ConnectImpl: func(_ context.Context, pi pstore.PeerInfo) error {
+			if pi.ID == unreachable {
+				return errors.New("unreachable")
+			}
+			lk.Lock()
+			defer lk.Unlock()
+			connected = append(connected, pi.ID)
+			return nil
+		}}
+		fakeDialer := &th.FakeDialer{PeersImpl: panicPeers}
+		fakeRouter := offroute.NewOfflineRouter(repo.NewInMemoryRepo().Datastore(), blankValidator{})
+
+		addr, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/6000")
+		require.NoError(t, err)
+		ps := pstoremem.NewPeerstore()
+		known := th.RequireRandomPeerID(t)
+		ps.AddAddr(known, addr, pstore.PermanentAddrTTL)
+		kp := NewKnownPeers(repo.NewInMemoryRepo().Datastore(), ps, 10)
+		require.NoError(t, kp.Add(known))
+
+		b := NewBootstrapper([]pstore.PeerInfo{{ID: unreachable}}, fakeHost, fakeDialer, fakeRouter, 2, time.Minute)
+		b.KnownPeers = kp
+		b.ctx = context.Background()
+		currentPeers := []peer.ID{th.RequireRandomPeerID(t)} // Have 1
+		b.bootstrap(currentPeers)
+		lk.Lock()
+		assert.Equal(t, []peer.ID{known}, connected)
+		lk.Unlock()
+	})
 }
@@ -0,0 +1,78 @@
+package net
+
+import (
+	"context"
+	"sync"
+)
+
+// FetchProgress describes the blocks a Fetcher has fetched since it was
+// created, so that long syncs can be told apart from hung ones.
+type FetchProgress struct {
+	// BlocksRequested is the number of blocks wanted by cid.
+	BlocksRequested uint64 `json:"blocksRequested"`
+	// TipSetsRequested is the number of tipsets requested in chains of
+	// ancestors.
+	TipSetsRequested uint64 `json:"tipSetsRequested"`
+	BlocksReceived   uint64 `json:"blocksReceived"`
+	BytesReceived    uint64 `json:"bytesReceived"`
+	// Height is the height of the blocks last received.
+	Height uint64 `json:"height"`
+}
+
+// progressTracker accumulates fetch progress and notifies subscribers of each
+// change.
+type progressTracker struct {
+	mu       sync.Mutex
+	progress FetchProgress
+	nextID   int
+	subs     map[int]chan FetchProgress
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{subs: make(map[int]chan FetchProgress)}
+}
+
+func (pt *progressTracker) get() FetchProgress {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.progress
+}
+
+// update applies f to the progress and sends the result to subscribers,
+// replacing any progress they have yet to receive.
+func (pt *progressTracker) update(f func(*FetchProgress)) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	f(&pt.progress)
+	for _, ch := range pt.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		// Only update sends, under the lock, so there is room now.
+		ch <- pt.progress
+	}
+}
+
+// subscribe returns a channel receiving the current progress followed by the
+// latest progress after each change, which is closed when ctx is done.
+func (pt *progressTracker) subscribe(ctx context.Context) <-chan FetchProgress {
+	ch := make(chan FetchProgress, 1)
+
+	pt.mu.Lock()
+	id := pt.nextID
+	pt.nextID++
+	pt.subs[id] = ch
+	ch <- pt.progress
+	pt.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		pt.mu.Lock()
+		defer pt.mu.Unlock()
+		delete(pt.subs, id)
+		close(ch)
+	}()
+	return ch
+}
@@ -3,55 +3,341 @@ package net
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ipfs/go-block-format"
 	bserv "github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dag "github.com/ipfs/go-merkledag"
+	host "github.com/libp2p/go-libp2p-host"
+	peer "github.com/libp2p/go-libp2p-peer"
 	"github.com/pkg/errors"
 
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+// maxAncestorsPeers is the number of peers, besides those that announced
+// the head, asked for a chain of ancestors before giving up.
+const maxAncestorsPeers = 3
+
+const (
+	// fetchAttemptTimeout bounds each attempt to fetch blocks.
+	fetchAttemptTimeout = 10 * time.Second
+	// fetchRetries is the number of times fetching missing blocks is
+	// retried.
+	fetchRetries = 2
+	// fetchRetryBackoff is the delay before the first retry, doubling
+	// for each following one.
+	fetchRetryBackoff = 250 * time.Millisecond
+)
+
 // Fetcher is used to fetch data over the network.  It is implemented with
-// a persistent bitswap session on a networked blockservice.
+// a persistent bitswap session on a networked blockservice, which sync
+// operations can replace with one of their own using WithSession.
 type Fetcher struct {
+	// bsrv is the networked blockservice new sessions are started on.
+	bsrv bserv.BlockService
 	// session is a bitswap session that enables efficient transfer.
 	session *bserv.Session
+	// bstore is the blockstore behind the session, into which chains of
+	// ancestors are written.
+	bstore bstore.Blockstore
+	// host is used to request chains of ancestors from peers.  It is nil
+	// if the fetcher only uses bitswap.
+	host host.Host
+	// stats measures the requests for ancestors and orders the peers they
+	// are sent to.
+	stats *PeerStats
+	// progress tracks the blocks fetched for progress reports.
+	progress *progressTracker
 }
 
 // NewFetcher returns a Fetcher wired up to the input BlockService and a newly
 // initialized persistent session of the block service.
 func NewFetcher(ctx context.Context, bsrv bserv.BlockService) *Fetcher {
 	return &Fetcher{
-		session: bserv.NewSession(ctx, bsrv),
+		bsrv:     bsrv,
+		session:  bserv.NewSession(ctx, bsrv),
+		bstore:   bsrv.Blockstore(),
+		progress: newProgressTracker(),
+	}
+}
+
+// Progress returns the fetcher's progress so far.
+func (f *Fetcher) Progress() FetchProgress {
+	return f.progress.get()
+}
+
+// SubscribeProgress returns a channel receiving the fetcher's progress so far
+// followed by its latest progress whenever it changes.  Slow receivers only
+// miss intermediate updates.  The channel is closed when ctx is done.
+func (f *Fetcher) SubscribeProgress(ctx context.Context) <-chan FetchProgress {
+	return f.progress.subscribe(ctx)
+}
+
+// NewChainFetcher returns a Fetcher like NewFetcher that can additionally
+// fetch whole chains of ancestors from a single peer of h in one request,
+// preferring the peers stats finds fastest.
+func NewChainFetcher(ctx context.Context, bsrv bserv.BlockService, h host.Host, stats *PeerStats) *Fetcher {
+	f := NewFetcher(ctx, bsrv)
+	f.host = h
+	f.stats = stats
+	return f
+}
+
+// FetchAncestors requests the tipset with key head and up to length - 1 of its
+// ancestors from peers, one peer at a time, starting with the peers that
+// announced the head, recorded in ctx by WithSourcePeers, and stores the blocks
+// received so that subsequent calls to GetBlocks resolve them locally instead
+// of wanting each block over bitswap.  Nothing is requested if the head's
+// blocks are already local.  Fetching is best effort: blocks not received can
+// still be fetched with GetBlocks.
+func (f *Fetcher) FetchAncestors(ctx context.Context, head types.SortedCidSet, length uint64) error {
+	if f.host == nil || head.Empty() || f.hasAll(head) {
+		return nil
+	}
+
+	var lastErr error
+	for _, p := range f.ancestorsPeers(ctx) {
+		tipsets, err := f.requestAncestors(ctx, p, head, length)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(tipsets) == 0 {
+			continue
+		}
+		return f.putTipSets(tipsets)
+	}
+	if lastErr != nil {
+		return errors.Wrapf(lastErr, "failed to fetch ancestors of %s", head.String())
+	}
+	return errors.Errorf("no peer served ancestors of %s", head.String())
+}
+
+// FetchState fetches the state tree with the given root, and the actors'
+// storage it links to, into the blockstore.  Nodes already local are not
+// fetched again.
+func (f *Fetcher) FetchState(ctx context.Context, root cid.Cid) error {
+	return dag.FetchGraph(ctx, root, dag.NewDAGService(f.bsrv))
+}
+
+// ancestorsPeers returns the peers to request ancestors from in order of
+// preference: the peers recorded in ctx by WithSourcePeers, which announced
+// the head and so have its ancestors, followed by the maxAncestorsPeers
+// fastest of the other connected peers.
+func (f *Fetcher) ancestorsPeers(ctx context.Context) []peer.ID {
+	var peers []peer.ID
+	seen := map[peer.ID]struct{}{f.host.ID(): {}}
+	for _, p := range SourcePeers(ctx) {
+		if _, ok := seen[p]; !ok {
+			seen[p] = struct{}{}
+			peers = append(peers, p)
+		}
+	}
+	var connected []peer.ID
+	for _, p := range f.host.Network().Peers() {
+		if _, ok := seen[p]; !ok {
+			seen[p] = struct{}{}
+			connected = append(connected, p)
+		}
+	}
+	f.stats.Fastest(connected)
+	if len(connected) > maxAncestorsPeers {
+		connected = connected[:maxAncestorsPeers]
+	}
+	return append(peers, connected...)
+}
+
+func (f *Fetcher) requestAncestors(ctx context.Context, p peer.ID, head types.SortedCidSet, length uint64) (tipsets []types.TipSet, err error) {
+	done := f.stats.StartRequest(p)
+	defer func() {
+		blocks, size := 0, uint64(0)
+		for _, ts := range tipsets {
+			for _, blk := range ts {
+				blocks++
+				size += uint64(len(blk.ToNode().RawData()))
+			}
+		}
+		done(blocks, size, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, ancestorsTimeout)
+	defer cancel()
+
+	s, err := f.host.NewStream(ctx, p, AncestorsProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close() // nolint: errcheck
+
+	// Not every transport supports deadlines, so the stream is reset when
+	// ctx is done instead.
+	go func() {
+		<-ctx.Done()
+		s.Reset() // nolint: errcheck
+	}()
+
+	if err := cbu.NewMsgWriter(s).WriteMsg(&AncestorsRequest{Head: head.ToSlice(), Length: length}); err != nil {
+		return nil, err
+	}
+	f.progress.update(func(p *FetchProgress) { p.TipSetsRequested += length })
+	return readAncestors(s, head, length)
+}
+
+func (f *Fetcher) hasAll(key types.SortedCidSet) bool {
+	for it := key.Iter(); !it.Complete(); it.Next() {
+		if has, err := f.bstore.Has(it.Value()); err != nil || !has {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Fetcher) putTipSets(tipsets []types.TipSet) error {
+	var nodes []blocks.Block
+	var size uint64
+	for _, ts := range tipsets {
+		for _, blk := range ts {
+			node := blk.ToNode()
+			nodes = append(nodes, node)
+			size += uint64(len(node.RawData()))
+		}
+	}
+	if err := f.bstore.PutMany(nodes); err != nil {
+		return err
 	}
+
+	// Chains of ancestors are served from the head down.
+	height, err := tipsets[len(tipsets)-1].Height()
+	if err != nil {
+		return err
+	}
+	f.progress.update(func(p *FetchProgress) {
+		p.BlocksReceived += uint64(len(nodes))
+		p.BytesReceived += size
+		p.Height = height
+	})
+	return nil
 }
 
 // GetBlocks fetches the blocks with the given cids from the network using the
-// Fetcher's bitswap session.
+// bitswap session started for ctx by WithSession or, if there is none, the
+// Fetcher's persistent session.  Each attempt to fetch the blocks is bounded
+// by fetchAttemptTimeout.  Blocks still missing are retried with exponential
+// backoff in new sessions, which want them from all connected peers rather
+// than the peers of the first session, and finally requested from the peers
+// one at a time.  If some blocks cannot be fetched the error is a
+// *MissingBlocksError listing them.
 func (f *Fetcher) GetBlocks(ctx context.Context, cids []cid.Cid) ([]*types.Block, error) {
-	var unsanitized []blocks.Block
-	for b := range f.session.GetBlocks(ctx, cids) {
-		unsanitized = append(unsanitized, b)
+	session := f.session
+	if fs := sessionFrom(ctx); fs != nil {
+		session = fs.session
 	}
 
-	if len(unsanitized) < len(cids) {
-		var err error
-		if ctxErr := ctx.Err(); ctxErr != nil {
-			err = errors.Wrap(ctxErr, "failed to fetch all requested blocks")
-		} else {
-			err = errors.New("failed to fetch all requested blocks")
+	f.progress.update(func(p *FetchProgress) { p.BlocksRequested += uint64(len(cids)) })
+
+	fetched := make(map[cid.Cid]blocks.Block)
+	missing := f.fetchAttempt(ctx, session, cids, fetched)
+	backoff := fetchRetryBackoff
+	for retry := 0; retry < fetchRetries && len(missing) > 0 && ctx.Err() == nil; retry++ {
+		select {
+		case <-ctx.Done():
+			continue
+		case <-time.After(backoff):
 		}
-		return nil, err
+		backoff *= 2
+
+		retryCtx, cancel := context.WithCancel(ctx)
+		missing = f.fetchAttempt(retryCtx, bserv.NewSession(retryCtx, f.bsrv), missing, fetched)
+		cancel()
+	}
+	if len(missing) > 0 && f.host != nil && ctx.Err() == nil {
+		missing = f.fetchFromPeers(ctx, missing, fetched)
+	}
+	if len(missing) > 0 {
+		return nil, &MissingBlocksError{Cids: missing, Err: ctx.Err()}
 	}
 
 	var blocks []*types.Block
-	for _, u := range unsanitized {
+	for _, c := range cids {
+		u := fetched[c]
 		block, err := types.DecodeBlock(u.RawData())
 		if err != nil {
 			return nil, errors.Wrap(err, fmt.Sprintf("fetched data (cid %s) was not a block", u.Cid().String()))
 		}
 		blocks = append(blocks, block)
 	}
+	if len(blocks) > 0 {
+		f.progress.update(func(p *FetchProgress) { p.Height = uint64(blocks[len(blocks)-1].Height) })
+	}
 	return blocks, nil
 }
+
+// fetchAttempt wants cids from session for at most fetchAttemptTimeout,
+// adding the blocks received to fetched, and returns the cids not received.
+func (f *Fetcher) fetchAttempt(ctx context.Context, session *bserv.Session, cids []cid.Cid, fetched map[cid.Cid]blocks.Block) []cid.Cid {
+	ctx, cancel := context.WithTimeout(ctx, fetchAttemptTimeout)
+	defer cancel()
+
+	for b := range session.GetBlocks(ctx, cids) {
+		fetched[b.Cid()] = b
+		size := uint64(len(b.RawData()))
+		f.progress.update(func(p *FetchProgress) {
+			p.BlocksReceived++
+			p.BytesReceived += size
+		})
+	}
+	return missingCids(cids, fetched)
+}
+
+// fetchFromPeers requests each of cids directly from the peers returned by
+// ancestorsPeers, adding the blocks received to fetched, and returns the cids
+// that no peer served.
+func (f *Fetcher) fetchFromPeers(ctx context.Context, cids []cid.Cid, fetched map[cid.Cid]blocks.Block) []cid.Cid {
+	peers := f.ancestorsPeers(ctx)
+	for _, c := range cids {
+		for _, p := range peers {
+			tipsets, err := f.requestAncestors(ctx, p, types.NewSortedCidSet(c), 1)
+			if err != nil || len(tipsets) == 0 {
+				continue
+			}
+			if err := f.putTipSets(tipsets); err != nil {
+				logAncestors.Debugf("failed to store block %s: %s", c, err)
+			}
+			fetched[c] = tipsets[0][c].ToNode()
+			break
+		}
+	}
+	return missingCids(cids, fetched)
+}
+
+func missingCids(cids []cid.Cid, fetched map[cid.Cid]blocks.Block) []cid.Cid {
+	var missing []cid.Cid
+	for _, c := range cids {
+		if _, ok := fetched[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// MissingBlocksError is returned by GetBlocks when some of the requested blocks
+// could not be fetched, so that callers can tell which peers failed to
+// provide them.
+type MissingBlocksError struct {
+	// Cids are the cids of the blocks that could not be fetched.
+	Cids []cid.Cid
+	// Err is the reason fetching stopped early, if it did.
+	Err error
+}
+
+func (e *MissingBlocksError) Error() string {
+	msg := fmt.Sprintf("failed to fetch all requested blocks, missing %v", e.Cids)
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+	return msg
+}
@@ -11,9 +11,12 @@ import (
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-exchange-offline"
 	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/net"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -64,6 +67,39 @@ func TestFetchNoBlockFails(t *testing.T) {
 	blocks, err := fetcher.GetBlocks(context.Background(), cids.ToSlice())
 	require.Error(t, err)
 	require.Nil(t, blocks)
+
+	missingErr, ok := err.(*net.MissingBlocksError)
+	require.True(t, ok)
+	assert.Equal(t, []cid.Cid{block2.Cid()}, missingErr.Cids)
+}
+
+func TestFetchFallsBackToPeers(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := th.NewFakeBlockProvider()
+	block := source.NewBlock(0)
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(t, err)
+	net.NewAncestorsService(mn.Hosts()[0], source)
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	// The block is not available over the (offline) exchange.
+	bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
+	fetcher := net.NewChainFetcher(ctx, bserv.New(bs, offline.Exchange(bs)), mn.Hosts()[1], net.NewPeerStats())
+
+	blocks, err := fetcher.GetBlocks(ctx, []cid.Cid{block.Cid()})
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, block.Cid(), blocks[0].Cid())
+
+	has, err := bs.Has(block.Cid())
+	require.NoError(t, err)
+	assert.True(t, has)
 }
 
 func TestFetchNotBlockFormat(t *testing.T) {
@@ -0,0 +1,144 @@
+package net
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	cbor.RegisterCborType(knownPeer{})
+}
+
+// KnownPeersPrefix is the datastore prefix for known peers.
+const KnownPeersPrefix = "knownpeers"
+
+// DefaultMaxKnownPeers is the number of known peers remembered by default.
+const DefaultMaxKnownPeers = 100
+
+// knownPeer is the persisted record of a known peer.
+type knownPeer struct {
+	Addrs [][]byte
+	// LastSeen is the unix time, in nanoseconds, at which the peer was last
+	// seen.
+	LastSeen uint64
+}
+
+// KnownPeers is a persisted set of the peers the node recently exchanged a
+// hello with, so that it can reconnect to them after a restart when its
+// bootstrap peers are unreachable.
+type KnownPeers struct {
+	ds       datastore.Datastore
+	addrs    pstore.AddrBook
+	maxPeers int
+
+	// lk serializes writes so that pruning sees a consistent set of keys.
+	lk sync.Mutex
+}
+
+// NewKnownPeers returns a KnownPeers persisted to ds, which looks up the
+// addresses of peers in addrs and keeps the maxPeers most recently seen ones.
+func NewKnownPeers(ds datastore.Datastore, addrs pstore.AddrBook, maxPeers int) *KnownPeers {
+	return &KnownPeers{ds: ds, addrs: addrs, maxPeers: maxPeers}
+}
+
+// Add records that p was seen now, at its current addresses.  Peers without
+// an address are not recorded since there would be no way to dial them.
+func (kp *KnownPeers) Add(p peer.ID) error {
+	kp.lk.Lock()
+	defer kp.lk.Unlock()
+
+	addrs := kp.addrs.Addrs(p)
+	if len(addrs) == 0 {
+		return nil
+	}
+	rec := knownPeer{LastSeen: uint64(time.Now().UnixNano())}
+	for _, a := range addrs {
+		rec.Addrs = append(rec.Addrs, a.Bytes())
+	}
+	datum, err := cbor.DumpObject(rec)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal known peer")
+	}
+	if err := kp.ds.Put(knownPeerKey(p), datum); err != nil {
+		return errors.Wrap(err, "could not save known peer")
+	}
+	return kp.prune()
+}
+
+// Peers returns the known peers, most recently seen first.
+func (kp *KnownPeers) Peers() ([]pstore.PeerInfo, error) {
+	results, err := kp.ds.Query(query.Query{Prefix: "/" + KnownPeersPrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query known peers")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query known peers")
+	}
+
+	type seenPeer struct {
+		pstore.PeerInfo
+		lastSeen uint64
+	}
+	var peers []seenPeer
+	for _, entry := range entries {
+		p, err := peer.IDB58Decode(datastore.NewKey(entry.Key).BaseNamespace())
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed known peer key %s", entry.Key)
+		}
+		var rec knownPeer
+		if err := cbor.DecodeInto(entry.Value, &rec); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal known peer")
+		}
+		info := pstore.PeerInfo{ID: p}
+		for _, b := range rec.Addrs {
+			a, err := ma.NewMultiaddrBytes(b)
+			if err != nil {
+				return nil, errors.Wrapf(err, "malformed address for known peer %s", p)
+			}
+			info.Addrs = append(info.Addrs, a)
+		}
+		peers = append(peers, seenPeer{PeerInfo: info, lastSeen: rec.LastSeen})
+	}
+	sort.SliceStable(peers, func(i, j int) bool { return peers[i].lastSeen > peers[j].lastSeen })
+
+	out := make([]pstore.PeerInfo, len(peers))
+	for i, p := range peers {
+		out[i] = p.PeerInfo
+	}
+	return out, nil
+}
+
+// prune forgets the least recently seen peers beyond the capacity.  It must
+// be called with the lock held.
+func (kp *KnownPeers) prune() error {
+	if kp.maxPeers <= 0 {
+		return nil
+	}
+	peers, err := kp.Peers()
+	if err != nil {
+		return err
+	}
+	if len(peers) <= kp.maxPeers {
+		return nil
+	}
+	for _, p := range peers[kp.maxPeers:] {
+		if err := kp.ds.Delete(knownPeerKey(p.ID)); err != nil {
+			return errors.Wrap(err, "failed to delete known peer")
+		}
+	}
+	return nil
+}
+
+func knownPeerKey(p peer.ID) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{KnownPeersPrefix, p.Pretty()})
+}
@@ -0,0 +1,72 @@
+package net_test
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	"github.com/libp2p/go-libp2p-peerstore/pstoremem"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestKnownPeers(t *testing.T) {
+	tf.UnitTest(t)
+
+	addr, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/6000")
+	require.NoError(t, err)
+	newPeer := func(t *testing.T, ps pstore.Peerstore) peer.ID {
+		p := th.RequireRandomPeerID(t)
+		ps.AddAddr(p, addr, pstore.PermanentAddrTTL)
+		return p
+	}
+
+	t.Run("persists peers most recently seen first", func(t *testing.T) {
+		ds := dss.MutexWrap(datastore.NewMapDatastore())
+		ps := pstoremem.NewPeerstore()
+		kp := net.NewKnownPeers(ds, ps, 10)
+		first, second := newPeer(t, ps), newPeer(t, ps)
+		require.NoError(t, kp.Add(first))
+		require.NoError(t, kp.Add(second))
+
+		// A new instance, as after a restart, knows the same peers.
+		peers, err := net.NewKnownPeers(ds, pstoremem.NewPeerstore(), 10).Peers()
+		require.NoError(t, err)
+		require.Len(t, peers, 2)
+		assert.Equal(t, second, peers[0].ID)
+		assert.Equal(t, first, peers[1].ID)
+		assert.Equal(t, []ma.Multiaddr{addr}, peers[0].Addrs)
+	})
+
+	t.Run("forgets the least recently seen peers", func(t *testing.T) {
+		ps := pstoremem.NewPeerstore()
+		kp := net.NewKnownPeers(dss.MutexWrap(datastore.NewMapDatastore()), ps, 2)
+		a, b, c := newPeer(t, ps), newPeer(t, ps), newPeer(t, ps)
+		require.NoError(t, kp.Add(a))
+		require.NoError(t, kp.Add(b))
+		require.NoError(t, kp.Add(a))
+		require.NoError(t, kp.Add(c))
+
+		peers, err := kp.Peers()
+		require.NoError(t, err)
+		require.Len(t, peers, 2)
+		assert.Equal(t, c, peers[0].ID)
+		assert.Equal(t, a, peers[1].ID)
+	})
+
+	t.Run("ignores peers without addresses", func(t *testing.T) {
+		kp := net.NewKnownPeers(dss.MutexWrap(datastore.NewMapDatastore()), pstoremem.NewPeerstore(), 10)
+		require.NoError(t, kp.Add(th.RequireRandomPeerID(t)))
+
+		peers, err := kp.Peers()
+		require.NoError(t, err)
+		assert.Empty(t, peers)
+	})
+}
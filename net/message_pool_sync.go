@@ -0,0 +1,158 @@
+package net
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// MessagePoolSyncProtocol is the libp2p protocol identifier for fetching the
+// pending messages of a peer's message pool.
+const MessagePoolSyncProtocol = "/fil/mpool/sync/1.0.0"
+
+// MaxMessagePoolSyncCids is the maximum number of cids sent in a message pool
+// sync request, which keeps the request within the size of a single read.
+const MaxMessagePoolSyncCids = 5000
+
+// MaxMessagesPerPoolSync is the maximum number of messages served in response
+// to a single message pool sync request.
+const MaxMessagesPerPoolSync = 2000
+
+// messagePoolSyncTimeout bounds the time spent serving or reading a response.
+const messagePoolSyncTimeout = time.Minute
+
+var logMessagePoolSync = logging.Logger("net.mpoolsync")
+
+func init() {
+	cbor.RegisterCborType(MessagePoolSyncRequest{})
+	cbor.RegisterCborType(MessagePoolSyncResponse{})
+}
+
+// MessagePoolSyncRequest asks a peer for its pending messages, except those
+// with the cids the requester already has.
+type MessagePoolSyncRequest struct {
+	Have []cid.Cid
+}
+
+// MessagePoolSyncResponse carries one message.  A response to a message pool
+// sync request is a stream of these, which ends when the peer has sent all
+// the messages the requester lacks.
+type MessagePoolSyncResponse struct {
+	Message *types.SignedMessage
+}
+
+type messagePoolSyncSource interface {
+	Pending() []*types.SignedMessage
+}
+
+// MessagePoolSyncService serves the pending messages of the message pool to
+// peers, so that nodes that restarted or were partitioned learn of messages
+// gossiped while they were away.
+type MessagePoolSyncService struct {
+	pool messagePoolSyncSource
+}
+
+// NewMessagePoolSyncService creates a service serving the pending messages of
+// pool and registers it to the given host.
+func NewMessagePoolSyncService(h host.Host, pool messagePoolSyncSource) *MessagePoolSyncService {
+	mss := &MessagePoolSyncService{pool: pool}
+	h.SetStreamHandler(MessagePoolSyncProtocol, mss.handleNewStream)
+	return mss
+}
+
+func (mss *MessagePoolSyncService) handleNewStream(s inet.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	if err := s.SetDeadline(time.Now().Add(messagePoolSyncTimeout)); err != nil {
+		logMessagePoolSync.Debugf("failed to set deadline: %s", err)
+	}
+
+	from := s.Conn().RemotePeer()
+	var req MessagePoolSyncRequest
+	if err := cbu.NewMsgReader(s).ReadMsg(&req); err != nil {
+		logMessagePoolSync.Debugf("bad message pool sync request from peer %s: %s", from, err)
+		return
+	}
+	if err := mss.serve(cbu.NewMsgWriter(s), req); err != nil {
+		logMessagePoolSync.Debugf("failed to serve messages to peer %s: %s", from, err)
+	}
+}
+
+// serve writes the pending messages not in req.Have to w, up to
+// MaxMessagesPerPoolSync.
+func (mss *MessagePoolSyncService) serve(w *cbu.MsgWriter, req MessagePoolSyncRequest) error {
+	have := make(map[cid.Cid]struct{}, len(req.Have))
+	for _, c := range req.Have {
+		have[c] = struct{}{}
+	}
+
+	sent := 0
+	for _, msg := range mss.pool.Pending() {
+		if sent >= MaxMessagesPerPoolSync {
+			break
+		}
+		c, err := msg.Cid()
+		if err != nil {
+			return err
+		}
+		if _, ok := have[c]; ok {
+			continue
+		}
+		if err := w.WriteMsg(MessagePoolSyncResponse{Message: msg}); err != nil {
+			return err
+		}
+		sent++
+	}
+	return nil
+}
+
+// RequestMessages asks p for the pending messages of its message pool other
+// than those with cids in have, of which only the first
+// MaxMessagePoolSyncCids are sent.  The messages received are not validated.
+func RequestMessages(ctx context.Context, h host.Host, p peer.ID, have []cid.Cid) ([]*types.SignedMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, messagePoolSyncTimeout)
+	defer cancel()
+
+	s, err := h.NewStream(ctx, p, MessagePoolSyncProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close() // nolint: errcheck
+	// Not every transport supports deadlines, so the stream is reset when
+	// ctx is done instead.
+	go func() {
+		<-ctx.Done()
+		s.Reset() // nolint: errcheck
+	}()
+
+	if len(have) > MaxMessagePoolSyncCids {
+		have = have[:MaxMessagePoolSyncCids]
+	}
+	if err := cbu.NewMsgWriter(s).WriteMsg(MessagePoolSyncRequest{Have: have}); err != nil {
+		return nil, err
+	}
+
+	r := cbu.NewMsgReader(s)
+	var msgs []*types.SignedMessage
+	for len(msgs) < MaxMessagesPerPoolSync {
+		var resp MessagePoolSyncResponse
+		if err := r.ReadMsg(&resp); err != nil {
+			// The peer has no more to send or sent something we won't read,
+			// keep what has been received.
+			break
+		}
+		if resp.Message != nil {
+			msgs = append(msgs, resp.Message)
+		}
+	}
+	return msgs, nil
+}
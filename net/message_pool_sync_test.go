@@ -0,0 +1,63 @@
+package net_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type fakePendingPool []*types.SignedMessage
+
+func (p fakePendingPool) Pending() []*types.SignedMessage {
+	return p
+}
+
+func TestMessagePoolSync(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	server, requester := mn.Hosts()[0], mn.Hosts()[1]
+	_, err = mn.ConnectPeers(server.ID(), requester.ID())
+	require.NoError(t, err)
+
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	msgs := types.NewSignedMsgs(3, signer)
+	net.NewMessagePoolSyncService(server, fakePendingPool(msgs))
+
+	t.Run("returns the messages the requester lacks", func(t *testing.T) {
+		have, err := msgs[1].Cid()
+		require.NoError(t, err)
+
+		received, err := net.RequestMessages(ctx, requester, server.ID(), []cid.Cid{have})
+		require.NoError(t, err)
+		require.Len(t, received, 2)
+		assert.True(t, msgs[0].Equals(received[0]))
+		assert.True(t, msgs[2].Equals(received[1]))
+	})
+
+	t.Run("returns nothing when the requester has all messages", func(t *testing.T) {
+		var have []cid.Cid
+		for _, m := range msgs {
+			c, err := m.Cid()
+			require.NoError(t, err)
+			have = append(have, c)
+		}
+
+		received, err := net.RequestMessages(ctx, requester, server.ID(), have)
+		require.NoError(t, err)
+		assert.Empty(t, received)
+	})
+}
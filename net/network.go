@@ -10,6 +10,7 @@ import (
 	"github.com/libp2p/go-libp2p-metrics"
 	"github.com/libp2p/go-libp2p-peer"
 	"github.com/libp2p/go-libp2p-peerstore"
+	"github.com/libp2p/go-libp2p-protocol"
 	"github.com/libp2p/go-libp2p-swarm"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/pkg/errors"
@@ -46,6 +47,9 @@ func (ci *SwarmConnInfo) Swap(i, j int) {
 // SwarmConnInfos represent details about a list of swarm connections.
 type SwarmConnInfos struct {
 	Peers []SwarmConnInfo
+	// SyncStats, if requested, are the sync stats of all peers chain
+	// requests were made to, connected or not.
+	SyncStats []PeerStat `json:",omitempty"`
 }
 
 func (ci SwarmConnInfos) Less(i, j int) bool {
@@ -68,6 +72,8 @@ type Network struct {
 	metrics.Reporter
 	*Router
 	*Pinger
+	*PeerTracker
+	reachability *Reachability
 }
 
 // New returns a new Network
@@ -78,14 +84,18 @@ func New(
 	router *Router,
 	reporter metrics.Reporter,
 	pinger *Pinger,
+	tracker *PeerTracker,
+	reachability *Reachability,
 ) *Network {
 	return &Network{
-		host:       host,
-		Pinger:     pinger,
-		Publisher:  publisher,
-		Reporter:   reporter,
-		Router:     router,
-		Subscriber: subscriber,
+		host:         host,
+		PeerTracker:  tracker,
+		Pinger:       pinger,
+		Publisher:    publisher,
+		Reporter:     reporter,
+		Router:       router,
+		Subscriber:   subscriber,
+		reachability: reachability,
 	}
 }
 
@@ -104,6 +114,32 @@ func (network *Network) GetBandwidthStats() metrics.Stats {
 	return network.Reporter.GetBandwidthTotals()
 }
 
+// GetBandwidthStatsByPeer gets stats on the bandwidth used with each
+// connected peer, keyed by peer id
+func (network *Network) GetBandwidthStatsByPeer() map[string]metrics.Stats {
+	out := make(map[string]metrics.Stats)
+	for _, p := range network.host.Network().Peers() {
+		out[p.Pretty()] = network.Reporter.GetBandwidthForPeer(p)
+	}
+	return out
+}
+
+// GetBandwidthStatsByProtocol gets stats on the bandwidth used by each
+// protocol the node speaks, keyed by protocol id
+func (network *Network) GetBandwidthStatsByProtocol() map[string]metrics.Stats {
+	out := make(map[string]metrics.Stats)
+	for _, pid := range network.host.Mux().Protocols() {
+		out[pid] = network.Reporter.GetBandwidthForProtocol(protocol.ID(pid))
+	}
+	return out
+}
+
+// GetReachability reports whether peers can dial the node directly or only
+// through relays.  It returns an error when the node is offline.
+func (network *Network) GetReachability() (*ReachabilityStatus, error) {
+	return network.reachability.Status()
+}
+
 // ConnectionResult represents the result of an attempted connection from the
 // Connect method.
 type ConnectionResult struct {
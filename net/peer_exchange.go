@@ -0,0 +1,167 @@
+package net
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+)
+
+// PeerExchangeProtocol is the libp2p protocol identifier for asking a peer
+// for other peers to connect to.
+const PeerExchangeProtocol = "/fil/peers/1.0.0"
+
+// MaxPeersPerExchange is the maximum number of peers served in response to a
+// single peer exchange request.
+const MaxPeersPerExchange = 20
+
+// peerExchangeTimeout bounds the time spent serving or reading a response.
+const peerExchangeTimeout = 30 * time.Second
+
+var logPeerExchange = logging.Logger("net.peerexchange")
+
+func init() {
+	cbor.RegisterCborType(PeerExchangeRequest{})
+	cbor.RegisterCborType(PeerExchangeResponse{})
+	cbor.RegisterCborType(PeerExchangeEntry{})
+}
+
+// PeerExchangeRequest asks a peer for up to Count of the peers it is
+// connected to.
+type PeerExchangeRequest struct {
+	Count uint64
+}
+
+// PeerExchangeEntry is a peer and the addresses it can be dialed at.
+type PeerExchangeEntry struct {
+	ID    string
+	Addrs [][]byte
+}
+
+// PeerExchangeResponse carries the peers sent in response to a request.
+type PeerExchangeResponse struct {
+	Peers []PeerExchangeEntry
+}
+
+// PeerExchangeService tells peers about other peers it is connected to, so
+// that nodes that can only reach a few peers can find more.
+type PeerExchangeService struct {
+	h host.Host
+}
+
+// NewPeerExchangeService creates a peer exchange service and registers it to
+// the given host.
+func NewPeerExchangeService(h host.Host) *PeerExchangeService {
+	pes := &PeerExchangeService{h: h}
+	h.SetStreamHandler(PeerExchangeProtocol, pes.handleNewStream)
+	return pes
+}
+
+func (pes *PeerExchangeService) handleNewStream(s inet.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	if err := s.SetDeadline(time.Now().Add(peerExchangeTimeout)); err != nil {
+		logPeerExchange.Debugf("failed to set deadline: %s", err)
+	}
+
+	from := s.Conn().RemotePeer()
+	var req PeerExchangeRequest
+	if err := cbu.NewMsgReader(s).ReadMsg(&req); err != nil {
+		logPeerExchange.Debugf("bad peer exchange request from peer %s: %s", from, err)
+		return
+	}
+	if err := cbu.NewMsgWriter(s).WriteMsg(pes.response(from, req)); err != nil {
+		logPeerExchange.Debugf("failed to serve peers to peer %s: %s", from, err)
+	}
+}
+
+// response picks up to the requested number of random connected peers with
+// known addresses, other than the requester.
+func (pes *PeerExchangeService) response(from peer.ID, req PeerExchangeRequest) PeerExchangeResponse {
+	count := req.Count
+	if count > MaxPeersPerExchange {
+		count = MaxPeersPerExchange
+	}
+
+	var resp PeerExchangeResponse
+	peers := pes.h.Network().Peers()
+	for _, i := range rand.Perm(len(peers)) {
+		if uint64(len(resp.Peers)) >= count {
+			break
+		}
+		p := peers[i]
+		if p == from {
+			continue
+		}
+		addrs := pes.h.Peerstore().Addrs(p)
+		if len(addrs) == 0 {
+			continue
+		}
+		entry := PeerExchangeEntry{ID: p.Pretty()}
+		for _, a := range addrs {
+			entry.Addrs = append(entry.Addrs, a.Bytes())
+		}
+		resp.Peers = append(resp.Peers, entry)
+	}
+	return resp
+}
+
+// RequestPeers asks p for up to count of the peers it is connected to.
+func RequestPeers(ctx context.Context, h host.Host, p peer.ID, count uint64) ([]pstore.PeerInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, peerExchangeTimeout)
+	defer cancel()
+
+	s, err := h.NewStream(ctx, p, PeerExchangeProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close() // nolint: errcheck
+	// Not every transport supports deadlines, so the stream is reset when
+	// ctx is done instead.
+	go func() {
+		<-ctx.Done()
+		s.Reset() // nolint: errcheck
+	}()
+
+	if err := cbu.NewMsgWriter(s).WriteMsg(PeerExchangeRequest{Count: count}); err != nil {
+		return nil, err
+	}
+	var resp PeerExchangeResponse
+	if err := cbu.NewMsgReader(s).ReadMsg(&resp); err != nil {
+		return nil, err
+	}
+
+	var infos []pstore.PeerInfo
+	for _, entry := range resp.Peers {
+		id, err := peer.IDB58Decode(entry.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "peer sent a malformed peer id %q", entry.ID)
+		}
+		if id == h.ID() {
+			continue
+		}
+		info := pstore.PeerInfo{ID: id}
+		for _, b := range entry.Addrs {
+			a, err := ma.NewMultiaddrBytes(b)
+			if err != nil {
+				return nil, errors.Wrapf(err, "peer sent a malformed address for %s", id)
+			}
+			info.Addrs = append(info.Addrs, a)
+		}
+		infos = append(infos, info)
+		if uint64(len(infos)) == count {
+			break
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,53 @@
+package net_test
+
+import (
+	"context"
+	"testing"
+
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	"github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestPeerExchange(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The server is connected to the requester and to another peer the
+	// requester doesn't know of.
+	mn, err := mocknet.WithNPeers(ctx, 3)
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	server, requester, other := mn.Hosts()[0], mn.Hosts()[1], mn.Hosts()[2]
+	net.NewPeerExchangeService(server)
+	_, err = mn.ConnectPeers(server.ID(), requester.ID())
+	require.NoError(t, err)
+	_, err = mn.ConnectPeers(server.ID(), other.ID())
+	require.NoError(t, err)
+	// Identify records the listen addresses of connected peers, which the
+	// mock network doesn't run.
+	server.Peerstore().AddAddrs(other.ID(), other.Addrs(), pstore.PermanentAddrTTL)
+
+	t.Run("returns the other peers of the server", func(t *testing.T) {
+		peers, err := net.RequestPeers(ctx, requester, server.ID(), 10)
+		require.NoError(t, err)
+		require.Len(t, peers, 1)
+		assert.Equal(t, other.ID(), peers[0].ID)
+		assert.Equal(t, other.Addrs(), peers[0].Addrs)
+
+		// The peer can be dialed at the addresses received.
+		require.NoError(t, requester.Connect(ctx, peers[0]))
+	})
+
+	t.Run("returns no more than the requested count", func(t *testing.T) {
+		peers, err := net.RequestPeers(ctx, requester, server.ID(), 0)
+		require.NoError(t, err)
+		assert.Empty(t, peers)
+	})
+}
@@ -0,0 +1,70 @@
+package net
+
+import (
+	"sync"
+
+	ifconnmgr "github.com/libp2p/go-libp2p-interface-connmgr"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+const (
+	// SyncTag protects peers the node is syncing a chain from.
+	SyncTag = "sync"
+	// StorageDealTag protects peers transferring the data of a storage deal.
+	StorageDealTag = "storage-deal"
+)
+
+// PeerProtector keeps the connection manager from pruning connections to
+// peers while they serve the node.  A peer may serve several tasks under a
+// tag at once, and stays protected until the last of them is done.
+type PeerProtector struct {
+	cm ifconnmgr.ConnManager
+
+	mu     sync.Mutex
+	counts map[protection]int
+}
+
+type protection struct {
+	p   peer.ID
+	tag string
+}
+
+// NewPeerProtector returns a PeerProtector protecting peers in cm.
+func NewPeerProtector(cm ifconnmgr.ConnManager) *PeerProtector {
+	return &PeerProtector{
+		cm:     cm,
+		counts: make(map[protection]int),
+	}
+}
+
+// Protect protects p under tag until the returned function is called.
+func (pp *PeerProtector) Protect(p peer.ID, tag string) (release func()) {
+	key := protection{p, tag}
+
+	pp.mu.Lock()
+	pp.counts[key]++
+	if pp.counts[key] == 1 {
+		pp.cm.Protect(p, tag)
+	}
+	pp.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pp.mu.Lock()
+			defer pp.mu.Unlock()
+			pp.counts[key]--
+			if pp.counts[key] == 0 {
+				delete(pp.counts, key)
+				pp.cm.Unprotect(p, tag)
+			}
+		})
+	}
+}
+
+// Protected returns the number of tasks p is protected for under tag.
+func (pp *PeerProtector) Protected(p peer.ID, tag string) int {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return pp.counts[protection{p, tag}]
+}
@@ -0,0 +1,68 @@
+package net_test
+
+import (
+	"testing"
+
+	ifconnmgr "github.com/libp2p/go-libp2p-interface-connmgr"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+// protectConnMgr records the tags peers are protected under.
+type protectConnMgr struct {
+	ifconnmgr.NullConnMgr
+	protected map[peer.ID]map[string]bool
+}
+
+func (cm *protectConnMgr) Protect(p peer.ID, tag string) {
+	if cm.protected[p] == nil {
+		cm.protected[p] = make(map[string]bool)
+	}
+	cm.protected[p][tag] = true
+}
+
+func (cm *protectConnMgr) Unprotect(p peer.ID, tag string) bool {
+	delete(cm.protected[p], tag)
+	return len(cm.protected[p]) > 0
+}
+
+func TestPeerProtector(t *testing.T) {
+	tf.UnitTest(t)
+
+	p := th.RequireRandomPeerID(t)
+
+	t.Run("protects a peer until its last task is done", func(t *testing.T) {
+		cm := &protectConnMgr{protected: make(map[peer.ID]map[string]bool)}
+		pp := net.NewPeerProtector(cm)
+
+		release1 := pp.Protect(p, net.SyncTag)
+		release2 := pp.Protect(p, net.SyncTag)
+		assert.True(t, cm.protected[p][net.SyncTag])
+		assert.Equal(t, 2, pp.Protected(p, net.SyncTag))
+
+		release1()
+		// Releasing twice has no effect.
+		release1()
+		assert.True(t, cm.protected[p][net.SyncTag])
+
+		release2()
+		assert.False(t, cm.protected[p][net.SyncTag])
+		assert.Equal(t, 0, pp.Protected(p, net.SyncTag))
+	})
+
+	t.Run("protects under each tag separately", func(t *testing.T) {
+		cm := &protectConnMgr{protected: make(map[peer.ID]map[string]bool)}
+		pp := net.NewPeerProtector(cm)
+
+		releaseSync := pp.Protect(p, net.SyncTag)
+		pp.Protect(p, net.StorageDealTag)
+		releaseSync()
+
+		assert.False(t, cm.protected[p][net.SyncTag])
+		assert.True(t, cm.protected[p][net.StorageDealTag])
+	})
+}
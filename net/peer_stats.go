@@ -0,0 +1,320 @@
+package net
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+)
+
+func init() {
+	cbor.RegisterCborType(peerStatsRecord{})
+}
+
+var logPeerStats = logging.Logger("net.peer_stats")
+
+// PeerStatsPrefix is the datastore prefix for the sync stats of peers.
+const PeerStatsPrefix = "peerstats"
+
+// peerTagKey breaks the per-peer metrics down by peer id.
+var peerTagKey, _ = tag.NewKey("peer")
+
+var (
+	peerLatencyTimer    = metrics.NewTimer("net/peer_latency", "Round trip latency to peers", peerTagKey)
+	peerRequestTimer    = metrics.NewTimer("net/peer_request_duration", "Duration of chain requests to peers", peerTagKey)
+	peerRequestsCt      = metrics.NewInt64Counter("net/peer_requests", "Number of chain requests made to peers", peerTagKey)
+	peerFailuresCt      = metrics.NewInt64Counter("net/peer_request_failures", "Number of chain requests to peers that failed", peerTagKey)
+	peerBlocksPerSecond = metrics.NewInt64Gauge("net/peer_blocks_per_second", "Rate at which peers served blocks to chain requests", peerTagKey)
+	peerBytesCt         = metrics.NewInt64Counter("net/peer_bytes", "Bytes of blocks served by peers to chain requests", peerTagKey)
+)
+
+const (
+	// ewmaWeight is the weight of the newest sample in the moving averages
+	// of a peer's latency and request duration.
+	ewmaWeight = 0.2
+	// minScoredRequests is the number of requests made to a peer before its
+	// error rate counts against its score.
+	minScoredRequests = 10
+	// maxSyncErrorRate is the error rate above which a peer's failed
+	// requests are recorded as offenses.
+	maxSyncErrorRate = 0.5
+)
+
+// PeerStat describes the performance of a peer.
+type PeerStat struct {
+	Peer peer.ID `json:"peer"`
+	// Latency is the moving average of the round trip time to the peer.
+	Latency time.Duration `json:"latency"`
+	// RequestDuration is the moving average of the duration of successful
+	// chain requests to the peer.
+	RequestDuration time.Duration `json:"requestDuration"`
+	Requests        uint64        `json:"requests"`
+	Failures        uint64        `json:"failures"`
+	// ErrorRate is the fraction of requests to the peer that failed.
+	ErrorRate float64 `json:"errorRate"`
+	// Blocks and Bytes count the block headers served by the peer and their
+	// size.
+	Blocks uint64 `json:"blocks"`
+	Bytes  uint64 `json:"bytes"`
+	// BlocksPerSecond is the rate at which the peer served blocks over all
+	// successful requests.
+	BlocksPerSecond float64 `json:"blocksPerSecond"`
+}
+
+// peerStatsRecord is the record of a peer's stats, persisted as is.
+type peerStatsRecord struct {
+	Latency         time.Duration
+	RequestDuration time.Duration
+	Requests        uint64
+	Failures        uint64
+	Blocks          uint64
+	Bytes           uint64
+	// Busy is the total duration of successful requests.
+	Busy time.Duration
+}
+
+// PeerStats records the latency to peers and the performance of the chain
+// requests made to them, exporting both as metrics, so that requests can be
+// sent to the fastest peers first.  Once persisted, the stats are kept across
+// restarts, so that operators can tell which peers serve syncs best.
+type PeerStats struct {
+	mu      sync.Mutex
+	peers   map[peer.ID]*peerStatsRecord
+	ds      datastore.Datastore
+	tracker *PeerTracker
+}
+
+// NewPeerStats returns an empty PeerStats.
+func NewPeerStats() *PeerStats {
+	return &PeerStats{peers: make(map[peer.ID]*peerStatsRecord)}
+}
+
+// Persist loads the stats previously saved to ds and saves every update to
+// them from now on.
+func (ps *PeerStats) Persist(ds datastore.Datastore) error {
+	results, err := ds.Query(query.Query{Prefix: "/" + PeerStatsPrefix})
+	if err != nil {
+		return errors.Wrap(err, "failed to query peer stats")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to query peer stats")
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, entry := range entries {
+		p, err := peer.IDB58Decode(datastore.NewKey(entry.Key).BaseNamespace())
+		if err != nil {
+			return errors.Wrapf(err, "malformed peer stats key %s", entry.Key)
+		}
+		var rec peerStatsRecord
+		if err := cbor.DecodeInto(entry.Value, &rec); err != nil {
+			return errors.Wrap(err, "failed to unmarshal peer stats")
+		}
+		ps.peers[p] = &rec
+	}
+	ps.ds = ds
+	return nil
+}
+
+// ReportTo makes ps record an offense with tracker for each request failing
+// to a peer whose requests fail too often.
+func (ps *PeerStats) ReportTo(tracker *PeerTracker) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.tracker = tracker
+}
+
+// StartLatency starts measuring a round trip to p, which is recorded by
+// calling the returned function once it completes.
+func (ps *PeerStats) StartLatency(p peer.ID) func() {
+	ctx := peerContext(p)
+	sw := peerLatencyTimer.Start(ctx)
+	start := time.Now()
+	return func() {
+		sw.Stop(ctx)
+		d := time.Since(start)
+
+		ps.mu.Lock()
+		defer ps.mu.Unlock()
+		rec := ps.record(p)
+		rec.Latency = ewma(rec.Latency, d)
+		ps.save(p, rec)
+	}
+}
+
+// StartRequest starts measuring a chain request to p, which is recorded by
+// calling the returned function with the number and total size of the blocks
+// received and the request's error, if any.
+func (ps *PeerStats) StartRequest(p peer.ID) func(blocks int, bytes uint64, err error) {
+	ctx := peerContext(p)
+	sw := peerRequestTimer.Start(ctx)
+	start := time.Now()
+	return func(blocks int, bytes uint64, err error) {
+		sw.Stop(ctx)
+		d := time.Since(start)
+		peerRequestsCt.Inc(ctx, 1)
+		if err != nil {
+			peerFailuresCt.Inc(ctx, 1)
+		}
+
+		ps.mu.Lock()
+		rec := ps.record(p)
+		rec.Requests++
+		if err != nil {
+			rec.Failures++
+		} else {
+			rec.RequestDuration = ewma(rec.RequestDuration, d)
+			rec.Blocks += uint64(blocks)
+			rec.Bytes += bytes
+			rec.Busy += d
+			peerBlocksPerSecond.Set(ctx, int64(rec.blocksPerSecond()))
+			peerBytesCt.Inc(ctx, int64(bytes))
+		}
+		ps.save(p, rec)
+		unreliable := err != nil && rec.Requests >= minScoredRequests && rec.errorRate() > maxSyncErrorRate
+		tracker := ps.tracker
+		ps.mu.Unlock()
+
+		// The tracker may disconnect the peer, so it is called without the
+		// lock held.
+		if unreliable && tracker != nil {
+			tracker.Record(p, UnreliableSync)
+		}
+	}
+}
+
+// Stats returns the stats of every peer measured, by peer id.
+func (ps *PeerStats) Stats() []PeerStat {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	var out []PeerStat
+	for p, rec := range ps.peers {
+		out = append(out, PeerStat{
+			Peer:            p,
+			Latency:         rec.Latency,
+			RequestDuration: rec.RequestDuration,
+			Requests:        rec.Requests,
+			Failures:        rec.Failures,
+			ErrorRate:       rec.errorRate(),
+			Blocks:          rec.Blocks,
+			Bytes:           rec.Bytes,
+			BlocksPerSecond: rec.blocksPerSecond(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Peer < out[j].Peer })
+	return out
+}
+
+// Fastest sorts peers in the order requests should be sent to them: peers
+// that served requests by their expected request duration, followed by
+// peers yet to serve a request by latency, unmeasured ones last, followed by
+// peers whose requests all failed.  The order of peers that can't be told apart is kept.
+func (ps *PeerStats) Fastest(peers []peer.ID) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	type rank struct {
+		class int
+		cost  float64
+	}
+	ranks := make(map[peer.ID]rank, len(peers))
+	for _, p := range peers {
+		rec, ok := ps.peers[p]
+		switch {
+		case !ok:
+			ranks[p] = rank{class: 1, cost: math.Inf(1)}
+		case rec.Requests == 0:
+			ranks[p] = rank{class: 1, cost: float64(rec.Latency)}
+		case rec.Failures == rec.Requests:
+			ranks[p] = rank{class: 2}
+		default:
+			// The expected duration until a request succeeds, retrying
+			// failures.
+			ranks[p] = rank{class: 0, cost: float64(rec.RequestDuration) / (1 - rec.errorRate())}
+		}
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		ri, rj := ranks[peers[i]], ranks[peers[j]]
+		if ri.class != rj.class {
+			return ri.class < rj.class
+		}
+		return ri.cost < rj.cost
+	})
+}
+
+// record returns p's record, creating it if needed.  It must be called with
+// the lock held.
+func (ps *PeerStats) record(p peer.ID) *peerStatsRecord {
+	rec, ok := ps.peers[p]
+	if !ok {
+		rec = &peerStatsRecord{}
+		ps.peers[p] = rec
+	}
+	return rec
+}
+
+// save persists p's record, if the stats are persisted.  It must be called
+// with the lock held.
+func (ps *PeerStats) save(p peer.ID, rec *peerStatsRecord) {
+	if ps.ds == nil {
+		return
+	}
+	datum, err := cbor.DumpObject(rec)
+	if err != nil {
+		logPeerStats.Warningf("could not marshal stats of peer %s: %s", p.Pretty(), err)
+		return
+	}
+	if err := ps.ds.Put(peerStatsKey(p), datum); err != nil {
+		logPeerStats.Warningf("could not save stats of peer %s: %s", p.Pretty(), err)
+	}
+}
+
+func peerStatsKey(p peer.ID) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{PeerStatsPrefix, p.Pretty()})
+}
+
+func (rec *peerStatsRecord) blocksPerSecond() float64 {
+	if rec.Busy <= 0 {
+		return 0
+	}
+	return float64(rec.Blocks) / rec.Busy.Seconds()
+}
+
+func (rec *peerStatsRecord) errorRate() float64 {
+	if rec.Requests == 0 {
+		return 0
+	}
+	return float64(rec.Failures) / float64(rec.Requests)
+}
+
+// ewma adds sample to the moving average avg, which is zero before the
+// first sample.
+func ewma(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return time.Duration(ewmaWeight*float64(sample) + (1-ewmaWeight)*float64(avg))
+}
+
+// peerContext returns a context tagging the metrics recorded with it with p.
+func peerContext(p peer.ID) context.Context {
+	ctx, err := tag.New(context.Background(), tag.Upsert(peerTagKey, p.Pretty()))
+	if err != nil {
+		return context.Background()
+	}
+	return ctx
+}
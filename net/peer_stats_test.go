@@ -0,0 +1,105 @@
+package net_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestPeerStats(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("records requests and their failures", func(t *testing.T) {
+		ps := net.NewPeerStats()
+		p := th.RequireRandomPeerID(t)
+
+		done := ps.StartRequest(p)
+		time.Sleep(10 * time.Millisecond)
+		done(5, 5000, nil)
+		ps.StartRequest(p)(0, 0, errors.New("timeout"))
+
+		stats := ps.Stats()
+		require.Len(t, stats, 1)
+		assert.Equal(t, p, stats[0].Peer)
+		assert.Equal(t, uint64(2), stats[0].Requests)
+		assert.Equal(t, uint64(1), stats[0].Failures)
+		assert.Equal(t, 0.5, stats[0].ErrorRate)
+		assert.Equal(t, uint64(5), stats[0].Blocks)
+		assert.Equal(t, uint64(5000), stats[0].Bytes)
+		assert.True(t, stats[0].RequestDuration >= 10*time.Millisecond)
+		assert.True(t, stats[0].BlocksPerSecond > 0 && stats[0].BlocksPerSecond <= 500)
+	})
+
+	t.Run("records latency", func(t *testing.T) {
+		ps := net.NewPeerStats()
+		p := th.RequireRandomPeerID(t)
+
+		done := ps.StartLatency(p)
+		time.Sleep(10 * time.Millisecond)
+		done()
+
+		stats := ps.Stats()
+		require.Len(t, stats, 1)
+		assert.True(t, stats[0].Latency >= 10*time.Millisecond)
+		assert.Equal(t, uint64(0), stats[0].Requests)
+	})
+
+	t.Run("orders peers fastest first", func(t *testing.T) {
+		ps := net.NewPeerStats()
+		fast, slow, failing, measured, unknown := th.RequireRandomPeerID(t), th.RequireRandomPeerID(t),
+			th.RequireRandomPeerID(t), th.RequireRandomPeerID(t), th.RequireRandomPeerID(t)
+
+		done := ps.StartRequest(slow)
+		time.Sleep(20 * time.Millisecond)
+		done(1, 1000, nil)
+		ps.StartRequest(fast)(1, 1000, nil)
+		ps.StartRequest(failing)(0, 0, errors.New("timeout"))
+		ps.StartLatency(measured)()
+
+		peers := []peer.ID{unknown, failing, measured, slow, fast}
+		ps.Fastest(peers)
+		assert.Equal(t, []peer.ID{fast, slow, measured, unknown, failing}, peers)
+	})
+
+	t.Run("keeps stats across restarts", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		p := th.RequireRandomPeerID(t)
+
+		ps := net.NewPeerStats()
+		require.NoError(t, ps.Persist(ds))
+		ps.StartRequest(p)(3, 300, nil)
+		ps.StartLatency(p)()
+
+		restarted := net.NewPeerStats()
+		require.NoError(t, restarted.Persist(ds))
+		assert.Equal(t, ps.Stats(), restarted.Stats())
+	})
+
+	t.Run("reports peers whose requests fail too often", func(t *testing.T) {
+		ps := net.NewPeerStats()
+		tracker := net.NewPeerTracker(nil)
+		ps.ReportTo(tracker)
+		reliable, unreliable := th.RequireRandomPeerID(t), th.RequireRandomPeerID(t)
+
+		for i := 0; i < 10; i++ {
+			ps.StartRequest(unreliable)(0, 0, errors.New("timeout"))
+			ps.StartRequest(reliable)(1, 100, nil)
+		}
+		ps.StartRequest(reliable)(0, 0, errors.New("timeout"))
+
+		// Only the tenth failure counts, once enough requests were made.
+		scores := tracker.PeerScores()
+		require.Len(t, scores, 1)
+		assert.Equal(t, unreliable, scores[0].Peer)
+		assert.Equal(t, map[string]int{"unreliable sync": 1}, scores[0].Offenses)
+	})
+}
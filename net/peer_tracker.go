@@ -0,0 +1,265 @@
+package net
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+)
+
+var logPeerTracker = logging.Logger("net.peer_tracker")
+
+var peersGauge = metrics.NewInt64Gauge("net/peers", "Number of peers connected")
+
+// Offense is a kind of misbehavior attributed to a peer.
+type Offense int
+
+const (
+	// InvalidBlock is recorded against a peer that sent or announced a
+	// block that failed validation.
+	InvalidBlock Offense = iota
+	// FetchTimeout is recorded against a peer that announced blocks it
+	// then failed to provide.
+	FetchTimeout
+	// ProtocolViolation is recorded against a peer that sent a malformed
+	// message.
+	ProtocolViolation
+	// ForeignNetwork is recorded against a peer of another network, on a
+	// node that only accepts peers of its own.
+	ForeignNetwork
+	// UnreliableSync is recorded against a peer for each chain request
+	// that fails once most of its requests have failed.
+	UnreliableSync
+)
+
+func (o Offense) String() string {
+	switch o {
+	case InvalidBlock:
+		return "invalid block"
+	case FetchTimeout:
+		return "fetch timeout"
+	case ProtocolViolation:
+		return "protocol violation"
+	case ForeignNetwork:
+		return "foreign network"
+	case UnreliableSync:
+		return "unreliable sync"
+	default:
+		return "unknown offense"
+	}
+}
+
+// penalty returns the amount an offense lowers a peer's score by.
+func (o Offense) penalty() int {
+	switch o {
+	case InvalidBlock:
+		return 50
+	case FetchTimeout, UnreliableSync:
+		return 10
+	case ForeignNetwork:
+		// Banned at once.
+		return initialPeerScore + 1
+	default:
+		return 25
+	}
+}
+
+const (
+	// initialPeerScore is the score of a peer with no recent offenses.
+	initialPeerScore = 100
+	// offenseWindow is how long an offense counts against a peer's score.
+	offenseWindow = 10 * time.Minute
+	// banDuration is how long a peer whose score drops below zero is banned.
+	banDuration = 10 * time.Minute
+)
+
+// ErrPeerNotBanned is returned when unbanning a peer that is not banned.
+var ErrPeerNotBanned = errors.New("peer is not banned")
+
+// PeerScore describes a peer's standing with the tracker.
+type PeerScore struct {
+	Peer  peer.ID `json:"peer"`
+	Score int     `json:"score"`
+	// Offenses counts the peer's recent offenses by kind.
+	Offenses map[string]int `json:"offenses,omitempty"`
+	// BannedUntil is set while the peer is banned.
+	BannedUntil *time.Time `json:"bannedUntil,omitempty"`
+}
+
+type offenseRecord struct {
+	offense Offense
+	at      time.Time
+}
+
+type peerRecord struct {
+	offenses    []offenseRecord
+	bannedUntil time.Time
+}
+
+// PeerTracker scores peers by their recent offenses and temporarily bans
+// those whose score drops below zero, so that a single misbehaving peer
+// cannot repeatedly feed the node bad data.
+type PeerTracker struct {
+	onBan func(peer.ID)
+	now   func() time.Time
+
+	mu    sync.Mutex
+	peers map[peer.ID]*peerRecord
+}
+
+// NewPeerTracker returns a PeerTracker that calls onBan, if not nil, with
+// each peer it bans.
+func NewPeerTracker(onBan func(peer.ID)) *PeerTracker {
+	return &PeerTracker{
+		onBan: onBan,
+		now:   time.Now,
+		peers: make(map[peer.ID]*peerRecord),
+	}
+}
+
+// Record records an offense by p, banning it if its score drops below zero.
+func (pt *PeerTracker) Record(p peer.ID, o Offense) {
+	pt.mu.Lock()
+	now := pt.now()
+	rec, ok := pt.peers[p]
+	if !ok {
+		rec = &peerRecord{}
+		pt.peers[p] = rec
+	}
+	rec.offenses = append(rec.offenses, offenseRecord{offense: o, at: now})
+	banned := false
+	if score(rec, now) < 0 && !now.Before(rec.bannedUntil) {
+		rec.bannedUntil = now.Add(banDuration)
+		banned = true
+	}
+	pt.mu.Unlock()
+
+	if banned {
+		logPeerTracker.Warningf("banning peer %s until %s after %s", p.Pretty(), now.Add(banDuration), o)
+		if pt.onBan != nil {
+			pt.onBan(p)
+		}
+	}
+}
+
+// Score returns p's current score.
+func (pt *PeerTracker) Score(p peer.ID) int {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	rec, ok := pt.peers[p]
+	if !ok {
+		return initialPeerScore
+	}
+	return score(rec, pt.now())
+}
+
+// IsBanned returns true if p is currently banned.
+func (pt *PeerTracker) IsBanned(p peer.ID) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	rec, ok := pt.peers[p]
+	return ok && pt.now().Before(rec.bannedUntil)
+}
+
+// PeerScores lists the peers with recent offenses or a ban, lowest score
+// first.  Peers with neither are forgotten.
+func (pt *PeerTracker) PeerScores() []PeerScore {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	now := pt.now()
+	var out []PeerScore
+	for p, rec := range pt.peers {
+		prune(rec, now)
+		banned := now.Before(rec.bannedUntil)
+		if len(rec.offenses) == 0 && !banned {
+			delete(pt.peers, p)
+			continue
+		}
+
+		ps := PeerScore{
+			Peer:     p,
+			Score:    score(rec, now),
+			Offenses: make(map[string]int),
+		}
+		for _, o := range rec.offenses {
+			ps.Offenses[o.offense.String()]++
+		}
+		if banned {
+			until := rec.bannedUntil
+			ps.BannedUntil = &until
+		}
+		out = append(out, ps)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score < out[j].Score
+		}
+		return out[i].Peer < out[j].Peer
+	})
+	return out
+}
+
+// Unban lifts p's ban and forgives its offenses.
+func (pt *PeerTracker) Unban(p peer.ID) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	rec, ok := pt.peers[p]
+	if !ok || !pt.now().Before(rec.bannedUntil) {
+		return errors.Wrap(ErrPeerNotBanned, p.Pretty())
+	}
+	delete(pt.peers, p)
+	return nil
+}
+
+// RefuseBanned arranges for connections from banned peers to be closed as
+// soon as they are established on n.
+func (pt *PeerTracker) RefuseBanned(n inet.Network) {
+	n.Notify(&inet.NotifyBundle{
+		ConnectedF: func(_ inet.Network, c inet.Conn) {
+			if pt.IsBanned(c.RemotePeer()) {
+				logPeerTracker.Debugf("closing connection from banned peer %s", c.RemotePeer().Pretty())
+				c.Close() // nolint: errcheck
+			}
+		},
+	})
+}
+
+// RecordPeers keeps the net/peers gauge up to date with the number of peers
+// connected on n.
+func RecordPeers(n inet.Network) {
+	record := func(n inet.Network, _ inet.Conn) {
+		peersGauge.Set(context.Background(), int64(len(n.Peers())))
+	}
+	n.Notify(&inet.NotifyBundle{ConnectedF: record, DisconnectedF: record})
+}
+
+// score returns the score of the peer with record rec at time now.  It must
+// be called with the tracker's lock held.
+func score(rec *peerRecord, now time.Time) int {
+	prune(rec, now)
+	s := initialPeerScore
+	for _, o := range rec.offenses {
+		s -= o.offense.penalty()
+	}
+	return s
+}
+
+// prune drops the offenses in rec that no longer count against the peer.
+func prune(rec *peerRecord, now time.Time) {
+	i := 0
+	for i < len(rec.offenses) && now.Sub(rec.offenses[i].at) >= offenseWindow {
+		i++
+	}
+	rec.offenses = rec.offenses[i:]
+}
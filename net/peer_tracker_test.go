@@ -0,0 +1,98 @@
+package net
+
+import (
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestPeerTracker(t *testing.T) {
+	tf.UnitTest(t)
+
+	p1 := peer.ID("peer1")
+	p2 := peer.ID("peer2")
+
+	newTracker := func() (*PeerTracker, *time.Time, *[]peer.ID) {
+		now := time.Unix(1000000, 0)
+		var banned []peer.ID
+		pt := NewPeerTracker(func(p peer.ID) { banned = append(banned, p) })
+		pt.now = func() time.Time { return now }
+		return pt, &now, &banned
+	}
+
+	t.Run("bans a peer whose score drops below zero", func(t *testing.T) {
+		pt, _, banned := newTracker()
+		assert.Equal(t, initialPeerScore, pt.Score(p1))
+
+		pt.Record(p1, InvalidBlock)
+		pt.Record(p1, InvalidBlock)
+		assert.Equal(t, 0, pt.Score(p1))
+		assert.False(t, pt.IsBanned(p1))
+
+		pt.Record(p1, FetchTimeout)
+		assert.True(t, pt.IsBanned(p1))
+		assert.False(t, pt.IsBanned(p2))
+		assert.Equal(t, []peer.ID{p1}, *banned)
+
+		// Further offenses while banned do not ban again.
+		pt.Record(p1, ProtocolViolation)
+		assert.Len(t, *banned, 1)
+	})
+
+	t.Run("bans a peer of a foreign network at once", func(t *testing.T) {
+		pt, _, banned := newTracker()
+		pt.Record(p1, ForeignNetwork)
+		assert.True(t, pt.IsBanned(p1))
+		assert.Equal(t, []peer.ID{p1}, *banned)
+	})
+
+	t.Run("offenses and bans expire", func(t *testing.T) {
+		pt, now, _ := newTracker()
+		for i := 0; i < 3; i++ {
+			pt.Record(p1, InvalidBlock)
+		}
+		require.True(t, pt.IsBanned(p1))
+
+		*now = now.Add(banDuration)
+		assert.False(t, pt.IsBanned(p1))
+		assert.Equal(t, initialPeerScore, pt.Score(p1))
+		assert.Empty(t, pt.PeerScores())
+	})
+
+	t.Run("lists peers lowest score first", func(t *testing.T) {
+		pt, _, _ := newTracker()
+		pt.Record(p2, FetchTimeout)
+		for i := 0; i < 3; i++ {
+			pt.Record(p1, InvalidBlock)
+		}
+
+		scores := pt.PeerScores()
+		require.Len(t, scores, 2)
+		assert.Equal(t, p1, scores[0].Peer)
+		assert.Equal(t, -50, scores[0].Score)
+		assert.Equal(t, 3, scores[0].Offenses["invalid block"])
+		assert.NotNil(t, scores[0].BannedUntil)
+		assert.Equal(t, p2, scores[1].Peer)
+		assert.Equal(t, 90, scores[1].Score)
+		assert.Nil(t, scores[1].BannedUntil)
+	})
+
+	t.Run("unbans a banned peer", func(t *testing.T) {
+		pt, _, _ := newTracker()
+		for i := 0; i < 3; i++ {
+			pt.Record(p1, InvalidBlock)
+		}
+
+		require.NoError(t, pt.Unban(p1))
+		assert.False(t, pt.IsBanned(p1))
+		assert.Equal(t, initialPeerScore, pt.Score(p1))
+
+		assert.Equal(t, ErrPeerNotBanned, errors.Cause(pt.Unban(p1)))
+	})
+}
@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+)
+
+// topicTagKey breaks the deduplication metrics down by topic.
+var topicTagKey, _ = tag.NewKey("topic")
+
+var (
+	dedupHitCt  = metrics.NewInt64Counter("pubsub/dedup_hit", "Number of gossiped messages whose validation result was reused", topicTagKey)
+	dedupMissCt = metrics.NewInt64Counter("pubsub/dedup_miss", "Number of gossiped messages validated because their content was not recently seen", topicTagKey)
+)
+
+// dedupEntry is the result of validating some content.
+type dedupEntry struct {
+	err error
+	at  time.Time
+}
+
+// Deduplicate returns a validator that remembers the results of validate for
+// the content of the last size messages received on topic, and reuses a
+// result for ttl instead of validating the same content again.  Pubsub only
+// drops copies of a message with the same id, so this saves validating
+// content published several times, e.g. messages rebroadcast by their
+// sender.  A size of zero returns validate unchanged.
+func Deduplicate(topic string, validate Validator, size int, ttl time.Duration) Validator {
+	if size <= 0 {
+		return validate
+	}
+	cache, err := lru.New(size)
+	if err != nil {
+		// lru.New only fails for non-positive sizes.
+		panic(err)
+	}
+	metricsCtx, err := tag.New(context.Background(), tag.Upsert(topicTagKey, topic))
+	if err != nil {
+		metricsCtx = context.Background()
+	}
+
+	return func(ctx context.Context, msg Message) error {
+		key := sha256.Sum256(msg.GetData())
+		if v, ok := cache.Get(key); ok {
+			entry := v.(dedupEntry)
+			if time.Since(entry.at) < ttl {
+				dedupHitCt.Inc(metricsCtx, 1)
+				return entry.err
+			}
+		}
+		dedupMissCt.Inc(metricsCtx, 1)
+
+		err := validate(ctx, msg)
+		if ctx.Err() != nil {
+			// Validation was cut short, so its result says nothing about
+			// the content.
+			return err
+		}
+		cache.Add(key, dedupEntry{err: err, at: time.Now()})
+		return err
+	}
+}
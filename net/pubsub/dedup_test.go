@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestDeduplicate(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	// countingValidator rejects messages with the content "bad".
+	newCountingValidator := func() (Validator, *int) {
+		calls := 0
+		return func(ctx context.Context, msg Message) error {
+			calls++
+			if string(msg.GetData()) == "bad" {
+				return errors.New("bad message")
+			}
+			return nil
+		}, &calls
+	}
+
+	t.Run("reuses results for the same content", func(t *testing.T) {
+		validate, calls := newCountingValidator()
+		dedup := Deduplicate("test", validate, 10, time.Minute)
+
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("good")}))
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("good")}))
+		assert.Error(t, dedup(ctx, &FakeMessage{data: []byte("bad")}))
+		assert.Error(t, dedup(ctx, &FakeMessage{data: []byte("bad")}))
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("validates again after the ttl", func(t *testing.T) {
+		validate, calls := newCountingValidator()
+		dedup := Deduplicate("test", validate, 10, time.Millisecond)
+
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("good")}))
+		time.Sleep(5 * time.Millisecond)
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("good")}))
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("evicts the least recently seen content", func(t *testing.T) {
+		validate, calls := newCountingValidator()
+		dedup := Deduplicate("test", validate, 1, time.Minute)
+
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("a")}))
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("b")}))
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("a")}))
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("does not cache without a size", func(t *testing.T) {
+		validate, calls := newCountingValidator()
+		dedup := Deduplicate("test", validate, 0, time.Minute)
+
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("good")}))
+		assert.NoError(t, dedup(ctx, &FakeMessage{data: []byte("good")}))
+		assert.Equal(t, 2, *calls)
+	})
+}
@@ -0,0 +1,36 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+	libp2p "github.com/libp2p/go-libp2p-pubsub"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+)
+
+var log = logging.Logger("pubsub")
+
+// validatorTimeout bounds the time spent validating a single message.
+// Messages whose validation times out are dropped.
+const validatorTimeout = time.Second
+
+// Validator checks a message received on a topic, returning an error if it
+// is invalid.
+type Validator func(ctx context.Context, msg Message) error
+
+// RegisterTopicValidator registers validate with ps so that messages received
+// on topic are neither delivered to subscribers nor relayed to other peers
+// unless validate accepts them.  Rejected messages are counted by rejectedCt.
+func RegisterTopicValidator(ps *libp2p.PubSub, topic string, validate Validator, rejectedCt *metrics.Int64Counter) error {
+	return ps.RegisterTopicValidator(topic, func(ctx context.Context, _ peer.ID, msg *libp2p.Message) bool {
+		if err := validate(ctx, msg); err != nil {
+			log.Debugf("rejected message from %s on topic %s: %s", msg.GetFrom().Pretty(), topic, err)
+			rejectedCt.Inc(ctx, 1)
+			return false
+		}
+		return true
+	}, libp2p.WithValidatorTimeout(validatorTimeout))
+}
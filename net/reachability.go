@@ -0,0 +1,86 @@
+package net
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p-autonat"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	"github.com/libp2p/go-libp2p-host"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+)
+
+// Reachability values of a ReachabilityStatus.
+const (
+	// ReachabilityUnknown means no peer has reported whether it could dial
+	// the node yet.
+	ReachabilityUnknown = "unknown"
+	// ReachabilityPublic means peers can dial the node directly.
+	ReachabilityPublic = "public"
+	// ReachabilityPrivate means the node is behind a NAT or firewall and can
+	// only be reached through relays.
+	ReachabilityPrivate = "private"
+)
+
+// ReachabilityStatus describes how peers can reach the node.
+type ReachabilityStatus struct {
+	Reachability string `json:"reachability"`
+	// PublicAddr is the address at which peers dialed the node, if it is
+	// publicly reachable.
+	PublicAddr string `json:"publicAddr,omitempty"`
+	// RelayAddrs are the circuit addresses through which peers can reach
+	// the node via a relay.
+	RelayAddrs []string `json:"relayAddrs"`
+	// ListenAddrs are the node's other addresses.
+	ListenAddrs []string `json:"listenAddrs"`
+}
+
+// Reachability uses the AutoNAT protocol to determine whether the node can be
+// dialed by its peers, asking peers that run the AutoNAT service to dial it
+// back.
+type Reachability struct {
+	host host.Host
+	nat  autonat.AutoNAT
+}
+
+// NewReachability starts detecting the reachability of h.
+func NewReachability(ctx context.Context, h host.Host) *Reachability {
+	return &Reachability{
+		host: h,
+		nat:  autonat.NewAutoNAT(ctx, h, nil),
+	}
+}
+
+// Status returns the node's reachability as last determined, along with its
+// relay and listen addresses.
+func (r *Reachability) Status() (*ReachabilityStatus, error) {
+	if r == nil {
+		return nil, errors.New("node must be online")
+	}
+
+	status := &ReachabilityStatus{Reachability: ReachabilityUnknown}
+	switch r.nat.Status() {
+	case autonat.NATStatusPublic:
+		status.Reachability = ReachabilityPublic
+		if addr, err := r.nat.PublicAddr(); err == nil {
+			status.PublicAddr = addr.String()
+		}
+	case autonat.NATStatusPrivate:
+		status.Reachability = ReachabilityPrivate
+	}
+	status.RelayAddrs, status.ListenAddrs = splitRelayAddrs(r.host.Addrs())
+	return status, nil
+}
+
+// splitRelayAddrs separates circuit relay addresses from the other addresses.
+func splitRelayAddrs(addrs []ma.Multiaddr) (relay []string, other []string) {
+	relay, other = []string{}, []string{}
+	for _, a := range addrs {
+		if _, err := a.ValueForProtocol(circuit.P_CIRCUIT); err == nil {
+			relay = append(relay, a.String())
+		} else {
+			other = append(other, a.String())
+		}
+	}
+	return relay, other
+}
@@ -0,0 +1,50 @@
+package net
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestReachability(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("is unknown until a peer dials back", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mn, err := mocknet.WithNPeers(ctx, 1)
+		require.NoError(t, err)
+		h := mn.Hosts()[0]
+
+		status, err := NewReachability(ctx, h).Status()
+		require.NoError(t, err)
+		assert.Equal(t, ReachabilityUnknown, status.Reachability)
+		assert.Empty(t, status.PublicAddr)
+		assert.Empty(t, status.RelayAddrs)
+		assert.Len(t, status.ListenAddrs, len(h.Addrs()))
+	})
+
+	t.Run("errors when offline", func(t *testing.T) {
+		var r *Reachability
+		_, err := r.Status()
+		assert.Error(t, err)
+	})
+
+	t.Run("separates relay addresses", func(t *testing.T) {
+		direct, err := ma.NewMultiaddr("/ip4/1.2.3.4/tcp/6000")
+		require.NoError(t, err)
+		relayed, err := ma.NewMultiaddr("/ip4/5.6.7.8/tcp/6000/ipfs/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC/p2p-circuit")
+		require.NoError(t, err)
+
+		relay, other := splitRelayAddrs([]ma.Multiaddr{direct, relayed})
+		assert.Equal(t, []string{relayed.String()}, relay)
+		assert.Equal(t, []string{direct.String()}, other)
+	})
+}
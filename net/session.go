@@ -0,0 +1,49 @@
+package net
+
+import (
+	"context"
+
+	bserv "github.com/ipfs/go-blockservice"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+type sourcePeersKey struct{}
+
+type fetchSessionKey struct{}
+
+// fetchSession is the state of a single sync operation's fetching.
+type fetchSession struct {
+	// session is the bitswap session over which the operation's blocks are
+	// wanted.  Bitswap sessions send wants to the peers that served earlier
+	// blocks of the session, so related blocks are not requested from (and
+	// sent by) every connected peer.
+	session *bserv.Session
+}
+
+// WithSourcePeers returns a context recording that the data fetched with it
+// was announced by the given peers.
+func WithSourcePeers(ctx context.Context, peers ...peer.ID) context.Context {
+	return context.WithValue(ctx, sourcePeersKey{}, peers)
+}
+
+// SourcePeers returns the peers recorded in ctx by WithSourcePeers.
+func SourcePeers(ctx context.Context) []peer.ID {
+	peers, _ := ctx.Value(sourcePeersKey{}).([]peer.ID)
+	return peers
+}
+
+// WithSession returns a context under which blocks are fetched with a new
+// bitswap session scoped to the peers recorded in ctx by WithSourcePeers,
+// rather than with the fetcher's persistent session.  The session is closed
+// when ctx is done, so one should be started per sync operation.
+func (f *Fetcher) WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fetchSessionKey{}, &fetchSession{
+		session: bserv.NewSession(ctx, f.bsrv),
+	})
+}
+
+// sessionFrom returns the session started for ctx by WithSession, if any.
+func sessionFrom(ctx context.Context) *fetchSession {
+	fs, _ := ctx.Value(fetchSessionKey{}).(*fetchSession)
+	return fs
+}
@@ -0,0 +1,30 @@
+package node
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-filecoin/net"
+	"github.com/filecoin-project/go-filecoin/net/pubsub"
+	"github.com/filecoin-project/go-filecoin/plumbing/notary"
+)
+
+// processAttestation keeps an attestation received from the network if it
+// is validly signed and newer than the last one from its notary.
+func (node *Node) processAttestation(ctx context.Context, pubSubMsg pubsub.Message) error {
+	if node.PeerTracker.IsBanned(pubSubMsg.GetFrom()) {
+		return nil
+	}
+
+	att := &notary.Attestation{}
+	if err := att.Unmarshal(pubSubMsg.GetData()); err != nil {
+		node.PeerTracker.Record(pubSubMsg.GetFrom(), net.ProtocolViolation)
+		return err
+	}
+
+	// Attestations are relayed by all nodes, so stale or badly signed ones
+	// don't say anything about the peer that relayed them.
+	if err := node.Attestations.Add(att); err != nil {
+		log.Debugf("ignoring attestation by %s: %s", att.Notary, err)
+	}
+	return nil
+}
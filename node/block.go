@@ -3,17 +3,39 @@ package node
 import (
 	"context"
 
+	"github.com/ipfs/go-cid"
+	libp2ppeer "github.com/libp2p/go-libp2p-peer"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/metrics/tracing"
+	"github.com/filecoin-project/go-filecoin/net"
 	"github.com/filecoin-project/go-filecoin/net/pubsub"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
 // BlockTopic is the pubsub topic identifier on which new blocks are announced.
+// Only block headers are published on the topic, receivers fetch the full
+// blocks of the headers that pass validation.
 const BlockTopic = "/fil/blocks"
 
+var blockRejectedCt = metrics.NewInt64Counter("pubsub/block_rejected", "Number of blocks received over pubsub that failed validation and were not relayed")
+
+// validateBlockGossip returns a pubsub validator that rejects block headers v
+// finds invalid.
+func validateBlockGossip(v *consensus.BlockGossipValidator) pubsub.Validator {
+	return func(ctx context.Context, pubSubMsg pubsub.Message) error {
+		header, err := types.DecodeBlockHeader(pubSubMsg.GetData())
+		if err != nil {
+			return errors.Wrap(err, "got bad block header data")
+		}
+		return v.Validate(ctx, header)
+	}
+}
+
 // AddNewBlock receives a newly mined block and stores, validates and propagates it to the network.
 func (node *Node) AddNewBlock(ctx context.Context, b *types.Block) (err error) {
 	ctx, span := trace.StartSpan(ctx, "Node.AddNewBlock")
@@ -33,9 +55,16 @@ func (node *Node) AddNewBlock(ctx context.Context, b *types.Block) (err error) {
 		return err
 	}
 
-	// TODO: should this just be a cid? Right now receivers ask to fetch
-	// the block over bitswap anyway.
-	return node.PorcelainAPI.PubSubPublish(BlockTopic, b.ToNode().RawData())
+	// Announce the header only, receivers fetch the block from us.
+	header, err := types.NewBlockHeader(b)
+	if err != nil {
+		return errors.Wrap(err, "could not create block header")
+	}
+	data, err := header.Marshal()
+	if err != nil {
+		return errors.Wrap(err, "could not encode block header")
+	}
+	return node.PorcelainAPI.PubSubPublish(BlockTopic, data)
 }
 
 func (node *Node) processBlock(ctx context.Context, pubSubMsg pubsub.Message) (err error) {
@@ -43,23 +72,68 @@ func (node *Node) processBlock(ctx context.Context, pubSubMsg pubsub.Message) (e
 	if pubSubMsg.GetFrom() == node.Host().ID() {
 		return nil
 	}
+	if node.PeerTracker.IsBanned(pubSubMsg.GetFrom()) {
+		return nil
+	}
 
 	ctx, span := trace.StartSpan(ctx, "Node.processBlock")
 	defer tracing.AddErrorEndSpan(ctx, span, &err)
 
-	blk, err := types.DecodeBlock(pubSubMsg.GetData())
+	header, err := types.DecodeBlockHeader(pubSubMsg.GetData())
+	if err != nil {
+		node.PeerTracker.Record(pubSubMsg.GetFrom(), net.ProtocolViolation)
+		return errors.Wrap(err, "got bad block header data")
+	}
+	span.AddAttributes(trace.StringAttribute("block", header.Cid.String()))
+
+	log.Infof("Received new block header from network cid: %s", header.Cid.String())
+
+	// The header passed the topic validator, so fetch the full block and
+	// check it against the header before syncing to it.
+	ctx = net.WithSourcePeers(ctx, pubSubMsg.GetFrom())
+	blks, err := node.Fetcher.GetBlocks(ctx, []cid.Cid{header.Cid})
 	if err != nil {
-		return errors.Wrap(err, "got bad block data")
+		node.recordSyncOffense(pubSubMsg.GetFrom(), err)
+		return errors.Wrapf(err, "fetching announced block %s", header.Cid)
 	}
-	span.AddAttributes(trace.StringAttribute("block", blk.Cid().String()))
+	if err := node.blockValidator.ValidateBlock(header, blks[0]); err != nil {
+		node.PeerTracker.Record(pubSubMsg.GetFrom(), net.InvalidBlock)
+		return errors.Wrapf(err, "announced block %s is invalid", header.Cid)
+	}
+	log.Debugf("Received new block from network: %s", blks[0])
 
-	log.Infof("Received new block from network cid: %s", blk.Cid().String())
-	log.Debugf("Received new block from network: %s", blk)
+	if _, err := node.FaultDetector.Observe(ctx, header); err != nil {
+		log.Warningf("failed to check block %s for a consensus fault: %s", header.Cid, err)
+	}
 
-	err = node.Syncer.HandleNewTipset(ctx, types.NewSortedCidSet(blk.Cid()))
+	// While the node syncs, a block whose parents are not synced yet is
+	// kept until they are, rather than synced once the sync in progress
+	// ends, walking back to its parents again.
+	if node.PorcelainAPI.ChainSyncStatus().Stage != chain.SyncIdle && !node.ChainReader.HasTipSetAndState(ctx, blks[0].Parents.String()) {
+		if node.OrphanBlocks.Add(blks[0], pubSubMsg.GetFrom()) {
+			log.Debugf("keeping block %s until its parents are synced", header.Cid)
+		}
+		return nil
+	}
+
+	err = node.Syncer.HandleNewTipset(ctx, types.NewSortedCidSet(header.Cid))
 	if err != nil {
+		node.recordSyncOffense(pubSubMsg.GetFrom(), err)
 		return errors.Wrap(err, "processing block from network")
 	}
 
 	return nil
 }
+
+// recordSyncOffense records against the peer that announced a tipset the
+// offense, if any, that caused syncing to it to fail.
+func (node *Node) recordSyncOffense(p libp2ppeer.ID, err error) {
+	switch cause := errors.Cause(err); {
+	case cause == chain.ErrInvalidTipSet, cause == chain.ErrChainHasBadTipSet, cause == chain.ErrInconsistentWeight:
+		node.PeerTracker.Record(p, net.InvalidBlock)
+	default:
+		if _, ok := cause.(*net.MissingBlocksError); ok {
+			node.PeerTracker.Record(p, net.FetchTimeout)
+		}
+	}
+}
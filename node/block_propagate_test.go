@@ -15,6 +15,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
 )
 
 func connect(t *testing.T, nd1, nd2 *Node) {
@@ -135,11 +136,11 @@ func TestChainSync(t *testing.T) {
 
 type ZeroRewarder struct{}
 
-func (r *ZeroRewarder) BlockReward(ctx context.Context, st state.Tree, minerAddr address.Address) error {
+func (r *ZeroRewarder) BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address) error {
 	return nil
 }
 
-func (r *ZeroRewarder) GasReward(ctx context.Context, st state.Tree, minerAddr address.Address, msg *types.SignedMessage, cost *types.AttoFIL) error {
+func (r *ZeroRewarder) GasReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address, msg *types.SignedMessage, cost *types.AttoFIL) error {
 	return nil
 }
 
@@ -1,8 +1,17 @@
 package node
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
 	libp2p "github.com/libp2p/go-libp2p"
+	connmgr "github.com/libp2p/go-libp2p-connmgr"
 	ci "github.com/libp2p/go-libp2p-crypto"
+	pnet "github.com/libp2p/go-libp2p-pnet"
+	libp2pquic "github.com/libp2p/go-libp2p-quic-transport"
+	ma "github.com/multiformats/go-multiaddr"
 	errors "github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/repo"
@@ -17,12 +26,51 @@ func OptionsFromRepo(r repo.Repo) ([]ConfigOpt, error) {
 	}
 
 	cfg := r.Config()
+	listenAddrs := []string{cfg.Swarm.Address}
+	p2pOpts := []libp2p.Option{libp2p.Identity(sk)}
+	if cfg.Swarm.EnableQUIC {
+		quicAddr := cfg.Swarm.QUICAddress
+		if quicAddr == "" {
+			if quicAddr, err = quicAddrFor(cfg.Swarm.Address); err != nil {
+				return nil, err
+			}
+		}
+		listenAddrs = append(listenAddrs, quicAddr)
+		// Setting any transport replaces the defaults, so keep TCP.
+		p2pOpts = append(p2pOpts, libp2p.DefaultTransports, libp2p.Transport(libp2pquic.NewTransport))
+	}
+	if cfg.Swarm.NATPortMap {
+		p2pOpts = append(p2pOpts, libp2p.NATPortMap())
+	}
+	if cfg.Swarm.ConnMgrHighWater > 0 {
+		grace, err := time.ParseDuration(cfg.Swarm.ConnMgrGracePeriod)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid connection manager grace period %s", cfg.Swarm.ConnMgrGracePeriod)
+		}
+		cm := connmgr.NewConnManager(cfg.Swarm.ConnMgrLowWater, cfg.Swarm.ConnMgrHighWater, grace)
+		p2pOpts = append(p2pOpts, libp2p.ConnectionManager(cm))
+	}
+	if cfg.Swarm.PrivateNetworkKey != "" {
+		// QUIC connections don't go through the protector, so they would
+		// let foreign peers in.
+		if cfg.Swarm.EnableQUIC {
+			return nil, errors.New("QUIC cannot be enabled on a private network")
+		}
+		repoPath, err := r.Path()
+		if err != nil {
+			return nil, err
+		}
+		pnetOpt, err := privateNetworkOption(repoPath, cfg.Swarm.PrivateNetworkKey)
+		if err != nil {
+			return nil, err
+		}
+		p2pOpts = append(p2pOpts, pnetOpt)
+	}
+	p2pOpts = append(p2pOpts, libp2p.ListenAddrStrings(listenAddrs...))
+
 	cfgopts := []ConfigOpt{
 		// Libp2pOptions can only be called once, so add all options here.
-		Libp2pOptions(
-			libp2p.ListenAddrStrings(cfg.Swarm.Address),
-			libp2p.Identity(sk),
-		),
+		Libp2pOptions(p2pOpts...),
 	}
 
 	dsopt := func(c *Config) error {
@@ -41,3 +89,42 @@ func privKeyFromKeystore(r repo.Repo) (ci.PrivKey, error) {
 
 	return sk, nil
 }
+
+// privateNetworkOption returns the option restricting the host to the private
+// network of the pre-shared key at keyPath, relative to repoPath if not
+// absolute.
+func privateNetworkOption(repoPath, keyPath string) (libp2p.Option, error) {
+	if !filepath.IsAbs(keyPath) {
+		keyPath = filepath.Join(repoPath, keyPath)
+	}
+	f, err := os.Open(keyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open private network key")
+	}
+	defer f.Close() // nolint: errcheck
+
+	protector, err := pnet.NewProtector(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid private network key %s", keyPath)
+	}
+	return libp2p.PrivateNetwork(protector), nil
+}
+
+// quicAddrFor returns the QUIC multiaddr listening on the same ip and port as
+// the given tcp multiaddr, e.g. /ip4/0.0.0.0/udp/6000/quic for
+// /ip4/0.0.0.0/tcp/6000.
+func quicAddrFor(tcpAddr string) (string, error) {
+	maddr, err := ma.NewMultiaddr(tcpAddr)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid swarm address %s", tcpAddr)
+	}
+	ip, rest := ma.SplitFirst(maddr)
+	if ip == nil || rest == nil {
+		return "", errors.Errorf("swarm address %s is not a tcp address", tcpAddr)
+	}
+	port, err := rest.ValueForProtocol(ma.P_TCP)
+	if err != nil {
+		return "", errors.Wrapf(err, "swarm address %s is not a tcp address", tcpAddr)
+	}
+	return fmt.Sprintf("%s/udp/%s/quic", ip, port), nil
+}
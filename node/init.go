@@ -126,7 +126,7 @@ func newAddress(r repo.Repo) (address.Address, error) {
 		return address.Undef, errors.Wrap(err, "failed to set up wallet backend")
 	}
 
-	addr, err := backend.NewAddress()
+	addr, err := backend.NewAddress(address.SECP256K1)
 	if err != nil {
 		return address.Undef, errors.Wrap(err, "failed to create address")
 	}
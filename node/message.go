@@ -3,18 +3,112 @@ package node
 import (
 	"context"
 
+	"github.com/ipfs/go-cid"
+	libp2ppeer "github.com/libp2p/go-libp2p-peer"
+
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/net"
 	"github.com/filecoin-project/go-filecoin/net/pubsub"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+var messageRejectedCt = metrics.NewInt64Counter("pubsub/message_rejected", "Number of messages received over pubsub that failed validation and were not relayed")
+
+// validateMessageGossip returns a pubsub validator that rejects messages v
+// finds invalid.
+func validateMessageGossip(v *consensus.IngestionValidator) pubsub.Validator {
+	return func(ctx context.Context, pubSubMsg pubsub.Message) error {
+		unmarshaled := &types.SignedMessage{}
+		if err := unmarshaled.Unmarshal(pubSubMsg.GetData()); err != nil {
+			return err
+		}
+		return v.Validate(ctx, unmarshaled)
+	}
+}
+
+// restoreMessagePool loads the messages the pool persisted before the node
+// stopped.  The local ones are queued in the outbox again, so that the nonces
+// of new messages follow theirs, and republished since peers may have dropped
+// them meanwhile.
+func (node *Node) restoreMessagePool(ctx context.Context) error {
+	local, err := node.MsgPool.LoadPersisted(ctx)
+	if err != nil {
+		return err
+	}
+	height, err := node.PorcelainAPI.ChainBlockHeight()
+	if err != nil {
+		return err
+	}
+	for _, m := range local {
+		if err := node.Outbox.Enqueue(m, height.AsBigInt().Uint64()); err != nil {
+			log.Warningf("failed to queue restored message %s in the outbox: %s", m, err)
+			continue
+		}
+		c, err := m.Cid()
+		if err != nil {
+			return err
+		}
+		if node.MsgPool.IsQueued(c) {
+			continue
+		}
+		data, err := m.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := node.PorcelainAPI.PubSubPublish(msg.Topic, data); err != nil {
+			log.Warningf("failed to republish restored message %s: %s", m, err)
+		}
+	}
+	return nil
+}
+
+// syncMessagePool fetches the pending messages of peer p the message pool
+// lacks, e.g. those gossiped while the node was offline, and adds those that
+// are valid to the pool.
+func (node *Node) syncMessagePool(ctx context.Context, p libp2ppeer.ID) {
+	var have []cid.Cid
+	for _, m := range append(node.MsgPool.Pending(), node.MsgPool.Queued()...) {
+		c, err := m.Cid()
+		if err != nil {
+			log.Warningf("failed to compute cid of pending message: %s", err)
+			return
+		}
+		have = append(have, c)
+	}
+
+	msgs, err := net.RequestMessages(ctx, node.Host(), p, have)
+	if err != nil {
+		log.Debugf("failed to sync message pool with peer %s: %s", p, err)
+		return
+	}
+	added := 0
+	for _, m := range msgs {
+		if _, err := node.MsgPool.Add(ctx, m); err != nil {
+			log.Debugf("dropping message %s from peer %s: %s", m, p, err)
+			continue
+		}
+		added++
+	}
+	if added > 0 {
+		log.Infof("added %d of %d pending messages from peer %s to the message pool", added, len(msgs), p)
+	}
+}
+
 func (node *Node) processMessage(ctx context.Context, pubSubMsg pubsub.Message) (err error) {
 	ctx = log.Start(ctx, "Node.processMessage")
 	defer func() {
 		log.FinishWithErr(ctx, err)
 	}()
 
+	if node.PeerTracker.IsBanned(pubSubMsg.GetFrom()) {
+		return nil
+	}
+
 	unmarshaled := &types.SignedMessage{}
 	if err := unmarshaled.Unmarshal(pubSubMsg.GetData()); err != nil {
+		node.PeerTracker.Record(pubSubMsg.GetFrom(), net.ProtocolViolation)
 		return err
 	}
 	log.SetTag(ctx, "message", unmarshaled)
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	bserv "github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
 	"github.com/ipfs/go-hamt-ipld"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-exchange-interface"
@@ -39,6 +42,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/auth"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
@@ -50,11 +54,18 @@ import (
 	"github.com/filecoin-project/go-filecoin/net/pubsub"
 	"github.com/filecoin-project/go-filecoin/paths"
 	"github.com/filecoin-project/go-filecoin/plumbing"
+	"github.com/filecoin-project/go-filecoin/plumbing/addrbook"
 	"github.com/filecoin-project/go-filecoin/plumbing/bcf"
 	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
+	"github.com/filecoin-project/go-filecoin/plumbing/chainstats"
 	"github.com/filecoin-project/go-filecoin/plumbing/dag"
+	"github.com/filecoin-project/go-filecoin/plumbing/faults"
 	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/plumbing/notary"
+	"github.com/filecoin-project/go-filecoin/plumbing/powerevents"
 	"github.com/filecoin-project/go-filecoin/plumbing/strgdls"
+	"github.com/filecoin-project/go-filecoin/plumbing/upgrade"
+	"github.com/filecoin-project/go-filecoin/plumbing/walletnotify"
 	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
@@ -88,6 +99,7 @@ type nodeChainReader interface {
 	GetHead() types.SortedCidSet
 	GetTipSet(types.SortedCidSet) (*types.TipSet, error)
 	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+	HasTipSetAndState(ctx context.Context, tsKey string) bool
 	HeadEvents() *ps.PubSub
 	Load(context.Context) error
 	Stop()
@@ -143,10 +155,11 @@ type Node struct {
 	RetrievalMiner *retrieval.Miner
 
 	// Network Fields
-	BlockSub     pubsub.Subscription
-	MessageSub   pubsub.Subscription
-	HelloSvc     *hello.Handler
-	Bootstrapper *net.Bootstrapper
+	BlockSub       pubsub.Subscription
+	MessageSub     pubsub.Subscription
+	AttestationSub pubsub.Subscription
+	HelloSvc       *hello.Handler
+	Bootstrapper   *net.Bootstrapper
 
 	// Data Storage Fields
 
@@ -160,6 +173,9 @@ type Node struct {
 	// Fetcher is the interface for fetching data from nodes.
 	Fetcher *net.Fetcher
 
+	// blockValidator checks the blocks announced over gossip.
+	blockValidator *consensus.BlockGossipValidator
+
 	// Exchange is the interface for fetching data from other nodes.
 	Exchange exchange.Interface
 
@@ -180,6 +196,50 @@ type Node struct {
 
 	// Router is a router from IPFS
 	Router routing.IpfsRouting
+
+	// Supervisor runs the node's long-running subsystems, recovering and
+	// restarting them when they panic.
+	Supervisor *Supervisor
+
+	// PeerTracker scores peers by their misbehavior and bans the worst.
+	PeerTracker *net.PeerTracker
+
+	// PeerProtector keeps the connection manager from pruning peers while
+	// they serve a sync or a storage deal.
+	PeerProtector *net.PeerProtector
+
+	// PeerStats measures the latency to peers and their performance serving
+	// chain requests.
+	PeerStats *net.PeerStats
+
+	// SyncTargets holds the heads announced by peers, the heaviest of which
+	// is synced next.
+	SyncTargets *chain.TargetTracker
+
+	// OrphanBlocks holds the blocks received while syncing whose parents are
+	// not synced yet.
+	OrphanBlocks *chain.OrphanCache
+	orphansCh    chan interface{}
+
+	// ChainStats holds samples of the size of the chain's state.
+	ChainStats   *chainstats.Series
+	chainStatsCh chan interface{}
+
+	walletNotifyCh chan interface{}
+
+	// Notary signs attestations of the node's head, if the node is
+	// configured as a notary.
+	Notary *notary.Notary
+	// Attestations keeps the latest attestation received from each notary.
+	Attestations *notary.Book
+
+	// FaultDetector records the miners announcing two blocks at a height.
+	FaultDetector *faults.Detector
+
+	// PowerEvents sends the changes in miners' power of each new head to
+	// its subscribers.
+	PowerEvents   *powerevents.Stream
+	powerEventsCh chan interface{}
 }
 
 // Config is a helper to aid in the construction of a filecoin node.
@@ -265,6 +325,16 @@ type blankValidator struct{}
 func (blankValidator) Validate(_ string, _ []byte) error        { return nil }
 func (blankValidator) Select(_ string, _ [][]byte) (int, error) { return 0, nil }
 
+// deduplicateGossip wraps the validator of topic with the deduplication
+// configured by cfg.
+func deduplicateGossip(topic string, validate pubsub.Validator, cfg *config.GossipDedupConfig) (pubsub.Validator, error) {
+	ttl, err := time.ParseDuration(cfg.TTL)
+	if err != nil {
+		return nil, err
+	}
+	return pubsub.Deduplicate(topic, validate, cfg.CacheSize, ttl), nil
+}
+
 // readGenesisCid is a helper function that queries the provided datastore for
 // an entry with the genesisKey cid, returning if found.
 func readGenesisCid(ds datastore.Datastore) (cid.Cid, error) {
@@ -324,12 +394,16 @@ func (nc *Config) buildHost(ctx context.Context, makeDHT func(host host.Host) (r
 		}
 		return relayHost, nil
 	}
-	return libp2p.New(
-		ctx,
-		libp2p.EnableAutoRelay(),
+	opts := []libp2p.Option{
 		libp2p.Routing(makeDHTRightType),
 		libp2p.ChainOptions(nc.Libp2pOpts...),
-	)
+	}
+	// Unless disabled, a node that autoNAT finds is not dialable announces
+	// addresses on relays discovered through routing.
+	if !nc.Repo.Config().Swarm.DisableAutoRelay {
+		opts = append(opts, libp2p.EnableAutoRelay())
+	}
+	return libp2p.New(ctx, opts...)
 }
 
 // Build instantiates a filecoin Node from the settings specified in the config.
@@ -344,6 +418,7 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 
 	var peerHost host.Host
 	var router routing.IpfsRouting
+	var reachability *net.Reachability
 
 	bandwidthTracker := p2pmetrics.NewBandwidthCounter()
 	nc.Libp2pOpts = append(nc.Libp2pOpts, libp2p.BandwidthReporter(bandwidthTracker))
@@ -369,6 +444,12 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		if err != nil {
 			return nil, err
 		}
+		// Bound the upload to each peer over the protocols serving data.
+		bwManager := net.NewBandwidthManager(nc.Repo.Config().Swarm.PeerUploadLimit,
+			bsnet.ProtocolBitswap, bsnet.ProtocolBitswapOne, bsnet.ProtocolBitswapNoVers,
+			net.AncestorsProtocol, retrieval.FreeProtocol)
+		peerHost = bwManager.Host(peerHost)
+		reachability = net.NewReachability(ctx, peerHost)
 	} else {
 		router = offroute.NewOfflineRouter(nc.Repo.Datastore(), validator)
 		peerHost = rhost.Wrap(noopLibP2PHost{}, router)
@@ -382,7 +463,11 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 	//nwork := bsnet.NewFromIpfsHost(innerHost, router)
 	bswap := bitswap.New(ctx, nwork, bs)
 	bservice := bserv.New(bs, bswap)
-	fetcher := net.NewFetcher(ctx, bservice)
+	peerStats := net.NewPeerStats()
+	if err := peerStats.Persist(nc.Repo.Datastore()); err != nil {
+		return nil, errors.Wrap(err, "failed to load peer stats")
+	}
+	fetcher := net.NewChainFetcher(ctx, bservice, peerHost, peerStats)
 
 	cstOffline := hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
 	genCid, err := readGenesisCid(nc.Repo.Datastore())
@@ -390,80 +475,242 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		return nil, err
 	}
 
+	var genesis types.Block
+	if err := cstOffline.Get(ctx, genCid, &genesis); err != nil {
+		return nil, errors.Wrap(err, "failed to load genesis block")
+	}
+
 	// set up chainstore
-	chainStore := chain.NewDefaultStore(nc.Repo.ChainDatastore(), genCid)
-	powerTable := &consensus.MarketView{}
+	chainCfg := nc.Repo.Config().Chain
+	chainCache := chain.NewReadCache(chainCfg.BlockCacheSize, chainCfg.TipSetCacheSize)
+	chainStore := chain.NewDefaultStoreWithCache(nc.Repo.ChainDatastore(), genCid, chainCache)
+	// serve chains of ancestors to syncing peers
+	net.NewAncestorsService(peerHost, chainStore)
+	// tell peers with few connections about other peers
+	net.NewPeerExchangeService(peerHost)
+	powerTable := core.NewPowerTableCache(&consensus.MarketView{}, core.DefaultPowerTableCacheSize)
+
+	// activate protocol upgrades at their fork heights
+	for name, height := range chainCfg.Forks {
+		if err := consensus.ScheduleFork(name, height); err != nil {
+			return nil, errors.Wrap(err, "failed to schedule fork")
+		}
+	}
 
 	// set up processor
 	var processor consensus.Processor
 	if nc.Rewarder == nil {
-		processor = consensus.NewDefaultProcessor()
+		processor = consensus.NewBlockProcessor()
 	} else {
-		processor = consensus.NewConfiguredProcessor(consensus.NewDefaultMessageValidator(), nc.Rewarder)
+		processor = consensus.NewConfiguredProcessor(consensus.NewBlockMessageValidator(), nc.Rewarder)
 	}
 
 	// set up consensus
+	var verifier proofs.Verifier = &proofs.RustVerifier{}
+	if nc.Verifier != nil {
+		verifier = nc.Verifier
+	}
 	var nodeConsensus consensus.Protocol
-	if nc.Verifier == nil {
-		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, powerTable, genCid, &proofs.RustVerifier{})
+	if producer := nc.Repo.Config().Mining.ScheduledProducer; !producer.Empty() {
+		// The scheduled producer mines every round under proof of authority
+		// consensus, without an election, so it is only allowed on
+		// development networks.
+		if network := nc.Repo.Config().Net; !strings.HasPrefix(network, "devnet") {
+			return nil, errors.Errorf("mining.scheduledProducer can only be set on a devnet, not on network %q", network)
+		}
+		nodeConsensus = consensus.NewAuthority(&cstOffline, bs, processor, genCid, producer)
 	} else {
-		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, powerTable, genCid, nc.Verifier)
+		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, powerTable, genCid, verifier)
 	}
 
 	chainFacade := bcf.NewBlockChainFacade(chainStore, &cstOffline)
 
 	// only the syncer gets the storage which is online connected
 	chainSyncer := chain.NewDefaultSyncer(&cstOffline, nodeConsensus, chainStore, fetcher)
-	msgPool := core.NewMessagePool(chainStore, nc.Repo.Config().Mpool, consensus.NewIngestionValidator(chainFacade, nc.Repo.Config().Mpool))
+	syncTargets := chain.NewTargetTracker()
+	if err := syncTargets.Resume(nc.Repo.ChainDatastore()); err != nil {
+		return nil, errors.Wrap(err, "failed to resume sync")
+	}
+	if cp := nc.Repo.Config().Chain.Checkpoint; cp != nil {
+		chainSyncer.SetCheckpoint(&chain.Checkpoint{TipSet: cp.TipSet, StateRoot: cp.StateRoot})
+	}
+	if depth := nc.Repo.Config().Chain.MaxReorgDepth; depth != 0 {
+		chainSyncer.SetMaxReorgDepth(depth)
+	}
+	maxFutureDrift := consensus.DefaultMaxFutureDrift
+	if driftStr := nc.Repo.Config().Chain.MaxFutureDrift; driftStr != "" {
+		if maxFutureDrift, err = time.ParseDuration(driftStr); err != nil {
+			return nil, errors.Wrapf(err, "couldn't parse chain.maxFutureDrift %s", driftStr)
+		}
+	}
+	chainSyncer.SetTimestampRules(&consensus.TimestampRules{
+		GenesisTime:    uint64(genesis.Timestamp),
+		BlockTime:      nc.BlockTime,
+		MaxFutureDrift: maxFutureDrift,
+	})
+	ingestionValidator := consensus.NewIngestionValidator(chainFacade, nc.Repo.Config().Mpool)
+	msgPool := core.NewMessagePool(chainStore, nc.Repo.Config().Mpool, ingestionValidator)
+	msgPool.Persist(nc.Repo.Datastore(), nc.Repo.Config().Mpool.PersistAll)
+	// serve pending messages to peers that just connected
+	net.NewMessagePoolSyncService(peerHost, msgPool)
 	outbox := core.NewMessageQueue()
 
 	// Set up libp2p pubsub
+	pubsubCfg := nc.Repo.Config().Pubsub
+	seenMessagesTTL, err := time.ParseDuration(pubsubCfg.SeenMessagesTTL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid pubsub.seenMessagesTTL %s", pubsubCfg.SeenMessagesTTL)
+	}
+	// The seen messages cache is configured globally by libp2p, for all
+	// instances created after it is set.
+	libp2pps.TimeCacheDuration = seenMessagesTTL
 	fsub, err := libp2pps.NewFloodSub(ctx, peerHost)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to set up pubsub")
 	}
+	publishMessages := func(msgs []*types.SignedMessage, when string) {
+		for _, m := range msgs {
+			data, err := m.Marshal()
+			if err == nil {
+				err = fsub.Publish(msg.Topic, data)
+			}
+			if err != nil {
+				log.Warningf("failed to publish message %s %s: %s", m, when, err)
+			}
+		}
+	}
+	// Messages queued behind a nonce gap are published once it fills.
+	msgPool.OnPromote(func(msgs []*types.SignedMessage) {
+		publishMessages(msgs, "after its nonce gap filled")
+	})
+	// Messages of blocks dropped by a reorg are published again, since peers
+	// dropped them from their pools when the blocks were mined.
+	msgPool.OnReinject(func(msgs []*types.SignedMessage) {
+		publishMessages(msgs, "after a reorg")
+	})
 	backend, err := wallet.NewDSBackend(nc.Repo.WalletDatastore())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to set up wallet backend")
 	}
-	fcWallet := wallet.New(backend)
+	backends := []wallet.Backend{backend}
+	if nc.Repo.Config().Wallet.Ledger {
+		ledgerDS := namespace.Wrap(nc.Repo.WalletDatastore(), wallet.LedgerPrefix)
+		ledgerBackend, err := wallet.NewLedgerBackend(ledgerDS, wallet.OpenLedger)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to set up Ledger wallet backend")
+		}
+		backends = append(backends, ledgerBackend)
+	}
+	fcWallet := wallet.New(backends...)
+
+	// Score peers by their misbehavior, disconnecting and refusing those
+	// that are banned.
+	peerTracker := net.NewPeerTracker(func(p libp2ppeer.ID) {
+		peerHost.Network().ClosePeer(p) // nolint: errcheck
+	})
+	peerTracker.RefuseBanned(peerHost.Network())
+	net.RecordPeers(peerHost.Network())
+	peerStats.ReportTo(peerTracker)
+
+	chainStats := chainstats.NewSeries(nc.Repo.Datastore(), nc.Repo.Config().Observability.ChainStats.MaxSamples)
+
+	var headNotary *notary.Notary
+	if notaryAddr := nc.Repo.Config().Chain.NotaryAddress; !notaryAddr.Empty() {
+		if !fcWallet.HasAddress(notaryAddr) {
+			return nil, errors.Errorf("chain.notaryAddress %s is not in the wallet", notaryAddr)
+		}
+		headNotary = notary.NewNotary(notaryAddr, fcWallet, chainStore)
+	}
+	attestations := notary.NewBook()
+	faultDetector := faults.NewDetector(nc.Repo.Datastore())
+	powerEvents := powerevents.NewStream(chainStore, &cstOffline, bs, powerTable)
+	msgWaiter := msg.NewWaiter(chainStore, bs, &cstOffline)
+	// The message index records receipts so that finding a message doesn't
+	// run its tipset again.
+	chainStore.SetMessageReceipts(msgWaiter.TipSetReceipts)
 
 	PorcelainAPI := porcelain.New(plumbing.New(&plumbing.APIDeps{
+		AddrBook:     addrbook.New(nc.Repo.Datastore()),
+		Attestations: attestations,
 		Bitswap:      bswap,
 		Chain:        chainFacade,
+		ChainStats:   chainStats,
 		Config:       cfg.NewConfig(nc.Repo),
 		DAG:          dag.NewDAG(merkledag.NewDAGService(bservice)),
 		Deals:        strgdls.New(nc.Repo.DealsDatastore()),
+		Faults:       faultDetector,
+		Fetcher:      fetcher,
 		MsgPool:      msgPool,
 		MsgPreviewer: msg.NewPreviewer(fcWallet, chainStore, &cstOffline, bs),
 		MsgQueryer:   msg.NewQueryer(nc.Repo, fcWallet, chainStore, &cstOffline, bs),
+		MsgReplayer:  msg.NewReplayer(chainStore, &cstOffline, bs),
 		MsgSender:    msg.NewSender(fcWallet, chainStore, &cstOffline, chainStore, outbox, msgPool, consensus.NewOutboundMessageValidator(), fsub.Publish),
-		MsgWaiter:    msg.NewWaiter(chainStore, bs, &cstOffline),
-		Network:      net.New(peerHost, pubsub.NewPublisher(fsub), pubsub.NewSubscriber(fsub), net.NewRouter(router), bandwidthTracker, net.NewPinger(peerHost, pingService)),
+		MsgWaiter:    msgWaiter,
+		Network:      net.New(peerHost, pubsub.NewPublisher(fsub), pubsub.NewSubscriber(fsub), net.NewRouter(router), bandwidthTracker, net.NewPinger(peerHost, pingService), peerTracker, reachability),
+		Notary:       headNotary,
 		Outbox:       outbox,
+		PeerStats:    peerStats,
+		PowerEvents:  powerEvents,
+		State:        msg.NewStateComputer(chainStore, bs),
+		Syncer:       chainSyncer,
+		SyncTargets:  syncTargets,
+		Tokens:       auth.NewTokenStore(nc.Repo.Datastore()),
+		Upgrades:     upgrade.NewDryRunner(chainStore, &cstOffline, bs),
 		Wallet:       fcWallet,
 	}))
 
+	// Validate gossiped blocks and messages so that invalid ones are not
+	// relayed to the rest of the network.
+	// Validation results are reused for content received again within the
+	// configured window.
+	blockValidator := consensus.NewBlockGossipValidator(PorcelainAPI, maxFutureDrift)
+	blockGossipValidator, err := deduplicateGossip(BlockTopic, validateBlockGossip(blockValidator), pubsubCfg.Blocks)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid pubsub.blocks")
+	}
+	if err := pubsub.RegisterTopicValidator(fsub, BlockTopic, blockGossipValidator, blockRejectedCt); err != nil {
+		return nil, errors.Wrap(err, "failed to register block validator")
+	}
+	messageGossipValidator, err := deduplicateGossip(msg.Topic, validateMessageGossip(ingestionValidator), pubsubCfg.Messages)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid pubsub.messages")
+	}
+	if err := pubsub.RegisterTopicValidator(fsub, msg.Topic, messageGossipValidator, messageRejectedCt); err != nil {
+		return nil, errors.Wrap(err, "failed to register message validator")
+	}
+
 	nd := &Node{
-		blockservice: bservice,
-		Blockstore:   bs,
-		cborStore:    &cstOffline,
-		Consensus:    nodeConsensus,
-		ChainReader:  chainStore,
-		Syncer:       chainSyncer,
-		PowerTable:   powerTable,
-		PorcelainAPI: PorcelainAPI,
-		Fetcher:      fetcher,
-		Exchange:     bswap,
-		host:         peerHost,
-		MsgPool:      msgPool,
-		Outbox:       outbox,
-		OfflineMode:  nc.OfflineMode,
-		PeerHost:     peerHost,
-		Repo:         nc.Repo,
-		Wallet:       fcWallet,
-		blockTime:    nc.BlockTime,
-		Router:       router,
+		blockservice:   bservice,
+		Blockstore:     bs,
+		cborStore:      &cstOffline,
+		Consensus:      nodeConsensus,
+		ChainReader:    chainStore,
+		Syncer:         chainSyncer,
+		PowerTable:     powerTable,
+		PorcelainAPI:   PorcelainAPI,
+		Fetcher:        fetcher,
+		blockValidator: blockValidator,
+		Exchange:       bswap,
+		host:           peerHost,
+		MsgPool:        msgPool,
+		Outbox:         outbox,
+		OfflineMode:    nc.OfflineMode,
+		PeerHost:       peerHost,
+		Repo:           nc.Repo,
+		Wallet:         fcWallet,
+		blockTime:      nc.BlockTime,
+		Router:         router,
+		Supervisor:     NewSupervisor(),
+		PeerTracker:    peerTracker,
+		PeerProtector:  net.NewPeerProtector(peerHost.ConnManager()),
+		PeerStats:      peerStats,
+		ChainStats:     chainStats,
+		Notary:         headNotary,
+		Attestations:   attestations,
+		FaultDetector:  faultDetector,
+		PowerEvents:    powerEvents,
+		SyncTargets:    syncTargets,
+		OrphanBlocks:   chain.NewOrphanCache(chain.DefaultOrphanCacheSize),
 	}
 
 	// Bootstrapping network peers.
@@ -482,6 +729,16 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 	minPeerThreshold := nd.Repo.Config().Bootstrap.MinPeerThreshold
 	nd.Bootstrapper = net.NewBootstrapper(bpi, nd.Host(), nd.Host().Network(), nd.Router, minPeerThreshold, period)
 
+	// Previously seen peers are redialed and used when the bootstrap peers
+	// are unreachable.
+	nd.Bootstrapper.KnownPeers = net.NewKnownPeers(nc.Repo.Datastore(), nd.Host().Peerstore(), net.DefaultMaxKnownPeers)
+	nd.Bootstrapper.MaxPeerThreshold = nd.Repo.Config().Bootstrap.MaxPeerThreshold
+	if redialStr := nd.Repo.Config().Bootstrap.RedialPeriod; redialStr != "" {
+		if nd.Bootstrapper.RedialPeriod, err = time.ParseDuration(redialStr); err != nil {
+			return nil, errors.Wrapf(err, "couldn't parse bootstrap redial period %s", redialStr)
+		}
+	}
+
 	return nd, nil
 }
 
@@ -509,20 +766,30 @@ func (node *Node) Start(ctx context.Context) error {
 	}
 
 	// Start up 'hello' handshake service
-	syncCallBack := func(pid libp2ppeer.ID, cids []cid.Cid, height uint64) {
-		cidSet := types.NewSortedCidSet(cids...)
-		err := node.Syncer.HandleNewTipset(context.Background(), cidSet)
-		if err != nil {
-			log.Infof("error handling blocks: %s", cidSet.String())
+	syncCallBack := func(pid libp2ppeer.ID, cids []cid.Cid, height uint64, parentWeight uint64) {
+		// The peer is on our network, remember it for later bootstraps.
+		if err := node.Bootstrapper.KnownPeers.Add(pid); err != nil {
+			log.Warningf("failed to record known peer %s: %s", pid, err)
 		}
+		node.SyncTargets.Add(pid, types.NewSortedCidSet(cids...), height, parentWeight)
+		// Fetch the messages gossiped while we were not connected to the peer.
+		go node.syncMessagePool(context.Background(), pid)
+	}
+	node.HelloSvc = hello.New(node.Host(), node.ChainReader.GenesisCid(), syncCallBack, node.PorcelainAPI.ChainHead, node.PeerStats, node.Repo.Config().Net, flags.Commit)
+	node.HelloSvc.Advertise(hello.FeatureAncestors, hello.FeaturePeerExchange, hello.FeatureMessagePoolSync)
+	if node.Repo.Config().Swarm.PrivateNetworkKey != "" {
+		node.HelloSvc.RefuseForeignPeers(func(p libp2ppeer.ID) {
+			node.PeerTracker.Record(p, net.ForeignNetwork)
+		})
 	}
-	node.HelloSvc = hello.New(node.Host(), node.ChainReader.GenesisCid(), syncCallBack, node.PorcelainAPI.ChainHead, node.Repo.Config().Net, flags.Commit)
 
 	err = node.setupProtocols()
 	if err != nil {
 		return errors.Wrap(err, "failed to set up protocols:")
 	}
-	node.RetrievalMiner = retrieval.NewMiner(node)
+	node.RetrievalMiner = retrieval.NewMiner(node, func() bool {
+		return node.StorageMiner != nil && node.StorageMiner.IsSealing()
+	})
 
 	// subscribe to block notifications
 	blkSub, err := node.PorcelainAPI.PubSubSubscribe(BlockTopic)
@@ -538,13 +805,34 @@ func (node *Node) Start(ctx context.Context) error {
 	}
 	node.MessageSub = msgSub
 
+	if err := node.restoreMessagePool(ctx); err != nil {
+		return errors.Wrap(err, "failed to restore message pool")
+	}
+
 	cctx, cancel := context.WithCancel(context.Background())
 	node.cancelSubscriptionsCtx = cancel
 
-	go node.handleSubscription(cctx, node.processBlock, "processBlock", node.BlockSub, "BlockSub")
-	go node.handleSubscription(cctx, node.processMessage, "processMessage", node.MessageSub, "MessageSub")
+	node.Supervisor.Go(cctx, "block subscription", func(ctx context.Context) {
+		node.handleSubscription(ctx, node.processBlock, "processBlock", node.BlockSub, "BlockSub")
+	})
+	node.Supervisor.Go(cctx, "message subscription", func(ctx context.Context) {
+		node.handleSubscription(ctx, node.processMessage, "processMessage", node.MessageSub, "MessageSub")
+	})
 
-	outboxPolicy := core.NewMessageQueuePolicy(node.Outbox, node.ChainReader, core.OutboxMaxAgeRounds)
+	// subscribe to notaries' attestations
+	attestationSub, err := node.PorcelainAPI.PubSubSubscribe(notary.Topic)
+	if err != nil {
+		return errors.Wrap(err, "failed to subscribe to attestations topic")
+	}
+	node.AttestationSub = attestationSub
+	node.Supervisor.Go(cctx, "attestation subscription", func(ctx context.Context) {
+		node.handleSubscription(ctx, node.processAttestation, "processAttestation", node.AttestationSub, "AttestationSub")
+	})
+
+	// Keep the outbox's margin over the message pool's timeout when the
+	// latter is configured.
+	outboxMaxAge := core.OutboxMaxAgeRounds + node.MsgPool.MessageTimeOut() - core.MessageTimeOut
+	outboxPolicy := core.NewMessageQueuePolicy(node.Outbox, node.ChainReader, outboxMaxAge)
 
 	node.HeaviestTipSetHandled = func() {}
 	node.HeaviestTipSetCh = node.ChainReader.HeadEvents().Sub(chain.NewHeadTopic)
@@ -552,7 +840,20 @@ func (node *Node) Start(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get chain head")
 	}
-	go node.handleNewHeaviestTipSet(cctx, *head, outboxPolicy)
+	node.Supervisor.Go(cctx, "heaviest tipset handler", func(ctx context.Context) {
+		// The head seen last is lost in a panic, restart from the current one.
+		if head == nil {
+			current, err := node.PorcelainAPI.ChainHead()
+			if err != nil {
+				log.Errorf("failed to get chain head: %s", err)
+				return
+			}
+			head = current
+		}
+		start := *head
+		head = nil
+		node.handleNewHeaviestTipSet(ctx, start, outboxPolicy)
+	})
 
 	if !node.OfflineMode {
 		node.Bootstrapper.Start(context.Background())
@@ -562,9 +863,234 @@ func (node *Node) Start(ctx context.Context) error {
 		return errors.Wrap(err, "failed to start heartbeat services")
 	}
 
+	node.Supervisor.Go(cctx, "sync targets", node.syncTargets)
+	node.setupChainStatsSampler(cctx)
+	node.setupPowerEvents(cctx)
+	node.setupOrphanBlocks(cctx)
+
+	if err := node.setupWalletNotifier(cctx); err != nil {
+		return errors.Wrap(err, "failed to start wallet notifier")
+	}
+
+	if err := node.setupNotary(cctx); err != nil {
+		return errors.Wrap(err, "failed to start notary")
+	}
+
+	return nil
+}
+
+// setupChainStatsSampler starts sampling the chain's state as new heads
+// arrive, if sampling is enabled.
+func (node *Node) setupChainStatsSampler(ctx context.Context) {
+	interval := node.Repo.Config().Observability.ChainStats.SampleInterval
+	if interval == 0 {
+		return
+	}
+
+	sampler := chainstats.NewSampler(node.ChainReader, node.cborStore, node.Blockstore, node.ChainStats, interval)
+	node.chainStatsCh = node.ChainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	node.Supervisor.Go(ctx, "chain stats sampler", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case head, ok := <-node.chainStatsCh:
+				if !ok {
+					return
+				}
+				ts, ok := head.(types.TipSet)
+				if !ok {
+					log.Errorf("non-tipset published on head channel")
+					continue
+				}
+				if err := sampler.HandleNewHead(ctx, ts); err != nil {
+					log.Warningf("failed to sample chain stats: %s", err)
+				}
+			}
+		}
+	})
+}
+
+// setupPowerEvents starts diffing the power table of new heads for the
+// subscribers to power changes.
+func (node *Node) setupPowerEvents(ctx context.Context) {
+	node.powerEventsCh = node.ChainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	node.Supervisor.Go(ctx, "power events", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case head, ok := <-node.powerEventsCh:
+				if !ok {
+					return
+				}
+				ts, ok := head.(types.TipSet)
+				if !ok {
+					log.Errorf("non-tipset published on head channel")
+					continue
+				}
+				if err := node.PowerEvents.HandleNewHead(ctx, ts); err != nil {
+					log.Warningf("failed to compute power changes: %s", err)
+				}
+			}
+		}
+	})
+}
+
+// setupOrphanBlocks starts syncing the orphan blocks whose parents become the
+// head.  Orphans are taken from the cache as head events arrive and synced by
+// another goroutine, since syncing waits for the sync in progress, which
+// must not wait for head events to be received.
+func (node *Node) setupOrphanBlocks(ctx context.Context) {
+	orphans := make(chan *chain.Orphan, chain.DefaultOrphanCacheSize)
+	node.orphansCh = node.ChainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	node.Supervisor.Go(ctx, "orphan blocks", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case head, ok := <-node.orphansCh:
+				if !ok {
+					return
+				}
+				ts, ok := head.(types.TipSet)
+				if !ok {
+					log.Errorf("non-tipset published on head channel")
+					continue
+				}
+				for _, o := range node.OrphanBlocks.Take(ts.ToSortedCidSet()) {
+					select {
+					case orphans <- o:
+					default:
+						log.Debugf("dropping orphan block %s, too many orphans to sync", o.Block.Cid())
+					}
+				}
+			}
+		}
+	})
+	node.Supervisor.Go(ctx, "orphan block syncer", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case o := <-orphans:
+				blkCtx := net.WithSourcePeers(ctx, o.From)
+				if err := node.Syncer.HandleNewTipset(blkCtx, types.NewSortedCidSet(o.Block.Cid())); err != nil {
+					log.Infof("failed to sync orphan block %s: %s", o.Block.Cid(), err)
+					node.recordSyncOffense(o.From, err)
+				}
+			}
+		}
+	})
+}
+
+// setupWalletNotifier starts notifying the configured webhook and journal
+// of the messages mined to or from the wallet's addresses, if any are
+// configured.
+func (node *Node) setupWalletNotifier(ctx context.Context) error {
+	cfg := node.Repo.Config().Wallet
+	var sinks []walletnotify.Sink
+	if cfg.NotifyWebhook != "" {
+		sinks = append(sinks, walletnotify.NewWebhookSink(cfg.NotifyWebhook))
+	}
+	if cfg.NotifyJournal != "" {
+		journalPath := cfg.NotifyJournal
+		if !filepath.IsAbs(journalPath) {
+			repoPath, err := node.Repo.Path()
+			if err != nil {
+				return err
+			}
+			journalPath = filepath.Join(repoPath, journalPath)
+		}
+		sinks = append(sinks, walletnotify.NewJournalSink(journalPath))
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	var eventTypes []walletnotify.EventType
+	for _, name := range cfg.NotifyEvents {
+		t, err := walletnotify.ParseEventType(name)
+		if err != nil {
+			return err
+		}
+		eventTypes = append(eventTypes, t)
+	}
+
+	notifier := walletnotify.NewNotifier(node.ChainReader, node.Wallet, eventTypes, sinks...)
+	node.walletNotifyCh = node.ChainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	node.Supervisor.Go(ctx, "wallet notifier", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case head, ok := <-node.walletNotifyCh:
+				if !ok {
+					return
+				}
+				ts, ok := head.(types.TipSet)
+				if !ok {
+					log.Errorf("non-tipset published on head channel")
+					continue
+				}
+				if err := notifier.HandleNewHead(ctx, ts); err != nil {
+					log.Warningf("failed to notify wallet events: %s", err)
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// setupNotary starts attesting the node's head periodically and publishing
+// the attestations, if the node is configured as a notary.
+func (node *Node) setupNotary(ctx context.Context) error {
+	if node.Notary == nil {
+		return nil
+	}
+	period := node.GetBlockTime()
+	if periodStr := node.Repo.Config().Chain.NotaryPeriod; periodStr != "" {
+		var err error
+		period, err = time.ParseDuration(periodStr)
+		if err != nil {
+			return errors.Wrapf(err, "invalid chain.notaryPeriod %s", periodStr)
+		}
+	}
+
+	node.Supervisor.Go(ctx, "notary", func(ctx context.Context) {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := node.attestHead(ctx); err != nil {
+					log.Warningf("failed to attest head: %s", err)
+				}
+			}
+		}
+	})
 	return nil
 }
 
+// attestHead signs an attestation of the current head and publishes it.
+func (node *Node) attestHead(ctx context.Context) error {
+	head, err := node.PorcelainAPI.ChainHead()
+	if err != nil {
+		return err
+	}
+	att, err := node.Notary.Attest(ctx, *head)
+	if err != nil {
+		return err
+	}
+	data, err := att.Marshal()
+	if err != nil {
+		return err
+	}
+	return node.PorcelainAPI.PubSubPublish(notary.Topic, data)
+}
+
 func (node *Node) setupHeartbeatServices(ctx context.Context) error {
 	mag := func() address.Address {
 		addr, err := node.miningAddress()
@@ -615,9 +1141,6 @@ func (node *Node) setIsMining(isMining bool) {
 }
 
 func (node *Node) handleNewMiningOutput(miningOutCh <-chan mining.Output) {
-	defer func() {
-		node.miningDoneWg.Done()
-	}()
 	for {
 		select {
 		case <-node.miningCtx.Done():
@@ -697,6 +1220,18 @@ func (node *Node) cancelSubscriptions() {
 // Stop initiates the shutdown of the node.
 func (node *Node) Stop(ctx context.Context) {
 	node.ChainReader.HeadEvents().Unsub(node.HeaviestTipSetCh)
+	if node.chainStatsCh != nil {
+		node.ChainReader.HeadEvents().Unsub(node.chainStatsCh)
+	}
+	if node.walletNotifyCh != nil {
+		node.ChainReader.HeadEvents().Unsub(node.walletNotifyCh)
+	}
+	if node.powerEventsCh != nil {
+		node.ChainReader.HeadEvents().Unsub(node.powerEventsCh)
+	}
+	if node.orphansCh != nil {
+		node.ChainReader.HeadEvents().Unsub(node.orphansCh)
+	}
 	node.StopMining(ctx)
 
 	node.cancelSubscriptions()
@@ -722,6 +1257,53 @@ func (node *Node) Stop(ctx context.Context) {
 	fmt.Println("stopping filecoin :(")
 }
 
+// syncTargets syncs the heaviest of the heads announced by peers, selecting
+// the next one each time a sync ends, until ctx is done.
+func (node *Node) syncTargets(ctx context.Context) {
+	for {
+		head, err := node.PorcelainAPI.ChainHead()
+		if err != nil {
+			log.Errorf("failed to get chain head: %s", err)
+			return
+		}
+		weight, err := head.ParentWeight()
+		if err != nil {
+			log.Errorf("failed to get weight of chain head: %s", err)
+			return
+		}
+
+		target, ok := node.SyncTargets.Select(weight)
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-node.SyncTargets.Ready():
+			}
+			continue
+		}
+		node.syncTarget(ctx, target)
+	}
+}
+
+// syncTarget syncs the chain with head target from the peers that announced
+// it, penalizing them if the chain is invalid.
+func (node *Node) syncTarget(ctx context.Context, target chain.SyncTarget) {
+	ctx = chain.WithClaimedWeight(net.WithSourcePeers(ctx, target.Peers...), target.ParentWeight)
+	for _, p := range target.Peers {
+		release := node.PeerProtector.Protect(p, net.SyncTag)
+		defer release()
+	}
+
+	err := node.Syncer.HandleNewTipset(ctx, target.TipSet)
+	node.SyncTargets.Done(target, err)
+	if err != nil {
+		log.Infof("error syncing chain with head %s: %s", target.TipSet.String(), err)
+		for _, p := range target.Peers {
+			node.recordSyncOffense(p, err)
+		}
+	}
+}
+
 type newBlockFunc func(context.Context, *types.Block)
 
 func (node *Node) addNewlyMinedBlock(ctx context.Context, b *types.Block) {
@@ -743,11 +1325,20 @@ func (node *Node) miningAddress() (address.Address, error) {
 }
 
 // MiningTimes returns the configured time it takes to mine a block, and also
-// the mining delay duration, which is currently a fixed fraction of block time.
+// the mining delay duration.  The mining delay is the configured propagation
+// delay if set, otherwise a fixed fraction of block time.
 // Note this is mocked behavior, in production this time is determined by how
 // long it takes to generate PoSTs.
 func (node *Node) MiningTimes() (time.Duration, time.Duration) {
 	mineDelay := node.GetBlockTime() / mining.MineDelayConversionFactor
+	if delayStr := node.Repo.Config().Mining.PropagationDelay; delayStr != "" {
+		delay, err := time.ParseDuration(delayStr)
+		if err != nil {
+			log.Warningf("invalid mining propagation delay %q, using %s: %s", delayStr, mineDelay, err)
+		} else {
+			mineDelay = delay
+		}
+	}
 	return node.GetBlockTime(), mineDelay
 }
 
@@ -804,7 +1395,12 @@ func (node *Node) StartMining(ctx context.Context) error {
 		node.miningDoneWg = doneWg
 		node.AddNewlyMinedBlock = node.addNewlyMinedBlock
 		node.miningDoneWg.Add(1)
-		go node.handleNewMiningOutput(outCh)
+		go func() {
+			defer node.miningDoneWg.Done()
+			node.Supervisor.Run(node.miningCtx, "mining output handler", func(ctx context.Context) {
+				node.handleNewMiningOutput(outCh)
+			})
+		}()
 	}
 
 	// initialize a storage miner
@@ -816,7 +1412,7 @@ func (node *Node) StartMining(ctx context.Context) error {
 
 	// loop, turning sealing-results into commitSector messages to be included
 	// in the chain
-	go func() {
+	node.Supervisor.Go(node.miningCtx, "sector commitment handler", func(ctx context.Context) {
 		for {
 			select {
 			case result := <-node.SectorBuilder().SectorSealResults():
@@ -852,18 +1448,18 @@ func (node *Node) StartMining(ctx context.Context) error {
 
 					node.StorageMiner.OnCommitmentSent(val, msgCid, nil)
 				}
-			case <-node.miningCtx.Done():
+			case <-ctx.Done():
 				return
 			}
 		}
-	}()
+	})
 
 	// schedules sealing of staged piece-data
 	if node.Repo.Config().Mining.AutoSealIntervalSeconds > 0 {
-		go func() {
+		node.Supervisor.Go(node.miningCtx, "auto-sealer", func(ctx context.Context) {
 			for {
 				select {
-				case <-node.miningCtx.Done():
+				case <-ctx.Done():
 					return
 				case <-time.After(time.Duration(node.Repo.Config().Mining.AutoSealIntervalSeconds) * time.Second):
 					log.Info("auto-seal has been triggered")
@@ -873,7 +1469,7 @@ func (node *Node) StartMining(ctx context.Context) error {
 					}
 				}
 			}
-		}()
+		})
 	} else {
 		log.Debug("auto-seal is disabled")
 	}
@@ -972,9 +1568,10 @@ func (node *Node) StopMining(ctx context.Context) {
 	// TODO: stop node.StorageMiner
 }
 
-// NewAddress creates a new account address on the default wallet backend.
+// NewAddress creates a new secp256k1 account address on the default wallet
+// backend.
 func (node *Node) NewAddress() (address.Address, error) {
-	return wallet.NewAddress(node.Wallet)
+	return wallet.NewAddress(node.Wallet, address.SECP256K1)
 }
 
 // miningOwnerAddress returns the owner of miningAddr.
@@ -987,6 +1584,18 @@ func (node *Node) miningOwnerAddress(ctx context.Context, miningAddr address.Add
 	return ownerAddr, nil
 }
 
+// ProtectPeer keeps the connection to p from being pruned while it serves the
+// task tagged tag, until the returned function is called.
+func (node *Node) ProtectPeer(p libp2ppeer.ID, tag string) func() {
+	return node.PeerProtector.Protect(p, tag)
+}
+
+// Supervise runs the named task in a new goroutine under the node's
+// supervisor.
+func (node *Node) Supervise(name string, run func(context.Context)) {
+	node.Supervisor.Go(context.Background(), name, run)
+}
+
 func (node *Node) handleSubscription(ctx context.Context, f pubSubProcessorFunc, fname string, s pubsub.Subscription, sname string) {
 	for {
 		pubSubMsg, err := s.Next(ctx)
@@ -1018,12 +1627,12 @@ func (node *Node) setupProtocols() error {
 	node.BlockMiningAPI = &blockMiningAPI
 
 	// set up retrieval client and api
-	retapi := retrieval.NewAPI(retrieval.NewClient(node.host, node.blockTime, node.PorcelainAPI))
+	retapi := retrieval.NewAPI(retrieval.NewClient(node.host, node.blockTime, node.PorcelainAPI), func() *retrieval.Miner { return node.RetrievalMiner })
 	node.RetrievalAPI = &retapi
 
 	// set up storage client and api
 	smc := storage.NewClient(node.blockTime, node.host, node.PorcelainAPI)
-	smcAPI := storage.NewAPI(smc)
+	smcAPI := storage.NewAPI(smc, func() *storage.Miner { return node.StorageMiner })
 	node.StorageAPI = &smcAPI
 	return nil
 }
@@ -1048,10 +1657,17 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (mining.Worker, error)
 		log.Errorf("could not get owner address of miner actor")
 		return nil, err
 	}
-	return mining.NewDefaultWorker(
+	worker := mining.NewDefaultWorker(
 		node.MsgPool, node.getStateTree, node.getWeight, node.getAncestors, processor, node.PowerTable,
 		node.Blockstore, node.CborStore(), minerAddr, minerOwnerAddr, minerPubKey,
-		node.Wallet, node.blockTime), nil
+		node.Wallet, node.blockTime)
+	worker.SetScheduledProducer(node.Repo.Config().Mining.ScheduledProducer)
+	if limit := node.Repo.Config().Mining.BlockGasLimit; limit != 0 {
+		if err := worker.SetBlockGasLimit(types.NewGasUnits(limit)); err != nil {
+			return nil, errors.Wrap(err, "invalid mining.blockGasLimit")
+		}
+	}
+	return worker, nil
 }
 
 // getStateFromKey returns the state tree based on tipset fetched with provided key tsKey
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
@@ -21,6 +22,7 @@ import (
 
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/libp2p/go-libp2p-peerstore"
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -122,6 +124,67 @@ func TestConnectsToBootstrapNodes(t *testing.T) {
 	})
 }
 
+func TestNodeQUICTransport(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	newQUICNode := func(t *testing.T) *node.Node {
+		r := repo.NewInMemoryRepo()
+		r.Config().Swarm.Address = "/ip4/127.0.0.1/tcp/0"
+		r.Config().Swarm.EnableQUIC = true
+
+		require.NoError(t, node.Init(ctx, r, consensus.DefaultGenesis))
+		opts, err := node.OptionsFromRepo(r)
+		require.NoError(t, err)
+		nd, err := node.New(ctx, opts...)
+		require.NoError(t, err)
+		require.NoError(t, nd.Start(ctx))
+		return nd
+	}
+
+	nd1 := newQUICNode(t)
+	defer nd1.Stop(ctx)
+	nd2 := newQUICNode(t)
+	defer nd2.Stop(ctx)
+
+	var quicAddrs []ma.Multiaddr
+	for _, a := range nd1.Host().Addrs() {
+		if _, err := a.ValueForProtocol(ma.P_QUIC); err == nil {
+			quicAddrs = append(quicAddrs, a)
+		}
+	}
+	require.NotEmpty(t, quicAddrs, "node listens on QUIC")
+
+	err := nd2.Host().Connect(ctx, peerstore.PeerInfo{ID: nd1.Host().ID(), Addrs: quicAddrs})
+	require.NoError(t, err)
+	conns := nd2.Host().Network().ConnsToPeer(nd1.Host().ID())
+	require.Len(t, conns, 1)
+	_, err = conns[0].RemoteMultiaddr().ValueForProtocol(ma.P_QUIC)
+	assert.NoError(t, err)
+}
+
+func TestNodeScheduledProducerRequiresDevnet(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	r := repo.NewInMemoryRepo()
+	r.Config().Mining.ScheduledProducer = address.NewForTestGetter()()
+	require.NoError(t, node.Init(ctx, r, consensus.DefaultGenesis))
+
+	opts, err := node.OptionsFromRepo(r)
+	require.NoError(t, err)
+	opts = append(opts, node.OfflineMode(true))
+
+	r.Config().Net = "testnet"
+	_, err = node.New(ctx, opts...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can only be set on a devnet")
+
+	r.Config().Net = "devnet-user"
+	_, err = node.New(ctx, opts...)
+	assert.NoError(t, err)
+}
+
 func TestNodeInit(t *testing.T) {
 	tf.UnitTest(t)
 
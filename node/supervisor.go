@@ -0,0 +1,123 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRestartBackoff is the delay before a subsystem that panicked
+	// is first restarted.  The delay doubles with each consecutive panic.
+	defaultRestartBackoff = time.Second
+	// defaultMaxRestartBackoff bounds the delay before restarting a
+	// subsystem.  A subsystem that runs for this long without panicking
+	// starts over from the initial delay.
+	defaultMaxRestartBackoff = time.Minute
+	// maxPanicJournalEntries is the number of panics remembered.
+	maxPanicJournalEntries = 100
+)
+
+// PanicRecord describes a panic recovered in a supervised subsystem.
+type PanicRecord struct {
+	Subsystem string
+	Time      time.Time
+	Panic     string
+	Stack     string
+	// Restarts is the number of consecutive times the subsystem has
+	// panicked, including this one.
+	Restarts int
+}
+
+// Supervisor runs the node's long-running subsystems so that a panic in one
+// of them is recovered and journaled, and the subsystem restarted after a
+// backoff, instead of taking down the whole daemon.
+type Supervisor struct {
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu      sync.Mutex
+	journal []PanicRecord
+}
+
+// NewSupervisor returns a Supervisor with the default restart backoff.
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		backoff:    defaultRestartBackoff,
+		maxBackoff: defaultMaxRestartBackoff,
+	}
+}
+
+// Go runs the named subsystem in a new goroutine under supervision.
+func (s *Supervisor) Go(ctx context.Context, name string, run func(context.Context)) {
+	go s.Run(ctx, name, run)
+}
+
+// Run runs the named subsystem until it returns without panicking or ctx is
+// done, restarting it with backoff each time it panics.
+func (s *Supervisor) Run(ctx context.Context, name string, run func(context.Context)) {
+	backoff := s.backoff
+	restarts := 0
+	for {
+		start := time.Now()
+		recovered, stack := runRecovered(ctx, run)
+		if recovered == nil {
+			return
+		}
+
+		if time.Since(start) >= s.maxBackoff {
+			backoff = s.backoff
+			restarts = 0
+		}
+		restarts++
+		s.record(PanicRecord{
+			Subsystem: name,
+			Time:      time.Now(),
+			Panic:     fmt.Sprint(recovered),
+			Stack:     stack,
+			Restarts:  restarts,
+		})
+		log.Errorf("subsystem %s panicked, restarting in %s: %v\n%s", name, backoff, recovered, stack)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// Journal returns the panics recovered by the supervisor, oldest first.
+func (s *Supervisor) Journal() []PanicRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]PanicRecord{}, s.journal...)
+}
+
+func (s *Supervisor) record(rec PanicRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.journal = append(s.journal, rec)
+	if len(s.journal) > maxPanicJournalEntries {
+		s.journal = s.journal[len(s.journal)-maxPanicJournalEntries:]
+	}
+}
+
+// runRecovered calls run, returning the value it panicked with, if any, and
+// the stack at the time of the panic.
+func runRecovered(ctx context.Context, run func(context.Context)) (recovered interface{}, stack string) {
+	defer func() {
+		if recovered = recover(); recovered != nil {
+			stack = string(debug.Stack())
+		}
+	}()
+	run(ctx)
+	return nil, ""
+}
@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestSupervisor(t *testing.T) {
+	tf.UnitTest(t)
+
+	newSupervisor := func() *Supervisor {
+		return &Supervisor{backoff: time.Millisecond, maxBackoff: 10 * time.Millisecond}
+	}
+
+	t.Run("restarts a subsystem that panics", func(t *testing.T) {
+		s := newSupervisor()
+		runs := 0
+		s.Run(context.Background(), "flaky", func(context.Context) {
+			runs++
+			if runs < 3 {
+				panic("boom")
+			}
+		})
+
+		assert.Equal(t, 3, runs)
+		journal := s.Journal()
+		require.Len(t, journal, 2)
+		assert.Equal(t, "flaky", journal[0].Subsystem)
+		assert.Equal(t, "boom", journal[0].Panic)
+		assert.NotEmpty(t, journal[0].Stack)
+		assert.Equal(t, 1, journal[0].Restarts)
+		assert.Equal(t, 2, journal[1].Restarts)
+	})
+
+	t.Run("does not restart a subsystem that returns", func(t *testing.T) {
+		s := newSupervisor()
+		runs := 0
+		s.Run(context.Background(), "steady", func(context.Context) { runs++ })
+
+		assert.Equal(t, 1, runs)
+		assert.Empty(t, s.Journal())
+	})
+
+	t.Run("stops restarting once the context is done", func(t *testing.T) {
+		s := newSupervisor()
+		ctx, cancel := context.WithCancel(context.Background())
+		runs := 0
+		s.Run(ctx, "doomed", func(context.Context) {
+			runs++
+			cancel()
+			panic("boom")
+		})
+
+		assert.Equal(t, 1, runs)
+		assert.Len(t, s.Journal(), 1)
+	})
+}
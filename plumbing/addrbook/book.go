@@ -0,0 +1,142 @@
+package addrbook
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+func init() {
+	cbor.RegisterCborType(Entry{})
+}
+
+// Prefix is the datastore prefix for address book entries.
+const Prefix = "addrbook"
+
+// ErrEntryExists is returned when adding an entry under a name already in use.
+var ErrEntryExists = errors.New("address book already has an entry with this name")
+
+// ErrEntryNotFound is returned when no entry has the requested name.
+var ErrEntryNotFound = errors.New("address book has no entry with this name")
+
+// Entry is a named address in the address book.
+type Entry struct {
+	Name    string          `json:"name"`
+	Address address.Address `json:"address"`
+	// Type describes the counterparty, e.g. "miner" or "client".
+	Type  string `json:"type,omitempty"`
+	Notes string `json:"notes,omitempty"`
+}
+
+// Book is a persisted mapping of names to the addresses of frequently used
+// counterparties.
+type Book struct {
+	ds repo.Datastore
+}
+
+// New returns a new Book persisted to ds.
+func New(ds repo.Datastore) *Book {
+	return &Book{ds: ds}
+}
+
+// Add adds an entry to the book.  It fails if the name is in use.
+func (b *Book) Add(entry *Entry) error {
+	if err := ValidateName(entry.Name); err != nil {
+		return err
+	}
+	if entry.Address.Empty() {
+		return errors.New("address book entries must have an address")
+	}
+
+	key := entryKey(entry.Name)
+	has, err := b.ds.Has(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to read address book")
+	}
+	if has {
+		return errors.Wrap(ErrEntryExists, entry.Name)
+	}
+
+	datum, err := cbor.DumpObject(entry)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal address book entry")
+	}
+	if err := b.ds.Put(key, datum); err != nil {
+		return errors.Wrap(err, "could not save address book entry")
+	}
+	return nil
+}
+
+// Remove removes the entry with the given name from the book.
+func (b *Book) Remove(name string) error {
+	key := entryKey(name)
+	has, err := b.ds.Has(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to read address book")
+	}
+	if !has {
+		return errors.Wrap(ErrEntryNotFound, name)
+	}
+	return b.ds.Delete(key)
+}
+
+// Get returns the entry with the given name.
+func (b *Book) Get(name string) (*Entry, error) {
+	datum, err := b.ds.Get(entryKey(name))
+	if err == datastore.ErrNotFound {
+		return nil, errors.Wrap(ErrEntryNotFound, name)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read address book")
+	}
+
+	var entry Entry
+	if err := cbor.DecodeInto(datum, &entry); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal address book entry")
+	}
+	return &entry, nil
+}
+
+// Ls returns all entries of the book, ordered by name.
+func (b *Book) Ls() ([]*Entry, error) {
+	results, err := b.ds.Query(query.Query{Prefix: "/" + Prefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query address book")
+	}
+
+	var entries []*Entry
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, errors.Wrap(result.Error, "failed to query address book")
+		}
+		var entry Entry
+		if err := cbor.DecodeInto(result.Value, &entry); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal address book entry")
+		}
+		entries = append(entries, &entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// ValidateName returns an error if name cannot be used for an entry.
+func ValidateName(name string) error {
+	if name == "" {
+		return errors.New("address book names must not be empty")
+	}
+	if strings.ContainsAny(name, "/@ \t\n") {
+		return errors.Errorf("address book name %q must not contain '/', '@' or whitespace", name)
+	}
+	return nil
+}
+
+func entryKey(name string) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{Prefix, name})
+}
@@ -0,0 +1,57 @@
+package addrbook_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/addrbook"
+	"github.com/filecoin-project/go-filecoin/repo"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestAddressBook(t *testing.T) {
+	tf.UnitTest(t)
+
+	addressMaker := address.NewForTestGetter()
+	book := addrbook.New(repo.NewInMemoryRepo().Datastore())
+
+	alice := &addrbook.Entry{Name: "alice", Address: addressMaker(), Type: "client", Notes: "pays on time"}
+	bob := &addrbook.Entry{Name: "bob", Address: addressMaker(), Type: "miner"}
+	require.NoError(t, book.Add(bob))
+	require.NoError(t, book.Add(alice))
+
+	t.Run("gets entries by name", func(t *testing.T) {
+		got, err := book.Get("alice")
+		require.NoError(t, err)
+		assert.Equal(t, alice, got)
+
+		_, err = book.Get("carol")
+		assert.Equal(t, addrbook.ErrEntryNotFound, errors.Cause(err))
+	})
+
+	t.Run("lists entries by name", func(t *testing.T) {
+		entries, err := book.Ls()
+		require.NoError(t, err)
+		assert.Equal(t, []*addrbook.Entry{alice, bob}, entries)
+	})
+
+	t.Run("rejects duplicate and invalid entries", func(t *testing.T) {
+		err := book.Add(&addrbook.Entry{Name: "alice", Address: addressMaker()})
+		assert.Equal(t, addrbook.ErrEntryExists, errors.Cause(err))
+
+		assert.Error(t, book.Add(&addrbook.Entry{Name: "@carol", Address: addressMaker()}))
+		assert.Error(t, book.Add(&addrbook.Entry{Name: "carol"}))
+	})
+
+	t.Run("removes entries", func(t *testing.T) {
+		require.NoError(t, book.Remove("bob"))
+		_, err := book.Get("bob")
+		assert.Error(t, err)
+
+		assert.Equal(t, addrbook.ErrEntryNotFound, errors.Cause(book.Remove("bob")))
+	})
+}
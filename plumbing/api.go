@@ -15,22 +15,32 @@ import (
 	"github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/auth"
 	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/net"
 	"github.com/filecoin-project/go-filecoin/net/pubsub"
+	"github.com/filecoin-project/go-filecoin/plumbing/addrbook"
 	"github.com/filecoin-project/go-filecoin/plumbing/bcf"
 	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
+	"github.com/filecoin-project/go-filecoin/plumbing/chainstats"
 	"github.com/filecoin-project/go-filecoin/plumbing/dag"
+	"github.com/filecoin-project/go-filecoin/plumbing/faults"
 	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/plumbing/notary"
+	"github.com/filecoin-project/go-filecoin/plumbing/powerevents"
 	"github.com/filecoin-project/go-filecoin/plumbing/strgdls"
+	"github.com/filecoin-project/go-filecoin/plumbing/upgrade"
 	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
 	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
@@ -42,35 +52,63 @@ import (
 type API struct {
 	logger logging.EventLogger
 
+	addrBook     *addrbook.Book
+	attestations *notary.Book
 	bitswap      exchange.Interface
 	chain        *bcf.BlockChainFacade
+	chainStats   *chainstats.Series
 	config       *cfg.Config
 	dag          *dag.DAG
+	faults       *faults.Detector
+	fetcher      *net.Fetcher
 	msgPool      *core.MessagePool
 	msgPreviewer *msg.Previewer
 	msgQueryer   *msg.Queryer
+	msgReplayer  *msg.Replayer
 	outbox       *core.MessageQueue
 	msgSender    *msg.Sender
 	msgWaiter    *msg.Waiter
 	network      *net.Network
+	notary       *notary.Notary
+	peerStats    *net.PeerStats
+	powerEvents  *powerevents.Stream
+	state        *msg.StateComputer
 	storagedeals *strgdls.Store
+	syncer       *chain.DefaultSyncer
+	syncTargets  *chain.TargetTracker
+	tokens       *auth.TokenStore
+	upgrades     *upgrade.DryRunner
 	wallet       *wallet.Wallet
 }
 
 // APIDeps contains all the API's dependencies
 type APIDeps struct {
+	AddrBook     *addrbook.Book
+	Attestations *notary.Book
 	Bitswap      exchange.Interface
 	Chain        *bcf.BlockChainFacade
+	ChainStats   *chainstats.Series
 	Config       *cfg.Config
 	DAG          *dag.DAG
 	Deals        *strgdls.Store
+	Faults       *faults.Detector
+	Fetcher      *net.Fetcher
 	MsgPool      *core.MessagePool
 	MsgPreviewer *msg.Previewer
 	MsgQueryer   *msg.Queryer
+	MsgReplayer  *msg.Replayer
 	MsgSender    *msg.Sender
 	MsgWaiter    *msg.Waiter
 	Network      *net.Network
+	Notary       *notary.Notary
 	Outbox       *core.MessageQueue
+	PeerStats    *net.PeerStats
+	PowerEvents  *powerevents.Stream
+	State        *msg.StateComputer
+	Syncer       *chain.DefaultSyncer
+	SyncTargets  *chain.TargetTracker
+	Tokens       *auth.TokenStore
+	Upgrades     *upgrade.DryRunner
 	Wallet       *wallet.Wallet
 }
 
@@ -79,18 +117,32 @@ func New(deps *APIDeps) *API {
 	return &API{
 		logger: logging.Logger("porcelain"),
 
+		addrBook:     deps.AddrBook,
+		attestations: deps.Attestations,
 		bitswap:      deps.Bitswap,
 		chain:        deps.Chain,
+		chainStats:   deps.ChainStats,
 		config:       deps.Config,
 		dag:          deps.DAG,
+		faults:       deps.Faults,
+		fetcher:      deps.Fetcher,
 		msgPool:      deps.MsgPool,
 		msgPreviewer: deps.MsgPreviewer,
 		msgQueryer:   deps.MsgQueryer,
+		msgReplayer:  deps.MsgReplayer,
 		msgSender:    deps.MsgSender,
 		msgWaiter:    deps.MsgWaiter,
 		network:      deps.Network,
+		notary:       deps.Notary,
 		outbox:       deps.Outbox,
+		peerStats:    deps.PeerStats,
+		powerEvents:  deps.PowerEvents,
+		state:        deps.State,
 		storagedeals: deps.Deals,
+		syncer:       deps.Syncer,
+		syncTargets:  deps.SyncTargets,
+		tokens:       deps.Tokens,
+		upgrades:     deps.Upgrades,
 		wallet:       deps.Wallet,
 	}
 }
@@ -100,6 +152,12 @@ func (api *API) ActorGet(ctx context.Context, addr address.Address) (*actor.Acto
 	return api.chain.GetActor(ctx, addr)
 }
 
+// ActorGetAt returns an actor from the state of the tipset with key tsKey, or
+// of the head if tsKey is empty.
+func (api *API) ActorGetAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error) {
+	return api.chain.GetActorAt(ctx, tsKey, addr)
+}
+
 // ActorGetSignature returns the signature of the given actor's given method.
 // The function signature is typically used to enable a caller to decode the
 // output of an actor method call (message).
@@ -107,11 +165,59 @@ func (api *API) ActorGetSignature(ctx context.Context, actorAddr address.Address
 	return api.chain.GetActorSignature(ctx, actorAddr, method)
 }
 
+// ActorDump returns an actor along with its decoded storage in the state of
+// the tipset with key tsKey, or of the head if tsKey is empty.
+func (api *API) ActorDump(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*state.ActorDump, error) {
+	return api.chain.DumpActor(ctx, tsKey, addr)
+}
+
 // ActorLs returns a channel with actors from the latest state on the chain
 func (api *API) ActorLs(ctx context.Context) (<-chan state.GetAllActorsResult, error) {
 	return api.chain.LsActors(ctx)
 }
 
+// AuthTokenCreate creates an API token granting perm, returning the token
+// and its description.
+func (api *API) AuthTokenCreate(name string, perm auth.Permission) (string, *auth.Token, error) {
+	return api.tokens.Create(name, perm)
+}
+
+// AuthTokenLs returns the descriptions of the API tokens, oldest first.
+func (api *API) AuthTokenLs() ([]*auth.Token, error) {
+	return api.tokens.Ls()
+}
+
+// AuthTokenRevoke revokes the API token with the given id.
+func (api *API) AuthTokenRevoke(id string) error {
+	return api.tokens.Revoke(id)
+}
+
+// AuthTokenVerify returns the description of an API token, or
+// auth.ErrInvalidToken if it isn't valid.
+func (api *API) AuthTokenVerify(token string) (*auth.Token, error) {
+	return api.tokens.Verify(token)
+}
+
+// AddrBookAdd adds an entry to the address book.
+func (api *API) AddrBookAdd(entry *addrbook.Entry) error {
+	return api.addrBook.Add(entry)
+}
+
+// AddrBookRemove removes the entry with the given name from the address book.
+func (api *API) AddrBookRemove(name string) error {
+	return api.addrBook.Remove(name)
+}
+
+// AddrBookGet returns the address book entry with the given name.
+func (api *API) AddrBookGet(name string) (*addrbook.Entry, error) {
+	return api.addrBook.Get(name)
+}
+
+// AddrBookLs returns all entries of the address book.
+func (api *API) AddrBookLs() ([]*addrbook.Entry, error) {
+	return api.addrBook.Ls()
+}
+
 // ConfigSet sets the given parameters at the given path in the local config.
 // The given path may be either a single field name, or a dotted path to a field.
 // The JSON value may be either a single value or a whole data structure to be replace.
@@ -133,16 +239,128 @@ func (api *API) ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, er
 	return api.chain.GetBlock(ctx, id)
 }
 
+// ChainGetTipSet returns the tipset with key tsKey.
+func (api *API) ChainGetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error) {
+	return api.chain.GetTipSet(tsKey)
+}
+
+// ChainGetTipSetAtHeight returns the tipset of the current chain at height,
+// or the one before it if there is none at height because of null blocks.
+func (api *API) ChainGetTipSetAtHeight(ctx context.Context, height uint64) (*types.TipSet, error) {
+	return api.chain.GetTipSetAtHeight(ctx, height)
+}
+
 // ChainHead returns the head tipset
 func (api *API) ChainHead() (*types.TipSet, error) {
 	return api.chain.Head()
 }
 
+// ChainSubscribeHead returns a channel receiving the head tipset each time it
+// changes, until ctx is done.  Heads a slow subscriber misses are skipped.
+func (api *API) ChainSubscribeHead(ctx context.Context) <-chan types.TipSet {
+	return api.chain.SubscribeHead(ctx)
+}
+
 // ChainLs returns an iterator of tipsets from head to genesis
 func (api *API) ChainLs(ctx context.Context) (*chain.TipsetIterator, error) {
 	return api.chain.Ls(ctx)
 }
 
+// ChainFetchProgress returns the progress of fetching blocks from the network.
+func (api *API) ChainFetchProgress() net.FetchProgress {
+	return api.fetcher.Progress()
+}
+
+// ChainSubscribeFetchProgress returns a channel receiving the progress of
+// fetching blocks from the network whenever it changes, until ctx is done.
+func (api *API) ChainSubscribeFetchProgress(ctx context.Context) <-chan net.FetchProgress {
+	return api.fetcher.SubscribeProgress(ctx)
+}
+
+// ChainForceSync syncs the chain with head tsKey, switching to it if it is
+// heavier even if it forked from the head's chain deeper than the reorg depth
+// limit.
+func (api *API) ChainForceSync(ctx context.Context, tsKey types.SortedCidSet) error {
+	return api.syncer.HandleNewTipset(chain.WithoutReorgLimit(ctx), tsKey)
+}
+
+// ChainSyncCandidates returns the heads announced by peers that the node may
+// sync, heaviest first.
+func (api *API) ChainSyncCandidates() []chain.SyncTarget {
+	return api.syncTargets.Candidates()
+}
+
+// ChainSyncStatus returns the status of the chain sync in progress, or of the
+// last one if the node is not syncing.
+func (api *API) ChainSyncStatus() chain.SyncStatus {
+	return api.syncer.Status()
+}
+
+// ChainSubscribeSyncStatus returns a channel receiving the status of syncing
+// the chain whenever it changes, until ctx is done.
+func (api *API) ChainSubscribeSyncStatus(ctx context.Context) <-chan chain.SyncStatus {
+	return api.syncer.SubscribeStatus(ctx)
+}
+
+// ChainUpgradeDryRun rehearses the named protocol upgrade against the tipsets
+// of the heaviest chain between fromHeight and toHeight, without modifying the
+// chain, and reports messages whose outcome would change along with timings.
+// A toHeight of zero means the head.
+func (api *API) ChainUpgradeDryRun(ctx context.Context, name string, fromHeight, toHeight uint64) (*consensus.UpgradeDryRunReport, error) {
+	return api.upgrades.Run(ctx, name, fromHeight, toHeight)
+}
+
+// ChainAttestation returns the latest attestation of the node's head, if the
+// node is a notary.
+func (api *API) ChainAttestation() (*notary.Attestation, error) {
+	if api.notary == nil {
+		return nil, errors.New("node is not a notary, set chain.notaryAddress to make it one")
+	}
+	att := api.notary.Latest()
+	if att == nil {
+		return nil, errors.New("no attestation signed yet")
+	}
+	return att, nil
+}
+
+// ChainAttestations returns the latest attestation received from each
+// notary on the network.
+func (api *API) ChainAttestations() []*notary.Attestation {
+	return api.attestations.List()
+}
+
+// ChainFaults returns the consensus faults detected in the blocks received
+// from the network, ordered by height.
+func (api *API) ChainFaults() ([]*faults.Fault, error) {
+	return api.faults.Faults()
+}
+
+// ChainPowerChanges returns the changes in miners' power between the tipset
+// with key tsKey and its parent.
+func (api *API) ChainPowerChanges(ctx context.Context, tsKey types.SortedCidSet) ([]*powerevents.Change, error) {
+	return api.powerEvents.Changes(ctx, tsKey)
+}
+
+// ChainSubscribePowerChanges returns a channel receiving the changes in
+// miners' power of each new head, until ctx is done.
+func (api *API) ChainSubscribePowerChanges(ctx context.Context) <-chan *powerevents.Change {
+	return api.powerEvents.Subscribe(ctx)
+}
+
+// ChainReplayTipSet re-executes the messages of a tipset that is on chain
+// against the state of its parent, reporting the receipt and gas usage of each
+// message, the state root computed and how it differs from the recorded one.
+func (api *API) ChainReplayTipSet(ctx context.Context, tsKey types.SortedCidSet) (*consensus.TipSetReplay, error) {
+	return api.msgReplayer.ReplayTipSet(ctx, tsKey)
+}
+
+// ChainStatsSamples returns the samples of the chain's state size and message
+// volume taken at heights from fromHeight to toHeight.  A toHeight of zero
+// means no upper bound.
+func (api *API) ChainStatsSamples(fromHeight, toHeight uint64) ([]*chainstats.Sample, error) {
+	return api.chainStats.Range(fromHeight, toHeight)
+}
+
 // ChainSampleRandomness produces a slice of random bytes sampled from a TipSet
 // in the blockchain at a given height, useful for things like PoSt challenge seed
 // generation.
@@ -180,11 +398,45 @@ func (api *API) MessagePoolPending() []*types.SignedMessage {
 	return api.msgPool.Pending()
 }
 
+// MessagePoolQueued lists messages in the pool waiting for messages with
+// lower nonces from their senders
+func (api *API) MessagePoolQueued() []*types.SignedMessage {
+	return api.msgPool.Queued()
+}
+
+// MessagePoolSelect lists the messages in the pool, queued ones included, that
+// pass the filter.
+func (api *API) MessagePoolSelect(filter core.MessagePoolFilter) []*types.SignedMessage {
+	return api.msgPool.Select(filter)
+}
+
+// MessagePoolStats describes the messages in the pool that pass the filter.
+func (api *API) MessagePoolStats(filter core.MessagePoolFilter) (core.MessagePoolStats, error) {
+	return api.msgPool.Stats(filter)
+}
+
 // MessagePoolGet fetches a message from the pool.
 func (api *API) MessagePoolGet(cid cid.Cid) (value *types.SignedMessage, ok bool) {
 	return api.msgPool.Get(cid)
 }
 
+// MessagePoolPendingSpend returns the most the pending messages from an address can cost it
+func (api *API) MessagePoolPendingSpend(addr address.Address) *types.AttoFIL {
+	return api.msgPool.PendingSpend(addr)
+}
+
+// MessagePoolNextNonce returns the nonce of the next message from addr, taking
+// the messages from addr pending in the message pool into account.
+func (api *API) MessagePoolNextNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	return api.msgPool.NextNonce(ctx, addr)
+}
+
+// MessagePoolSubscribe returns a channel receiving an event for every message
+// added to or removed from the message pool, until ctx is done.
+func (api *API) MessagePoolSubscribe(ctx context.Context) <-chan core.MessagePoolEvent {
+	return api.msgPool.Subscribe(ctx)
+}
+
 // MessagePoolRemove removes a message from the message pool.
 func (api *API) MessagePoolRemove(cid cid.Cid) {
 	api.msgPool.Remove(cid)
@@ -203,6 +455,12 @@ func (api *API) MessageQuery(ctx context.Context, optFrom, to address.Address, m
 	return api.msgQueryer.Query(ctx, optFrom, to, method, params...)
 }
 
+// MessageQueryAt calls an actor's method like MessageQuery, against the state
+// of the tipset with key tsKey, or of the head if tsKey is empty.
+func (api *API) MessageQueryAt(ctx context.Context, tsKey types.SortedCidSet, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error) {
+	return api.msgQueryer.QueryAt(ctx, tsKey, optFrom, to, method, params...)
+}
+
 // MessageSend sends a message. It uses the default from address if none is given and signs the
 // message using the wallet. This call "sends" in the sense that it enqueues the
 // message in the msg pool and broadcasts it to the network; it does not wait for the
@@ -212,11 +470,61 @@ func (api *API) MessageSend(ctx context.Context, from, to address.Address, value
 	return api.msgSender.Send(ctx, from, to, value, gasPrice, gasLimit, method, params...)
 }
 
+// MessageReplace sends again a pending message with a higher gas price so
+// that it replaces the original.  See msg.Sender.Replace.
+func (api *API) MessageReplace(ctx context.Context, c cid.Cid, gasPrice types.AttoFIL, gasLimit types.GasUnits) (cid.Cid, error) {
+	return api.msgSender.Replace(ctx, c, gasPrice, gasLimit)
+}
+
+// MessageSign signs mmsg with the key of its sender in the wallet, e.g. on an
+// offline node holding the keys of a cold wallet.
+func (api *API) MessageSign(mmsg *types.MeteredMessage) (*types.SignedMessage, error) {
+	return types.NewSignedMessage(mmsg.Message, api.wallet, mmsg.GasPrice, mmsg.GasLimit)
+}
+
+// MessageSendSigned sends a message signed elsewhere, e.g. by MessageSign on
+// an offline node.  See msg.Sender.SendSigned.
+func (api *API) MessageSendSigned(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	return api.msgSender.SendSigned(ctx, smsg)
+}
+
 // MessageFind returns a message and receipt from the blockchain, if it exists.
 func (api *API) MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error) {
 	return api.msgWaiter.Find(ctx, msgCid)
 }
 
+// MessageHistory returns the messages on chain addr sent or received, newest
+// first, skipping the offset newest and returning at most limit of them, or
+// all if limit is zero.
+func (api *API) MessageHistory(ctx context.Context, addr address.Address, offset, limit uint) ([]*msg.HistoryEntry, error) {
+	return api.msgWaiter.History(ctx, addr, offset, limit)
+}
+
+// MessageReceipt returns the execution receipt of a message that is on chain.
+func (api *API) MessageReceipt(ctx context.Context, msgCid cid.Cid) (*types.MessageReceipt, error) {
+	return api.msgWaiter.Receipt(ctx, msgCid)
+}
+
+// MessageReplay re-executes a message that is on chain against the state it
+// was originally applied to, reporting its gas usage, internal sends and state
+// changes.
+func (api *API) MessageReplay(ctx context.Context, msgCid cid.Cid) (*consensus.MessageReplay, error) {
+	return api.msgReplayer.Replay(ctx, msgCid)
+}
+
+// MessageTrace re-executes a message that is on chain and returns the tree of
+// sends it made, with the method, value, exit code and gas used of each.
+func (api *API) MessageTrace(ctx context.Context, msgCid cid.Cid) (*vm.SendTrace, error) {
+	replay, err := api.msgReplayer.Replay(ctx, msgCid)
+	if err != nil {
+		return nil, err
+	}
+	if replay.Trace == nil {
+		return nil, errors.Errorf("message %s was not executed: %s", msgCid, replay.ApplyError)
+	}
+	return replay.Trace, nil
+}
+
 // MessageWait invokes the callback when a message with the given cid appears on chain.
 // It will find the message in both the case that it is already on chain and
 // the case that it appears in a newly mined block. An error is returned if one is
@@ -241,6 +549,16 @@ func (api *API) NetworkGetBandwidthStats() metrics.Stats {
 	return api.network.GetBandwidthStats()
 }
 
+// NetworkGetBandwidthStatsByPeer gets stats on the bandwidth used with each connected peer
+func (api *API) NetworkGetBandwidthStatsByPeer() map[string]metrics.Stats {
+	return api.network.GetBandwidthStatsByPeer()
+}
+
+// NetworkGetBandwidthStatsByProtocol gets stats on the bandwidth used by each protocol
+func (api *API) NetworkGetBandwidthStatsByProtocol() map[string]metrics.Stats {
+	return api.network.GetBandwidthStatsByProtocol()
+}
+
 // NetworkGetPeerAddresses gets the current addresses of the node
 func (api *API) NetworkGetPeerAddresses() []ma.Multiaddr {
 	return api.network.GetPeerAddresses()
@@ -251,6 +569,11 @@ func (api *API) NetworkGetPeerID() peer.ID {
 	return api.network.GetPeerID()
 }
 
+// NetworkGetReachability reports whether peers can dial the node directly or only through relays
+func (api *API) NetworkGetReachability() (*net.ReachabilityStatus, error) {
+	return api.network.GetReachability()
+}
+
 // NetworkFindProvidersAsync issues a findProviders query to the filecoin network content router.
 func (api *API) NetworkFindProvidersAsync(ctx context.Context, key cid.Cid, count int) <-chan pstore.PeerInfo {
 	return api.network.Router.FindProvidersAsync(ctx, key, count)
@@ -281,11 +604,35 @@ func (api *API) NetworkPeers(ctx context.Context, verbose, latency, streams bool
 	return api.network.Peers(ctx, verbose, latency, streams)
 }
 
+// NetworkPeerSyncStats lists the sync stats of every peer chain requests
+// were made to, including before the node last restarted
+func (api *API) NetworkPeerSyncStats() []net.PeerStat {
+	return api.peerStats.Stats()
+}
+
+// NetworkPeerScores lists the scores of peers with recent offenses or bans
+func (api *API) NetworkPeerScores() []net.PeerScore {
+	return api.network.PeerScores()
+}
+
+// NetworkUnbanPeer lifts the ban on the given peer
+func (api *API) NetworkUnbanPeer(p peer.ID) error {
+	return api.network.Unban(p)
+}
+
 // SignBytes uses private key information associated with the given address to sign the given bytes.
 func (api *API) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
 	return api.wallet.SignBytes(data, addr)
 }
 
+// StateCompute applies messages on top of the state of the tipset with key
+// tsKey, or of the head if empty, in a sandbox, returning the resulting state
+// root and each message's receipt and trace.  The chain's state is left
+// unmodified.
+func (api *API) StateCompute(ctx context.Context, tsKey types.SortedCidSet, messages []*types.SignedMessage) (*msg.StateComputation, error) {
+	return api.state.Compute(ctx, tsKey, messages)
+}
+
 // WalletAddresses gets addresses from the wallet
 func (api *API) WalletAddresses() []address.Address {
 	return api.wallet.Addresses()
@@ -301,9 +648,48 @@ func (api *API) WalletGetPubKeyForAddress(addr address.Address) ([]byte, error)
 	return api.wallet.GetPubKeyForAddress(addr)
 }
 
-// WalletNewAddress generates a new wallet address
-func (api *API) WalletNewAddress() (address.Address, error) {
-	return wallet.NewAddress(api.wallet)
+// WalletNewAddress generates a new wallet address using protocol
+func (api *API) WalletNewAddress(protocol address.Protocol) (address.Address, error) {
+	return wallet.NewAddress(api.wallet, protocol)
+}
+
+// WalletNewSeed sets the seed of the wallet to a new one and returns its
+// mnemonic
+func (api *API) WalletNewSeed() (string, error) {
+	return wallet.NewSeed(api.wallet)
+}
+
+// WalletMnemonic returns the mnemonic of the seed of the wallet
+func (api *API) WalletMnemonic() (string, error) {
+	return wallet.Mnemonic(api.wallet)
+}
+
+// WalletRestoreSeed sets the seed of the wallet to the one of mnemonic and
+// restores its first count addresses
+func (api *API) WalletRestoreSeed(mnemonic string, count uint) ([]address.Address, error) {
+	return wallet.RestoreSeed(api.wallet, mnemonic, count)
+}
+
+// WalletNewLedgerAddress adds the address of the index-th key of the Ledger
+// device to the wallet
+func (api *API) WalletNewLedgerAddress(index uint32, confirm bool) (address.Address, error) {
+	return wallet.NewLedgerAddress(api.wallet, index, confirm)
+}
+
+// WalletEncrypt encrypts the keys of the wallet with passphrase and locks it
+func (api *API) WalletEncrypt(passphrase string) error {
+	return wallet.Encrypt(api.wallet, passphrase)
+}
+
+// WalletUnlock unlocks the wallet with passphrase for timeout, or until it is
+// locked if timeout is zero
+func (api *API) WalletUnlock(passphrase string, timeout time.Duration) error {
+	return wallet.Unlock(api.wallet, passphrase, timeout)
+}
+
+// WalletLock locks the wallet
+func (api *API) WalletLock() error {
+	return wallet.Lock(api.wallet)
 }
 
 // WalletImport adds a given set of KeyInfos to the wallet
@@ -316,6 +702,17 @@ func (api *API) WalletExport(addrs []address.Address) ([]*types.KeyInfo, error)
 	return api.wallet.Export(addrs)
 }
 
+// WalletExportKeyFile returns the keys of the given wallet addresses in the
+// versioned key file format
+func (api *API) WalletExportKeyFile(addrs []address.Address) (*wallet.KeyFile, error) {
+	return wallet.ExportKeyFile(api.wallet, addrs)
+}
+
+// WalletImportKeyFile adds the keys of a key file to the wallet
+func (api *API) WalletImportKeyFile(kf *wallet.KeyFile) ([]address.Address, error) {
+	return wallet.ImportKeyFile(api.wallet, kf)
+}
+
 // DAGGetNode returns the associated DAG node for the passed in CID.
 func (api *API) DAGGetNode(ctx context.Context, ref string) (interface{}, error) {
 	return api.dag.GetNode(ctx, ref)
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/cskr/pubsub"
 	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/exec"
@@ -22,6 +24,7 @@ type bcfChainReader interface {
 	GetHead() types.SortedCidSet
 	GetTipSet(types.SortedCidSet) (*types.TipSet, error)
 	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+	HeadEvents() *pubsub.PubSub
 }
 
 // BlockChainFacade is a facade pattern for the chain core api. It provides a
@@ -70,19 +73,96 @@ func (chn *BlockChainFacade) Ls(ctx context.Context) (*chain.TipsetIterator, err
 	return chain.IterAncestors(ctx, chn.reader, *ts), nil
 }
 
+// GetTipSet returns the tipset with key tsKey.
+func (chn *BlockChainFacade) GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error) {
+	return chn.reader.GetTipSet(tsKey)
+}
+
+// GetTipSetAtHeight returns the tipset of the current chain at height, or
+// the one before it if there is none at height because of null blocks.
+func (chn *BlockChainFacade) GetTipSetAtHeight(ctx context.Context, height uint64) (*types.TipSet, error) {
+	head, err := chn.reader.GetTipSet(chn.reader.GetHead())
+	if err != nil {
+		return nil, err
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	if height > headHeight {
+		return nil, errors.Errorf("height %d is above the head at height %d", height, headHeight)
+	}
+
+	for it := chain.IterAncestors(ctx, chn.reader, *head); !it.Complete(); {
+		ts := it.Value()
+		h, err := ts.Height()
+		if err != nil {
+			return nil, err
+		}
+		if h <= height {
+			return &ts, nil
+		}
+		if err := it.Next(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.Errorf("no tipset at height %d", height)
+}
+
 // GetBlock gets a block by CID
 func (chn *BlockChainFacade) GetBlock(ctx context.Context, id cid.Cid) (*types.Block, error) {
 	return chn.reader.GetBlock(ctx, id)
 }
 
-// SampleRandomness samples randomness from the chain at the given height.
+// SubscribeHead returns a channel receiving the head of the chain each time it
+// changes, until ctx is done.  A subscriber that falls behind only receives
+// the latest head, so that it never holds up the chain store.
+func (chn *BlockChainFacade) SubscribeHead(ctx context.Context) <-chan types.TipSet {
+	sub := chn.reader.HeadEvents().Sub(chain.NewHeadTopic)
+	out := make(chan types.TipSet, 1)
+	go func() {
+		defer close(out)
+		defer chn.reader.HeadEvents().Unsub(sub, chain.NewHeadTopic)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-sub:
+				if !ok {
+					return
+				}
+				ts, ok := v.(types.TipSet)
+				if !ok {
+					continue
+				}
+				// Replace the head the subscriber hasn't received yet.
+				select {
+				case <-out:
+				default:
+				}
+				out <- ts
+			}
+		}
+	}()
+	return out
+}
+
+// SampleRandomness samples randomness for PoSt challenges from the chain at
+// the given height.
 func (chn *BlockChainFacade) SampleRandomness(ctx context.Context, sampleHeight *types.BlockHeight) ([]byte, error) {
-	tipSetBuffer, err := chain.GetRecentAncestorsOfHeaviestChain(ctx, chn.reader, sampleHeight)
+	return chn.Randomness(ctx, sampleHeight, sampling.PoStChallengeTag)
+}
+
+var _ sampling.Randomness = (*BlockChainFacade)(nil)
+
+// Randomness samples the randomness for tag from the heaviest chain at epoch.
+func (chn *BlockChainFacade) Randomness(ctx context.Context, epoch *types.BlockHeight, tag sampling.Tag) ([]byte, error) {
+	tipSetBuffer, err := chain.GetRecentAncestorsOfHeaviestChain(ctx, chn.reader, epoch)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get recent ancestors")
 	}
 
-	return sampling.SampleChainRandomness(sampleHeight, tipSetBuffer)
+	return sampling.NewChainRandomness(tipSetBuffer).Randomness(ctx, epoch, tag)
 }
 
 // GetActor returns an actor from the latest state on the chain
@@ -103,6 +183,39 @@ func (chn *BlockChainFacade) LsActors(ctx context.Context) (<-chan state.GetAllA
 	return state.GetAllActors(ctx, st), nil
 }
 
+// GetActorAt returns an actor from the state of the tipset with key tsKey, or
+// of the head if tsKey is empty.
+func (chn *BlockChainFacade) GetActorAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error) {
+	st, err := chn.stateAt(ctx, tsKey)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetActor(ctx, addr)
+}
+
+// DumpActor returns the actor at addr along with its decoded storage in the
+// state of the tipset with key tsKey, or of the head if tsKey is empty.
+func (chn *BlockChainFacade) DumpActor(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*state.ActorDump, error) {
+	st, err := chn.stateAt(ctx, tsKey)
+	if err != nil {
+		return nil, err
+	}
+	return state.DumpActor(ctx, st, addr)
+}
+
+// stateAt loads the state of the tipset with key tsKey, or of the head if
+// tsKey is empty.
+func (chn *BlockChainFacade) stateAt(ctx context.Context, tsKey types.SortedCidSet) (state.Tree, error) {
+	if tsKey.Len() == 0 {
+		tsKey = chn.reader.GetHead()
+	}
+	stateCid, err := chn.reader.GetTipSetStateRoot(tsKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get state root of tipset %s", tsKey)
+	}
+	return state.LoadStateTree(ctx, chn.cst, stateCid, builtin.Actors)
+}
+
 // GetActorSignature returns the signature of the given actor's given method.
 // The function signature is typically used to enable a caller to decode the
 // output of an actor method call (message).
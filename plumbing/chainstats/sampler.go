@@ -0,0 +1,215 @@
+package chainstats
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("chainstats")
+
+// Abstracts over a store of blockchain state.
+type samplerChainReader interface {
+	GetBlock(context.Context, cid.Cid) (*types.Block, error)
+	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+}
+
+// Sampler periodically records the size of the state tree, the number of
+// actors of each type and the volume of messages into a Series, so that
+// operators can forecast disk and memory growth.
+type Sampler struct {
+	chainReader samplerChainReader
+	cst         *hamt.CborIpldStore
+	bs          bstore.Blockstore
+	series      *Series
+	// interval is the number of epochs between samples.
+	interval uint64
+
+	// lastHeight is the height of the last sample taken, valid if
+	// sampled is true.
+	lastHeight uint64
+	sampled    bool
+}
+
+// NewSampler returns a Sampler that records a sample into series every
+// interval epochs.
+func NewSampler(chainReader samplerChainReader, cst *hamt.CborIpldStore, bs bstore.Blockstore, series *Series, interval uint64) *Sampler {
+	return &Sampler{
+		chainReader: chainReader,
+		cst:         cst,
+		bs:          bs,
+		series:      series,
+		interval:    interval,
+	}
+}
+
+// HandleNewHead takes a sample at head if at least the sampler's interval
+// has passed since the last one.  It is not safe for concurrent use.
+func (s *Sampler) HandleNewHead(ctx context.Context, head types.TipSet) error {
+	height, err := head.Height()
+	if err != nil {
+		return err
+	}
+	if s.sampled && height < s.lastHeight+s.interval {
+		return nil
+	}
+
+	fromHeight := uint64(0)
+	if s.sampled && height > s.lastHeight {
+		fromHeight = s.lastHeight + 1
+	} else if height >= s.interval {
+		fromHeight = height - s.interval + 1
+	}
+
+	sample, err := s.Sample(ctx, head, fromHeight)
+	if errors.Cause(err) == chain.ErrStateDeferred {
+		log.Debugf("skipping sample of %s: %s", head.String(), err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.series.Put(sample); err != nil {
+		return err
+	}
+	s.lastHeight = height
+	s.sampled = true
+	log.Debugf("sampled chain at height %d: %d state bytes, %d actors, %d messages", height, sample.StateBytes, sample.Actors, sample.Messages)
+	return nil
+}
+
+// Sample measures the state after head and the messages in the tipsets from
+// fromHeight up to head.
+func (s *Sampler) Sample(ctx context.Context, head types.TipSet, fromHeight uint64) (*Sample, error) {
+	height, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	stateRoot, err := s.chainReader.GetTipSetStateRoot(head.ToSortedCidSet())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get state root")
+	}
+
+	sample := &Sample{
+		Height:       height,
+		Timestamp:    uint64(time.Now().Unix()),
+		StateRoot:    stateRoot,
+		ActorsByType: make(map[string]int),
+		FromHeight:   fromHeight,
+	}
+
+	if sample.StateNodes, sample.StateBytes, err = s.measureDAG(ctx, stateRoot); err != nil {
+		return nil, errors.Wrap(err, "failed to measure state tree")
+	}
+
+	actors, err := state.GetAllActorsFromStore(ctx, s.cst, stateRoot)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load state tree")
+	}
+	for result := range actors {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		sample.Actors++
+		sample.ActorsByType[actorType(result.Actor.Code)]++
+	}
+
+	for it := chain.IterAncestors(ctx, s.chainReader, head); !it.Complete(); err = it.Next() {
+		if err != nil {
+			return nil, err
+		}
+		h, err := it.Value().Height()
+		if err != nil {
+			return nil, err
+		}
+		if h < fromHeight {
+			break
+		}
+		for _, blk := range it.Value().ToSlice() {
+			for _, msg := range blk.Messages {
+				raw, err := msg.Marshal()
+				if err != nil {
+					return nil, err
+				}
+				sample.Messages++
+				sample.MessageBytes += uint64(len(raw))
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sample, nil
+}
+
+// measureDAG returns the number and total size of the blocks reachable from
+// root.  Blocks missing from the blockstore are not counted.
+func (s *Sampler) measureDAG(ctx context.Context, root cid.Cid) (nodes, size uint64, err error) {
+	seen := cid.NewSet()
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		if ctx.Err() != nil {
+			return 0, 0, ctx.Err()
+		}
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !seen.Visit(c) {
+			continue
+		}
+
+		blk, err := s.bs.Get(c)
+		if err == bstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		nodes++
+		size += uint64(len(blk.RawData()))
+
+		if c.Type() != cid.DagCBOR {
+			continue
+		}
+		nd, err := cbor.DecodeBlock(blk)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "failed to decode state node %s", c)
+		}
+		for _, link := range nd.Links() {
+			stack = append(stack, link.Cid)
+		}
+	}
+	return nodes, size, nil
+}
+
+// actorType returns a name for the type of actor with the given code.
+func actorType(code cid.Cid) string {
+	switch {
+	case !code.Defined():
+		return "empty"
+	case code.Equals(types.AccountActorCodeCid):
+		return "account"
+	case code.Equals(types.StorageMarketActorCodeCid):
+		return "storagemarket"
+	case code.Equals(types.PaymentBrokerActorCodeCid):
+		return "paymentbroker"
+	case code.Equals(types.MinerActorCodeCid), code.Equals(types.BootstrapMinerActorCodeCid):
+		return "miner"
+	case code.Equals(types.RewardActorCodeCid):
+		return "reward"
+	case code.Equals(types.CronActorCodeCid):
+		return "cron"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,133 @@
+package chainstats_test
+
+import (
+	"context"
+	"testing"
+
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/chainstats"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/state"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestSampler(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	bs := bstore.NewBlockstore(datastore.NewMapDatastore())
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
+
+	// The state has two accounts and a miner.
+	tree := state.NewEmptyStateTree(cst)
+	addrGetter := address.NewForTestGetter()
+	for _, code := range []cid.Cid{types.AccountActorCodeCid, types.AccountActorCodeCid, types.MinerActorCodeCid} {
+		require.NoError(t, tree.SetActor(ctx, addrGetter(), actor.NewActor(code, types.NewZeroAttoFIL())))
+	}
+	stateRoot, err := tree.Flush(ctx)
+	require.NoError(t, err)
+
+	// The chain has one message at height 1 and two at height 2.
+	ms, _ := types.NewMockSignersAndKeyInfo(1)
+	msgGetter := types.NewSignedMessageForTestGetter(ms)
+	reader := &fakeChainReader{blocks: make(map[cid.Cid]*types.Block), stateRoot: stateRoot}
+	genesis := reader.newBlock(nil, 0)
+	b1 := reader.newBlock(genesis, 1, msgGetter())
+	b2 := reader.newBlock(b1, 2, msgGetter(), msgGetter())
+	head := types.RequireNewTipSet(t, b2)
+
+	t.Run("measures the state and messages", func(t *testing.T) {
+		sampler := chainstats.NewSampler(reader, cst, bs, chainstats.NewSeries(repo.NewInMemoryRepo().Datastore(), 0), 10)
+		sample, err := sampler.Sample(ctx, head, 1)
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(2), sample.Height)
+		assert.Equal(t, stateRoot, sample.StateRoot)
+		assert.True(t, sample.StateNodes > 0)
+		assert.True(t, sample.StateBytes > 0)
+		assert.Equal(t, uint64(3), sample.Actors)
+		assert.Equal(t, map[string]int{"account": 2, "miner": 1}, sample.ActorsByType)
+		assert.Equal(t, uint64(1), sample.FromHeight)
+		assert.Equal(t, uint64(3), sample.Messages)
+		assert.True(t, sample.MessageBytes > 0)
+
+		fromTwo, err := sampler.Sample(ctx, head, 2)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), fromTwo.Messages)
+	})
+
+	t.Run("samples once per interval", func(t *testing.T) {
+		series := chainstats.NewSeries(repo.NewInMemoryRepo().Datastore(), 0)
+		sampler := chainstats.NewSampler(reader, cst, bs, series, 2)
+
+		require.NoError(t, sampler.HandleNewHead(ctx, types.RequireNewTipSet(t, genesis)))
+		require.NoError(t, sampler.HandleNewHead(ctx, types.RequireNewTipSet(t, b1)))
+		require.NoError(t, sampler.HandleNewHead(ctx, head))
+
+		samples, err := series.Range(0, 0)
+		require.NoError(t, err)
+		require.Len(t, samples, 2)
+		assert.Equal(t, uint64(0), samples[0].Height)
+		assert.Equal(t, uint64(2), samples[1].Height)
+		assert.Equal(t, uint64(1), samples[1].FromHeight)
+		assert.Equal(t, uint64(3), samples[1].Messages)
+	})
+}
+
+func TestSeries(t *testing.T) {
+	tf.UnitTest(t)
+
+	series := chainstats.NewSeries(repo.NewInMemoryRepo().Datastore(), 3)
+	for h := uint64(1); h <= 5; h++ {
+		require.NoError(t, series.Put(&chainstats.Sample{Height: h * 10, StateRoot: types.SomeCid(), ActorsByType: map[string]int{}}))
+	}
+
+	samples, err := series.Range(0, 0)
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+	assert.Equal(t, uint64(30), samples[0].Height)
+	assert.Equal(t, uint64(50), samples[2].Height)
+
+	samples, err = series.Range(35, 45)
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	assert.Equal(t, uint64(40), samples[0].Height)
+}
+
+type fakeChainReader struct {
+	blocks    map[cid.Cid]*types.Block
+	stateRoot cid.Cid
+}
+
+func (r *fakeChainReader) newBlock(parent *types.Block, height uint64, msgs ...*types.SignedMessage) *types.Block {
+	blk := &types.Block{Height: types.Uint64(height), Messages: msgs}
+	if parent != nil {
+		blk.Parents = types.NewSortedCidSet(parent.Cid())
+	}
+	r.blocks[blk.Cid()] = blk
+	return blk
+}
+
+func (r *fakeChainReader) GetBlock(ctx context.Context, c cid.Cid) (*types.Block, error) {
+	blk, ok := r.blocks[c]
+	if !ok {
+		return nil, errors.Errorf("no block %s", c)
+	}
+	return blk, nil
+}
+
+func (r *fakeChainReader) GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error) {
+	return r.stateRoot, nil
+}
@@ -0,0 +1,141 @@
+package chainstats
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+func init() {
+	cbor.RegisterCborType(Sample{})
+}
+
+// Prefix is the datastore prefix for chain and state samples.
+const Prefix = "chainstats"
+
+// Sample describes the size of the chain's state at a height and the message
+// volume of the epochs since the previous sample.
+type Sample struct {
+	Height uint64 `json:"height"`
+	// Timestamp is the unix time at which the sample was taken.
+	Timestamp uint64  `json:"timestamp"`
+	StateRoot cid.Cid `json:"stateRoot"`
+
+	// StateNodes and StateBytes are the number and total size of the
+	// blocks of the state tree, including actor state.
+	StateNodes uint64 `json:"stateNodes"`
+	StateBytes uint64 `json:"stateBytes"`
+
+	Actors       uint64         `json:"actors"`
+	ActorsByType map[string]int `json:"actorsByType"`
+
+	// FromHeight is the first height whose messages are counted in
+	// Messages and MessageBytes.
+	FromHeight   uint64 `json:"fromHeight"`
+	Messages     uint64 `json:"messages"`
+	MessageBytes uint64 `json:"messageBytes"`
+}
+
+// Series is a persisted time series of samples, keyed by height.
+type Series struct {
+	ds         repo.Datastore
+	maxSamples int
+
+	// lk serializes writes so that pruning sees a consistent set of keys.
+	lk sync.Mutex
+}
+
+// NewSeries returns a Series persisted to ds that keeps at most maxSamples
+// samples, or all samples if maxSamples is not positive.
+func NewSeries(ds repo.Datastore, maxSamples int) *Series {
+	return &Series{ds: ds, maxSamples: maxSamples}
+}
+
+// Put records a sample, replacing any sample at the same height and
+// discarding the oldest samples beyond the series' capacity.
+func (s *Series) Put(sample *Sample) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	datum, err := cbor.DumpObject(sample)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal sample")
+	}
+	if err := s.ds.Put(sampleKey(sample.Height), datum); err != nil {
+		return errors.Wrap(err, "could not save sample")
+	}
+	return s.prune()
+}
+
+// Range returns the samples with heights from fromHeight to toHeight
+// inclusive, ordered by height.  A toHeight of zero means no upper bound.
+func (s *Series) Range(fromHeight, toHeight uint64) ([]*Sample, error) {
+	results, err := s.ds.Query(query.Query{Prefix: "/" + Prefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query samples")
+	}
+
+	var samples []*Sample
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, errors.Wrap(result.Error, "failed to query samples")
+		}
+		var sample Sample
+		if err := cbor.DecodeInto(result.Value, &sample); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal sample")
+		}
+		if sample.Height < fromHeight || (toHeight != 0 && sample.Height > toHeight) {
+			continue
+		}
+		samples = append(samples, &sample)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Height < samples[j].Height })
+	return samples, nil
+}
+
+// prune deletes the oldest samples beyond the series' capacity.
+func (s *Series) prune() error {
+	if s.maxSamples <= 0 {
+		return nil
+	}
+	results, err := s.ds.Query(query.Query{Prefix: "/" + Prefix, KeysOnly: true})
+	if err != nil {
+		return errors.Wrap(err, "failed to query samples")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to query samples")
+	}
+	if len(entries) <= s.maxSamples {
+		return nil
+	}
+
+	heights := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		height, err := strconv.ParseUint(datastore.NewKey(entry.Key).BaseNamespace(), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "malformed sample key %s", entry.Key)
+		}
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	for _, height := range heights[:len(heights)-s.maxSamples] {
+		if err := s.ds.Delete(sampleKey(height)); err != nil {
+			return errors.Wrap(err, "failed to delete sample")
+		}
+	}
+	return nil
+}
+
+func sampleKey(height uint64) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{Prefix, fmt.Sprintf("%d", height)})
+}
@@ -0,0 +1,182 @@
+package faults
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("faults")
+
+var faultsDetectedCt = metrics.NewInt64Counter("consensus/faults_detected", "Number of consensus faults detected in block headers received")
+
+func init() {
+	cbor.RegisterCborType(Fault{})
+}
+
+// Prefix is the datastore prefix for consensus faults.
+const Prefix = "faults"
+
+// Window is the number of heights below the highest header observed for
+// which headers are remembered.  Double mining further in the past isn't
+// detected.
+const Window = 100
+
+// Fault is the evidence of a consensus fault: two distinct blocks mined by
+// the same miner at the same height.
+type Fault struct {
+	Miner  address.Address    `json:"miner"`
+	Height uint64             `json:"height"`
+	First  *types.BlockHeader `json:"first"`
+	Second *types.BlockHeader `json:"second"`
+	// DetectedAt is the unix time at which the fault was detected.
+	DetectedAt uint64 `json:"detectedAt"`
+}
+
+// Handler is called with each fault detected, e.g. to submit it for
+// slashing.
+type Handler func(*Fault)
+
+type minerHeight struct {
+	miner  address.Address
+	height uint64
+}
+
+// Detector records the headers of the blocks the node receives per miner and
+// height, and records a fault when a miner announces two distinct blocks at
+// the same height.  Faults are persisted so they can be acted upon after a
+// restart.
+//
+// Blocks are not signed yet, only their tickets are, so a peer replaying a
+// miner's ticket in a block of its own making is indistinguishable from the
+// miner mining twice.  Faults must not be submitted for slashing automatically
+// until blocks carry their miner's signature.
+type Detector struct {
+	ds       datastore.Datastore
+	handlers []Handler
+
+	lk sync.Mutex
+	// seen is the first header observed for each miner and height within
+	// the window.
+	seen    map[minerHeight]*types.BlockHeader
+	highest uint64
+}
+
+// NewDetector returns a Detector persisting faults to ds and calling
+// handlers with each new fault.
+func NewDetector(ds datastore.Datastore, handlers ...Handler) *Detector {
+	return &Detector{
+		ds:       ds,
+		handlers: handlers,
+		seen:     make(map[minerHeight]*types.BlockHeader),
+	}
+}
+
+// Observe records h and returns the fault it proves, if any.  Only the first
+// fault of a miner at a height is recorded.  Observe must only be given
+// headers that passed validation, or anyone could frame a miner.
+func (d *Detector) Observe(ctx context.Context, h *types.BlockHeader) (*Fault, error) {
+	height := uint64(h.Height)
+	key := minerHeight{h.Miner, height}
+
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	if height+Window < d.highest {
+		return nil, nil
+	}
+	if height > d.highest {
+		d.highest = height
+		d.prune()
+	}
+	first, ok := d.seen[key]
+	if !ok {
+		d.seen[key] = h
+		return nil, nil
+	}
+
+	if first.Cid.Equals(h.Cid) {
+		return nil, nil
+	}
+	has, err := d.ds.Has(faultKey(h.Miner, height))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up fault")
+	}
+	if has {
+		return nil, nil
+	}
+
+	fault := &Fault{
+		Miner:      h.Miner,
+		Height:     height,
+		First:      first,
+		Second:     h,
+		DetectedAt: uint64(time.Now().Unix()),
+	}
+	datum, err := cbor.DumpObject(fault)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal fault")
+	}
+	if err := d.ds.Put(faultKey(h.Miner, height), datum); err != nil {
+		return nil, errors.Wrap(err, "could not save fault")
+	}
+
+	log.Warningf("miner %s mined blocks %s and %s at height %d", h.Miner, first.Cid, h.Cid, height)
+	faultsDetectedCt.Inc(ctx, 1)
+	for _, handle := range d.handlers {
+		handle(fault)
+	}
+	return fault, nil
+}
+
+// Faults returns the faults recorded, ordered by height.
+func (d *Detector) Faults() ([]*Fault, error) {
+	results, err := d.ds.Query(query.Query{Prefix: "/" + Prefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query faults")
+	}
+
+	var faults []*Fault
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, errors.Wrap(result.Error, "failed to query faults")
+		}
+		var fault Fault
+		if err := cbor.DecodeInto(result.Value, &fault); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal fault")
+		}
+		faults = append(faults, &fault)
+	}
+	sort.Slice(faults, func(i, j int) bool {
+		if faults[i].Height != faults[j].Height {
+			return faults[i].Height < faults[j].Height
+		}
+		return faults[i].Miner.String() < faults[j].Miner.String()
+	})
+	return faults, nil
+}
+
+// prune forgets the headers below the window.  The caller must hold the
+// lock.
+func (d *Detector) prune() {
+	for key := range d.seen {
+		if key.height+Window < d.highest {
+			delete(d.seen, key)
+		}
+	}
+}
+
+func faultKey(miner address.Address, height uint64) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{Prefix, miner.String(), fmt.Sprintf("%d", height)})
+}
@@ -0,0 +1,90 @@
+package faults_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/faults"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestDetector(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrGetter := address.NewForTestGetter()
+	miner, other := addrGetter(), addrGetter()
+	cidGetter := types.NewCidForTestGetter()
+	header := func(miner address.Address, height uint64) *types.BlockHeader {
+		return &types.BlockHeader{Cid: cidGetter(), Miner: miner, Height: types.Uint64(height)}
+	}
+
+	t.Run("detects two blocks of a miner at the same height", func(t *testing.T) {
+		var handled []*faults.Fault
+		d := faults.NewDetector(datastore.NewMapDatastore(), func(f *faults.Fault) { handled = append(handled, f) })
+
+		first := header(miner, 5)
+		fault, err := d.Observe(ctx, first)
+		require.NoError(t, err)
+		assert.Nil(t, fault)
+
+		// The same block again, and blocks of other miners or heights,
+		// aren't faults.
+		for _, h := range []*types.BlockHeader{first, header(other, 5), header(miner, 6)} {
+			fault, err = d.Observe(ctx, h)
+			require.NoError(t, err)
+			assert.Nil(t, fault)
+		}
+
+		second := header(miner, 5)
+		fault, err = d.Observe(ctx, second)
+		require.NoError(t, err)
+		require.NotNil(t, fault)
+		assert.Equal(t, miner, fault.Miner)
+		assert.Equal(t, uint64(5), fault.Height)
+		assert.Equal(t, first.Cid, fault.First.Cid)
+		assert.Equal(t, second.Cid, fault.Second.Cid)
+		assert.Equal(t, []*faults.Fault{fault}, handled)
+
+		// Only the first fault at a height is recorded.
+		fault, err = d.Observe(ctx, header(miner, 5))
+		require.NoError(t, err)
+		assert.Nil(t, fault)
+
+		recorded, err := d.Faults()
+		require.NoError(t, err)
+		require.Len(t, recorded, 1)
+		assert.Equal(t, second.Cid, recorded[0].Second.Cid)
+	})
+
+	t.Run("faults are persisted", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		d := faults.NewDetector(ds)
+		_, err := d.Observe(ctx, header(miner, 1))
+		require.NoError(t, err)
+		_, err = d.Observe(ctx, header(miner, 1))
+		require.NoError(t, err)
+
+		recorded, err := faults.NewDetector(ds).Faults()
+		require.NoError(t, err)
+		assert.Len(t, recorded, 1)
+	})
+
+	t.Run("forgets headers below the window", func(t *testing.T) {
+		d := faults.NewDetector(datastore.NewMapDatastore())
+		_, err := d.Observe(ctx, header(miner, 1))
+		require.NoError(t, err)
+		_, err = d.Observe(ctx, header(other, 2+faults.Window))
+		require.NoError(t, err)
+
+		fault, err := d.Observe(ctx, header(miner, 1))
+		require.NoError(t, err)
+		assert.Nil(t, fault)
+	})
+}
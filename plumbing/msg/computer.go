@@ -0,0 +1,168 @@
+package msg
+
+import (
+	"context"
+
+	blocks "github.com/ipfs/go-block-format"
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/sampling"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// Abstracts over a store of blockchain state.
+type computerChainReader interface {
+	GetBlock(context.Context, cid.Cid) (*types.Block, error)
+	GetHead() types.SortedCidSet
+	GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
+	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+}
+
+// StateComputation is the result of applying a set of messages on top of the
+// state of a tipset.
+type StateComputation struct {
+	// TipSet is the tipset whose state the messages were applied to, and
+	// Height the height they were applied at, the one following it.
+	TipSet types.SortedCidSet `json:"tipSet"`
+	Height *types.BlockHeight `json:"height"`
+	// StateRoot is the root of the state after applying the messages.  The
+	// state itself is discarded, so it can't be loaded from the repo.
+	StateRoot cid.Cid                    `json:"stateRoot"`
+	Messages  []*consensus.MessageReplay `json:"messages"`
+}
+
+// StateComputer applies hypothetical messages to the chain's state, without
+// changing it.
+type StateComputer struct {
+	chainReader computerChainReader
+	bs          bstore.Blockstore
+}
+
+// NewStateComputer returns a new StateComputer.
+func NewStateComputer(chainReader computerChainReader, bs bstore.Blockstore) *StateComputer {
+	return &StateComputer{
+		chainReader: chainReader,
+		bs:          bs,
+	}
+}
+
+// Compute applies messages in order on top of the state of the tipset with
+// key tsKey, or of the head if tsKey is empty, as if they were the messages
+// of a block mined on it.  Gas is paid to the network actor.  It returns the
+// resulting state root along with each message's receipt, sends and state
+// changes.  Messages are applied even if they would not be accepted in a
+// block, e.g. because of a bad nonce, and are reported with their error.
+func (c *StateComputer) Compute(ctx context.Context, tsKey types.SortedCidSet, messages []*types.SignedMessage) (*StateComputation, error) {
+	if tsKey.Len() == 0 {
+		tsKey = c.chainReader.GetHead()
+	}
+	ts, err := c.chainReader.GetTipSet(tsKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load tipset %s", tsKey)
+	}
+	h, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	stateCid, err := c.chainReader.GetTipSetStateRoot(tsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tipset state root")
+	}
+
+	// All writes go to memory so that the repo's state is left unmodified.
+	sandbox := newSandboxBlockstore(c.bs)
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(sandbox, offline.Exchange(sandbox))}
+	st, err := state.LoadStateTree(ctx, cst, stateCid, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load tipset state")
+	}
+
+	bh := types.NewBlockHeight(h + 1)
+	ancestorHeight := types.NewBlockHeight(consensus.AncestorRoundsNeeded)
+	ancestors, err := chain.GetRecentAncestors(ctx, *ts, c.chainReader, bh, ancestorHeight, sampling.LookbackParameter)
+	if err != nil {
+		return nil, err
+	}
+
+	vms := vm.NewStorageMap(sandbox)
+	results, err := consensus.NewDefaultProcessor().ComputeMessages(ctx, st, vms, messages, address.NetworkAddress, bh, ancestors)
+	if err != nil {
+		return nil, err
+	}
+	if err := vms.Flush(); err != nil {
+		return nil, errors.Wrap(err, "failed to flush actor storage")
+	}
+	root, err := st.Flush(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to flush state")
+	}
+
+	return &StateComputation{
+		TipSet:    tsKey,
+		Height:    bh,
+		StateRoot: root,
+		Messages:  results,
+	}, nil
+}
+
+// sandboxBlockstore reads through to a blockstore but keeps the blocks put
+// to it in memory.
+type sandboxBlockstore struct {
+	bstore.Blockstore
+	mem bstore.Blockstore
+}
+
+func newSandboxBlockstore(bs bstore.Blockstore) *sandboxBlockstore {
+	return &sandboxBlockstore{
+		Blockstore: bs,
+		mem:        bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore())),
+	}
+}
+
+func (sb *sandboxBlockstore) Has(c cid.Cid) (bool, error) {
+	if has, err := sb.mem.Has(c); err != nil || has {
+		return has, err
+	}
+	return sb.Blockstore.Has(c)
+}
+
+func (sb *sandboxBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	blk, err := sb.mem.Get(c)
+	if err == bstore.ErrNotFound {
+		return sb.Blockstore.Get(c)
+	}
+	return blk, err
+}
+
+func (sb *sandboxBlockstore) GetSize(c cid.Cid) (int, error) {
+	size, err := sb.mem.GetSize(c)
+	if err == bstore.ErrNotFound {
+		return sb.Blockstore.GetSize(c)
+	}
+	return size, err
+}
+
+func (sb *sandboxBlockstore) Put(blk blocks.Block) error {
+	return sb.mem.Put(blk)
+}
+
+func (sb *sandboxBlockstore) PutMany(blks []blocks.Block) error {
+	return sb.mem.PutMany(blks)
+}
+
+func (sb *sandboxBlockstore) DeleteBlock(c cid.Cid) error {
+	return sb.mem.DeleteBlock(c)
+}
@@ -0,0 +1,48 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestStateCompute(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
+	fromAddr, toAddr := mockSigner.Addresses[0], address.NewForTestGetter()()
+	deps := requiredCommonDeps(t, consensus.MakeGenesisFunc(
+		consensus.ActorAccount(fromAddr, types.NewAttoFILFromFIL(1000)),
+	))
+	head := deps.chainStore.GetHead()
+	headRoot, err := deps.chainStore.GetTipSetStateRoot(head)
+	require.NoError(t, err)
+
+	msg := types.NewMessage(fromAddr, toAddr, 0, types.NewAttoFILFromFIL(100), "", nil)
+	smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+
+	computation, err := NewStateComputer(deps.chainStore, deps.blockstore).Compute(ctx, types.SortedCidSet{}, []*types.SignedMessage{smsg})
+	require.NoError(t, err)
+
+	assert.Equal(t, head, computation.TipSet)
+	assert.Equal(t, types.NewBlockHeight(1), computation.Height)
+	require.Len(t, computation.Messages, 1)
+	require.NotNil(t, computation.Messages[0].Receipt)
+	assert.Equal(t, uint8(0), computation.Messages[0].Receipt.ExitCode)
+	assert.False(t, computation.StateRoot.Equals(headRoot))
+
+	// Nothing was written to the repo.
+	has, err := deps.blockstore.Has(computation.StateRoot)
+	require.NoError(t, err)
+	assert.False(t, has)
+	assert.Equal(t, head, deps.chainStore.GetHead())
+}
@@ -0,0 +1,95 @@
+package msg
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Statuses of messages in the history of an address.
+const (
+	// HistoryApplied is the status of messages applied successfully.
+	HistoryApplied = "applied"
+	// HistoryFailed is the status of messages whose application failed.
+	HistoryFailed = "failed"
+	// HistorySkipped is the status of messages included in a block but not
+	// applied, e.g. because they conflicted with another message of their
+	// tipset.
+	HistorySkipped = "skipped"
+)
+
+// HistoryEntry is a message an address sent or received, with the height it
+// was included at and the outcome of its application.
+type HistoryEntry struct {
+	Cid     cid.Cid              `json:"cid"`
+	Message *types.SignedMessage `json:"message"`
+	Height  uint64               `json:"height"`
+	// Sent is true if the address sent the message, false if it received
+	// it.  Messages an address sends to itself are sent.
+	Sent   bool   `json:"sent"`
+	Status string `json:"status"`
+	// Receipt is nil if the message was skipped.
+	Receipt *types.MessageReceipt `json:"receipt"`
+	// GasCost is the gas used by the message times its gas price, which the
+	// sender paid.
+	GasCost *types.AttoFIL `json:"gasCost"`
+}
+
+// History returns the messages on chain that addr sent or received, newest
+// first.  The offset newest messages are skipped, and at most limit are
+// returned, or all of them if limit is zero.  It relies on the message index
+// of the chain and fails if the index isn't up to date with the head.
+func (w *Waiter) History(ctx context.Context, addr address.Address, offset, limit uint) ([]*HistoryEntry, error) {
+	msgs, err := w.chainReader.GetAddressMessages(addr)
+	if err == chain.ErrMessageIndexStale {
+		return nil, errors.Wrap(err, "message history unavailable until the index catches up")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if offset >= uint(len(msgs)) {
+		return []*HistoryEntry{}, nil
+	}
+	msgs = msgs[offset:]
+	if limit > 0 && limit < uint(len(msgs)) {
+		msgs = msgs[:limit]
+	}
+
+	entries := make([]*HistoryEntry, len(msgs))
+	for i, m := range msgs {
+		chainMsg, found, err := w.Find(ctx, m.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load message %s", m.Cid)
+		}
+		if !found {
+			return nil, errors.Errorf("indexed message %s not found on chain", m.Cid)
+		}
+
+		entry := &HistoryEntry{
+			Cid:     m.Cid,
+			Message: chainMsg.Message,
+			Height:  m.Height,
+			Sent:    chainMsg.Message.From == addr,
+			Receipt: chainMsg.Receipt,
+			Status:  HistorySkipped,
+			GasCost: types.NewZeroAttoFIL(),
+		}
+		if chainMsg.Receipt != nil {
+			entry.Status = HistoryApplied
+			if chainMsg.Receipt.ExitCode != 0 {
+				entry.Status = HistoryFailed
+			}
+			if chainMsg.Receipt.GasAttoFIL != nil {
+				entry.GasCost = chainMsg.Receipt.GasAttoFIL
+			}
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
@@ -9,10 +9,11 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/address"
-	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
 	"github.com/filecoin-project/go-filecoin/wallet"
@@ -20,8 +21,8 @@ import (
 
 // Abstracts over a store of blockchain state.
 type queryerChainReader interface {
-	BlockHeight() (uint64, error)
 	GetHead() types.SortedCidSet
+	GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
 	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
 }
 
@@ -43,21 +44,38 @@ func NewQueryer(repo repo.Repo, wallet *wallet.Wallet, chainReader queryerChainR
 	return &Queryer{repo, wallet, chainReader, cst, bs}
 }
 
-// Query sends a read-only message to an actor.
+// Query sends a read-only message to an actor, against the state of the head.
 func (q *Queryer) Query(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error) {
+	return q.QueryAt(ctx, types.SortedCidSet{}, optFrom, to, method, params...)
+}
+
+// QueryAt sends a read-only message to an actor, against the state of the
+// tipset with key tsKey, or of the head if tsKey is empty.
+func (q *Queryer) QueryAt(ctx context.Context, tsKey types.SortedCidSet, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error) {
 	encodedParams, err := abi.ToEncodedValues(params...)
 	if err != nil {
 		return nil, errors.Wrap(err, "couldnt encode message params")
 	}
 
-	st, err := chain.LatestState(ctx, q.chainReader, q.cst)
+	if tsKey.Len() == 0 {
+		tsKey = q.chainReader.GetHead()
+	}
+	ts, err := q.chainReader.GetTipSet(tsKey)
 	if err != nil {
-		return nil, errors.Wrap(err, "could load tree for latest state root")
+		return nil, errors.Wrapf(err, "couldnt get tipset %s", tsKey)
 	}
-	h, err := q.chainReader.BlockHeight()
+	h, err := ts.Height()
 	if err != nil {
 		return nil, errors.Wrap(err, "couldnt get base tipset height")
 	}
+	stateCid, err := q.chainReader.GetTipSetStateRoot(tsKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldnt get state root of tipset %s", tsKey)
+	}
+	st, err := state.LoadStateTree(ctx, q.cst, stateCid, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrap(err, "could load tree for state root")
+	}
 
 	vms := vm.NewStorageMap(q.bs)
 	r, ec, err := consensus.CallQueryMethod(ctx, st, vms, to, method, encodedParams, optFrom, types.NewBlockHeight(h))
@@ -0,0 +1,192 @@
+package msg
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/sampling"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// Abstracts over a store of blockchain state.
+type replayerChainReader interface {
+	GetBlock(context.Context, cid.Cid) (*types.Block, error)
+	GetHead() types.SortedCidSet
+	GetMessageLocation(msgCid cid.Cid) (*chain.MessageLocation, bool, error)
+	GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
+	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+}
+
+// Replayer re-executes messages that are on chain.
+type Replayer struct {
+	chainReader replayerChainReader
+	cst         *hamt.CborIpldStore
+	bs          bstore.Blockstore
+}
+
+// NewReplayer returns a new Replayer.
+func NewReplayer(chainReader replayerChainReader, cst *hamt.CborIpldStore, bs bstore.Blockstore) *Replayer {
+	return &Replayer{
+		chainReader: chainReader,
+		cst:         cst,
+		bs:          bs,
+	}
+}
+
+// Replay re-executes the message with the given cid against the state it was
+// originally applied to and reports its gas usage, the sends it made and the
+// actor state changes it caused.  The chain's state is left unmodified.
+func (r *Replayer) Replay(ctx context.Context, msgCid cid.Cid) (*consensus.MessageReplay, error) {
+	ts, found, err := r.messageTipSet(ctx, msgCid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to locate message %s", msgCid)
+	}
+	if !found {
+		return nil, errors.Errorf("message %s not found on chain", msgCid)
+	}
+	h, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return nil, err
+	}
+	stateCid, err := r.chainReader.GetTipSetStateRoot(parentKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent state root")
+	}
+	st, err := state.LoadStateTree(ctx, r.cst, stateCid, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load parent state")
+	}
+
+	parentTs, err := r.chainReader.GetTipSet(parentKey)
+	if err != nil {
+		return nil, err
+	}
+	ancestorHeight := types.NewBlockHeight(consensus.AncestorRoundsNeeded)
+	ancestors, err := chain.GetRecentAncestors(ctx, *parentTs, r.chainReader, types.NewBlockHeight(h), ancestorHeight, sampling.LookbackParameter)
+	if err != nil {
+		return nil, err
+	}
+
+	// The state tree is never flushed so the replay leaves no trace in the
+	// repo's state.
+	return consensus.NewBlockProcessor().ReplayMessage(ctx, st, vm.NewStorageMap(r.bs), ts, ancestors, msgCid)
+}
+
+// messageTipSet returns the tipset that included the message with msgCid on
+// the chain, and whether it was found.  The chain's message index is consulted
+// first; the chain is only traversed if the index is not up to date with the
+// head.
+func (r *Replayer) messageTipSet(ctx context.Context, msgCid cid.Cid) (types.TipSet, bool, error) {
+	loc, found, err := r.chainReader.GetMessageLocation(msgCid)
+	if err == nil {
+		if !found {
+			return nil, false, nil
+		}
+		ts, err := r.chainReader.GetTipSet(loc.TipSet)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to load tipset %s", loc.TipSet)
+		}
+		return *ts, true, nil
+	}
+	if err != chain.ErrMessageIndexStale {
+		return nil, false, err
+	}
+	log.Debugf("message index stale, searching chain for %s", msgCid)
+
+	head, err := r.chainReader.GetTipSet(r.chainReader.GetHead())
+	if err != nil {
+		return nil, false, err
+	}
+	for iterator := chain.IterAncestors(ctx, r.chainReader, *head); !iterator.Complete(); err = iterator.Next() {
+		if err != nil {
+			return nil, false, err
+		}
+		for _, blk := range iterator.Value() {
+			for _, msg := range blk.Messages {
+				c, err := msg.Cid()
+				if err != nil {
+					return nil, false, err
+				}
+				if c.Equals(msgCid) {
+					return iterator.Value(), true, nil
+				}
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// ReplayTipSet re-executes every message of the tipset with key tsKey against
+// the state of its parent and compares the state it computes with the state
+// recorded for the tipset.  The computed state is written to the cbor store so
+// that it can be inspected, but the chain's state is left unmodified.
+func (r *Replayer) ReplayTipSet(ctx context.Context, tsKey types.SortedCidSet) (*consensus.TipSetReplay, error) {
+	ts, err := r.chainReader.GetTipSet(tsKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load tipset %s", tsKey)
+	}
+	h, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return nil, err
+	}
+	if parentKey.Len() == 0 {
+		return nil, errors.New("the genesis tipset has no parent to replay it against")
+	}
+	stateCid, err := r.chainReader.GetTipSetStateRoot(parentKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent state root")
+	}
+	st, err := state.LoadStateTree(ctx, r.cst, stateCid, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load parent state")
+	}
+
+	parentTs, err := r.chainReader.GetTipSet(parentKey)
+	if err != nil {
+		return nil, err
+	}
+	ancestorHeight := types.NewBlockHeight(consensus.AncestorRoundsNeeded)
+	ancestors, err := chain.GetRecentAncestors(ctx, *parentTs, r.chainReader, types.NewBlockHeight(h), ancestorHeight, sampling.LookbackParameter)
+	if err != nil {
+		return nil, err
+	}
+
+	replay, err := consensus.NewBlockProcessor().ReplayTipSet(ctx, st, vm.NewStorageMap(r.bs), *ts, ancestors)
+	if err != nil {
+		return nil, err
+	}
+
+	replay.RecordedStateRoot, err = r.chainReader.GetTipSetStateRoot(tsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recorded state root")
+	}
+	if replay.Matches() {
+		return replay, nil
+	}
+	recorded, err := state.LoadStateTree(ctx, r.cst, replay.RecordedStateRoot, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load recorded state")
+	}
+	replay.Diff, err = consensus.DiffStates(ctx, recorded, st)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to diff states")
+	}
+	return replay, nil
+}
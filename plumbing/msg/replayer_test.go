@@ -0,0 +1,72 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/core"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestReplayWithStaleIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	fromAddr, toAddr := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	minerAddr, minerOwner := mockSigner.Addresses[2], mockSigner.Addresses[3]
+	deps := requiredCommonDeps(t, consensus.MakeGenesisFunc(
+		consensus.ActorAccount(fromAddr, types.NewAttoFILFromFIL(1000)),
+		consensus.ActorAccount(minerOwner, types.ZeroAttoFIL),
+		consensus.MinerActor(minerAddr, minerOwner, []byte{}, 1000, th.RequireRandomPeerID(t), types.ZeroAttoFIL, types.OneKiBSectorSize),
+	))
+	// Failing to compute receipts leaves the message index behind the head.
+	deps.chainStore.SetMessageReceipts(func(context.Context, types.TipSet) (map[cid.Cid]*types.MessageReceipt, error) {
+		return nil, errors.New("no receipts")
+	})
+
+	msg := types.NewMessage(fromAddr, toAddr, 0, types.NewAttoFILFromFIL(100), "", nil)
+	smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(300))
+	require.NoError(t, err)
+	msgCid, err := smsg.Cid()
+	require.NoError(t, err)
+
+	head, err := deps.chainStore.GetTipSet(deps.chainStore.GetHead())
+	require.NoError(t, err)
+	baseBlock := head.ToSlice()[0]
+	blk := th.RequireMkFakeChild(t, th.FakeChildParams{
+		MinerAddr:  minerAddr,
+		Parent:     *head,
+		GenesisCid: deps.chainStore.GenesisCid(),
+		StateRoot:  baseBlock.StateRoot,
+		Signer:     mockSigner,
+	})
+	blk.Messages = []*types.SignedMessage{smsg}
+	core.MustPut(deps.cst, blk)
+	ts := th.RequireNewTipSet(t, blk)
+	th.RequirePutTsas(ctx, t, deps.chainStore, &chain.TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: baseBlock.StateRoot,
+	})
+	require.NoError(t, deps.chainStore.SetHead(ctx, ts))
+	_, _, err = deps.chainStore.GetMessageLocation(msgCid)
+	require.Equal(t, chain.ErrMessageIndexStale, err)
+
+	replayer := NewReplayer(deps.chainStore, deps.cst, deps.blockstore)
+	replay, err := replayer.Replay(ctx, msgCid)
+	require.NoError(t, err)
+	assert.Equal(t, smsg, replay.Message)
+	require.NotNil(t, replay.Receipt)
+	assert.Equal(t, uint8(0), replay.Receipt.ExitCode)
+
+	_, err = replayer.Replay(ctx, types.SomeCid())
+	assert.Error(t, err)
+}
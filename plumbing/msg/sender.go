@@ -103,7 +103,7 @@ func (s *Sender) Send(ctx context.Context, from, to address.Address, value *type
 		return cid.Undef, errors.Wrapf(err, "no actor at address %s", from)
 	}
 
-	nonce, err := nextNonce(fromActor, s.outbox, from)
+	nonce, err := nextNonce(ctx, fromActor, s.outbox, s.inbox, from)
 	if err != nil {
 		return cid.Undef, errors.Wrapf(err, "failed calculating nonce for actor %s", from)
 	}
@@ -116,7 +116,7 @@ func (s *Sender) Send(ctx context.Context, from, to address.Address, value *type
 
 	err = s.validator.Validate(ctx, smsg, fromActor)
 	if err != nil {
-		return cid.Undef, errors.Wrap(err, "invalid message")
+		return cid.Undef, errors.Wrap(consensus.AsRejection(err), "invalid message")
 	}
 
 	smsgdata, err := smsg.Marshal()
@@ -133,9 +133,15 @@ func (s *Sender) Send(ctx context.Context, from, to address.Address, value *type
 	if err := s.outbox.Enqueue(smsg, height); err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to add message to outbound queue")
 	}
-	if _, err := s.inbox.Add(ctx, smsg); err != nil {
+	c, err := s.inbox.AddLocal(ctx, smsg)
+	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to add message to message pool")
 	}
+	if s.inbox.IsQueued(c) {
+		// The pool publishes the message once the gap before its nonce fills.
+		log.Infof("message %s from %s follows a nonce gap, holding it until the gap fills", c, from)
+		return c, nil
+	}
 
 	if err = s.publish(Topic, smsgdata); err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to publish message to network")
@@ -145,17 +151,154 @@ func (s *Sender) Send(ctx context.Context, from, to address.Address, value *type
 	return smsg.Cid()
 }
 
+// Replace sends again the pending message with cid c with gasPrice and
+// gasLimit, so that it replaces the original in the message pools of the
+// network, e.g. when the original is stuck with too low a gas price.  A zero
+// gasPrice is replaced by the least price the pool accepts for a
+// replacement, and a zero gasLimit by the original's.  The message must be
+// from an address of the node's wallet.
+func (s *Sender) Replace(ctx context.Context, c cid.Cid, gasPrice types.AttoFIL, gasLimit types.GasUnits) (out cid.Cid, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+	}()
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	orig, ok := s.inbox.Get(c)
+	if !ok {
+		return cid.Undef, errors.Errorf("message %s is not in the message pool", c)
+	}
+	if gasPrice.IsZero() {
+		gasPrice = *s.inbox.MinReplacementGasPrice(orig)
+	}
+	if gasLimit == 0 {
+		gasLimit = orig.GasLimit
+	}
+
+	smsg, err := types.NewSignedMessage(orig.Message, s.signer, gasPrice, gasLimit)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to sign message")
+	}
+
+	st, err := chain.LatestState(ctx, s.chainState, s.cst)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to load state from chain")
+	}
+	fromActor, err := st.GetActor(ctx, orig.From)
+	if err != nil {
+		return cid.Undef, errors.Wrapf(err, "no actor at address %s", orig.From)
+	}
+	if err := s.validator.Validate(ctx, smsg, fromActor); err != nil {
+		return cid.Undef, errors.Wrap(consensus.AsRejection(err), "invalid message")
+	}
+
+	smsgdata, err := smsg.Marshal()
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to marshal message")
+	}
+	height, err := s.blockTimer.BlockHeight()
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to get block height")
+	}
+
+	out, err = s.inbox.AddLocal(ctx, smsg)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to replace message in message pool")
+	}
+	// The original may have been sent by another node with the same keys.
+	if err := s.outbox.Replace(smsg, height); err != nil {
+		log.Debugf("replaced message %s not in outbound queue: %s", c, err)
+	}
+	if !s.inbox.IsQueued(out) {
+		if err = s.publish(Topic, smsgdata); err != nil {
+			return cid.Undef, errors.Wrap(err, "failed to publish message to network")
+		}
+	}
+
+	log.Debugf("MessageReplace replaced %s with message: %s", c, smsg)
+	return out, nil
+}
+
+// SendSigned sends smsg, a message signed elsewhere, e.g. by a wallet kept
+// on an offline machine.  It is validated against the latest state like the
+// messages Send signs, and its sender needn't be in the node's wallet.
+func (s *Sender) SendSigned(ctx context.Context, smsg *types.SignedMessage) (out cid.Cid, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+	}()
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	st, err := chain.LatestState(ctx, s.chainState, s.cst)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to load state from chain")
+	}
+	fromActor, err := st.GetActor(ctx, smsg.From)
+	if err != nil {
+		return cid.Undef, errors.Wrapf(err, "no actor at address %s", smsg.From)
+	}
+	if err := s.validator.Validate(ctx, smsg, fromActor); err != nil {
+		return cid.Undef, errors.Wrap(consensus.AsRejection(err), "invalid message")
+	}
+
+	smsgdata, err := smsg.Marshal()
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to marshal message")
+	}
+	height, err := s.blockTimer.BlockHeight()
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to get block height")
+	}
+
+	out, err = s.inbox.AddLocal(ctx, smsg)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to add message to message pool")
+	}
+	// Queue the message so that it is resent and messages the node sends
+	// from the same address follow its nonce, unless it doesn't follow the
+	// queued ones.
+	if err := s.outbox.Enqueue(smsg, height); err != nil {
+		log.Debugf("signed message %s not added to outbound queue: %s", out, err)
+	}
+	if s.inbox.IsQueued(out) {
+		log.Infof("message %s from %s follows a nonce gap, holding it until the gap fills", out, smsg.From)
+		return out, nil
+	}
+
+	if err = s.publish(Topic, smsgdata); err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to publish message to network")
+	}
+
+	log.Debugf("MessageSendSigned with message: %s", smsg)
+	return out, nil
+}
+
 // nextNonce returns the next expected nonce value for an account actor. This is the larger
-// of the actor's nonce value, or one greater than the largest nonce from the actor found in the message pool.
-func nextNonce(act *actor.Actor, outbox *core.MessageQueue, address address.Address) (uint64, error) {
+// of the actor's nonce value, or one greater than the largest nonce from the actor found in the
+// outbound queue or the message pool, which may hold messages from the actor sent by another
+// node with the same keys.
+func nextNonce(ctx context.Context, act *actor.Actor, outbox *core.MessageQueue, inbox *core.MessagePool, address address.Address) (uint64, error) {
 	actorNonce, err := actor.NextNonce(act)
 	if err != nil {
 		return 0, err
 	}
 
-	poolNonce, found := outbox.LargestNonce(address)
-	if found && poolNonce >= actorNonce {
-		return poolNonce + 1, nil
+	nonce := actorNonce
+	if queueNonce, found := outbox.LargestNonce(address); found && queueNonce >= nonce {
+		nonce = queueNonce + 1
+	}
+	poolNonce, err := inbox.NextNonce(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	if poolNonce > nonce {
+		nonce = poolNonce
 	}
-	return actorNonce, nil
+	return nonce, nil
 }
@@ -66,6 +66,36 @@ func TestSend(t *testing.T) {
 		assert.True(t, publishCalled)
 	})
 
+	t.Run("send signed message enqueues and calls publish", func(t *testing.T) {
+		w, chainStore, cst := setupSendTest(t)
+		addr := w.Addresses()[0]
+		toAddr := address.NewForTestGetter()()
+		timer := testhelpers.NewTestMessagePoolAPI(1000)
+		queue := core.NewMessageQueue()
+		pool := core.NewMessagePool(timer, config.NewDefaultConfig().Mpool, testhelpers.NewMockMessagePoolValidator())
+
+		var published []byte
+		publish := func(topic string, data []byte) error {
+			published = data
+			return nil
+		}
+
+		smsg, err := types.NewSignedMessage(*types.NewMessage(addr, toAddr, 0, types.NewZeroAttoFIL(), "", nil), w, types.NewGasPrice(0), types.NewGasUnits(0))
+		require.NoError(t, err)
+		smsgdata, err := smsg.Marshal()
+		require.NoError(t, err)
+
+		s := NewSender(w, chainStore, cst, timer, queue, pool, nullValidator{}, publish)
+		c, err := s.SendSigned(context.Background(), smsg)
+		require.NoError(t, err)
+		expected, err := smsg.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, expected, c)
+		assert.Equal(t, smsg, queue.List(addr)[0].Msg)
+		assert.Equal(t, 1, len(pool.Pending()))
+		assert.Equal(t, smsgdata, published)
+	})
+
 	t.Run("send message avoids nonce race", func(t *testing.T) {
 		ctx := context.Background()
 
@@ -119,12 +149,17 @@ func TestSend(t *testing.T) {
 func TestNextNonce(t *testing.T) {
 	tf.UnitTest(t)
 
+	ctx := context.Background()
+	newPool := func() *core.MessagePool {
+		return core.NewMessagePool(testhelpers.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, testhelpers.NewMockMessagePoolValidator())
+	}
+
 	t.Run("account exists but wrong type", func(t *testing.T) {
 		address := address.NewForTestGetter()()
 		actor, err := storagemarket.NewActor()
 		assert.NoError(t, err)
 
-		_, err = nextNonce(actor, core.NewMessageQueue(), address)
+		_, err = nextNonce(ctx, actor, core.NewMessageQueue(), newPool(), address)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "account or empty")
 	})
@@ -135,19 +170,20 @@ func TestNextNonce(t *testing.T) {
 		assert.NoError(t, err)
 		actor.Nonce = 42
 
-		nonce, err := nextNonce(actor, core.NewMessageQueue(), address)
+		nonce, err := nextNonce(ctx, actor, core.NewMessageQueue(), newPool(), address)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(42), nonce)
 	})
 
 	t.Run("gets nonce from highest message queue value", func(t *testing.T) {
 		outbox := core.NewMessageQueue()
+		inbox := newPool()
 		addr := mockSigner.Addresses[0]
 		actor, err := account.NewActor(types.NewAttoFILFromFIL(0))
 		assert.NoError(t, err)
 		actor.Nonce = 2
 
-		nonce, err := nextNonce(actor, outbox, addr)
+		nonce, err := nextNonce(ctx, actor, outbox, inbox, addr)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(2), nonce)
 
@@ -155,7 +191,7 @@ func TestNextNonce(t *testing.T) {
 		smsg := testhelpers.MustSign(mockSigner, msg)
 		core.MustEnqueue(outbox, 100, smsg...)
 
-		nonce, err = nextNonce(actor, outbox, addr)
+		nonce, err = nextNonce(ctx, actor, outbox, inbox, addr)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(3), nonce)
 
@@ -163,10 +199,26 @@ func TestNextNonce(t *testing.T) {
 		smsg = testhelpers.MustSign(mockSigner, msg)
 		core.MustEnqueue(outbox, 100, smsg...)
 
-		nonce, err = nextNonce(actor, outbox, addr)
+		nonce, err = nextNonce(ctx, actor, outbox, inbox, addr)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(4), nonce)
 	})
+
+	t.Run("gets nonce from highest message pool value", func(t *testing.T) {
+		inbox := newPool()
+		addr := mockSigner.Addresses[0]
+		actor, err := account.NewActor(types.NewAttoFILFromFIL(0))
+		assert.NoError(t, err)
+		actor.Nonce = 2
+
+		// A message from the same keys sent by another node.
+		msg := types.NewMessage(addr, address.TestAddress, 5, nil, "", []byte{})
+		core.MustAdd(inbox, testhelpers.MustSign(mockSigner, msg)...)
+
+		nonce, err := nextNonce(ctx, actor, core.NewMessageQueue(), inbox, addr)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(6), nonce)
+	})
 }
 
 type nullValidator struct {
@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/sampling"
@@ -24,8 +25,10 @@ var log = logging.Logger("messageimpl")
 
 // Abstracts over a store of blockchain state.
 type waiterChainReader interface {
+	GetAddressMessages(addr address.Address) ([]chain.AddressMessage, error)
 	GetBlock(context.Context, cid.Cid) (*types.Block, error)
 	GetHead() types.SortedCidSet
+	GetMessageLocation(msgCid cid.Cid) (*chain.MessageLocation, bool, error)
 	GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
 	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
 	HeadEvents() *pubsub.PubSub
@@ -55,7 +58,21 @@ func NewWaiter(chainStore waiterChainReader, bs bstore.Blockstore, cst *hamt.Cbo
 }
 
 // Find searches the blockchain history for a message (but doesn't wait).
+// The chain's message index is consulted first; the chain is only traversed
+// if the index is not up to date with the head.
 func (w *Waiter) Find(ctx context.Context, msgCid cid.Cid) (*ChainMessage, bool, error) {
+	loc, found, err := w.chainReader.GetMessageLocation(msgCid)
+	if err == nil {
+		if !found {
+			return nil, false, nil
+		}
+		return w.messageAtLocation(ctx, loc, msgCid)
+	}
+	if err != chain.ErrMessageIndexStale {
+		return nil, false, err
+	}
+	log.Debugf("message index stale, searching chain for %s", msgCid)
+
 	headTipSet, err := w.chainReader.GetTipSet(w.chainReader.GetHead())
 	if err != nil {
 		return nil, false, err
@@ -63,6 +80,51 @@ func (w *Waiter) Find(ctx context.Context, msgCid cid.Cid) (*ChainMessage, bool,
 	return w.findMessage(ctx, headTipSet, msgCid)
 }
 
+// Receipt returns the receipt of a message that is on chain.  The receipt is
+// nil if the message was included but not applied, e.g. because it
+// conflicted with another message of its tipset.
+func (w *Waiter) Receipt(ctx context.Context, msgCid cid.Cid) (*types.MessageReceipt, error) {
+	chainMsg, found, err := w.Find(ctx, msgCid)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("message %s not found on chain", msgCid)
+	}
+	return chainMsg.Receipt, nil
+}
+
+// messageAtLocation reads the message with msgCid from the tipset recorded by
+// the message index, with the receipt the index recorded if any.
+func (w *Waiter) messageAtLocation(ctx context.Context, loc *chain.MessageLocation, msgCid cid.Cid) (*ChainMessage, bool, error) {
+	ts, err := w.chainReader.GetTipSet(loc.TipSet)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to load indexed tipset %s", loc.TipSet.String())
+	}
+	blk, ok := (*ts)[loc.BlockCid]
+	if !ok {
+		return nil, false, fmt.Errorf("indexed block %s not in tipset %s", loc.BlockCid, loc.TipSet.String())
+	}
+	for _, msg := range blk.Messages {
+		c, err := msg.Cid()
+		if err != nil {
+			return nil, false, err
+		}
+		if !c.Equals(msgCid) {
+			continue
+		}
+		if loc.HasReceipt {
+			return &ChainMessage{msg, blk, loc.Receipt}, true, nil
+		}
+		recpt, err := w.receiptFromTipSet(ctx, msgCid, *ts)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "error retrieving receipt from tipset")
+		}
+		return &ChainMessage{msg, blk, recpt}, true, nil
+	}
+	return nil, false, fmt.Errorf("indexed block %s does not contain message %s", loc.BlockCid, msgCid)
+}
+
 // Wait invokes the callback when a message with the given cid appears on chain.
 // See api description.
 //
@@ -71,10 +133,6 @@ func (w *Waiter) Find(ctx context.Context, msgCid cid.Cid) (*ChainMessage, bool,
 // if in fact that's what it wants to do, using something like receiptFromTipset.
 // Something like receiptFromTipset is necessary because not every message in
 // a block will have a receipt in the tipset: it might be a duplicate message.
-//
-// TODO: This implementation will become prohibitively expensive since it
-// traverses the entire chain. We should use an index instead.
-// https://github.com/filecoin-project/go-filecoin/issues/1518
 func (w *Waiter) Wait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error {
 	ctx = log.Start(ctx, "Waiter.Wait")
 	defer log.Finish(ctx)
@@ -171,24 +229,23 @@ func (w *Waiter) waitForMessage(ctx context.Context, ch <-chan interface{}, msgC
 // receiptFromTipSet finds the receipt for the message with msgCid in the
 // input tipset.  This can differ from the message's receipt as stored in its
 // parent block in the case that the message is in conflict with another
-// message of the tipset.
+// message of the tipset, in which case there is no receipt.
 func (w *Waiter) receiptFromTipSet(ctx context.Context, msgCid cid.Cid, ts types.TipSet) (*types.MessageReceipt, error) {
+	receipts, err := w.TipSetReceipts(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	return receipts[msgCid], nil
+}
+
+// TipSetReceipts returns the receipts of the messages applied by ts, keyed by
+// message cid.  Messages of ts that were not applied, e.g. because they
+// conflicted with another message of the tipset, have no receipt.  The chain
+// store's message index records them so that lookups don't recompute them.
+func (w *Waiter) TipSetReceipts(ctx context.Context, ts types.TipSet) (map[cid.Cid]*types.MessageReceipt, error) {
 	// Receipts always match block if tipset has only 1 member.
-	var rcpt *types.MessageReceipt
-	blks := ts.ToSlice()
 	if len(ts) == 1 {
-		b := blks[0]
-		// TODO: this should return an error if a receipt doesn't exist.
-		// Right now doing so breaks tests because our test helpers
-		// don't correctly apply messages when making test chains.
-		j, err := msgIndexOfTipSet(msgCid, ts, types.SortedCidSet{})
-		if err != nil {
-			return nil, err
-		}
-		if j < len(b.MessageReceipts) {
-			rcpt = b.MessageReceipts[j]
-		}
-		return rcpt, nil
+		return blockReceipts(ts)
 	}
 
 	// Apply all the tipset's messages to determine the correct receipts.
@@ -197,6 +254,11 @@ func (w *Waiter) receiptFromTipSet(ctx context.Context, msgCid cid.Cid, ts types
 		return nil, err
 	}
 	stateCid, err := w.chainReader.GetTipSetStateRoot(ids)
+	if errors.Cause(err) == chain.ErrStateDeferred {
+		// The tipset is below a checkpoint and can't be run, so trust the
+		// receipts its blocks recorded.
+		return blockReceipts(ts)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -220,55 +282,36 @@ func (w *Waiter) receiptFromTipSet(ctx context.Context, msgCid cid.Cid, ts types
 		return nil, err
 	}
 
-	res, err := consensus.NewDefaultProcessor().ProcessTipSet(ctx, st, vm.NewStorageMap(w.bs), ts, ancestors)
+	res, err := consensus.NewBlockProcessor().ProcessTipSet(ctx, st, vm.NewStorageMap(w.bs), ts, ancestors)
 	if err != nil {
 		return nil, err
 	}
-
-	// If this is a failing conflict message there is no application receipt.
-	if res.Failures.Has(msgCid) {
-		return nil, nil
-	}
-
-	j, err := msgIndexOfTipSet(msgCid, ts, res.Failures)
-	if err != nil {
-		return nil, err
-	}
-	// TODO: out of bounds receipt index should return an error.
-	if j < len(res.Results) {
-		rcpt = res.Results[j].Receipt
-	}
-	return rcpt, nil
+	return consensus.TipSetReceipts(ts, res)
 }
 
-// msgIndexOfTipSet returns the order in which msgCid appears in the canonical
-// message ordering of the given tipset, or an error if it is not in the
-// tipset.
-// TODO: find a better home for this method
-func msgIndexOfTipSet(msgCid cid.Cid, ts types.TipSet, fails types.SortedCidSet) (int, error) {
+// blockReceipts returns the receipts the blocks of ts recorded for their
+// messages, keyed by message cid.  A message included by several blocks gets
+// the receipt of the first of them in canonical order.
+func blockReceipts(ts types.TipSet) (map[cid.Cid]*types.MessageReceipt, error) {
 	blks := ts.ToSlice()
 	types.SortBlocks(blks)
-	var duplicates types.SortedCidSet
-	var msgCnt int
+	receipts := make(map[cid.Cid]*types.MessageReceipt)
 	for _, b := range blks {
-		for _, msg := range b.Messages {
+		for j, msg := range b.Messages {
 			c, err := msg.Cid()
 			if err != nil {
-				return -1, err
+				return nil, err
 			}
-			if fails.Has(c) {
+			if _, ok := receipts[c]; ok {
 				continue
 			}
-			if duplicates.Has(c) {
-				continue
+			// TODO: this should return an error if a receipt doesn't exist.
+			// Right now doing so breaks tests because our test helpers
+			// don't correctly apply messages when making test chains.
+			if j < len(b.MessageReceipts) {
+				receipts[c] = b.MessageReceipts[j]
 			}
-			(&duplicates).Add(c)
-			if c.Equals(msgCid) {
-				return msgCnt, nil
-			}
-			msgCnt++
 		}
 	}
-
-	return -1, fmt.Errorf("message cid %s not in tipset", msgCid.String())
+	return receipts, nil
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-hamt-ipld"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -213,6 +214,40 @@ func TestWaitConflicting(t *testing.T) {
 	testWaitHelp(nil, t, waiter, sm2, false, msgApplyFail)
 }
 
+func TestFindUsesIndexedReceipts(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, chainStore, waiter := setupTest(t)
+
+	m1 := newSignedMessage()
+	c1, err := m1.Cid()
+	require.NoError(t, err)
+	rcpt := &types.MessageReceipt{ExitCode: 3}
+	runs := 0
+	chainStore.SetMessageReceipts(func(ctx context.Context, ts types.TipSet) (map[cid.Cid]*types.MessageReceipt, error) {
+		runs++
+		return map[cid.Cid]*types.MessageReceipt{c1: rcpt}, nil
+	})
+
+	headTipSet, err := chainStore.GetTipSet(chainStore.GetHead())
+	require.NoError(t, err)
+	chainWithMsgs := core.NewChainWithMessages(cst, *headTipSet, smsgsSet{smsgs{m1}})
+	ts := chainWithMsgs[len(chainWithMsgs)-1]
+	th.RequirePutTsas(ctx, t, chainStore, &chain.TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: ts.ToSlice()[0].StateRoot,
+	})
+	require.NoError(t, chainStore.SetHead(ctx, ts))
+	indexRuns := runs
+
+	chainMsg, found, err := waiter.Find(ctx, c1)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, rcpt, chainMsg.Receipt)
+	assert.Equal(t, indexRuns, runs)
+}
+
 func TestWaitRespectsContextCancel(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -241,3 +276,54 @@ func TestWaitRespectsContextCancel(t *testing.T) {
 		assert.Fail(t, "Wait should have returned when context was canceled")
 	}
 }
+
+func TestHistory(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, chainStore, waiter := setupTest(t)
+
+	m1, m2, m3 := newSignedMessage(), newSignedMessage(), newSignedMessage()
+	head := chainStore.GetHead()
+	headTipSet, err := chainStore.GetTipSet(head)
+	require.NoError(t, err)
+	chainWithMsgs := core.NewChainWithMessages(cst, *headTipSet, smsgsSet{smsgs{m1, m2}}, smsgsSet{smsgs{m3}})
+	for _, ts := range chainWithMsgs[1:] {
+		th.RequirePutTsas(ctx, t, chainStore, &chain.TipSetAndState{
+			TipSet:          ts,
+			TipSetStateRoot: ts.ToSlice()[0].StateRoot,
+		})
+	}
+	require.NoError(t, chainStore.SetHead(ctx, chainWithMsgs[len(chainWithMsgs)-1]))
+
+	c3, err := m3.Cid()
+	require.NoError(t, err)
+
+	t.Run("returns messages sent newest first", func(t *testing.T) {
+		entries, err := waiter.History(ctx, m1.From, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, c3, entries[0].Cid)
+		assert.True(t, entries[0].Sent)
+		assert.True(t, entries[0].Height > entries[2].Height)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		entries, err := waiter.History(ctx, m1.From, 1, 1)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.NotEqual(t, c3, entries[0].Cid)
+
+		entries, err = waiter.History(ctx, m1.From, 3, 0)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("returns messages received", func(t *testing.T) {
+		entries, err := waiter.History(ctx, m3.To, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, c3, entries[0].Cid)
+		assert.False(t, entries[0].Sent)
+	})
+}
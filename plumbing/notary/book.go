@@ -0,0 +1,52 @@
+package notary
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+)
+
+// Book keeps the latest attestation received from each notary.
+type Book struct {
+	lk           sync.Mutex
+	attestations map[address.Address]*Attestation
+}
+
+// NewBook returns an empty Book.
+func NewBook() *Book {
+	return &Book{attestations: make(map[address.Address]*Attestation)}
+}
+
+// Add verifies att and keeps it if it is newer than the attestation kept for
+// its notary.  Older attestations are ignored, so that a replayed
+// attestation can't hide a notary's current head.
+func (b *Book) Add(att *Attestation) error {
+	if err := att.Verify(); err != nil {
+		return err
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if prev, ok := b.attestations[att.Notary]; ok && prev.Timestamp >= att.Timestamp {
+		return errors.Errorf("attestation by %s is not newer than the last one received", att.Notary)
+	}
+	b.attestations[att.Notary] = att
+	return nil
+}
+
+// List returns the attestations kept, ordered by notary address.
+func (b *Book) List() []*Attestation {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	atts := make([]*Attestation, 0, len(b.attestations))
+	for _, att := range b.attestations {
+		atts = append(atts, att)
+	}
+	sort.Slice(atts, func(i, j int) bool {
+		return atts[i].Notary.String() < atts[j].Notary.String()
+	})
+	return atts
+}
@@ -0,0 +1,136 @@
+package notary
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func init() {
+	cbor.RegisterCborType(Attestation{})
+}
+
+// Topic is the pubsub topic attestations are published on.
+const Topic = "/fil/attestations"
+
+// Attestation is a notary's signed statement of the head of its chain at a
+// point in time.  Consumers of a node's chain can cross-check it against
+// attestations from other notaries to detect a compromised or forked node.
+type Attestation struct {
+	Notary    address.Address    `json:"notary"`
+	Height    uint64             `json:"height"`
+	TipSet    types.SortedCidSet `json:"tipSet"`
+	StateRoot cid.Cid            `json:"stateRoot"`
+	// Timestamp is the unix time at which the attestation was made.
+	Timestamp uint64          `json:"timestamp"`
+	Signature types.Signature `json:"signature"`
+}
+
+// signedBytes returns the bytes the notary signs: the attestation encoded
+// without its signature.
+func (a *Attestation) signedBytes() ([]byte, error) {
+	unsigned := *a
+	unsigned.Signature = nil
+	return cbor.DumpObject(unsigned)
+}
+
+// Marshal returns the cbor encoding of the attestation.
+func (a *Attestation) Marshal() ([]byte, error) {
+	return cbor.DumpObject(a)
+}
+
+// Unmarshal decodes an attestation from its cbor encoding.
+func (a *Attestation) Unmarshal(b []byte) error {
+	return cbor.DecodeInto(b, a)
+}
+
+// Verify returns an error if the attestation isn't signed by its notary.
+func (a *Attestation) Verify() error {
+	data, err := a.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !types.IsValidSignature(data, a.Notary, a.Signature) {
+		return errors.Errorf("invalid signature on attestation by %s", a.Notary)
+	}
+	return nil
+}
+
+// Abstracts over a store of blockchain state.
+type notaryChainReader interface {
+	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+}
+
+// Notary signs attestations of chain heads with a dedicated key and keeps
+// the latest one.
+type Notary struct {
+	address     address.Address
+	signer      types.Signer
+	chainReader notaryChainReader
+
+	lk     sync.Mutex
+	latest *Attestation
+}
+
+// NewNotary returns a Notary signing attestations with the key of addr.
+func NewNotary(addr address.Address, signer types.Signer, chainReader notaryChainReader) *Notary {
+	return &Notary{
+		address:     addr,
+		signer:      signer,
+		chainReader: chainReader,
+	}
+}
+
+// Address returns the address whose key signs the notary's attestations.
+func (n *Notary) Address() address.Address {
+	return n.address
+}
+
+// Attest signs an attestation of head and its state root, and keeps it as
+// the latest attestation.
+func (n *Notary) Attest(ctx context.Context, head types.TipSet) (*Attestation, error) {
+	height, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	tsKey := head.ToSortedCidSet()
+	stateRoot, err := n.chainReader.GetTipSetStateRoot(tsKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get state root of %s", tsKey)
+	}
+
+	att := &Attestation{
+		Notary:    n.address,
+		Height:    height,
+		TipSet:    tsKey,
+		StateRoot: stateRoot,
+		Timestamp: uint64(time.Now().Unix()),
+	}
+	data, err := att.signedBytes()
+	if err != nil {
+		return nil, err
+	}
+	att.Signature, err = n.signer.SignBytes(data, n.address)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign attestation")
+	}
+
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	n.latest = att
+	return att, nil
+}
+
+// Latest returns the latest attestation signed, or nil if there is none.
+func (n *Notary) Latest() *Attestation {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	return n.latest
+}
@@ -0,0 +1,93 @@
+package notary_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/plumbing/notary"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type fakeChainReader struct {
+	stateRoot cid.Cid
+}
+
+func (r *fakeChainReader) GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error) {
+	return r.stateRoot, nil
+}
+
+func TestNotaryAttest(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	stateRoot := types.SomeCid()
+	n := notary.NewNotary(signer.Addresses[0], signer, &fakeChainReader{stateRoot})
+	assert.Nil(t, n.Latest())
+
+	head := types.RequireNewTipSet(t, types.NewBlockForTest(nil, 1))
+	att, err := n.Attest(ctx, head)
+	require.NoError(t, err)
+	assert.Equal(t, att, n.Latest())
+	assert.Equal(t, signer.Addresses[0], att.Notary)
+	assert.Equal(t, head.ToSortedCidSet(), att.TipSet)
+	assert.Equal(t, stateRoot, att.StateRoot)
+	assert.NoError(t, att.Verify())
+
+	t.Run("encoding round trips", func(t *testing.T) {
+		data, err := att.Marshal()
+		require.NoError(t, err)
+		var decoded notary.Attestation
+		require.NoError(t, decoded.Unmarshal(data))
+		assert.NoError(t, decoded.Verify())
+		assert.Equal(t, att.StateRoot, decoded.StateRoot)
+	})
+
+	t.Run("tampered attestations don't verify", func(t *testing.T) {
+		tampered := *att
+		tampered.StateRoot = head.ToSlice()[0].Cid()
+		assert.Error(t, tampered.Verify())
+
+		impostor := *att
+		impostor.Notary = signer.Addresses[1]
+		assert.Error(t, impostor.Verify())
+	})
+}
+
+func TestBook(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	n0 := notary.NewNotary(signer.Addresses[0], signer, &fakeChainReader{types.SomeCid()})
+	n1 := notary.NewNotary(signer.Addresses[1], signer, &fakeChainReader{types.SomeCid()})
+	head := types.RequireNewTipSet(t, types.NewBlockForTest(nil, 1))
+
+	att0, err := n0.Attest(ctx, head)
+	require.NoError(t, err)
+	att1, err := n1.Attest(ctx, head)
+	require.NoError(t, err)
+
+	b := notary.NewBook()
+	require.NoError(t, b.Add(att1))
+	require.NoError(t, b.Add(att0))
+	assert.Equal(t, 2, len(b.List()))
+
+	// Replays are ignored.
+	assert.Error(t, b.Add(att0))
+
+	// Forged attestations are rejected.
+	forged := *att0
+	forged.Timestamp++
+	assert.Error(t, b.Add(&forged))
+	for _, att := range b.List() {
+		if att.Notary == att0.Notary {
+			assert.Equal(t, att0, att)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Package powerevents reports the changes in the power of miners from one
+// tipset to the next.
+package powerevents
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("powerevents")
+
+// SubscriptionBuffer is the number of changes buffered for each subscriber.
+// Changes are dropped for subscribers whose buffer is full.
+const SubscriptionBuffer = 256
+
+// Kind is the kind of a power change.
+type Kind string
+
+const (
+	// MinerAdded is the kind of change for a miner created in the child
+	// tipset.
+	MinerAdded = Kind("added")
+	// PowerIncreased is the kind of change for a miner whose power grew.
+	PowerIncreased = Kind("increased")
+	// PowerDecreased is the kind of change for a miner whose power shrank
+	// but is still positive.
+	PowerDecreased = Kind("decreased")
+	// MinerSlashed is the kind of change for a miner that lost all its
+	// power or was removed, which only happens when a miner is slashed.
+	MinerSlashed = Kind("slashed")
+)
+
+// Change is a change in the power of a miner between a parent tipset and
+// its child.
+type Change struct {
+	Kind  Kind            `json:"kind"`
+	Miner address.Address `json:"miner"`
+	// Height and TipSet identify the child tipset.
+	Height uint64             `json:"height"`
+	TipSet types.SortedCidSet `json:"tipSet"`
+	// Before is nil for an added miner, and After is nil for a removed one.
+	Before *types.BytesAmount `json:"before"`
+	After  *types.BytesAmount `json:"after"`
+}
+
+// Abstracts over a store of blockchain state.
+type streamChainReader interface {
+	GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
+	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+}
+
+// Stream diffs the power table of each new head against its parent's and
+// sends the changes to its subscribers, so that consumers can follow power
+// incrementally rather than re-reading the whole table.
+type Stream struct {
+	chainReader streamChainReader
+	cst         *hamt.CborIpldStore
+	bs          bstore.Blockstore
+	view        consensus.PowerTableView
+
+	mu     sync.Mutex
+	subs   map[int]chan *Change
+	nextID int
+}
+
+// NewStream returns a Stream reading power from the state with view.
+func NewStream(chainReader streamChainReader, cst *hamt.CborIpldStore, bs bstore.Blockstore, view consensus.PowerTableView) *Stream {
+	return &Stream{
+		chainReader: chainReader,
+		cst:         cst,
+		bs:          bs,
+		view:        view,
+		subs:        make(map[int]chan *Change),
+	}
+}
+
+// Changes returns the changes in power between the tipset with key tsKey
+// and its parent, ordered by miner address.
+func (s *Stream) Changes(ctx context.Context, tsKey types.SortedCidSet) ([]*Change, error) {
+	ts, err := s.chainReader.GetTipSet(tsKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load tipset %s", tsKey)
+	}
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return nil, err
+	}
+
+	parentPower := make(map[address.Address]*types.BytesAmount)
+	if parentKey.Len() > 0 {
+		if parentPower, err = s.minerPower(ctx, parentKey); err != nil {
+			return nil, err
+		}
+	}
+	childPower, err := s.minerPower(ctx, tsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Diff(parentPower, childPower)
+	for _, c := range changes {
+		c.Height = height
+		c.TipSet = tsKey
+	}
+	return changes, nil
+}
+
+// minerPower returns the power of each miner in the state of the tipset with
+// key tsKey.
+func (s *Stream) minerPower(ctx context.Context, tsKey types.SortedCidSet) (map[address.Address]*types.BytesAmount, error) {
+	root, err := s.chainReader.GetTipSetStateRoot(tsKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get state root of %s", tsKey)
+	}
+	st, err := state.LoadStateTree(ctx, s.cst, root, builtin.Actors)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state of %s", tsKey)
+	}
+
+	var miners []address.Address
+	err = st.ForEachActor(ctx, func(a address.Address, act *actor.Actor) error {
+		if act.Code.Equals(types.MinerActorCodeCid) {
+			miners = append(miners, a)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// State trees are not safe for concurrent reads, so miners are queried
+	// one at a time.
+	power := make(map[address.Address]*types.BytesAmount, len(miners))
+	for _, m := range miners {
+		p, err := s.view.Miner(ctx, st, s.bs, m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get power of miner %s", m)
+		}
+		power[m] = p
+	}
+	return power, nil
+}
+
+// Diff returns the changes from the power of each miner in parent to its
+// power in child, ordered by miner address.
+func Diff(parent, child map[address.Address]*types.BytesAmount) []*Change {
+	var changes []*Change
+	for m, after := range child {
+		before, ok := parent[m]
+		switch {
+		case !ok:
+			changes = append(changes, &Change{Kind: MinerAdded, Miner: m, After: after})
+		case after.GreaterThan(before):
+			changes = append(changes, &Change{Kind: PowerIncreased, Miner: m, Before: before, After: after})
+		case after.LessThan(before) && after.IsPositive():
+			changes = append(changes, &Change{Kind: PowerDecreased, Miner: m, Before: before, After: after})
+		case after.LessThan(before):
+			changes = append(changes, &Change{Kind: MinerSlashed, Miner: m, Before: before, After: after})
+		}
+	}
+	for m, before := range parent {
+		if _, ok := child[m]; !ok {
+			changes = append(changes, &Change{Kind: MinerSlashed, Miner: m, Before: before})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Miner.String() < changes[j].Miner.String()
+	})
+	return changes
+}
+
+// HandleNewHead sends the changes in power between head and its parent to
+// the subscribers.  Only heads that have subscribers are diffed.
+func (s *Stream) HandleNewHead(ctx context.Context, head types.TipSet) error {
+	s.mu.Lock()
+	subscribed := len(s.subs) > 0
+	s.mu.Unlock()
+	if !subscribed {
+		return nil
+	}
+
+	changes, err := s.Changes(ctx, head.ToSortedCidSet())
+	if errors.Cause(err) == chain.ErrStateDeferred {
+		// The head is a checkpoint, whose parent has no state to diff with.
+		log.Debugf("skipping power changes of %s: %s", head.String(), err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range changes {
+		for id, ch := range s.subs {
+			select {
+			case ch <- c:
+			default:
+				log.Warningf("dropped power change of miner %s for slow subscriber %d", c.Miner, id)
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving the changes in power of each new
+// head, which is closed when ctx is done.
+func (s *Stream) Subscribe(ctx context.Context) <-chan *Change {
+	ch := make(chan *Change, SubscriptionBuffer)
+
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.subs[id] = ch
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subs, id)
+		close(ch)
+	}()
+	return ch
+}
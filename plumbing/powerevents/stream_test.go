@@ -0,0 +1,55 @@
+package powerevents_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/powerevents"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestDiff(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	unchanged, grown, shrunk, emptied, removed, added := newAddress(), newAddress(), newAddress(), newAddress(), newAddress(), newAddress()
+
+	parent := map[address.Address]*types.BytesAmount{
+		unchanged: types.NewBytesAmount(10),
+		grown:     types.NewBytesAmount(10),
+		shrunk:    types.NewBytesAmount(10),
+		emptied:   types.NewBytesAmount(10),
+		removed:   types.NewBytesAmount(10),
+	}
+	child := map[address.Address]*types.BytesAmount{
+		unchanged: types.NewBytesAmount(10),
+		grown:     types.NewBytesAmount(20),
+		shrunk:    types.NewBytesAmount(5),
+		emptied:   types.NewBytesAmount(0),
+		added:     types.NewBytesAmount(0),
+	}
+
+	changes := powerevents.Diff(parent, child)
+	require.Len(t, changes, 5)
+	for i := 1; i < len(changes); i++ {
+		assert.True(t, changes[i-1].Miner.String() < changes[i].Miner.String())
+	}
+
+	byMiner := make(map[address.Address]*powerevents.Change)
+	for _, c := range changes {
+		byMiner[c.Miner] = c
+	}
+	assert.NotContains(t, byMiner, unchanged)
+	assert.Equal(t, powerevents.PowerIncreased, byMiner[grown].Kind)
+	assert.Equal(t, types.NewBytesAmount(20), byMiner[grown].After)
+	assert.Equal(t, powerevents.PowerDecreased, byMiner[shrunk].Kind)
+	assert.Equal(t, powerevents.MinerSlashed, byMiner[emptied].Kind)
+	assert.Equal(t, powerevents.MinerSlashed, byMiner[removed].Kind)
+	assert.Nil(t, byMiner[removed].After)
+	assert.Equal(t, powerevents.MinerAdded, byMiner[added].Kind)
+	assert.Nil(t, byMiner[added].Before)
+}
@@ -0,0 +1,94 @@
+package upgrade
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/sampling"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Abstracts over a store of blockchain state.
+type dryRunnerChainReader interface {
+	GetBlock(context.Context, cid.Cid) (*types.Block, error)
+	GetHead() types.SortedCidSet
+	GetTipSet(tsKey types.SortedCidSet) (*types.TipSet, error)
+	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+}
+
+// DryRunner rehearses registered protocol upgrades against the local chain.
+type DryRunner struct {
+	chainReader dryRunnerChainReader
+	cst         *hamt.CborIpldStore
+	bs          bstore.Blockstore
+}
+
+// NewDryRunner returns a new DryRunner.
+func NewDryRunner(chainReader dryRunnerChainReader, cst *hamt.CborIpldStore, bs bstore.Blockstore) *DryRunner {
+	return &DryRunner{
+		chainReader: chainReader,
+		cst:         cst,
+		bs:          bs,
+	}
+}
+
+// Run applies the named upgrade's migration to the state before the tipset at
+// fromHeight and processes the tipsets of the heaviest chain from fromHeight
+// to toHeight under both the current and the upgraded rules, reporting
+// messages whose outcome differs.  A toHeight of zero means the head.
+func (dr *DryRunner) Run(ctx context.Context, name string, fromHeight, toHeight uint64) (*consensus.UpgradeDryRunReport, error) {
+	upgrade, ok := consensus.LookupUpgrade(name)
+	if !ok {
+		return nil, errors.Errorf("unknown upgrade %q, known upgrades: %v", name, consensus.UpgradeNames())
+	}
+	if fromHeight == 0 {
+		return nil, errors.New("the genesis tipset cannot be processed, start from height 1 or above")
+	}
+
+	head, err := dr.chainReader.GetTipSet(dr.chainReader.GetHead())
+	if err != nil {
+		return nil, err
+	}
+	if toHeight == 0 {
+		if toHeight, err = head.Height(); err != nil {
+			return nil, err
+		}
+	}
+	tipsets, err := chain.GetTipSetsInRange(ctx, dr.chainReader, *head, fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+	if len(tipsets) == 0 {
+		return nil, errors.Errorf("no tipsets between heights %d and %d", fromHeight, toHeight)
+	}
+
+	parentKey, err := tipsets[0].Parents()
+	if err != nil {
+		return nil, err
+	}
+	parentState, err := dr.chainReader.GetTipSetStateRoot(parentKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent state root")
+	}
+
+	ancestors := func(ts types.TipSet) ([]types.TipSet, error) {
+		parent, err := chain.GetParentTipSet(ctx, dr.chainReader, ts)
+		if err != nil {
+			return nil, err
+		}
+		h, err := ts.Height()
+		if err != nil {
+			return nil, err
+		}
+		ancestorHeight := types.NewBlockHeight(consensus.AncestorRoundsNeeded)
+		return chain.GetRecentAncestors(ctx, parent, dr.chainReader, types.NewBlockHeight(h), ancestorHeight, sampling.LookbackParameter)
+	}
+
+	return consensus.DryRunUpgrade(ctx, upgrade, consensus.NewBlockProcessor(), dr.cst, dr.bs, parentState, tipsets, ancestors)
+}
@@ -0,0 +1,114 @@
+package upgrade_test
+
+import (
+	"context"
+	"testing"
+
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/plumbing/upgrade"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+func TestDryRunner(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(4)
+	fromAddr, toAddr := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	minerAddr, minerOwner := mockSigner.Addresses[2], mockSigner.Addresses[3]
+
+	r := repo.NewInMemoryRepo()
+	bs := bstore.NewBlockstore(r.Datastore())
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
+	chainStore, err := chain.Init(ctx, r, bs, cst, consensus.MakeGenesisFunc(
+		consensus.ActorAccount(fromAddr, types.NewAttoFILFromFIL(1000)),
+		consensus.ActorAccount(minerOwner, types.ZeroAttoFIL),
+		consensus.MinerActor(minerAddr, minerOwner, []byte{}, 1000, th.RequireRandomPeerID(t), types.ZeroAttoFIL, types.OneKiBSectorSize),
+	))
+	require.NoError(t, err)
+
+	// The upgrade's migration drains the sender of the chain's only message,
+	// so the message can no longer be applied under the upgraded rules.
+	migrated := false
+	require.NoError(t, consensus.RegisterUpgrade(&consensus.Upgrade{
+		Name: "dry-runner-test",
+		Migrate: func(ctx context.Context, st state.Tree, vms vm.StorageMap) error {
+			migrated = true
+			return st.SetActor(ctx, fromAddr, th.RequireNewAccountActor(t, types.ZeroAttoFIL))
+		},
+		Processor: consensus.NewDefaultProcessor(),
+	}))
+
+	msg := types.NewMessage(fromAddr, toAddr, 0, types.NewAttoFILFromFIL(100), "", nil)
+	smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(300))
+	require.NoError(t, err)
+	msgCid, err := smsg.Cid()
+	require.NoError(t, err)
+
+	genesis, err := chainStore.GetTipSet(chainStore.GetHead())
+	require.NoError(t, err)
+	genesisRoot := genesis.ToSlice()[0].StateRoot
+	blk := th.RequireMkFakeChild(t, th.FakeChildParams{
+		MinerAddr:  minerAddr,
+		Parent:     *genesis,
+		GenesisCid: chainStore.GenesisCid(),
+		StateRoot:  genesisRoot,
+		Signer:     mockSigner,
+	})
+	blk.Messages = []*types.SignedMessage{smsg}
+	_, err = cst.Put(ctx, blk)
+	require.NoError(t, err)
+	ts := th.RequireNewTipSet(t, blk)
+	th.RequirePutTsas(ctx, t, chainStore, &chain.TipSetAndState{TipSet: ts, TipSetStateRoot: genesisRoot})
+	require.NoError(t, chainStore.SetHead(ctx, ts))
+
+	dr := upgrade.NewDryRunner(chainStore, cst, bs)
+
+	t.Run("reports the messages the migration changes the outcome of", func(t *testing.T) {
+		report, err := dr.Run(ctx, "dry-runner-test", 1, 0)
+		require.NoError(t, err)
+		assert.True(t, migrated)
+		assert.Equal(t, "dry-runner-test", report.Upgrade)
+		assert.Equal(t, 1, report.TipSets)
+		assert.Equal(t, 1, report.Messages)
+
+		require.Len(t, report.Divergences, 1)
+		div := report.Divergences[0]
+		assert.Equal(t, msgCid, div.Message)
+		assert.Equal(t, uint64(1), div.Height)
+		require.NotNil(t, div.Current)
+		assert.Equal(t, uint8(0), div.Current.ExitCode)
+		assert.Nil(t, div.Upgraded)
+	})
+
+	t.Run("leaves the chain's state untouched", func(t *testing.T) {
+		st, err := state.LoadStateTree(ctx, cst, genesisRoot, nil)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewAttoFILFromFIL(1000), state.MustGetActor(st, fromAddr).Balance)
+		assert.True(t, chainStore.GetHead().Equals(ts.ToSortedCidSet()))
+	})
+
+	t.Run("validates its arguments", func(t *testing.T) {
+		_, err := dr.Run(ctx, "unknown", 1, 0)
+		assert.Error(t, err)
+		_, err = dr.Run(ctx, "dry-runner-test", 0, 0)
+		assert.Error(t, err)
+		_, err = dr.Run(ctx, "dry-runner-test", 2, 1)
+		assert.Error(t, err)
+		_, err = dr.Run(ctx, "dry-runner-test", 5, 6)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,228 @@
+package walletnotify
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("walletnotify")
+
+// MaxCatchUp is the number of tipsets a Notifier looks back at most when a
+// new head is far ahead of the last one it saw, e.g. after a sync.
+const MaxCatchUp = 100
+
+// EventType is the kind of a wallet event.
+type EventType string
+
+const (
+	// Received is the type of event for a message transferring funds to a
+	// wallet address being mined.
+	Received = EventType("received")
+	// Mined is the type of event for a message sent from a wallet address
+	// being mined successfully.
+	Mined = EventType("mined")
+	// Failed is the type of event for a message sent from a wallet address
+	// being mined with a non-zero exit code.
+	Failed = EventType("failed")
+)
+
+// ParseEventType returns the event type named s.
+func ParseEventType(s string) (EventType, error) {
+	switch t := EventType(s); t {
+	case Received, Mined, Failed:
+		return t, nil
+	default:
+		return "", errors.Errorf("unknown wallet event type %q", s)
+	}
+}
+
+// Event describes a message mined in the chain concerning a wallet address.
+type Event struct {
+	Type EventType `json:"type"`
+	// Address is the wallet address the event concerns.
+	Address  address.Address    `json:"address"`
+	Message  cid.Cid            `json:"message"`
+	From     address.Address    `json:"from"`
+	To       address.Address    `json:"to"`
+	Value    *types.AttoFIL     `json:"value"`
+	Method   string             `json:"method"`
+	ExitCode uint8              `json:"exitCode"`
+	Height   uint64             `json:"height"`
+	Block    cid.Cid            `json:"block"`
+	TipSet   types.SortedCidSet `json:"tipSet"`
+}
+
+// Sink delivers wallet events.
+type Sink interface {
+	Notify(ctx context.Context, event *Event) error
+}
+
+// Abstracts over a store of blockchain state.
+type notifierChainReader interface {
+	GetBlock(context.Context, cid.Cid) (*types.Block, error)
+}
+
+// Abstracts over the node's wallet.
+type notifierWallet interface {
+	HasAddress(a address.Address) bool
+}
+
+// Notifier follows the chain's head and notifies its sinks of the messages
+// mined to or from the wallet's addresses, so that users processing payments
+// don't need to follow the chain themselves.
+type Notifier struct {
+	chainReader notifierChainReader
+	wallet      notifierWallet
+	sinks       []Sink
+	// types is the set of event types to notify, or nil for all of them.
+	types map[EventType]bool
+
+	// lastHeight is the height of the last head handled, valid if started
+	// is true.
+	lastHeight uint64
+	started    bool
+}
+
+// NewNotifier returns a Notifier delivering events of the given types, or of
+// all types if none are given, to sinks.
+func NewNotifier(chainReader notifierChainReader, wallet notifierWallet, eventTypes []EventType, sinks ...Sink) *Notifier {
+	n := &Notifier{
+		chainReader: chainReader,
+		wallet:      wallet,
+		sinks:       sinks,
+	}
+	if len(eventTypes) > 0 {
+		n.types = make(map[EventType]bool)
+		for _, t := range eventTypes {
+			n.types[t] = true
+		}
+	}
+	return n
+}
+
+// HandleNewHead notifies the events of the tipsets from the last head handled
+// up to head, oldest first.  The first head handled, and a head that isn't
+// above the last one, only has its own events notified: messages that were
+// already notified are notified again if a reorg brings them into a new
+// tipset.  It is not safe for concurrent use.
+func (n *Notifier) HandleNewHead(ctx context.Context, head types.TipSet) error {
+	height, err := head.Height()
+	if err != nil {
+		return err
+	}
+	fromHeight := height
+	if n.started && height > n.lastHeight {
+		fromHeight = n.lastHeight + 1
+	}
+	if height-fromHeight >= MaxCatchUp {
+		fromHeight = height - MaxCatchUp + 1
+	}
+
+	var tipsets []types.TipSet
+	for it := chain.IterAncestors(ctx, n.chainReader, head); !it.Complete(); err = it.Next() {
+		if err != nil {
+			return err
+		}
+		h, err := it.Value().Height()
+		if err != nil {
+			return err
+		}
+		if h < fromHeight {
+			break
+		}
+		tipsets = append(tipsets, it.Value())
+	}
+	if err != nil {
+		return err
+	}
+
+	n.lastHeight = height
+	n.started = true
+	for i := len(tipsets) - 1; i >= 0; i-- {
+		events, err := n.Events(tipsets[i])
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			n.notify(ctx, event)
+		}
+	}
+	return nil
+}
+
+// Events returns the events of the messages in ts, in the order of the
+// tipset's blocks and of their messages, filtered by the notifier's types.
+func (n *Notifier) Events(ts types.TipSet) ([]*Event, error) {
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	tsKey := ts.ToSortedCidSet()
+
+	var events []*Event
+	for _, blk := range ts.ToSlice() {
+		if len(blk.MessageReceipts) != len(blk.Messages) {
+			return nil, errors.Errorf("block %s has %d messages but %d receipts", blk.Cid(), len(blk.Messages), len(blk.MessageReceipts))
+		}
+		for i, smsg := range blk.Messages {
+			msg := smsg.Message
+			receipt := blk.MessageReceipts[i]
+
+			var addrs []address.Address
+			var eventTypes []EventType
+			if n.wallet.HasAddress(msg.From) {
+				addrs = append(addrs, msg.From)
+				if receipt.ExitCode == 0 {
+					eventTypes = append(eventTypes, Mined)
+				} else {
+					eventTypes = append(eventTypes, Failed)
+				}
+			}
+			if receipt.ExitCode == 0 && msg.Value != nil && msg.Value.IsPositive() && n.wallet.HasAddress(msg.To) {
+				addrs = append(addrs, msg.To)
+				eventTypes = append(eventTypes, Received)
+			}
+
+			for j, t := range eventTypes {
+				if n.types != nil && !n.types[t] {
+					continue
+				}
+				mcid, err := smsg.Cid()
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, &Event{
+					Type:     t,
+					Address:  addrs[j],
+					Message:  mcid,
+					From:     msg.From,
+					To:       msg.To,
+					Value:    msg.Value,
+					Method:   msg.Method,
+					ExitCode: receipt.ExitCode,
+					Height:   height,
+					Block:    blk.Cid(),
+					TipSet:   tsKey,
+				})
+			}
+		}
+	}
+	return events, nil
+}
+
+// notify delivers event to every sink.  A failing sink doesn't keep the
+// others from being notified.
+func (n *Notifier) notify(ctx context.Context, event *Event) {
+	for _, sink := range n.sinks {
+		if err := sink.Notify(ctx, event); err != nil {
+			log.Warningf("failed to notify %s event for message %s: %s", event.Type, event.Message, err)
+		}
+	}
+}
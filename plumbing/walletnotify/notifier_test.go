@@ -0,0 +1,145 @@
+package walletnotify_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/walletnotify"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestNotifier(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	ms, _ := types.NewMockSignersAndKeyInfo(2)
+	ours, theirs := ms.Addresses[0], ms.Addresses[1]
+	wallet := fakeWallet{ours: true}
+	newMsg := func(from, to address.Address, value uint64) *types.SignedMessage {
+		msg := types.NewMessage(from, to, 0, types.NewAttoFILFromFIL(value), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &ms, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	reader := &fakeChainReader{blocks: make(map[cid.Cid]*types.Block)}
+	genesis := reader.newBlock(nil, 0, nil)
+	sent := newMsg(ours, theirs, 10)
+	received := newMsg(theirs, ours, 5)
+	b1 := reader.newBlock(genesis, 1, []uint8{0, 0, 0},
+		sent,
+		received,
+		newMsg(theirs, ours, 0), // carries no funds
+	)
+	failed := newMsg(ours, theirs, 1)
+	b2 := reader.newBlock(b1, 2, []uint8{1}, failed)
+	head := types.RequireNewTipSet(t, b2)
+
+	t.Run("notifies the wallet's messages", func(t *testing.T) {
+		sink := &recordingSink{}
+		notifier := walletnotify.NewNotifier(reader, wallet, nil, sink)
+
+		require.NoError(t, notifier.HandleNewHead(ctx, types.RequireNewTipSet(t, genesis)))
+		assert.Empty(t, sink.events)
+		require.NoError(t, notifier.HandleNewHead(ctx, head))
+
+		require.Len(t, sink.events, 3)
+		assert.Equal(t, walletnotify.Mined, sink.events[0].Type)
+		assert.Equal(t, ours, sink.events[0].Address)
+		assert.Equal(t, mustCid(t, sent), sink.events[0].Message)
+		assert.Equal(t, uint64(1), sink.events[0].Height)
+
+		assert.Equal(t, walletnotify.Received, sink.events[1].Type)
+		assert.Equal(t, mustCid(t, received), sink.events[1].Message)
+		assert.Equal(t, types.NewAttoFILFromFIL(5), sink.events[1].Value)
+
+		assert.Equal(t, walletnotify.Failed, sink.events[2].Type)
+		assert.Equal(t, mustCid(t, failed), sink.events[2].Message)
+		assert.Equal(t, uint8(1), sink.events[2].ExitCode)
+		assert.Equal(t, uint64(2), sink.events[2].Height)
+	})
+
+	t.Run("starts at the first head", func(t *testing.T) {
+		sink := &recordingSink{}
+		notifier := walletnotify.NewNotifier(reader, wallet, nil, sink)
+
+		require.NoError(t, notifier.HandleNewHead(ctx, head))
+		require.Len(t, sink.events, 1)
+		assert.Equal(t, walletnotify.Failed, sink.events[0].Type)
+	})
+
+	t.Run("filters event types", func(t *testing.T) {
+		sink := &recordingSink{}
+		notifier := walletnotify.NewNotifier(reader, wallet, []walletnotify.EventType{walletnotify.Received}, sink)
+
+		require.NoError(t, notifier.HandleNewHead(ctx, types.RequireNewTipSet(t, b1)))
+		require.Len(t, sink.events, 1)
+		assert.Equal(t, walletnotify.Received, sink.events[0].Type)
+	})
+
+	t.Run("keeps notifying other sinks when one fails", func(t *testing.T) {
+		sink := &recordingSink{}
+		notifier := walletnotify.NewNotifier(reader, wallet, nil, failingSink{}, sink)
+
+		require.NoError(t, notifier.HandleNewHead(ctx, head))
+		assert.Len(t, sink.events, 1)
+	})
+}
+
+func mustCid(t *testing.T, smsg *types.SignedMessage) cid.Cid {
+	c, err := smsg.Cid()
+	require.NoError(t, err)
+	return c
+}
+
+type fakeWallet map[address.Address]bool
+
+func (w fakeWallet) HasAddress(a address.Address) bool {
+	return w[a]
+}
+
+type recordingSink struct {
+	events []*walletnotify.Event
+}
+
+func (s *recordingSink) Notify(ctx context.Context, event *walletnotify.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+type failingSink struct{}
+
+func (failingSink) Notify(ctx context.Context, event *walletnotify.Event) error {
+	return errors.New("unreachable")
+}
+
+type fakeChainReader struct {
+	blocks map[cid.Cid]*types.Block
+}
+
+func (r *fakeChainReader) newBlock(parent *types.Block, height uint64, exitCodes []uint8, msgs ...*types.SignedMessage) *types.Block {
+	blk := &types.Block{Height: types.Uint64(height), Messages: msgs}
+	for _, code := range exitCodes {
+		blk.MessageReceipts = append(blk.MessageReceipts, &types.MessageReceipt{ExitCode: code})
+	}
+	if parent != nil {
+		blk.Parents = types.NewSortedCidSet(parent.Cid())
+	}
+	r.blocks[blk.Cid()] = blk
+	return blk
+}
+
+func (r *fakeChainReader) GetBlock(ctx context.Context, c cid.Cid) (*types.Block, error) {
+	blk, ok := r.blocks[c]
+	if !ok {
+		return nil, errors.Errorf("no block %s", c)
+	}
+	return blk, nil
+}
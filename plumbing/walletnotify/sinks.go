@@ -0,0 +1,86 @@
+package walletnotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// webhookTimeout is how long a webhook has to respond to an event.
+const webhookTimeout = 10 * time.Second
+
+// WebhookSink posts events as JSON to a URL.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns a sink posting events to url.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Notify posts event to the webhook.  Responses other than 2xx are errors.
+func (s *WebhookSink) Notify(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "failed to post to webhook")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// JournalSink appends events to a file, one JSON object per line.
+type JournalSink struct {
+	lk   sync.Mutex
+	path string
+}
+
+// NewJournalSink returns a sink appending events to the file at path, which
+// is created if it doesn't exist.
+func NewJournalSink(path string) *JournalSink {
+	return &JournalSink{path: path}
+}
+
+// Notify appends event to the journal.
+func (s *JournalSink) Notify(ctx context.Context, event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open journal")
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close() // nolint: errcheck
+		return errors.Wrap(err, "failed to write journal")
+	}
+	return f.Close()
+}
@@ -144,6 +144,13 @@ func (a *API) MinerGetPeerID(ctx context.Context, minerAddr address.Address) (pe
 	return MinerGetPeerID(ctx, a, minerAddr)
 }
 
+// MinerGetPower queries for the power of the given miner and the total power
+// of the storage market, in the state of the tipset with key tsKey, or of the
+// head if tsKey is empty.
+func (a *API) MinerGetPower(ctx context.Context, tsKey types.SortedCidSet, minerAddr address.Address) (*MinerPower, error) {
+	return MinerGetPower(ctx, a, tsKey, minerAddr)
+}
+
 // MinerSetPrice configures the price of storage. See implementation for details.
 func (a *API) MinerSetPrice(ctx context.Context, from address.Address, miner address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, price *types.AttoFIL, expiry *big.Int) (MinerSetPriceResponse, error) {
 	return MinerSetPrice(ctx, a, from, miner, gasPrice, gasLimit, price, expiry)
@@ -161,22 +168,79 @@ func (a *API) MinerPreviewSetPrice(
 	return MinerPreviewSetPrice(ctx, a, from, miner, price, expiry)
 }
 
+// MultisigCreate creates a multisig wallet and returns its address
+func (a *API) MultisigCreate(
+	ctx context.Context,
+	from address.Address,
+	gasPrice types.AttoFIL,
+	gasLimit types.GasUnits,
+	signers []address.Address,
+	required uint64,
+	value *types.AttoFIL,
+	unlockDuration *types.BlockHeight,
+) (address.Address, error) {
+	return MultisigCreate(ctx, a, from, gasPrice, gasLimit, signers, required, value, unlockDuration)
+}
+
+// MultisigShow describes the multisig wallet at the given address
+func (a *API) MultisigShow(ctx context.Context, addr address.Address) (*MultisigInfo, error) {
+	return MultisigShow(ctx, a, addr)
+}
+
+// StorageMarketGetState queries for the state of the storage market in the
+// state of the tipset with key tsKey, or of the head if tsKey is empty.
+func (a *API) StorageMarketGetState(ctx context.Context, tsKey types.SortedCidSet) (*StorageMarketState, error) {
+	return StorageMarketGetState(ctx, a, tsKey)
+}
+
 // ProtocolParameters fetches the current protocol configuration parameters.
 func (a *API) ProtocolParameters(ctx context.Context) (*ProtocolParams, error) {
 	return ProtocolParameters(ctx, a)
 }
 
+// RewardShow describes the reward actor
+func (a *API) RewardShow(ctx context.Context) (*RewardInfo, error) {
+	return RewardShow(ctx, a)
+}
+
 // WalletBalance returns the current balance of the given wallet address.
 func (a *API) WalletBalance(ctx context.Context, address address.Address) (*types.AttoFIL, error) {
 	return WalletBalance(ctx, a, address)
 }
 
+// WalletBalanceAt returns the balance of the given wallet address in the
+// state of the tipset with key tsKey, or of the head if tsKey is empty.
+func (a *API) WalletBalanceAt(ctx context.Context, tsKey types.SortedCidSet, address address.Address) (*types.AttoFIL, error) {
+	return WalletBalanceAt(ctx, a, tsKey, address)
+}
+
+// WalletAvailableBalance returns the balance of the given wallet address not
+// committed to its messages pending in the message pool.
+func (a *API) WalletAvailableBalance(ctx context.Context, address address.Address) (*types.AttoFIL, error) {
+	return WalletAvailableBalance(ctx, a, address)
+}
+
 // WalletDefaultAddress returns a default wallet address from the config.
 // If none is set it picks the first address in the wallet and sets it as the default in the config.
 func (a *API) WalletDefaultAddress() (address.Address, error) {
 	return WalletDefaultAddress(a)
 }
 
+// WalletSetDefaultAddress makes the given wallet address the default one
+func (a *API) WalletSetDefaultAddress(addr address.Address) error {
+	return WalletSetDefaultAddress(a, addr)
+}
+
+// WalletLabel labels a wallet address with a name in the address book
+func (a *API) WalletLabel(addr address.Address, name string) error {
+	return WalletLabel(a, addr, name)
+}
+
+// WalletLs returns the wallet addresses with their balances and labels
+func (a *API) WalletLs(ctx context.Context) ([]*WalletAddressInfo, error) {
+	return WalletLs(ctx, a)
+}
+
 // PaymentChannelLs lists payment channels for a given payer
 func (a *API) PaymentChannelLs(
 	ctx context.Context,
@@ -198,6 +262,22 @@ func (a *API) PaymentChannelVoucher(
 	return PaymentChannelVoucher(ctx, a, fromAddr, channel, amount, validAt, condition)
 }
 
+// PaymentChannelLaneVoucher returns a signed voucher paying in a lane of a
+// payment channel
+func (a *API) PaymentChannelLaneVoucher(
+	ctx context.Context,
+	fromAddr address.Address,
+	channel *types.ChannelID,
+	amount *types.AttoFIL,
+	validAt *types.BlockHeight,
+	condition *types.Predicate,
+	lane uint64,
+	nonce uint64,
+	merges []types.LaneMerge,
+) (*types.PaymentVoucher, error) {
+	return PaymentChannelLaneVoucher(ctx, a, fromAddr, channel, amount, validAt, condition, lane, nonce, merges)
+}
+
 // ClientListAsks returns a channel with asks from the latest chain state
 func (a *API) ClientListAsks(ctx context.Context) <-chan Ask {
 	return ClientListAsks(ctx, a)
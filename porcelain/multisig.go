@@ -0,0 +1,126 @@
+package porcelain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+	vmErrors "github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+// msigCreateAPI is the subset of the plumbing.API that MultisigCreate uses.
+type msigCreateAPI interface {
+	MessageSendWithDefaultAddress(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
+	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
+}
+
+// MultisigCreate creates a multisig wallet funded with value, spending its
+// funds with the approval of required of the signers, and returns its address
+// once the wallet appears on chain.  If unlockDuration is not zero, value
+// unlocks linearly over that many blocks.
+func MultisigCreate(
+	ctx context.Context,
+	plumbing msigCreateAPI,
+	from address.Address,
+	gasPrice types.AttoFIL,
+	gasLimit types.GasUnits,
+	signers []address.Address,
+	required uint64,
+	value *types.AttoFIL,
+	unlockDuration *types.BlockHeight,
+) (address.Address, error) {
+	smsgCid, err := plumbing.MessageSendWithDefaultAddress(
+		ctx,
+		from,
+		address.MultisigFactoryAddress,
+		value,
+		gasPrice,
+		gasLimit,
+		"createMultisig",
+		signers,
+		big.NewInt(0).SetUint64(required),
+		unlockDuration,
+	)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	var walletAddr address.Address
+	err = plumbing.MessageWait(ctx, smsgCid, func(blk *types.Block, smsg *types.SignedMessage, receipt *types.MessageReceipt) (err error) {
+		if receipt.ExitCode != uint8(0) {
+			return vmErrors.VMExitCodeToError(receipt.ExitCode, multisig.Errors)
+		}
+		walletAddr, err = address.NewFromBytes(receipt.Return[0])
+		return err
+	})
+	if err != nil {
+		return address.Undef, err
+	}
+
+	return walletAddr, nil
+}
+
+// MultisigInfo describes a multisig wallet.
+type MultisigInfo struct {
+	Signers  []address.Address `json:"signers"`
+	Required uint64            `json:"required"`
+	Balance  *types.AttoFIL    `json:"balance"`
+	Locked   *types.AttoFIL    `json:"locked"`
+	// Transactions maps the stringified ids of the transactions awaiting
+	// approvals to the transactions.
+	Transactions map[string]*multisig.Transaction `json:"transactions"`
+}
+
+// msigShowAPI is the subset of the plumbing.API that MultisigShow uses.
+type msigShowAPI interface {
+	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
+	WalletBalance(ctx context.Context, address address.Address) (*types.AttoFIL, error)
+}
+
+// MultisigShow describes the multisig wallet at addr.
+func MultisigShow(ctx context.Context, plumbing msigShowAPI, addr address.Address) (*MultisigInfo, error) {
+	var info MultisigInfo
+
+	ret, err := plumbing.MessageQuery(ctx, address.Undef, addr, "getSigners")
+	if err != nil {
+		return nil, err
+	}
+	signers, err := abi.Deserialize(ret[0], abi.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	info.Signers = signers.Val.([]address.Address)
+
+	ret, err = plumbing.MessageQuery(ctx, address.Undef, addr, "getRequired")
+	if err != nil {
+		return nil, err
+	}
+	info.Required = big.NewInt(0).SetBytes(ret[0]).Uint64()
+
+	ret, err = plumbing.MessageQuery(ctx, address.Undef, addr, "getLocked")
+	if err != nil {
+		return nil, err
+	}
+	info.Locked = types.NewAttoFILFromBytes(ret[0])
+
+	ret, err = plumbing.MessageQuery(ctx, address.Undef, addr, "getTransactions")
+	if err != nil {
+		return nil, err
+	}
+	if err := cbor.DecodeInto(ret[0], &info.Transactions); err != nil {
+		return nil, err
+	}
+
+	info.Balance, err = plumbing.WalletBalance(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
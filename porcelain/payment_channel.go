@@ -4,6 +4,7 @@ import (
 	"context"
 
 	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
@@ -97,3 +98,57 @@ func PaymentChannelVoucher(
 
 	return voucher, nil
 }
+
+// PaymentChannelLaneVoucher returns a signed voucher paying in the given lane
+// of a payment channel.  The nonce must be higher than those of the vouchers
+// of the lane given before, and merges name the lanes whose amounts the
+// voucher's amount includes.
+func PaymentChannelLaneVoucher(
+	ctx context.Context,
+	plumbing pcvPlumbing,
+	fromAddr address.Address,
+	channel *types.ChannelID,
+	amount *types.AttoFIL,
+	validAt *types.BlockHeight,
+	condition *types.Predicate,
+	lane uint64,
+	nonce uint64,
+	merges []types.LaneMerge,
+) (voucher *types.PaymentVoucher, err error) {
+	if nonce == 0 {
+		return nil, errors.New("lane vouchers must have a nonce of at least 1")
+	}
+
+	if fromAddr.Empty() {
+		fromAddr, err = plumbing.WalletDefaultAddress()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	values, err := plumbing.MessageQuery(
+		ctx,
+		fromAddr,
+		address.PaymentBrokerAddress,
+		"voucher",
+		channel, amount, validAt, condition,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cbor.DecodeInto(values[0], &voucher); err != nil {
+		return nil, err
+	}
+	voucher.Lane = lane
+	voucher.Nonce = nonce
+	voucher.Merges = merges
+
+	sig, err := paymentbroker.SignLaneVoucher(voucher, plumbing)
+	if err != nil {
+		return nil, err
+	}
+	voucher.Signature = sig
+
+	return voucher, nil
+}
@@ -0,0 +1,49 @@
+package porcelain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// RewardInfo describes the reward actor: the funds left for block rewards and
+// the parameters of the rewards.
+type RewardInfo struct {
+	Balance        *types.AttoFIL `json:"balance"`
+	BlockReward    *types.AttoFIL `json:"blockReward"`
+	GasBurnPercent uint64         `json:"gasBurnPercent"`
+}
+
+// rewardShowAPI is the subset of the plumbing.API that RewardShow uses.
+type rewardShowAPI interface {
+	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
+	WalletBalance(ctx context.Context, address address.Address) (*types.AttoFIL, error)
+}
+
+// RewardShow describes the reward actor at the network address.
+func RewardShow(ctx context.Context, plumbing rewardShowAPI) (*RewardInfo, error) {
+	var info RewardInfo
+
+	ret, err := plumbing.MessageQuery(ctx, address.Undef, address.NetworkAddress, "getBlockReward")
+	if err != nil {
+		return nil, errors.Wrap(err, "'getBlockReward' query message failed")
+	}
+	info.BlockReward = types.NewAttoFILFromBytes(ret[0])
+
+	ret, err = plumbing.MessageQuery(ctx, address.Undef, address.NetworkAddress, "getGasBurnPercent")
+	if err != nil {
+		return nil, errors.Wrap(err, "'getGasBurnPercent' query message failed")
+	}
+	info.GasBurnPercent = big.NewInt(0).SetBytes(ret[0]).Uint64()
+
+	info.Balance, err = plumbing.WalletBalance(ctx, address.NetworkAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,75 @@
+package porcelain
+
+import (
+	"context"
+	"math/big"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// MinerPower is the power of a miner along with the total power of the
+// storage market, in bytes of storage.
+type MinerPower struct {
+	Power *types.BytesAmount `json:"power"`
+	Total *types.BytesAmount `json:"total"`
+}
+
+// StorageMarketState describes the state of the storage market actor.
+type StorageMarketState struct {
+	// TotalStorage is the storage committed by all miners, in bytes.
+	TotalStorage *types.BytesAmount `json:"totalStorage"`
+	ProofsMode   types.ProofsMode   `json:"proofsMode"`
+}
+
+type smQueryPlumbing interface {
+	MessageQueryAt(ctx context.Context, tsKey types.SortedCidSet, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
+}
+
+// MinerGetPower queries for the power of the given miner and the total power
+// of the storage market, in the state of the tipset with key tsKey, or of the
+// head if tsKey is empty.
+func MinerGetPower(ctx context.Context, plumbing smQueryPlumbing, tsKey types.SortedCidSet, minerAddr address.Address) (*MinerPower, error) {
+	power, err := queryBytesAmount(ctx, plumbing, tsKey, minerAddr, "getPower")
+	if err != nil {
+		return nil, err
+	}
+	total, err := queryBytesAmount(ctx, plumbing, tsKey, address.StorageMarketAddress, "getTotalStorage")
+	if err != nil {
+		return nil, err
+	}
+	return &MinerPower{Power: power, Total: total}, nil
+}
+
+// StorageMarketGetState queries for the state of the storage market in the
+// state of the tipset with key tsKey, or of the head if tsKey is empty.
+func StorageMarketGetState(ctx context.Context, plumbing smQueryPlumbing, tsKey types.SortedCidSet) (*StorageMarketState, error) {
+	total, err := queryBytesAmount(ctx, plumbing, tsKey, address.StorageMarketAddress, "getTotalStorage")
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := plumbing.MessageQueryAt(ctx, tsKey, address.Undef, address.StorageMarketAddress, "getProofsMode")
+	if err != nil {
+		return nil, errors.Wrap(err, "'getProofsMode' query message failed")
+	}
+	var proofsMode types.ProofsMode
+	if err := cbor.DecodeInto(values[0], &proofsMode); err != nil {
+		return nil, errors.Wrap(err, "could not convert query message result to ProofsMode")
+	}
+
+	return &StorageMarketState{TotalStorage: total, ProofsMode: proofsMode}, nil
+}
+
+// queryBytesAmount sends a query message returning an integer amount of
+// bytes, which abi encodes as big-endian.
+func queryBytesAmount(ctx context.Context, plumbing smQueryPlumbing, tsKey types.SortedCidSet, to address.Address, method string) (*types.BytesAmount, error) {
+	values, err := plumbing.MessageQueryAt(ctx, tsKey, address.Undef, to, method)
+	if err != nil {
+		return nil, errors.Wrapf(err, "'%s' query message failed", method)
+	}
+	return types.NewBytesAmountFromBigInt(big.NewInt(0).SetBytes(values[0])), nil
+}
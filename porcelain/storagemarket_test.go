@@ -0,0 +1,67 @@
+package porcelain_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type smTestPlumbing struct {
+	// power is the power of each miner, and the total storage of the
+	// storage market, by tipset key.
+	power map[string]map[address.Address]int64
+	// tsKeys are the keys queries were made at.
+	tsKeys []types.SortedCidSet
+}
+
+func (smtp *smTestPlumbing) MessageQueryAt(ctx context.Context, tsKey types.SortedCidSet, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error) {
+	smtp.tsKeys = append(smtp.tsKeys, tsKey)
+	switch method {
+	case "getPower", "getTotalStorage":
+		return [][]byte{big.NewInt(smtp.power[tsKey.String()][to]).Bytes()}, nil
+	case "getProofsMode":
+		return [][]byte{{byte(types.TestProofsMode)}}, nil
+	}
+	return nil, nil
+}
+
+func TestStorageMarketStateAt(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	minerAddr := address.NewForTestGetter()()
+	oldKey := types.NewSortedCidSet(types.SomeCid())
+	plumbing := &smTestPlumbing{power: map[string]map[address.Address]int64{
+		types.SortedCidSet{}.String(): {minerAddr: 1024, address.StorageMarketAddress: 4096},
+		oldKey.String():               {minerAddr: 256, address.StorageMarketAddress: 512},
+	}}
+
+	t.Run("miner power", func(t *testing.T) {
+		power, err := porcelain.MinerGetPower(ctx, plumbing, types.SortedCidSet{}, minerAddr)
+		require.NoError(t, err)
+		assert.Equal(t, "1024", power.Power.String())
+		assert.Equal(t, "4096", power.Total.String())
+
+		plumbing.tsKeys = nil
+		power, err = porcelain.MinerGetPower(ctx, plumbing, oldKey, minerAddr)
+		require.NoError(t, err)
+		assert.Equal(t, "256", power.Power.String())
+		assert.Equal(t, "512", power.Total.String())
+		assert.Equal(t, []types.SortedCidSet{oldKey, oldKey}, plumbing.tsKeys)
+	})
+
+	t.Run("storage market state", func(t *testing.T) {
+		st, err := porcelain.StorageMarketGetState(ctx, plumbing, oldKey)
+		require.NoError(t, err)
+		assert.Equal(t, "512", st.TotalStorage.String())
+		assert.Equal(t, types.TestProofsMode, st.ProofsMode)
+	})
+}
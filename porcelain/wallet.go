@@ -6,6 +6,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/addrbook"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -32,6 +33,47 @@ func WalletBalance(ctx context.Context, plumbing wbPlumbing, addr address.Addres
 	return act.Balance, nil
 }
 
+type wbaPlumbing interface {
+	ActorGetAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error)
+}
+
+// WalletBalanceAt gets the balance associated with an address in the state of
+// the tipset with key tsKey, or of the head if tsKey is empty.
+func WalletBalanceAt(ctx context.Context, plumbing wbaPlumbing, tsKey types.SortedCidSet, addr address.Address) (*types.AttoFIL, error) {
+	act, err := plumbing.ActorGetAt(ctx, tsKey, addr)
+	if err != nil {
+		if state.IsActorNotFoundError(err) {
+			// the account didn't exist yet, so the balance was zero
+			return types.NewAttoFILFromFIL(0), nil
+		}
+
+		return types.ZeroAttoFIL, err
+	}
+
+	return act.Balance, nil
+}
+
+type wabPlumbing interface {
+	wbPlumbing
+	MessagePoolPendingSpend(addr address.Address) *types.AttoFIL
+}
+
+// WalletAvailableBalance gets the part of the balance associated with an
+// address that is not committed to messages pending in the message pool, i.e.
+// the most a new message from the address can spend.
+func WalletAvailableBalance(ctx context.Context, plumbing wabPlumbing, addr address.Address) (*types.AttoFIL, error) {
+	balance, err := WalletBalance(ctx, plumbing, addr)
+	if err != nil {
+		return types.ZeroAttoFIL, err
+	}
+
+	available := balance.Sub(plumbing.MessagePoolPendingSpend(addr))
+	if available.IsNegative() {
+		return types.ZeroAttoFIL, nil
+	}
+	return available, nil
+}
+
 type wdaPlumbing interface {
 	ConfigGet(dottedPath string) (interface{}, error)
 	ConfigSet(dottedPath string, paramJSON string) error
@@ -61,3 +103,103 @@ func WalletDefaultAddress(plumbing wdaPlumbing) (address.Address, error) {
 
 	return address.Undef, ErrNoDefaultFromAddress
 }
+
+type wsdaPlumbing interface {
+	ConfigSet(dottedPath string, paramJSON string) error
+	WalletAddresses() []address.Address
+}
+
+// WalletSetDefaultAddress makes addr, which must be in the wallet, the
+// address messages are sent from when no address is given.
+func WalletSetDefaultAddress(plumbing wsdaPlumbing, addr address.Address) error {
+	if !isInWallet(addr, plumbing.WalletAddresses()) {
+		return errors.Errorf("address %s is not in the wallet", addr)
+	}
+	return plumbing.ConfigSet("wallet.defaultAddress", addr.String())
+}
+
+// WalletLabelType is the type of the address book entries labeling
+// addresses of the wallet.
+const WalletLabelType = "wallet"
+
+type wlPlumbing interface {
+	AddrBookAdd(entry *addrbook.Entry) error
+	WalletAddresses() []address.Address
+}
+
+// WalletLabel labels addr, which must be in the wallet, with name.  Labels
+// are address book entries, so that name can be used wherever the address
+// book is.
+func WalletLabel(plumbing wlPlumbing, addr address.Address, name string) error {
+	if !isInWallet(addr, plumbing.WalletAddresses()) {
+		return errors.Errorf("address %s is not in the wallet", addr)
+	}
+	return plumbing.AddrBookAdd(&addrbook.Entry{
+		Name:    name,
+		Address: addr,
+		Type:    WalletLabelType,
+	})
+}
+
+// WalletAddressInfo describes an address of the wallet.
+type WalletAddressInfo struct {
+	Address address.Address `json:"address"`
+	Balance *types.AttoFIL  `json:"balance"`
+	// Default is true for the address messages are sent from when no
+	// address is given.
+	Default bool `json:"default"`
+	// Labels are the names of the address in the address book.
+	Labels []string `json:"labels"`
+}
+
+type wlsPlumbing interface {
+	wdaPlumbing
+	wbPlumbing
+	AddrBookLs() ([]*addrbook.Entry, error)
+}
+
+// WalletLs returns the addresses of the wallet with their balances and
+// labels, marking the default one.
+func WalletLs(ctx context.Context, plumbing wlsPlumbing) ([]*WalletAddressInfo, error) {
+	addrs := plumbing.WalletAddresses()
+	if len(addrs) == 0 {
+		return []*WalletAddressInfo{}, nil
+	}
+
+	defaultAddr, err := WalletDefaultAddress(plumbing)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := plumbing.AddrBookLs()
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[address.Address][]string)
+	for _, entry := range entries {
+		labels[entry.Address] = append(labels[entry.Address], entry.Name)
+	}
+
+	infos := make([]*WalletAddressInfo, len(addrs))
+	for i, addr := range addrs {
+		balance, err := WalletBalance(ctx, plumbing, addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get balance of %s", addr)
+		}
+		infos[i] = &WalletAddressInfo{
+			Address: addr,
+			Balance: balance,
+			Default: addr == defaultAddr,
+			Labels:  labels[addr],
+		}
+	}
+	return infos, nil
+}
+
+func isInWallet(addr address.Address, addrs []address.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
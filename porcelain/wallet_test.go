@@ -8,6 +8,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/addrbook"
 	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/repo"
@@ -22,9 +23,20 @@ type wbTestPlumbing struct {
 	balance *types.AttoFIL
 }
 
+type wabTestPlumbing struct {
+	wbTestPlumbing
+	pendingSpend *types.AttoFIL
+}
+
+func (wabtp *wabTestPlumbing) MessagePoolPendingSpend(addr address.Address) *types.AttoFIL {
+	return wabtp.pendingSpend
+}
+
 type wdaTestPlumbing struct {
+	wbTestPlumbing
 	config *cfg.Config
 	wallet *wallet.Wallet
+	book   *addrbook.Book
 }
 
 func newWdaTestPlumbing(t *testing.T) *wdaTestPlumbing {
@@ -32,8 +44,10 @@ func newWdaTestPlumbing(t *testing.T) *wdaTestPlumbing {
 	backend, err := wallet.NewDSBackend(repo.WalletDatastore())
 	require.NoError(t, err)
 	return &wdaTestPlumbing{
-		config: cfg.NewConfig(repo),
-		wallet: wallet.New(backend),
+		wbTestPlumbing: wbTestPlumbing{balance: types.NewAttoFILFromFIL(7)},
+		config:         cfg.NewConfig(repo),
+		wallet:         wallet.New(backend),
+		book:           addrbook.New(repo.Datastore()),
 	}
 }
 
@@ -54,8 +68,16 @@ func (wdatp *wdaTestPlumbing) WalletAddresses() []address.Address {
 	return wdatp.wallet.Addresses()
 }
 
+func (wdatp *wdaTestPlumbing) AddrBookAdd(entry *addrbook.Entry) error {
+	return wdatp.book.Add(entry)
+}
+
+func (wdatp *wdaTestPlumbing) AddrBookLs() ([]*addrbook.Entry, error) {
+	return wdatp.book.Ls()
+}
+
 func (wdatp *wdaTestPlumbing) WalletNewAddress() (address.Address, error) {
-	return wallet.NewAddress(wdatp.wallet)
+	return wallet.NewAddress(wdatp.wallet, address.SECP256K1)
 }
 
 func TestWalletBalance(t *testing.T) {
@@ -75,6 +97,33 @@ func TestWalletBalance(t *testing.T) {
 	})
 }
 
+func TestWalletAvailableBalance(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	t.Run("Subtracts the pending spend from the balance", func(t *testing.T) {
+		plumbing := &wabTestPlumbing{
+			wbTestPlumbing: wbTestPlumbing{balance: types.NewAttoFILFromFIL(20)},
+			pendingSpend:   types.NewAttoFILFromFIL(5),
+		}
+		available, err := porcelain.WalletAvailableBalance(ctx, plumbing, address.Undef)
+		require.NoError(t, err)
+
+		assert.Equal(t, types.NewAttoFILFromFIL(15), available)
+	})
+
+	t.Run("Is zero when pending messages exceed the balance", func(t *testing.T) {
+		plumbing := &wabTestPlumbing{
+			wbTestPlumbing: wbTestPlumbing{balance: types.NewAttoFILFromFIL(20)},
+			pendingSpend:   types.NewAttoFILFromFIL(25),
+		}
+		available, err := porcelain.WalletAvailableBalance(ctx, plumbing, address.Undef)
+		require.NoError(t, err)
+
+		assert.True(t, available.IsZero())
+	})
+}
+
 func TestWalletDefaultAddress(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -111,6 +160,63 @@ func TestWalletDefaultAddress(t *testing.T) {
 	})
 }
 
+func TestWalletSetDefaultAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	wdatp := newWdaTestPlumbing(t)
+	addr1, err := wdatp.WalletNewAddress()
+	require.NoError(t, err)
+	addr2, err := wdatp.WalletNewAddress()
+	require.NoError(t, err)
+
+	require.NoError(t, porcelain.WalletSetDefaultAddress(wdatp, addr1))
+	got, err := porcelain.WalletDefaultAddress(wdatp)
+	require.NoError(t, err)
+	assert.Equal(t, addr1, got)
+
+	require.NoError(t, porcelain.WalletSetDefaultAddress(wdatp, addr2))
+	got, err = porcelain.WalletDefaultAddress(wdatp)
+	require.NoError(t, err)
+	assert.Equal(t, addr2, got)
+
+	t.Run("rejects addresses not in the wallet", func(t *testing.T) {
+		assert.Error(t, porcelain.WalletSetDefaultAddress(wdatp, address.TestAddress))
+		got, err := porcelain.WalletDefaultAddress(wdatp)
+		require.NoError(t, err)
+		assert.Equal(t, addr2, got)
+	})
+}
+
+func TestWalletLs(t *testing.T) {
+	tf.UnitTest(t)
+
+	wdatp := newWdaTestPlumbing(t)
+	addr1, err := wdatp.WalletNewAddress()
+	require.NoError(t, err)
+	addr2, err := wdatp.WalletNewAddress()
+	require.NoError(t, err)
+	require.NoError(t, porcelain.WalletSetDefaultAddress(wdatp, addr2))
+	require.NoError(t, porcelain.WalletLabel(wdatp, addr1, "savings"))
+	assert.Error(t, porcelain.WalletLabel(wdatp, address.TestAddress, "other"))
+
+	infos, err := porcelain.WalletLs(context.Background(), wdatp)
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	for _, info := range infos {
+		assert.Equal(t, types.NewAttoFILFromFIL(7), info.Balance)
+		switch info.Address {
+		case addr1:
+			assert.False(t, info.Default)
+			assert.Equal(t, []string{"savings"}, info.Labels)
+		case addr2:
+			assert.True(t, info.Default)
+			assert.Empty(t, info.Labels)
+		default:
+			t.Errorf("unexpected address %s", info.Address)
+		}
+	}
+}
+
 func isInList(needle address.Address, haystack []address.Address) bool {
 	for _, a := range haystack {
 		if a == needle {
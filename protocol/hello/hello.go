@@ -3,6 +3,7 @@ package hello
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	cid "github.com/ipfs/go-cid"
@@ -12,6 +13,7 @@ import (
 	net "github.com/libp2p/go-libp2p-net"
 	peer "github.com/libp2p/go-libp2p-peer"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
 
 	cbu "github.com/filecoin-project/go-filecoin/cborutil"
 	"github.com/filecoin-project/go-filecoin/metrics"
@@ -21,6 +23,7 @@ import (
 var versionErrCt = metrics.NewInt64Counter("hello_version_error", "Number of errors encountered in hello protocol due to incorrect version")
 var genesisErrCt = metrics.NewInt64Counter("hello_genesis_error", "Number of errors encountered in hello protocol due to incorrect genesis block")
 var helloMsgErrCt = metrics.NewInt64Counter("hello_message_error", "Number of errors encountered in hello protocol due to malformed message")
+var protocolErrCt = metrics.NewInt64Counter("hello_protocol_error", "Number of errors encountered in hello protocol due to incompatible protocol versions")
 
 func init() {
 	cbor.RegisterCborType(Message{})
@@ -31,18 +34,72 @@ const protocol = "/fil/hello/1.0.0"
 
 var log = logging.Logger("/fil/hello")
 
+const (
+	// ProtocolVersion is the version of the network protocols, the chain
+	// format and consensus rules this node implements.  It is bumped on
+	// every change that stops nodes of different versions from following
+	// the same chain.
+	//
+	// Version 2 returns the canonical exit codes of package vm/exitcode,
+	// which end up in the receipts of blocks, for failed messages.
+	ProtocolVersion = 2
+	// MinProtocolVersion is the lowest protocol version of peers this node
+	// is compatible with.
+	MinProtocolVersion = 2
+)
+
+// Features are optional protocols a node may support, which it advertises in
+// its hello messages.
+const (
+	// FeatureAncestors is the protocol serving chains of ancestors.
+	FeatureAncestors = "ancestors"
+	// FeaturePeerExchange is the protocol serving known peers.
+	FeaturePeerExchange = "peer-exchange"
+	// FeatureMessagePoolSync is the protocol serving pending messages.
+	FeatureMessagePoolSync = "mpool-sync"
+)
+
 // Message is the data structure of a single message in the hello protocol.
 type Message struct {
 	HeaviestTipSetCids   []cid.Cid
 	HeaviestTipSetHeight uint64
+	// HeaviestTipSetWeight is the parent weight of the heaviest tipset,
+	// which the receiver checks the chain it fetches against.
+	HeaviestTipSetWeight uint64
 	GenesisHash          cid.Cid
 	CommitSha            string
+	// ProtocolVersion is the protocol version of the sender, and Features
+	// the optional features it supports.
+	ProtocolVersion uint64
+	Features        []string
+}
+
+// PeerVersion is what a peer told about its version in its hello message.
+type PeerVersion struct {
+	ProtocolVersion uint64   `json:"protocolVersion"`
+	CommitSha       string   `json:"commitSha"`
+	Features        []string `json:"features"`
+}
+
+// HasFeature returns true if the peer supports feature.
+func (pv *PeerVersion) HasFeature(feature string) bool {
+	for _, f := range pv.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
 }
 
-type syncCallback func(from peer.ID, cids []cid.Cid, height uint64)
+type syncCallback func(from peer.ID, cids []cid.Cid, height uint64, parentWeight uint64)
 
 type getTipSetFunc func() (*types.TipSet, error)
 
+// latencyRecorder measures the round trip time to peers.
+type latencyRecorder interface {
+	StartLatency(p peer.ID) func()
+}
+
 // Handler implements the 'Hello' protocol handler. Upon connecting to a new
 // node, we send them a message containing some information about the state of
 // our chain, and receive the same information from them. This is used to
@@ -59,20 +116,35 @@ type Handler struct {
 	// for filling out our hello messages.
 	getHeaviestTipSet getTipSetFunc
 
+	// latency measures the round trip of opening the hello stream, which
+	// negotiates the protocol with the peer.
+	latency latencyRecorder
+
 	net       string
 	commitSha string
+	features  []string
+
+	// refuseForeign, if set, is called with peers that announce another
+	// genesis block or don't say hello in time.
+	refuseForeign func(peer.ID)
+
+	// greeted holds the versions of the connected peers that said hello.
+	greetedLk sync.Mutex
+	greeted   map[peer.ID]*PeerVersion
 }
 
 // New creates a new instance of the hello protocol and registers it to
 // the given host, with the provided callbacks.
-func New(h host.Host, gen cid.Cid, syncCallback syncCallback, getHeaviestTipSet getTipSetFunc, net string, commitSha string) *Handler {
+func New(h host.Host, gen cid.Cid, syncCallback syncCallback, getHeaviestTipSet getTipSetFunc, latency latencyRecorder, net string, commitSha string) *Handler {
 	hello := &Handler{
 		host:              h,
 		genesis:           gen,
 		chainSyncCB:       syncCallback,
 		getHeaviestTipSet: getHeaviestTipSet,
+		latency:           latency,
 		net:               net,
 		commitSha:         commitSha,
+		greeted:           make(map[peer.ID]*PeerVersion),
 	}
 	h.SetStreamHandler(protocol, hello.handleNewStream)
 
@@ -82,6 +154,29 @@ func New(h host.Host, gen cid.Cid, syncCallback syncCallback, getHeaviestTipSet
 	return hello
 }
 
+// RefuseForeignPeers makes the handler call refuse with, and disconnect from,
+// peers that announce another genesis block or that don't say hello within
+// the hello timeout, so that a private network only keeps peers of its own.
+// It must be called before the host connects to peers.
+func (h *Handler) RefuseForeignPeers(refuse func(peer.ID)) {
+	h.refuseForeign = refuse
+}
+
+// Advertise makes the handler tell peers that the node supports features.  It
+// must be called before the host connects to peers.
+func (h *Handler) Advertise(features ...string) {
+	h.features = append(h.features, features...)
+}
+
+// PeerVersion returns the version p told in its hello message, if p is
+// connected and said hello.
+func (h *Handler) PeerVersion(p peer.ID) (*PeerVersion, bool) {
+	h.greetedLk.Lock()
+	defer h.greetedLk.Unlock()
+	pv, ok := h.greeted[p]
+	return pv, ok
+}
+
 func (h *Handler) handleNewStream(s net.Stream) {
 	defer s.Close() // nolint: errcheck
 
@@ -95,10 +190,18 @@ func (h *Handler) handleNewStream(s net.Stream) {
 		return
 	}
 
-	switch err := h.processHelloMessage(from, &hello); err {
+	switch err := h.processHelloMessage(from, &hello); errors.Cause(err) {
+	case ErrIncompatibleProtocol:
+		log.Infof("disconnecting from peer %s: %s", from, err)
+		protocolErrCt.Inc(context.TODO(), 1)
+		s.Conn().Close() // nolint: errcheck
+		return
 	case ErrBadGenesis:
 		log.Debugf("genesis cid: %s does not match: %s, disconnecting from peer: %s", &hello.GenesisHash, h.genesis, from)
 		genesisErrCt.Inc(context.TODO(), 1)
+		if h.refuseForeign != nil {
+			h.refuseForeign(from)
+		}
 		s.Conn().Close() // nolint: errcheck
 		return
 	case ErrWrongVersion:
@@ -106,7 +209,14 @@ func (h *Handler) handleNewStream(s net.Stream) {
 		versionErrCt.Inc(context.TODO(), 1)
 		s.Conn().Close() // nolint: errcheck
 		return
-	case nil: // ok, noop
+	case nil:
+		h.greetedLk.Lock()
+		h.greeted[from] = &PeerVersion{
+			ProtocolVersion: hello.ProtocolVersion,
+			CommitSha:       hello.CommitSha,
+			Features:        hello.Features,
+		}
+		h.greetedLk.Unlock()
 	default:
 		log.Error(err)
 	}
@@ -118,7 +228,16 @@ var ErrBadGenesis = fmt.Errorf("bad genesis block")
 // ErrWrongVersion is the error returned when a mismatch in the code version happens.
 var ErrWrongVersion = fmt.Errorf("code version mismatch")
 
+// ErrIncompatibleProtocol is the error returned when a peer runs a protocol
+// version this node is not compatible with.
+var ErrIncompatibleProtocol = fmt.Errorf("incompatible protocol version")
+
 func (h *Handler) processHelloMessage(from peer.ID, msg *Message) error {
+	// Checked first, as peers of other versions may disagree on everything
+	// else.
+	if msg.ProtocolVersion < MinProtocolVersion {
+		return errors.Wrapf(ErrIncompatibleProtocol, "peer runs protocol version %d (commit %s), lowest supported is %d", msg.ProtocolVersion, msg.CommitSha, MinProtocolVersion)
+	}
 	if !msg.GenesisHash.Equals(h.genesis) {
 		return ErrBadGenesis
 	}
@@ -126,7 +245,7 @@ func (h *Handler) processHelloMessage(from peer.ID, msg *Message) error {
 		return ErrWrongVersion
 	}
 
-	h.chainSyncCB(from, msg.HeaviestTipSetCids, msg.HeaviestTipSetHeight)
+	h.chainSyncCB(from, msg.HeaviestTipSetCids, msg.HeaviestTipSetHeight, msg.HeaviestTipSetWeight)
 	return nil
 }
 
@@ -139,20 +258,29 @@ func (h *Handler) getOurHelloMessage() *Message {
 	if err != nil {
 		panic("somehow heaviest tipset is empty")
 	}
+	weight, err := heaviest.ParentWeight()
+	if err != nil {
+		panic("somehow heaviest tipset is empty")
+	}
 
 	return &Message{
 		GenesisHash:          h.genesis,
 		HeaviestTipSetCids:   heaviest.ToSortedCidSet().ToSlice(),
 		HeaviestTipSetHeight: height,
+		HeaviestTipSetWeight: weight,
 		CommitSha:            h.commitSha,
+		ProtocolVersion:      ProtocolVersion,
+		Features:             h.features,
 	}
 }
 
 func (h *Handler) sayHello(ctx context.Context, p peer.ID) error {
+	done := h.latency.StartLatency(p)
 	s, err := h.host.NewStream(ctx, p, protocol)
 	if err != nil {
 		return err
 	}
+	done()
 	defer s.Close() // nolint: errcheck
 
 	msg := h.getOurHelloMessage()
@@ -160,6 +288,20 @@ func (h *Handler) sayHello(ctx context.Context, p peer.ID) error {
 	return cbu.NewMsgWriter(s).WriteMsg(&msg)
 }
 
+// refuseIfSilent disconnects from and refuses p if it is still connected
+// without having said hello.
+func (h *Handler) refuseIfSilent(p peer.ID) {
+	h.greetedLk.Lock()
+	_, ok := h.greeted[p]
+	h.greetedLk.Unlock()
+	if ok || h.host.Network().Connectedness(p) != net.Connected {
+		return
+	}
+	log.Debugf("no hello from peer %s, disconnecting", p)
+	h.refuseForeign(p)
+	h.host.Network().ClosePeer(p) // nolint: errcheck
+}
+
 // New peer connection notifications
 
 type helloNotify Handler
@@ -179,10 +321,22 @@ func (hn *helloNotify) Connected(n net.Network, c net.Conn) {
 			log.Warningf("failed to send hello handshake to peer %s: %s", p, err)
 		}
 	}()
+	if hn.refuseForeign != nil {
+		p := c.RemotePeer()
+		time.AfterFunc(helloTimeout, func() { hn.hello().refuseIfSilent(p) })
+	}
 }
 
 func (hn *helloNotify) Listen(n net.Network, a ma.Multiaddr)      {}
 func (hn *helloNotify) ListenClose(n net.Network, a ma.Multiaddr) {}
-func (hn *helloNotify) Disconnected(n net.Network, c net.Conn)    {}
-func (hn *helloNotify) OpenedStream(n net.Network, s net.Stream)  {}
-func (hn *helloNotify) ClosedStream(n net.Network, s net.Stream)  {}
+func (hn *helloNotify) Disconnected(n net.Network, c net.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) == net.Connected {
+		return
+	}
+	hn.greetedLk.Lock()
+	delete(hn.greeted, p)
+	hn.greetedLk.Unlock()
+}
+func (hn *helloNotify) OpenedStream(n net.Network, s net.Stream) {}
+func (hn *helloNotify) ClosedStream(n net.Network, s net.Stream) {}
@@ -13,6 +13,8 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	fnet "github.com/filecoin-project/go-filecoin/net"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -22,8 +24,8 @@ type mockSyncCallback struct {
 	mock.Mock
 }
 
-func (msb *mockSyncCallback) SyncCallback(p peer.ID, cids []cid.Cid, h uint64) {
-	msb.Called(p, cids, h)
+func (msb *mockSyncCallback) SyncCallback(p peer.ID, cids []cid.Cid, h uint64, w uint64) {
+	msb.Called(p, cids, h, w)
 }
 
 type mockHeaviestGetter struct {
@@ -48,17 +50,18 @@ func TestHelloHandshake(t *testing.T) {
 
 	genesisA := &types.Block{Nonce: 451}
 
-	heavy1 := th.RequireNewTipSet(t, &types.Block{Nonce: 1000, Height: 2})
-	heavy2 := th.RequireNewTipSet(t, &types.Block{Nonce: 1001, Height: 3})
+	heavy1 := th.RequireNewTipSet(t, &types.Block{Nonce: 1000, Height: 2, ParentWeight: 20000})
+	heavy2 := th.RequireNewTipSet(t, &types.Block{Nonce: 1001, Height: 3, ParentWeight: 30000})
 
 	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
 	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
 
-	New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet, "", "")
-	New(b, genesisA.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet, "", "")
+	statsA := fnet.NewPeerStats()
+	New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet, statsA, "", "")
+	New(b, genesisA.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet, fnet.NewPeerStats(), "", "")
 
-	msc1.On("SyncCallback", b.ID(), heavy2.ToSortedCidSet().ToSlice(), uint64(3)).Return()
-	msc2.On("SyncCallback", a.ID(), heavy1.ToSortedCidSet().ToSlice(), uint64(2)).Return()
+	msc1.On("SyncCallback", b.ID(), heavy2.ToSortedCidSet().ToSlice(), uint64(3), uint64(30000)).Return()
+	msc2.On("SyncCallback", a.ID(), heavy1.ToSortedCidSet().ToSlice(), uint64(2), uint64(20000)).Return()
 
 	require.NoError(t, mn.LinkAll())
 	require.NoError(t, mn.ConnectAllButSelf())
@@ -85,6 +88,11 @@ func TestHelloHandshake(t *testing.T) {
 
 		return msc1Done && msc2Done, nil
 	}))
+
+	// a measured the latency to b when saying hello.
+	stats := statsA.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, b.ID(), stats[0].Peer)
 }
 
 func TestHelloBadGenesis(t *testing.T) {
@@ -102,17 +110,17 @@ func TestHelloBadGenesis(t *testing.T) {
 	genesisA := &types.Block{Nonce: 451}
 	genesisB := &types.Block{Nonce: 101}
 
-	heavy1 := th.RequireNewTipSet(t, &types.Block{Nonce: 1000, Height: 2})
-	heavy2 := th.RequireNewTipSet(t, &types.Block{Nonce: 1001, Height: 3})
+	heavy1 := th.RequireNewTipSet(t, &types.Block{Nonce: 1000, Height: 2, ParentWeight: 20000})
+	heavy2 := th.RequireNewTipSet(t, &types.Block{Nonce: 1001, Height: 3, ParentWeight: 30000})
 
 	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
 	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
 
-	New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet, "", "")
-	New(b, genesisB.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet, "", "")
+	New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet, fnet.NewPeerStats(), "", "")
+	New(b, genesisB.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet, fnet.NewPeerStats(), "", "")
 
-	msc1.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything).Return()
-	msc2.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything).Return()
+	msc1.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	msc2.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
 
 	require.NoError(t, mn.LinkAll())
 	require.NoError(t, mn.ConnectAllButSelf())
@@ -123,6 +131,111 @@ func TestHelloBadGenesis(t *testing.T) {
 	msc2.AssertNumberOfCalls(t, "SyncCallback", 0)
 }
 
+func TestHelloRefuseForeignPeers(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(t, err)
+
+	a, b := mn.Hosts()[0], mn.Hosts()[1]
+
+	genesisA := &types.Block{Nonce: 451}
+	genesisB := &types.Block{Nonce: 101}
+
+	heavy := th.RequireNewTipSet(t, &types.Block{Nonce: 1000, Height: 2})
+
+	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
+	hg := &mockHeaviestGetter{heavy}
+
+	refused := make(chan peer.ID, 1)
+	New(a, genesisA.Cid(), msc1.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "", "").RefuseForeignPeers(func(p peer.ID) {
+		refused <- p
+	})
+	New(b, genesisB.Cid(), msc2.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "", "")
+
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	select {
+	case p := <-refused:
+		assert.Equal(t, b.ID(), p)
+	case <-time.After(5 * time.Second):
+		t.Fatal("peer announcing another genesis was not refused")
+	}
+	require.NoError(t, th.WaitForIt(10, 50*time.Millisecond, func() (bool, error) {
+		return len(a.Network().ConnsToPeer(b.ID())) == 0, nil
+	}))
+	msc1.AssertNumberOfCalls(t, "SyncCallback", 0)
+}
+
+func TestHelloProtocolVersion(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	genesis := &types.Block{Nonce: 451}
+	heavy := th.RequireNewTipSet(t, &types.Block{Nonce: 1000, Height: 2})
+	hg := &mockHeaviestGetter{heavy}
+
+	t.Run("records the version and features of peers", func(t *testing.T) {
+		mn, err := mocknet.WithNPeers(ctx, 2)
+		require.NoError(t, err)
+		a, b := mn.Hosts()[0], mn.Hosts()[1]
+
+		msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
+		msc1.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+		msc2.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+		helloA := New(a, genesis.Cid(), msc1.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "", "sha1")
+		New(b, genesis.Cid(), msc2.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "", "sha2").Advertise(FeatureAncestors)
+
+		require.NoError(t, mn.LinkAll())
+		require.NoError(t, mn.ConnectAllButSelf())
+
+		var pv *PeerVersion
+		require.NoError(t, th.WaitForIt(10, 50*time.Millisecond, func() (bool, error) {
+			var ok bool
+			pv, ok = helloA.PeerVersion(b.ID())
+			return ok, nil
+		}))
+		assert.Equal(t, uint64(ProtocolVersion), pv.ProtocolVersion)
+		assert.Equal(t, "sha2", pv.CommitSha)
+		assert.True(t, pv.HasFeature(FeatureAncestors))
+		assert.False(t, pv.HasFeature(FeaturePeerExchange))
+	})
+
+	t.Run("disconnects from peers of incompatible versions", func(t *testing.T) {
+		mn, err := mocknet.WithNPeers(ctx, 2)
+		require.NoError(t, err)
+		a, b := mn.Hosts()[0], mn.Hosts()[1]
+
+		msc := new(mockSyncCallback)
+		New(a, genesis.Cid(), msc.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "", "")
+		require.NoError(t, mn.LinkAll())
+		_, err = mn.ConnectPeers(b.ID(), a.ID())
+		require.NoError(t, err)
+
+		// b says hello as a node of a protocol version before the lowest
+		// supported.
+		s, err := b.NewStream(ctx, a.ID(), protocol)
+		require.NoError(t, err)
+		msg := &Message{
+			GenesisHash:        genesis.Cid(),
+			HeaviestTipSetCids: heavy.ToSortedCidSet().ToSlice(),
+			ProtocolVersion:    MinProtocolVersion - 1,
+		}
+		require.NoError(t, cbu.NewMsgWriter(s).WriteMsg(msg))
+
+		require.NoError(t, th.WaitForIt(10, 50*time.Millisecond, func() (bool, error) {
+			return len(a.Network().ConnsToPeer(b.ID())) == 0, nil
+		}))
+		msc.AssertNumberOfCalls(t, "SyncCallback", 0)
+	})
+}
+
 func TestHelloWrongVersion(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -141,11 +254,11 @@ func TestHelloWrongVersion(t *testing.T) {
 	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
 	hg := &mockHeaviestGetter{heavy}
 
-	New(a, genesisA.Cid(), msc1.SyncCallback, hg.getHeaviestTipSet, "devnet-user", "sha1")
-	msc1.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything).Return()
+	New(a, genesisA.Cid(), msc1.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "devnet-user", "sha1")
+	msc1.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
 
-	New(b, genesisA.Cid(), msc2.SyncCallback, hg.getHeaviestTipSet, "devnet-user", "sha2")
-	msc2.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything).Return()
+	New(b, genesisA.Cid(), msc2.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "devnet-user", "sha2")
+	msc2.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
 
 	require.NoError(t, mn.LinkAll())
 	require.NoError(t, mn.ConnectAllButSelf())
@@ -174,11 +287,11 @@ func TestHelloWrongVersionTestDevnet(t *testing.T) {
 	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
 	hg := &mockHeaviestGetter{heavy}
 
-	New(a, genesisA.Cid(), msc1.SyncCallback, hg.getHeaviestTipSet, "devnet-test", "sha1")
-	msc1.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything).Return()
+	New(a, genesisA.Cid(), msc1.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "devnet-test", "sha1")
+	msc1.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
 
-	New(b, genesisA.Cid(), msc2.SyncCallback, hg.getHeaviestTipSet, "devnet-test", "sha2")
-	msc2.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything).Return()
+	New(b, genesisA.Cid(), msc2.SyncCallback, hg.getHeaviestTipSet, fnet.NewPeerStats(), "devnet-test", "sha2")
+	msc2.On("SyncCallback", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
 
 	require.NoError(t, mn.LinkAll())
 	require.NoError(t, mn.ConnectAllButSelf())
@@ -217,11 +330,11 @@ func TestHelloMultiBlock(t *testing.T) {
 	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
 	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
 
-	New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet, "", "")
-	New(b, genesisA.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet, "", "")
+	New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet, fnet.NewPeerStats(), "", "")
+	New(b, genesisA.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet, fnet.NewPeerStats(), "", "")
 
-	msc1.On("SyncCallback", b.ID(), heavy2.ToSortedCidSet().ToSlice(), uint64(3)).Return()
-	msc2.On("SyncCallback", a.ID(), heavy1.ToSortedCidSet().ToSlice(), uint64(2)).Return()
+	msc1.On("SyncCallback", b.ID(), heavy2.ToSortedCidSet().ToSlice(), uint64(3), uint64(0)).Return()
+	msc2.On("SyncCallback", a.ID(), heavy1.ToSortedCidSet().ToSlice(), uint64(2), uint64(0)).Return()
 
 	assert.NoError(t, mn.LinkAll())
 	assert.NoError(t, mn.ConnectAllButSelf())
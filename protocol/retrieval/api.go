@@ -6,21 +6,37 @@ import (
 
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
 )
 
-// API here is the API for a retrieval client.
+// ErrNoRetrievalMiner is returned by miner calls when the node is not serving
+// retrievals.
+var ErrNoRetrievalMiner = errors.New("node is not running a retrieval miner")
+
+// API here is the API for a retrieval client and the node's retrieval miner.
 type API struct {
-	rc *Client
+	rc    *Client
+	miner func() *Miner
 }
 
-// NewAPI creates a new API for a retrieval client.
-func NewAPI(rc *Client) API {
-	return API{rc: rc}
+// NewAPI creates a new API for a retrieval client.  The retrieval miner is
+// looked up on each call as it is only created once the node starts.
+func NewAPI(rc *Client, retrievalMiner func() *Miner) API {
+	return API{rc: rc, miner: retrievalMiner}
 }
 
 // RetrievePiece retrieves bytes referenced by CID pieceCID
 func (a *API) RetrievePiece(ctx context.Context, pieceCID cid.Cid, mpid peer.ID, minerAddr address.Address) (io.ReadCloser, error) {
 	return a.rc.RetrievePiece(ctx, mpid, pieceCID)
 }
+
+// UnsealJobs calls the retrieval miner UnsealJobs function
+func (a *API) UnsealJobs() ([]UnsealJob, error) {
+	rm := a.miner()
+	if rm == nil {
+		return nil, ErrNoRetrievalMiner
+	}
+	return rm.UnsealJobs(), nil
+}
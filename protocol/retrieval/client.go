@@ -52,7 +52,7 @@ func (sc *Client) RetrievePiece(ctx context.Context, minerPeerID peer.ID, pieceC
 	if err != nil {
 		return nil, err
 	}
-	s, err := sc.host.NewStream(ctx, minerPeerID, retrievalFreeProtocol)
+	s, err := sc.host.NewStream(ctx, minerPeerID, FreeProtocol)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create stream to retrieval miner")
 	}
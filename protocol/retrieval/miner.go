@@ -1,8 +1,10 @@
 package retrieval
 
 import (
-	"io/ioutil"
+	"context"
+	"io"
 
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
 	host "github.com/libp2p/go-libp2p-host"
 	inet "github.com/libp2p/go-libp2p-net"
@@ -14,7 +16,13 @@ import (
 
 var log = logging.Logger("/fil/retrieval")
 
-const retrievalFreeProtocol = protocol.ID("/fil/retrieval/free/0.0.0")
+// FreeProtocol is the libp2p protocol identifier for retrieving pieces for
+// free.
+const FreeProtocol = protocol.ID("/fil/retrieval/free/0.0.0")
+
+// maxUnsealJobs is the number of pieces a Miner unseals at once while it is
+// not sealing.
+const maxUnsealJobs = 2
 
 // TODO: better name
 type minerNode interface {
@@ -24,20 +32,32 @@ type minerNode interface {
 
 // Miner serves requests for pieces from RetrievalClients.
 type Miner struct {
-	node minerNode
+	node   minerNode
+	unseal *UnsealQueue
 }
 
 // NewMiner is used to create a Miner and bind a handling function to the piece retrieval protocol.
-func NewMiner(nd minerNode) *Miner {
+// Requested pieces are unsealed through a queue that throttles unsealing while
+// sealing reports that the node's storage miner is sealing sectors.
+func NewMiner(nd minerNode, sealing func() bool) *Miner {
 	rm := &Miner{
 		node: nd,
 	}
+	rm.unseal = NewUnsealQueue(func(pieceRef cid.Cid) (io.Reader, error) {
+		return nd.SectorBuilder().ReadPieceFromSealedSector(pieceRef)
+	}, sealing, maxUnsealJobs)
 
-	nd.Host().SetStreamHandler(retrievalFreeProtocol, rm.handleRetrievePieceForFree)
+	nd.Host().SetStreamHandler(FreeProtocol, rm.handleRetrievePieceForFree)
 
 	return rm
 }
 
+// UnsealJobs returns the status of the miner's active and recently finished
+// unseal jobs.
+func (rm *Miner) UnsealJobs() []UnsealJob {
+	return rm.unseal.Jobs()
+}
+
 func (rm *Miner) handleRetrievePieceForFree(s inet.Stream) {
 	defer s.Close() // nolint: errcheck
 
@@ -47,9 +67,9 @@ func (rm *Miner) handleRetrievePieceForFree(s inet.Stream) {
 		return
 	}
 
-	reader, err := rm.node.SectorBuilder().ReadPieceFromSealedSector(req.PieceRef)
+	bs, err := rm.unseal.Unseal(context.Background(), req.PieceRef, UnsealPriorityRetrieval)
 	if err != nil {
-		log.Warningf("failed to obtain a reader for piece with CID %s: %s", req.PieceRef.String(), err)
+		log.Warningf("failed to unseal piece with CID %s: %s", req.PieceRef.String(), err)
 
 		resp := RetrievePieceResponse{
 			Status:       Failure,
@@ -63,11 +83,6 @@ func (rm *Miner) handleRetrievePieceForFree(s inet.Stream) {
 		return
 	}
 
-	bs, err := ioutil.ReadAll(reader)
-	if err != nil {
-		log.Errorf("failed to read all bytes: %s", err)
-	}
-
 	resp := RetrievePieceResponse{
 		Status: Success,
 	}
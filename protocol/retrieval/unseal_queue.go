@@ -0,0 +1,237 @@
+package retrieval
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// maxFinishedUnsealJobs is the number of finished unseal jobs whose status is
+// remembered by an UnsealQueue.
+const maxFinishedUnsealJobs = 100
+
+// UnsealPriority orders the jobs waiting in an UnsealQueue, jobs with a higher
+// priority are started first.
+type UnsealPriority int
+
+const (
+	// UnsealPriorityBackground is the priority of unseals nobody is waiting on.
+	UnsealPriorityBackground = UnsealPriority(iota)
+	// UnsealPriorityRetrieval is the priority of unseals a retrieval client is
+	// waiting on.
+	UnsealPriorityRetrieval
+)
+
+func (p UnsealPriority) String() string {
+	switch p {
+	case UnsealPriorityBackground:
+		return "background"
+	case UnsealPriorityRetrieval:
+		return "retrieval"
+	default:
+		return "unknown"
+	}
+}
+
+// UnsealJobState is the state of an unseal job.
+type UnsealJobState string
+
+const (
+	// UnsealQueued means the job is waiting for a free slot.
+	UnsealQueued = UnsealJobState("queued")
+	// UnsealRunning means the piece is being unsealed.
+	UnsealRunning = UnsealJobState("running")
+	// UnsealDone means the piece was unsealed.
+	UnsealDone = UnsealJobState("done")
+	// UnsealFailed means the piece could not be unsealed.
+	UnsealFailed = UnsealJobState("failed")
+)
+
+// UnsealJob is the status of a request to unseal a piece.
+type UnsealJob struct {
+	ID       uint64         `json:"id"`
+	PieceRef cid.Cid        `json:"pieceRef"`
+	Priority UnsealPriority `json:"priority"`
+	State    UnsealJobState `json:"state"`
+	// Requests is the number of requests for the piece served by this job.
+	Requests int        `json:"requests"`
+	Enqueued time.Time  `json:"enqueued"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// unsealJob is an UnsealJob along with its result.
+type unsealJob struct {
+	UnsealJob
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// unsealer reads a piece from the sealed sector holding it.
+type unsealer func(pieceRef cid.Cid) (io.Reader, error)
+
+// UnsealQueue runs the unsealing of pieces in the background.  Requests for
+// a piece that is already being unsealed share the job's result, waiting jobs
+// are started highest priority first, and the number of jobs running at once
+// is limited so that unsealing does not starve sealing of resources.
+type UnsealQueue struct {
+	unseal unsealer
+	// sealing reports whether the miner is sealing sectors, in which case
+	// only a single job runs at a time.
+	sealing func() bool
+	maxJobs int
+
+	lk       sync.Mutex
+	nextID   uint64
+	active   map[cid.Cid]*unsealJob
+	waiting  []*unsealJob
+	running  int
+	finished []UnsealJob
+}
+
+// NewUnsealQueue returns an UnsealQueue running up to maxJobs unseals at once
+// while sealing returns false, and one at a time while it returns true.
+func NewUnsealQueue(unseal unsealer, sealing func() bool, maxJobs int) *UnsealQueue {
+	if maxJobs < 1 {
+		maxJobs = 1
+	}
+	return &UnsealQueue{
+		unseal:  unseal,
+		sealing: sealing,
+		maxJobs: maxJobs,
+		active:  make(map[cid.Cid]*unsealJob),
+	}
+}
+
+// Unseal enqueues the unsealing of pieceRef, or joins the job already
+// unsealing it, and waits for the piece's bytes.  Cancelling ctx stops the
+// wait but not the job.
+func (q *UnsealQueue) Unseal(ctx context.Context, pieceRef cid.Cid, priority UnsealPriority) ([]byte, error) {
+	job := q.enqueue(pieceRef, priority)
+	select {
+	case <-job.done:
+		return job.data, job.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Jobs returns the status of the active jobs and of the most recently
+// finished ones, ordered by id.
+func (q *UnsealQueue) Jobs() []UnsealJob {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	var jobs []UnsealJob
+	for _, job := range q.active {
+		jobs = append(jobs, job.UnsealJob)
+	}
+	jobs = append(jobs, q.finished...)
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+func (q *UnsealQueue) enqueue(pieceRef cid.Cid, priority UnsealPriority) *unsealJob {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	if job, ok := q.active[pieceRef]; ok {
+		job.Requests++
+		if priority > job.Priority {
+			job.Priority = priority
+		}
+		return job
+	}
+
+	q.nextID++
+	job := &unsealJob{
+		UnsealJob: UnsealJob{
+			ID:       q.nextID,
+			PieceRef: pieceRef,
+			Priority: priority,
+			State:    UnsealQueued,
+			Requests: 1,
+			Enqueued: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	q.active[pieceRef] = job
+	q.waiting = append(q.waiting, job)
+	q.schedule()
+	return job
+}
+
+// schedule starts waiting jobs while there are free slots.  q.lk must be
+// held.
+func (q *UnsealQueue) schedule() {
+	limit := q.maxJobs
+	if q.sealing != nil && q.sealing() {
+		limit = 1
+	}
+	for q.running < limit && len(q.waiting) > 0 {
+		// Highest priority first, oldest first within a priority.
+		next := 0
+		for i, job := range q.waiting {
+			if job.Priority > q.waiting[next].Priority {
+				next = i
+			}
+		}
+		job := q.waiting[next]
+		q.waiting = append(q.waiting[:next], q.waiting[next+1:]...)
+
+		now := time.Now()
+		job.State = UnsealRunning
+		job.Started = &now
+		q.running++
+		go q.run(job)
+	}
+}
+
+func (q *UnsealQueue) run(job *unsealJob) {
+	data, err := q.read(job.PieceRef)
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	now := time.Now()
+	job.Finished = &now
+	job.data, job.err = data, err
+	if err != nil {
+		job.State = UnsealFailed
+		job.Error = err.Error()
+	} else {
+		job.State = UnsealDone
+	}
+	close(job.done)
+
+	delete(q.active, job.PieceRef)
+	// Only the status of finished jobs is kept, so their data is released
+	// once all waiters are done with it.
+	q.finished = append(q.finished, job.UnsealJob)
+	if len(q.finished) > maxFinishedUnsealJobs {
+		q.finished = q.finished[1:]
+	}
+
+	q.running--
+	q.schedule()
+}
+
+func (q *UnsealQueue) read(pieceRef cid.Cid) ([]byte, error) {
+	reader, err := q.unseal(pieceRef)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain a reader for piece with CID %s", pieceRef)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read piece with CID %s", pieceRef)
+	}
+	return data, nil
+}
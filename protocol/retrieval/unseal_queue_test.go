@@ -0,0 +1,132 @@
+package retrieval_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/protocol/retrieval"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestUnsealQueue(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	newCid := types.NewCidForTestGetter()
+
+	t.Run("deduplicates requests for the same piece", func(t *testing.T) {
+		u := newBlockingUnsealer()
+		q := retrieval.NewUnsealQueue(u.unseal, nil, 1)
+		piece := newCid()
+
+		results := make(chan []byte, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				data, err := q.Unseal(ctx, piece, retrieval.UnsealPriorityRetrieval)
+				assert.NoError(t, err)
+				results <- data
+			}()
+		}
+		assert.Equal(t, piece, <-u.started)
+		requireJobs(t, q, func(jobs []retrieval.UnsealJob) bool { return len(jobs) == 1 && jobs[0].Requests == 2 })
+		u.release <- nil
+
+		assert.Equal(t, piece.Bytes(), <-results)
+		assert.Equal(t, piece.Bytes(), <-results)
+		jobs := q.Jobs()
+		require.Len(t, jobs, 1)
+		assert.Equal(t, retrieval.UnsealDone, jobs[0].State)
+		assert.Equal(t, 2, jobs[0].Requests)
+	})
+
+	t.Run("starts retrieval jobs first", func(t *testing.T) {
+		u := newBlockingUnsealer()
+		q := retrieval.NewUnsealQueue(u.unseal, nil, 1)
+		first, background, retrieve := newCid(), newCid(), newCid()
+
+		go q.Unseal(ctx, first, retrieval.UnsealPriorityBackground) // nolint: errcheck
+		assert.Equal(t, first, <-u.started)
+		go q.Unseal(ctx, background, retrieval.UnsealPriorityBackground) // nolint: errcheck
+		requireJobs(t, q, func(jobs []retrieval.UnsealJob) bool { return len(jobs) == 2 })
+		go q.Unseal(ctx, retrieve, retrieval.UnsealPriorityRetrieval) // nolint: errcheck
+		requireJobs(t, q, func(jobs []retrieval.UnsealJob) bool { return len(jobs) == 3 })
+
+		u.release <- nil
+		assert.Equal(t, retrieve, <-u.started)
+		u.release <- nil
+		assert.Equal(t, background, <-u.started)
+		u.release <- nil
+	})
+
+	t.Run("runs one job at a time while sealing", func(t *testing.T) {
+		u := newBlockingUnsealer()
+		q := retrieval.NewUnsealQueue(u.unseal, func() bool { return true }, 2)
+		a, b := newCid(), newCid()
+
+		go q.Unseal(ctx, a, retrieval.UnsealPriorityRetrieval) // nolint: errcheck
+		assert.Equal(t, a, <-u.started)
+		go q.Unseal(ctx, b, retrieval.UnsealPriorityRetrieval) // nolint: errcheck
+		requireJobs(t, q, func(jobs []retrieval.UnsealJob) bool { return len(jobs) == 2 })
+		assert.Equal(t, retrieval.UnsealQueued, q.Jobs()[1].State)
+
+		u.release <- nil
+		assert.Equal(t, b, <-u.started)
+		u.release <- nil
+	})
+
+	t.Run("reports failed jobs", func(t *testing.T) {
+		u := newBlockingUnsealer()
+		q := retrieval.NewUnsealQueue(u.unseal, nil, 1)
+		piece := newCid()
+
+		go func() {
+			assert.Equal(t, piece, <-u.started)
+			u.release <- errors.New("piece not found")
+		}()
+		_, err := q.Unseal(ctx, piece, retrieval.UnsealPriorityRetrieval)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "piece not found")
+
+		jobs := q.Jobs()
+		require.Len(t, jobs, 1)
+		assert.Equal(t, retrieval.UnsealFailed, jobs[0].State)
+		assert.Contains(t, jobs[0].Error, "piece not found")
+	})
+}
+
+// blockingUnsealer announces each piece it is asked to unseal on started and
+// then blocks until it is sent the error to return on release.  Unsealed
+// pieces contain their cid's bytes.
+type blockingUnsealer struct {
+	started chan cid.Cid
+	release chan error
+}
+
+func newBlockingUnsealer() *blockingUnsealer {
+	return &blockingUnsealer{started: make(chan cid.Cid), release: make(chan error)}
+}
+
+func (u *blockingUnsealer) unseal(pieceRef cid.Cid) (io.Reader, error) {
+	u.started <- pieceRef
+	if err := <-u.release; err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(pieceRef.Bytes()), nil
+}
+
+// requireJobs waits until the queue's jobs satisfy cond.
+func requireJobs(t *testing.T, q *retrieval.UnsealQueue, cond func([]retrieval.UnsealJob) bool) {
+	require.NoError(t, th.WaitForIt(100, 10*time.Millisecond, func() (bool, error) {
+		return cond(q.Jobs()), nil
+	}))
+}
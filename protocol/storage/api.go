@@ -4,20 +4,29 @@ import (
 	"context"
 
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
-// API here is the API for a storage client.
+// ErrNoStorageMiner is returned by miner calls when the node is not running
+// a storage miner.
+var ErrNoStorageMiner = errors.New("node is not running a storage miner")
+
+// API here is the API for a storage client and, when mining, the node's
+// storage miner.
 type API struct {
-	sc *Client
+	sc    *Client
+	miner func() *Miner
 }
 
-// NewAPI creates a new API for a storage client.
-func NewAPI(storageClient *Client) API {
-	return API{sc: storageClient}
+// NewAPI creates a new API for a storage client.  The storage miner is looked
+// up on each call as it is only created once mining starts, the getter
+// returning nil while there is none.
+func NewAPI(storageClient *Client, storageMiner func() *Miner) API {
+	return API{sc: storageClient, miner: storageMiner}
 }
 
 // ProposeStorageDeal calls the storage client ProposeDeal function
@@ -36,3 +45,21 @@ func (a *API) QueryStorageDeal(ctx context.Context, prop cid.Cid) (*storagedeal.
 func (a *API) Payments(ctx context.Context, dealCid cid.Cid) ([]*types.PaymentVoucher, error) {
 	return a.sc.LoadVouchersForDeal(dealCid)
 }
+
+// MinerProvingStatus calls the storage miner ProvingStatus function
+func (a *API) MinerProvingStatus(ctx context.Context) (*ProvingStatus, error) {
+	sm := a.miner()
+	if sm == nil {
+		return nil, ErrNoStorageMiner
+	}
+	return sm.ProvingStatus(ctx)
+}
+
+// MinerStats calls the storage miner Stats function
+func (a *API) MinerStats(ctx context.Context) (*MinerStats, error) {
+	sm := a.miner()
+	if sm == nil {
+		return nil, ErrNoStorageMiner
+	}
+	return sm.Stats(ctx)
+}
@@ -18,6 +18,7 @@ import (
 	uio "github.com/ipfs/go-unixfs/io"
 	host "github.com/libp2p/go-libp2p-host"
 	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
 	"github.com/libp2p/go-libp2p-protocol"
 	"github.com/pkg/errors"
 
@@ -27,6 +28,9 @@ import (
 	"github.com/filecoin-project/go-filecoin/address"
 	cbu "github.com/filecoin-project/go-filecoin/cborutil"
 	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/metrics"
+	fnet "github.com/filecoin-project/go-filecoin/net"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
@@ -37,6 +41,8 @@ import (
 
 var log = logging.Logger("/fil/storage")
 
+var duplicatePiecesCt = metrics.NewInt64Counter("storage/duplicate_pieces", "Number of deals whose piece the miner had already stored")
+
 const makeDealProtocol = protocol.ID("/fil/storage/mk/1.0.0")
 const queryDealProtocol = protocol.ID("/fil/storage/qry/1.0.0")
 
@@ -58,8 +64,16 @@ type Miner struct {
 	postInProcessLk sync.Mutex
 	postInProcess   *types.BlockHeight
 
+	recentPoStsLk sync.Mutex
+	recentPoSts   []*PoStResult
+
 	dealsAwaitingSeal *dealsAwaitingSealStruct
 
+	// transfers release the protection of the clients sending the data of
+	// deals, by proposal cid, once the data is received.
+	transfersLk sync.Mutex
+	transfers   map[cid.Cid]func()
+
 	porcelainAPI minerPorcelain
 	node         node
 
@@ -82,6 +96,7 @@ type minerPorcelain interface {
 	MessageSend(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
 	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
+	MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error)
 
 	MinerGetSectorSize(ctx context.Context, minerAddr address.Address) (*types.BytesAmount, error)
 }
@@ -94,6 +109,12 @@ type node interface {
 	BlockService() bserv.BlockService
 	Host() host.Host
 	SectorBuilder() sectorbuilder.SectorBuilder
+	// ProtectPeer keeps the connection to p from being pruned until the
+	// returned function is called.
+	ProtectPeer(p peer.ID, tag string) func()
+	// Supervise runs the named task in the background, recovering and
+	// restarting it if it panics.
+	Supervise(name string, run func(context.Context))
 }
 
 // generatePostInput is a struct containing sector id and related commitments
@@ -107,6 +128,7 @@ type generatePostInput struct {
 
 func init() {
 	cbor.RegisterCborType(dealsAwaitingSealStruct{})
+	cbor.RegisterCborType(stagedPiece{})
 }
 
 // NewMiner is
@@ -119,6 +141,7 @@ func NewMiner(minerAddr, minerOwnerAddr address.Address, nd node, dealsDs repo.D
 		node:                nd,
 		proposalAcceptor:    acceptProposal,
 		proposalRejector:    rejectProposal,
+		transfers:           make(map[cid.Cid]func()),
 	}
 
 	if err := sm.loadDealsAwaitingSeal(); err != nil {
@@ -142,8 +165,20 @@ func (sm *Miner) handleMakeDeal(s inet.Stream) {
 		return
 	}
 
+	// Keep the client connected while it sends the data, which starts as
+	// soon as the proposal is accepted.
+	proposalCid, err := convert.ToCid(&signedProposal.Proposal)
+	if err != nil {
+		log.Errorf("failed to get cid of proposal: %s", err)
+		return
+	}
+	sm.protectTransfer(s.Conn().RemotePeer(), proposalCid)
+
 	ctx := context.Background()
 	resp, err := sm.receiveStorageProposal(ctx, &signedProposal)
+	if err != nil || resp.State != storagedeal.Accepted {
+		sm.releaseTransfer(proposalCid)
+	}
 	if err != nil {
 		log.Errorf("failed to process proposal: %s", err)
 		return
@@ -185,6 +220,29 @@ func (sm *Miner) receiveStorageProposal(ctx context.Context, sp *storagedeal.Sig
 	return sm.proposalAcceptor(sm, p)
 }
 
+// protectTransfer protects the connection to the client p until the data of
+// the deal proposed in proposalCid is received.
+func (sm *Miner) protectTransfer(p peer.ID, proposalCid cid.Cid) {
+	sm.transfersLk.Lock()
+	defer sm.transfersLk.Unlock()
+	if _, ok := sm.transfers[proposalCid]; ok {
+		return
+	}
+	sm.transfers[proposalCid] = sm.node.ProtectPeer(p, fnet.StorageDealTag)
+}
+
+// releaseTransfer releases the protection of the client sending the data of
+// the deal proposed in proposalCid, if any.
+func (sm *Miner) releaseTransfer(proposalCid cid.Cid) {
+	sm.transfersLk.Lock()
+	release, ok := sm.transfers[proposalCid]
+	delete(sm.transfers, proposalCid)
+	sm.transfersLk.Unlock()
+	if ok {
+		release()
+	}
+}
+
 func (sm *Miner) validateDealPayment(ctx context.Context, p *storagedeal.Proposal) error {
 	// compute expected total price for deal (storage price * duration * bytes)
 	price, err := sm.getStoragePrice()
@@ -351,7 +409,9 @@ func acceptProposal(sm *Miner, p *storagedeal.Proposal) (*storagedeal.Response,
 	}
 
 	// TODO: use some sort of nicer scheduler
-	go sm.processStorageDeal(proposalCid)
+	sm.node.Supervise("storage deal processor", func(context.Context) {
+		sm.processStorageDeal(proposalCid)
+	})
 
 	return resp, nil
 }
@@ -400,6 +460,7 @@ func (sm *Miner) processStorageDeal(proposalCid cid.Cid) {
 	log.Debugf("Miner.processStorageDeal(%s)", proposalCid.String())
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	defer sm.releaseTransfer(proposalCid)
 
 	d := sm.porcelainAPI.DealGet(proposalCid)
 	if d == nil {
@@ -414,8 +475,14 @@ func (sm *Miner) processStorageDeal(proposalCid cid.Cid) {
 	// 'Receive' the data, this could also be a truck full of hard drives. (TODO: proper abstraction)
 	// TODO: this is not a great way to do this. At least use a session
 	// Also, this needs to be fetched into a staging area for miners to prepare and seal in data
+	if sm.storeDuplicatePiece(proposalCid, d) {
+		return
+	}
+
 	log.Debug("Miner.processStorageDeal - FetchGraph")
-	if err := dag.FetchGraph(ctx, d.Proposal.PieceRef, dag.NewDAGService(sm.node.BlockService())); err != nil {
+	err := dag.FetchGraph(ctx, d.Proposal.PieceRef, dag.NewDAGService(sm.node.BlockService()))
+	sm.releaseTransfer(proposalCid)
+	if err != nil {
 		log.Errorf("failed to fetch data: %s", err)
 		err := sm.updateDealResponse(proposalCid, func(resp *storagedeal.Response) {
 			resp.Message = "Transfer failed"
@@ -468,7 +535,14 @@ func (sm *Miner) processStorageDeal(proposalCid cid.Cid) {
 		return
 	}
 
-	err = sm.updateDealResponse(proposalCid, func(resp *storagedeal.Response) {
+	sm.dealsAwaitingSeal.stagePiece(sectorID, d.Proposal.PieceRef, d.Proposal.Size.Uint64())
+	sm.stageDeal(proposalCid, sectorID)
+}
+
+// stageDeal records that the piece of the deal is in the sector, to update
+// the deal once the sector is sealed.
+func (sm *Miner) stageDeal(proposalCid cid.Cid, sectorID uint64) {
+	err := sm.updateDealResponse(proposalCid, func(resp *storagedeal.Response) {
 		resp.State = storagedeal.Staged
 	})
 	if err != nil {
@@ -483,6 +557,53 @@ func (sm *Miner) processStorageDeal(proposalCid cid.Cid) {
 	}
 }
 
+// storeDuplicatePiece references the piece of another deal of the miner if it
+// has the same data as the deal's, rather than storing the data again, and
+// returns true if it did.  Pieces are identified by their ref and size.  The
+// deal is still paid for and tracked on its own: it is staged in the sector
+// of a piece that is not sealed yet, or posted with the proof of a piece that
+// is.
+func (sm *Miner) storeDuplicatePiece(proposalCid cid.Cid, d *storagedeal.Deal) bool {
+	ref, size := d.Proposal.PieceRef, d.Proposal.Size.Uint64()
+
+	if sectorID, ok := sm.dealsAwaitingSeal.stagedSector(ref, size); ok {
+		log.Infof("deal %s stores piece %s already staged in sector %d", proposalCid, ref, sectorID)
+		duplicatePiecesCt.Inc(context.Background(), 1)
+		sm.stageDeal(proposalCid, sectorID)
+		return true
+	}
+
+	deals, err := sm.porcelainAPI.DealsLs()
+	if err != nil {
+		log.Warningf("failed to list deals to find duplicate pieces: %s", err)
+		return false
+	}
+	for _, other := range deals {
+		if other.Miner != sm.minerAddr || other.Proposal == nil || other.Response == nil {
+			continue
+		}
+		if other.Response.State != storagedeal.Posted || other.Response.ProofInfo == nil {
+			continue
+		}
+		if !other.Proposal.PieceRef.Equals(ref) || other.Proposal.Size.Uint64() != size {
+			continue
+		}
+
+		proofInfo := *other.Response.ProofInfo
+		log.Infof("deal %s stores piece %s already sealed in sector %d", proposalCid, ref, proofInfo.SectorID)
+		duplicatePiecesCt.Inc(context.Background(), 1)
+		err := sm.updateDealResponse(proposalCid, func(resp *storagedeal.Response) {
+			resp.State = storagedeal.Posted
+			resp.ProofInfo = &proofInfo
+		})
+		if err != nil {
+			log.Errorf("could not update deal with duplicate piece to 'Posted' state: %s", err)
+		}
+		return true
+	}
+	return false
+}
+
 // dealsAwaitingSealStruct is a container for keeping track of which sectors have
 // pieces from which deals. We need it to accommodate a race condition where
 // a sector commit message is added to chain before we can add the sector/deal
@@ -498,17 +619,26 @@ type dealsAwaitingSealStruct struct {
 	FailedSectors map[uint64]string
 	// Maps from sector id to the sector's commitSector message CID
 	CommitmentMessages map[uint64]cid.Cid
+	// Maps from piece cid to the pieces of sectors that are not sealed yet.
+	StagedPieces map[string]stagedPiece
 
 	onSuccess func(dealCid cid.Cid, sector *sectorbuilder.SealedSectorMetadata)
 	onFail    func(dealCid cid.Cid, message string)
 }
 
+// stagedPiece is a piece in a sector that is not sealed yet.
+type stagedPiece struct {
+	SectorID uint64
+	Size     uint64
+}
+
 func (sm *Miner) loadDealsAwaitingSeal() error {
 	sm.dealsAwaitingSeal = &dealsAwaitingSealStruct{
 		SectorsToDeals:     make(map[uint64][]cid.Cid),
 		SuccessfulSectors:  make(map[uint64]*sectorbuilder.SealedSectorMetadata),
 		FailedSectors:      make(map[uint64]string),
 		CommitmentMessages: make(map[uint64]cid.Cid),
+		StagedPieces:       make(map[string]stagedPiece),
 	}
 
 	key := datastore.KeyWithNamespaces([]string{dealsAwatingSealDatastorePrefix})
@@ -536,6 +666,15 @@ func (sm *Miner) saveDealsAwaitingSeal() error {
 	return nil
 }
 
+// IsSealing returns true if the miner has staged pieces from deals in sectors
+// that have not finished sealing.
+func (sm *Miner) IsSealing() bool {
+	sm.dealsAwaitingSeal.l.Lock()
+	defer sm.dealsAwaitingSeal.l.Unlock()
+
+	return len(sm.dealsAwaitingSeal.SectorsToDeals) > 0
+}
+
 func (dealsAwaitingSeal *dealsAwaitingSealStruct) addCommitmentMessageCid(sectorID uint64, msgCid cid.Cid) {
 	dealsAwaitingSeal.l.Lock()
 	defer dealsAwaitingSeal.l.Unlock()
@@ -572,11 +711,55 @@ func (dealsAwaitingSeal *dealsAwaitingSealStruct) add(sectorID uint64, dealCid c
 	}
 }
 
+// stagePiece records that the piece with the given ref and size was added to
+// the sector, so that deals for the same piece can reference it until the
+// sector is sealed.
+func (dealsAwaitingSeal *dealsAwaitingSealStruct) stagePiece(sectorID uint64, ref cid.Cid, size uint64) {
+	dealsAwaitingSeal.l.Lock()
+	defer dealsAwaitingSeal.l.Unlock()
+
+	// The sector may already have been sealed, see add().
+	if _, ok := dealsAwaitingSeal.SuccessfulSectors[sectorID]; ok {
+		return
+	}
+	if _, ok := dealsAwaitingSeal.FailedSectors[sectorID]; ok {
+		return
+	}
+	if dealsAwaitingSeal.StagedPieces == nil {
+		dealsAwaitingSeal.StagedPieces = make(map[string]stagedPiece)
+	}
+	dealsAwaitingSeal.StagedPieces[ref.String()] = stagedPiece{SectorID: sectorID, Size: size}
+}
+
+// stagedSector returns the sector that the piece with the given ref and size
+// was staged in, if it is not sealed yet.
+func (dealsAwaitingSeal *dealsAwaitingSealStruct) stagedSector(ref cid.Cid, size uint64) (uint64, bool) {
+	dealsAwaitingSeal.l.Lock()
+	defer dealsAwaitingSeal.l.Unlock()
+
+	piece, ok := dealsAwaitingSeal.StagedPieces[ref.String()]
+	if !ok || piece.Size != size {
+		return 0, false
+	}
+	return piece.SectorID, true
+}
+
+// unstagePieces forgets the pieces of the sector.  It must be called with the
+// lock held.
+func (dealsAwaitingSeal *dealsAwaitingSealStruct) unstagePieces(sectorID uint64) {
+	for ref, piece := range dealsAwaitingSeal.StagedPieces {
+		if piece.SectorID == sectorID {
+			delete(dealsAwaitingSeal.StagedPieces, ref)
+		}
+	}
+}
+
 func (dealsAwaitingSeal *dealsAwaitingSealStruct) success(sector *sectorbuilder.SealedSectorMetadata) {
 	dealsAwaitingSeal.l.Lock()
 	defer dealsAwaitingSeal.l.Unlock()
 
 	dealsAwaitingSeal.SuccessfulSectors[sector.SectorID] = sector
+	dealsAwaitingSeal.unstagePieces(sector.SectorID)
 
 	for _, dealCid := range dealsAwaitingSeal.SectorsToDeals[sector.SectorID] {
 		dealsAwaitingSeal.onSuccess(dealCid, sector)
@@ -589,6 +772,7 @@ func (dealsAwaitingSeal *dealsAwaitingSealStruct) fail(sectorID uint64, message
 	defer dealsAwaitingSeal.l.Unlock()
 
 	dealsAwaitingSeal.FailedSectors[sectorID] = message
+	dealsAwaitingSeal.unstagePieces(sectorID)
 
 	for _, dealCid := range dealsAwaitingSeal.SectorsToDeals[sectorID] {
 		dealsAwaitingSeal.onFail(dealCid, message)
@@ -822,7 +1006,9 @@ func (sm *Miner) OnNewHeaviestTipSet(ts types.TipSet) {
 				return
 			}
 
-			go sm.submitPoSt(provingPeriodStart, provingPeriodEnd, seed, inputs)
+			sm.node.Supervise("PoSt submitter", func(context.Context) {
+				sm.submitPoSt(provingPeriodStart, provingPeriodEnd, seed, inputs)
+			})
 		} else {
 			// we are too late
 			// TODO: figure out faults and payments here
@@ -862,6 +1048,9 @@ func (sm *Miner) generatePoSt(sortedCommRs proofs.SortedCommRs, seed types.PoStC
 }
 
 func (sm *Miner) submitPoSt(start, end *types.BlockHeight, seed types.PoStChallengeSeed, inputs []generatePostInput) {
+	result := &PoStResult{ProvingPeriodStart: start, ProvingPeriodEnd: end}
+	defer sm.recordPoSt(result)
+
 	commRs := make([]types.CommR, len(inputs))
 	for i, input := range inputs {
 		commRs[i] = input.commR
@@ -872,28 +1061,34 @@ func (sm *Miner) submitPoSt(start, end *types.BlockHeight, seed types.PoStChalle
 	proofs, faults, err := sm.generatePoSt(sortedCommRs, seed)
 	if err != nil {
 		log.Errorf("failed to generate PoSts: %s", err)
+		result.Error = err.Error()
 		return
 	}
 	if len(faults) != 0 {
 		log.Warningf("some faults when generating PoSt: %v", faults)
 		// TODO: proper fault handling
 	}
+	result.Faults = len(faults)
 
 	height, err := sm.porcelainAPI.ChainBlockHeight()
 	if err != nil {
 		log.Errorf("failed to submit PoSt, as the current block height can not be determined: %s", err)
 		// TODO: what should happen in this case?
+		result.Error = err.Error()
 		return
 	}
+	result.Height = height
 	if height.LessThan(start) {
 		// TODO: what to do here? not sure this can happen, maybe through reordering?
 		log.Errorf("PoSt generation time took negative block time: %s < %s", height, start)
+		result.Error = "PoSt generated before the proving period started"
 		return
 	}
 
 	if height.GreaterEqual(end) {
 		// TODO: we are too late, figure out faults and decide if we want to still submit
 		log.Errorf("PoSt generation was too slow height=%s end=%s", height, end)
+		result.Error = "PoSt generation was too slow"
 		return
 	}
 
@@ -905,11 +1100,13 @@ func (sm *Miner) submitPoSt(start, end *types.BlockHeight, seed types.PoStChalle
 	gasPrice := types.NewGasPrice(submitPostGasPrice)
 	gasLimit := types.NewGasUnits(submitPostGasLimit)
 
-	_, err = sm.porcelainAPI.MessageSend(ctx, sm.minerOwnerAddr, sm.minerAddr, types.ZeroAttoFIL, gasPrice, gasLimit, "submitPoSt", proofs)
+	msgCid, err := sm.porcelainAPI.MessageSend(ctx, sm.minerOwnerAddr, sm.minerAddr, types.ZeroAttoFIL, gasPrice, gasLimit, "submitPoSt", proofs)
 	if err != nil {
 		log.Errorf("failed to submit PoSt: %s", err)
+		result.Error = err.Error()
 		return
 	}
+	result.Message = &msgCid
 
 	log.Debug("submitted PoSt")
 }
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ProvingState is where a miner stands in its current proving period.
+type ProvingState string
+
+const (
+	// ProvingIdle means the miner has no committed sectors to prove.
+	ProvingIdle = ProvingState("idle")
+	// ProvingWaiting means the current proving period has yet to start.
+	ProvingWaiting = ProvingState("waiting")
+	// ProvingDue means a PoSt is due for the current proving period and
+	// none is being generated.
+	ProvingDue = ProvingState("due")
+	// ProvingGenerating means the PoSt for the current proving period is
+	// being generated.
+	ProvingGenerating = ProvingState("generating")
+	// ProvingSubmitted means the PoSt for the current proving period was
+	// submitted and is waiting to be included in the chain.
+	ProvingSubmitted = ProvingState("submitted")
+	// ProvingFailed means submitting the PoSt for the current proving period
+	// failed.
+	ProvingFailed = ProvingState("failed")
+	// ProvingLate means the submission deadline has passed, but a PoSt can
+	// still be submitted in the grace period.
+	ProvingLate = ProvingState("late")
+	// ProvingMissed means the grace period has passed without a PoSt.
+	ProvingMissed = ProvingState("missed")
+)
+
+// PoStChainStatus is the status of a submitted PoSt message on chain.
+type PoStChainStatus string
+
+const (
+	// PoStPending means the PoSt message is not in the chain yet.
+	PoStPending = PoStChainStatus("pending")
+	// PoStIncluded means the PoSt message was included and applied
+	// successfully.
+	PoStIncluded = PoStChainStatus("included")
+	// PoStRejected means the PoSt message was included but failed.
+	PoStRejected = PoStChainStatus("rejected")
+)
+
+// ProvingStatus describes a storage miner's current proving period, computed
+// from the chain and the miner's PoSt scheduler.
+type ProvingStatus struct {
+	Miner  address.Address    `json:"miner"`
+	Height *types.BlockHeight `json:"height"`
+	State  ProvingState       `json:"state"`
+
+	// ProvingPeriodEnd is the deadline for submitting a PoSt for the period
+	// starting at ProvingPeriodStart, after which it is late until
+	// GracePeriodEnd.  They are nil if the miner has no committed sectors.
+	ProvingPeriodStart *types.BlockHeight `json:"provingPeriodStart,omitempty"`
+	ProvingPeriodEnd   *types.BlockHeight `json:"provingPeriodEnd,omitempty"`
+	GracePeriodEnd     *types.BlockHeight `json:"gracePeriodEnd,omitempty"`
+	// Sectors are the ids of the sectors the PoSt must prove.
+	Sectors []uint64 `json:"sectors"`
+
+	// LastPoSt is the last PoSt submission attempt, of any period, and
+	// LastPoStStatus the status of its message on chain, if one was sent.
+	LastPoSt       *PoStResult     `json:"lastPoSt,omitempty"`
+	LastPoStStatus PoStChainStatus `json:"lastPoStStatus,omitempty"`
+	// LastPoStExitCode is the exit code of the last PoSt message if it was
+	// included.
+	LastPoStExitCode uint8 `json:"lastPoStExitCode,omitempty"`
+}
+
+// ProvingStatus gathers the miner's current proving period from the chain,
+// the PoSt being generated and the last PoSt submitted.
+func (sm *Miner) ProvingStatus(ctx context.Context) (*ProvingStatus, error) {
+	height, err := sm.porcelainAPI.ChainBlockHeight()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get chain height")
+	}
+	status := &ProvingStatus{
+		Miner:   sm.minerAddr,
+		Height:  height,
+		State:   ProvingIdle,
+		Sectors: []uint64{},
+	}
+
+	if posts := sm.RecentPoSts(); len(posts) > 0 {
+		status.LastPoSt = posts[len(posts)-1]
+		if err := sm.fillLastPoStStatus(ctx, status); err != nil {
+			return nil, err
+		}
+	}
+
+	commitments, err := sm.getActorSectorCommitments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for k := range commitments {
+		id, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse commitment sector id")
+		}
+		status.Sectors = append(status.Sectors, id)
+	}
+	sort.Slice(status.Sectors, func(i, j int) bool { return status.Sectors[i] < status.Sectors[j] })
+	if len(status.Sectors) == 0 {
+		return status, nil
+	}
+
+	start, err := sm.getProvingPeriodStart()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get proving period start")
+	}
+	status.ProvingPeriodStart = start
+	status.ProvingPeriodEnd = start.Add(types.NewBlockHeight(miner.ProvingPeriodBlocks))
+	status.GracePeriodEnd = status.ProvingPeriodEnd.Add(types.NewBlockHeight(miner.GracePeriodBlocks))
+	status.State = sm.provingState(status)
+	return status, nil
+}
+
+// fillLastPoStStatus looks up the message of the last PoSt on chain.
+func (sm *Miner) fillLastPoStStatus(ctx context.Context, status *ProvingStatus) error {
+	if status.LastPoSt.Message == nil {
+		return nil
+	}
+	chainMsg, found, err := sm.porcelainAPI.MessageFind(ctx, *status.LastPoSt.Message)
+	if err != nil {
+		return errors.Wrap(err, "failed to look up PoSt message")
+	}
+	switch {
+	case !found:
+		status.LastPoStStatus = PoStPending
+	case chainMsg.Receipt == nil || chainMsg.Receipt.ExitCode != 0:
+		status.LastPoStStatus = PoStRejected
+		if chainMsg.Receipt != nil {
+			status.LastPoStExitCode = chainMsg.Receipt.ExitCode
+		}
+	default:
+		status.LastPoStStatus = PoStIncluded
+	}
+	return nil
+}
+
+// provingState determines the state of the period described by status.
+func (sm *Miner) provingState(status *ProvingStatus) ProvingState {
+	if status.Height.LessThan(status.ProvingPeriodStart) {
+		return ProvingWaiting
+	}
+
+	// Once a PoSt is included the chain moves on to the next proving period,
+	// so a PoSt for the current one is at most pending.
+	last := status.LastPoSt
+	if last != nil && last.ProvingPeriodStart != nil && last.ProvingPeriodStart.Equal(status.ProvingPeriodStart) {
+		if last.Error != "" || status.LastPoStStatus == PoStRejected {
+			return ProvingFailed
+		}
+		return ProvingSubmitted
+	}
+
+	sm.postInProcessLk.Lock()
+	generating := sm.postInProcess != nil && sm.postInProcess.Equal(status.ProvingPeriodStart)
+	sm.postInProcessLk.Unlock()
+	switch {
+	case generating:
+		return ProvingGenerating
+	case status.Height.LessThan(status.ProvingPeriodEnd):
+		return ProvingDue
+	case status.Height.LessThan(status.GracePeriodEnd):
+		return ProvingLate
+	default:
+		return ProvingMissed
+	}
+}
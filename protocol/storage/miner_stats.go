@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// maxRecentPoSts is the number of PoSt submissions remembered by a miner.
+const maxRecentPoSts = 10
+
+// PoStResult records the outcome of an attempt to submit a PoSt for a proving
+// period.
+type PoStResult struct {
+	ProvingPeriodStart *types.BlockHeight `json:"provingPeriodStart"`
+	ProvingPeriodEnd   *types.BlockHeight `json:"provingPeriodEnd"`
+	// Height is the chain height at which the attempt finished.
+	Height *types.BlockHeight `json:"height"`
+	// Message is the cid of the submitPoSt message, if one was sent.
+	Message *cid.Cid `json:"message,omitempty"`
+	Faults  int      `json:"faults"`
+	// Error is set if no PoSt could be submitted.
+	Error string `json:"error,omitempty"`
+}
+
+// MinerStats summarizes a storage miner's asks, deals, capacity and proving
+// status in a single document.
+type MinerStats struct {
+	Miner  address.Address    `json:"miner"`
+	Height *types.BlockHeight `json:"height"`
+
+	// Asks are the miner's asks that have not expired.
+	Asks []*miner.Ask `json:"asks"`
+	// DealsByState counts the miner's deals in each state.
+	DealsByState map[string]int `json:"dealsByState"`
+
+	SectorSize       *types.BytesAmount `json:"sectorSize"`
+	PledgedSectors   uint64             `json:"pledgedSectors"`
+	CommittedSectors uint64             `json:"committedSectors"`
+	PledgedBytes     *types.BytesAmount `json:"pledgedBytes"`
+	CommittedBytes   *types.BytesAmount `json:"committedBytes"`
+
+	// ProvingPeriodEnd is the height by which a PoSt must be submitted for
+	// the current proving period, after which it is late until
+	// GracePeriodEnd.  They are nil if the miner has no committed sectors.
+	ProvingPeriodStart *types.BlockHeight `json:"provingPeriodStart,omitempty"`
+	ProvingPeriodEnd   *types.BlockHeight `json:"provingPeriodEnd,omitempty"`
+	GracePeriodEnd     *types.BlockHeight `json:"gracePeriodEnd,omitempty"`
+
+	// RecentPoSts lists the most recent PoSt submissions, oldest first.
+	RecentPoSts []*PoStResult `json:"recentPoSts"`
+}
+
+// Stats gathers the miner's current statistics from the chain and from its
+// local deal and PoSt records.
+func (sm *Miner) Stats(ctx context.Context) (*MinerStats, error) {
+	height, err := sm.porcelainAPI.ChainBlockHeight()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get chain height")
+	}
+	stats := &MinerStats{
+		Miner:        sm.minerAddr,
+		Height:       height,
+		DealsByState: make(map[string]int),
+		RecentPoSts:  sm.RecentPoSts(),
+	}
+
+	if stats.Asks, err = sm.getActiveAsks(ctx, height); err != nil {
+		return nil, errors.Wrap(err, "failed to get asks")
+	}
+
+	deals, err := sm.porcelainAPI.DealsLs()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list deals")
+	}
+	for _, deal := range deals {
+		if deal.Miner != sm.minerAddr || deal.Response == nil {
+			continue
+		}
+		stats.DealsByState[deal.Response.State.String()]++
+	}
+
+	if stats.SectorSize, err = sm.porcelainAPI.MinerGetSectorSize(ctx, sm.minerAddr); err != nil {
+		return nil, errors.Wrap(err, "failed to get sector size")
+	}
+	ret, err := sm.porcelainAPI.MessageQuery(ctx, address.Undef, sm.minerAddr, "getPledge")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pledge")
+	}
+	stats.PledgedSectors = big.NewInt(0).SetBytes(ret[0]).Uint64()
+	commitments, err := sm.getActorSectorCommitments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.CommittedSectors = uint64(len(commitments))
+	stats.PledgedBytes = stats.SectorSize.Mul(types.NewBytesAmount(stats.PledgedSectors))
+	stats.CommittedBytes = stats.SectorSize.Mul(types.NewBytesAmount(stats.CommittedSectors))
+
+	if stats.CommittedSectors > 0 {
+		start, err := sm.getProvingPeriodStart()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get proving period start")
+		}
+		stats.ProvingPeriodStart = start
+		stats.ProvingPeriodEnd = start.Add(types.NewBlockHeight(miner.ProvingPeriodBlocks))
+		stats.GracePeriodEnd = stats.ProvingPeriodEnd.Add(types.NewBlockHeight(miner.GracePeriodBlocks))
+	}
+
+	return stats, nil
+}
+
+// getActiveAsks returns the miner's asks that have not expired at height.
+func (sm *Miner) getActiveAsks(ctx context.Context, height *types.BlockHeight) ([]*miner.Ask, error) {
+	ret, err := sm.porcelainAPI.MessageQuery(ctx, address.Undef, sm.minerAddr, "getAsks")
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sm.porcelainAPI.ActorGetSignature(ctx, sm.minerAddr, "getAsks")
+	if err != nil {
+		return nil, err
+	}
+	idsVal, err := abi.Deserialize(ret[0], sig.Return[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "deserialization failed")
+	}
+	ids, ok := idsVal.Val.([]uint64)
+	if !ok {
+		return nil, errors.New("type assertion failed")
+	}
+
+	var asks []*miner.Ask
+	for _, id := range ids {
+		ret, err := sm.porcelainAPI.MessageQuery(ctx, address.Undef, sm.minerAddr, "getAsk", big.NewInt(int64(id)))
+		if err != nil {
+			return nil, err
+		}
+		var ask miner.Ask
+		if err := cbor.DecodeInto(ret[0], &ask); err != nil {
+			return nil, err
+		}
+		if height.LessThan(ask.Expiry) {
+			asks = append(asks, &ask)
+		}
+	}
+	return asks, nil
+}
+
+// RecentPoSts returns the most recent PoSt submission attempts, oldest first.
+func (sm *Miner) RecentPoSts() []*PoStResult {
+	sm.recentPoStsLk.Lock()
+	defer sm.recentPoStsLk.Unlock()
+
+	return append([]*PoStResult{}, sm.recentPoSts...)
+}
+
+func (sm *Miner) recordPoSt(result *PoStResult) {
+	sm.recentPoStsLk.Lock()
+	defer sm.recentPoStsLk.Unlock()
+
+	sm.recentPoSts = append(sm.recentPoSts, result)
+	if len(sm.recentPoSts) > maxRecentPoSts {
+		sm.recentPoSts = sm.recentPoSts[len(sm.recentPoSts)-maxRecentPoSts:]
+	}
+}
@@ -8,14 +8,18 @@ import (
 	"testing"
 
 	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
 	"github.com/filecoin-project/go-filecoin/repo"
@@ -392,6 +396,223 @@ func TestOnCommitmentAddedToChain(t *testing.T) {
 	})
 }
 
+func TestStoreDuplicatePiece(t *testing.T) {
+	tf.UnitTest(t)
+
+	cidGetter := types.NewCidForTestGetter()
+	firstCid, secondCid := cidGetter(), cidGetter()
+	sectorID := uint64(777)
+	msgCid := cidGetter()
+	pip := []byte{3, 3, 3, 3, 3}
+
+	// putDeal stores an accepted deal for the piece of proposal.
+	putDeal := func(t *testing.T, porcelainAPI *minerTestPorcelain, miner *Miner, proposal storagedeal.Proposal) *storagedeal.Deal {
+		deal := &storagedeal.Deal{
+			Miner:    miner.minerAddr,
+			Proposal: &proposal,
+			Response: &storagedeal.Response{State: storagedeal.Accepted, ProposalCid: secondCid},
+		}
+		require.NoError(t, porcelainAPI.DealPut(deal))
+		return deal
+	}
+	sealSector := func(miner *Miner, proposal *storagedeal.SignedDealProposal) {
+		piece := &sectorbuilder.PieceInfo{Ref: proposal.PieceRef, Size: proposal.Size.Uint64(), InclusionProof: pip}
+		sector := &sectorbuilder.SealedSectorMetadata{SectorID: sectorID, Pieces: []*sectorbuilder.PieceInfo{piece}}
+		miner.OnCommitmentSent(sector, msgCid, nil)
+	}
+
+	t.Run("stages the deal in the sector of a staged piece", func(t *testing.T) {
+		porcelainAPI, miner, proposal := minerWithAcceptedDealTestSetup(t, firstCid, sectorID)
+		miner.dealsAwaitingSeal.stagePiece(sectorID, proposal.PieceRef, proposal.Size.Uint64())
+
+		deal := putDeal(t, porcelainAPI, miner, proposal.Proposal)
+		assert.True(t, miner.storeDuplicatePiece(secondCid, deal))
+		assert.Equal(t, storagedeal.Staged, porcelainAPI.DealGet(secondCid).Response.State)
+		assert.Equal(t, []cid.Cid{firstCid, secondCid}, miner.dealsAwaitingSeal.SectorsToDeals[sectorID])
+
+		// Both deals are posted once the sector is sealed.
+		sealSector(miner, proposal)
+		assert.Equal(t, storagedeal.Posted, porcelainAPI.DealGet(firstCid).Response.State)
+		assert.Equal(t, storagedeal.Posted, porcelainAPI.DealGet(secondCid).Response.State)
+		_, staged := miner.dealsAwaitingSeal.stagedSector(proposal.PieceRef, proposal.Size.Uint64())
+		assert.False(t, staged)
+	})
+
+	t.Run("posts the deal with the proof of a sealed piece", func(t *testing.T) {
+		porcelainAPI, miner, proposal := minerWithAcceptedDealTestSetup(t, firstCid, sectorID)
+		sealSector(miner, proposal)
+
+		deal := putDeal(t, porcelainAPI, miner, proposal.Proposal)
+		assert.True(t, miner.storeDuplicatePiece(secondCid, deal))
+
+		resp := porcelainAPI.DealGet(secondCid).Response
+		assert.Equal(t, storagedeal.Posted, resp.State)
+		require.NotNil(t, resp.ProofInfo)
+		assert.Equal(t, sectorID, resp.ProofInfo.SectorID)
+		assert.Equal(t, &msgCid, resp.ProofInfo.CommitmentMessage)
+		assert.Equal(t, pip, resp.ProofInfo.PieceInclusionProof)
+	})
+
+	t.Run("stores other pieces", func(t *testing.T) {
+		porcelainAPI, miner, proposal := minerWithAcceptedDealTestSetup(t, firstCid, sectorID)
+		miner.dealsAwaitingSeal.stagePiece(sectorID, proposal.PieceRef, proposal.Size.Uint64())
+
+		other := proposal.Proposal
+		other.PieceRef = cidGetter()
+		assert.False(t, miner.storeDuplicatePiece(secondCid, putDeal(t, porcelainAPI, miner, other)))
+
+		other = proposal.Proposal
+		other.Size = types.NewBytesAmount(proposal.Size.Uint64() + 1)
+		assert.False(t, miner.storeDuplicatePiece(secondCid, putDeal(t, porcelainAPI, miner, other)))
+		assert.Equal(t, storagedeal.Accepted, porcelainAPI.DealGet(secondCid).Response.State)
+	})
+}
+
+func TestMinerStats(t *testing.T) {
+	tf.UnitTest(t)
+
+	porcelainAPI := newMinerTestPorcelain(t)
+	sm := newTestMiner(porcelainAPI)
+	sm.minerAddr = address.NewForTestGetter()()
+	newCid := types.NewCidForTestGetter()
+
+	for _, state := range []storagedeal.State{storagedeal.Accepted, storagedeal.Accepted, storagedeal.Posted} {
+		require.NoError(t, porcelainAPI.DealPut(&storagedeal.Deal{
+			Miner:    sm.minerAddr,
+			Response: &storagedeal.Response{State: state, ProposalCid: newCid()},
+		}))
+	}
+	// Deals with other miners are not counted.
+	require.NoError(t, porcelainAPI.DealPut(&storagedeal.Deal{
+		Miner:    address.TestAddress,
+		Response: &storagedeal.Response{State: storagedeal.Failed, ProposalCid: newCid()},
+	}))
+
+	askIDs, err := (&abi.Value{Type: abi.UintArray, Val: []uint64{0}}).Serialize()
+	require.NoError(t, err)
+	ask, err := cbor.DumpObject(miner.Ask{Price: types.NewAttoFILFromFIL(2), Expiry: types.NewBlockHeight(1000), ID: big.NewInt(0)})
+	require.NoError(t, err)
+	commitments, err := (&abi.Value{Type: abi.CommitmentsMap, Val: map[string]types.Commitments{"1": {}, "2": {}}}).Serialize()
+	require.NoError(t, err)
+	porcelainAPI.queries = map[string][][]byte{
+		"getAsks":               {askIDs},
+		"getAsk":                {ask},
+		"getPledge":             {big.NewInt(10).Bytes()},
+		"getSectorCommitments":  {commitments},
+		"getProvingPeriodStart": {types.NewBlockHeight(500).Bytes()},
+	}
+
+	t.Run("summarizes the miner", func(t *testing.T) {
+		stats, err := sm.Stats(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, sm.minerAddr, stats.Miner)
+		assert.Equal(t, types.NewBlockHeight(773), stats.Height)
+		require.Len(t, stats.Asks, 1)
+		assert.Equal(t, types.NewAttoFILFromFIL(2), stats.Asks[0].Price)
+		assert.Equal(t, map[string]int{"accepted": 2, "posted": 1}, stats.DealsByState)
+		assert.Equal(t, uint64(10), stats.PledgedSectors)
+		assert.Equal(t, uint64(2), stats.CommittedSectors)
+		assert.Equal(t, types.OneKiBSectorSize.Mul(types.NewBytesAmount(2)), stats.CommittedBytes)
+		assert.Equal(t, types.NewBlockHeight(500+miner.ProvingPeriodBlocks), stats.ProvingPeriodEnd)
+		assert.Equal(t, types.NewBlockHeight(500+miner.ProvingPeriodBlocks+miner.GracePeriodBlocks), stats.GracePeriodEnd)
+	})
+
+	t.Run("keeps only the most recent PoSts", func(t *testing.T) {
+		for i := 0; i < maxRecentPoSts+2; i++ {
+			sm.recordPoSt(&PoStResult{ProvingPeriodStart: types.NewBlockHeight(uint64(i))})
+		}
+
+		stats, err := sm.Stats(context.Background())
+		require.NoError(t, err)
+		require.Len(t, stats.RecentPoSts, maxRecentPoSts)
+		assert.Equal(t, types.NewBlockHeight(2), stats.RecentPoSts[0].ProvingPeriodStart)
+		assert.Equal(t, types.NewBlockHeight(maxRecentPoSts+1), stats.RecentPoSts[maxRecentPoSts-1].ProvingPeriodStart)
+	})
+}
+
+func TestMinerProvingStatus(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	newStatus := func(t *testing.T, sectors map[string]types.Commitments) (*minerTestPorcelain, *Miner) {
+		porcelainAPI := newMinerTestPorcelain(t)
+		sm := newTestMiner(porcelainAPI)
+		sm.minerAddr = address.NewForTestGetter()()
+		commitments, err := (&abi.Value{Type: abi.CommitmentsMap, Val: sectors}).Serialize()
+		require.NoError(t, err)
+		porcelainAPI.queries = map[string][][]byte{
+			"getSectorCommitments":  {commitments},
+			"getProvingPeriodStart": {types.NewBlockHeight(700).Bytes()},
+		}
+		porcelainAPI.onChain = make(map[cid.Cid]*msg.ChainMessage)
+		return porcelainAPI, sm
+	}
+	sectors := map[string]types.Commitments{"2": {}, "1": {}}
+	end := types.NewBlockHeight(700 + miner.ProvingPeriodBlocks)
+
+	t.Run("is idle without sectors", func(t *testing.T) {
+		_, sm := newStatus(t, map[string]types.Commitments{})
+		status, err := sm.ProvingStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ProvingIdle, status.State)
+		assert.Nil(t, status.ProvingPeriodEnd)
+	})
+
+	t.Run("reports the deadline and sectors of a due PoSt", func(t *testing.T) {
+		_, sm := newStatus(t, sectors)
+		status, err := sm.ProvingStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ProvingDue, status.State)
+		assert.Equal(t, types.NewBlockHeight(700), status.ProvingPeriodStart)
+		assert.Equal(t, end, status.ProvingPeriodEnd)
+		assert.Equal(t, []uint64{1, 2}, status.Sectors)
+	})
+
+	t.Run("reports a PoSt being generated", func(t *testing.T) {
+		_, sm := newStatus(t, sectors)
+		sm.postInProcess = types.NewBlockHeight(700)
+		status, err := sm.ProvingStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ProvingGenerating, status.State)
+	})
+
+	t.Run("reports a submitted PoSt pending on chain", func(t *testing.T) {
+		_, sm := newStatus(t, sectors)
+		msgCid := types.SomeCid()
+		sm.recordPoSt(&PoStResult{ProvingPeriodStart: types.NewBlockHeight(700), ProvingPeriodEnd: end, Message: &msgCid})
+		status, err := sm.ProvingStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ProvingSubmitted, status.State)
+		assert.Equal(t, PoStPending, status.LastPoStStatus)
+	})
+
+	t.Run("reports the chain status of the last PoSt", func(t *testing.T) {
+		porcelainAPI, sm := newStatus(t, sectors)
+		msgCid := types.SomeCid()
+		sm.recordPoSt(&PoStResult{ProvingPeriodStart: types.NewBlockHeight(0), Message: &msgCid})
+		porcelainAPI.onChain[msgCid] = &msg.ChainMessage{Receipt: &types.MessageReceipt{ExitCode: 3}}
+		status, err := sm.ProvingStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ProvingDue, status.State)
+		assert.Equal(t, PoStRejected, status.LastPoStStatus)
+		assert.Equal(t, uint8(3), status.LastPoStExitCode)
+	})
+
+	t.Run("reports a late PoSt", func(t *testing.T) {
+		porcelainAPI, sm := newStatus(t, sectors)
+		porcelainAPI.blockHeight = end
+		status, err := sm.ProvingStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ProvingLate, status.State)
+
+		porcelainAPI.blockHeight = status.GracePeriodEnd
+		status, err = sm.ProvingStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ProvingMissed, status.State)
+	})
+}
+
 type minerTestPorcelain struct {
 	config        *cfg.Config
 	payerAddress  address.Address
@@ -405,6 +626,11 @@ type minerTestPorcelain struct {
 	paymentStart  *types.BlockHeight
 	deals         map[cid.Cid]*storagedeal.Deal
 
+	// queries holds canned return values of miner actor methods.
+	queries map[string][][]byte
+	// onChain holds the messages found on chain.
+	onChain map[cid.Cid]*msg.ChainMessage
+
 	testing *testing.T
 }
 
@@ -452,6 +678,9 @@ func newMinerTestPorcelain(t *testing.T) *minerTestPorcelain {
 }
 
 func (mtp *minerTestPorcelain) ActorGetSignature(ctx context.Context, actorAddr address.Address, method string) (_ *exec.FunctionSignature, err error) {
+	if _, ok := mtp.queries[method]; ok {
+		return (&miner.Actor{}).Exports()[method], nil
+	}
 	return nil, nil
 }
 
@@ -463,6 +692,9 @@ func (mtp *minerTestPorcelain) MessageQuery(ctx context.Context, optFrom, to add
 	if method == "getProofsMode" {
 		return messageQueryGetProofsMode()
 	}
+	if ret, ok := mtp.queries[method]; ok {
+		return ret, nil
+	}
 	return mtp.messageQueryPaymentBrokerLs()
 }
 
@@ -501,6 +733,11 @@ func (mtp *minerTestPorcelain) MessageWait(ctx context.Context, msgCid cid.Cid,
 	return nil
 }
 
+func (mtp *minerTestPorcelain) MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error) {
+	chainMsg, ok := mtp.onChain[msgCid]
+	return chainMsg, ok, nil
+}
+
 func newTestMiner(api *minerTestPorcelain) *Miner {
 	return &Miner{
 		porcelainAPI:   api,
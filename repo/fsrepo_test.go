@@ -31,12 +31,20 @@ const (
 			"GET",
 			"POST",
 			"PUT"
-		]
+		],
+		"graphql": false
 	},
 	"bootstrap": {
 		"addresses": [],
 		"minPeerThreshold": 0,
-		"period": "1m"
+		"period": "1m",
+		"maxPeerThreshold": 20,
+		"redialPeriod": "5m"
+	},
+	"chain": {
+		"blockCacheSize": 5000,
+		"tipSetCacheSize": 1000,
+		"notaryAddress": "empty"
 	},
 	"datastore": {
 		"type": "badgerds",
@@ -51,7 +59,8 @@ const (
 	"mining": {
 		"minerAddress": "empty",
 		"autoSealIntervalSeconds": 120,
-		"storagePrice": "0"
+		"storagePrice": "0",
+		"scheduledProducer": "empty"
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
@@ -68,13 +77,31 @@ const (
 			"jaegerTracingEnabled": false,
 			"probabilitySampler": 1,
 			"jaegerEndpoint": "http://localhost:14268/api/traces"
+		},
+		"chainStats": {
+			"sampleInterval": 10,
+			"maxSamples": 10000
+		}
+	},
+	"pubsub": {
+		"seenMessagesTTL": "2m",
+		"blocks": {
+			"cacheSize": 1000,
+			"ttl": "2m"
+		},
+		"messages": {
+			"cacheSize": 10000,
+			"ttl": "2m"
 		}
 	},
 	"sectorbase": {
 		"rootdir": ""
 	},
 	"swarm": {
-		"address": "/ip4/0.0.0.0/tcp/6000"
+		"address": "/ip4/0.0.0.0/tcp/6000",
+		"connMgrLowWater": 100,
+		"connMgrHighWater": 200,
+		"connMgrGracePeriod": "20s"
 	},
 	"wallet": {
 		"defaultAddress": "empty"
@@ -0,0 +1,367 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/auth"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/chainfollower"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// API is the subset of the porcelain API the server exposes.
+type API interface {
+	ActorGetAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*actor.Actor, error)
+	ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error)
+	ChainGetTipSetAtHeight(ctx context.Context, height uint64) (*types.TipSet, error)
+	ChainHead() (*types.TipSet, error)
+	ChainSubscribeHead(ctx context.Context) <-chan types.TipSet
+	ChainSyncStatus() chain.SyncStatus
+	MessagePoolGet(cid cid.Cid) (*types.SignedMessage, bool)
+	MessagePoolNextNonce(ctx context.Context, addr address.Address) (uint64, error)
+	MessagePoolPending() []*types.SignedMessage
+	MessagePoolSubscribe(ctx context.Context) <-chan core.MessagePoolEvent
+	MessageReceipt(ctx context.Context, msgCid cid.Cid) (*types.MessageReceipt, error)
+	MessageSendSigned(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error)
+	MessageSign(mmsg *types.MeteredMessage) (*types.SignedMessage, error)
+	MinerGetPower(ctx context.Context, tsKey types.SortedCidSet, minerAddr address.Address) (*porcelain.MinerPower, error)
+	StorageMarketGetState(ctx context.Context, tsKey types.SortedCidSet) (*porcelain.StorageMarketState, error)
+	WalletAddresses() []address.Address
+	WalletBalanceAt(ctx context.Context, tsKey types.SortedCidSet, addr address.Address) (*types.AttoFIL, error)
+	WalletDefaultAddress() (address.Address, error)
+}
+
+// TipSetRef selects the tipset whose state a method reads: the tipset with
+// key Key, or the tipset of the chain at Height, or the one before it if there
+// is none at Height.  State methods read the state of the head when they are
+// given no TipSetRef.
+type TipSetRef struct {
+	Key    types.SortedCidSet `json:"key"`
+	Height *uint64            `json:"height,omitempty"`
+}
+
+// TipSet is a tipset as returned by the server.
+type TipSet struct {
+	Key    types.SortedCidSet `json:"key"`
+	Height uint64             `json:"height"`
+	Blocks []*types.Block     `json:"blocks"`
+}
+
+// HeadChange is the notification of a SubscribeHeads subscription when the
+// head changes.  The first notification applies the head at the time of the
+// subscription.
+type HeadChange struct {
+	// Revert are the tipsets leaving the chain in a reorg, children first.
+	Revert []*TipSet `json:"revert"`
+	// Apply are the tipsets joining the chain, parents first.
+	Apply []*TipSet `json:"apply"`
+}
+
+// AddressMessage is the notification of a SubscribeAddressMessages
+// subscription for a message from or to a watched address joining or, in a
+// reorg, leaving the chain.
+type AddressMessage struct {
+	Cid cid.Cid `json:"cid"`
+	// Block is the cid of the block that included the message.
+	Block   cid.Cid               `json:"block"`
+	Height  uint64                `json:"height"`
+	Message *types.SignedMessage  `json:"message"`
+	Receipt *types.MessageReceipt `json:"receipt"`
+	// Reverted is true if the message left the chain with its tipset.
+	Reverted bool `json:"reverted"`
+}
+
+// RegisterAPI registers the chain, state, mpool, wallet and sync methods of
+// api with s, and the subscriptions to heads, messages of addresses and mpool
+// changes, which are served over websockets only.
+func RegisterAPI(s *Server, api API) error {
+	methods := map[string]interface{}{
+		// Chain
+		"ChainHead": func(ctx context.Context) (*TipSet, error) {
+			head, err := api.ChainHead()
+			if err != nil {
+				return nil, err
+			}
+			return newTipSet(*head)
+		},
+		"ChainGetBlock": api.ChainGetBlock,
+		"ChainGetMessageReceipt": func(ctx context.Context, msgCid cid.Cid) (*types.MessageReceipt, error) {
+			return api.MessageReceipt(ctx, msgCid)
+		},
+
+		// State, at the head or at the tipset of an optional TipSetRef
+		"StateGetActor": func(ctx context.Context, addr address.Address, ref *TipSetRef) (*actor.Actor, error) {
+			tsKey, err := tipSetKey(ctx, api, ref)
+			if err != nil {
+				return nil, err
+			}
+			return api.ActorGetAt(ctx, tsKey, addr)
+		},
+		"StateGetBalance": func(ctx context.Context, addr address.Address, ref *TipSetRef) (*types.AttoFIL, error) {
+			tsKey, err := tipSetKey(ctx, api, ref)
+			if err != nil {
+				return nil, err
+			}
+			return api.WalletBalanceAt(ctx, tsKey, addr)
+		},
+		"StateGetMinerPower": func(ctx context.Context, minerAddr address.Address, ref *TipSetRef) (*porcelain.MinerPower, error) {
+			tsKey, err := tipSetKey(ctx, api, ref)
+			if err != nil {
+				return nil, err
+			}
+			return api.MinerGetPower(ctx, tsKey, minerAddr)
+		},
+		"StateGetStorageMarket": func(ctx context.Context, ref *TipSetRef) (*porcelain.StorageMarketState, error) {
+			tsKey, err := tipSetKey(ctx, api, ref)
+			if err != nil {
+				return nil, err
+			}
+			return api.StorageMarketGetState(ctx, tsKey)
+		},
+
+		// Message pool
+		"MpoolPending": func(ctx context.Context) ([]*types.SignedMessage, error) {
+			return api.MessagePoolPending(), nil
+		},
+		"MpoolGetMessage": func(ctx context.Context, msgCid cid.Cid) (*types.SignedMessage, error) {
+			smsg, ok := api.MessagePoolGet(msgCid)
+			if !ok {
+				return nil, errors.Errorf("message %s not in the message pool", msgCid)
+			}
+			return smsg, nil
+		},
+		"MpoolNextNonce": api.MessagePoolNextNonce,
+		"MpoolPush":      api.MessageSendSigned,
+
+		// Wallet
+		"WalletAddresses": func(ctx context.Context) ([]address.Address, error) {
+			return api.WalletAddresses(), nil
+		},
+		"WalletDefaultAddress": func(ctx context.Context) (address.Address, error) {
+			return api.WalletDefaultAddress()
+		},
+		"WalletSignMessage": func(ctx context.Context, mmsg *types.MeteredMessage) (*types.SignedMessage, error) {
+			return api.MessageSign(mmsg)
+		},
+
+		// Sync
+		"SyncStatus": func(ctx context.Context) (chain.SyncStatus, error) {
+			return api.ChainSyncStatus(), nil
+		},
+
+		// Subscriptions
+		"SubscribeHeads": func(ctx context.Context) (string, error) {
+			return Subscribe(ctx, func(ctx context.Context, notify func(interface{}) error) error {
+				return followHeads(ctx, api, notify)
+			})
+		},
+		"SubscribeAddressMessages": func(ctx context.Context, addrs []address.Address) (string, error) {
+			if len(addrs) == 0 {
+				return "", errors.New("no address to watch")
+			}
+			return Subscribe(ctx, func(ctx context.Context, notify func(interface{}) error) error {
+				return followAddressMessages(ctx, api, addrs, notify)
+			})
+		},
+		"SubscribeMpool": func(ctx context.Context) (string, error) {
+			return Subscribe(ctx, func(ctx context.Context, notify func(interface{}) error) error {
+				return followMessagePool(ctx, api, notify)
+			})
+		},
+	}
+	// The other methods only read.
+	perms := map[string]auth.Permission{
+		"MpoolPush":         auth.PermWrite,
+		"WalletSignMessage": auth.PermSign,
+	}
+	for name, fn := range methods {
+		perm, ok := perms[name]
+		if !ok {
+			perm = auth.PermRead
+		}
+		if err := s.Register(name, perm, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tipSetKey returns the key of the tipset ref selects, or an empty key,
+// meaning the head, if ref is nil.
+func tipSetKey(ctx context.Context, api API, ref *TipSetRef) (types.SortedCidSet, error) {
+	if ref == nil {
+		return types.SortedCidSet{}, nil
+	}
+	if ref.Height == nil {
+		return ref.Key, nil
+	}
+	if ref.Key.Len() > 0 {
+		return types.SortedCidSet{}, errors.New("only one of key and height may be given")
+	}
+	ts, err := api.ChainGetTipSetAtHeight(ctx, *ref.Height)
+	if err != nil {
+		return types.SortedCidSet{}, err
+	}
+	return ts.ToSortedCidSet(), nil
+}
+
+func newTipSet(ts types.TipSet) (*TipSet, error) {
+	h, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	blks := ts.ToSlice()
+	types.SortBlocks(blks)
+	return &TipSet{
+		Key:    ts.ToSortedCidSet(),
+		Height: h,
+		Blocks: blks,
+	}, nil
+}
+
+// follow hands the changes to the chain from from, or from the head if from
+// is nil, to handler until ctx is done, calling flush after each head
+// change handled.
+func follow(ctx context.Context, api API, handler chainfollower.Handler, from types.TipSet, flush func() error) error {
+	heads := api.ChainSubscribeHead(ctx)
+	f, err := chainfollower.New(api, handler, from)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := f.Update(ctx); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-heads:
+			if !ok {
+				return nil
+			}
+		}
+	}
+}
+
+// headChanges collects the tipsets the chain follower reverts and applies.
+type headChanges struct {
+	change HeadChange
+}
+
+func (h *headChanges) Apply(ctx context.Context, ts *chainfollower.TipSet) error {
+	out, err := newTipSet(ts.TipSet)
+	if err != nil {
+		return err
+	}
+	h.change.Apply = append(h.change.Apply, out)
+	return nil
+}
+
+func (h *headChanges) Revert(ctx context.Context, ts *chainfollower.TipSet) error {
+	out, err := newTipSet(ts.TipSet)
+	if err != nil {
+		return err
+	}
+	h.change.Revert = append(h.change.Revert, out)
+	return nil
+}
+
+func followHeads(ctx context.Context, api API, notify func(interface{}) error) error {
+	h := &headChanges{}
+	return follow(ctx, api, h, nil, func() error {
+		if len(h.change.Apply) == 0 && len(h.change.Revert) == 0 {
+			return nil
+		}
+		change := h.change
+		h.change = HeadChange{}
+		return notify(&change)
+	})
+}
+
+// addressMessages collects the messages from or to the watched addresses of
+// the tipsets the chain follower reverts and applies.
+type addressMessages struct {
+	watched map[address.Address]struct{}
+	msgs    []*AddressMessage
+}
+
+func (h *addressMessages) Apply(ctx context.Context, ts *chainfollower.TipSet) error {
+	h.collect(ts, false)
+	return nil
+}
+
+func (h *addressMessages) Revert(ctx context.Context, ts *chainfollower.TipSet) error {
+	h.collect(ts, true)
+	return nil
+}
+
+func (h *addressMessages) collect(ts *chainfollower.TipSet, reverted bool) {
+	for _, m := range ts.Messages {
+		_, from := h.watched[m.Message.From]
+		_, to := h.watched[m.Message.To]
+		if !from && !to {
+			continue
+		}
+		h.msgs = append(h.msgs, &AddressMessage{
+			Cid:      m.Cid,
+			Block:    m.Block,
+			Height:   ts.Height,
+			Message:  m.Message,
+			Receipt:  m.Receipt,
+			Reverted: reverted,
+		})
+	}
+}
+
+func followAddressMessages(ctx context.Context, api API, addrs []address.Address, notify func(interface{}) error) error {
+	h := &addressMessages{watched: make(map[address.Address]struct{})}
+	for _, addr := range addrs {
+		h.watched[addr] = struct{}{}
+	}
+	// Only messages joining or leaving the chain after the subscription are
+	// notified.
+	head, err := api.ChainHead()
+	if err != nil {
+		return err
+	}
+	return follow(ctx, api, h, *head, func() error {
+		msgs := h.msgs
+		h.msgs = nil
+		for _, m := range msgs {
+			if err := notify(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func followMessagePool(ctx context.Context, api API, notify func(interface{}) error) error {
+	events := api.MessagePoolSubscribe(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("subscriber fell behind the message pool events")
+			}
+			if err := notify(&ev); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Package rpc implements a JSON-RPC 2.0 server over HTTP and websockets.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/auth"
+)
+
+var log = logging.Logger("rpc")
+
+// Version is the version of the methods of the server, which is part of
+// their names so that incompatible changes can be made under a new version
+// while clients of the previous one keep working.
+const Version = 0
+
+// maxRequestSize is the largest request body or websocket message the
+// server reads.
+const maxRequestSize = 10 << 20
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeServerError is the code of errors returned by methods.
+	CodeServerError = -32000
+	// CodePermissionDenied is the code of errors returned for calls lacking
+	// the permission of their method.
+	CodePermissionDenied = -32001
+)
+
+// MethodName returns the versioned name under which name is served, e.g.
+// Filecoin.v0.ChainHead.
+func MethodName(name string) string {
+	return fmt.Sprintf("Filecoin.v%d.%s", Version, name)
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	nullID      = json.RawMessage("null")
+)
+
+// method is a registered method.
+type method struct {
+	fn reflect.Value
+	// perm is the permission required to call fn.
+	perm auth.Permission
+	// params are the types of the parameters of fn after its context.
+	params []reflect.Type
+	// required is the number of params that must be passed.  The trailing
+	// pointer params after them are optional and nil when omitted.
+	required int
+	// hasResult is true if fn returns a result before its error.
+	hasResult bool
+}
+
+// Server serves JSON-RPC 2.0 requests over HTTP POST requests, and over
+// websockets for connections upgraded from a GET request.  Requests may be
+// batched.
+type Server struct {
+	methods  map[string]*method
+	upgrader websocket.Upgrader
+}
+
+// NewServer returns a server whose only method is Unsubscribe, which cancels
+// a subscription of the websocket connection calling it.
+func NewServer() *Server {
+	s := &Server{
+		methods: make(map[string]*method),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+		},
+	}
+	if err := s.Register("Unsubscribe", auth.PermRead, unsubscribe); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Register serves fn under the versioned name of name to requests with
+// permission perm.  fn takes a context followed by the parameters of the
+// method, which are passed by position, and returns either an error or a
+// result and an error.  Trailing parameters of pointer type may be omitted.
+func (s *Server) Register(name string, perm auth.Permission, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		return errors.Errorf("method %s is not a function", name)
+	}
+	if t.NumIn() == 0 || t.In(0) != contextType {
+		return errors.Errorf("method %s must take a context first", name)
+	}
+	if t.NumOut() == 0 || t.NumOut() > 2 || t.Out(t.NumOut()-1) != errorType {
+		return errors.Errorf("method %s must return an error last", name)
+	}
+
+	m := &method{fn: v, perm: perm, hasResult: t.NumOut() == 2}
+	for i := 1; i < t.NumIn(); i++ {
+		m.params = append(m.params, t.In(i))
+		if t.In(i).Kind() != reflect.Ptr {
+			m.required = len(m.params)
+		}
+	}
+	s.methods[MethodName(name)] = m
+	return nil
+}
+
+// ServeHTTP serves a request or a batch of requests POSTed to it, or upgrades
+// a GET request to a websocket connection.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebsocket(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	out := s.handle(r.Context(), body)
+	w.Header().Set("Content-Type", "application/json")
+	if out == nil {
+		// Only notifications, which have no response.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if _, err := w.Write(out); err != nil {
+		log.Debugf("failed to write response: %s", err)
+	}
+}
+
+// serveWebsocket serves the requests of a websocket connection until it is
+// closed.  Requests are handled in order, and the subscriptions they start
+// end with the connection.
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has replied with an error.
+		log.Debugf("failed to upgrade to websocket: %s", err)
+		return
+	}
+	ws.SetReadLimit(maxRequestSize)
+
+	c := &conn{ws: ws}
+	c.subs.subs = make(map[string]context.CancelFunc)
+	ctx, cancel := context.WithCancel(context.WithValue(r.Context(), connKey{}, c))
+	defer func() {
+		cancel()
+		ws.Close() // nolint: errcheck
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Debugf("websocket read failed: %s", err)
+			}
+			return
+		}
+		out := s.handle(ctx, data)
+		if out != nil {
+			if err := c.write(out); err != nil {
+				log.Debugf("websocket write failed: %s", err)
+				return
+			}
+		}
+		c.startPending()
+	}
+}
+
+// conn is a websocket connection, whose writes are serialized.
+type conn struct {
+	lk   sync.Mutex
+	ws   *websocket.Conn
+	subs subscriptions
+}
+
+func (c *conn) write(data []byte) error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// handle returns the response to data, a request or a batch of requests, or
+// nil if there is nothing to respond because they are all notifications.
+func (s *Server) handle(ctx context.Context, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return marshalResponse(errorResponse(nullID, CodeParseError, err.Error()))
+		}
+		if len(batch) == 0 {
+			return marshalResponse(errorResponse(nullID, CodeInvalidRequest, "empty batch"))
+		}
+		var resps []*response
+		for _, req := range batch {
+			if resp := s.handleOne(ctx, req); resp != nil {
+				resps = append(resps, resp)
+			}
+		}
+		if len(resps) == 0 {
+			return nil
+		}
+		return marshalResponse(resps)
+	}
+
+	resp := s.handleOne(ctx, data)
+	if resp == nil {
+		return nil
+	}
+	return marshalResponse(resp)
+}
+
+// handleOne returns the response to a single request, or nil if it is a
+// notification.
+func (s *Server) handleOne(ctx context.Context, data []byte) *response {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errorResponse(nullID, CodeParseError, err.Error())
+	}
+	id := req.ID
+	notification := len(id) == 0
+	if notification {
+		id = nullID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(id, CodeInvalidRequest, "invalid JSON-RPC 2.0 request")
+	}
+
+	result, rpcErr := s.call(ctx, req.Method, req.Params)
+	if notification {
+		return nil
+	}
+	if rpcErr != nil {
+		return &response{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(id, CodeInternalError, errors.Wrap(err, "failed to encode result").Error())
+	}
+	return &response{JSONRPC: "2.0", ID: id, Result: b}
+}
+
+// call calls the method name with params.
+func (s *Server) call(ctx context.Context, name string, params json.RawMessage) (interface{}, *Error) {
+	m, ok := s.methods[name]
+	if !ok {
+		return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %s not found", name)}
+	}
+	if err := auth.Require(ctx, m.perm); err != nil {
+		return nil, &Error{Code: CodePermissionDenied, Message: err.Error()}
+	}
+
+	var raw []json.RawMessage
+	if len(params) > 0 && !bytes.Equal(params, nullID) {
+		if err := json.Unmarshal(params, &raw); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "params must be an array"}
+		}
+	}
+	if len(raw) < m.required || len(raw) > len(m.params) {
+		expected := fmt.Sprintf("%d", len(m.params))
+		if m.required < len(m.params) {
+			expected = fmt.Sprintf("%d to %d", m.required, len(m.params))
+		}
+		return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("expected %s params, got %d", expected, len(raw))}
+	}
+
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	for i, t := range m.params {
+		arg := reflect.New(t)
+		if i >= len(raw) {
+			args = append(args, arg.Elem())
+			continue
+		}
+		if err := json.Unmarshal(raw[i], arg.Interface()); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid param %d: %s", i, err)}
+		}
+		args = append(args, arg.Elem())
+	}
+
+	out := m.fn.Call(args)
+	if err, _ := out[len(out)-1].Interface().(error); err != nil {
+		return nil, &Error{Code: CodeServerError, Message: err.Error()}
+	}
+	if !m.hasResult {
+		return nil, nil
+	}
+	return out[0].Interface(), nil
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	return &response{JSONRPC: "2.0", ID: id, Error: &Error{Code: code, Message: msg}}
+}
+
+func marshalResponse(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		// Responses only hold marshaled results and strings.
+		panic(err)
+	}
+	return b
+}
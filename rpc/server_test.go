@@ -0,0 +1,270 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/auth"
+	"github.com/filecoin-project/go-filecoin/rpc"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+type testResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpc.Error      `json:"error"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	s := rpc.NewServer()
+	require.NoError(t, s.Register("Add", auth.PermRead, func(ctx context.Context, a, b int) (int, error) {
+		return a + b, nil
+	}))
+	require.NoError(t, s.Register("Echo", auth.PermRead, func(ctx context.Context, addr address.Address) (address.Address, error) {
+		return addr, nil
+	}))
+	require.NoError(t, s.Register("Scale", auth.PermRead, func(ctx context.Context, a int, by *int) (int, error) {
+		if by == nil {
+			return a, nil
+		}
+		return a * *by, nil
+	}))
+	require.NoError(t, s.Register("Fail", auth.PermRead, func(ctx context.Context) error {
+		return errors.New("failed")
+	}))
+	return httptest.NewServer(auth.NewHandler(nil, auth.PermAdmin, s))
+}
+
+func post(t *testing.T, url, body string) (int, []byte) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	var out json.RawMessage
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	}
+	return resp.StatusCode, out
+}
+
+func TestServer(t *testing.T) {
+	tf.UnitTest(t)
+
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	call := func(t *testing.T, body string) *testResponse {
+		code, out := post(t, ts.URL, body)
+		require.Equal(t, http.StatusOK, code)
+		var resp testResponse
+		require.NoError(t, json.Unmarshal(out, &resp))
+		assert.Equal(t, "2.0", resp.JSONRPC)
+		return &resp
+	}
+
+	t.Run("calls versioned methods", func(t *testing.T) {
+		assert.Equal(t, "Filecoin.v0.Add", rpc.MethodName("Add"))
+
+		resp := call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Add","params":[2,3]}`)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, "1", string(resp.ID))
+		assert.Equal(t, "5", string(resp.Result))
+
+		resp = call(t, `{"jsonrpc":"2.0","id":"a","method":"Filecoin.v0.Echo","params":["`+address.TestAddress.String()+`"]}`)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, `"`+address.TestAddress.String()+`"`, string(resp.Result))
+	})
+
+	t.Run("omits trailing pointer params", func(t *testing.T) {
+		resp := call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Scale","params":[2,3]}`)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, "6", string(resp.Result))
+
+		resp = call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Scale","params":[2,null]}`)
+		assert.Equal(t, "2", string(resp.Result))
+
+		resp = call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Scale","params":[2]}`)
+		assert.Equal(t, "2", string(resp.Result))
+
+		resp = call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Scale","params":[]}`)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, rpc.CodeInvalidParams, resp.Error.Code)
+		assert.Equal(t, "expected 1 to 2 params, got 0", resp.Error.Message)
+	})
+
+	t.Run("returns errors", func(t *testing.T) {
+		resp := call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Fail"}`)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, rpc.CodeServerError, resp.Error.Code)
+		assert.Equal(t, "failed", resp.Error.Message)
+
+		resp = call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v1.Add","params":[2,3]}`)
+		assert.Equal(t, rpc.CodeMethodNotFound, resp.Error.Code)
+
+		resp = call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Add","params":[2]}`)
+		assert.Equal(t, rpc.CodeInvalidParams, resp.Error.Code)
+
+		resp = call(t, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Add","params":["x",3]}`)
+		assert.Equal(t, rpc.CodeInvalidParams, resp.Error.Code)
+
+		resp = call(t, `{"id":1,"method":"Filecoin.v0.Add","params":[2,3]}`)
+		assert.Equal(t, rpc.CodeInvalidRequest, resp.Error.Code)
+
+		resp = call(t, `{"jsonrpc":`)
+		assert.Equal(t, rpc.CodeParseError, resp.Error.Code)
+	})
+
+	t.Run("answers batches without notifications", func(t *testing.T) {
+		code, out := post(t, ts.URL, `[
+			{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Add","params":[1,1]},
+			{"jsonrpc":"2.0","method":"Filecoin.v0.Add","params":[1,2]},
+			{"jsonrpc":"2.0","id":2,"method":"Filecoin.v0.Fail"}
+		]`)
+		require.Equal(t, http.StatusOK, code)
+		var resps []testResponse
+		require.NoError(t, json.Unmarshal(out, &resps))
+		require.Len(t, resps, 2)
+		assert.Equal(t, "2", string(resps[0].Result))
+		assert.Equal(t, rpc.CodeServerError, resps[1].Error.Code)
+
+		code, _ = post(t, ts.URL, `{"jsonrpc":"2.0","method":"Filecoin.v0.Add","params":[1,2]}`)
+		assert.Equal(t, http.StatusNoContent, code)
+	})
+
+	t.Run("serves websockets", func(t *testing.T) {
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+		require.NoError(t, err)
+		defer ws.Close() // nolint: errcheck
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":7,"method":"Filecoin.v0.Add","params":[3,4]}`)))
+			var resp testResponse
+			require.NoError(t, ws.ReadJSON(&resp))
+			assert.Equal(t, "7", string(resp.ID))
+			assert.Equal(t, "7", string(resp.Result))
+		}
+	})
+}
+
+func TestServerSubscribe(t *testing.T) {
+	tf.UnitTest(t)
+
+	s := rpc.NewServer()
+	stopped := make(chan struct{})
+	require.NoError(t, s.Register("Count", auth.PermRead, func(ctx context.Context, n int) (string, error) {
+		return rpc.Subscribe(ctx, func(ctx context.Context, notify func(interface{}) error) error {
+			for i := 1; i <= n; i++ {
+				if err := notify(i); err != nil {
+					return err
+				}
+			}
+			if n < 0 {
+				return errors.New("negative count")
+			}
+			<-ctx.Done()
+			close(stopped)
+			return nil
+		})
+	}))
+	ts := httptest.NewServer(auth.NewHandler(nil, auth.PermAdmin, s))
+	defer ts.Close()
+
+	t.Run("requires a websocket", func(t *testing.T) {
+		_, out := post(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Count","params":[1]}`)
+		var resp testResponse
+		require.NoError(t, json.Unmarshal(out, &resp))
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, rpc.CodeServerError, resp.Error.Code)
+	})
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer ws.Close() // nolint: errcheck
+
+	type testNotification struct {
+		JSONRPC string                 `json:"jsonrpc"`
+		Method  string                 `json:"method"`
+		Params  rpc.SubscriptionParams `json:"params"`
+	}
+
+	t.Run("notifies after responding", func(t *testing.T) {
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Count","params":[2]}`)))
+		var resp testResponse
+		require.NoError(t, ws.ReadJSON(&resp))
+		require.Nil(t, resp.Error)
+		var id string
+		require.NoError(t, json.Unmarshal(resp.Result, &id))
+
+		for i := 1; i <= 2; i++ {
+			var n testNotification
+			require.NoError(t, ws.ReadJSON(&n))
+			assert.Equal(t, rpc.SubscriptionMethod, n.Method)
+			assert.Equal(t, id, n.Params.Subscription)
+			assert.Equal(t, strconv.Itoa(i), string(n.Params.Result))
+		}
+
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"Filecoin.v0.Unsubscribe","params":["`+id+`"]}`)))
+		require.NoError(t, ws.ReadJSON(&resp))
+		assert.Equal(t, "true", string(resp.Result))
+		<-stopped
+
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"Filecoin.v0.Unsubscribe","params":["`+id+`"]}`)))
+		require.NoError(t, ws.ReadJSON(&resp))
+		assert.Equal(t, "false", string(resp.Result))
+	})
+
+	t.Run("notifies failures", func(t *testing.T) {
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Count","params":[-1]}`)))
+		var resp testResponse
+		require.NoError(t, ws.ReadJSON(&resp))
+		var id string
+		require.NoError(t, json.Unmarshal(resp.Result, &id))
+
+		var n testNotification
+		require.NoError(t, ws.ReadJSON(&n))
+		assert.Equal(t, id, n.Params.Subscription)
+		require.NotNil(t, n.Params.Error)
+		assert.Equal(t, "negative count", n.Params.Error.Message)
+	})
+}
+
+func TestServerPermissions(t *testing.T) {
+	tf.UnitTest(t)
+
+	s := rpc.NewServer()
+	require.NoError(t, s.Register("Read", auth.PermRead, func(ctx context.Context) error { return nil }))
+	require.NoError(t, s.Register("Write", auth.PermWrite, func(ctx context.Context) error { return nil }))
+	ts := httptest.NewServer(auth.NewHandler(nil, auth.PermRead, s))
+	defer ts.Close()
+
+	_, out := post(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Read"}`)
+	var resp testResponse
+	require.NoError(t, json.Unmarshal(out, &resp))
+	assert.Nil(t, resp.Error)
+
+	_, out = post(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"Filecoin.v0.Write"}`)
+	resp = testResponse{}
+	require.NoError(t, json.Unmarshal(out, &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, rpc.CodePermissionDenied, resp.Error.Code)
+}
+
+func TestServerRegister(t *testing.T) {
+	tf.UnitTest(t)
+
+	s := rpc.NewServer()
+	assert.Error(t, s.Register("NoContext", auth.PermRead, func(a int) error { return nil }))
+	assert.Error(t, s.Register("NoError", auth.PermRead, func(ctx context.Context) int { return 0 }))
+	assert.Error(t, s.Register("NotAFunction", auth.PermRead, 3))
+}
@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SubscriptionMethod is the name of the method of the notifications a
+// subscription sends.
+var SubscriptionMethod = MethodName("Subscription")
+
+// SubscriptionFunc runs a subscription, calling notify for each result to
+// send to the subscriber, until ctx is done or it fails.  notify fails if the
+// result can't be sent, e.g. because the connection closed.
+type SubscriptionFunc func(ctx context.Context, notify func(result interface{}) error) error
+
+// SubscriptionParams are the params of the notifications of a subscription.
+// A notification carrying an error is the last one of its subscription.
+type SubscriptionParams struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        *Error          `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string              `json:"jsonrpc"`
+	Method  string              `json:"method"`
+	Params  *SubscriptionParams `json:"params"`
+}
+
+// connKey is the key of the websocket connection in the context of the
+// methods it calls.
+type connKey struct{}
+
+// subscriptions are the subscriptions of a websocket connection.
+type subscriptions struct {
+	lk     sync.Mutex
+	nextID uint64
+	subs   map[string]context.CancelFunc
+	// pending are closed once the responses to the requests that started
+	// their subscriptions are written, so that notifications follow them.
+	pending []chan struct{}
+}
+
+// Subscribe starts a subscription running run on the websocket connection of
+// ctx, the context of a method, and returns its id.  The subscription lasts
+// until it is unsubscribed, run returns or the connection closes.  Methods
+// called over HTTP can't subscribe.
+func Subscribe(ctx context.Context, run SubscriptionFunc) (string, error) {
+	c, ok := ctx.Value(connKey{}).(*conn)
+	if !ok {
+		return "", errors.New("subscriptions require a websocket connection")
+	}
+
+	c.subs.lk.Lock()
+	c.subs.nextID++
+	id := strconv.FormatUint(c.subs.nextID, 10)
+	subCtx, cancel := context.WithCancel(ctx)
+	c.subs.subs[id] = cancel
+	ready := make(chan struct{})
+	c.subs.pending = append(c.subs.pending, ready)
+	c.subs.lk.Unlock()
+
+	go func() {
+		defer c.unsubscribe(id)
+		select {
+		case <-subCtx.Done():
+			return
+		case <-ready:
+		}
+
+		notify := func(result interface{}) error {
+			b, err := json.Marshal(result)
+			if err != nil {
+				return errors.Wrap(err, "failed to encode notification")
+			}
+			return c.notify(&SubscriptionParams{Subscription: id, Result: b})
+		}
+		err := run(subCtx, notify)
+		if err != nil && subCtx.Err() == nil {
+			log.Debugf("subscription %s failed: %s", id, err)
+			if err := c.notify(&SubscriptionParams{Subscription: id, Error: &Error{Code: CodeServerError, Message: err.Error()}}); err != nil {
+				log.Debugf("failed to notify subscription %s of its failure: %s", id, err)
+			}
+		}
+	}()
+	return id, nil
+}
+
+// unsubscribe cancels the subscription id, returning false if there is no
+// such subscription.
+func (c *conn) unsubscribe(id string) bool {
+	c.subs.lk.Lock()
+	defer c.subs.lk.Unlock()
+	cancel, ok := c.subs.subs[id]
+	if ok {
+		cancel()
+		delete(c.subs.subs, id)
+	}
+	return ok
+}
+
+// startPending lets the subscriptions started by the last requests notify.
+func (c *conn) startPending() {
+	c.subs.lk.Lock()
+	defer c.subs.lk.Unlock()
+	for _, ready := range c.subs.pending {
+		close(ready)
+	}
+	c.subs.pending = nil
+}
+
+func (c *conn) notify(params *SubscriptionParams) error {
+	return c.write(marshalResponse(&notification{JSONRPC: "2.0", Method: SubscriptionMethod, Params: params}))
+}
+
+// unsubscribe is the method cancelling a subscription of the connection
+// calling it.
+func unsubscribe(ctx context.Context, id string) (bool, error) {
+	c, ok := ctx.Value(connKey{}).(*conn)
+	if !ok {
+		return false, errors.New("subscriptions require a websocket connection")
+	}
+	return c.unsubscribe(id), nil
+}
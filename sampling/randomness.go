@@ -0,0 +1,45 @@
+package sampling
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Tag distinguishes the uses of randomness, so that a source can draw
+// different values for different uses at the same epoch.
+type Tag string
+
+const (
+	// PoStChallengeTag tags the randomness drawn to generate PoSt
+	// challenge seeds.
+	PoStChallengeTag = Tag("post-challenge")
+)
+
+// Randomness is a source of randomness for the epochs of the chain.
+type Randomness interface {
+	// Randomness returns the randomness for the use tag at epoch.
+	Randomness(ctx context.Context, epoch *types.BlockHeight, tag Tag) ([]byte, error)
+}
+
+// ChainRandomness draws randomness from the tickets of a chain, as
+// SampleChainRandomness does.
+type ChainRandomness struct {
+	tipSetsSortedByBlockHeightDescending []types.TipSet
+}
+
+var _ Randomness = (*ChainRandomness)(nil)
+
+// NewChainRandomness returns a ChainRandomness sampling the given tipsets,
+// which must be sorted by block height in descending order and include the
+// LookbackParameter tipsets below the epochs sampled.
+func NewChainRandomness(tipSetsSortedByBlockHeightDescending []types.TipSet) *ChainRandomness {
+	return &ChainRandomness{tipSetsSortedByBlockHeightDescending}
+}
+
+// Randomness returns the min ticket of the tipset LookbackParameter tipsets
+// below epoch.  The chain holds a single ticket per tipset, so tag doesn't
+// change the value.
+func (r *ChainRandomness) Randomness(ctx context.Context, epoch *types.BlockHeight, tag Tag) ([]byte, error) {
+	return SampleChainRandomness(epoch, r.tipSetsSortedByBlockHeightDescending)
+}
@@ -0,0 +1,54 @@
+package sampling_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/sampling"
+	"github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestChainRandomness(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	chain := testhelpers.RequireTipSetChain(t, 20)
+	r := sampling.NewChainRandomness(chain)
+
+	for _, height := range []uint64{3, 10, 20} {
+		expected, err := sampling.SampleChainRandomness(types.NewBlockHeight(height), chain)
+		require.NoError(t, err)
+		actual, err := r.Randomness(ctx, types.NewBlockHeight(height), sampling.PoStChallengeTag)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := r.Randomness(ctx, types.NewBlockHeight(30), sampling.PoStChallengeTag)
+	assert.Error(t, err)
+}
+
+func TestFakeRandomness(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	r := &sampling.FakeRandomness{Seed: []byte("seed")}
+
+	a, err := r.Randomness(ctx, types.NewBlockHeight(10), sampling.PoStChallengeTag)
+	require.NoError(t, err)
+	again, err := r.Randomness(ctx, types.NewBlockHeight(10), sampling.PoStChallengeTag)
+	require.NoError(t, err)
+	assert.Equal(t, a, again)
+
+	otherEpoch, err := r.Randomness(ctx, types.NewBlockHeight(11), sampling.PoStChallengeTag)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, otherEpoch)
+
+	otherTag, err := r.Randomness(ctx, types.NewBlockHeight(10), sampling.Tag("other"))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, otherTag)
+}
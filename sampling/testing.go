@@ -0,0 +1,26 @@
+package sampling
+
+import (
+	"context"
+
+	"github.com/minio/sha256-simd"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// FakeRandomness is a deterministic source of randomness for tests, which
+// derives the randomness of an epoch and tag from a seed without a chain.
+type FakeRandomness struct {
+	Seed []byte
+}
+
+var _ Randomness = (*FakeRandomness)(nil)
+
+// Randomness returns the hash of the seed, epoch and tag.
+func (r *FakeRandomness) Randomness(ctx context.Context, epoch *types.BlockHeight, tag Tag) ([]byte, error) {
+	h := sha256.New()
+	h.Write(r.Seed)        // nolint: errcheck
+	h.Write(epoch.Bytes()) // nolint: errcheck
+	h.Write([]byte(tag))   // nolint: errcheck
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ActorDump is an actor along with its decoded storage, for inspection.
+type ActorDump struct {
+	Address   address.Address `json:"address"`
+	ActorType string          `json:"actorType"`
+	Code      cid.Cid         `json:"code,omitempty"`
+	Head      cid.Cid         `json:"head,omitempty"`
+	Nonce     uint64          `json:"nonce"`
+	Balance   *types.AttoFIL  `json:"balance"`
+	// State is the actor's decoded storage, or nil when the actor has no
+	// storage or its code doesn't describe it.
+	State interface{} `json:"state,omitempty"`
+}
+
+// stateDescriber is implemented by the builtin actors whose storage is a
+// single struct that can be decoded without running the actor.
+type stateDescriber interface {
+	// EmptyState returns a pointer to a zero value of the actor's state.
+	EmptyState() interface{}
+}
+
+// DumpActor returns the actor at address addr in st along with its decoded
+// storage.
+func DumpActor(ctx context.Context, st Tree, addr address.Address) (*ActorDump, error) {
+	act, err := st.GetActor(ctx, addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get actor %s", addr)
+	}
+
+	dump := &ActorDump{
+		Address:   addr,
+		ActorType: types.ActorCodeTypeName(act.Code),
+		Code:      act.Code,
+		Head:      act.Head,
+		Nonce:     uint64(act.Nonce),
+		Balance:   act.Balance,
+	}
+	if act.Empty() || !act.Head.Defined() {
+		return dump, nil
+	}
+
+	code, err := st.GetBuiltinActorCode(act.Code)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load code of actor %s", addr)
+	}
+	describer, ok := code.(stateDescriber)
+	if !ok {
+		return dump, nil
+	}
+
+	stg := describer.EmptyState()
+	if err := st.GetActorStorage(ctx, addr, stg); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode storage of actor %s", addr)
+	}
+	dump.State = stg
+	return dump, nil
+}
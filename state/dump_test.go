@@ -0,0 +1,58 @@
+package state_test
+
+import (
+	"context"
+	"testing"
+
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	. "github.com/filecoin-project/go-filecoin/state"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestDumpActor(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
+
+	blk, err := consensus.DefaultGenesis(cst, bs)
+	require.NoError(t, err)
+	st, err := LoadStateTree(ctx, cst, blk.StateRoot, builtin.Actors)
+	require.NoError(t, err)
+
+	t.Run("decodes the state of builtin actors", func(t *testing.T) {
+		dump, err := DumpActor(ctx, st, address.NetworkAddress)
+		require.NoError(t, err)
+
+		assert.Equal(t, "RewardActor", dump.ActorType)
+		assert.Equal(t, types.RewardActorCodeCid, dump.Code)
+		assert.Equal(t, consensus.DefaultNetworkBalance, dump.Balance)
+		assert.Equal(t, reward.DefaultState(), dump.State)
+	})
+
+	t.Run("omits the state of actors without storage", func(t *testing.T) {
+		dump, err := DumpActor(ctx, st, address.TestAddress)
+		require.NoError(t, err)
+
+		assert.Equal(t, "AccountActor", dump.ActorType)
+		assert.Nil(t, dump.State)
+	})
+
+	t.Run("fails on unknown addresses", func(t *testing.T) {
+		_, err := DumpActor(ctx, st, address.NewForTestGetter()())
+		assert.Error(t, err)
+	})
+}
@@ -42,6 +42,8 @@ type Tree interface {
 
 	ForEachActor(ctx context.Context, walkFn ActorWalkFn) error
 
+	GetActorStorage(ctx context.Context, a address.Address, stg interface{}) error
+
 	GetBuiltinActorCode(c cid.Cid) (exec.ExecutableActor, error)
 }
 
@@ -125,6 +127,19 @@ func (t *tree) GetBuiltinActorCode(codePointer cid.Cid) (exec.ExecutableActor, e
 	return actor, nil
 }
 
+// GetActorStorage decodes the storage the head of the actor at address a
+// points to into stg.  The storage must have been flushed to the tree's store.
+func (t *tree) GetActorStorage(ctx context.Context, a address.Address, stg interface{}) error {
+	act, err := t.GetActor(ctx, a)
+	if err != nil {
+		return err
+	}
+	if !act.Head.Defined() {
+		return fmt.Errorf("actor %s has no storage", a)
+	}
+	return t.store.Get(ctx, act.Head, stg)
+}
+
 // GetActor retrieves an actor by their address. If no actor
 // exists at the given address then an error will be returned
 // for which IsActorNotFoundError(err) is true.
@@ -195,7 +195,7 @@ func MakeProofAndWinningTicket(signerPubKey []byte, minerPower uint64, totalPowe
 			errStr := fmt.Sprintf("error creating ticket: %s", err)
 			panic(errStr)
 		}
-		if consensus.CompareTicketPower(ticket, minerPower, totalPower) {
+		if consensus.CompareTicketPower(ticket, types.NewBytesAmount(minerPower), types.NewBytesAmount(totalPower)) {
 			return poStProof, ticket, nil
 		}
 	}
@@ -249,3 +249,25 @@ func (bs *FakeBlockProvider) NewBlockWithMessages(nonce uint64, messages []*type
 func (bs *FakeBlockProvider) NewBlock(nonce uint64, parents ...*types.Block) *types.Block {
 	return bs.NewBlockWithMessages(nonce, []*types.SignedMessage{}, parents...)
 }
+
+// NewBlockAfterNullRounds creates and stores a new block in this provider
+// whose height is nullRounds greater than a direct child of its parents would
+// have.
+func (bs *FakeBlockProvider) NewBlockAfterNullRounds(nonce uint64, nullRounds uint64, parents ...*types.Block) *types.Block {
+	b := &types.Block{
+		Nonce:    types.Uint64(nonce),
+		Messages: []*types.SignedMessage{},
+		Height:   types.Uint64(nullRounds),
+	}
+
+	if len(parents) > 0 {
+		b.Height += parents[0].Height + 1
+		b.StateRoot = parents[0].StateRoot
+		for _, p := range parents {
+			b.Parents.Add(p.Cid())
+		}
+	}
+
+	bs.blocks[b.Cid()] = b
+	return b
+}
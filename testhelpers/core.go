@@ -118,6 +118,11 @@ func (v *MockMessagePoolValidator) Validate(ctx context.Context, msg *types.Sign
 	return errors.New("mock validation error")
 }
 
+// ValidateSpend returns true if the mock validator is set to validate the message
+func (v *MockMessagePoolValidator) ValidateSpend(ctx context.Context, msg *types.SignedMessage, pendingSpend *types.AttoFIL) error {
+	return v.Validate(ctx, msg)
+}
+
 // BlockHeight represents the height of the highest tipset.
 func (tbt *TestMessagePoolAPI) BlockHeight() (uint64, error) {
 	return tbt.Height, nil
@@ -13,6 +13,15 @@ import (
 	"github.com/filecoin-project/go-filecoin/address"
 )
 
+const (
+	// BlockMessageLimit is the maximum number of messages a block may
+	// include.
+	BlockMessageLimit = 1000
+	// BlockSizeLimit is the maximum size in bytes of a serialized block,
+	// including its messages and receipts.
+	BlockSizeLimit = 2 << 20
+)
+
 func init() {
 	cbor.RegisterCborType(Block{})
 }
@@ -39,10 +48,19 @@ type Block struct {
 	// Nonce is a temporary field used to differentiate blocks for testing
 	Nonce Uint64 `json:"nonce"`
 
+	// Timestamp is the unix time at which the block was mined.  It is zero
+	// for blocks mined before blocks were timestamped, and omitted from
+	// their encoding so that their cids are unchanged.
+	Timestamp Uint64 `json:"timestamp,omitempty" refmt:",omitempty"`
+
 	// Messages is the set of messages included in this block
 	// TODO: should be a merkletree-ish thing
 	Messages []*SignedMessage `json:"messages"`
 
+	// BLSAggregateSig aggregates the signatures of the messages sent from
+	// BLS addresses, which are included without their own signatures.
+	BLSAggregateSig Signature `json:"blsAggregateSig,omitempty" refmt:",omitempty"`
+
 	// StateRoot is a cid pointer to the state tree after application of the
 	// transactions state transitions.
 	StateRoot cid.Cid `json:"stateRoot,omitempty" refmt:",omitempty"`
@@ -112,6 +130,11 @@ func (b *Block) ToNode() node.Node {
 	return obj
 }
 
+// Size returns the size in bytes of the serialized block.
+func (b *Block) Size() int {
+	return len(b.ToNode().RawData())
+}
+
 func (b *Block) String() string {
 	errStr := "(error encoding Block)"
 	cid := b.Cid()
@@ -134,6 +157,19 @@ func DecodeBlock(b []byte) (*Block, error) {
 	return &out, nil
 }
 
+// Clone returns a deep copy of the block, decoded from the block's encoding so
+// that it shares nothing mutable with the block.
+func (b *Block) Clone() *Block {
+	// Cid encodes the block if it wasn't already.
+	c := b.Cid()
+	out, err := DecodeBlock(b.cachedBytes)
+	if err != nil {
+		panic(err)
+	}
+	out.cachedCid = c
+	return out
+}
+
 // Score returns the score of this block. Naively this will just return the
 // height. But in the future this will return a more sophisticated metric to be
 // used in the fork choice rule
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"bytes"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+)
+
+func init() {
+	cbor.RegisterCborType(BlockHeader{})
+}
+
+// BlockHeader announces a block without carrying its messages and receipts.
+// It holds the cids of the block and of its messages, so that a receiver can
+// run cheap checks on the header and fetch the full block only if they pass.
+type BlockHeader struct {
+	// Cid is the cid of the announced block.
+	Cid cid.Cid `json:"cid"`
+
+	Miner        address.Address `json:"miner"`
+	Ticket       Signature       `json:"ticket"`
+	Parents      SortedCidSet    `json:"parents"`
+	ParentWeight Uint64          `json:"parentWeight"`
+	Height       Uint64          `json:"height"`
+	Nonce        Uint64          `json:"nonce"`
+	Timestamp    Uint64          `json:"timestamp,omitempty" refmt:",omitempty"`
+	StateRoot    cid.Cid         `json:"stateRoot,omitempty" refmt:",omitempty"`
+	Proof        PoStProof       `json:"proof"`
+
+	// MessageCids are the cids of the block's messages, in order.
+	MessageCids []cid.Cid `json:"messageCids"`
+}
+
+// NewBlockHeader returns the header announcing b.
+func NewBlockHeader(b *Block) (*BlockHeader, error) {
+	msgCids, err := messageCids(b.Messages)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockHeader{
+		Cid:          b.Cid(),
+		Miner:        b.Miner,
+		Ticket:       b.Ticket,
+		Parents:      b.Parents,
+		ParentWeight: b.ParentWeight,
+		Height:       b.Height,
+		Nonce:        b.Nonce,
+		Timestamp:    b.Timestamp,
+		StateRoot:    b.StateRoot,
+		Proof:        b.Proof,
+		MessageCids:  msgCids,
+	}, nil
+}
+
+// DecodeBlockHeader decodes raw cbor bytes into a BlockHeader.
+func DecodeBlockHeader(b []byte) (*BlockHeader, error) {
+	var out BlockHeader
+	if err := cbor.DecodeInto(b, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Marshal returns the cbor encoding of the header.
+func (h *BlockHeader) Marshal() ([]byte, error) {
+	return cbor.DumpObject(h)
+}
+
+// CheckBlock returns an error if b is not the block the header announces.
+func (h *BlockHeader) CheckBlock(b *Block) error {
+	if !b.Cid().Equals(h.Cid) {
+		return errors.Errorf("block %s does not have the announced cid %s", b.Cid(), h.Cid)
+	}
+	if b.Miner != h.Miner || b.Height != h.Height || b.Nonce != h.Nonce || b.Timestamp != h.Timestamp || b.ParentWeight != h.ParentWeight ||
+		!b.Parents.Equals(h.Parents) || !b.StateRoot.Equals(h.StateRoot) ||
+		!bytes.Equal(b.Ticket, h.Ticket) || !bytes.Equal(b.Proof, h.Proof) {
+		return errors.Errorf("block %s does not match its header", h.Cid)
+	}
+
+	msgCids, err := messageCids(b.Messages)
+	if err != nil {
+		return err
+	}
+	if len(msgCids) != len(h.MessageCids) {
+		return errors.Errorf("block %s has %d messages, its header announced %d", h.Cid, len(msgCids), len(h.MessageCids))
+	}
+	for i, c := range msgCids {
+		if !c.Equals(h.MessageCids[i]) {
+			return errors.Errorf("message %d of block %s is %s, its header announced %s", i, h.Cid, c, h.MessageCids[i])
+		}
+	}
+	return nil
+}
+
+func messageCids(msgs []*SignedMessage) ([]cid.Cid, error) {
+	cids := make([]cid.Cid, len(msgs))
+	for i, msg := range msgs {
+		c, err := msg.Cid()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute cid of message %d", i)
+		}
+		cids[i] = c
+	}
+	return cids, nil
+}
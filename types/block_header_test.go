@@ -0,0 +1,71 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestBlockHeader(t *testing.T) {
+	tf.UnitTest(t)
+
+	newSignedMessage := NewSignedMessageForTestGetter(mockSigner)
+	newBlock := func() *Block {
+		return &Block{
+			Miner:     address.NewForTestGetter()(),
+			Ticket:    []byte{1, 2},
+			Parents:   NewSortedCidSet(SomeCid()),
+			Height:    2,
+			Messages:  []*SignedMessage{newSignedMessage(), newSignedMessage()},
+			StateRoot: SomeCid(),
+			MessageReceipts: []*MessageReceipt{
+				{ExitCode: 0, Return: [][]byte{{1}}},
+				{ExitCode: 1},
+			},
+		}
+	}
+
+	t.Run("round trips and announces the block's messages", func(t *testing.T) {
+		blk := newBlock()
+		header, err := NewBlockHeader(blk)
+		require.NoError(t, err)
+		assert.Equal(t, blk.Cid(), header.Cid)
+		require.Len(t, header.MessageCids, 2)
+		msgCid, err := blk.Messages[1].Cid()
+		require.NoError(t, err)
+		assert.Equal(t, msgCid, header.MessageCids[1])
+
+		data, err := header.Marshal()
+		require.NoError(t, err)
+		assert.True(t, len(data) < blk.Size())
+		decoded, err := DecodeBlockHeader(data)
+		require.NoError(t, err)
+		assert.Equal(t, header, decoded)
+		assert.NoError(t, decoded.CheckBlock(blk))
+	})
+
+	t.Run("rejects a block other than the announced one", func(t *testing.T) {
+		header, err := NewBlockHeader(newBlock())
+		require.NoError(t, err)
+		other := newBlock()
+		other.Messages = other.Messages[:1]
+		assert.Error(t, header.CheckBlock(other))
+	})
+
+	t.Run("rejects a block that does not match its header", func(t *testing.T) {
+		blk := newBlock()
+		header, err := NewBlockHeader(blk)
+		require.NoError(t, err)
+		header.Height = 3
+		assert.Error(t, header.CheckBlock(blk))
+	})
+
+	t.Run("decode failure results in an error", func(t *testing.T) {
+		_, err := DecodeBlockHeader([]byte{1, 2, 3})
+		assert.Error(t, err)
+	})
+}
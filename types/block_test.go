@@ -66,7 +66,9 @@ func TestTriangleEncoding(t *testing.T) {
 			Ticket:          []byte{0x01, 0x02, 0x03},
 			Height:          Uint64(2),
 			Nonce:           3,
+			Timestamp:       Uint64(1234),
 			Messages:        []*SignedMessage{newSignedMessage()},
+			BLSAggregateSig: []byte{0x04, 0x05},
 			MessageReceipts: []*MessageReceipt{{ExitCode: 1}},
 			Parents:         NewSortedCidSet(SomeCid()),
 			ParentWeight:    Uint64(1000),
@@ -76,7 +78,7 @@ func TestTriangleEncoding(t *testing.T) {
 		s := reflect.TypeOf(*b)
 		// This check is here to request that you add a non-zero value for new fields
 		// to the above (and update the field count below).
-		require.Equal(t, 12, s.NumField()) // Note: this also counts private fields
+		require.Equal(t, 14, s.NumField()) // Note: this also counts private fields
 		testRoundTrip(t, b)
 	})
 }
@@ -104,6 +106,29 @@ func TestBlockString(t *testing.T) {
 	assert.Contains(t, got, cid.String())
 }
 
+func TestBlockCidWithoutTimestamp(t *testing.T) {
+	tf.UnitTest(t)
+
+	// These cids were computed before blocks carried a timestamp: blocks
+	// without one must keep them for chains mined back then to validate.
+	assert.Equal(t, "zDPWYqFCswmjv5Ku9p1V6Lri4VyCQ8eoac5GY1oSCBjBUnyJWH13", (&Block{}).Cid().String())
+
+	b := &Block{
+		Miner:           address.TestAddress,
+		Ticket:          []byte{0x01, 0x02, 0x03},
+		Parents:         NewSortedCidSet(SomeCid()),
+		ParentWeight:    Uint64(1000),
+		Height:          Uint64(2),
+		Nonce:           Uint64(3),
+		StateRoot:       SomeCid(),
+		MessageReceipts: []*MessageReceipt{{ExitCode: 1}},
+	}
+	assert.Equal(t, "zDPWYqFCyFpPjBAEj6tTsewWN1FQs61wxW5RjL3x7QUBYBkDz89A", b.Cid().String())
+
+	b = &Block{Height: b.Height, Nonce: b.Nonce, Timestamp: Uint64(1234)}
+	assert.NotEqual(t, (&Block{Height: b.Height, Nonce: b.Nonce}).Cid(), b.Cid())
+}
+
 func TestBlockScore(t *testing.T) {
 	tf.UnitTest(t)
 
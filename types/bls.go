@@ -0,0 +1,118 @@
+package types
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
+)
+
+// isBLS returns true if a is a BLS address.
+func isBLS(a address.Address) bool {
+	return !a.Empty() && a.Protocol() == address.BLS
+}
+
+// SignBLS signs data with the BLS private key sk.
+func SignBLS(sk []byte, data []byte) (Signature, error) {
+	if len(sk) != bls.PrivateKeyBytes {
+		return nil, errors.Errorf("BLS private key must be %d bytes, got %d", bls.PrivateKeyBytes, len(sk))
+	}
+	var key bls.PrivateKey
+	copy(key[:], sk)
+	sig := bls.PrivateKeySign(key, data)
+	return sig[:], nil
+}
+
+// AggregateBLSSignatures returns the signature aggregating sigs, which
+// verifies against the data and signers of all of them at once.
+func AggregateBLSSignatures(sigs []Signature) (Signature, error) {
+	blsSigs := make([]bls.Signature, len(sigs))
+	for i, sig := range sigs {
+		if len(sig) != bls.SignatureBytes {
+			return nil, errors.Errorf("BLS signature must be %d bytes, got %d", bls.SignatureBytes, len(sig))
+		}
+		copy(blsSigs[i][:], sig)
+	}
+	agg := bls.Aggregate(blsSigs)
+	return agg[:], nil
+}
+
+// IsValidBLSAggregate returns true if sig aggregates the signatures of each
+// of data by the key of the BLS address of the same index in addrs.
+func IsValidBLSAggregate(data [][]byte, addrs []address.Address, sig Signature) bool {
+	if len(data) != len(addrs) || len(sig) != bls.SignatureBytes {
+		return false
+	}
+	digests := make([]bls.Digest, len(data))
+	keys := make([]bls.PublicKey, len(addrs))
+	for i, a := range addrs {
+		if !isBLS(a) || len(a.Payload()) != bls.PublicKeyBytes {
+			return false
+		}
+		copy(keys[i][:], a.Payload())
+		digests[i] = bls.Hash(data[i])
+	}
+	var blsSig bls.Signature
+	copy(blsSig[:], sig)
+	return bls.Verify(blsSig, digests, keys)
+}
+
+// AggregateBLSMessages returns msgs with the signatures of the messages sent
+// from BLS addresses replaced by a single signature aggregating them, which
+// is nil if none of msgs is from a BLS address.  Messages are copied rather
+// than modified.
+func AggregateBLSMessages(msgs []*SignedMessage) ([]*SignedMessage, Signature, error) {
+	var sigs []Signature
+	out := make([]*SignedMessage, len(msgs))
+	for i, msg := range msgs {
+		if !isBLS(msg.From) {
+			out[i] = msg
+			continue
+		}
+		sigs = append(sigs, msg.Signature)
+		stripped := *msg
+		stripped.Signature = nil
+		out[i] = &stripped
+	}
+	if len(sigs) == 0 {
+		return out, nil, nil
+	}
+	agg, err := AggregateBLSSignatures(sigs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, agg, nil
+}
+
+// VerifyBLSAggregate returns an error unless the signatures of the messages
+// of b sent from BLS addresses are aggregated into b's BLS aggregate
+// signature.  Those messages must not carry signatures of their own.
+func (b *Block) VerifyBLSAggregate() error {
+	var data [][]byte
+	var signers []address.Address
+	for _, msg := range b.Messages {
+		if !isBLS(msg.From) {
+			continue
+		}
+		if len(msg.Signature) > 0 {
+			return errors.Errorf("block contains message from %s with a BLS signature that isn't aggregated", msg.From)
+		}
+		bmsg, err := msg.MeteredMessage.Marshal()
+		if err != nil {
+			return err
+		}
+		data = append(data, bmsg)
+		signers = append(signers, msg.From)
+	}
+
+	if len(signers) == 0 {
+		if len(b.BLSAggregateSig) > 0 {
+			return errors.New("block has a BLS aggregate signature but no BLS messages")
+		}
+		return nil
+	}
+	if !IsValidBLSAggregate(data, signers, b.BLSAggregateSig) {
+		return errors.New("block has an invalid BLS aggregate signature")
+	}
+	return nil
+}
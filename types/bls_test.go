@@ -0,0 +1,86 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestBLSSignatures(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer := NewMockSigner(MustGenerateBLSKeyInfo(2))
+	for _, a := range signer.Addresses {
+		require.Equal(t, address.BLS, a.Protocol())
+	}
+	data := []byte("some data")
+
+	sig, err := signer.SignBytes(data, signer.Addresses[0])
+	require.NoError(t, err)
+	assert.True(t, IsValidSignature(data, signer.Addresses[0], sig))
+	assert.False(t, IsValidSignature(data, signer.Addresses[1], sig))
+	assert.False(t, IsValidSignature([]byte("other data"), signer.Addresses[0], sig))
+}
+
+func TestBLSAggregation(t *testing.T) {
+	tf.UnitTest(t)
+
+	blsSigner := NewMockSigner(MustGenerateBLSKeyInfo(2))
+	secpSigner, _ := NewMockSignersAndKeyInfo(1)
+	to := address.NewForTestGetter()()
+
+	newMsg := func(signer MockSigner, from address.Address) *SignedMessage {
+		msg := NewMessage(from, to, 0, NewAttoFILFromFIL(1), "", nil)
+		smsg, err := NewSignedMessage(*msg, signer, NewGasPrice(1), NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+	msgs := []*SignedMessage{
+		newMsg(blsSigner, blsSigner.Addresses[0]),
+		newMsg(secpSigner, secpSigner.Addresses[0]),
+		newMsg(blsSigner, blsSigner.Addresses[1]),
+	}
+
+	blockMsgs, agg, err := AggregateBLSMessages(msgs)
+	require.NoError(t, err)
+	require.NotNil(t, agg)
+	assert.Empty(t, blockMsgs[0].Signature)
+	assert.Equal(t, msgs[1].Signature, blockMsgs[1].Signature)
+	assert.Empty(t, blockMsgs[2].Signature)
+	// The original messages keep their signatures.
+	assert.NotEmpty(t, msgs[0].Signature)
+
+	t.Run("BLS messages keep their cid without their signature", func(t *testing.T) {
+		for i := range msgs {
+			before, err := msgs[i].Cid()
+			require.NoError(t, err)
+			after, err := blockMsgs[i].Cid()
+			require.NoError(t, err)
+			assert.Equal(t, before, after)
+		}
+	})
+
+	t.Run("blocks verify their aggregate", func(t *testing.T) {
+		blk := &Block{Messages: blockMsgs, BLSAggregateSig: agg}
+		assert.NoError(t, blk.VerifyBLSAggregate())
+
+		// Dropping a BLS message breaks the aggregate.
+		partial := &Block{Messages: blockMsgs[:2], BLSAggregateSig: agg}
+		assert.Error(t, partial.VerifyBLSAggregate())
+
+		// BLS messages must not carry their own signatures.
+		unaggregated := &Block{Messages: msgs, BLSAggregateSig: agg}
+		assert.Error(t, unaggregated.VerifyBLSAggregate())
+
+		// An aggregate without BLS messages is rejected.
+		spurious := &Block{Messages: blockMsgs[1:2], BLSAggregateSig: agg}
+		assert.Error(t, spurious.VerifyBLSAggregate())
+
+		noBLS := &Block{Messages: blockMsgs[1:2]}
+		assert.NoError(t, noBLS.VerifyBLSAggregate())
+	})
+}
@@ -80,6 +80,11 @@ func NewBytesAmountFromBytes(buf []byte) *BytesAmount {
 	return ba
 }
 
+// NewBytesAmountFromBigInt allocates and returns a new BytesAmount set to x.
+func NewBytesAmountFromBigInt(x *big.Int) *BytesAmount {
+	return &BytesAmount{val: big.NewInt(0).Set(x)}
+}
+
 // NewBytesAmountFromString allocates a new BytesAmount set to the value of s,
 // interpreted in the given base, and returns it and a boolean indicating success.
 func NewBytesAmountFromString(s string, base int) (*BytesAmount, bool) {
@@ -175,6 +180,12 @@ func (z *BytesAmount) String() string {
 	return z.val.String()
 }
 
+// BigInt returns a copy of the value of z as a big.Int.
+func (z *BytesAmount) BigInt() *big.Int {
+	ensureBytesAmounts(&z)
+	return big.NewInt(0).Set(z.val)
+}
+
 // Uint64 returns the uint64 representation of x. If x cannot be represented in a uint64, the result is undefined.
 func (z *BytesAmount) Uint64() uint64 {
 	return z.val.Uint64()
@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/json"
+	"math/big"
 	"math/rand"
 	"testing"
 	"time"
@@ -30,6 +31,12 @@ func TestBytesAmountCreation(t *testing.T) {
 
 	_, ok = NewBytesAmountFromString("asdf", 10)
 	assert.False(t, ok)
+
+	// Values beyond uint64 are kept whole.
+	x := new(big.Int).Lsh(big.NewInt(1), 70)
+	d := NewBytesAmountFromBigInt(x)
+	assert.Equal(t, "1180591620717411303424", d.String())
+	assert.Equal(t, x, d.BigInt())
 }
 
 func TestZeroBytes(t *testing.T) {
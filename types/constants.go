@@ -40,6 +40,30 @@ var BootstrapMinerActorCodeObj ipld.Node
 // BootstrapMinerActorCodeCid is the cid of the above object
 var BootstrapMinerActorCodeCid cid.Cid
 
+// MultisigActorCodeObj is the code representation of the builtin multisig wallet actor.
+var MultisigActorCodeObj ipld.Node
+
+// MultisigActorCodeCid is the cid of the above object
+var MultisigActorCodeCid cid.Cid
+
+// MultisigFactoryActorCodeObj is the code representation of the builtin multisig factory actor.
+var MultisigFactoryActorCodeObj ipld.Node
+
+// MultisigFactoryActorCodeCid is the cid of the above object
+var MultisigFactoryActorCodeCid cid.Cid
+
+// RewardActorCodeObj is the code representation of the builtin reward actor.
+var RewardActorCodeObj ipld.Node
+
+// RewardActorCodeCid is the cid of the above object
+var RewardActorCodeCid cid.Cid
+
+// CronActorCodeObj is the code representation of the builtin cron actor.
+var CronActorCodeObj ipld.Node
+
+// CronActorCodeCid is the cid of the above object
+var CronActorCodeCid cid.Cid
+
 // ActorCodeCidTypeNames maps Actor codeCid's to the name of the associated Actor type.
 var ActorCodeCidTypeNames = make(map[cid.Cid]string)
 
@@ -54,6 +78,14 @@ func init() {
 	MinerActorCodeCid = MinerActorCodeObj.Cid()
 	BootstrapMinerActorCodeObj = dag.NewRawNode([]byte("bootstrapmineractor"))
 	BootstrapMinerActorCodeCid = BootstrapMinerActorCodeObj.Cid()
+	MultisigActorCodeObj = dag.NewRawNode([]byte("multisigactor"))
+	MultisigActorCodeCid = MultisigActorCodeObj.Cid()
+	MultisigFactoryActorCodeObj = dag.NewRawNode([]byte("multisigfactory"))
+	MultisigFactoryActorCodeCid = MultisigFactoryActorCodeObj.Cid()
+	RewardActorCodeObj = dag.NewRawNode([]byte("rewardactor"))
+	RewardActorCodeCid = RewardActorCodeObj.Cid()
+	CronActorCodeObj = dag.NewRawNode([]byte("cronactor"))
+	CronActorCodeCid = CronActorCodeObj.Cid()
 
 	// New Actors need to be added here.
 	// TODO: Make this work with reflection -- but note that nasty import cycles lie on that path.
@@ -63,6 +95,10 @@ func init() {
 	ActorCodeCidTypeNames[PaymentBrokerActorCodeCid] = "PaymentBrokerActor"
 	ActorCodeCidTypeNames[MinerActorCodeCid] = "MinerActor"
 	ActorCodeCidTypeNames[BootstrapMinerActorCodeCid] = "MinerActor"
+	ActorCodeCidTypeNames[MultisigActorCodeCid] = "MultisigActor"
+	ActorCodeCidTypeNames[MultisigFactoryActorCodeCid] = "MultisigFactoryActor"
+	ActorCodeCidTypeNames[RewardActorCodeCid] = "RewardActor"
+	ActorCodeCidTypeNames[CronActorCodeCid] = "CronActor"
 }
 
 // ActorCodeTypeName returns the (string) name of the Go type of the actor with cid, code.
@@ -6,6 +6,7 @@ import (
 	cbor "github.com/ipfs/go-ipld-cbor"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 )
 
@@ -58,11 +59,20 @@ func (ki *KeyInfo) Equals(other *KeyInfo) bool {
 
 // Address returns the address for this keyinfo
 func (ki *KeyInfo) Address() (address.Address, error) {
-	// TODO: Use the address type we are running on from the config.
+	if ki.Curve == BLS {
+		return address.NewBLSAddress(ki.PublicKey())
+	}
 	return address.NewSecp256k1Address(ki.PublicKey())
 }
 
-// PublicKey returns the public key part as uncompressed bytes.
+// PublicKey returns the public key part as uncompressed bytes, or compressed
+// for a BLS key.
 func (ki *KeyInfo) PublicKey() []byte {
+	if ki.Curve == BLS {
+		var sk bls.PrivateKey
+		copy(sk[:], ki.PrivateKey)
+		pk := bls.PrivateKeyPublicKey(sk)
+		return pk[:]
+	}
 	return crypto.PublicKey(ki.PrivateKey)
 }
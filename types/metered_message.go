@@ -9,9 +9,19 @@ import (
 // GasUnits represents number of units of gas consumed
 type GasUnits = Uint64
 
-// BlockGasLimit is the maximum amount of gas that can be used to execute messages in a single block
+// BlockGasLimit is the maximum amount of gas that can be used to execute messages in a single block.
+// The gas limits of a block's messages must add up to no more than it.
 var BlockGasLimit = NewGasUnits(10000000)
 
+// TotalGasLimit returns the sum of the gas limits of msgs.
+func TotalGasLimit(msgs []*SignedMessage) GasUnits {
+	total := NewGasUnits(0)
+	for _, msg := range msgs {
+		total += msg.GasLimit
+	}
+	return total
+}
+
 func init() {
 	cbor.RegisterCborType(MeteredMessage{})
 }
@@ -54,6 +64,16 @@ func NewGasUnits(cost uint64) GasUnits {
 	return Uint64(cost)
 }
 
+// MaxCost returns the most the message can cost its sender: its value plus
+// the gas charge if it uses all of its gas limit.
+func (msg *MeteredMessage) MaxCost() *AttoFIL {
+	maxGasCharge := msg.GasPrice.MulBigInt(big.NewInt(int64(msg.GasLimit)))
+	if msg.Value == nil {
+		return maxGasCharge
+	}
+	return maxGasCharge.Add(msg.Value)
+}
+
 // Equals tests whether two metered messages are equal
 func (msg *MeteredMessage) Equals(other *MeteredMessage) bool {
 	return msg.Message.Equals(&other.Message) &&
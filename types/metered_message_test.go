@@ -1,6 +1,7 @@
 package types
 
 import (
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -11,6 +12,20 @@ import (
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 )
 
+func TestMeteredMessageMaxCost(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := address.NewForTestGetter()
+	msg := NewMessage(addrGetter(), addrGetter(), 0, NewAttoFILFromFIL(2), "method", nil)
+
+	metered := NewMeteredMessage(*msg, NewGasPrice(3), NewGasUnits(100))
+	assert.True(t, NewAttoFILFromFIL(2).Add(NewAttoFIL(big.NewInt(300))).Equal(metered.MaxCost()))
+
+	msg.Value = nil
+	metered = NewMeteredMessage(*msg, NewGasPrice(3), NewGasUnits(100))
+	assert.True(t, NewAttoFIL(big.NewInt(300)).Equal(metered.MaxCost()))
+}
+
 func TestMeteredMessageMessage(t *testing.T) {
 	tf.UnitTest(t)
 
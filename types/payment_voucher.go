@@ -10,6 +10,7 @@ import (
 func init() {
 	cbor.RegisterCborType(Predicate{})
 	cbor.RegisterCborType(PaymentVoucher{})
+	cbor.RegisterCborType(LaneMerge{})
 }
 
 // Predicate is an optional message that is sent to another actor and must return true for the voucher to be valid.
@@ -45,10 +46,31 @@ type PaymentVoucher struct {
 	// Condition defines a optional message that will be called and must return true before this voucher can be redeemed.
 	Condition *Predicate `json:"condition"`
 
+	// Lane is the lane of the channel this voucher pays in.  Lanes are
+	// redeemed independently, so that a payer can make payments for several
+	// transfers over a single channel.
+	Lane uint64 `json:"lane,omitempty"`
+
+	// Nonce orders the vouchers of a lane: a voucher can only be redeemed if
+	// its nonce is higher than that of the last voucher redeemed in its lane.
+	// Vouchers with a zero nonce are single lane vouchers, redeemed against
+	// the whole channel.
+	Nonce uint64 `json:"nonce,omitempty"`
+
+	// Merges are other lanes whose redeemed amounts this voucher's amount
+	// includes, which closes them up to the given nonces.
+	Merges []LaneMerge `json:"merges,omitempty"`
+
 	// Signature is the signature of all the data in this voucher.
 	Signature Signature `json:"signature"`
 }
 
+// LaneMerge designates a lane merged into another by a voucher.
+type LaneMerge struct {
+	Lane  uint64 `json:"lane"`
+	Nonce uint64 `json:"nonce"`
+}
+
 // DecodeVoucher creates a *PaymentVoucher from a base58, Cbor-encoded one
 func DecodeVoucher(voucherRaw string) (*PaymentVoucher, error) {
 	_, cborVoucher, err := multibase.Decode(voucherRaw)
@@ -15,6 +15,9 @@ type Signature []byte
 // IsValidSignature cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key belonging to `addr`.
 func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
+	if isBLS(addr) {
+		return IsValidBLSAggregate([][]byte{data}, []address.Address{addr}, sig)
+	}
 	maybePk, err := wutil.Ecrecover(data, sig)
 	if err != nil {
 		// Any error returned from Ecrecover means this signature is not valid.
@@ -19,6 +19,10 @@ var (
 	ErrMessageUnsigned = errors.New("message does not contain a signature")
 )
 
+// MessageSizeLimit is the maximum size in bytes of a serialized signed
+// message.
+const MessageSizeLimit = 32 << 10
+
 func init() {
 	cbor.RegisterCborType(SignedMessage{})
 }
@@ -63,15 +67,32 @@ func (smsg *SignedMessage) Marshal() ([]byte, error) {
 	return cbor.DumpObject(smsg)
 }
 
-// Cid returns the canonical CID for the SignedMessage.
+// Size returns the size in bytes of the serialized SignedMessage.
+func (smsg *SignedMessage) Size() (int, error) {
+	bs, err := smsg.Marshal()
+	if err != nil {
+		return 0, err
+	}
+	return len(bs), nil
+}
+
+// Cid returns the canonical CID for the SignedMessage.  The CID of a message
+// from a BLS address doesn't cover its signature, which blocks replace with an
+// aggregate, so that the message has the same CID in the pool and on chain.
 // TODO: can we avoid returning an error?
 func (smsg *SignedMessage) Cid() (cid.Cid, error) {
-	obj, err := cbor.WrapObject(smsg, DefaultHashFunction, -1)
+	obj := smsg
+	if isBLS(smsg.From) && len(smsg.Signature) > 0 {
+		unsigned := *smsg
+		unsigned.Signature = nil
+		obj = &unsigned
+	}
+	nd, err := cbor.WrapObject(obj, DefaultHashFunction, -1)
 	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to marshal to cbor")
 	}
 
-	return obj.Cid(), nil
+	return nd.Cid(), nil
 }
 
 // RecoverAddress returns the address derived from the signature and message encapsulated in `SignedMessage`
@@ -118,6 +118,29 @@ func (s SortedCidSet) Equals(s2 SortedCidSet) bool {
 	return true
 }
 
+// Compare returns -1, 0 or 1 as s sorts before, the same as or after s2.
+// Sets are compared cid by cid in their order, and a set sorts before the
+// sets it is a prefix of.  It gives tipsets an order independent of the order
+// their blocks were received in.
+func (s SortedCidSet) Compare(s2 SortedCidSet) int {
+	for i := 0; i < len(s.s) && i < len(s2.s); i++ {
+		if cidLess(s.s[i], s2.s[i]) {
+			return -1
+		}
+		if cidLess(s2.s[i], s.s[i]) {
+			return 1
+		}
+	}
+	switch {
+	case len(s.s) < len(s2.s):
+		return -1
+	case len(s.s) > len(s2.s):
+		return 1
+	default:
+		return 0
+	}
+}
+
 // Contains checks if s2 is a sub-tipset of s
 func (s *SortedCidSet) Contains(s2 *SortedCidSet) bool {
 	for it := s2.Iter(); !it.Complete(); it.Next() {
@@ -108,3 +108,24 @@ func TestSortedCidSetJSONRoundtrip(t *testing.T) {
 	assert.Equal(t, 3, act.Len())
 	assert.True(t, act.Equals(exp))
 }
+
+func TestSortedCidSetCompare(t *testing.T) {
+	tf.UnitTest(t)
+
+	c1, _ := cid.Parse("zDPWYqFD4b5HLFuPfhkjJJkfvm4r8KLi1V9e2ahJX6Ab16Ay24pJ")
+	c2, _ := cid.Parse("zDPWYqFD4b5HLFuPfhkjJJkfvm4r8KLi1V9e2ahJX6Ab16Ay24pK")
+	c3, _ := cid.Parse("zDPWYqFD4b5HLFuPfhkjJJkfvm4r8KLi1V9e2ahJX6Ab16Ay24pL")
+
+	s12 := NewSortedCidSet(c2, c1)
+	s13 := NewSortedCidSet(c1, c3)
+	s1 := NewSortedCidSet(c1)
+
+	assert.Equal(t, 0, s12.Compare(NewSortedCidSet(c1, c2)))
+	assert.Equal(t, -1, s12.Compare(s13))
+	assert.Equal(t, 1, s13.Compare(s12))
+
+	// A prefix sorts first.
+	assert.Equal(t, -1, s1.Compare(s12))
+	assert.Equal(t, 1, s12.Compare(s1))
+	assert.Equal(t, -1, SortedCidSet{}.Compare(s1))
+}
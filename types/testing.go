@@ -46,7 +46,7 @@ func NewMockSigner(kis []KeyInfo) MockSigner {
 	for _, k := range kis {
 		// extract public key
 		pub := k.PublicKey()
-		newAddr, err := address.NewSecp256k1Address(pub)
+		newAddr, err := k.Address()
 		if err != nil {
 			panic(err)
 		}
@@ -71,6 +71,9 @@ func (ms MockSigner) SignBytes(data []byte, addr address.Address) (Signature, er
 	if !ok {
 		panic("unknown address")
 	}
+	if ki.Curve == BLS {
+		return SignBLS(ki.Key(), data)
+	}
 
 	hash := blake2b.Sum256(data)
 	return crypto.Sign(ki.Key(), hash[:])
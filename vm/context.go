@@ -27,7 +27,15 @@ type Context struct {
 	storageMap  StorageMap
 	gasTracker  *GasTracker
 	blockHeight *types.BlockHeight
-	ancestors   []types.TipSet
+	randomness  sampling.Randomness
+	tracer      *Tracer
+
+	// depth is the number of sends the context is nested in, 0 for the
+	// message being applied.
+	depth int
+	// executing are the addresses of the actors whose methods are executing
+	// in this context and the contexts it is nested in.
+	executing []address.Address
 
 	deps *deps // Inject external dependencies so we can unit test robustly.
 }
@@ -44,10 +52,28 @@ type NewContextParams struct {
 	GasTracker  *GasTracker
 	BlockHeight *types.BlockHeight
 	Ancestors   []types.TipSet
+	// Randomness is optional. When set, randomness is drawn from it instead
+	// of being sampled from Ancestors.
+	Randomness sampling.Randomness
+	// Tracer is optional. When set, sends made in this context and its
+	// descendants are recorded.
+	Tracer *Tracer
 }
 
+// MaxCallDepth is the maximum number of sends a message may nest.  It keeps
+// a graph of actors calling each other from exhausting the stack.
+const MaxCallDepth = 64
+
 // NewVMContext returns an initialized context.
 func NewVMContext(params NewContextParams) *Context {
+	randomness := params.Randomness
+	if randomness == nil {
+		randomness = sampling.NewChainRandomness(params.Ancestors)
+	}
+	var executing []address.Address
+	if params.Message != nil && params.Message.Method != "" {
+		executing = []address.Address{params.Message.To}
+	}
 	return &Context{
 		from:        params.From,
 		to:          params.To,
@@ -56,7 +82,9 @@ func NewVMContext(params NewContextParams) *Context {
 		storageMap:  params.StorageMap,
 		gasTracker:  params.GasTracker,
 		blockHeight: params.BlockHeight,
-		ancestors:   params.Ancestors,
+		randomness:  randomness,
+		tracer:      params.Tracer,
+		executing:   executing,
 		deps:        makeDeps(params.State),
 	}
 }
@@ -64,8 +92,10 @@ func NewVMContext(params NewContextParams) *Context {
 var _ exec.VMContext = (*Context)(nil)
 
 // Storage returns an implementation of the storage module for this context.
+// Reads and writes are charged gas at the storage prices in force at the
+// context's block height.
 func (ctx *Context) Storage() exec.Storage {
-	return ctx.storageMap.NewStorage(ctx.message.To, ctx.to)
+	return meter(ctx.storageMap.NewStorage(ctx.message.To, ctx.to), ctx.gasTracker, ctx.blockHeight)
 }
 
 // Message retrieves the message associated with this context.
@@ -128,8 +158,17 @@ func (ctx *Context) IsFromAccountActor() bool {
 	return account.IsAccount(ctx.from)
 }
 
+// Balance returns the balance of the actor the message is sent to, the value
+// of the message included.
+func (ctx *Context) Balance() *types.AttoFIL {
+	return ctx.to.Balance
+}
+
 // Send sends a message to another actor.
 // This method assumes to be called from inside the `to` actor.
+// Sends nested deeper than MaxCallDepth and calls to the methods of actors
+// already executing one for the message are reverted.  Transfers of value
+// without a method may be sent to any actor.
 func (ctx *Context) Send(to address.Address, method string, value *types.AttoFIL, params []interface{}) ([][]byte, uint8, error) {
 	deps := ctx.deps
 
@@ -152,6 +191,12 @@ func (ctx *Context) Send(to address.Address, method string, value *types.AttoFIL
 		// TODO: handle this
 		return nil, 1, errors.NewFaultErrorf("unhandled: sending to self (%s)", msg.From)
 	}
+	if ctx.depth >= MaxCallDepth {
+		return nil, errors.ErrCallDepthExceeded, errors.Errors[errors.ErrCallDepthExceeded]
+	}
+	if method != "" && ctx.isExecuting(to) {
+		return nil, errors.ErrReentrantCall, errors.Errors[errors.ErrReentrantCall]
+	}
 
 	toActor, err := deps.GetOrCreateActor(context.TODO(), msg.To, func() (*actor.Actor, error) {
 		return &actor.Actor{}, nil
@@ -168,9 +213,12 @@ func (ctx *Context) Send(to address.Address, method string, value *types.AttoFIL
 		StorageMap:  ctx.storageMap,
 		GasTracker:  ctx.gasTracker,
 		BlockHeight: ctx.blockHeight,
-		Ancestors:   ctx.ancestors,
+		Randomness:  ctx.randomness,
+		Tracer:      ctx.tracer,
 	}
 	innerCtx := NewVMContext(innerParams)
+	innerCtx.depth = ctx.depth + 1
+	innerCtx.executing = append(append([]address.Address{}, ctx.executing...), innerCtx.executing...)
 
 	out, ret, err := deps.Send(context.Background(), innerCtx)
 	if err != nil {
@@ -180,6 +228,17 @@ func (ctx *Context) Send(to address.Address, method string, value *types.AttoFIL
 	return out, ret, nil
 }
 
+// isExecuting returns true if a method of the actor at addr is executing in
+// this context or one it is nested in.
+func (ctx *Context) isExecuting(addr address.Address) bool {
+	for _, a := range ctx.executing {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
 // AddressForNewActor creates computes the address for a new actor in the same
 // way that ethereum does.  Note that this will not work if we allow the
 // creation of multiple contracts in a given invocation (nonce will remain the
@@ -221,7 +280,7 @@ func (ctx *Context) CreateNewActor(addr address.Address, code cid.Cid, initializ
 	// make this the right 'type' of actor
 	newActor.Code = code
 
-	childStorage := ctx.storageMap.NewStorage(addr, newActor)
+	childStorage := meter(ctx.storageMap.NewStorage(addr, newActor), ctx.gasTracker, ctx.blockHeight)
 	execActor, err := ctx.state.GetBuiltinActorCode(code)
 	if err != nil {
 		return errors.NewRevertErrorf("attempt to create executable actor from non-existent code %s", code.String())
@@ -238,10 +297,11 @@ func (ctx *Context) CreateNewActor(addr address.Address, code cid.Cid, initializ
 	return nil
 }
 
-// SampleChainRandomness samples randomness from a block's ancestors at the
-// given height.
+// SampleChainRandomness draws the randomness of the given height for PoSt
+// challenges, sampled from a block's ancestors unless the context was given
+// another source.
 func (ctx *Context) SampleChainRandomness(sampleHeight *types.BlockHeight) ([]byte, error) {
-	return sampling.SampleChainRandomness(sampleHeight, ctx.ancestors)
+	return ctx.randomness.Randomness(context.TODO(), sampleHeight, sampling.PoStChallengeTag)
 }
 
 // Dependency injection setup.
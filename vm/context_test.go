@@ -239,6 +239,79 @@ func TestVMContextSendFailures(t *testing.T) {
 		assert.Equal(t, []string{"ToValues", "EncodeValues", "GetOrCreateActor", "Send"}, calls)
 	})
 
+	t.Run("reverts sends nested deeper than the maximum call depth", func(t *testing.T) {
+		sends := 0
+		deps := &deps{
+			EncodeValues: func(_ []*abi.Value) ([]byte, error) {
+				return nil, nil
+			},
+			GetOrCreateActor: func(_ context.Context, _ address.Address, f func() (*actor.Actor, error)) (*actor.Actor, error) {
+				return f()
+			},
+			ToValues: func(_ []interface{}) ([]*abi.Value, error) {
+				return nil, nil
+			},
+		}
+		deps.Send = func(ctx context.Context, vmCtx *Context) ([][]byte, uint8, error) {
+			sends++
+			vmCtx.deps = deps
+			return vmCtx.Send(newAddress(), "foo", nil, []interface{}{})
+		}
+
+		ctx := NewVMContext(vmCtxParams)
+		ctx.deps = deps
+
+		_, code, err := ctx.Send(newAddress(), "foo", nil, []interface{}{})
+
+		assert.Equal(t, errors.ErrCallDepthExceeded, int(code))
+		assert.True(t, errors.ShouldRevert(err))
+		assert.Equal(t, MaxCallDepth, sends)
+	})
+
+	t.Run("reverts calls into an executing actor", func(t *testing.T) {
+		executing := newAddress()
+		other := newAddress()
+
+		var innerCode uint8
+		var innerErr error
+		deps := &deps{
+			EncodeValues: func(_ []*abi.Value) ([]byte, error) {
+				return nil, nil
+			},
+			GetOrCreateActor: func(_ context.Context, _ address.Address, f func() (*actor.Actor, error)) (*actor.Actor, error) {
+				return f()
+			},
+			ToValues: func(_ []interface{}) ([]*abi.Value, error) {
+				return nil, nil
+			},
+		}
+		deps.Send = func(ctx context.Context, vmCtx *Context) ([][]byte, uint8, error) {
+			vmCtx.deps = deps
+			deps.Send = func(ctx context.Context, vmCtx *Context) ([][]byte, uint8, error) {
+				return nil, 0, nil
+			}
+
+			// transfers without a method are allowed
+			_, code, err := vmCtx.Send(executing, "", nil, []interface{}{})
+			require.NoError(t, err)
+			require.Equal(t, 0, int(code))
+
+			_, innerCode, innerErr = vmCtx.Send(executing, "foo", nil, []interface{}{})
+			return nil, 0, nil
+		}
+
+		params := vmCtxParams
+		params.Message = types.NewMessage(newAddress(), executing, 0, nil, "foo", nil)
+		ctx := NewVMContext(params)
+		ctx.deps = deps
+
+		_, _, err := ctx.Send(other, "bar", nil, []interface{}{})
+		require.NoError(t, err)
+
+		assert.Equal(t, errors.ErrReentrantCall, int(innerCode))
+		assert.True(t, errors.ShouldRevert(innerErr))
+	})
+
 	t.Run("creates new actor from cid", func(t *testing.T) {
 		ctx := context.Background()
 		vmctx := NewVMContext(vmCtxParams)
@@ -366,4 +439,17 @@ func TestVMContextRand(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, []byte(strconv.Itoa(0)), r)
 	})
+	t.Run("draws from the randomness given instead of ancestors", func(t *testing.T) {
+		randomness := &sampling.FakeRandomness{Seed: []byte("seed")}
+		ctx := NewVMContext(NewContextParams{
+			Randomness: randomness,
+		})
+
+		// No chain is needed, even for heights far from genesis.
+		r, err := ctx.SampleChainRandomness(types.NewBlockHeight(uint64(1000)))
+		require.NoError(t, err)
+		expected, err := randomness.Randomness(context.Background(), types.NewBlockHeight(uint64(1000)), sampling.PoStChallengeTag)
+		require.NoError(t, err)
+		assert.Equal(t, expected, r)
+	})
 }
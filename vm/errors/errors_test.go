@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -91,3 +92,26 @@ func TestApplyErrorTemporary(t *testing.T) {
 		assert.NotContains(t, errors.Cause(e).Error(), "wrapper")
 	})
 }
+
+func TestVMExitCodeToError(t *testing.T) {
+	tf.UnitTest(t)
+
+	notFound := NewCodedRevertError(exitcode.NotFound, "channel not found")
+	actorErrors := map[uint8]error{
+		exitcode.NotFound: notFound,
+		33:                NewCodedRevertError(33, "specific"),
+	}
+
+	t.Run("prefers the actor's description of canonical codes", func(t *testing.T) {
+		assert.Equal(t, notFound, VMExitCodeToError(exitcode.NotFound, actorErrors))
+	})
+	t.Run("falls back to the canonical errors", func(t *testing.T) {
+		assert.Equal(t, Errors[exitcode.Forbidden], VMExitCodeToError(exitcode.Forbidden, actorErrors))
+	})
+	t.Run("finds codes specific to the actor", func(t *testing.T) {
+		assert.Equal(t, uint8(33), CodeError(VMExitCodeToError(33, actorErrors)))
+	})
+	t.Run("unknown codes keep their code", func(t *testing.T) {
+		assert.Equal(t, uint8(34), CodeError(VMExitCodeToError(34, actorErrors)))
+	})
+}
@@ -1,48 +1,62 @@
 package errors
 
+import (
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
+)
+
 // ReservedErrors is the highest error code that may not be used by actors
+// for errors specific to them.  Codes up to it are the canonical codes
+// defined in package exitcode.
 const ReservedErrors = 32
 
 const (
-	_ = iota
-	_
 	// ErrCannotTransferNegativeValue is the error code for attempting to transfer a negative number
-	ErrCannotTransferNegativeValue
+	ErrCannotTransferNegativeValue = exitcode.NegativeValue
 	// ErrInsufficientBalance is the error code for attempting to send more than you have
-	ErrInsufficientBalance
+	ErrInsufficientBalance = exitcode.InsufficientFunds
 	// ErrMissingExport is the error code for a message that calls a non-existent method
-	ErrMissingExport
+	ErrMissingExport = exitcode.MethodNotFound
 	// ErrNoActorCode indicates the recipient's code could not be loaded.
-	ErrNoActorCode
+	ErrNoActorCode = exitcode.ActorCodeNotFound
+	// ErrCallDepthExceeded indicates a send nested deeper than the VM allows.
+	ErrCallDepthExceeded = exitcode.CallDepthExceeded
+	// ErrReentrantCall indicates a call into an actor already executing a method.
+	ErrReentrantCall = exitcode.ReentrantCall
 )
 
 // Errors is a map from exit codes to errors.
 // Most errors should live in the actors that throw them. However some
 // errors will be pervasive so we define them centrally here.
 var Errors = map[uint8]error{
+	exitcode.Unspecified:           NewCodedRevertError(exitcode.Unspecified, "unspecified error"),
 	ErrCannotTransferNegativeValue: NewCodedRevertError(ErrCannotTransferNegativeValue, "cannot transfer negative values"),
 	ErrInsufficientBalance:         NewCodedRevertError(ErrInsufficientBalance, "not enough balance"),
-	ErrMissingExport:               NewCodedRevertError(ErrInsufficientBalance, "actor does not export method"),
+	ErrMissingExport:               NewCodedRevertError(ErrMissingExport, "actor does not export method"),
 	ErrNoActorCode:                 NewCodedRevertError(ErrNoActorCode, "actor code not found"),
+	exitcode.IllegalArgument:       NewCodedRevertError(exitcode.IllegalArgument, "illegal argument"),
+	exitcode.Forbidden:             NewCodedRevertError(exitcode.Forbidden, "caller not allowed to call the method"),
+	exitcode.NotFound:              NewCodedRevertError(exitcode.NotFound, "not found"),
+	exitcode.AlreadyExists:         NewCodedRevertError(exitcode.AlreadyExists, "already exists"),
+	exitcode.IllegalState:          NewCodedRevertError(exitcode.IllegalState, "illegal state"),
+	exitcode.SerializationError:    NewCodedRevertError(exitcode.SerializationError, "serialization error"),
+	exitcode.OutOfGas:              NewCodedRevertError(exitcode.OutOfGas, "out of gas"),
+	ErrCallDepthExceeded:           NewCodedRevertError(ErrCallDepthExceeded, "maximum call depth exceeded"),
+	ErrReentrantCall:               NewCodedRevertError(ErrReentrantCall, "reentrant call into an executing actor"),
 }
 
-// VMExitCodeToError tries to locate an error in either the VM errors or the provide error map
-// If it fails to locate the error it will return an unknown error
+// VMExitCodeToError tries to locate an error in either the provided error map
+// or the VM errors.  Actors may describe canonical codes more precisely in
+// their error map, so it is looked up first.  If it fails to locate the error
+// it will return an unknown error
 func VMExitCodeToError(exitCode uint8, actorErrors map[uint8]error) error {
-	var (
-		err   error
-		found bool
-	)
-
-	if exitCode <= ReservedErrors {
-		err, found = Errors[exitCode]
-	} else {
-		err, found = actorErrors[exitCode]
-	}
-
-	if found {
+	if err, found := actorErrors[exitCode]; found {
 		return err
 	}
+	if exitCode <= ReservedErrors {
+		if err, found := Errors[exitCode]; found {
+			return err
+		}
+	}
 
 	return NewCodedRevertError(exitCode, "Error executing command. Consult the logs.")
 }
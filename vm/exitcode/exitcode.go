@@ -0,0 +1,76 @@
+// Package exitcode defines the canonical exit codes of messages.
+//
+// Codes up to vm/errors.ReservedErrors have the same meaning for every actor,
+// so that clients may react to a failure without knowing the actor that
+// raised it.  Actors return them for the generic failures they share, and
+// codes above ReservedErrors, listed in their Errors maps, for the failures
+// specific to them.
+//
+// Exit codes are recorded in the receipts of blocks, so changing the code a
+// failure returns is a consensus-breaking change: it must bump
+// hello.ProtocolVersion, or be activated at a height by a scheduled fork.
+package exitcode
+
+const (
+	// Ok indicates a message applied successfully.
+	Ok = 0
+	// Unspecified indicates a failure not described by a more specific code.
+	Unspecified = 1
+	// NegativeValue indicates an attempt to transfer a negative value.
+	NegativeValue = 2
+	// InsufficientFunds indicates a balance or an amount of funds too low for
+	// the operation.
+	InsufficientFunds = 3
+	// MethodNotFound indicates a message calling a method its recipient does
+	// not export.
+	MethodNotFound = 4
+	// ActorCodeNotFound indicates the code of the recipient could not be
+	// loaded.
+	ActorCodeNotFound = 5
+	// IllegalArgument indicates a parameter outside of its valid range or
+	// otherwise malformed.
+	IllegalArgument = 6
+	// Forbidden indicates a caller not allowed to call the method.
+	Forbidden = 7
+	// NotFound indicates a reference to something that does not exist.
+	NotFound = 8
+	// AlreadyExists indicates an attempt to create something that already
+	// exists.
+	AlreadyExists = 9
+	// IllegalState indicates an operation not allowed in the current state of
+	// the actor.
+	IllegalState = 10
+	// SerializationError indicates data that could not be encoded or decoded.
+	SerializationError = 11
+	// OutOfGas indicates the message ran out of gas.
+	OutOfGas = 12
+	// CallDepthExceeded indicates a send nested deeper than the VM allows.
+	CallDepthExceeded = 13
+	// ReentrantCall indicates a call into an actor whose method is already
+	// executing for the same message.
+	ReentrantCall = 14
+)
+
+var names = map[uint8]string{
+	Ok:                 "ok",
+	Unspecified:        "unspecified",
+	NegativeValue:      "negative value",
+	InsufficientFunds:  "insufficient funds",
+	MethodNotFound:     "method not found",
+	ActorCodeNotFound:  "actor code not found",
+	IllegalArgument:    "illegal argument",
+	Forbidden:          "forbidden",
+	NotFound:           "not found",
+	AlreadyExists:      "already exists",
+	IllegalState:       "illegal state",
+	SerializationError: "serialization error",
+	OutOfGas:           "out of gas",
+	CallDepthExceeded:  "call depth exceeded",
+	ReentrantCall:      "reentrant call",
+}
+
+// Name returns the name of a canonical exit code, or the empty string if code
+// is specific to an actor.
+func Name(code uint8) string {
+	return names[code]
+}
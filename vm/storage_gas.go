@@ -0,0 +1,184 @@
+package vm
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// StoragePrices is a version of the gas prices of the reads and writes actors
+// make to their storage.  Each operation costs a base price plus a price per
+// byte of the IPLD object read or written, so that the cost of an actor's
+// state grows with its size.
+type StoragePrices struct {
+	// Name identifies the prices.
+	Name         string
+	ReadBase     types.GasUnits
+	ReadPerByte  types.GasUnits
+	WriteBase    types.GasUnits
+	WritePerByte types.GasUnits
+}
+
+// Free returns true if storage operations cost nothing under p.
+func (p *StoragePrices) Free() bool {
+	return p.ReadBase == 0 && p.ReadPerByte == 0 && p.WriteBase == 0 && p.WritePerByte == 0
+}
+
+// ReadCost returns the gas charged for reading an object of size bytes.
+func (p *StoragePrices) ReadCost(size int) types.GasUnits {
+	return p.ReadBase + p.ReadPerByte*types.GasUnits(size)
+}
+
+// WriteCost returns the gas charged for writing an object of size bytes.
+func (p *StoragePrices) WriteCost(size int) types.GasUnits {
+	return p.WriteBase + p.WritePerByte*types.GasUnits(size)
+}
+
+// StoragePricesV0 are the storage prices of the genesis rules, under which
+// storage operations are free and only the flat costs of methods are
+// charged.
+var StoragePricesV0 = &StoragePrices{Name: "storage-v0"}
+
+// StoragePricesV1 charge for every read and write of actor storage.  Writes
+// are priced above reads since they grow the state every node keeps.
+var StoragePricesV1 = &StoragePrices{
+	Name:         "storage-v1",
+	ReadBase:     10,
+	ReadPerByte:  1,
+	WriteBase:    50,
+	WritePerByte: 4,
+}
+
+var (
+	storagePricesLk sync.Mutex
+	storagePrices   = map[string]*StoragePrices{
+		StoragePricesV0.Name: StoragePricesV0,
+		StoragePricesV1.Name: StoragePricesV1,
+	}
+)
+
+// RegisterStoragePrices makes storage prices available to activate by name.
+// It is intended to be called from init functions.
+func RegisterStoragePrices(p *StoragePrices) error {
+	if p.Name == "" {
+		return errors.New("storage prices must have a name")
+	}
+	storagePricesLk.Lock()
+	defer storagePricesLk.Unlock()
+	if _, ok := storagePrices[p.Name]; ok {
+		return errors.Errorf("storage prices %s already registered", p.Name)
+	}
+	storagePrices[p.Name] = p
+	return nil
+}
+
+// LookupStoragePrices returns the registered storage prices with the given
+// name.
+func LookupStoragePrices(name string) (*StoragePrices, bool) {
+	storagePricesLk.Lock()
+	defer storagePricesLk.Unlock()
+	p, ok := storagePrices[name]
+	return p, ok
+}
+
+type storagePricesActivation struct {
+	height uint64
+	prices *StoragePrices
+}
+
+// StorageGasSchedule selects the storage prices in force at each height, so
+// that every node charges the same gas for a message whatever version of the
+// software it runs.
+type StorageGasSchedule struct {
+	lk sync.RWMutex
+	// activations is sorted by ascending height and starts at height 0.
+	activations []storagePricesActivation
+}
+
+// NewStorageGasSchedule returns a schedule in which genesis is in force from
+// genesis on.
+func NewStorageGasSchedule(genesis *StoragePrices) *StorageGasSchedule {
+	return &StorageGasSchedule{activations: []storagePricesActivation{{0, genesis}}}
+}
+
+// StorageGas is the storage gas schedule of the network.
+var StorageGas = NewStorageGasSchedule(StoragePricesV0)
+
+// Activate puts the registered storage prices with the given name in force
+// from height on.  Activations must be made in ascending order of height.
+func (s *StorageGasSchedule) Activate(height uint64, name string) error {
+	p, ok := LookupStoragePrices(name)
+	if !ok {
+		return errors.Errorf("unknown storage prices %q", name)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if last := s.activations[len(s.activations)-1]; height <= last.height {
+		return errors.Errorf("storage prices %s must activate above height %d, where %s activates", name, last.height, last.prices.Name)
+	}
+	s.activations = append(s.activations, storagePricesActivation{height, p})
+	return nil
+}
+
+// At returns the storage prices in force at height.
+func (s *StorageGasSchedule) At(height uint64) *StoragePrices {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	i := sort.Search(len(s.activations), func(i int) bool {
+		return s.activations[i].height > height
+	})
+	return s.activations[i-1].prices
+}
+
+// meteredStorage charges gas for the reads and writes made to an actor's
+// storage.
+type meteredStorage struct {
+	Storage
+	gasTracker *GasTracker
+	prices     *StoragePrices
+}
+
+var _ exec.Storage = (*meteredStorage)(nil)
+
+// meter returns s, charging gas to gasTracker for its operations at the
+// storage prices in force at height bh.
+func meter(s Storage, gasTracker *GasTracker, bh *types.BlockHeight) exec.Storage {
+	if gasTracker == nil || bh == nil {
+		return s
+	}
+	prices := StorageGas.At(bh.AsBigInt().Uint64())
+	if prices.Free() {
+		return s
+	}
+	return &meteredStorage{Storage: s, gasTracker: gasTracker, prices: prices}
+}
+
+// Put adds v to storage and charges for the size of its encoding.
+func (ms *meteredStorage) Put(v interface{}) (cid.Cid, error) {
+	c, err := ms.Storage.Put(v)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := ms.gasTracker.Charge(ms.prices.WriteCost(len(ms.Storage.chunks[c].RawData()))); err != nil {
+		return cid.Undef, err
+	}
+	return c, nil
+}
+
+// Get charges for the size of the object read.
+func (ms *meteredStorage) Get(c cid.Cid) ([]byte, error) {
+	data, err := ms.Storage.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.gasTracker.Charge(ms.prices.ReadCost(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
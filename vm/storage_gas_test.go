@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestStorageGasSchedule(t *testing.T) {
+	tf.UnitTest(t)
+
+	s := NewStorageGasSchedule(StoragePricesV0)
+	require.NoError(t, s.Activate(100, StoragePricesV1.Name))
+
+	assert.Equal(t, StoragePricesV0, s.At(0))
+	assert.Equal(t, StoragePricesV0, s.At(99))
+	assert.Equal(t, StoragePricesV1, s.At(100))
+	assert.Equal(t, StoragePricesV1, s.At(1000))
+
+	assert.Error(t, s.Activate(100, StoragePricesV0.Name))
+	assert.Error(t, s.Activate(200, "no-such-prices"))
+	assert.Error(t, RegisterStoragePrices(&StoragePrices{Name: StoragePricesV1.Name}))
+}
+
+func TestMeteredStorage(t *testing.T) {
+	tf.UnitTest(t)
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := NewStorageMap(bs)
+	testActor := actor.NewActor(types.AccountActorCodeCid, types.NewZeroAttoFIL())
+
+	data, err := cbor.WrapObject("some data an actor might store", types.DefaultHashFunction, -1)
+	require.NoError(t, err)
+	size := len(data.RawData())
+
+	newGasTracker := func(limit uint64) *GasTracker {
+		gasTracker := NewGasTracker()
+		gasTracker.MsgGasLimit = types.NewGasUnits(limit)
+		return gasTracker
+	}
+
+	t.Run("free prices are not metered", func(t *testing.T) {
+		s := meter(vms.NewStorage(address.TestAddress, testActor), newGasTracker(0), types.NewBlockHeight(0))
+		_, ok := s.(Storage)
+		assert.True(t, ok)
+	})
+
+	t.Run("reads and writes are charged by size", func(t *testing.T) {
+		gasTracker := newGasTracker(10000)
+		s := &meteredStorage{Storage: vms.NewStorage(address.TestAddress, testActor), gasTracker: gasTracker, prices: StoragePricesV1}
+
+		c, err := s.Put(data.RawData())
+		require.NoError(t, err)
+		assert.Equal(t, StoragePricesV1.WriteCost(size), gasTracker.gasConsumedByMessage)
+
+		_, err = s.Get(c)
+		require.NoError(t, err)
+		assert.Equal(t, StoragePricesV1.WriteCost(size)+StoragePricesV1.ReadCost(size), gasTracker.gasConsumedByMessage)
+	})
+
+	t.Run("writes beyond the gas limit fail", func(t *testing.T) {
+		gasTracker := newGasTracker(uint64(StoragePricesV1.WriteCost(size)) - 1)
+		s := &meteredStorage{Storage: vms.NewStorage(address.TestAddress, testActor), gasTracker: gasTracker, prices: StoragePricesV1}
+
+		_, err := s.Put(data.RawData())
+		assert.Error(t, err)
+	})
+}
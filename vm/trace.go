@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// SendTrace records the execution of a single message send in the VM,
+// including the sends it made to other actors.
+type SendTrace struct {
+	From     address.Address `json:"from"`
+	To       address.Address `json:"to"`
+	Method   string          `json:"method"`
+	Value    *types.AttoFIL  `json:"value"`
+	ExitCode uint8           `json:"exitCode"`
+	// GasUsed is the gas consumed by this send including its subcalls.
+	GasUsed  types.GasUnits `json:"gasUsed"`
+	Error    string         `json:"error,omitempty"`
+	Subcalls []*SendTrace   `json:"subcalls,omitempty"`
+}
+
+// Failed returns true if the send errored or exited with a non-zero code.
+func (st *SendTrace) Failed() bool {
+	return st.ExitCode != 0 || st.Error != ""
+}
+
+// FirstFailure returns the send that caused st to fail: the deepest failed
+// send along the path of the first failed subcall of each failed send.  It
+// returns nil if st did not fail.
+func (st *SendTrace) FirstFailure() *SendTrace {
+	if !st.Failed() {
+		return nil
+	}
+	for _, sub := range st.Subcalls {
+		if cause := sub.FirstFailure(); cause != nil {
+			return cause
+		}
+	}
+	return st
+}
+
+// Walk calls fn for st and each of its subcalls, depth first, with the depth
+// of the send in the tree starting at zero.
+func (st *SendTrace) Walk(fn func(trace *SendTrace, depth int)) {
+	st.walk(fn, 0)
+}
+
+func (st *SendTrace) walk(fn func(*SendTrace, int), depth int) {
+	fn(st, depth)
+	for _, sub := range st.Subcalls {
+		sub.walk(fn, depth+1)
+	}
+}
+
+// Tracer collects a tree of SendTraces as the VM executes a message.  A
+// Tracer is not safe for concurrent use and should trace a single message.
+type Tracer struct {
+	root  *SendTrace
+	stack []*SendTrace
+}
+
+// NewTracer returns an empty Tracer.
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// Root returns the trace of the outermost send, or nil if nothing was sent.
+func (t *Tracer) Root() *SendTrace {
+	return t.root
+}
+
+// begin records the start of a send and returns its trace.
+func (t *Tracer) begin(vmCtx *Context) *SendTrace {
+	trace := &SendTrace{
+		From:   vmCtx.message.From,
+		To:     vmCtx.message.To,
+		Method: vmCtx.message.Method,
+		Value:  vmCtx.message.Value,
+	}
+	if len(t.stack) == 0 {
+		t.root = trace
+	} else {
+		parent := t.stack[len(t.stack)-1]
+		parent.Subcalls = append(parent.Subcalls, trace)
+	}
+	t.stack = append(t.stack, trace)
+	return trace
+}
+
+// end records the outcome of the send most recently begun.
+func (t *Tracer) end(trace *SendTrace, gasUsed types.GasUnits, exitCode uint8, err error) {
+	trace.GasUsed = gasUsed
+	trace.ExitCode = exitCode
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+type tracerKey struct{}
+
+// WithTracer returns a context that causes messages applied with it to be
+// traced by t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// TracerFromContext returns the Tracer set on ctx by WithTracer, or nil.
+func TracerFromContext(ctx context.Context) *Tracer {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	return t
+}
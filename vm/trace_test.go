@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+func TestTracer(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	a, b, c := newAddress(), newAddress(), newAddress()
+	sendCtx := func(from, to address.Address, method string) *Context {
+		return &Context{message: types.NewMessage(from, to, 0, types.ZeroAttoFIL, method, nil)}
+	}
+
+	tracer := NewTracer()
+	assert.Nil(t, tracer.Root())
+
+	// a calls b, which calls c twice; the second call fails.
+	outer := tracer.begin(sendCtx(a, b, "outer"))
+	first := tracer.begin(sendCtx(b, c, "first"))
+	tracer.end(first, types.NewGasUnits(10), 0, nil)
+	second := tracer.begin(sendCtx(b, c, "second"))
+	tracer.end(second, types.NewGasUnits(20), 1, errors.NewRevertError("boom"))
+	tracer.end(outer, types.NewGasUnits(50), 1, errors.NewRevertError("callee failed"))
+
+	root := tracer.Root()
+	require.NotNil(t, root)
+	assert.Equal(t, "outer", root.Method)
+	require.Len(t, root.Subcalls, 2)
+	assert.Equal(t, types.NewGasUnits(50), root.GasUsed)
+	assert.False(t, root.Subcalls[0].Failed())
+	assert.True(t, root.Subcalls[1].Failed())
+	assert.Equal(t, "boom", root.Subcalls[1].Error)
+
+	t.Run("first failure is the deepest failed send", func(t *testing.T) {
+		assert.Equal(t, root.Subcalls[1], root.FirstFailure())
+		assert.Nil(t, root.Subcalls[0].FirstFailure())
+	})
+
+	t.Run("walk visits sends depth first", func(t *testing.T) {
+		var methods []string
+		var depths []int
+		root.Walk(func(trace *SendTrace, depth int) {
+			methods = append(methods, trace.Method)
+			depths = append(depths, depth)
+		})
+		assert.Equal(t, []string{"outer", "first", "second"}, methods)
+		assert.Equal(t, []int{0, 1, 1}, depths)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm/errors"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 )
 
 // Send executes a message pass inside the VM. If error is set it
@@ -18,7 +19,15 @@ func Send(ctx context.Context, vmCtx *Context) ([][]byte, uint8, error) {
 	deps := sendDeps{
 		transfer: Transfer,
 	}
-	return send(ctx, deps, vmCtx)
+	if vmCtx.tracer == nil {
+		return send(ctx, deps, vmCtx)
+	}
+
+	gasBefore := vmCtx.GasUnits()
+	trace := vmCtx.tracer.begin(vmCtx)
+	ret, exitCode, err := send(ctx, deps, vmCtx)
+	vmCtx.tracer.end(trace, vmCtx.GasUnits()-gasBefore, exitCode, err)
+	return ret, exitCode, err
 }
 
 type sendDeps struct {
@@ -48,7 +57,7 @@ func send(ctx context.Context, deps sendDeps, vmCtx *Context) ([][]byte, uint8,
 	}
 
 	if !toExecutable.Exports().Has(vmCtx.message.Method) {
-		return nil, 1, errors.Errors[errors.ErrMissingExport]
+		return nil, errors.ErrMissingExport, errors.Errors[errors.ErrMissingExport]
 	}
 
 	r, code, err := actor.MakeTypedExport(toExecutable, vmCtx.message.Method)(vmCtx)
@@ -56,7 +65,7 @@ func send(ctx context.Context, deps sendDeps, vmCtx *Context) ([][]byte, uint8,
 		var rv [][]byte
 		err = cbor.DecodeInto(r, &rv)
 		if err != nil {
-			return nil, 1, errors.NewRevertErrorf("method return doesn't decode as array: %s", err)
+			return nil, exitcode.SerializationError, errors.NewCodedRevertErrorf(exitcode.SerializationError, "method return doesn't decode as array: %s", err)
 		}
 		return rv, code, err
 	}
@@ -14,6 +14,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm/errors"
+	"github.com/filecoin-project/go-filecoin/vm/exitcode"
 
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/stretchr/testify/assert"
@@ -107,7 +108,7 @@ func TestSendErrorHandling(t *testing.T) {
 		assert.True(t, errors.ShouldRevert(sendErr))
 	})
 
-	t.Run("returns exit code 1 and a revert error if code doesn't export a matching method", func(t *testing.T) {
+	t.Run("returns a method not found exit code and a revert error if code doesn't export a matching method", func(t *testing.T) {
 		msg := newMsg()
 		msg.Value = nil // such that we don't transfer
 		msg.Method = "bar"
@@ -133,7 +134,7 @@ func TestSendErrorHandling(t *testing.T) {
 		_, code, sendErr := send(context.Background(), deps, vmCtx)
 
 		assert.Error(t, sendErr)
-		assert.Equal(t, 1, int(code))
+		assert.Equal(t, exitcode.MethodNotFound, int(code))
 		assert.True(t, errors.ShouldRevert(sendErr))
 	})
 }
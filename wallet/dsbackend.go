@@ -4,12 +4,14 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	ds "github.com/ipfs/go-datastore"
 	dsq "github.com/ipfs/go-datastore/query"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -33,6 +35,17 @@ type DSBackend struct {
 
 	// TODO: proper cache
 	cache map[address.Address]struct{}
+
+	// hdLk serializes the derivation of keys from the seed.
+	hdLk sync.Mutex
+
+	// ksLk guards the encryption state of the backend.  params is nil if
+	// the keys are stored in the clear, and key is nil while the backend is
+	// locked.
+	ksLk      sync.RWMutex
+	params    *keystoreParams
+	key       []byte
+	lockTimer *time.Timer
 }
 
 var _ Backend = (*DSBackend)(nil)
@@ -53,6 +66,9 @@ func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
 
 	cache := make(map[address.Address]struct{})
 	for _, el := range list {
+		if isMetadataKey(el.Key) {
+			continue
+		}
 		parsedAddr, err := address.NewFromString(strings.Trim(el.Key, "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", el.Key)
@@ -60,9 +76,15 @@ func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
 		cache[parsedAddr] = struct{}{}
 	}
 
+	params, err := loadKeystoreParams(ds)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DSBackend{
-		ds:    ds,
-		cache: cache,
+		ds:     ds,
+		cache:  cache,
+		params: params,
 	}, nil
 }
 
@@ -93,18 +115,37 @@ func (backend *DSBackend) HasAddress(addr address.Address) bool {
 	return ok
 }
 
-// NewAddress creates a new address and stores it.
+// NewAddress creates a new address using protocol, which is either
+// address.SECP256K1 or address.BLS, and stores its key.  Secp256k1 keys are
+// derived from the seed of the backend if it has one.
 // Safe for concurrent access.
-func (backend *DSBackend) NewAddress() (address.Address, error) {
-	prv, err := crypto.GenerateKey()
-	if err != nil {
-		return address.Undef, err
-	}
-
-	// TODO: maybe the above call should just return a keyinfo?
-	ki := &types.KeyInfo{
-		PrivateKey: prv,
-		Curve:      SECP256K1,
+func (backend *DSBackend) NewAddress(protocol address.Protocol) (address.Address, error) {
+	var ki *types.KeyInfo
+	switch {
+	case protocol == address.SECP256K1 && backend.HasSeed():
+		var err error
+		ki, err = backend.newDerivedKeyInfo()
+		if err != nil {
+			return address.Undef, err
+		}
+	case protocol == address.SECP256K1:
+		prv, err := crypto.GenerateKey()
+		if err != nil {
+			return address.Undef, err
+		}
+		// TODO: maybe the above call should just return a keyinfo?
+		ki = &types.KeyInfo{
+			PrivateKey: prv,
+			Curve:      SECP256K1,
+		}
+	case protocol == address.BLS:
+		prv := bls.PrivateKeyGenerate()
+		ki = &types.KeyInfo{
+			PrivateKey: prv[:],
+			Curve:      types.BLS,
+		}
+	default:
+		return address.Undef, errors.Errorf("cannot create addresses of protocol %d", protocol)
 	}
 
 	if err := backend.putKeyInfo(ki); err != nil {
@@ -120,18 +161,21 @@ func (backend *DSBackend) putKeyInfo(ki *types.KeyInfo) error {
 		return err
 	}
 
-	backend.lk.Lock()
-	defer backend.lk.Unlock()
-
 	kib, err := ki.Marshal()
 	if err != nil {
 		return err
 	}
 
-	if err := backend.ds.Put(ds.NewKey(a.String()), kib); err != nil {
+	if err := backend.putSecret(ds.NewKey(a.String()), kib); err != nil {
+		if err == ErrLocked {
+			return err
+		}
 		return errors.Wrap(err, "failed to store new address")
 	}
 
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
 	backend.cache[a] = struct{}{}
 	return nil
 }
@@ -143,6 +187,9 @@ func (backend *DSBackend) SignBytes(data []byte, addr address.Address) (types.Si
 		return nil, err
 	}
 
+	if ki.Type() == types.BLS {
+		return types.SignBLS(ki.Key(), data)
+	}
 	return wutil.Sign(ki.Key(), data)
 }
 
@@ -153,14 +200,18 @@ func (backend *DSBackend) Verify(data, pk []byte, sig types.Signature) bool {
 }
 
 // GetKeyInfo will return the private & public keys associated with address `addr`
-// iff backend contains the addr.
+// iff backend contains the addr.  It returns ErrLocked if the backend is
+// locked.
 func (backend *DSBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, error) {
 	if !backend.HasAddress(addr) {
 		return nil, errors.New("backend does not contain address")
 	}
 
 	// kib is a cbor of types.KeyInfo
-	kib, err := backend.ds.Get(ds.NewKey(addr.String()))
+	kib, err := backend.getSecret(ds.NewKey(addr.String()))
+	if err == ErrLocked {
+		return nil, err
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch private key from backend")
 	}
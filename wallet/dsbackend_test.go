@@ -8,7 +8,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
 )
 
 func TestDSBackendSimple(t *testing.T) {
@@ -26,7 +28,7 @@ func TestDSBackendSimple(t *testing.T) {
 	assert.Len(t, fs.Addresses(), 0)
 
 	t.Log("can create new address")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("address is stored")
@@ -39,6 +41,30 @@ func TestDSBackendSimple(t *testing.T) {
 	assert.True(t, fs2.HasAddress(addr))
 }
 
+func TestDSBackendBLS(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+
+	addr, err := fs.NewAddress(address.BLS)
+	require.NoError(t, err)
+	assert.Equal(t, address.BLS, addr.Protocol())
+
+	ki, err := fs.GetKeyInfo(addr)
+	require.NoError(t, err)
+	assert.Equal(t, types.BLS, ki.Type())
+
+	data := []byte("data to sign")
+	sig, err := fs.SignBytes(data, addr)
+	require.NoError(t, err)
+	assert.True(t, types.IsValidSignature(data, addr, sig))
+	assert.False(t, types.IsValidSignature([]byte("other data"), addr, sig))
+
+	_, err = fs.NewAddress(address.Actor)
+	assert.Error(t, err)
+}
+
 func TestDSBackendKeyPairMatchAddress(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -51,7 +77,7 @@ func TestDSBackendKeyPairMatchAddress(t *testing.T) {
 	assert.NoError(t, err)
 
 	t.Log("can create new address")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("address is stored")
@@ -87,7 +113,7 @@ func TestDSBackendErrorsForUnknownAddress(t *testing.T) {
 	assert.NoError(t, err)
 
 	t.Log("can create new address in fs1")
-	addr, err := fs1.NewAddress()
+	addr, err := fs1.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("address is stored fs1")
@@ -123,7 +149,7 @@ func TestDSBackendParallel(t *testing.T) {
 	wg.Add(count)
 	for i := 0; i < count; i++ {
 		go func() {
-			_, err := fs.NewAddress()
+			_, err := fs.NewAddress(address.SECP256K1)
 			assert.NoError(t, err)
 			wg.Done()
 		}()
@@ -0,0 +1,162 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip39"
+
+	"github.com/filecoin-project/go-filecoin/crypto"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// MnemonicEntropyBits is the entropy of the mnemonics of new seeds, which
+// makes them 24 words long.
+const MnemonicEntropyBits = 256
+
+// FilecoinCoinType is the coin type of Filecoin in the paths of keys
+// derived from a seed, as registered in SLIP-0044.
+const FilecoinCoinType = 461
+
+var (
+	// ErrNoSeed is returned when an operation requires a seed the wallet
+	// does not have.
+	ErrNoSeed = errors.New("wallet has no seed")
+	// ErrSeedExists is returned when setting the seed of a wallet that
+	// already has one.
+	ErrSeedExists = errors.New("wallet already has a seed")
+	// ErrInvalidMnemonic is returned for mnemonics that aren't valid BIP39
+	// mnemonics.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+)
+
+var (
+	hdPrefix      = ds.NewKey("hd")
+	mnemonicKey   = hdPrefix.ChildString("mnemonic")
+	nextIndexKey  = hdPrefix.ChildString("next")
+	hdPrefixMatch = hdPrefix.String() + "/"
+)
+
+// NewMnemonic returns the mnemonic of a new random seed.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(MnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// KeyPath returns the BIP44 path of the index-th secp256k1 key derived from
+// a seed: m/44'/461'/0'/0/index.
+func KeyPath(index uint32) []uint32 {
+	return []uint32{
+		crypto.HardenedKeyStart + 44,
+		crypto.HardenedKeyStart + FilecoinCoinType,
+		crypto.HardenedKeyStart,
+		0,
+		index,
+	}
+}
+
+// isHDKey returns true if key holds the seed of the backend rather than a key.
+func isHDKey(key string) bool {
+	return strings.HasPrefix(key, hdPrefixMatch)
+}
+
+// SetMnemonic sets the seed secp256k1 addresses are derived from to the one
+// of mnemonic.  Addresses created from then on are derived from the seed,
+// so that they can be restored from the mnemonic.
+func (backend *DSBackend) SetMnemonic(mnemonic string) error {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return ErrInvalidMnemonic
+	}
+
+	backend.hdLk.Lock()
+	defer backend.hdLk.Unlock()
+
+	has, err := backend.ds.Has(mnemonicKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to read seed")
+	}
+	if has {
+		return ErrSeedExists
+	}
+	if err := backend.putSecret(mnemonicKey, []byte(mnemonic)); err != nil {
+		if err == ErrLocked {
+			return err
+		}
+		return errors.Wrap(err, "failed to store seed")
+	}
+	return nil
+}
+
+// Mnemonic returns the mnemonic of the seed of the backend, or ErrNoSeed.
+func (backend *DSBackend) Mnemonic() (string, error) {
+	mnemonic, err := backend.getSecret(mnemonicKey)
+	if err == ds.ErrNotFound {
+		return "", ErrNoSeed
+	}
+	if err == ErrLocked {
+		return "", err
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read seed")
+	}
+	return string(mnemonic), nil
+}
+
+// HasSeed returns true if the backend derives its secp256k1 addresses from a
+// seed.
+func (backend *DSBackend) HasSeed() bool {
+	has, err := backend.ds.Has(mnemonicKey)
+	return err == nil && has
+}
+
+// newDerivedKeyInfo derives the next secp256k1 key from the seed.
+func (backend *DSBackend) newDerivedKeyInfo() (*types.KeyInfo, error) {
+	backend.hdLk.Lock()
+	defer backend.hdLk.Unlock()
+
+	mnemonic, err := backend.Mnemonic()
+	if err != nil {
+		return nil, err
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute seed")
+	}
+
+	var index uint32
+	next, err := backend.ds.Get(nextIndexKey)
+	if err == nil {
+		index = binary.BigEndian.Uint32(next)
+	} else if err != ds.ErrNotFound {
+		return nil, errors.Wrap(err, "failed to read next key index")
+	}
+
+	var prv []byte
+	for {
+		prv, err = crypto.DeriveKeyPath(seed, KeyPath(index))
+		index++
+		if err != crypto.ErrInvalidDerivedKey {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	next = make([]byte, 4)
+	binary.BigEndian.PutUint32(next, index)
+	if err := backend.ds.Put(nextIndexKey, next); err != nil {
+		return nil, errors.Wrap(err, "failed to store next key index")
+	}
+
+	return &types.KeyInfo{
+		PrivateKey: prv,
+		Curve:      SECP256K1,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestDSBackendSeed(t *testing.T) {
+	tf.UnitTest(t)
+
+	mnemonic, err := NewMnemonic()
+	require.NoError(t, err)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	assert.False(t, fs.HasSeed())
+	_, err = fs.Mnemonic()
+	assert.Equal(t, ErrNoSeed, err)
+
+	require.NoError(t, fs.SetMnemonic(mnemonic))
+	assert.Equal(t, ErrSeedExists, fs.SetMnemonic(mnemonic))
+
+	got, err := fs.Mnemonic()
+	require.NoError(t, err)
+	assert.Equal(t, mnemonic, got)
+
+	addr1, err := fs.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	addr2, err := fs.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	assert.NotEqual(t, addr1, addr2)
+
+	t.Run("reloads the backend with its seed", func(t *testing.T) {
+		reloaded, err := NewDSBackend(ds)
+		require.NoError(t, err)
+		assert.True(t, reloaded.HasSeed())
+		assert.ElementsMatch(t, []address.Address{addr1, addr2}, reloaded.Addresses())
+	})
+
+	t.Run("restores the same addresses from the mnemonic", func(t *testing.T) {
+		restored, err := RestoreSeed(New(mustNewDSBackend(t)), mnemonic, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{addr1, addr2}, restored)
+	})
+
+	t.Run("rejects invalid mnemonics", func(t *testing.T) {
+		other := mustNewDSBackend(t)
+		assert.Equal(t, ErrInvalidMnemonic, other.SetMnemonic("not a valid mnemonic"))
+		assert.False(t, other.HasSeed())
+	})
+}
+
+func mustNewDSBackend(t *testing.T) *DSBackend {
+	fs, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	return fs
+}
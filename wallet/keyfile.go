@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// KeyFileVersion is the version of the key file format written by
+// ExportKeyFile.  ImportKeyFile rejects key files of other versions.
+const KeyFileVersion = 1
+
+// KeyFile is the JSON format keys are exported in, so that they can move
+// between nodes and other tools.  Private keys are hex encoded, and each key
+// carries its address so that a key file can be checked when read.
+type KeyFile struct {
+	Version int          `json:"version"`
+	Keys    []KeyFileKey `json:"keys"`
+}
+
+// KeyFileKey is a key of a KeyFile.
+type KeyFileKey struct {
+	// Type is the type of the key, secp256k1 or bls.
+	Type string `json:"type"`
+	// PrivateKey is the hex encoded private key.
+	PrivateKey string `json:"privateKey"`
+	// Address is the address of the key.
+	Address string `json:"address"`
+}
+
+// NewKeyFile returns the key file of kis.
+func NewKeyFile(kis []*types.KeyInfo) (*KeyFile, error) {
+	kf := &KeyFile{
+		Version: KeyFileVersion,
+		Keys:    make([]KeyFileKey, len(kis)),
+	}
+	for i, ki := range kis {
+		addr, err := ki.Address()
+		if err != nil {
+			return nil, err
+		}
+		kf.Keys[i] = KeyFileKey{
+			Type:       ki.Curve,
+			PrivateKey: hex.EncodeToString(ki.PrivateKey),
+			Address:    addr.String(),
+		}
+	}
+	return kf, nil
+}
+
+// KeyInfos returns the keys of kf, after checking that the version of kf is
+// known and that the keys match their addresses.
+func (kf *KeyFile) KeyInfos() ([]*types.KeyInfo, error) {
+	if kf.Version != KeyFileVersion {
+		return nil, errors.Errorf("unsupported key file version %d, expected %d", kf.Version, KeyFileVersion)
+	}
+
+	kis := make([]*types.KeyInfo, len(kf.Keys))
+	for i, k := range kf.Keys {
+		if k.Type != SECP256K1 && k.Type != types.BLS {
+			return nil, errors.Errorf("unsupported key type %q", k.Type)
+		}
+		prv, err := hex.DecodeString(k.PrivateKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid private key of %s", k.Address)
+		}
+		ki := &types.KeyInfo{PrivateKey: prv, Curve: k.Type}
+
+		want, err := address.NewFromString(k.Address)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid address %s", k.Address)
+		}
+		addr, err := ki.Address()
+		if err != nil {
+			return nil, err
+		}
+		if addr != want {
+			return nil, errors.Errorf("private key of %s is the key of %s", want, addr)
+		}
+		kis[i] = ki
+	}
+	return kis, nil
+}
+
+// ExportKeyFile returns the key file of the keys of addrs.
+func ExportKeyFile(w *Wallet, addrs []address.Address) (*KeyFile, error) {
+	kis, err := w.Export(addrs)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyFile(kis)
+}
+
+// ImportKeyFile adds the keys of kf to the wallet and returns their
+// addresses.
+func ImportKeyFile(w *Wallet, kf *KeyFile) ([]address.Address, error) {
+	kis, err := kf.KeyInfos()
+	if err != nil {
+		return nil, err
+	}
+	return w.Import(kis)
+}
@@ -0,0 +1,64 @@
+package wallet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestKeyFile(t *testing.T) {
+	tf.UnitTest(t)
+
+	newWallet := func() *Wallet {
+		fs, err := NewDSBackend(datastore.NewMapDatastore())
+		require.NoError(t, err)
+		return New(fs)
+	}
+
+	w := newWallet()
+	secpAddr, err := NewAddress(w, address.SECP256K1)
+	require.NoError(t, err)
+	blsAddr, err := NewAddress(w, address.BLS)
+	require.NoError(t, err)
+
+	kf, err := ExportKeyFile(w, []address.Address{secpAddr, blsAddr})
+	require.NoError(t, err)
+	assert.Equal(t, KeyFileVersion, kf.Version)
+	assert.Equal(t, SECP256K1, kf.Keys[0].Type)
+	assert.Equal(t, secpAddr.String(), kf.Keys[0].Address)
+	assert.Equal(t, blsAddr.String(), kf.Keys[1].Address)
+
+	t.Run("imports exported keys", func(t *testing.T) {
+		b, err := json.Marshal(kf)
+		require.NoError(t, err)
+		var decoded KeyFile
+		require.NoError(t, json.Unmarshal(b, &decoded))
+
+		other := newWallet()
+		addrs, err := ImportKeyFile(other, &decoded)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{secpAddr, blsAddr}, addrs)
+		assert.True(t, other.HasAddress(secpAddr))
+		assert.True(t, other.HasAddress(blsAddr))
+	})
+
+	t.Run("rejects unknown versions", func(t *testing.T) {
+		bad := *kf
+		bad.Version = KeyFileVersion + 1
+		_, err := ImportKeyFile(newWallet(), &bad)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects keys not matching their address", func(t *testing.T) {
+		bad := KeyFile{Version: KeyFileVersion, Keys: []KeyFileKey{kf.Keys[0]}}
+		bad.Keys[0].Address = blsAddr.String()
+		_, err := ImportKeyFile(newWallet(), &bad)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,297 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"strings"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+func init() {
+	cbor.RegisterCborType(keystoreParams{})
+}
+
+// Parameters of the scrypt derivation of the encryption key from the
+// passphrase, as recommended for interactive use in 2017.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLen      = 32
+)
+
+var (
+	// ErrLocked is returned by operations that need the private keys of an
+	// encrypted wallet while it is locked.
+	ErrLocked = errors.New("wallet is locked")
+	// ErrNotEncrypted is returned when locking or unlocking a wallet whose
+	// keys are not encrypted.
+	ErrNotEncrypted = errors.New("wallet is not encrypted")
+	// ErrAlreadyEncrypted is returned when encrypting a wallet whose keys
+	// are already encrypted.
+	ErrAlreadyEncrypted = errors.New("wallet is already encrypted")
+	// ErrWrongPassphrase is returned when unlocking a wallet with a
+	// passphrase other than the one it was encrypted with.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
+
+var (
+	keystorePrefix    = ds.NewKey("keystore")
+	keystoreParamsKey = keystorePrefix.ChildString("params")
+)
+
+// passphraseCheck is encrypted in the keystore parameters to check
+// passphrases on unlock.
+var passphraseCheck = []byte("filecoin wallet")
+
+// keystoreParams are the parameters the encryption key of a wallet is
+// derived from its passphrase with.
+type keystoreParams struct {
+	Salt []byte
+	N    int
+	R    int
+	P    int
+	// Check is passphraseCheck sealed with the encryption key.
+	Check []byte
+}
+
+// isMetadataKey returns true if key holds data other than a key of the
+// backend, such as its seed, its keystore parameters or the addresses of the
+// Ledger backend sharing its datastore.
+func isMetadataKey(key string) bool {
+	return isHDKey(key) ||
+		strings.HasPrefix(key, keystorePrefix.String()+"/") ||
+		strings.HasPrefix(key, LedgerPrefix.String()+"/")
+}
+
+func (p *keystoreParams) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), p.Salt, p.N, p.R, p.P, scryptKeyLen)
+}
+
+// loadKeystoreParams returns the keystore parameters stored in d, or nil if
+// the wallet is not encrypted.
+func loadKeystoreParams(d ds.Datastore) (*keystoreParams, error) {
+	b, err := d.Get(keystoreParamsKey)
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read keystore parameters")
+	}
+	var params keystoreParams
+	if err := cbor.DecodeInto(b, &params); err != nil {
+		return nil, errors.Wrap(err, "failed to decode keystore parameters")
+	}
+	return &params, nil
+}
+
+// seal encrypts plaintext with key, prepending the random nonce to the
+// ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts what seal returned for key.
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypted returns true if the private keys of the backend are encrypted.
+func (backend *DSBackend) Encrypted() bool {
+	backend.ksLk.RLock()
+	defer backend.ksLk.RUnlock()
+	return backend.params != nil
+}
+
+// Locked returns true if the private keys of the backend are encrypted and
+// the backend is locked.
+func (backend *DSBackend) Locked() bool {
+	backend.ksLk.RLock()
+	defer backend.ksLk.RUnlock()
+	return backend.params != nil && backend.key == nil
+}
+
+// Encrypt encrypts the private keys and the seed of the backend with a key
+// derived from passphrase.  The backend is locked afterwards.
+func (backend *DSBackend) Encrypt(passphrase string) error {
+	backend.ksLk.Lock()
+	defer backend.ksLk.Unlock()
+
+	if backend.params != nil {
+		return ErrAlreadyEncrypted
+	}
+
+	params := &keystoreParams{
+		Salt: make([]byte, saltLen),
+		N:    scryptN,
+		R:    scryptR,
+		P:    scryptP,
+	}
+	if _, err := io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return err
+	}
+	key, err := params.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	if params.Check, err = seal(key, passphraseCheck); err != nil {
+		return err
+	}
+
+	// Encrypt the keys and the params in a single batch, so that a wallet
+	// is never left with part of its keys encrypted.
+	batch, err := backend.ds.Batch()
+	if err != nil {
+		return err
+	}
+	keys := []ds.Key{mnemonicKey}
+	for _, addr := range backend.Addresses() {
+		keys = append(keys, ds.NewKey(addr.String()))
+	}
+	for _, k := range keys {
+		plaintext, err := backend.ds.Get(k)
+		if err == ds.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", k)
+		}
+		sealed, err := seal(key, plaintext)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(k, sealed); err != nil {
+			return err
+		}
+	}
+	b, err := cbor.DumpObject(params)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(keystoreParamsKey, b); err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return errors.Wrap(err, "failed to store encrypted keys")
+	}
+
+	backend.params = params
+	return nil
+}
+
+// Unlock unlocks the backend with passphrase for timeout, or until Lock is
+// called if timeout is zero.
+func (backend *DSBackend) Unlock(passphrase string, timeout time.Duration) error {
+	backend.ksLk.Lock()
+	defer backend.ksLk.Unlock()
+
+	if backend.params == nil {
+		return ErrNotEncrypted
+	}
+	key, err := backend.params.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	check, err := open(key, backend.params.Check)
+	if err != nil || !bytes.Equal(check, passphraseCheck) {
+		return ErrWrongPassphrase
+	}
+
+	backend.key = key
+	if backend.lockTimer != nil {
+		backend.lockTimer.Stop()
+		backend.lockTimer = nil
+	}
+	if timeout > 0 {
+		backend.lockTimer = time.AfterFunc(timeout, func() {
+			backend.Lock() // nolint: errcheck
+		})
+	}
+	return nil
+}
+
+// Lock forgets the encryption key of the backend, so that its private keys
+// can't be used until it is unlocked again.
+func (backend *DSBackend) Lock() error {
+	backend.ksLk.Lock()
+	defer backend.ksLk.Unlock()
+
+	if backend.params == nil {
+		return ErrNotEncrypted
+	}
+	backend.key = nil
+	if backend.lockTimer != nil {
+		backend.lockTimer.Stop()
+		backend.lockTimer = nil
+	}
+	return nil
+}
+
+// putSecret stores value at k, encrypted if the backend is.
+func (backend *DSBackend) putSecret(k ds.Key, value []byte) error {
+	backend.ksLk.RLock()
+	defer backend.ksLk.RUnlock()
+
+	if backend.params != nil {
+		if backend.key == nil {
+			return ErrLocked
+		}
+		var err error
+		if value, err = seal(backend.key, value); err != nil {
+			return err
+		}
+	}
+	return backend.ds.Put(k, value)
+}
+
+// getSecret returns the value stored at k, decrypted if the backend is
+// encrypted.
+func (backend *DSBackend) getSecret(k ds.Key) ([]byte, error) {
+	backend.ksLk.RLock()
+	defer backend.ksLk.RUnlock()
+
+	if backend.params != nil && backend.key == nil {
+		return nil, ErrLocked
+	}
+	value, err := backend.ds.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	if backend.params == nil {
+		return value, nil
+	}
+	return open(backend.key, value)
+}
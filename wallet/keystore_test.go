@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestDSBackendEncryption(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	addr, err := fs.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	plain, err := ds.Get(datastore.NewKey(addr.String()))
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrNotEncrypted, fs.Lock())
+	require.NoError(t, fs.Encrypt("passphrase"))
+	assert.Equal(t, ErrAlreadyEncrypted, fs.Encrypt("passphrase"))
+	assert.True(t, fs.Encrypted())
+	assert.True(t, fs.Locked())
+
+	sealed, err := ds.Get(datastore.NewKey(addr.String()))
+	require.NoError(t, err)
+	assert.NotEqual(t, plain, sealed)
+
+	t.Run("locked backends don't sign or create keys", func(t *testing.T) {
+		_, err := fs.SignBytes([]byte("data"), addr)
+		assert.Equal(t, ErrLocked, err)
+		_, err = fs.NewAddress(address.SECP256K1)
+		assert.Equal(t, ErrLocked, err)
+	})
+
+	t.Run("unlocks with the passphrase only", func(t *testing.T) {
+		assert.Equal(t, ErrWrongPassphrase, fs.Unlock("wrong", 0))
+		assert.True(t, fs.Locked())
+
+		require.NoError(t, fs.Unlock("passphrase", 0))
+		ki, err := fs.GetKeyInfo(addr)
+		require.NoError(t, err)
+		kib, err := ki.Marshal()
+		require.NoError(t, err)
+		assert.Equal(t, plain, kib)
+
+		_, err = fs.NewAddress(address.SECP256K1)
+		assert.NoError(t, err)
+
+		require.NoError(t, fs.Lock())
+		assert.True(t, fs.Locked())
+	})
+
+	t.Run("reloads encrypted and locked", func(t *testing.T) {
+		reloaded, err := NewDSBackend(ds)
+		require.NoError(t, err)
+		assert.True(t, reloaded.Locked())
+		assert.Len(t, reloaded.Addresses(), 2)
+
+		require.NoError(t, reloaded.Unlock("passphrase", 0))
+		_, err = reloaded.SignBytes([]byte("data"), addr)
+		assert.NoError(t, err)
+	})
+
+	t.Run("locks after the unlock timeout", func(t *testing.T) {
+		require.NoError(t, fs.Unlock("passphrase", 10*time.Millisecond))
+		assert.False(t, fs.Locked())
+		time.Sleep(100 * time.Millisecond)
+		assert.True(t, fs.Locked())
+	})
+}
+
+func TestDSBackendEncryptsSeed(t *testing.T) {
+	tf.UnitTest(t)
+
+	mnemonic, err := NewMnemonic()
+	require.NoError(t, err)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	require.NoError(t, fs.SetMnemonic(mnemonic))
+	require.NoError(t, fs.Encrypt("passphrase"))
+
+	stored, err := ds.Get(mnemonicKey)
+	require.NoError(t, err)
+	assert.NotEqual(t, mnemonic, string(stored))
+
+	_, err = fs.Mnemonic()
+	assert.Equal(t, ErrLocked, err)
+
+	require.NoError(t, fs.Unlock("passphrase", 0))
+	got, err := fs.Mnemonic()
+	require.NoError(t, err)
+	assert.Equal(t, mnemonic, got)
+}
@@ -0,0 +1,239 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/crypto"
+	"github.com/filecoin-project/go-filecoin/types"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+// APDU instructions of the Filecoin app of Ledger devices.
+const (
+	ledgerCLA          = 0x06
+	ledgerInsGetAddr   = 0x01
+	ledgerInsSign      = 0x02
+	ledgerChunkSize    = 250
+	ledgerPayloadInit  = 0
+	ledgerPayloadAdd   = 1
+	ledgerPayloadLast  = 2
+	ledgerSignatureLen = 65
+)
+
+// ErrKeyNotExportable is returned when asking a hardware wallet backend for
+// a private key, which never leaves the device.
+var ErrKeyNotExportable = errors.New("private key can't be exported from a hardware wallet")
+
+// LedgerPrefix is the prefix of the keys of the wallet datastore the Ledger
+// backend stores its addresses under.
+var LedgerPrefix = ds.NewKey("ledger")
+
+// LedgerBackendType is the reflect type of the LedgerBackend.
+var LedgerBackendType = reflect.TypeOf(&LedgerBackend{})
+
+// LedgerDevice exchanges APDUs with a Ledger device running the Filecoin
+// app.
+type LedgerDevice interface {
+	// Exchange sends command to the device and returns its response,
+	// without the status word, or an error if the status isn't success.
+	Exchange(command []byte) ([]byte, error)
+	Close() error
+}
+
+// LedgerBackend is a wallet backend whose keys are held by a Ledger device,
+// which signs messages after the user confirms them on its screen.  It only
+// stores the derivation paths of its addresses, so that the keys never are
+// on the host.  The device is opened for each operation, so it can be
+// plugged in only when signing.
+type LedgerBackend struct {
+	lk sync.RWMutex
+
+	ds   ds.Datastore
+	open func() (LedgerDevice, error)
+
+	// indexes are the indexes in the paths of the keys of the addresses.
+	indexes map[address.Address]uint32
+}
+
+var _ Backend = (*LedgerBackend)(nil)
+
+// NewLedgerBackend constructs a backend that stores the addresses of the
+// device open connects to in d.
+func NewLedgerBackend(d ds.Datastore, open func() (LedgerDevice, error)) (*LedgerBackend, error) {
+	result, err := d.Query(dsq.Query{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query datastore")
+	}
+	list, err := result.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read query results")
+	}
+
+	indexes := make(map[address.Address]uint32)
+	for _, el := range list {
+		addr, err := address.NewFromString(strings.Trim(el.Key, "/"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", el.Key)
+		}
+		if len(el.Value) != 4 {
+			return nil, errors.Errorf("invalid key index for address %s", addr)
+		}
+		indexes[addr] = binary.BigEndian.Uint32(el.Value)
+	}
+
+	return &LedgerBackend{
+		ds:      d,
+		open:    open,
+		indexes: indexes,
+	}, nil
+}
+
+// Addresses returns a list of all addresses that are stored in this backend.
+func (backend *LedgerBackend) Addresses() []address.Address {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	var cpy []address.Address
+	for addr := range backend.indexes {
+		cpy = append(cpy, addr)
+	}
+	return cpy
+}
+
+// HasAddress checks if the passed in address is stored in this backend.
+func (backend *LedgerBackend) HasAddress(addr address.Address) bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	_, ok := backend.indexes[addr]
+	return ok
+}
+
+// NewAddress adds the address of the index-th key of the device, at path
+// KeyPath(index), to the backend.  If confirm is true, the device displays
+// the address and the user must confirm it.
+func (backend *LedgerBackend) NewAddress(index uint32, confirm bool) (address.Address, error) {
+	dev, err := backend.open()
+	if err != nil {
+		return address.Undef, errors.Wrap(err, "failed to open ledger device")
+	}
+	defer dev.Close() // nolint: errcheck
+
+	var p1 byte
+	if confirm {
+		p1 = 1
+	}
+	path := serializeLedgerPath(KeyPath(index))
+	resp, err := dev.Exchange(append([]byte{ledgerCLA, ledgerInsGetAddr, p1, 0, byte(len(path))}, path...))
+	if err != nil {
+		return address.Undef, errors.Wrap(err, "failed to get address from ledger device")
+	}
+
+	if len(resp) < crypto.PublicKeyBytes+1 {
+		return address.Undef, errors.New("ledger device returned a truncated address")
+	}
+	pk := resp[:crypto.PublicKeyBytes]
+	addrLen := int(resp[crypto.PublicKeyBytes])
+	addrBytes := resp[crypto.PublicKeyBytes+1:]
+	if len(addrBytes) < addrLen {
+		return address.Undef, errors.New("ledger device returned a truncated address")
+	}
+
+	addr, err := address.NewSecp256k1Address(pk)
+	if err != nil {
+		return address.Undef, err
+	}
+	if !bytes.Equal(addr.Bytes(), addrBytes[:addrLen]) {
+		return address.Undef, errors.Errorf("ledger device returned address %x for public key of address %s", addrBytes[:addrLen], addr)
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, index)
+	if err := backend.ds.Put(ds.NewKey(addr.String()), b); err != nil {
+		return address.Undef, errors.Wrap(err, "failed to store new address")
+	}
+	backend.indexes[addr] = index
+	return addr, nil
+}
+
+// SignBytes has the device sign data with the key of addr, once the user
+// confirms it.
+func (backend *LedgerBackend) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+	backend.lk.RLock()
+	index, ok := backend.indexes[addr]
+	backend.lk.RUnlock()
+	if !ok {
+		return nil, errors.New("backend does not contain address")
+	}
+
+	dev, err := backend.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open ledger device")
+	}
+	defer dev.Close() // nolint: errcheck
+
+	chunks := [][]byte{serializeLedgerPath(KeyPath(index))}
+	for len(data) > ledgerChunkSize {
+		chunks = append(chunks, data[:ledgerChunkSize])
+		data = data[ledgerChunkSize:]
+	}
+	chunks = append(chunks, data)
+
+	var resp []byte
+	for i, chunk := range chunks {
+		p1 := byte(ledgerPayloadAdd)
+		if i == 0 {
+			p1 = ledgerPayloadInit
+		} else if i == len(chunks)-1 {
+			p1 = ledgerPayloadLast
+		}
+		resp, err = dev.Exchange(append([]byte{ledgerCLA, ledgerInsSign, p1, 0, byte(len(chunk))}, chunk...))
+		if err != nil {
+			return nil, errors.Wrap(err, "ledger device failed to sign")
+		}
+	}
+
+	// The response is the signature as [R | S | V] followed by its DER
+	// encoding.
+	if len(resp) < ledgerSignatureLen {
+		return nil, errors.New("ledger device returned a truncated signature")
+	}
+	sig := make([]byte, ledgerSignatureLen)
+	copy(sig, resp)
+	return sig, nil
+}
+
+// Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
+// the public key `pk`.
+func (backend *LedgerBackend) Verify(data, pk []byte, sig types.Signature) bool {
+	valid, err := wutil.Verify(pk, data, sig)
+	return err == nil && valid
+}
+
+// GetKeyInfo returns ErrKeyNotExportable since the keys never leave the
+// device.
+func (backend *LedgerBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, error) {
+	return nil, ErrKeyNotExportable
+}
+
+// serializeLedgerPath serializes path as the Filecoin app expects, as
+// little endian integers.
+func serializeLedgerPath(path []uint32) []byte {
+	b := make([]byte, 4*len(path))
+	for i, index := range path {
+		binary.LittleEndian.PutUint32(b[4*i:], index)
+	}
+	return b
+}
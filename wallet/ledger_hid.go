@@ -0,0 +1,10 @@
+package wallet
+
+import (
+	ledger "github.com/zondax/ledger-go"
+)
+
+// OpenLedger connects to the first Ledger device plugged in over USB.
+func OpenLedger() (LedgerDevice, error) {
+	return ledger.FindLedger()
+}
@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/crypto"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+// fakeLedger implements the Filecoin app with keys derived from a seed.
+type fakeLedger struct {
+	seed    []byte
+	pending []byte
+	path    []uint32
+}
+
+func (l *fakeLedger) Exchange(command []byte) ([]byte, error) {
+	ins, p1, data := command[1], command[2], command[5:]
+	switch ins {
+	case ledgerInsGetAddr:
+		key, err := crypto.DeriveKeyPath(l.seed, parseLedgerPath(data))
+		if err != nil {
+			return nil, err
+		}
+		pk := crypto.PublicKey(key)
+		addr, err := address.NewSecp256k1Address(pk)
+		if err != nil {
+			return nil, err
+		}
+		resp := append(pk, byte(len(addr.Bytes())))
+		return append(resp, addr.Bytes()...), nil
+	case ledgerInsSign:
+		switch p1 {
+		case ledgerPayloadInit:
+			l.path, l.pending = parseLedgerPath(data), nil
+			return nil, nil
+		case ledgerPayloadAdd:
+			l.pending = append(l.pending, data...)
+			return nil, nil
+		}
+		l.pending = append(l.pending, data...)
+		key, err := crypto.DeriveKeyPath(l.seed, l.path)
+		if err != nil {
+			return nil, err
+		}
+		return wutil.Sign(key, l.pending)
+	}
+	return nil, errors.New("unknown instruction")
+}
+
+func (l *fakeLedger) Close() error {
+	return nil
+}
+
+func parseLedgerPath(b []byte) []uint32 {
+	path := make([]uint32, len(b)/4)
+	for i := range path {
+		path[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return path
+}
+
+func TestLedgerBackend(t *testing.T) {
+	tf.UnitTest(t)
+
+	device := &fakeLedger{seed: []byte("ledger seed")}
+	open := func() (LedgerDevice, error) { return device, nil }
+
+	ds := datastore.NewMapDatastore()
+	lb, err := NewLedgerBackend(ds, open)
+	require.NoError(t, err)
+
+	addr, err := lb.NewAddress(3, false)
+	require.NoError(t, err)
+	assert.True(t, lb.HasAddress(addr))
+
+	key, err := crypto.DeriveKeyPath(device.seed, KeyPath(3))
+	require.NoError(t, err)
+	want, err := address.NewSecp256k1Address(crypto.PublicKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, want, addr)
+
+	t.Run("signs data spanning several chunks", func(t *testing.T) {
+		data := bytes.Repeat([]byte{7}, 2*ledgerChunkSize+1)
+		sig, err := lb.SignBytes(data, addr)
+		require.NoError(t, err)
+		assert.True(t, types.IsValidSignature(data, addr, sig))
+	})
+
+	t.Run("doesn't export keys", func(t *testing.T) {
+		_, err := lb.GetKeyInfo(addr)
+		assert.Equal(t, ErrKeyNotExportable, err)
+	})
+
+	t.Run("reloads its addresses", func(t *testing.T) {
+		reloaded, err := NewLedgerBackend(ds, open)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{addr}, reloaded.Addresses())
+
+		sig, err := reloaded.SignBytes([]byte("data"), addr)
+		require.NoError(t, err)
+		assert.True(t, types.IsValidSignature([]byte("data"), addr, sig))
+	})
+}
@@ -6,10 +6,12 @@ import (
 	"reflect"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/types"
 	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
@@ -125,8 +127,15 @@ func (w *Wallet) GetAddressForPubKey(pk []byte) (address.Address, error) {
 }
 
 // Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
-// the public key `pk`.
+// the public key `pk`, which may be a secp256k1 or a BLS public key.
 func (w *Wallet) Verify(data []byte, pk []byte, sig types.Signature) (bool, error) {
+	if len(pk) == bls.PublicKeyBytes {
+		addr, err := address.NewBLSAddress(pk)
+		if err != nil {
+			return false, err
+		}
+		return types.IsValidSignature(data, addr, sig), nil
+	}
 	return wutil.Verify(pk, data, sig)
 }
 
@@ -138,15 +147,110 @@ func (w *Wallet) Ecrecover(data []byte, sig types.Signature) ([]byte, error) {
 	return wutil.Ecrecover(data, sig)
 }
 
-// NewAddress creates a new account address on the default wallet backend.
-func NewAddress(w *Wallet) (address.Address, error) {
-	backends := w.Backends(DSBackendType)
+// NewAddress creates a new account address using protocol on the default
+// wallet backend.
+func NewAddress(w *Wallet, protocol address.Protocol) (address.Address, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return address.Undef, err
+	}
+	return backend.NewAddress(protocol)
+}
+
+// NewSeed sets the seed of the default wallet backend to a new random one and
+// returns its mnemonic.  The secp256k1 addresses created from then on can be
+// restored from the mnemonic.
+func NewSeed(w *Wallet) (string, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return "", err
+	}
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		return "", err
+	}
+	if err := backend.SetMnemonic(mnemonic); err != nil {
+		return "", err
+	}
+	return mnemonic, nil
+}
+
+// Mnemonic returns the mnemonic of the seed of the default wallet backend.
+func Mnemonic(w *Wallet) (string, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return "", err
+	}
+	return backend.Mnemonic()
+}
+
+// RestoreSeed sets the seed of the default wallet backend to the one of
+// mnemonic and derives its first count secp256k1 addresses.
+func RestoreSeed(w *Wallet, mnemonic string, count uint) ([]address.Address, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return nil, err
+	}
+	if err := backend.SetMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	addrs := make([]address.Address, count)
+	for i := range addrs {
+		addrs[i], err = backend.NewAddress(address.SECP256K1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return addrs, nil
+}
+
+// NewLedgerAddress adds the address of the index-th key of the Ledger device
+// to the Ledger wallet backend, having the user confirm it on the device if
+// confirm is true.
+func NewLedgerAddress(w *Wallet, index uint32, confirm bool) (address.Address, error) {
+	backends := w.Backends(LedgerBackendType)
 	if len(backends) == 0 {
-		return address.Undef, fmt.Errorf("missing default ds backend")
+		return address.Undef, fmt.Errorf("ledger wallet backend is not enabled")
+	}
+	return backends[0].(*LedgerBackend).NewAddress(index, confirm)
+}
+
+// Encrypt encrypts the keys of the default wallet backend with passphrase and
+// locks it.
+func Encrypt(w *Wallet, passphrase string) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
 	}
+	return backend.Encrypt(passphrase)
+}
 
-	backend := (backends[0]).(*DSBackend)
-	return backend.NewAddress()
+// Unlock unlocks the default wallet backend with passphrase for timeout, or
+// until it is locked if timeout is zero.
+func Unlock(w *Wallet, passphrase string, timeout time.Duration) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
+	}
+	return backend.Unlock(passphrase, timeout)
+}
+
+// Lock locks the default wallet backend.
+func Lock(w *Wallet) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
+	}
+	return backend.Lock()
+}
+
+func defaultBackend(w *Wallet) (*DSBackend, error) {
+	backends := w.Backends(DSBackendType)
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("missing default ds backend")
+	}
+	return (backends[0]).(*DSBackend), nil
 }
 
 // GetPubKeyForAddress returns the public key in the keystore associated with
@@ -162,7 +266,7 @@ func (w *Wallet) GetPubKeyForAddress(addr address.Address) ([]byte, error) {
 
 // NewKeyInfo creates a new KeyInfo struct in the wallet backend and returns it
 func (w *Wallet) NewKeyInfo() (*types.KeyInfo, error) {
-	newAddr, err := NewAddress(w)
+	newAddr, err := NewAddress(w, address.SECP256K1)
 	if err != nil {
 		return &types.KeyInfo{}, err
 	}
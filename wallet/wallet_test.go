@@ -30,7 +30,7 @@ func TestWalletSimple(t *testing.T) {
 	assert.Len(t, w.Backends(wallet.DSBackendType), 1)
 
 	t.Log("create a new address in the backend")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("test HasAddress")
@@ -52,7 +52,7 @@ func TestWalletSimple(t *testing.T) {
 	assert.Equal(t, list[0], addr)
 
 	t.Log("addresses are sorted")
-	addr2, err := fs.NewAddress()
+	addr2, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	if bytes.Compare(addr2.Bytes(), addr.Bytes()) < 0 {
@@ -81,7 +81,7 @@ func TestSimpleSignAndVerify(t *testing.T) {
 	assert.Len(t, w.Backends(wallet.DSBackendType), 1)
 
 	t.Log("create a new address in the backend")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("test HasAddress")
@@ -149,9 +149,9 @@ func TestSignErrorCases(t *testing.T) {
 	assert.Len(t, w2.Backends(wallet.DSBackendType), 1)
 
 	t.Log("create a new address each backend")
-	addr1, err := fs1.NewAddress()
+	addr1, err := fs1.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
-	addr2, err := fs2.NewAddress()
+	addr2, err := fs2.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("test HasAddress")
@@ -206,7 +206,7 @@ func TestWallet_CreateTicket(t *testing.T) {
 	fs, err := wallet.NewDSBackend(ds)
 	assert.NoError(t, err)
 	w := wallet.New(fs)
-	addr, err := wallet.NewAddress(w)
+	addr, err := wallet.NewAddress(w, address.SECP256K1)
 	require.NoError(t, err)
 	pubKey, err := w.GetPubKeyForAddress(addr)
 	require.NoError(t, err)